@@ -0,0 +1,18 @@
+#!/bin/sh
+#
+# Prints what zeit is currently tracking, e.g. `▶ acme/dev 1:05`, for shell
+# prompts like starship or powerlevel10k, from the state `zeit status
+# --prompt` caches. Unlike zeit itself, this takes only a few milliseconds.
+# Run `zeit status --prompt` once to enable the cache; zeit keeps it updated
+# from then on.
+#
+
+state="${XDG_CACHE_HOME:-$HOME/.cache}/zeit/prompt"
+
+[ -s "$state" ] || exit 0
+
+IFS="$(printf '\t')" read -r begin segment < "$state"
+[ -n "$begin" ] || exit 0
+
+elapsed=$(( $(date +%s) - begin ))
+printf '%s %d:%02d\n' "$segment" $(( elapsed / 3600 )) $(( elapsed % 3600 / 60 ))
@@ -0,0 +1,34 @@
+// Package zeit is the data layer of the zeit time tracker: the activities,
+// projects and tasks, the buntdb database the zeit command stores them in,
+// the parsing of times like `-0:15` or `yesterday 14:00` and the aggregation
+// of tracked time.
+//
+// It does not depend on the command line interface, never exits the process
+// and doesn't read the configuration of zeit, so that other tools can read
+// and write the database of a user instead of running zeit:
+//
+//	store, err := zeit.Open(os.Getenv("ZEIT_DB"), zeit.Options{ReadOnly: true})
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//
+//	since, until, err := zeit.ParseRange("thisWeek", time.Now(), time.Monday)
+//	if err != nil {
+//		return err
+//	}
+//	entries, err := store.ListEntriesBetween(user, since, until)
+//	if err != nil {
+//		return err
+//	}
+//	for project, tracked := range zeit.SumByProject(entries, since, until) {
+//		fmt.Printf("%s: %s\n", project, tracked)
+//	}
+//
+// Databases are locked the same way the zeit command locks them, so that
+// both can be used at the same time. Encrypted databases have to be
+// decrypted by the caller and loaded using Load.
+//
+// Exported identifiers of this package are kept compatible; changes
+// breaking them are noted in the release notes.
+package zeit
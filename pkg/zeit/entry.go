@@ -0,0 +1,191 @@
+package zeit
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Entry is a tracked activity. Running activities have no Finish.
+type Entry struct {
+	ID      string    `json:"-"`
+	Begin   time.Time `json:"begin,omitempty"`
+	Finish  time.Time `json:"finish,omitempty"`
+	Project string    `json:"project,omitempty"`
+	Task    string    `json:"task,omitempty"`
+	Notes   string    `json:"notes,omitempty"`
+	User    string    `json:"user,omitempty"`
+
+	Attendees  []string `json:"attendees,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	SHA1 string `json:"-"`
+}
+
+// NewID returns a random ID for an entry.
+func NewID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// GetIdFromName returns the ID projects and tasks are stored and matched by,
+// which ignores case and everything but letters and digits.
+func GetIdFromName(name string) string {
+	reg, regerr := regexp.Compile("[^a-zA-Z0-9]+")
+	if regerr != nil {
+		return ""
+	}
+
+	id := strings.ToLower(reg.ReplaceAllString(name, ""))
+
+	return id
+}
+
+func (entry *Entry) SetIDFromDatabaseKey(key string) error {
+	splitKey := strings.Split(key, ":")
+
+	if len(splitKey) < 3 || len(splitKey) > 3 {
+		return errors.New("not a valid database key")
+	}
+
+	entry.ID = splitKey[2]
+	return nil
+}
+
+func ParseAttendees(attendees []string) []string {
+	var parsed []string
+
+	for _, attendee := range attendees {
+		attendee = strings.TrimSpace(attendee)
+		if attendee != "" {
+			parsed = append(parsed, attendee)
+		}
+	}
+
+	return parsed
+}
+
+func ContainsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (entry *Entry) HasAttendee(attendee string) bool {
+	return ContainsFold(entry.Attendees, attendee)
+}
+
+func ParseTags(tags []string) []string {
+	var parsed []string
+
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag != "" && !ContainsFold(parsed, tag) {
+			parsed = append(parsed, tag)
+		}
+	}
+
+	return parsed
+}
+
+func (entry *Entry) HasTag(tag string) bool {
+	return ContainsFold(entry.Tags, tag)
+}
+
+// ParseReferences normalizes ticket/URL references, e.g. `GH-123` or
+// `https://github.com/mrusme/zeit/issues/1`.
+func ParseReferences(references []string) []string {
+	var parsed []string
+
+	for _, reference := range references {
+		reference = strings.TrimSpace(reference)
+		if reference != "" && !ContainsFold(parsed, reference) {
+			parsed = append(parsed, reference)
+		}
+	}
+
+	return parsed
+}
+
+func (entry *Entry) HasReference(reference string) bool {
+	return ContainsFold(entry.References, reference)
+}
+
+func (entry *Entry) IsFinishedAfterBegan() bool {
+	return (entry.Finish.IsZero() || entry.Begin.Before(entry.Finish) || entry.Begin.Equal(entry.Finish))
+}
+
+// GetDuration returns the hours tracked, up to now for running entries.
+func (entry *Entry) GetDuration() decimal.Decimal {
+	duration := entry.Finish.Sub(entry.Begin)
+	if duration < 0 {
+		duration = time.Now().Sub(entry.Begin)
+	}
+	return decimal.NewFromFloat(duration.Hours())
+}
+
+// End returns the finish of the entry, or now if it is still running.
+func (entry Entry) End() time.Time {
+	if entry.Finish.IsZero() {
+		return time.Now()
+	}
+	return entry.Finish
+}
+
+// Overlaps reports whether the entry overlaps the range from/to, where a
+// zero time means the range is unbounded on that side.
+func (entry Entry) Overlaps(from time.Time, to time.Time) bool {
+	if !to.IsZero() && !entry.Begin.Before(to) {
+		return false
+	}
+
+	if !from.IsZero() && !entry.End().After(from) {
+		return false
+	}
+
+	return true
+}
+
+// FilterEntries returns the entries on the project and task that began at or
+// after since and finished at or before until. Empty values and zero times
+// don't filter.
+func FilterEntries(entries []Entry, project string, task string, since time.Time, until time.Time) []Entry {
+	var filteredEntries []Entry
+
+	for _, entry := range entries {
+		if project != "" && GetIdFromName(entry.Project) != GetIdFromName(project) {
+			continue
+		}
+
+		if task != "" && GetIdFromName(entry.Task) != GetIdFromName(task) {
+			continue
+		}
+
+		if since.IsZero() == false && since.Before(entry.Begin) == false && since.Equal(entry.Begin) == false {
+			continue
+		}
+
+		if until.IsZero() == false && until.After(entry.Finish) == false && until.Equal(entry.Finish) == false {
+			continue
+		}
+
+		if until.IsZero() == false && entry.Finish.IsZero() && !entry.Begin.Before(until) {
+			continue
+		}
+
+		filteredEntries = append(filteredEntries, entry)
+	}
+
+	return filteredEntries
+}
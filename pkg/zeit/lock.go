@@ -0,0 +1,83 @@
+package zeit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errWouldBlock = errors.New("lock is held by another process")
+
+type Lock struct {
+	File      *os.File
+	Exclusive bool
+}
+
+type LockedError struct {
+	Path string
+	PID  int
+}
+
+func (lerr *LockedError) Error() string {
+	if lerr.PID > 0 {
+		return fmt.Sprintf("database is locked by PID %d", lerr.PID)
+	}
+	return "database is locked by another process"
+}
+
+func readLockPID(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+	return pid
+}
+
+// AcquireLock takes an advisory lock on the file at path, which is exclusive
+// for processes writing to the database and shared for those only reading
+// from it. The process holding an exclusive lock writes its PID into the
+// file. Unless wait is set, a held lock returns a *LockedError. The lock is
+// released by the operating system when the process exits.
+func AcquireLock(path string, exclusive bool, wait bool) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = lockFile(file, exclusive)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			file.Close()
+			return nil, err
+		}
+
+		if !wait {
+			file.Close()
+			return nil, &LockedError{Path: path, PID: readLockPID(path)}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// No exclusive lock is held at this point, so a PID still in the file is
+	// outdated
+	file.Truncate(0)
+	if exclusive {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{File: file, Exclusive: exclusive}, nil
+}
+
+// Release unlocks the file, for processes that do not hold the lock until
+// they exit.
+func (lock *Lock) Release() error {
+	return lock.File.Close()
+}
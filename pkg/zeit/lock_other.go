@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package zeit
+
+import (
+	"os"
+)
+
+// Advisory file locks are not available on this platform, so concurrent
+// access is not guarded against.
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package zeit
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type ProjectRules struct {
+	NotesRequired  bool `json:"notesRequired,omitempty"`
+	TaskRequired   bool `json:"taskRequired,omitempty"`
+	KnownTasksOnly bool `json:"knownTasksOnly,omitempty"`
+}
+
+// ProjectBudget is the time or, if Amount is set, the money that may be spent
+// on a project in total.
+type ProjectBudget struct {
+	Duration time.Duration   `json:"duration,omitempty"`
+	Amount   decimal.Decimal `json:"amount,omitempty"`
+}
+
+func (budget *ProjectBudget) IsSet() bool {
+	return budget.Duration > 0 || budget.Amount.IsPositive()
+}
+
+type Project struct {
+	Name     string          `json:"name,omitempty"`
+	Color    string          `json:"color,omitempty"`
+	Billable bool            `json:"billable,omitempty"`
+	Rate     decimal.Decimal `json:"rate,omitempty"`
+	Currency string          `json:"currency,omitempty"`
+	Tasks    []string        `json:"tasks,omitempty"`
+	Rules    ProjectRules    `json:"rules,omitempty"`
+	Budget   ProjectBudget   `json:"budget,omitempty"`
+}
+
+func (project *Project) HasTask(task string) bool {
+	for _, projectTask := range project.Tasks {
+		if GetIdFromName(projectTask) == GetIdFromName(task) {
+			return true
+		}
+	}
+	return false
+}
+
+type Task struct {
+	Name          string `json:"name,omitempty"`
+	GitRepository string `json:"gitRepository,omitempty"`
+	// Project and Issue are set on tasks imported from assigned issues
+	Project string `json:"project,omitempty"`
+	Issue   string `json:"issue,omitempty"`
+}
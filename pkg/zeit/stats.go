@@ -0,0 +1,86 @@
+package zeit
+
+import (
+	"time"
+)
+
+// DateFormat is the format of the days SumByDay sums up by.
+const DateFormat string = "2006-01-02"
+
+// ClippedDuration returns the time of the entry that lies within from/to.
+// Running entries count up to now.
+func ClippedDuration(entry Entry, from time.Time, to time.Time) time.Duration {
+	begin, end := entry.Begin, entry.End()
+	if begin.Before(from) {
+		begin = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if end.Before(begin) {
+		return 0
+	}
+	return end.Sub(begin)
+}
+
+// Sum returns the time tracked within from/to. A zero time leaves the range
+// unbounded on that side.
+func Sum(entries []Entry, from time.Time, to time.Time) time.Duration {
+	var sum time.Duration
+	for _, entry := range entries {
+		sum += clippedToRange(entry, from, to)
+	}
+	return sum
+}
+
+// SumByProject returns the time tracked within from/to per project, by the
+// name of the project as it was tracked first.
+func SumByProject(entries []Entry, from time.Time, to time.Time) map[string]time.Duration {
+	sums := make(map[string]time.Duration)
+	names := make(map[string]string)
+	for _, entry := range entries {
+		duration := clippedToRange(entry, from, to)
+		if duration <= 0 {
+			continue
+		}
+		id := GetIdFromName(entry.Project)
+		if _, ok := names[id]; !ok {
+			names[id] = entry.Project
+		}
+		sums[names[id]] += duration
+	}
+	return sums
+}
+
+// SumByDay returns the time tracked within from/to per day in the location,
+// formatted using DateFormat. Entries spanning midnight are split between
+// the days.
+func SumByDay(entries []Entry, from time.Time, to time.Time, location *time.Location) map[string]time.Duration {
+	sums := make(map[string]time.Duration)
+	for _, entry := range entries {
+		begin, end := entry.Begin.In(location), entry.End()
+		for day := time.Date(begin.Year(), begin.Month(), begin.Day(), 0, 0, 0, 0, location); day.Before(end); day = day.AddDate(0, 0, 1) {
+			dayFrom, dayTo := day, day.AddDate(0, 0, 1)
+			if !from.IsZero() && from.After(dayFrom) {
+				dayFrom = from
+			}
+			if !to.IsZero() && to.Before(dayTo) {
+				dayTo = to
+			}
+			if duration := ClippedDuration(entry, dayFrom, dayTo); duration > 0 {
+				sums[day.Format(DateFormat)] += duration
+			}
+		}
+	}
+	return sums
+}
+
+func clippedToRange(entry Entry, from time.Time, to time.Time) time.Duration {
+	if from.IsZero() {
+		from = entry.Begin
+	}
+	if to.IsZero() {
+		to = entry.End()
+	}
+	return ClippedDuration(entry, from, to)
+}
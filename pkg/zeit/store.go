@@ -0,0 +1,736 @@
+package zeit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tidwall/buntdb"
+	"github.com/tidwall/gjson"
+)
+
+// ErrNotFound is returned for entries that don't exist.
+var ErrNotFound = buntdb.ErrNotFound
+
+// ErrReadOnly is returned when writing to a store opened read-only.
+var ErrReadOnly = errors.New("database was opened read-only")
+
+// Store is a zeit database. Entries, projects and tasks are stored as JSON
+// under keys prefixed with the user they belong to.
+type Store struct {
+	DB *buntdb.DB
+
+	// OnUpdate is called after every write, e.g. to write an encrypted copy
+	// of a database loaded into memory back to disk.
+	OnUpdate func() error
+
+	lock     *Lock
+	readOnly bool
+}
+
+type Options struct {
+	// ReadOnly stores only take a shared lock and refuse writes.
+	ReadOnly bool
+	// Wait until the database is unlocked instead of returning a
+	// *LockedError.
+	Wait bool
+}
+
+// Open locks and opens the database at path the same way the zeit command
+// does, creating it if it doesn't exist yet.
+func Open(path string, options Options) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	lock, err := AcquireLock(path+".lock", !options.ReadOnly, options.Wait)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := buntdb.Open(path)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+
+	store := NewStore(db)
+	store.lock = lock
+	store.readOnly = options.ReadOnly
+	return store, nil
+}
+
+// Load reads a database into memory, e.g. after decrypting it.
+func Load(r io.Reader) (*Store, error) {
+	db, err := buntdb.Open(":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Load(r); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return NewStore(db), nil
+}
+
+// NewStore uses an opened buntdb database as store, creating the indexes it
+// requires.
+func NewStore(db *buntdb.DB) *Store {
+	db.CreateIndex("task", "*", buntdb.IndexJSON("task"))
+	db.CreateIndex("project", "*", buntdb.IndexJSON("project"))
+	db.CreateIndex("begin", "*:entry:*", indexBegin)
+
+	return &Store{DB: db}
+}
+
+// Save writes the database, e.g. to encrypt it.
+func (store *Store) Save(w io.Writer) error {
+	return store.DB.Save(w)
+}
+
+func (store *Store) Close() error {
+	err := store.DB.Close()
+	if store.lock != nil {
+		store.lock.Release()
+		store.lock = nil
+	}
+	return err
+}
+
+func (store *Store) update(fn func(tx *buntdb.Tx) error) error {
+	if store.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := store.DB.Update(fn); err != nil {
+		return err
+	}
+
+	if store.OnUpdate != nil {
+		return store.OnUpdate()
+	}
+	return nil
+}
+
+// indexBegin orders entries by their begin time. Timestamps are compared as
+// times rather than strings, as they might have been stored with different
+// UTC offsets.
+func indexBegin(a, b string) bool {
+	beginA, _ := time.Parse(time.RFC3339Nano, gjson.Get(a, "begin").String())
+	beginB, _ := time.Parse(time.RFC3339Nano, gjson.Get(b, "begin").String())
+	return beginA.Before(beginB)
+}
+
+func beginPivot(t time.Time) string {
+	return `{"begin":"` + t.Format(time.RFC3339Nano) + `"}`
+}
+
+// setLongest keeps track of an upper bound for the duration of finished
+// entries, which is how far ListEntriesBetween has to look back for entries
+// that began before the requested range. Databases created before the bound
+// was tracked get it initialised on their first write.
+func setLongest(tx *buntdb.Tx, user string, entry Entry) error {
+	var longest time.Duration
+
+	value, err := tx.Get(user + ":status:longest")
+	if err == nil {
+		longest, _ = time.ParseDuration(value)
+	} else if errors.Is(err, buntdb.ErrNotFound) {
+		longest, err = scanLongest(tx, user)
+		if err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	if !entry.Finish.IsZero() && entry.Finish.Sub(entry.Begin) > longest {
+		longest = entry.Finish.Sub(entry.Begin)
+	} else if value != "" {
+		return nil
+	}
+
+	_, _, err = tx.Set(user+":status:longest", longest.String(), nil)
+	return err
+}
+
+func scanLongest(tx *buntdb.Tx, user string) (time.Duration, error) {
+	var longest time.Duration
+
+	err := tx.AscendKeys(user+":entry:*", func(key, value string) bool {
+		beginFinish := gjson.GetMany(value, "begin", "finish")
+		begin, _ := time.Parse(time.RFC3339Nano, beginFinish[0].String())
+		finish, err := time.Parse(time.RFC3339Nano, beginFinish[1].String())
+		if err == nil && finish.Sub(begin) > longest {
+			longest = finish.Sub(begin)
+		}
+		return true
+	})
+
+	return longest, err
+}
+
+func (store *Store) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	id, err := NewID()
+	if err != nil {
+		return id, err
+	}
+
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return id, jsonerr
+	}
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		if setRunning == true {
+			_, _, seterr := tx.Set(user+":status:running", id, nil)
+			if seterr != nil {
+				return seterr
+			}
+		}
+		_, _, seterr := tx.Set(user+":entry:"+id, string(entryJson), nil)
+		if seterr != nil {
+			return seterr
+		}
+
+		return setLongest(tx, user, entry)
+	})
+
+	return id, dberr
+}
+
+func (store *Store) GetEntry(user string, entryId string) (Entry, error) {
+	var entry Entry
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user + ":entry:" + entryId)
+		if err != nil {
+			return err
+		}
+		json.Unmarshal([]byte(value), &entry)
+
+		entry.ID = entryId
+		return nil
+	})
+
+	return entry, dberr
+}
+
+func (store *Store) UpdateEntry(user string, entry Entry) (string, error) {
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return entry.ID, jsonerr
+	}
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, _, seerr := tx.Set(user+":entry:"+entry.ID, string(entryJson), nil)
+		if seerr != nil {
+			return seerr
+		}
+
+		return setLongest(tx, user, entry)
+	})
+
+	return entry.ID, dberr
+}
+
+func (store *Store) FinishEntry(user string, entry Entry) (string, error) {
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return entry.ID, jsonerr
+	}
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		runningEntryId, grerr := tx.Get(user + ":status:running")
+		if grerr != nil {
+			return errors.New("no currently running entry found!")
+		}
+
+		if runningEntryId != entry.ID {
+			return errors.New("specified entry is not currently running!")
+		}
+
+		_, _, srerr := tx.Set(user+":status:running", "", nil)
+		if srerr != nil {
+			return srerr
+		}
+
+		_, _, seerr := tx.Set(user+":entry:"+entry.ID, string(entryJson), nil)
+		if seerr != nil {
+			return seerr
+		}
+
+		return setLongest(tx, user, entry)
+	})
+
+	return entry.ID, dberr
+}
+
+func (store *Store) EraseEntry(user string, id string) error {
+	runningEntryId, err := store.GetRunningEntryId(user)
+	if err != nil {
+		return err
+	}
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		if runningEntryId == id {
+			_, _, seterr := tx.Set(user+":status:running", "", nil)
+			if seterr != nil {
+				return seterr
+			}
+		}
+
+		_, delerr := tx.Delete(user + ":entry:" + id)
+		if delerr != nil {
+			return delerr
+		}
+
+		return nil
+	})
+
+	return dberr
+}
+
+func (store *Store) GetRunningEntryId(user string) (string, error) {
+	var runningId string = ""
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user + ":status:running")
+		if errors.Is(err, buntdb.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		runningId = value
+		return nil
+	})
+
+	return runningId, dberr
+}
+
+func (store *Store) SetRunningEntryId(user string, id string) error {
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, _, seterr := tx.Set(user+":status:running", id, nil)
+		return seterr
+	})
+
+	return dberr
+}
+
+func (store *Store) ListEntries(user string) ([]Entry, error) {
+	var entries []Entry
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		tx.AscendKeys(user+":entry:*", func(key, value string) bool {
+			var entry Entry
+			json.Unmarshal([]byte(value), &entry)
+
+			entry.SetIDFromDatabaseKey(key)
+
+			entries = append(entries, entry)
+			return true
+		})
+
+		return nil
+	})
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+	return entries, dberr
+}
+
+// ListEntriesBetween returns all entries overlapping the range from/to, using
+// the begin index to only touch entries that began within the range or at
+// most the longest entry duration before it. A zero time leaves the range
+// unbounded on that side.
+func (store *Store) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	var entries []Entry
+
+	if from.IsZero() && to.IsZero() {
+		return store.ListEntries(user)
+	}
+
+	longest, err := store.getLongest(user)
+	if err != nil {
+		return entries, err
+	}
+
+	runningEntryId, err := store.GetRunningEntryId(user)
+	if err != nil {
+		return entries, err
+	}
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		iterator := func(key, value string) bool {
+			if !strings.HasPrefix(key, user+":entry:") {
+				return true
+			}
+
+			var entry Entry
+			json.Unmarshal([]byte(value), &entry)
+			entry.SetIDFromDatabaseKey(key)
+
+			if entry.ID != runningEntryId && entry.Overlaps(from, to) {
+				entries = append(entries, entry)
+			}
+			return true
+		}
+
+		switch {
+		case from.IsZero():
+			return tx.AscendLessThan("begin", beginPivot(to), iterator)
+		case to.IsZero():
+			return tx.AscendGreaterOrEqual("begin", beginPivot(from.Add(-longest)), iterator)
+		default:
+			return tx.AscendRange("begin", beginPivot(from.Add(-longest)), beginPivot(to), iterator)
+		}
+	})
+	if dberr != nil {
+		return entries, dberr
+	}
+
+	// The running entry might have begun long before the longest finished one
+	if runningEntryId != "" {
+		runningEntry, err := store.GetEntry(user, runningEntryId)
+		if err == nil && runningEntry.Overlaps(from, to) {
+			entries = append(entries, runningEntry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+	return entries, nil
+}
+
+// ListLatestEntries walks the begin index backwards from to, so that only as
+// many entries are read as needed to fill the page.
+func (store *Store) ListLatestEntries(user string, from time.Time, to time.Time, match func(Entry) bool, offset int, limit int) ([]Entry, error) {
+	var entries []Entry
+
+	longest, err := store.getLongest(user)
+	if err != nil {
+		return entries, err
+	}
+
+	runningEntryId, err := store.GetRunningEntryId(user)
+	if err != nil {
+		return entries, err
+	}
+
+	// The running entry might have begun long before the longest finished
+	// one, so it is merged in where it belongs by its begin
+	var running *Entry
+	if runningEntryId != "" {
+		runningEntry, err := store.GetEntry(user, runningEntryId)
+		if err == nil && runningEntry.Overlaps(from, to) && match(runningEntry) {
+			running = &runningEntry
+		}
+	}
+
+	var skipped int
+	add := func(entry Entry) bool {
+		if skipped < offset {
+			skipped++
+			return true
+		}
+		entries = append(entries, entry)
+		return limit <= 0 || len(entries) < limit
+	}
+
+	more := true
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		iterator := func(key, value string) bool {
+			if !strings.HasPrefix(key, user+":entry:") {
+				return true
+			}
+
+			var entry Entry
+			json.Unmarshal([]byte(value), &entry)
+			entry.SetIDFromDatabaseKey(key)
+
+			if entry.ID == runningEntryId || !entry.Overlaps(from, to) || !match(entry) {
+				return true
+			}
+			if running != nil && !running.Begin.Before(entry.Begin) {
+				runningEntry := *running
+				running = nil
+				if more = add(runningEntry); !more {
+					return false
+				}
+			}
+			more = add(entry)
+			return more
+		}
+
+		switch {
+		case from.IsZero() && to.IsZero():
+			return tx.Descend("begin", iterator)
+		case from.IsZero():
+			return tx.DescendLessOrEqual("begin", beginPivot(to), iterator)
+		case to.IsZero():
+			return tx.DescendGreaterThan("begin", beginPivot(from.Add(-longest)), iterator)
+		default:
+			return tx.DescendRange("begin", beginPivot(to), beginPivot(from.Add(-longest)), iterator)
+		}
+	})
+	if dberr != nil {
+		return entries, dberr
+	}
+
+	if running != nil && more {
+		add(*running)
+	}
+
+	return entries, nil
+}
+
+// getLongest returns the upper bound for the duration of finished entries,
+// scanning all entries for databases that were not written to since it is
+// being tracked.
+func (store *Store) getLongest(user string) (time.Duration, error) {
+	var longest time.Duration
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user + ":status:longest")
+		if errors.Is(err, buntdb.ErrNotFound) {
+			longest, err = scanLongest(tx, user)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		longest, err = time.ParseDuration(value)
+		return err
+	})
+
+	return longest, dberr
+}
+
+// ListRawEntries returns the stored JSON of all entries by ID, without
+// attempting to parse it.
+func (store *Store) ListRawEntries(user string) (map[string]string, error) {
+	rawEntries := make(map[string]string)
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(user+":entry:*", func(key, value string) bool {
+			rawEntries[strings.TrimPrefix(key, user+":entry:")] = value
+			return true
+		})
+	})
+
+	return rawEntries, dberr
+}
+
+func (store *Store) GetImportsSHA1List(user string) (map[string]string, error) {
+	sha1List := make(map[string]string)
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user+":imports:sha1", false)
+		if err != nil {
+			return nil
+		}
+
+		sha1Entries := strings.Split(value, ",")
+
+		for _, sha1Entry := range sha1Entries {
+			sha1EntrySplit := strings.Split(sha1Entry, ":")
+			if len(sha1EntrySplit) < 2 {
+				continue
+			}
+			sha1 := sha1EntrySplit[0]
+			id := sha1EntrySplit[1]
+			sha1List[sha1] = id
+		}
+
+		return nil
+	})
+
+	return sha1List, dberr
+}
+
+func (store *Store) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	var sha1Entries []string
+
+	for sha1, id := range sha1List {
+		sha1Entries = append(sha1Entries, sha1+":"+id)
+	}
+
+	value := strings.Join(sha1Entries, ",")
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, _, seterr := tx.Set(user+":imports:sha1", value, nil)
+		if seterr != nil {
+			return seterr
+		}
+
+		return nil
+	})
+
+	return dberr
+}
+
+// GetMeta returns bookkeeping data of integrations stored under key, or an
+// empty string if nothing was stored yet.
+func (store *Store) GetMeta(user string, key string) (string, error) {
+	var value string
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		var err error
+		value, err = tx.Get(user+":meta:"+key, false)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		return err
+	})
+
+	return value, dberr
+}
+
+func (store *Store) SetMeta(user string, key string, value string) error {
+	return store.update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(user+":meta:"+key, value, nil)
+		return err
+	})
+}
+
+func (store *Store) UpdateProject(user string, projectName string, project Project) error {
+	projectJson, jsonerr := json.Marshal(project)
+	if jsonerr != nil {
+		return jsonerr
+	}
+
+	projectId := GetIdFromName(projectName)
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, _, sperr := tx.Set(user+":project:"+projectId, string(projectJson), nil)
+		if sperr != nil {
+			return sperr
+		}
+
+		return nil
+	})
+
+	return dberr
+}
+
+func (store *Store) GetProject(user string, projectName string) (Project, error) {
+	var project Project
+	projectId := GetIdFromName(projectName)
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user+":project:"+projectId, false)
+		if err != nil {
+			return nil
+		}
+
+		json.Unmarshal([]byte(value), &project)
+
+		return nil
+	})
+
+	return project, dberr
+}
+
+func (store *Store) UpdateTask(user string, taskName string, task Task) error {
+	taskJson, jsonerr := json.Marshal(task)
+	if jsonerr != nil {
+		return jsonerr
+	}
+
+	taskId := GetIdFromName(taskName)
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, _, sperr := tx.Set(user+":task:"+taskId, string(taskJson), nil)
+		if sperr != nil {
+			return sperr
+		}
+
+		return nil
+	})
+
+	return dberr
+}
+
+func (store *Store) GetTask(user string, taskName string) (Task, error) {
+	var task Task
+	taskId := GetIdFromName(taskName)
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(user+":task:"+taskId, false)
+		if err != nil {
+			return nil
+		}
+
+		json.Unmarshal([]byte(value), &task)
+
+		return nil
+	})
+
+	return task, dberr
+}
+
+func (store *Store) ListProjects(user string) ([]Project, error) {
+	var projects []Project
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		tx.AscendKeys(user+":project:*", func(key, value string) bool {
+			var project Project
+			json.Unmarshal([]byte(value), &project)
+
+			projects = append(projects, project)
+			return true
+		})
+
+		return nil
+	})
+
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, dberr
+}
+
+func (store *Store) EraseProject(user string, projectName string) error {
+	projectId := GetIdFromName(projectName)
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, delerr := tx.Delete(user + ":project:" + projectId)
+		return delerr
+	})
+
+	return dberr
+}
+
+func (store *Store) ListTasks(user string) ([]Task, error) {
+	var tasks []Task
+
+	dberr := store.DB.View(func(tx *buntdb.Tx) error {
+		tx.AscendKeys(user+":task:*", func(key, value string) bool {
+			var task Task
+			json.Unmarshal([]byte(value), &task)
+
+			tasks = append(tasks, task)
+			return true
+		})
+
+		return nil
+	})
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks, dberr
+}
+
+func (store *Store) EraseTask(user string, taskName string) error {
+	taskId := GetIdFromName(taskName)
+
+	dberr := store.update(func(tx *buntdb.Tx) error {
+		_, delerr := tx.Delete(user + ":task:" + taskId)
+		return delerr
+	})
+
+	return dberr
+}
@@ -0,0 +1,175 @@
+package zeit
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/markusmobius/go-dateparser"
+)
+
+// Time formats as returned by GetTimeFormat
+const (
+	TFAbsTwelveHour     int = 0
+	TFAbsTwentyfourHour int = 1
+	TFRelHourMinute     int = 2
+	TFRelHourFraction   int = 3
+)
+
+func TimeFormats() []string {
+	return []string{
+		`^\d{1,2}:\d{1,2}(am|pm)$`,     // Absolute twelve hour format
+		`^\d{1,2}:\d{1,2}$`,            // Absolute twenty four hour format
+		`^([+-])(\d{1,2}):(\d{1,2})$`,  // Relative hour:minute format
+		`^([+-])(\d{1,2})\.(\d{1,2})$`, // Relative hour.fraction format
+	}
+}
+
+func GetTimeFormat(timeStr string) int {
+	var matched bool
+	var regerr error
+
+	for timeFormatId, timeFormat := range TimeFormats() {
+		matched, regerr = regexp.MatchString(timeFormat, timeStr)
+		if regerr != nil {
+			return -1
+		}
+
+		if matched == true {
+			return timeFormatId
+		}
+	}
+
+	return -1
+}
+
+// RelToTime applies a relative time like `-0:15` or `+1.50` to contextTime,
+// or to now if contextTime is zero.
+func RelToTime(timeStr string, ftId int, contextTime time.Time) (time.Time, error) {
+	re := regexp.MustCompile(TimeFormats()[ftId])
+	gm := re.FindStringSubmatch(timeStr)
+
+	if len(gm) < 4 {
+		return time.Now(), errors.New("No match")
+	}
+
+	var hours int = 0
+	var minutes int = 0
+
+	if ftId == TFRelHourFraction {
+		f, _ := strconv.ParseFloat(gm[2]+"."+gm[3], 32)
+		minutes = int(f * 60.0)
+	} else {
+		hours, _ = strconv.Atoi(gm[2])
+		minutes, _ = strconv.Atoi(gm[3])
+	}
+
+	if contextTime.IsZero() {
+		contextTime = time.Now().Local()
+	}
+
+	offset := time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes)
+	if gm[1] == "-" {
+		offset = -offset
+	}
+
+	return contextTime.Add(offset), nil
+}
+
+// ParseTime parses absolute times like `14:00` or `2006-01-02 15:04`, natural
+// language like `yesterday 9am` and relative times, which are applied to
+// contextTime, or to now if contextTime is zero.
+func ParseTime(timeStr string, contextTime time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation("Local")
+	if err != nil {
+		return time.Now(), errors.New("could not load location")
+	}
+
+	cfg := dateparser.Configuration{
+		DefaultTimezone: loc,
+	}
+
+	tfId := GetTimeFormat(timeStr)
+
+	switch tfId {
+	case TFRelHourMinute, TFRelHourFraction:
+		return RelToTime(timeStr, tfId, contextTime)
+	default:
+		tnew, err := dateparser.Parse(&cfg, timeStr)
+		if err != nil {
+			return time.Now(), errors.New("could not match passed time")
+		}
+
+		return tnew.Time, err
+	}
+}
+
+func Ranges() []string {
+	return []string{
+		"today",
+		"yesterday",
+		"thisWeek",
+		"lastWeek",
+		"thisMonth",
+		"lastMonth",
+		"thisQuarter",
+		"lastQuarter",
+		"thisYear",
+		"lastYear",
+	}
+}
+
+// ParseRange returns the beginning and the end of a range like `lastWeek`
+// relative to today, in the location of today. `last-week` reads as well.
+func ParseRange(listRange string, today time.Time, weekStartDay time.Weekday) (time.Time, time.Time, error) {
+	var sinceTime time.Time
+	var untilTime time.Time
+
+	config := &now.Config{WeekStartDay: weekStartDay, TimeLocation: today.Location()}
+	day := config.With(today)
+
+	switch strings.ReplaceAll(strings.ToLower(listRange), "-", "") {
+	case "today":
+		sinceTime = day.BeginningOfDay()
+		untilTime = day.EndOfDay()
+	case "yesterday":
+		yesterday := config.With(day.BeginningOfDay().AddDate(0, 0, -1))
+		sinceTime = yesterday.BeginningOfDay()
+		untilTime = yesterday.EndOfDay()
+	case "thisweek":
+		sinceTime = day.BeginningOfWeek()
+		untilTime = day.EndOfWeek()
+	case "lastweek":
+		lastWeek := config.With(day.BeginningOfWeek().AddDate(0, 0, -1))
+		sinceTime = lastWeek.BeginningOfWeek()
+		untilTime = lastWeek.EndOfWeek()
+	case "thismonth":
+		sinceTime = day.BeginningOfMonth()
+		untilTime = day.EndOfMonth()
+	case "lastmonth":
+		lastMonth := config.With(day.BeginningOfMonth().AddDate(0, 0, -1))
+		sinceTime = lastMonth.BeginningOfMonth()
+		untilTime = lastMonth.EndOfMonth()
+	case "thisquarter":
+		sinceTime = day.BeginningOfQuarter()
+		untilTime = day.EndOfQuarter()
+	case "lastquarter":
+		lastQuarter := config.With(day.BeginningOfQuarter().AddDate(0, 0, -1))
+		sinceTime = lastQuarter.BeginningOfQuarter()
+		untilTime = lastQuarter.EndOfQuarter()
+	case "thisyear":
+		sinceTime = day.BeginningOfYear()
+		untilTime = day.EndOfYear()
+	case "lastyear":
+		lastYear := config.With(day.BeginningOfYear().AddDate(0, 0, -1))
+		sinceTime = lastYear.BeginningOfYear()
+		untilTime = lastYear.EndOfYear()
+	default:
+		return sinceTime, untilTime, errors.New("unknown range selection, possible options: " + strings.Join(Ranges(), " "))
+	}
+
+	return sinceTime, untilTime, nil
+}
@@ -0,0 +1,422 @@
+package z
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+)
+
+// `zeit import activitywatch` turns the window events ActivityWatch recorded
+// into activities, using the same rules as `zeit watch`:
+//
+//	activitywatch:
+//	  url: http://localhost:5600
+//	  # default are watch.rules
+//	  rules:
+//	    - app: (?i)code
+//	      title: '(ACME-[0-9]+).*acme'
+//	      project: acme
+//	      task: $1
+//	  merge: 5m    # gaps between windows of the same activity joined
+//	  minimum: 5m  # shorter activities left out
+//
+// Time ActivityWatch reports as AFK is left out. The candidates are reviewed
+// one by one before anything is imported.
+
+const defaultActivityWatchServer string = "http://localhost:5600"
+
+const defaultActivityWatchMerge time.Duration = 5 * time.Minute
+
+const defaultActivityWatchMinimum time.Duration = 5 * time.Minute
+
+type ActivityWatchImport struct {
+	Server  string
+	Host    string
+	Rules   []WatchRule
+	Merge   time.Duration
+	Minimum time.Duration
+}
+
+type activityWatchEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Duration  float64   `json:"duration"`
+	Data      struct {
+		App    string `json:"app"`
+		Title  string `json:"title"`
+		Status string `json:"status"`
+	} `json:"data"`
+}
+
+// activityWatchSpan is a window in front, or the time away from keyboard,
+// for a span of time.
+type activityWatchSpan struct {
+	Begin  time.Time
+	End    time.Time
+	Window Window
+	AFK    bool
+}
+
+// ActivityWatchCandidate is an activity made of the windows matching the
+// same rules, waiting to be reviewed.
+type ActivityWatchCandidate struct {
+	Entry   Entry
+	Certain bool
+	Reason  string
+	windows map[Window]time.Duration
+}
+
+// NewActivityWatchImport reads activitywatch.*; the server is the one given,
+// activitywatch.url, watch.activitywatch or the default of ActivityWatch.
+func NewActivityWatchImport(server string, host string) (ActivityWatchImport, error) {
+	aw := ActivityWatchImport{Server: server, Host: host}
+	var err error
+
+	for _, key := range []string{"activitywatch.url", "watch.activitywatch"} {
+		if aw.Server == "" {
+			aw.Server = viper.GetString(key)
+		}
+	}
+	if aw.Server == "" {
+		aw.Server = defaultActivityWatchServer
+	}
+	aw.Server = strings.TrimSuffix(aw.Server, "/")
+
+	if viper.IsSet("activitywatch.rules") {
+		aw.Rules, err = readWatchRules("activitywatch.rules")
+	} else {
+		aw.Rules, err = GetWatchRules()
+	}
+	if err != nil {
+		return aw, err
+	}
+	if len(aw.Rules) == 0 {
+		return aw, errors.New("no rules configured, please configure activitywatch.rules or watch.rules")
+	}
+
+	if aw.Merge, err = getWatchDuration("activitywatch.merge", defaultActivityWatchMerge); err != nil {
+		return aw, err
+	}
+	if aw.Minimum, err = getWatchDuration("activitywatch.minimum", defaultActivityWatchMinimum); err != nil {
+		return aw, err
+	}
+	return aw, nil
+}
+
+// buckets returns the IDs of the window and AFK watcher buckets, of the host
+// if one is given.
+func (aw *ActivityWatchImport) buckets() ([]string, []string, error) {
+	var buckets map[string]struct {
+		Type     string `json:"type"`
+		Hostname string `json:"hostname"`
+	}
+	if err := activityWatchGet(aw.Server+"/api/0/buckets/", &buckets); err != nil {
+		return nil, nil, err
+	}
+
+	var windowBuckets, afkBuckets []string
+	for id, info := range buckets {
+		if aw.Host != "" && !strings.EqualFold(info.Hostname, aw.Host) {
+			continue
+		}
+		switch info.Type {
+		case "currentwindow":
+			windowBuckets = append(windowBuckets, id)
+		case "afkstatus":
+			afkBuckets = append(afkBuckets, id)
+		}
+	}
+
+	if len(windowBuckets) == 0 {
+		if aw.Host != "" {
+			return nil, nil, fmt.Errorf("ActivityWatch has no window watcher bucket of %s", aw.Host)
+		}
+		return nil, nil, errors.New("ActivityWatch has no window watcher bucket, is aw-watcher-window running?")
+	}
+	return windowBuckets, afkBuckets, nil
+}
+
+// spans returns the events of the bucket between since and until.
+func (aw *ActivityWatchImport) spans(bucket string, since time.Time, until time.Time) ([]activityWatchSpan, error) {
+	var spans []activityWatchSpan
+
+	query := url.Values{}
+	query.Set("start", since.Format(time.RFC3339))
+	query.Set("end", until.Format(time.RFC3339))
+	query.Set("limit", "-1")
+
+	var events []activityWatchEvent
+	if err := activityWatchGet(aw.Server+"/api/0/buckets/"+url.PathEscape(bucket)+"/events?"+query.Encode(), &events); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		span := activityWatchSpan{
+			Begin:  event.Timestamp.Local(),
+			End:    event.Timestamp.Local().Add(time.Duration(event.Duration * float64(time.Second))),
+			Window: Window{App: event.Data.App, Title: event.Data.Title},
+			AFK:    event.Data.Status == "afk",
+		}
+		if span.Begin.Before(since) {
+			span.Begin = since
+		}
+		if span.End.After(until) {
+			span.End = until
+		}
+		if span.End.After(span.Begin) {
+			spans = append(spans, span)
+		}
+	}
+	return spans, nil
+}
+
+// subtractAFK cuts the AFK spans out of the window spans.
+func subtractAFK(spans []activityWatchSpan, afk []activityWatchSpan) []activityWatchSpan {
+	for _, away := range afk {
+		var remaining []activityWatchSpan
+		for _, span := range spans {
+			if !away.Begin.Before(span.End) || !away.End.After(span.Begin) {
+				remaining = append(remaining, span)
+				continue
+			}
+			if away.Begin.After(span.Begin) {
+				before := span
+				before.End = away.Begin
+				remaining = append(remaining, before)
+			}
+			if away.End.Before(span.End) {
+				after := span
+				after.Begin = away.End
+				remaining = append(remaining, after)
+			}
+		}
+		spans = remaining
+	}
+	return spans
+}
+
+// mergeCandidates joins the candidates of the same project and task that
+// are at most gap apart.
+func mergeCandidates(candidates []ActivityWatchCandidate, gap time.Duration) []ActivityWatchCandidate {
+	var merged []ActivityWatchCandidate
+
+	for _, candidate := range candidates {
+		last := len(merged) - 1
+		if last < 0 ||
+			!strings.EqualFold(merged[last].Entry.Project, candidate.Entry.Project) ||
+			!strings.EqualFold(merged[last].Entry.Task, candidate.Entry.Task) ||
+			candidate.Entry.Begin.Sub(merged[last].Entry.Finish) > gap {
+			merged = append(merged, candidate)
+			continue
+		}
+
+		if candidate.Entry.Finish.After(merged[last].Entry.Finish) {
+			merged[last].Entry.Finish = candidate.Entry.Finish
+		}
+		if merged[last].Certain && !candidate.Certain {
+			merged[last].Certain = false
+			merged[last].Reason = candidate.Reason
+		}
+		for window, duration := range candidate.windows {
+			merged[last].windows[window] += duration
+		}
+	}
+	return merged
+}
+
+// Candidates returns the activities the windows between since and until
+// make according to the rules. Windows matching the same rules are joined if
+// they are at most Merge apart, and activities shorter than Minimum are left
+// out, so that a quick look at another window doesn't interrupt an activity.
+func (aw *ActivityWatchImport) Candidates(user string, since time.Time, until time.Time) ([]ActivityWatchCandidate, error) {
+	windowBuckets, afkBuckets, err := aw.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	var spans, afk []activityWatchSpan
+	for _, bucket := range windowBuckets {
+		bucketSpans, err := aw.spans(bucket, since, until)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, bucketSpans...)
+	}
+	for _, bucket := range afkBuckets {
+		bucketSpans, err := aw.spans(bucket, since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, span := range bucketSpans {
+			if span.AFK {
+				afk = append(afk, span)
+			}
+		}
+	}
+
+	spans = subtractAFK(spans, afk)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Begin.Before(spans[j].Begin) })
+
+	var candidates []ActivityWatchCandidate
+	var covered time.Time
+	for _, span := range spans {
+		// Windows of several hosts may overlap, the earlier one wins
+		if span.Begin.Before(covered) {
+			span.Begin = covered
+		}
+		if !span.End.After(span.Begin) {
+			continue
+		}
+		covered = span.End
+
+		match, ok := MatchWatchRules(aw.Rules, span.Window)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, ActivityWatchCandidate{
+			Entry: Entry{
+				Begin:   span.Begin,
+				Finish:  span.End,
+				Project: match.Project,
+				Task:    match.Task,
+				User:    user,
+			},
+			Certain: match.Certain,
+			Reason:  match.Reason,
+			windows: map[Window]time.Duration{span.Window: span.End.Sub(span.Begin)},
+		})
+	}
+
+	var kept []ActivityWatchCandidate
+	for _, candidate := range mergeCandidates(candidates, aw.Merge) {
+		if candidate.Entry.Finish.Sub(candidate.Entry.Begin) >= aw.Minimum {
+			kept = append(kept, candidate)
+		}
+	}
+	candidates = mergeCandidates(kept, aw.Merge)
+
+	for idx := range candidates {
+		entry := &candidates[idx].Entry
+		entry.SHA1 = fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("activitywatch\x1f%d\x1f%d\x1f%s\x1f%s",
+			entry.Begin.Unix(), entry.Finish.Unix(), strings.ToLower(entry.Project), strings.ToLower(entry.Task)))))
+	}
+	return candidates, nil
+}
+
+// Windows returns the windows the candidate was made of, the longest in
+// front first.
+func (candidate ActivityWatchCandidate) Windows() []Window {
+	var windows []Window
+	for window := range candidate.windows {
+		windows = append(windows, window)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		if candidate.windows[windows[i]] != candidate.windows[windows[j]] {
+			return candidate.windows[windows[i]] > candidate.windows[windows[j]]
+		}
+		return windows[i].Title < windows[j].Title
+	})
+	return windows
+}
+
+// GetOutput shows the candidate with the three windows it was in front of
+// most.
+func (candidate ActivityWatchCandidate) GetOutput() string {
+	entry := candidate.Entry
+	output := fmt.Sprintf("%s %s from %s to %s (%sh) %s\n",
+		CharMore,
+		entry.Begin.Format("Mon 2006-01-02"),
+		color.FgLightWhite.Render(entry.Begin.Format("15:04")),
+		color.FgLightWhite.Render(entry.Finish.Format("15:04")),
+		color.FgLightWhite.Render(fmtDuration(entry.Finish.Sub(entry.Begin))),
+		color.FgLightWhite.Render(watchName(entry.Project, entry.Task)))
+
+	for idx, window := range candidate.Windows() {
+		if idx == 3 {
+			output += fmt.Sprintf("   %s\n", color.FgGray.Render(fmt.Sprintf("and %d more windows", len(candidate.windows)-idx)))
+			break
+		}
+		output += fmt.Sprintf("   %s %s\n",
+			color.FgGray.Render(strings.TrimPrefix(window.App+": "+window.Title, ": ")),
+			color.FgGray.Render("("+fmtDuration(candidate.windows[window])+"h)"))
+	}
+	if !candidate.Certain {
+		output += fmt.Sprintf("%s %s\n", CharInfo, candidate.Reason)
+	}
+	return output
+}
+
+// ReviewActivityWatchCandidates asks for every candidate whether to import
+// it, allowing to change its project and task first, and returns the
+// accepted ones.
+func ReviewActivityWatchCandidates(candidates []ActivityWatchCandidate) ([]Entry, error) {
+	var accepted []Entry
+
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(prompt string, value string) (string, error) {
+		if value != "" {
+			prompt += " (" + value + ")"
+		}
+		fmt.Printf("  %s: ", prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return value, err
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+		return value, nil
+	}
+
+	for idx := 0; idx < len(candidates); idx++ {
+		candidate := &candidates[idx]
+		fmt.Printf("\n%s", candidate.GetOutput())
+		fmt.Printf("%s [y]import  [n]skip  [e]edit  [a]import all  [d]done  [q]quit: ", CharMore)
+		key, err := readKey()
+		fmt.Printf("%c\n", key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(string(key)) {
+		case "y", "\r", "\n":
+			accepted = append(accepted, candidate.Entry)
+		case "n":
+		case "e":
+			if candidate.Entry.Project, err = ask("project", candidate.Entry.Project); err != nil {
+				return nil, err
+			}
+			task, err := ask("task, - for none", candidate.Entry.Task)
+			if err != nil {
+				return nil, err
+			}
+			if task == "-" {
+				task = ""
+			}
+			candidate.Entry.Task = task
+			candidate.Certain = true
+			idx--
+		case "a":
+			for _, rest := range candidates[idx:] {
+				accepted = append(accepted, rest.Entry)
+			}
+			return accepted, nil
+		case "d":
+			return accepted, nil
+		case "q", "\x03", "\x1b":
+			return nil, errors.New("aborted")
+		default:
+			fmt.Printf("%s unknown action\n", CharError)
+			idx--
+		}
+	}
+	return accepted, nil
+}
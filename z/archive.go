@@ -0,0 +1,395 @@
+package z
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Archives are yearly snapshots of all activities for long-term retention,
+// e.g. of tax records. Besides the activities as JSON and CSV they contain a
+// manifest with the checksums of all files, which signed archives carry a
+// detached GPG signature of.
+
+const archiveFormat string = "zeit-archive"
+const archiveVersion int = 1
+
+const (
+	archiveManifest  string = "manifest.json"
+	archiveSignature string = "manifest.json.asc"
+)
+
+type ArchiveManifest struct {
+	Format  string            `json:"format"`
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	User    string            `json:"user"`
+	Zeit    string            `json:"zeit"`
+	Year    int               `json:"year"`
+	Counts  map[string]int    `json:"counts"`
+	Files   map[string]string `json:"files"`
+}
+
+type Archive struct {
+	Manifest ArchiveManifest
+	Entries  []BackupEntry
+	Projects []Project
+	Tasks    []Task
+	Signed   bool
+}
+
+// ArchiveChanges lists the activities of the archived year that were added,
+// changed or erased in the database since the archive was created.
+type ArchiveChanges struct {
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Erased  []string `json:"erased"`
+}
+
+func ArchiveFormats() []string {
+	return []string{
+		ArchiveZip,
+		ArchiveSignedZip,
+	}
+}
+
+func GetArchiveSigningKey(key string) string {
+	if key != "" {
+		return key
+	}
+	return viper.GetString("archive.signingKey")
+}
+
+func archiveYearEntries(user string, year int) ([]Entry, error) {
+	location, err := GetTimeLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+	entries, err := database.ListEntriesBetween(user, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only activities beginning within the year and already finished belong
+	// to it, running ones might still change
+	var yearEntries []Entry
+	for _, entry := range entries {
+		if entry.Finish.IsZero() || entry.Begin.In(location).Year() != year {
+			continue
+		}
+		yearEntries = append(yearEntries, entry)
+	}
+	sort.Slice(yearEntries, func(i, j int) bool { return yearEntries[i].Begin.Before(yearEntries[j].Begin) })
+
+	return yearEntries, nil
+}
+
+func NewArchive(user string, year int) (Archive, error) {
+	var err error
+
+	archive := Archive{
+		Manifest: ArchiveManifest{
+			Format:  archiveFormat,
+			Version: archiveVersion,
+			Created: time.Now(),
+			User:    user,
+			Zeit:    VERSION,
+			Year:    year,
+		},
+	}
+
+	entries, err := archiveYearEntries(user, year)
+	if err != nil {
+		return archive, err
+	}
+	for _, entry := range entries {
+		archive.Entries = append(archive.Entries, BackupEntry{ID: entry.ID, Entry: entry})
+	}
+
+	if archive.Projects, err = database.ListProjects(user); err != nil {
+		return archive, err
+	}
+
+	if archive.Tasks, err = database.ListTasks(user); err != nil {
+		return archive, err
+	}
+
+	return archive, nil
+}
+
+func (archive *Archive) files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	for name, v := range map[string]interface{}{
+		"entries.json":  archive.Entries,
+		"projects.json": archive.Projects,
+		"tasks.json":    archive.Tasks,
+	} {
+		content, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+
+	entries := make([]Entry, len(archive.Entries))
+	for idx, entry := range archive.Entries {
+		entries[idx] = entry.Entry
+		entries[idx].ID = entry.ID
+	}
+	csv, err := ExportCSV(entries, CSVOptions{Columns: CSVColumns()})
+	if err != nil {
+		return nil, err
+	}
+	files["entries.csv"] = []byte(csv)
+
+	return files, nil
+}
+
+// Write writes the archive as zip file. If sign is set, the manifest is
+// signed using signingKey, or the default key of GPG if it is empty.
+func (archive *Archive) Write(writer io.Writer, sign bool, signingKey string) error {
+	files, err := archive.files()
+	if err != nil {
+		return err
+	}
+
+	archive.Manifest.Files = make(map[string]string)
+	for name, content := range files {
+		archive.Manifest.Files[name] = fmt.Sprintf("%x", sha256.Sum256(content))
+	}
+	archive.Manifest.Counts = map[string]int{
+		"entries":  len(archive.Entries),
+		"projects": len(archive.Projects),
+		"tasks":    len(archive.Tasks),
+	}
+
+	manifest, err := json.MarshalIndent(archive.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files[archiveManifest] = manifest
+
+	if sign {
+		args := []string{"--armor", "--detach-sign"}
+		if signingKey != "" {
+			args = append(args, "--local-user", signingKey)
+		}
+		signature, err := runGPG(manifest, args...)
+		if err != nil {
+			return err
+		}
+		files[archiveSignature] = signature
+		archive.Signed = true
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(writer)
+	for _, name := range names {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: archive.Manifest.Created,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (archive *Archive) WriteFile(path string, sign bool, signingKey string) error {
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, sign, signingKey); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0400)
+}
+
+// verifyArchiveSignature verifies the detached signature of the manifest
+// using the GPG keyring.
+func verifyArchiveSignature(manifest []byte, signature []byte) error {
+	file, err := os.CreateTemp("", "zeit-archive-*.asc")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(signature); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if _, err = runGPG(manifest, "--verify", file.Name(), "-"); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
+
+// ReadArchiveFile reads an archive and verifies the checksums of all files
+// against the manifest as well as the signature of the manifest, if any.
+func ReadArchiveFile(path string) (Archive, error) {
+	var archive Archive
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return archive, fmt.Errorf("not a zeit archive: %v", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return archive, fmt.Errorf("corrupt archive: %v", err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return archive, fmt.Errorf("corrupt archive: %s: %v", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+
+	manifest, ok := files[archiveManifest]
+	if !ok {
+		return archive, errors.New("not a zeit archive: manifest.json missing")
+	}
+	if err = json.Unmarshal(manifest, &archive.Manifest); err != nil {
+		return archive, fmt.Errorf("corrupt manifest: %v", err)
+	}
+
+	if archive.Manifest.Format != archiveFormat {
+		return archive, errors.New("not a zeit archive")
+	}
+	if archive.Manifest.Version > archiveVersion {
+		return archive, fmt.Errorf("archive version %d is not supported by this version of zeit", archive.Manifest.Version)
+	}
+
+	if signature, ok := files[archiveSignature]; ok {
+		if err = verifyArchiveSignature(manifest, signature); err != nil {
+			return archive, err
+		}
+		archive.Signed = true
+	}
+
+	for name := range files {
+		if _, ok := archive.Manifest.Files[name]; !ok && name != archiveManifest && name != archiveSignature {
+			return archive, fmt.Errorf("corrupt archive: %s is not part of the manifest", name)
+		}
+	}
+	for name, checksum := range archive.Manifest.Files {
+		content, ok := files[name]
+		if !ok {
+			return archive, fmt.Errorf("corrupt archive: %s missing", name)
+		}
+		if fmt.Sprintf("%x", sha256.Sum256(content)) != checksum {
+			return archive, fmt.Errorf("corrupt archive: checksum mismatch for %s", name)
+		}
+	}
+
+	for name, v := range map[string]interface{}{
+		"entries.json":  &archive.Entries,
+		"projects.json": &archive.Projects,
+		"tasks.json":    &archive.Tasks,
+	} {
+		if _, ok := archive.Manifest.Files[name]; !ok {
+			return archive, fmt.Errorf("corrupt archive: %s missing from manifest", name)
+		}
+		if err = json.Unmarshal(files[name], v); err != nil {
+			return archive, fmt.Errorf("corrupt archive: %s: %v", name, err)
+		}
+	}
+
+	if len(archive.Entries) != archive.Manifest.Counts["entries"] {
+		return archive, errors.New("corrupt archive: number of entries does not match manifest")
+	}
+
+	return archive, nil
+}
+
+// Compare reports how the activities of the archived year in the database
+// differ from the archive.
+func (archive *Archive) Compare(user string) (ArchiveChanges, error) {
+	var changes ArchiveChanges
+
+	entries, err := archiveYearEntries(user, archive.Manifest.Year)
+	if err != nil {
+		return changes, err
+	}
+
+	current := make(map[string]Entry)
+	for _, entry := range entries {
+		current[entry.ID] = entry
+	}
+
+	for _, archived := range archive.Entries {
+		entry, ok := current[archived.ID]
+		if !ok {
+			changes.Erased = append(changes.Erased, archived.ID)
+			continue
+		}
+		delete(current, archived.ID)
+
+		entry.ID = ""
+		archived.Entry.ID = ""
+		if !archivedEntryEqual(entry, archived.Entry) {
+			changes.Changed = append(changes.Changed, archived.ID)
+		}
+	}
+	for id := range current {
+		changes.Added = append(changes.Added, id)
+	}
+	sort.Strings(changes.Added)
+
+	return changes, nil
+}
+
+// archivedEntryEqual compares entries the way they are stored in archives,
+// which does not retain monotonic clock readings or locations.
+func archivedEntryEqual(a Entry, b Entry) bool {
+	if !a.Begin.Equal(b.Begin) || !a.Finish.Equal(b.Finish) {
+		return false
+	}
+	a.Begin, a.Finish, b.Begin, b.Finish = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	a.SHA1, b.SHA1 = "", ""
+	return reflect.DeepEqual(normalizeArchivedEntry(a), normalizeArchivedEntry(b))
+}
+
+func normalizeArchivedEntry(entry Entry) Entry {
+	for _, list := range []*[]string{&entry.Attendees, &entry.Tags, &entry.References} {
+		if len(*list) == 0 {
+			*list = nil
+		}
+	}
+	return entry
+}
+
+func (changes ArchiveChanges) Empty() bool {
+	return len(changes.Added) == 0 && len(changes.Changed) == 0 && len(changes.Erased) == 0
+}
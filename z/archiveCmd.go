@@ -0,0 +1,15 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Yearly archives",
+	Long:  "Create and verify yearly archives of all activities, checksummed and optionally signed, for long-term retention of e.g. tax records.",
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+}
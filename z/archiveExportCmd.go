@@ -0,0 +1,78 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	archiveYear       int
+	archiveFormatFlag string
+	archiveSigningKey string
+)
+
+var archiveExportCmd = &cobra.Command{
+	Use:         "export ([flags]) [file]",
+	Short:       "Export yearly archive",
+	Long:        "Export all finished activities of a year, along with the projects and tasks, into a compressed zip archive with the checksums of all files. Signed archives carry a detached GPG signature of these checksums.",
+	Args:        cobra.RangeArgs(0, 1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if !zeit.ContainsFold(ArchiveFormats(), archiveFormatFlag) {
+			return fmt.Errorf("unknown archive format '%s', possible values: %s", archiveFormatFlag, strings.Join(ArchiveFormats(), ", "))
+		}
+
+		file := fmt.Sprintf("zeit-archive-%d.zip", archiveYear)
+		if len(args) > 0 {
+			file = args[0]
+		}
+		if fileExists(file) {
+			return fmt.Errorf("%s already exists; archives are never overwritten", file)
+		}
+
+		archive, err := NewArchive(user, archiveYear)
+		if err != nil {
+			return err
+		}
+
+		sign := strings.EqualFold(archiveFormatFlag, ArchiveSignedZip)
+		if err = archive.WriteFile(file, sign, GetArchiveSigningKey(archiveSigningKey)); err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(map[string]interface{}{
+				"file":     file,
+				"signed":   archive.Signed,
+				"manifest": archive.Manifest,
+			})
+		}
+
+		signed := ""
+		if archive.Signed {
+			signed = "signed "
+		}
+		fmt.Printf("%s archived %d entries of %d to %s%s\n",
+			CharInfo,
+			len(archive.Entries),
+			archiveYear,
+			signed,
+			color.FgLightWhite.Render(file),
+		)
+		return nil
+	},
+}
+
+func init() {
+	archiveCmd.AddCommand(archiveExportCmd)
+	archiveExportCmd.Flags().IntVar(&archiveYear, "year", time.Now().Year()-1, "Year to archive")
+	archiveExportCmd.Flags().StringVar(&archiveFormatFlag, "format", ArchiveZip, "Format of the archive, possible values: "+strings.Join(ArchiveFormats(), ", "))
+	archiveExportCmd.Flags().StringVar(&archiveSigningKey, "signing-key", "", "GPG key to sign the archive with (default is archive.signingKey or the default key of GPG)")
+}
@@ -0,0 +1,78 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var archiveVerifyCmd = &cobra.Command{
+	Use:         "verify [file]",
+	Short:       "Verify yearly archive",
+	Long:        "Verify the checksums and, for signed archives, the signature of an archive, and list activities of the archived year that were added, changed or erased in the database since.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		archive, err := ReadArchiveFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		changes, err := archive.Compare(user)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			if err := printJSON(map[string]interface{}{
+				"signed":   archive.Signed,
+				"manifest": archive.Manifest,
+				"changes":  changes,
+			}); err != nil {
+				return err
+			}
+			if !changes.Empty() {
+				return exitWithCode(ExitValidation)
+			}
+			return nil
+		}
+
+		signed := "unsigned"
+		if archive.Signed {
+			signed = "signature valid"
+		}
+		fmt.Printf("%s archive of %d with %d entries is intact, %s, created %s\n",
+			CharInfo,
+			archive.Manifest.Year,
+			len(archive.Entries),
+			signed,
+			archive.Manifest.Created.Format(GetTimeDisplayFormat()),
+		)
+
+		if changes.Empty() {
+			fmt.Printf("%s the database matches the archive\n", CharInfo)
+			return nil
+		}
+
+		for _, list := range []struct {
+			name string
+			ids  []string
+		}{
+			{"added", changes.Added},
+			{"changed", changes.Changed},
+			{"erased", changes.Erased},
+		} {
+			for _, id := range list.ids {
+				fmt.Printf("%s %s %s since archival\n", CharError, color.FgLightWhite.Render(id), list.name)
+			}
+		}
+		return exitWithCode(ExitValidation)
+	},
+}
+
+func init() {
+	archiveCmd.AddCommand(archiveVerifyCmd)
+}
@@ -0,0 +1,142 @@
+package z
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const autoBackupPrefix string = "zeit-auto-"
+
+func GetAutoBackupDirectory() (string, error) {
+	if viper.GetString("backup.directory") != "" {
+		return ExpandPath(viper.GetString("backup.directory")), nil
+	}
+
+	if db, ok := unwrapStorage(database).(*Database); ok {
+		return filepath.Join(filepath.Dir(db.File), "zeit-backups"), nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "zeit", "backups"), nil
+}
+
+// AutoBackup snapshots the user's data before a destructive operation and
+// removes all but the last `backup.keep` automatic backups. It can be
+// disabled by setting `backup.auto` to false.
+func AutoBackup(user string, operation string) error {
+	if !viper.GetBool("backup.auto") {
+		return nil
+	}
+
+	if err := autoBackup(user, operation); err != nil {
+		return &StorageError{
+			Err:  fmt.Errorf("automatic backup before %s failed: %w", operation, err),
+			Hint: "set `backup.auto: false` in the config to disable automatic backups",
+		}
+	}
+	return nil
+}
+
+func autoBackup(user string, operation string) error {
+	directory, err := GetAutoBackupDirectory()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+
+	backup, err := NewUserBackup(user)
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(directory, fmt.Sprintf("%s%s-%s.tar.gz",
+		autoBackupPrefix, time.Now().Format("20060102-150405.000"), operation))
+	if err = backup.WriteFile(file); err != nil {
+		return err
+	}
+
+	if viper.GetBool("debug") {
+		fmt.Fprintln(os.Stderr, "Automatic backup:", file)
+	}
+
+	return pruneAutoBackups(directory, viper.GetInt("backup.keep"))
+}
+
+func pruneAutoBackups(directory string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, autoBackupPrefix+"*.tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+	for len(files) > keep {
+		if err = os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// EncryptAutoBackups encrypts the automatic backups that are still plaintext
+// and returns how many it encrypted.
+func EncryptAutoBackups(enc *Encryption) (int, error) {
+	files, err := ListAutoBackups()
+	if err != nil {
+		return 0, err
+	}
+
+	var encrypted int
+	for _, file := range files {
+		ok, err := IsEncryptedFile(file)
+		if err != nil {
+			return encrypted, err
+		}
+		if ok {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return encrypted, err
+		}
+		if err = enc.WriteFile(file, content); err != nil {
+			return encrypted, err
+		}
+		encrypted++
+	}
+
+	return encrypted, nil
+}
+
+func ListAutoBackups() ([]string, error) {
+	directory, err := GetAutoBackupDirectory()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, autoBackupPrefix+"*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
@@ -0,0 +1,451 @@
+package z
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const backupFormat string = "zeit-backup"
+const backupVersion int = 1
+
+type BackupEntry struct {
+	ID string `json:"id"`
+	Entry
+}
+
+type BackupMeta struct {
+	Running string            `json:"running,omitempty"`
+	Imports map[string]string `json:"imports,omitempty"`
+}
+
+type BackupManifest struct {
+	Format  string            `json:"format"`
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	User    string            `json:"user"`
+	Zeit    string            `json:"zeit"`
+	Counts  map[string]int    `json:"counts"`
+	Files   map[string]string `json:"files"`
+}
+
+type Backup struct {
+	Manifest   BackupManifest
+	Entries    []BackupEntry
+	Projects   []Project
+	Tasks      []Task
+	Meta       BackupMeta
+	Config     []byte
+	ConfigName string
+}
+
+type RestoreStats struct {
+	Entries  int
+	Projects int
+	Tasks    int
+	Skipped  int
+}
+
+func RestoreModes() []string {
+	return []string{
+		RestoreMerge,
+		RestoreReplace,
+	}
+}
+
+// NewUserBackup returns a backup of the entries, projects, tasks and metadata
+// of the user. Other users of the database and the API tokens of `zeit serve`
+// are not part of it.
+func NewUserBackup(user string) (Backup, error) {
+	var err error
+
+	backup := Backup{
+		Manifest: BackupManifest{
+			Format:  backupFormat,
+			Version: backupVersion,
+			Created: time.Now(),
+			User:    user,
+			Zeit:    VERSION,
+		},
+	}
+
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return backup, err
+	}
+	for _, entry := range entries {
+		backup.Entries = append(backup.Entries, BackupEntry{ID: entry.ID, Entry: entry})
+	}
+
+	if backup.Projects, err = database.ListProjects(user); err != nil {
+		return backup, err
+	}
+
+	if backup.Tasks, err = database.ListTasks(user); err != nil {
+		return backup, err
+	}
+
+	if backup.Meta.Running, err = database.GetRunningEntryId(user); err != nil {
+		return backup, err
+	}
+
+	if backup.Meta.Imports, err = database.GetImportsSHA1List(user); err != nil {
+		return backup, err
+	}
+
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		if config, err := os.ReadFile(configFile); err == nil {
+			backup.Config = config
+			backup.ConfigName = filepath.Base(configFile)
+		}
+	}
+
+	return backup, nil
+}
+
+func (backup *Backup) files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	for name, v := range map[string]interface{}{
+		"entries.json":  backup.Entries,
+		"projects.json": backup.Projects,
+		"tasks.json":    backup.Tasks,
+		"meta.json":     backup.Meta,
+	} {
+		content, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+
+	if backup.Config != nil {
+		files["config/"+backup.ConfigName] = backup.Config
+	}
+
+	return files, nil
+}
+
+func (backup *Backup) Write(writer io.Writer) error {
+	files, err := backup.files()
+	if err != nil {
+		return err
+	}
+
+	backup.Manifest.Files = make(map[string]string)
+	for name, content := range files {
+		backup.Manifest.Files[name] = fmt.Sprintf("%x", sha256.Sum256(content))
+	}
+	backup.Manifest.Counts = map[string]int{
+		"entries":  len(backup.Entries),
+		"projects": len(backup.Projects),
+		"tasks":    len(backup.Tasks),
+	}
+
+	manifest, err := json.MarshalIndent(backup.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files["manifest.json"] = manifest
+
+	gz := gzip.NewWriter(writer)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range []string{"manifest.json", "entries.json", "projects.json", "tasks.json", "meta.json"} {
+		if err = writeTarFile(tw, name, files[name], backup.Manifest.Created); err != nil {
+			return err
+		}
+		delete(files, name)
+	}
+	for name, content := range files {
+		if err = writeTarFile(tw, name, content, backup.Manifest.Created); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(content)
+	return err
+}
+
+// WriteFile writes the backup archive to path. Backups of encrypted
+// databases are encrypted the same way the database is.
+func (backup *Backup) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if err := backup.Write(&buf); err != nil {
+		return err
+	}
+
+	if db, ok := unwrapStorage(database).(*Database); ok && db.Encryption != nil {
+		return db.Encryption.WriteFile(path, buf.Bytes())
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// ReadBackup reads a backup archive and verifies the checksums of all files
+// against the manifest.
+func ReadBackup(reader io.Reader) (Backup, error) {
+	var backup Backup
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return backup, fmt.Errorf("not a zeit backup: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return backup, fmt.Errorf("corrupt backup: %v", err)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return backup, fmt.Errorf("corrupt backup: %v", err)
+		}
+		files[header.Name] = content
+	}
+
+	manifest, ok := files["manifest.json"]
+	if !ok {
+		return backup, errors.New("not a zeit backup: manifest.json missing")
+	}
+	if err = json.Unmarshal(manifest, &backup.Manifest); err != nil {
+		return backup, fmt.Errorf("corrupt manifest: %v", err)
+	}
+
+	if backup.Manifest.Format != backupFormat {
+		return backup, errors.New("not a zeit backup")
+	}
+	if backup.Manifest.Version > backupVersion {
+		return backup, fmt.Errorf("backup version %d is not supported by this version of zeit", backup.Manifest.Version)
+	}
+
+	for name, checksum := range backup.Manifest.Files {
+		content, ok := files[name]
+		if !ok {
+			return backup, fmt.Errorf("corrupt backup: %s missing", name)
+		}
+		if fmt.Sprintf("%x", sha256.Sum256(content)) != checksum {
+			return backup, fmt.Errorf("corrupt backup: checksum mismatch for %s", name)
+		}
+
+		if strings.HasPrefix(name, "config/") {
+			backup.Config = content
+			backup.ConfigName = strings.TrimPrefix(name, "config/")
+		}
+	}
+
+	for name, v := range map[string]interface{}{
+		"entries.json":  &backup.Entries,
+		"projects.json": &backup.Projects,
+		"tasks.json":    &backup.Tasks,
+		"meta.json":     &backup.Meta,
+	} {
+		if _, ok := backup.Manifest.Files[name]; !ok {
+			return backup, fmt.Errorf("corrupt backup: %s missing from manifest", name)
+		}
+		if err = json.Unmarshal(files[name], v); err != nil {
+			return backup, fmt.Errorf("corrupt backup: %s: %v", name, err)
+		}
+	}
+
+	if len(backup.Entries) != backup.Manifest.Counts["entries"] {
+		return backup, errors.New("corrupt backup: number of entries does not match manifest")
+	}
+
+	return backup, nil
+}
+
+func ReadBackupFile(path string) (Backup, error) {
+	encryption, plaintext, err := ReadEncryptedFile(path)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	if encryption != nil {
+		return ReadBackup(bytes.NewReader(plaintext))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	defer file.Close()
+
+	return ReadBackup(file)
+}
+
+func (backup *Backup) Restore(user string, mode string) (RestoreStats, error) {
+	var stats RestoreStats
+
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return stats, errors.New("unknown restore mode, possible values: " + strings.Join(RestoreModes(), ", "))
+	}
+
+	err := Batch(func() error {
+		if mode == RestoreReplace {
+			if err := eraseAll(user); err != nil {
+				return err
+			}
+		}
+
+		for _, backupEntry := range backup.Entries {
+			entry := backupEntry.Entry
+			entry.ID = backupEntry.ID
+
+			if mode == RestoreMerge {
+				if _, err := database.GetEntry(user, entry.ID); err == nil {
+					stats.Skipped++
+					continue
+				}
+			}
+
+			if _, err := database.UpdateEntry(user, entry); err != nil {
+				return err
+			}
+			stats.Entries++
+		}
+
+		for _, project := range backup.Projects {
+			if mode == RestoreMerge {
+				existing, err := database.GetProject(user, project.Name)
+				if err != nil {
+					return err
+				}
+				if existing.Name != "" {
+					continue
+				}
+			}
+
+			if err := database.UpdateProject(user, project.Name, project); err != nil {
+				return err
+			}
+			stats.Projects++
+		}
+
+		for _, task := range backup.Tasks {
+			if mode == RestoreMerge {
+				existing, err := database.GetTask(user, task.Name)
+				if err != nil {
+					return err
+				}
+				if existing.Name != "" {
+					continue
+				}
+			}
+
+			if err := database.UpdateTask(user, task.Name, task); err != nil {
+				return err
+			}
+			stats.Tasks++
+		}
+
+		imports := make(map[string]string)
+		if mode == RestoreMerge {
+			existingImports, err := database.GetImportsSHA1List(user)
+			if err != nil {
+				return err
+			}
+			imports = existingImports
+		}
+		for sha1, id := range backup.Meta.Imports {
+			if _, ok := imports[sha1]; !ok {
+				imports[sha1] = id
+			}
+		}
+		if err := database.UpdateImportsSHA1List(user, imports); err != nil {
+			return err
+		}
+
+		if backup.Meta.Running != "" {
+			runningEntryId, err := database.GetRunningEntryId(user)
+			if err != nil {
+				return err
+			}
+
+			// Merging keeps the entry if it exists, it may have been finished
+			// since the backup was made
+			entry, err := database.GetEntry(user, backup.Meta.Running)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if runningEntryId == "" && entry.Finish.IsZero() {
+				return database.SetRunningEntryId(user, backup.Meta.Running)
+			}
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+func eraseAll(user string) error {
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = database.EraseEntry(user, entry.ID); err != nil {
+			return err
+		}
+	}
+
+	projects, err := database.ListProjects(user)
+	if err != nil {
+		return err
+	}
+	for _, project := range projects {
+		if err = database.EraseProject(user, project.Name); err != nil {
+			return err
+		}
+	}
+
+	tasks, err := database.ListTasks(user)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err = database.EraseTask(user, task.Name); err != nil {
+			return err
+		}
+	}
+
+	return database.SetRunningEntryId(user, "")
+}
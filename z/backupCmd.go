@@ -0,0 +1,63 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var backupList bool
+
+var backupCmd = &cobra.Command{
+	Use:         "backup ([flags]) [file]",
+	Short:       "Backup database",
+	Long:        "Dump all entries, projects, tasks and metadata of the current user into a single portable archive. Other users of the database and API tokens are not included. Automatic backups are created before destructive operations.",
+	Args:        cobra.RangeArgs(0, 1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if backupList {
+			files, err := ListAutoBackups()
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
+				fmt.Printf("%s\n", file)
+			}
+			return nil
+		}
+
+		file := fmt.Sprintf("zeit-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+		if len(args) > 0 {
+			file = args[0]
+		}
+
+		backup, err := NewUserBackup(user)
+		if err != nil {
+			return err
+		}
+
+		if err = backup.WriteFile(file); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s backed up %d entries, %d projects and %d tasks of %s to %s\n",
+			CharInfo,
+			len(backup.Entries),
+			len(backup.Projects),
+			len(backup.Tasks),
+			color.FgLightWhite.Render(user),
+			color.FgLightWhite.Render(file),
+		)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.Flags().BoolVar(&backupList, "list", false, "List automatic backups, most recent first")
+}
@@ -0,0 +1,177 @@
+package z
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// addTestEntry adds a finished entry, or a running one without finish.
+func addTestEntry(t *testing.T, user string, begin time.Time, finish time.Time) string {
+	t.Helper()
+
+	id, err := database.AddEntry(user, Entry{Begin: begin, Finish: finish, Project: "zeit", User: user}, finish.IsZero())
+	if err != nil {
+		t.Fatalf("AddEntry() failed: %v", err)
+	}
+	return id
+}
+
+// roundTripBackup writes the backup and reads it back.
+func roundTripBackup(t *testing.T, backup Backup) Backup {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := backup.Write(&buf); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	read, err := ReadBackup(&buf)
+	if err != nil {
+		t.Fatalf("ReadBackup() failed: %v", err)
+	}
+	return read
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	useTestDatabase(t)
+	user := "test"
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	finishedId := addTestEntry(t, user, begin, begin.Add(time.Hour))
+	runningId := addTestEntry(t, user, begin.Add(2*time.Hour), time.Time{})
+	if err := database.UpdateProject(user, "zeit", Project{Name: "zeit", Color: "#ff0000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.UpdateTask(user, "review", Task{Name: "review"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.UpdateImportsSHA1List(user, map[string]string{"sha1": finishedId}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := NewUserBackup(user)
+	if err != nil {
+		t.Fatalf("NewUserBackup() failed: %v", err)
+	}
+	read := roundTripBackup(t, backup)
+
+	if read.Manifest.User != user || len(read.Entries) != 2 || len(read.Projects) != 1 || len(read.Tasks) != 1 {
+		t.Fatalf("ReadBackup() = %+v, want the backed up user, 2 entries, 1 project and 1 task", read)
+	}
+	if read.Meta.Running != runningId || read.Meta.Imports["sha1"] != finishedId {
+		t.Errorf("ReadBackup() meta = %+v, want running %s and the imports", read.Meta, runningId)
+	}
+	if read.Projects[0].Color != "#ff0000" {
+		t.Errorf("ReadBackup() project = %+v, want its color", read.Projects[0])
+	}
+
+	// Restoring into an empty database brings back everything
+	useTestDatabase(t)
+	stats, err := read.Restore(user, RestoreReplace)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Projects != 1 || stats.Tasks != 1 {
+		t.Errorf("Restore() = %+v, want 2 entries, 1 project and 1 task", stats)
+	}
+	entry, err := database.GetEntry(user, finishedId)
+	if err != nil || !entry.Begin.Equal(begin) || !entry.Finish.Equal(begin.Add(time.Hour)) {
+		t.Errorf("GetEntry() = %+v, %v, want the restored entry", entry, err)
+	}
+	if running, _ := database.GetRunningEntryId(user); running != runningId {
+		t.Errorf("GetRunningEntryId() = %q, want %q", running, runningId)
+	}
+}
+
+func TestRestoreMergeKeepsExisting(t *testing.T) {
+	useTestDatabase(t)
+	user := "test"
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	keptId := addTestEntry(t, user, begin, begin.Add(time.Hour))
+	deletedId := addTestEntry(t, user, begin.Add(2*time.Hour), begin.Add(3*time.Hour))
+	if err := database.UpdateProject(user, "zeit", Project{Name: "zeit", Color: "#ff0000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.UpdateImportsSHA1List(user, map[string]string{"old": keptId}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := NewUserBackup(user)
+	if err != nil {
+		t.Fatalf("NewUserBackup() failed: %v", err)
+	}
+	backup = roundTripBackup(t, backup)
+
+	// Changes after the backup was made
+	kept, _ := database.GetEntry(user, keptId)
+	kept.Notes = "changed"
+	if _, err = database.UpdateEntry(user, kept); err != nil {
+		t.Fatal(err)
+	}
+	if err = database.EraseEntry(user, deletedId); err != nil {
+		t.Fatal(err)
+	}
+	if err = database.UpdateProject(user, "zeit", Project{Name: "zeit", Color: "#00ff00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = database.UpdateImportsSHA1List(user, map[string]string{"new": keptId}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := backup.Restore(user, RestoreMerge)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if stats.Entries != 1 || stats.Skipped != 1 || stats.Projects != 0 {
+		t.Errorf("Restore() = %+v, want 1 restored and 1 skipped entry", stats)
+	}
+
+	if kept, _ = database.GetEntry(user, keptId); kept.Notes != "changed" {
+		t.Errorf("the existing entry was overwritten: %+v", kept)
+	}
+	if _, err = database.GetEntry(user, deletedId); err != nil {
+		t.Errorf("the erased entry was not restored: %v", err)
+	}
+	if project, _ := database.GetProject(user, "zeit"); project.Color != "#00ff00" {
+		t.Errorf("the existing project was overwritten: %+v", project)
+	}
+	if imports, _ := database.GetImportsSHA1List(user); len(imports) != 2 {
+		t.Errorf("GetImportsSHA1List() = %v, want the existing and restored imports", imports)
+	}
+}
+
+func TestRestoreMergeRunningEntry(t *testing.T) {
+	useTestDatabase(t)
+	user := "test"
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	runningId := addTestEntry(t, user, begin, time.Time{})
+	backup, err := NewUserBackup(user)
+	if err != nil {
+		t.Fatalf("NewUserBackup() failed: %v", err)
+	}
+
+	// The activity was finished after the backup was made
+	entry, _ := database.GetEntry(user, runningId)
+	entry.Finish = begin.Add(time.Hour)
+	if _, err = database.FinishEntry(user, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = backup.Restore(user, RestoreMerge); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if running, _ := database.GetRunningEntryId(user); running != "" {
+		t.Errorf("GetRunningEntryId() = %q, want the finished activity not to run again", running)
+	}
+
+	// Restoring into another database brings the running activity back
+	useTestDatabase(t)
+	if _, err = backup.Restore(user, RestoreMerge); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if running, _ := database.GetRunningEntryId(user); running != runningId {
+		t.Errorf("GetRunningEntryId() = %q, want %q", running, runningId)
+	}
+}
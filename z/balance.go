@@ -0,0 +1,110 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+type BalancePeriod struct {
+	Begin          time.Time `json:"begin"`
+	TrackedSeconds int64     `json:"trackedSeconds"`
+	TargetSeconds  int64     `json:"targetSeconds"`
+	DeltaSeconds   int64     `json:"deltaSeconds"`
+	BalanceSeconds int64     `json:"balanceSeconds"`
+}
+
+// Balance is the flextime balance: the time tracked beyond or short of the
+// targets, carried from day to day and week to week since the balance began.
+type Balance struct {
+	Since          time.Time       `json:"since"`
+	CarriedSeconds int64           `json:"carriedSeconds"`
+	Days           []BalancePeriod `json:"days"`
+	Weeks          []BalancePeriod `json:"weeks"`
+	BalanceSeconds int64           `json:"balanceSeconds"`
+}
+
+// GetBalanceStart returns the day the balance begins, configurable as
+// targets.since and otherwise the day of the first activity, and the
+// balance carried over into it, configurable as targets.balance.
+func GetBalanceStart(user string) (time.Time, time.Duration, error) {
+	var since time.Time
+	var carried time.Duration
+	var err error
+
+	// YAML reads unquoted dates as timestamps already
+	if value, ok := viper.Get("targets.since").(time.Time); ok {
+		since = time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.Local)
+	} else if value := viper.GetString("targets.since"); value != "" {
+		if since, err = time.ParseInLocation(DateFormat, value, time.Local); err != nil {
+			return since, carried, fmt.Errorf("invalid targets.since '%s', use e.g. 2024-01-01", value)
+		}
+	} else {
+		entries, err := database.ListEntries(user)
+		if err != nil {
+			return since, carried, err
+		}
+		for _, entry := range entries {
+			if since.IsZero() || entry.Begin.Before(since) {
+				since = entry.Begin
+			}
+		}
+		if since.IsZero() {
+			since = time.Now()
+		}
+		since = now.With(since).BeginningOfDay()
+	}
+
+	if value := strings.TrimSpace(viper.GetString("targets.balance")); value != "" {
+		if carried, err = parseTarget(strings.TrimLeft(value, "+-")); err != nil {
+			return since, carried, fmt.Errorf("invalid targets.balance '%s', use e.g. 2h30m or -1h", value)
+		}
+		if strings.HasPrefix(value, "-") {
+			carried = -carried
+		}
+	}
+
+	return since, carried, nil
+}
+
+// NewBalance sums up the days from since through until, the day until is on
+// counting with its full target.
+func NewBalance(user string, targets Targets, since time.Time, carried time.Duration, until time.Time) (Balance, error) {
+	balance := Balance{Since: since, CarriedSeconds: int64(carried.Seconds()), BalanceSeconds: int64(carried.Seconds())}
+	end := now.With(until).BeginningOfDay().AddDate(0, 0, 1)
+
+	entries, err := database.ListEntriesBetween(user, since, end)
+	if err != nil {
+		return balance, err
+	}
+
+	var week *BalancePeriod
+	for day := since; day.Before(end); day = day.AddDate(0, 0, 1) {
+		period := BalancePeriod{Begin: day, TargetSeconds: int64(targets.Day(day).Seconds())}
+		for _, entry := range entries {
+			period.TrackedSeconds += int64(zeit.ClippedDuration(entry, day, day.AddDate(0, 0, 1)).Seconds())
+		}
+		period.DeltaSeconds = period.TrackedSeconds - period.TargetSeconds
+		balance.BalanceSeconds += period.DeltaSeconds
+		period.BalanceSeconds = balance.BalanceSeconds
+		balance.Days = append(balance.Days, period)
+
+		if week == nil || day.Weekday() == time.Monday {
+			balance.Weeks = append(balance.Weeks, BalancePeriod{Begin: day})
+			week = &balance.Weeks[len(balance.Weeks)-1]
+		}
+		week.TrackedSeconds += period.TrackedSeconds
+		week.TargetSeconds += period.TargetSeconds
+		week.DeltaSeconds += period.DeltaSeconds
+		week.BalanceSeconds = period.BalanceSeconds
+	}
+
+	if balance.Days == nil {
+		balance.Days, balance.Weeks = []BalancePeriod{}, []BalancePeriod{}
+	}
+	return balance, nil
+}
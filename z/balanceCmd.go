@@ -0,0 +1,88 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	balanceDays bool
+	balanceLast int
+)
+
+var balanceCmd = &cobra.Command{
+	Use:         "balance",
+	Short:       "Flextime balance",
+	Long:        "Show the time tracked beyond or short of the configured targets per week, or per day using --days, and the flextime balance carried across them since targets.since.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		targets, err := GetTargets()
+		if err != nil {
+			return err
+		}
+		if !targets.IsSet() {
+			return fmt.Errorf("no targets configured; set targets.day or targets.days")
+		}
+
+		since, carried, err := GetBalanceStart(user)
+		if err != nil {
+			return err
+		}
+
+		balance, err := NewBalance(user, targets, since, carried, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(balance)
+		}
+
+		periods, label := balance.Weeks, func(begin time.Time) string {
+			year, week := begin.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+		if balanceDays {
+			periods, label = balance.Days, func(begin time.Time) string {
+				return begin.Format("Mon " + DateFormat)
+			}
+		}
+		if balanceLast > 0 && len(periods) > balanceLast {
+			periods = periods[len(periods)-balanceLast:]
+		}
+
+		seconds := func(s int64) time.Duration {
+			return time.Duration(s) * time.Second
+		}
+
+		fmt.Printf("\n   %-14s %10s %10s %10s %10s\n", "", "Tracked", "Target", "Delta", "Balance")
+		for _, period := range periods {
+			fmt.Printf("   %-14s %10s %10s %10s %10s\n",
+				label(period.Begin),
+				fmtDuration(seconds(period.TrackedSeconds)),
+				fmtDuration(seconds(period.TargetSeconds)),
+				fmtDelta(seconds(period.DeltaSeconds)),
+				fmtDelta(seconds(period.BalanceSeconds)),
+			)
+		}
+
+		clr := color.FgLightGreen
+		if balance.BalanceSeconds < 0 {
+			clr = color.FgLightRed
+		}
+		fmt.Printf("\n%s flextime balance since %s: %s\n\n", CharInfo, balance.Since.Format(DateFormat), clr.Render(fmtDelta(seconds(balance.BalanceSeconds))+"h"))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(balanceCmd)
+	balanceCmd.Flags().BoolVar(&balanceDays, "days", false, "Show the balance per day instead of per week")
+	balanceCmd.Flags().IntVar(&balanceLast, "last", 8, "Only show the last weeks or days, 0 shows all")
+	balanceCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,317 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+type TagBudget struct {
+	Tag    string
+	Period string
+	Limit  time.Duration
+}
+
+func BudgetPeriods() []string {
+	return []string{
+		BudgetDay,
+		BudgetWeek,
+		BudgetMonth,
+	}
+}
+
+// ParseTagBudget parses a budget like `5h/week` or `30m/day`. Budgets without
+// a period are weekly.
+func ParseTagBudget(tag string, value string) (TagBudget, error) {
+	budget := TagBudget{Tag: tag, Period: BudgetWeek}
+
+	limit, period, found := strings.Cut(strings.TrimSpace(value), "/")
+	if found {
+		budget.Period = strings.ToLower(strings.TrimSpace(period))
+	}
+
+	switch budget.Period {
+	case BudgetDay, BudgetWeek, BudgetMonth:
+	default:
+		return budget, fmt.Errorf("budget of tag %s has unknown period '%s', possible values: %s", tag, budget.Period, strings.Join(BudgetPeriods(), ", "))
+	}
+
+	var err error
+	if budget.Limit, err = time.ParseDuration(strings.TrimSpace(limit)); err != nil {
+		return budget, fmt.Errorf("budget of tag %s has invalid limit '%s', use e.g. 5h or 1h30m", tag, limit)
+	}
+
+	return budget, nil
+}
+
+// GetTagBudgets returns all tag budgets configured as `budgets.tags`.
+func GetTagBudgets() ([]TagBudget, error) {
+	var budgets []TagBudget
+
+	for tag, value := range viper.GetStringMapString("budgets.tags") {
+		budget, err := ParseTagBudget(tag, value)
+		if err != nil {
+			return budgets, err
+		}
+		budgets = append(budgets, budget)
+	}
+
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Tag < budgets[j].Tag })
+	return budgets, nil
+}
+
+func GetTagBudget(tag string) (TagBudget, bool, error) {
+	budgets, err := GetTagBudgets()
+	if err != nil {
+		return TagBudget{}, false, err
+	}
+
+	for _, budget := range budgets {
+		if strings.EqualFold(budget.Tag, tag) {
+			return budget, true, nil
+		}
+	}
+
+	return TagBudget{}, false, nil
+}
+
+// Range returns the beginning and end of the budget period containing t.
+func (budget *TagBudget) Range(t time.Time) (time.Time, time.Time) {
+	if IsFirstWeekDayMonday() {
+		now.WeekStartDay = time.Monday
+	}
+
+	ref := now.With(t)
+	switch budget.Period {
+	case BudgetDay:
+		return ref.BeginningOfDay(), ref.EndOfDay()
+	case BudgetMonth:
+		return ref.BeginningOfMonth(), ref.EndOfMonth()
+	default:
+		return ref.BeginningOfWeek(), ref.EndOfWeek()
+	}
+}
+
+// Used returns the time tracked on the budget's tag within the period
+// containing t.
+func (budget *TagBudget) Used(user string, t time.Time) (time.Duration, error) {
+	var used time.Duration
+
+	from, to := budget.Range(t)
+	entries, err := database.ListEntriesBetween(user, from, to)
+	if err != nil {
+		return used, err
+	}
+
+	for _, entry := range entries {
+		if entry.HasTag(budget.Tag) {
+			used += zeit.ClippedDuration(entry, from, to)
+		}
+	}
+
+	return used, nil
+}
+
+// WarnTagBudgets prints an alert for every tag of the entry that exceeded
+// its budget in the period the entry began in.
+func WarnTagBudgets(user string, entry Entry) {
+	for _, tag := range entry.Tags {
+		budget, found, err := GetTagBudget(tag)
+		if err != nil {
+			fmt.Printf("%s %+v\n", CharError, err)
+			return
+		}
+		if !found {
+			continue
+		}
+
+		used, err := budget.Used(user, entry.Begin)
+		if err != nil {
+			fmt.Printf("%s %+v\n", CharError, err)
+			return
+		}
+
+		if used > budget.Limit {
+			fmt.Printf("%s tag %s exceeded its budget of %sh per %s by %sh\n",
+				CharError,
+				color.FgLightWhite.Render(budget.Tag),
+				color.FgLightWhite.Render(fmtDuration(budget.Limit)),
+				budget.Period,
+				color.FgLightRed.Render(fmtDuration(used-budget.Limit)),
+			)
+		}
+	}
+}
+
+// ParseProjectBudget parses a budget of time like `80h` or of money like
+// `5000`, in the currency of the project's rate.
+func ParseProjectBudget(value string) (ProjectBudget, error) {
+	var budget ProjectBudget
+	var err error
+
+	value = strings.TrimSpace(value)
+	if budget.Duration, err = time.ParseDuration(value); err == nil && budget.Duration >= 0 {
+		return budget, nil
+	}
+	budget.Duration = 0
+	if budget.Amount, err = decimal.NewFromString(value); err == nil && !budget.Amount.IsNegative() {
+		return budget, nil
+	}
+
+	return ProjectBudget{}, fmt.Errorf("invalid budget '%s', use a duration like 80h or an amount like 5000", value)
+}
+
+type ProjectBudgetStatus struct {
+	Project       string          `json:"project"`
+	BudgetSeconds int64           `json:"budgetSeconds,omitempty"`
+	BudgetAmount  decimal.Decimal `json:"budgetAmount"`
+	UsedSeconds   int64           `json:"usedSeconds"`
+	Spent         decimal.Decimal `json:"spent"`
+	Currency      string          `json:"currency,omitempty"`
+
+	Budget ProjectBudget `json:"-"`
+	Used   time.Duration `json:"-"`
+}
+
+// GetProjectBudgetStatus sums up the time tracked on the project up until now
+// and, for budgets of money, what it costs at the project's rate or
+// invoice.rate.
+func GetProjectBudgetStatus(user string, project Project) (ProjectBudgetStatus, error) {
+	status := ProjectBudgetStatus{Project: project.Name, Budget: project.Budget, Currency: project.Currency}
+	if status.Currency == "" {
+		status.Currency = strings.ToUpper(viper.GetString("invoice.currency"))
+	}
+
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return status, err
+	}
+	for _, entry := range entries {
+		if zeit.GetIdFromName(entry.Project) == zeit.GetIdFromName(project.Name) {
+			status.Used += entry.End().Sub(entry.Begin)
+		}
+	}
+
+	if project.Budget.Amount.IsPositive() {
+		rate := project.Rate
+		if rate.IsZero() {
+			if rate, err = parseInvoiceDecimal("rate", viper.GetString("invoice.rate")); err != nil {
+				return status, err
+			}
+		}
+		if rate.IsZero() {
+			return status, fmt.Errorf("no rate for project '%s', set one using `zeit project --rate`", project.Name)
+		}
+		status.Spent = rate.Mul(decimal.NewFromFloat(status.Used.Hours())).Round(2)
+	}
+
+	status.BudgetSeconds = int64(project.Budget.Duration.Seconds())
+	status.BudgetAmount = project.Budget.Amount
+	status.UsedSeconds = int64(status.Used.Seconds())
+
+	return status, nil
+}
+
+// GetProjectBudgetStatuses returns the budget status of every project with a
+// budget.
+func GetProjectBudgetStatuses(user string) ([]ProjectBudgetStatus, error) {
+	statuses := []ProjectBudgetStatus{}
+
+	projects, err := database.ListProjects(user)
+	if err != nil {
+		return statuses, err
+	}
+	for _, project := range projects {
+		if !project.Budget.IsSet() {
+			continue
+		}
+		status, err := GetProjectBudgetStatus(user, project)
+		if err != nil {
+			return statuses, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return strings.ToLower(statuses[i].Project) < strings.ToLower(statuses[j].Project)
+	})
+	return statuses, nil
+}
+
+// Consumed returns the share of the budget used up, 1 being all of it.
+func (status *ProjectBudgetStatus) Consumed() float64 {
+	if status.Budget.Amount.IsPositive() {
+		return status.Spent.Div(status.Budget.Amount).InexactFloat64()
+	}
+	if status.Budget.Duration > 0 {
+		return float64(status.Used) / float64(status.Budget.Duration)
+	}
+	return 0
+}
+
+// GetOutput renders the used and remaining budget, e.g.
+// `32:00h of 80:00h used (40%), 48:00h remaining`.
+func (status *ProjectBudgetStatus) GetOutput() string {
+	var used, budget, remaining string
+	if status.Budget.Amount.IsPositive() {
+		used = FormatMoney(status.Spent, status.Currency)
+		budget = FormatMoney(status.Budget.Amount, status.Currency)
+		remaining = FormatMoney(status.Budget.Amount.Sub(status.Spent).Abs(), status.Currency)
+	} else {
+		used = fmtDuration(status.Used) + "h"
+		budget = fmtDuration(status.Budget.Duration) + "h"
+		remaining = fmtDuration((status.Budget.Duration - status.Used).Abs()) + "h"
+	}
+
+	consumed := status.Consumed()
+	percent := fmt.Sprintf("%.0f%%", consumed*100)
+	switch {
+	case consumed > 1:
+		percent = color.FgLightRed.Render(percent)
+		remaining = color.FgLightRed.Render(remaining) + " over"
+	case consumed == 1:
+		percent = color.FgLightRed.Render(percent)
+		remaining = color.FgLightRed.Render(remaining) + " remaining"
+	case consumed >= ProjectBudgetWarning:
+		percent = color.FgLightYellow.Render(percent)
+		remaining = color.FgLightWhite.Render(remaining) + " remaining"
+	default:
+		remaining = color.FgLightWhite.Render(remaining) + " remaining"
+	}
+
+	return fmt.Sprintf("%s of %s used (%s), %s", color.FgLightWhite.Render(used), color.FgLightWhite.Render(budget), percent, remaining)
+}
+
+// WarnProjectBudget prints an alert if the project of the entry used up
+// ProjectBudgetWarning or all of its budget.
+func WarnProjectBudget(user string, entry Entry) {
+	if entry.Project == "" {
+		return
+	}
+
+	project, err := database.GetProject(user, entry.Project)
+	if err != nil || !project.Budget.IsSet() {
+		return
+	}
+	project.Name = entry.Project
+
+	status, err := GetProjectBudgetStatus(user, project)
+	if err != nil {
+		fmt.Printf("%s %+v\n", CharError, err)
+		return
+	}
+
+	switch consumed := status.Consumed(); {
+	case consumed >= 1:
+		fmt.Printf("%s project %s used up its budget: %s\n", CharError, color.FgLightWhite.Render(project.Name), status.GetOutput())
+	case consumed >= ProjectBudgetWarning:
+		fmt.Printf("%s project %s used %.0f%% of its budget: %s\n", CharError, color.FgLightWhite.Render(project.Name), ProjectBudgetWarning*100, status.GetOutput())
+	}
+}
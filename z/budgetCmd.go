@@ -0,0 +1,42 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var budgetCmd = &cobra.Command{
+	Use:         "budget",
+	Short:       "Project budgets",
+	Long:        "Show how much of their budgets the projects used up. Budgets are set using `zeit budget set`.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		statuses, err := GetProjectBudgetStatuses(user)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(statuses)
+		}
+
+		if len(statuses) == 0 {
+			fmt.Printf("%s no project budgets\n", CharInfo)
+			return nil
+		}
+
+		for _, status := range statuses {
+			fmt.Printf("%s %s %s\n", CharMore, color.FgLightWhite.Render(status.Project), status.GetOutput())
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+	budgetCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,56 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var budgetSetCmd = &cobra.Command{
+	Use:   "set [project] [budget]",
+	Short: "Set project budget",
+	Long:  "Set the budget of a project, either time like 80h or money like 5000 in the currency of the project's rate. A budget of 0 removes it.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+		projectName := args[0]
+
+		budget, err := ParseProjectBudget(args[1])
+		if err != nil {
+			return err
+		}
+
+		project, err := database.GetProject(user, projectName)
+		if err != nil {
+			return err
+		}
+		project.Name = projectName
+		project.Budget = budget
+
+		// A budget of money needs a rate to be of any use
+		status, err := GetProjectBudgetStatus(user, project)
+		if err != nil {
+			return err
+		}
+
+		if err = database.UpdateProject(user, projectName, project); err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(status)
+		}
+
+		if !budget.IsSet() {
+			fmt.Printf("%s removed the budget of %s\n", CharInfo, color.FgLightWhite.Render(projectName))
+			return nil
+		}
+		fmt.Printf("%s budget of %s set, %s\n", CharInfo, color.FgLightWhite.Render(projectName), status.GetOutput())
+		return nil
+	},
+}
+
+func init() {
+	budgetCmd.AddCommand(budgetSetCmd)
+}
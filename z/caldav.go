@@ -0,0 +1,333 @@
+package z
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// CalDAVPull is the calendar events are pulled from into activities, e.g. a
+// work calendar with meetings.
+type CalDAVPull struct {
+	URL      string   `mapstructure:"url"`
+	Project  string   `mapstructure:"project"`
+	Keywords []string `mapstructure:"keywords"`
+}
+
+// CalDAV syncs finished activities as events to the calendar `caldav.url`
+// and pulls events from `caldav.pull.url` into activities.
+type CalDAV struct {
+	URL      string
+	Username string
+	Password string
+	Projects []string
+	Pull     CalDAVPull
+}
+
+type calDAVMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				DisplayName  string `xml:"DAV: displayname"`
+				ETag         string `xml:"DAV: getetag"`
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// NewCalDAV configures the CalDAV client from `caldav.*`. Without a
+// caldav.url or caldav.pull.url, CalDAV is not used and nil is returned.
+func NewCalDAV() (*CalDAV, error) {
+	caldav := CalDAV{
+		URL:      os.ExpandEnv(viper.GetString("caldav.url")),
+		Username: os.ExpandEnv(viper.GetString("caldav.username")),
+		Password: os.ExpandEnv(viper.GetString("caldav.password")),
+		Projects: viper.GetStringSlice("caldav.projects"),
+	}
+	if err := viper.UnmarshalKey("caldav.pull", &caldav.Pull); err != nil {
+		return nil, fmt.Errorf("invalid caldav.pull: %v", err)
+	}
+	caldav.Pull.URL = os.ExpandEnv(caldav.Pull.URL)
+	if caldav.URL == "" && caldav.Pull.URL == "" {
+		return nil, nil
+	}
+
+	// Events are stored within the calendar collection
+	if caldav.URL != "" && !strings.HasSuffix(caldav.URL, "/") {
+		caldav.URL += "/"
+	}
+	return &caldav, nil
+}
+
+func (caldav *CalDAV) request(method string, target string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if caldav.Username != "" {
+		req.SetBasicAuth(caldav.Username, caldav.Password)
+	}
+
+	client := http.Client{Timeout: 60 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return res, nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res, content, fmt.Errorf("%s %s: %s", method, req.URL.Redacted(), res.Status)
+	}
+	return res, content, nil
+}
+
+// resolve returns the absolute URL of an href of a multistatus response.
+func (caldav *CalDAV) resolve(base string, href string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return baseURL.ResolveReference(ref).String()
+}
+
+func (caldav *CalDAV) Name() string {
+	return "CalDAV"
+}
+
+func (caldav *CalDAV) CanPush() bool {
+	return caldav.URL != ""
+}
+
+// stateKey identifies the state of a user syncing with the calendars, so
+// that switching calendars pushes all activities again.
+func (caldav *CalDAV) stateKey(user string) string {
+	return user + " " + caldav.URL + " " + caldav.Pull.URL
+}
+
+// event returns the activity as a calendar object with a single event, and a
+// hash of it without the time it was created at.
+func (caldav *CalDAV) event(entry Entry) ([]byte, string, error) {
+	event := ExportICS([]Entry{entry})
+
+	var stable strings.Builder
+	for _, line := range strings.Split(event, "\r\n") {
+		if !strings.HasPrefix(line, "DTSTAMP:") {
+			stable.WriteString(line + "\n")
+		}
+	}
+	return []byte(event), fmt.Sprintf("%x", sha1.Sum([]byte(stable.String()))), nil
+}
+
+// put stores the event as <ID>.ics in the calendar collection, refusing to
+// overwrite events that were changed on the calendar since.
+func (caldav *CalDAV) put(entry Entry, pushed calendarPushed, exists bool, event []byte) (calendarPushed, error) {
+	headers := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	if !exists {
+		pushed.Href = caldav.URL + url.PathEscape(entry.ID) + ".ics"
+	} else if pushed.ETag != "" {
+		headers["If-Match"] = pushed.ETag
+	}
+
+	res, _, err := caldav.request(http.MethodPut, pushed.Href, event, headers)
+	if res != nil && res.StatusCode == http.StatusPreconditionFailed {
+		return pushed, errors.New("the event was changed on the calendar, not overwriting it")
+	} else if err != nil {
+		return pushed, err
+	}
+
+	pushed.ETag = res.Header.Get("ETag")
+	return pushed, nil
+}
+
+func (caldav *CalDAV) delete(pushed calendarPushed) error {
+	headers := make(map[string]string)
+	if pushed.ETag != "" {
+		headers["If-Match"] = pushed.ETag
+	}
+
+	res, _, err := caldav.request(http.MethodDelete, pushed.Href, nil, headers)
+	if err != nil && res != nil && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone) {
+		return nil
+	}
+	return err
+}
+
+// Push syncs the finished activities of the projects in caldav.projects, or
+// of all projects, to the calendar caldav.url.
+func (caldav *CalDAV) Push(user string, entries []Entry, dryRun bool, report func(Entry, string, error)) (CalendarSyncStats, error) {
+	if caldav.URL == "" {
+		return CalendarSyncStats{}, nil
+	}
+
+	state, file, err := loadCalendarState("caldav", caldav.stateKey(user))
+	if err != nil {
+		return CalendarSyncStats{}, err
+	}
+
+	var pushing []Entry
+	for _, entry := range entries {
+		if len(caldav.Projects) == 0 || zeit.ContainsFold(caldav.Projects, entry.Project) {
+			pushing = append(pushing, entry)
+		}
+	}
+	return pushCalendar(user, caldav, state, file, pushing, dryRun, report)
+}
+
+// displayName returns the name of the calendar collection.
+func (caldav *CalDAV) displayName(target string) (string, error) {
+	body := []byte(`<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:displayname/></d:prop></d:propfind>`)
+	_, content, err := caldav.request("PROPFIND", target, body, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var multistatus calDAVMultistatus
+	if err = xml.Unmarshal(content, &multistatus); err != nil {
+		return "", fmt.Errorf("could not read the calendar: %v", err)
+	}
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if propstat.Prop.DisplayName != "" {
+				return propstat.Prop.DisplayName, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// events returns the events of the calendar between since and until,
+// leaving out the ones pushed by zeit.
+func (caldav *CalDAV) events(target string, since time.Time, until time.Time) (icsCalendar, error) {
+	var calendar icsCalendar
+
+	body := []byte(fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, since.UTC().Format(icsTimeFormat), until.UTC().Format(icsTimeFormat)))
+	_, content, err := caldav.request("REPORT", target, body, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	})
+	if err != nil {
+		return calendar, err
+	}
+
+	var multistatus calDAVMultistatus
+	if err = xml.Unmarshal(content, &multistatus); err != nil {
+		return calendar, fmt.Errorf("could not read the calendar: %v", err)
+	}
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if propstat.Prop.CalendarData == "" {
+				continue
+			}
+			object, err := parseICS([]byte(propstat.Prop.CalendarData))
+			if err != nil {
+				return calendar, fmt.Errorf("%s: %v", caldav.resolve(target, response.Href), err)
+			}
+			for _, event := range object.Events {
+				if !strings.HasSuffix(event.value("UID"), "@"+icsUIDHostname) {
+					calendar.Events = append(calendar.Events, event)
+				}
+			}
+		}
+	}
+	return calendar, nil
+}
+
+// PullEntries returns the events of caldav.pull.url between since and until
+// as activities, except for the ones pulled before. Their project is
+// caldav.pull.project, or else the name of the calendar as mapped in
+// ics.calendars.
+func (caldav *CalDAV) PullEntries(user string, since time.Time, until time.Time) ([]Entry, []error, error) {
+	if caldav.Pull.URL == "" {
+		return nil, nil, nil
+	}
+
+	state, _, err := loadCalendarState("caldav", caldav.stateKey(user))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	calendar, err := caldav.events(caldav.Pull.URL, since, until)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	calendars, err := GetICSCalendarProjects(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if caldav.Pull.Project != "" {
+		calendar.Name = caldav.Pull.Project
+	} else if calendar.Name, err = caldav.displayName(caldav.Pull.URL); err != nil {
+		return nil, nil, err
+	} else if calendar.Name == "" {
+		calendar.Name = filepath.Base(strings.TrimSuffix(caldav.Pull.URL, "/"))
+	}
+
+	entries, eventErrors := importICSCalendar(user, calendar, ICSImportOptions{
+		Calendars: calendars,
+		Keywords:  caldav.Pull.Keywords,
+		Since:     since,
+		Until:     until,
+	})
+
+	var pulled []Entry
+	for _, entry := range entries {
+		if _, ok := state.Pulled[entry.SHA1]; !ok {
+			pulled = append(pulled, entry)
+		}
+	}
+	return pulled, eventErrors, nil
+}
+
+func (caldav *CalDAV) RecordPulled(user string, entries []Entry, sha1List map[string]string) error {
+	if caldav.Pull.URL == "" {
+		return nil
+	}
+
+	state, file, err := loadCalendarState("caldav", caldav.stateKey(user))
+	if err != nil {
+		return err
+	}
+	recordPulled(&state, entries, sha1List)
+	return file.Save(state)
+}
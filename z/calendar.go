@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/shopspring/decimal"
-	"github.com/spf13/viper"
 	// "github.com/gookit/color"
 )
 
@@ -47,7 +47,7 @@ func NewCalendar(entries []Entry) (Calendar, error) {
 		sameDayHours := decimal.NewFromInt(0)
 		nextDayHours := decimal.NewFromInt(0)
 
-		projectId := GetIdFromName(entry.Project)
+		projectId := zeit.GetIdFromName(entry.Project)
 
 		if projects[projectId].Name == "" {
 			project, err := database.GetProject(entry.User, entry.Project)
@@ -147,7 +147,7 @@ func (calendar *Calendar) GetOutputForWeekCalendar(date time.Time, month int, we
 
 	// Set day order based on firstWeekDayMonday configuration
 	var days []string
-	if viper.GetBool("firstWeekDayMonday") {
+	if IsFirstWeekDayMonday() {
 		days = []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
 	} else {
 		days = []string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"}
@@ -0,0 +1,15 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "Calendar integration",
+	Long:  "Sync activities with calendars: finished activities are pushed to a calendar as events and events of a work calendar are pulled into activities.",
+}
+
+func init() {
+	rootCmd.AddCommand(calendarCmd)
+}
@@ -0,0 +1,40 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var calendarLoginCmd = &cobra.Command{
+	Use:         "login",
+	Short:       "Log in to Google Calendar",
+	Long:        "Authorize zeit to access Google Calendar using the OAuth client google.clientId and google.clientSecret, by signing in using the browser. The tokens are kept in google.tokenFile, ~/.local/share/zeit/google-token.json by default.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		google, err := NewGoogleCalendar()
+		if err != nil {
+			return err
+		}
+		if google == nil {
+			return errors.New("please configure the OAuth client of a Google Cloud project as google.clientId and google.clientSecret")
+		}
+
+		err = google.Login(func(authURL string) {
+			fmt.Printf("%s open this URL in your browser to sign in:\n\n%s\n\n", CharInfo, authURL)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s logged in to %s\n", CharInfo, color.FgLightWhite.Render("Google Calendar"))
+		return nil
+	},
+}
+
+func init() {
+	calendarCmd.AddCommand(calendarLoginCmd)
+}
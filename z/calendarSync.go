@@ -0,0 +1,252 @@
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultCalendarPullDays is how far back events are pulled without --since.
+const defaultCalendarPullDays int = 30
+
+// CalendarProvider is a calendar service `zeit calendar sync` pushes
+// activities to as events and pulls events from into activities.
+type CalendarProvider interface {
+	Name() string
+	// CanPush returns whether a calendar to push activities to is configured.
+	CanPush() bool
+	// PullEntries returns the events between since and until as activities,
+	// except for the ones pulled before.
+	PullEntries(user string, since time.Time, until time.Time) ([]Entry, []error, error)
+	// RecordPulled remembers the activities the pulled events were imported
+	// as, by their SHA1 in sha1List.
+	RecordPulled(user string, entries []Entry, sha1List map[string]string) error
+	Push(user string, entries []Entry, dryRun bool, report func(Entry, string, error)) (CalendarSyncStats, error)
+}
+
+type CalendarSyncStats struct {
+	Pushed  int
+	Updated int
+	Deleted int
+	Skipped int
+}
+
+// GetCalendarProviders returns the calendars configured to sync with.
+func GetCalendarProviders() ([]CalendarProvider, error) {
+	var providers []CalendarProvider
+
+	caldav, err := NewCalDAV()
+	if err != nil {
+		return providers, err
+	}
+	if caldav != nil {
+		providers = append(providers, caldav)
+	}
+
+	google, err := NewGoogleCalendar()
+	if err != nil {
+		return providers, err
+	}
+	if google != nil {
+		providers = append(providers, google)
+	}
+
+	return providers, nil
+}
+
+// calendarPushed is the event an activity was pushed as, with a hash of the
+// event to tell whether the activity changed since.
+type calendarPushed struct {
+	Href string `json:"href"`
+	ETag string `json:"etag,omitempty"`
+	Hash string `json:"hash"`
+}
+
+// calendarState is what was synced with a calendar before: the events the
+// activities were pushed as and the activities events were pulled into, by
+// their SHA1.
+type calendarState struct {
+	Pushed map[string]calendarPushed `json:"pushed"`
+	Pulled map[string]string         `json:"pulled"`
+
+	// SyncToken continues pulling where the last sync left off, and Upcoming
+	// keeps the events that were not finished then, for providers that only
+	// return changed events.
+	SyncToken string           `json:"syncToken,omitempty"`
+	Upcoming  map[string]Entry `json:"upcoming,omitempty"`
+}
+
+// calendarStateFile holds the states of all users and calendars synced with
+// a provider, in `<provider>.state` or the data directory.
+type calendarStateFile struct {
+	Path   string
+	Key    string
+	States map[string]calendarState
+}
+
+func loadCalendarState(provider string, key string) (calendarState, *calendarStateFile, error) {
+	state := calendarState{Pushed: make(map[string]calendarPushed), Pulled: make(map[string]string), Upcoming: make(map[string]Entry)}
+	file := calendarStateFile{Path: viper.GetString(provider + ".state"), Key: key, States: make(map[string]calendarState)}
+
+	if file.Path == "" {
+		dataHome := GetDataHome()
+		if dataHome == "" {
+			return state, &file, fmt.Errorf("could not find the data directory, please configure %s.state", provider)
+		}
+		file.Path = filepath.Join(dataHome, "zeit", provider+".json")
+	}
+
+	content, err := os.ReadFile(file.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, &file, nil
+	} else if err != nil {
+		return state, &file, err
+	}
+	if err = json.Unmarshal(content, &file.States); err != nil {
+		return state, &file, fmt.Errorf("could not read the calendar sync state %s: %v", file.Path, err)
+	}
+
+	if saved, ok := file.States[key]; ok {
+		if saved.Pushed != nil {
+			state.Pushed = saved.Pushed
+		}
+		if saved.Pulled != nil {
+			state.Pulled = saved.Pulled
+		}
+		if saved.Upcoming != nil {
+			state.Upcoming = saved.Upcoming
+		}
+		state.SyncToken = saved.SyncToken
+	}
+	return state, &file, nil
+}
+
+func (file *calendarStateFile) Save(state calendarState) error {
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0700); err != nil {
+		return err
+	}
+
+	file.States[file.Key] = state
+	content, err := json.MarshalIndent(file.States, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(file.Path, content)
+}
+
+// recordPulled adds the activities the entries were imported as to the
+// pulled ones of the state.
+func recordPulled(state *calendarState, entries []Entry, sha1List map[string]string) {
+	for _, entry := range entries {
+		if id, ok := sha1List[entry.SHA1]; ok {
+			state.Pulled[entry.SHA1] = id
+		}
+	}
+}
+
+// calendarEvents are the events of a calendar activities are pushed to.
+type calendarEvents interface {
+	// event returns the event of the activity and its hash.
+	event(entry Entry) ([]byte, string, error)
+	// put creates the event, or replaces the event pushed before.
+	put(entry Entry, pushed calendarPushed, exists bool, event []byte) (calendarPushed, error)
+	delete(pushed calendarPushed) error
+}
+
+// pushCalendar puts the finished activities onto the calendar as events,
+// updating the ones that changed since they were pushed before, and deletes
+// the events of activities that were erased. Activities pulled from a
+// calendar are not pushed back.
+func pushCalendar(user string, events calendarEvents, state calendarState, file *calendarStateFile, entries []Entry, dryRun bool, report func(Entry, string, error)) (CalendarSyncStats, error) {
+	var stats CalendarSyncStats
+
+	save := func() error {
+		if dryRun {
+			return nil
+		}
+		return file.Save(state)
+	}
+
+	pulled := make(map[string]bool)
+	for _, id := range state.Pulled {
+		pulled[id] = true
+	}
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() || pulled[entry.ID] {
+			continue
+		}
+
+		event, hash, err := events.event(entry)
+		if err != nil {
+			return stats, err
+		}
+		pushed, exists := state.Pushed[entry.ID]
+		if exists && pushed.Hash == hash {
+			stats.Skipped++
+			continue
+		}
+
+		action := "pushed"
+		if exists {
+			action = "updated"
+		}
+
+		if !dryRun {
+			if pushed, err = events.put(entry, pushed, exists, event); err != nil {
+				report(entry, action, err)
+				continue
+			}
+			pushed.Hash = hash
+			state.Pushed[entry.ID] = pushed
+
+			// Save after every event, so that an interrupted sync does not
+			// push events twice
+			if err = save(); err != nil {
+				return stats, err
+			}
+		}
+
+		if exists {
+			stats.Updated++
+		} else {
+			stats.Pushed++
+		}
+		report(entry, action, nil)
+	}
+
+	// Activities no longer in the database were erased
+	tracked, err := database.ListEntries(user)
+	if err != nil {
+		return stats, err
+	}
+	exists := make(map[string]bool)
+	for _, entry := range tracked {
+		exists[entry.ID] = true
+	}
+	for id, pushed := range state.Pushed {
+		if exists[id] {
+			continue
+		}
+
+		if !dryRun {
+			if err = events.delete(pushed); err != nil {
+				report(Entry{ID: id}, "deleted", err)
+				continue
+			}
+			delete(state.Pushed, id)
+			if err = save(); err != nil {
+				return stats, err
+			}
+		}
+		stats.Deleted++
+		report(Entry{ID: id}, "deleted", nil)
+	}
+
+	return stats, nil
+}
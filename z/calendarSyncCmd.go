@@ -0,0 +1,162 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var calendarDryRun bool
+
+var calendarSyncCmd = &cobra.Command{
+	Use:   "sync ([flags])",
+	Short: "Sync activities with calendars",
+	Long:  "Push finished activities as events to the CalDAV calendar caldav.url and the Google calendar google.calendar, updating and deleting the events of changed and erased activities, and pull the events of caldav.pull.url and google.pull.calendar into activities. What was synced is remembered, so syncing repeatedly does not duplicate events or activities.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		providers, err := GetCalendarProviders()
+		if err != nil {
+			return err
+		}
+		if len(providers) == 0 {
+			return errors.New("please configure a calendar to sync with, see `zeit calendar sync --help`")
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+
+		pullSince, pullUntil := sinceTime, untilTime
+		if pullSince.IsZero() {
+			pullSince = time.Now().AddDate(0, 0, -defaultCalendarPullDays)
+		}
+		if pullUntil.IsZero() || pullUntil.After(time.Now()) {
+			pullUntil = time.Now()
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		rules, err := GetRedactionRules("calendar", redact)
+		if err != nil {
+			return err
+		}
+		entries = RedactEntries(entries, rules)
+
+		for _, provider := range providers {
+			if err := calendarPull(user, provider, pullSince, pullUntil); err != nil {
+				return err
+			}
+			if err := calendarPush(user, provider, entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// calendarPull imports the events pulled from the calendar.
+func calendarPull(user string, provider CalendarProvider, since time.Time, until time.Time) error {
+	pulled, eventErrors, err := provider.PullEntries(user, since, until)
+	for _, eventErr := range eventErrors {
+		fmt.Printf("%s %s: %+v\n", CharError, provider.Name(), eventErr)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", provider.Name(), err)
+	}
+
+	sha1List, err := database.GetImportsSHA1List(user)
+	if err != nil {
+		return err
+	}
+
+	if len(pulled) > 0 {
+		plan, err := PlanImport(user, pulled, sha1List, ImportDuplicateSkip)
+		if err != nil {
+			return err
+		}
+
+		if calendarDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "calendar sync"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if calendarDryRun {
+		return nil
+	}
+	if err = provider.RecordPulled(user, pulled, sha1List); err != nil {
+		return fmt.Errorf("%s: %v", provider.Name(), err)
+	}
+	return nil
+}
+
+// calendarPush pushes the activities to the calendar.
+func calendarPush(user string, provider CalendarProvider, entries []Entry) error {
+	if !provider.CanPush() {
+		return nil
+	}
+
+	stats, err := provider.Push(user, entries, calendarDryRun, func(entry Entry, action string, err error) {
+		if err != nil {
+			fmt.Printf("%s %s could not be %s: %+v\n", CharError, color.FgLightWhite.Render(entry.ID), action, color.FgRed.Render(err))
+			return
+		}
+		if calendarDryRun {
+			action = "would be " + action
+		}
+		if entry.Finish.IsZero() {
+			fmt.Printf("%s %s %s\n", CharMore, color.FgLightWhite.Render(entry.ID), action)
+			return
+		}
+		fmt.Printf("%s %s %s\n", CharMore, action, GetEntryOutput(entry, false))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", provider.Name(), err)
+	}
+
+	summary := fmt.Sprintf("pushed %d, updated %d and deleted %d events, %d were pushed before", stats.Pushed, stats.Updated, stats.Deleted, stats.Skipped)
+	if calendarDryRun {
+		summary = "dry run: would have " + summary
+	}
+	fmt.Printf("%s %s: %s\n", CharInfo, provider.Name(), summary)
+	return nil
+}
+
+func init() {
+	calendarCmd.AddCommand(calendarSyncCmd)
+	calendarSyncCmd.Flags().StringVar(&since, "since", "", "Date/time to sync from (default for pulling events is 30 days ago)")
+	calendarSyncCmd.Flags().StringVar(&until, "until", "", "Date/time to sync until")
+	calendarSyncCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	calendarSyncCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be pushed")
+	calendarSyncCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be pushed")
+	calendarSyncCmd.Flags().StringSliceVar(&redact, "redact", []string{}, "Redact notes instead of using redaction.calendar, possible values: "+strings.Join(RedactionRules(), ", "))
+	calendarSyncCmd.Flags().BoolVar(&calendarDryRun, "dry-run", false, "Only show what would be pushed and pulled")
+}
@@ -0,0 +1,312 @@
+package z
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+const defaultClockifyURL string = "https://api.clockify.me"
+
+const clockifyPageSize int = 200
+
+var (
+	clockifyDateLayouts = []string{"01/02/2006", "2006-01-02", "02.01.2006", "02/01/2006"}
+	clockifyTimeLayouts = []string{"03:04:05 PM", "15:04:05", "03:04 PM", "15:04"}
+)
+
+// ClockifyMapping maps the clients, projects and tags of Clockify to zeit.
+// Projects without a mapping of their own use the one of their client, if
+// any, and otherwise their Clockify name.
+type ClockifyMapping struct {
+	Clients  map[string]string
+	Projects map[string]string
+	Tags     map[string]string
+}
+
+type clockifyTimeEntry struct {
+	Client      string
+	Project     string
+	Task        string
+	Description string
+	Tags        []string
+	Start       time.Time
+	End         time.Time
+}
+
+func NewClockifyMapping(clientMappings []string, projectMappings []string, tagMappings []string) (ClockifyMapping, error) {
+	var mapping ClockifyMapping
+	var err error
+
+	if mapping.Clients, err = importMapping("clockify.clients", clientMappings); err != nil {
+		return mapping, err
+	}
+	if mapping.Projects, err = importMapping("clockify.projects", projectMappings); err != nil {
+		return mapping, err
+	}
+	if mapping.Tags, err = importMapping("clockify.tags", tagMappings); err != nil {
+		return mapping, err
+	}
+	return mapping, nil
+}
+
+// entry converts a time entry. The Clockify task is used as task with the
+// description as notes, or else the description is the task.
+func (mapping *ClockifyMapping) entry(user string, timeEntry clockifyTimeEntry) Entry {
+	project := timeEntry.Project
+	if mapped, ok := mapping.Projects[strings.ToLower(project)]; ok {
+		project = mapped
+	} else if mapped, ok := mapping.Clients[strings.ToLower(timeEntry.Client)]; ok && timeEntry.Client != "" {
+		project = mapped
+	}
+
+	var tags []string
+	for _, tag := range timeEntry.Tags {
+		if mapped, ok := mapping.Tags[strings.ToLower(strings.TrimSpace(tag))]; ok {
+			tag = mapped
+		}
+		tags = append(tags, tag)
+	}
+
+	entry := Entry{
+		Begin:   timeEntry.Start,
+		Finish:  timeEntry.End,
+		Project: project,
+		Task:    timeEntry.Description,
+		User:    user,
+		Tags:    zeit.ParseTags(tags),
+	}
+	if timeEntry.Task != "" {
+		entry.Task = timeEntry.Task
+		entry.Notes = timeEntry.Description
+	}
+
+	// Reports and the API identify time entries differently, so both are
+	// deduplicated by the minute they started at and description
+	sum := sha1.Sum([]byte("clockify\x1f" + timeEntry.Start.UTC().Truncate(time.Minute).Format(time.RFC3339) + "\x1f" + timeEntry.Description))
+	entry.SHA1 = fmt.Sprintf("%x", sum)
+	return entry
+}
+
+func parseClockifyTime(date string, clock string, location *time.Location) (time.Time, error) {
+	for _, dateLayout := range clockifyDateLayouts {
+		for _, timeLayout := range clockifyTimeLayouts {
+			if t, err := time.ParseInLocation(dateLayout+" "+timeLayout, date+" "+clock, location); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not read '%s %s'", date, clock)
+}
+
+// ImportClockifyCSV reads a detailed report exported from Clockify as CSV.
+// Rows that can't be read don't stop the import but are returned as errors.
+func ImportClockifyCSV(user string, file string, mapping ClockifyMapping, location *time.Location) ([]Entry, []error, error) {
+	var entries []Entry
+	var rowErrors []error
+
+	f, err := os.Open(file)
+	if err != nil {
+		return entries, rowErrors, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return entries, rowErrors, fmt.Errorf("could not read the header row: %v", err)
+	}
+	columns := make(map[string]int)
+	for idx, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = idx
+	}
+	for _, required := range []string{"start date", "start time", "end date", "end time", "description"} {
+		if _, ok := columns[required]; !ok {
+			return entries, rowErrors, fmt.Errorf("not a Clockify detailed report: column '%s' missing", required)
+		}
+	}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: err})
+			continue
+		}
+
+		value := func(column string) string {
+			if idx, ok := columns[column]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		timeEntry := clockifyTimeEntry{
+			Client:      value("client"),
+			Project:     value("project"),
+			Task:        value("task"),
+			Description: value("description"),
+		}
+		if tags := value("tags"); tags != "" {
+			timeEntry.Tags = strings.Split(tags, ",")
+		}
+
+		if timeEntry.Start, err = parseClockifyTime(value("start date"), value("start time"), location); err != nil {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: err})
+			continue
+		}
+		if timeEntry.End, err = parseClockifyTime(value("end date"), value("end time"), location); err != nil {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: err})
+			continue
+		}
+
+		entry := mapping.entry(user, timeEntry)
+		if !entry.IsFinishedAfterBegan() {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: NewFinishBeforeBeginError(entry)})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rowErrors, nil
+}
+
+type Clockify struct {
+	URL       string
+	Token     string
+	Workspace string
+}
+
+func NewClockify(workspace string) (*Clockify, error) {
+	clockify := Clockify{
+		URL:       strings.TrimSuffix(viper.GetString("clockify.url"), "/"),
+		Token:     os.ExpandEnv(viper.GetString("clockify.token")),
+		Workspace: workspace,
+	}
+	if clockify.URL == "" {
+		clockify.URL = defaultClockifyURL
+	}
+	if clockify.Token == "" {
+		return nil, errors.New("please configure the API key of Clockify as clockify.token")
+	}
+	if clockify.Workspace == "" {
+		clockify.Workspace = viper.GetString("clockify.workspace")
+	}
+	return &clockify, nil
+}
+
+func (clockify *Clockify) request(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, clockify.URL+"/api/v1"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", clockify.Token)
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("GET %s: %s", req.URL.Redacted(), res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// TimeEntries fetches the finished time entries of the user of the API key
+// between from and to.
+func (clockify *Clockify) TimeEntries(user string, from time.Time, to time.Time, mapping ClockifyMapping) ([]Entry, error) {
+	var entries []Entry
+
+	var me struct {
+		ID               string `json:"id"`
+		DefaultWorkspace string `json:"defaultWorkspace"`
+		ActiveWorkspace  string `json:"activeWorkspace"`
+	}
+	if err := clockify.request("/user", &me); err != nil {
+		return entries, err
+	}
+	workspace := clockify.Workspace
+	if workspace == "" {
+		workspace = me.ActiveWorkspace
+	}
+	if workspace == "" {
+		workspace = me.DefaultWorkspace
+	}
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("start", from.UTC().Format("2006-01-02T15:04:05Z"))
+		query.Set("end", to.UTC().Format("2006-01-02T15:04:05Z"))
+		query.Set("hydrated", "true")
+		query.Set("page", strconv.Itoa(page))
+		query.Set("page-size", strconv.Itoa(clockifyPageSize))
+
+		var timeEntries []struct {
+			Description string `json:"description"`
+			Project     *struct {
+				Name       string `json:"name"`
+				ClientName string `json:"clientName"`
+			} `json:"project"`
+			Task *struct {
+				Name string `json:"name"`
+			} `json:"task"`
+			Tags []struct {
+				Name string `json:"name"`
+			} `json:"tags"`
+			TimeInterval struct {
+				Start time.Time  `json:"start"`
+				End   *time.Time `json:"end"`
+			} `json:"timeInterval"`
+		}
+		if err := clockify.request(fmt.Sprintf("/workspaces/%s/user/%s/time-entries?%s", url.PathEscape(workspace), url.PathEscape(me.ID), query.Encode()), &timeEntries); err != nil {
+			return entries, err
+		}
+
+		for _, te := range timeEntries {
+			// Running time entries have no end yet
+			if te.TimeInterval.End == nil {
+				continue
+			}
+			timeEntry := clockifyTimeEntry{
+				Description: te.Description,
+				Start:       te.TimeInterval.Start.Local(),
+				End:         te.TimeInterval.End.Local(),
+			}
+			if te.Project != nil {
+				timeEntry.Project = te.Project.Name
+				timeEntry.Client = te.Project.ClientName
+			}
+			if te.Task != nil {
+				timeEntry.Task = te.Task.Name
+			}
+			for _, tag := range te.Tags {
+				timeEntry.Tags = append(timeEntry.Tags, tag.Name)
+			}
+			entries = append(entries, mapping.entry(user, timeEntry))
+		}
+
+		if len(timeEntries) < clockifyPageSize {
+			return entries, nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type ComparisonPeriod struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type ComparisonTotal struct {
+	Project         string   `json:"project,omitempty"`
+	CurrentSeconds  int64    `json:"currentSeconds"`
+	PreviousSeconds int64    `json:"previousSeconds"`
+	DeltaSeconds    int64    `json:"deltaSeconds"`
+	DeltaPercent    *float64 `json:"deltaPercent"`
+}
+
+// setDelta rounds both periods to the minutes shown and derives the delta
+// from the rounded values, so that it matches their difference.
+func (total *ComparisonTotal) setDelta() {
+	total.CurrentSeconds = roundSecondsToMinute(total.CurrentSeconds)
+	total.PreviousSeconds = roundSecondsToMinute(total.PreviousSeconds)
+
+	total.DeltaSeconds = total.CurrentSeconds - total.PreviousSeconds
+	if total.PreviousSeconds > 0 {
+		percent := float64(total.DeltaSeconds) / float64(total.PreviousSeconds) * 100
+		total.DeltaPercent = &percent
+	}
+}
+
+func roundSecondsToMinute(seconds int64) int64 {
+	return int64((time.Duration(seconds) * time.Second).Round(time.Minute).Seconds())
+}
+
+// Comparison is the time tracked per project in a period compared to the
+// time tracked in a previous one.
+type Comparison struct {
+	Current  ComparisonPeriod  `json:"current"`
+	Previous ComparisonPeriod  `json:"previous"`
+	Projects []ComparisonTotal `json:"projects"`
+	Total    ComparisonTotal   `json:"total"`
+}
+
+// ParseComparisonRange reads a range like lastMonth or last-quarter, or two
+// dates like 2024-01-01..2024-03-31, the last one included.
+func ParseComparisonRange(value string) (time.Time, time.Time, error) {
+	from, to, found := strings.Cut(value, "..")
+	if !found {
+		return ParseTimeRange("", "", value)
+	}
+
+	sinceTime, err := now.Parse(strings.TrimSpace(from))
+	if err != nil {
+		return sinceTime, time.Time{}, fmt.Errorf("invalid range '%s', use e.g. lastMonth or 2024-01-01..2024-03-31", value)
+	}
+	untilTime, err := now.Parse(strings.TrimSpace(to))
+	if err != nil {
+		return sinceTime, untilTime, fmt.Errorf("invalid range '%s', use e.g. lastMonth or 2024-01-01..2024-03-31", value)
+	}
+	if !strings.Contains(to, ":") {
+		untilTime = now.With(untilTime).EndOfDay()
+	}
+	if !untilTime.After(sinceTime) {
+		return sinceTime, untilTime, fmt.Errorf("range '%s' ends before it begins", value)
+	}
+	return sinceTime, untilTime, nil
+}
+
+// CurrentComparisonRange returns the range a previous one like lastMonth is
+// compared to, like thisMonth.
+func CurrentComparisonRange(previous string) (string, bool) {
+	normalized := strings.ReplaceAll(strings.ToLower(previous), "-", "")
+	if normalized == "yesterday" {
+		return "today", true
+	}
+	if period, found := strings.CutPrefix(normalized, "last"); found {
+		return "this" + period, true
+	}
+	return "", false
+}
+
+// NewComparison sums up the time tracked per project in both periods.
+func NewComparison(user string, current ComparisonPeriod, previous ComparisonPeriod) (Comparison, error) {
+	comparison := Comparison{Current: current, Previous: previous}
+
+	totals := make(map[string]*ComparisonTotal)
+	var keys []string
+	for idx, period := range []ComparisonPeriod{current, previous} {
+		entries, err := database.ListEntriesBetween(user, period.Since, period.Until)
+		if err != nil {
+			return comparison, err
+		}
+
+		for _, entry := range entries {
+			key := zeit.GetIdFromName(entry.Project)
+			total, ok := totals[key]
+			if !ok {
+				total = &ComparisonTotal{Project: entry.Project}
+				totals[key] = total
+				keys = append(keys, key)
+			}
+
+			seconds := int64(zeit.ClippedDuration(entry, period.Since, period.Until).Seconds())
+			if idx == 0 {
+				total.CurrentSeconds += seconds
+			} else {
+				total.PreviousSeconds += seconds
+			}
+		}
+	}
+
+	comparison.Projects = []ComparisonTotal{}
+	for _, key := range keys {
+		total := totals[key]
+		total.setDelta()
+		comparison.Projects = append(comparison.Projects, *total)
+
+		// The total adds up the rounded projects, as shown
+		comparison.Total.CurrentSeconds += total.CurrentSeconds
+		comparison.Total.PreviousSeconds += total.PreviousSeconds
+	}
+	comparison.Total.setDelta()
+
+	sort.Slice(comparison.Projects, func(i, j int) bool {
+		return strings.ToLower(comparison.Projects[i].Project) < strings.ToLower(comparison.Projects[j].Project)
+	})
+	return comparison, nil
+}
@@ -0,0 +1,92 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// completionEntries is how many of the most recent entries are offered when
+// completing entry IDs.
+const completionEntries int = 20
+
+// completeProjects completes the projects used so far, the most recently
+// used first.
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	entries, err := database.ListEntries(GetCurrentUser())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	projects, _ := GetRecentProjectsAndTasks(entries)
+	return projects, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTasks completes the tasks used so far, the most recently used
+// first, and only the ones of the project if --project was given already,
+// followed by the tasks imported from assigned issues.
+func completeTasks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	user := GetCurrentUser()
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	projects, projectTasks := GetRecentProjectsAndTasks(entries)
+	var project string
+	if flag := cmd.Flags().Lookup("project"); flag != nil && flag.Changed {
+		project = flag.Value.String()
+		projects = []string{project}
+	} else {
+		projects = append(projects, "")
+	}
+
+	var tasks []string
+	seen := make(map[string]bool)
+	for _, project := range projects {
+		for _, task := range projectTasks[project] {
+			if !seen[task] {
+				seen[task] = true
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	for _, task := range GetIssueTasks(user, project) {
+		if !seen[task] {
+			seen[task] = true
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeEntryIDs completes the IDs of the most recent entries, described
+// by when and for how long they were tracked and their task and project.
+func completeEntryIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	entries, err := ListLatestEntries(GetCurrentUser(), time.Time{}, time.Time{}, func(Entry) bool { return true }, 0, completionEntries)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		duration := fmtDuration(entry.End().Sub(entry.Begin)) + "h"
+		if entry.Finish.IsZero() {
+			duration += ", running"
+		}
+		ids = append(ids, fmt.Sprintf("%s\t%s %s (%s) %s on %s",
+			entry.ID,
+			entry.Begin.Format("Mon 01-02"),
+			entry.Begin.Format("15:04"),
+			duration,
+			entry.Task,
+			entry.Project,
+		))
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
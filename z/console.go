@@ -0,0 +1,439 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// Console is the bubbletea model of the interactive terminal UI of
+// `zeit ui`. Like Follow, it only holds the database while reading or
+// changing it, so other zeit processes can be used alongside.
+type Console struct {
+	user     string
+	targets  Targets
+	entries  []Entry
+	running  *Entry
+	week     WeekSheet
+	selected int
+	message  string
+	height   int
+	prompt   *consolePrompt
+}
+
+// consolePrompt reads a line for each of its labels below the console and
+// passes them on to done once all were entered.
+type consolePrompt struct {
+	labels []string
+	values []string
+	input  string
+	done   func(values []string) error
+}
+
+type consoleTickMsg time.Time
+
+// consoleEditedMsg is sent once an entry was edited.
+type consoleEditedMsg struct {
+	err error
+}
+
+// consoleEditor runs the editor for an entry and stores it while the console
+// has handed over the terminal, which resolving overlaps may prompt on.
+type consoleEditor struct {
+	console *Console
+	entry   Entry
+}
+
+func (editor *consoleEditor) Run() error {
+	modifiedEntry, err := editInEditor(NewEditableEntry(editor.entry))
+	if err != nil {
+		return err
+	}
+	return editor.console.update(editor.entry, modifiedEntry)
+}
+
+// The editor and prompts use the standard streams
+func (editor *consoleEditor) SetStdin(io.Reader)  {}
+func (editor *consoleEditor) SetStdout(io.Writer) {}
+func (editor *consoleEditor) SetStderr(io.Writer) {}
+
+func NewConsole(user string, targets Targets) *Console {
+	return &Console{
+		user:    user,
+		targets: targets,
+	}
+}
+
+// Run shows the console until it is quit.
+func (console *Console) Run() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("zeit ui requires an interactive terminal")
+	}
+
+	closeDatabase()
+
+	_, err := tea.NewProgram(console, tea.WithAltScreen()).Run()
+	return err
+}
+
+func consoleTick() tea.Cmd {
+	return tea.Tick(followInterval, func(t time.Time) tea.Msg {
+		return consoleTickMsg(t)
+	})
+}
+
+func (console *Console) Init() tea.Cmd {
+	console.refresh()
+	return consoleTick()
+}
+
+func (console *Console) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		console.height = msg.Height
+	case consoleTickMsg:
+		cmd = consoleTick()
+	case consoleEditedMsg:
+		console.showError(msg.err)
+	case tea.KeyMsg:
+		if console.prompt != nil {
+			console.handlePrompt(msg)
+		} else {
+			cmd = console.handle(msg)
+		}
+	}
+
+	console.refresh()
+	return console, cmd
+}
+
+func (console *Console) View() string {
+	output := console.GetOutput()
+	if prompt := console.prompt; prompt != nil {
+		output += fmt.Sprintf(" %s: %s_\n", prompt.labels[len(prompt.values)], prompt.input)
+	}
+	return output
+}
+
+func (console *Console) showError(err error) {
+	if err != nil {
+		console.message = fmt.Sprintf("%s %+v", CharError, err)
+	}
+}
+
+// handle reacts to a key outside of prompts.
+func (console *Console) handle(msg tea.KeyMsg) tea.Cmd {
+	var err error
+
+	switch msg.String() {
+	case "q", "ctrl+c", "ctrl+d":
+		return tea.Quit
+	case "j", "down":
+		console.selected = min(console.selected+1, max(len(console.entries)-1, 0))
+	case "k", "up":
+		console.selected = max(console.selected-1, 0)
+	case "s":
+		console.ask(func(values []string) error {
+			return console.start(values[0], values[1], values[2])
+		}, "project", "task", "notes")
+	case "f":
+		err = console.finish()
+	case "r":
+		if entry, ok := console.selectedEntry(); ok {
+			err = console.start(entry.Project, entry.Task, "")
+		}
+	case "e":
+		if entry, ok := console.selectedEntry(); ok {
+			return tea.Exec(&consoleEditor{console: console, entry: entry}, func(err error) tea.Msg {
+				return consoleEditedMsg{err: err}
+			})
+		}
+	case "x":
+		if entry, ok := console.selectedEntry(); ok {
+			console.ask(func(values []string) error {
+				if strings.ToLower(values[0]) != "y" {
+					return nil
+				}
+				return console.erase(entry)
+			}, fmt.Sprintf("erase %s on %s? [y/N]", entry.Task, entry.Project))
+		}
+	}
+
+	console.showError(err)
+	return nil
+}
+
+// ask prompts for a line per label and passes them on to done.
+func (console *Console) ask(done func(values []string) error, labels ...string) {
+	console.prompt = &consolePrompt{labels: labels, done: done}
+}
+
+// handlePrompt edits the line of the prompt; enter moves on to the next
+// label, escape cancels the prompt.
+func (console *Console) handlePrompt(msg tea.KeyMsg) {
+	prompt := console.prompt
+
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		console.prompt = nil
+	case tea.KeyEnter:
+		prompt.values = append(prompt.values, strings.TrimSpace(prompt.input))
+		prompt.input = ""
+		if len(prompt.values) == len(prompt.labels) {
+			console.prompt = nil
+			console.showError(prompt.done(prompt.values))
+		}
+	case tea.KeyBackspace:
+		if runes := []rune(prompt.input); len(runes) > 0 {
+			prompt.input = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		prompt.input += " "
+	case tea.KeyRunes:
+		prompt.input += string(msg.Runes)
+	}
+}
+
+func (console *Console) selectedEntry() (Entry, bool) {
+	if console.selected < 0 || console.selected >= len(console.entries) {
+		return Entry{}, false
+	}
+	return console.entries[console.selected], true
+}
+
+// modify opens the database for writing, runs fn and closes it again.
+func (console *Console) modify(fn func() error) error {
+	closeStorage, err := OpenStorage(true, true)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	return fn()
+}
+
+func (console *Console) start(project string, task string, notes string) error {
+	return console.modify(func() error {
+		runningEntryId, err := database.GetRunningEntryId(console.user)
+		if err != nil {
+			return err
+		}
+		if runningEntryId != "" {
+			return errors.New("a task is already running")
+		}
+
+		if project == "" && viper.GetString("project.default") != "" {
+			project = viper.GetString("project.default")
+		}
+		if project == "" && viper.GetBool("project.mandatory") {
+			return NewMandatoryError("project")
+		}
+		if task == "" && viper.GetBool("task.mandatory") {
+			return NewMandatoryError("task")
+		}
+
+		entry, err := NewEntry("", "", "", project, task, console.user)
+		if err != nil {
+			return err
+		}
+		entry.Notes = notes
+
+		if err = ValidateProjectRules(console.user, entry); err != nil {
+			return err
+		}
+		if err = RunPreHooks(HookPreTrack, entry); err != nil {
+			return err
+		}
+		if entry.ID, err = database.AddEntry(console.user, entry, true); err != nil {
+			return err
+		}
+		RunPostHooks(HookPostTrack, entry)
+
+		console.message = strings.TrimSpace(GetEntryOutputForTrack(entry, true, false))
+		console.selected = 0
+		return nil
+	})
+}
+
+func (console *Console) finish() error {
+	return console.modify(func() error {
+		runningEntryId, err := database.GetRunningEntryId(console.user)
+		if err != nil {
+			return err
+		}
+		if runningEntryId == "" {
+			return errors.New("not running")
+		}
+
+		entry, err := database.GetEntry(console.user, runningEntryId)
+		if err != nil {
+			return err
+		}
+		entry.Finish = time.Now()
+
+		if !entry.IsFinishedAfterBegan() {
+			return NewFinishBeforeBeginError(entry)
+		}
+		if err = ValidateProjectRules(console.user, entry); err != nil {
+			return err
+		}
+		if err = RunPreHooks(HookPreFinish, entry); err != nil {
+			return err
+		}
+		if _, err = database.FinishEntry(console.user, entry); err != nil {
+			return err
+		}
+		RunPostHooks(HookPostFinish, entry)
+
+		console.message = strings.TrimSpace(GetEntryOutputForFinish(entry))
+		return nil
+	})
+}
+
+// update stores the entry as it was changed in the editor.
+func (console *Console) update(entry Entry, modifiedEntry EditableEntry) error {
+	return console.modify(func() error {
+		if err := validateAndUpdateEntry(console.user, entry.ID, modifiedEntry); err != nil {
+			return err
+		}
+		console.message = fmt.Sprintf("%s updated %s", CharInfo, color.FgLightWhite.Render(entry.ID))
+		return nil
+	})
+}
+
+func (console *Console) erase(entry Entry) error {
+	return console.modify(func() error {
+		if err := ValidateEditWindow(entry); err != nil {
+			return err
+		}
+		if viper.GetBool("backup.auto") {
+			if err := autoBackup(console.user, "erase"); err != nil {
+				return fmt.Errorf("automatic backup before erase failed: %v", err)
+			}
+		}
+		if err := database.EraseEntry(console.user, entry.ID); err != nil {
+			return err
+		}
+
+		console.message = fmt.Sprintf("%s erased %s", CharInfo, color.FgLightWhite.Render(entry.ID))
+		return nil
+	})
+}
+
+// refresh reads the recent entries, the running one and the current week,
+// as many entries as fit the terminal.
+func (console *Console) refresh() {
+	rows := console.height
+	if rows == 0 {
+		rows = 24
+	}
+
+	if err := console.load(max(rows-16, 3)); err != nil {
+		console.message = fmt.Sprintf("%s %+v", CharError, err)
+	}
+	console.selected = min(console.selected, max(len(console.entries)-1, 0))
+}
+
+func (console *Console) load(limit int) error {
+	closeStorage, err := OpenStorage(false, true)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	if console.entries, err = ListLatestEntries(console.user, time.Time{}, time.Time{}, func(Entry) bool { return true }, 0, limit); err != nil {
+		return err
+	}
+
+	console.running = nil
+	for idx := range console.entries {
+		if console.entries[idx].Finish.IsZero() {
+			console.running = &console.entries[idx]
+		}
+	}
+
+	if IsFirstWeekDayMonday() {
+		now.WeekStartDay = time.Monday
+	}
+	console.week, err = NewWeekSheet(console.user, now.Monday(), console.targets)
+	return err
+}
+
+// GetOutput renders the running entry, the recent entries with the selected
+// one marked, the week summary and the keybindings.
+func (console *Console) GetOutput() string {
+	var output strings.Builder
+
+	fmt.Fprintf(&output, "\n %s %s\n\n", color.FgLightWhite.Render("zeit"), time.Now().Format("Mon 2006-01-02 15:04:05"))
+
+	if console.running != nil {
+		elapsed := time.Since(console.running.Begin).Truncate(time.Second)
+		fmt.Fprintf(&output, "%s %s on %s for %s\n",
+			CharTrack,
+			color.FgLightWhite.Render(console.running.Task),
+			color.FgLightWhite.Render(console.running.Project),
+			color.FgLightGreen.Render(fmtClock(elapsed)),
+		)
+	} else {
+		fmt.Fprintf(&output, "%s not running\n", CharFinish)
+	}
+
+	output.WriteString("\n RECENT\n")
+	if len(console.entries) == 0 {
+		output.WriteString("   no activities yet\n")
+	}
+	for idx, entry := range console.entries {
+		finish := "now  "
+		if !entry.Finish.IsZero() {
+			finish = entry.Finish.Format("15:04")
+		}
+		line := fmt.Sprintf("%s %s - %s %8sh  %s on %s",
+			entry.Begin.Format("Mon 01-02"),
+			entry.Begin.Format("15:04"),
+			finish,
+			fmtDuration(entry.End().Sub(entry.Begin)),
+			entry.Task,
+			entry.Project,
+		)
+		if idx == console.selected {
+			fmt.Fprintf(&output, " > %s\n", color.FgBlack.Render(color.BgLightWhite.Render(line)))
+		} else {
+			fmt.Fprintf(&output, "   %s\n", line)
+		}
+	}
+
+	fmt.Fprintf(&output, "\n WEEK %d-W%02d\n  ", console.week.Year, console.week.Week)
+	for _, day := range console.week.Days {
+		date, _ := time.ParseInLocation(DateFormat, day.Date, time.Local)
+		fmt.Fprintf(&output, " %s %6s", date.Format("Mon"), fmtDuration(time.Duration(day.TotalSeconds)*time.Second))
+	}
+	fmt.Fprintf(&output, "\n   total %sh", color.FgLightWhite.Render(fmtDuration(time.Duration(console.week.TotalSeconds)*time.Second)))
+	if console.targets.IsSet() {
+		fmt.Fprintf(&output, " of %sh (%s)",
+			fmtDuration(time.Duration(console.week.TargetSeconds)*time.Second),
+			fmtDelta(time.Duration(console.week.DeltaSeconds)*time.Second))
+	}
+	output.WriteString("\n\n")
+
+	fmt.Fprintf(&output, " %s\n\n", color.FgGray.Render("s start  f finish  r resume  e edit  x erase  j/k move  q quit"))
+	if console.message != "" {
+		fmt.Fprintf(&output, "%s\n", console.message)
+	}
+
+	return output.String()
+}
+
+func fmtClock(duration time.Duration) string {
+	return fmt.Sprintf("%d:%02d:%02d", int(duration.Hours()), int(duration.Minutes())%60, int(duration.Seconds())%60)
+}
@@ -3,13 +3,84 @@ package z
 const (
 	FlagNoColors string = "no-colors"
 	FlagDebug    string = "debug"
+	FlagOutput   string = "output"
+	FlagWait     string = "wait"
 )
 
 const (
-	TFAbsTwelveHour     int = 0
-	TFAbsTwentyfourHour int = 1
-	TFRelHourMinute     int = 2
-	TFRelHourFraction   int = 3
+	IssueProviderGitHub string = "github"
+	IssueProviderGitLab string = "gitlab"
+)
+
+const (
+	IssuePushComment string = "comment"
+	IssuePushSpent   string = "spent"
+)
+
+const (
+	SyncTargetZeit   string = "zeit"
+	SyncTargetWebDAV string = "webdav"
+	SyncTargetS3     string = "s3"
+)
+
+const (
+	StatsGroupByTag       string = "tag"
+	StatsGroupByReference string = "reference"
+	StatsGroupByHour      string = "hour"
+	StatsGroupByWeekday   string = "weekday"
+)
+
+const (
+	OverlapAsk    string = "ask"
+	OverlapReject string = "reject"
+	OverlapAllow  string = "allow"
+)
+
+const (
+	ImportActionCreate       string = "create"
+	ImportDuplicateSkip      string = "skip"
+	ImportDuplicateOverwrite string = "overwrite"
+	ImportDuplicateMerge     string = "merge"
+	ImportDuplicateFail      string = "fail"
+)
+
+const (
+	AnnotationReadOnly   string = "readonly"
+	AnnotationNoDatabase string = "nodatabase"
+	// AnnotationWritesWith names the flag with which a read-only command
+	// writes to the database after all
+	AnnotationWritesWith string = "writeswith"
+	// AnnotationNoDatabaseWith names the flag with which a command opens the
+	// database itself, if at all
+	AnnotationNoDatabaseWith string = "nodatabasewith"
+)
+
+const (
+	OutputText string = "text"
+	OutputJSON string = "json"
+)
+
+// Exit codes, so that scripts can tell why a command failed
+const (
+	ExitError      int = 1
+	ExitUsage      int = 2
+	ExitNotFound   int = 3
+	ExitValidation int = 4
+	ExitOverlap    int = 5
+	ExitStorage    int = 6
+)
+
+const (
+	ValidationInvalidTime       string = "invalid-time"
+	ValidationFinishBeforeBegin string = "finish-before-begin"
+	ValidationProjectMandatory  string = "project-mandatory"
+	ValidationTaskMandatory     string = "task-mandatory"
+	ValidationOverlap           string = "overlap"
+	ValidationRuleViolation     string = "rule-violation"
+	ValidationStrictViolation   string = "strict-violation"
+	ValidationEditLocked        string = "edit-locked"
+	ValidationSimilarName       string = "similar-name"
+	ValidationScriptViolation   string = "script-violation"
 )
 
 const (
@@ -18,3 +89,145 @@ const (
 )
 
 const DateFormat string = "2006-01-02"
+
+const (
+	EncryptionPassphrase string = "passphrase"
+	EncryptionAge        string = "age"
+	EncryptionGPG        string = "gpg"
+)
+
+const (
+	RestoreMerge   string = "merge"
+	RestoreReplace string = "replace"
+)
+
+const (
+	DoctorCorruptRecord     string = "corrupt-record"
+	DoctorInvalidTimestamp  string = "invalid-timestamp"
+	DoctorFinishBeforeBegin string = "finish-before-begin"
+	DoctorDuplicateID       string = "duplicate-id"
+	DoctorDuplicateEntry    string = "duplicate-entry"
+	DoctorOrphanedReference string = "orphaned-reference"
+)
+
+const (
+	BudgetDay   string = "day"
+	BudgetWeek  string = "week"
+	BudgetMonth string = "month"
+)
+
+// ProjectBudgetWarning is the share of a project budget from which on
+// tracking on the project warns.
+const ProjectBudgetWarning float64 = 0.8
+
+const (
+	RedactNotes     string = "notes"
+	RedactFirstLine string = "first-line"
+	RedactURLs      string = "urls"
+)
+
+const (
+	DurationDecimal string = "decimal"
+	DurationClock   string = "hh:mm"
+)
+
+const (
+	ArchiveZip       string = "zip"
+	ArchiveSignedZip string = "signed-zip"
+)
+
+const (
+	XLSXColumnsProject string = "project"
+	XLSXColumnsTask    string = "task"
+)
+
+const (
+	RoundUp      string = "up"
+	RoundNearest string = "nearest"
+	RoundDown    string = "down"
+)
+
+const (
+	RoundPerEntry string = "entry"
+	RoundPerDay   string = "day"
+)
+
+const (
+	RoundInStats   string = "stats"
+	RoundInExport  string = "export"
+	RoundInInvoice string = "invoice"
+)
+
+const (
+	InvoicePDF  string = "pdf"
+	InvoiceHTML string = "html"
+)
+
+const (
+	ReportFormatText     string = "text"
+	ReportFormatMarkdown string = "markdown"
+	ReportFormatHTML     string = "html"
+)
+
+const (
+	ReportGroupByDay     string = "day"
+	ReportGroupByProject string = "project"
+)
+
+const (
+	ReportScheduleDaily   string = "daily"
+	ReportScheduleWeekly  string = "weekly"
+	ReportScheduleMonthly string = "monthly"
+)
+
+const (
+	EmailSecurityStartTLS string = "starttls"
+	EmailSecurityTLS      string = "tls"
+	EmailSecurityNone     string = "none"
+)
+
+const (
+	ListGroupByDay     string = "day"
+	ListGroupByWeek    string = "week"
+	ListGroupByProject string = "project"
+	ListGroupByTask    string = "task"
+	ListGroupByClient  string = "client"
+)
+
+const (
+	SearchFieldNotes   string = "notes"
+	SearchFieldTask    string = "task"
+	SearchFieldProject string = "project"
+)
+
+const (
+	StatusbarText     string = "text"
+	StatusbarWaybar   string = "waybar"
+	StatusbarI3blocks string = "i3blocks"
+)
+
+const (
+	HookPreTrack   string = "pre-track"
+	HookPostTrack  string = "post-track"
+	HookPreFinish  string = "pre-finish"
+	HookPostFinish string = "post-finish"
+	HookPostEdit   string = "post-edit"
+	HookPostImport string = "post-import"
+)
+
+const (
+	GitSwitchRemind string = "remind"
+	GitSwitchAuto   string = "auto"
+)
+
+const (
+	GitHookPostCheckout     string = "post-checkout"
+	GitHookPrepareCommitMsg string = "prepare-commit-msg"
+)
+
+const (
+	IdleAsk      string = "ask"
+	IdleSubtract string = "subtract"
+	IdleSplit    string = "split"
+	IdleKeep     string = "keep"
+)
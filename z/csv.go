@@ -0,0 +1,418 @@
+package z
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+var defaultCSVColumns = []string{"begin", "finish", "duration", "project", "task", "notes", "tags"}
+
+func CSVColumns() []string {
+	return []string{
+		"id",
+		"date",
+		"begin",
+		"finish",
+		"duration",
+		"project",
+		"task",
+		"notes",
+		"tags",
+		"attendees",
+		"references",
+		"user",
+	}
+}
+
+func DurationFormats() []string {
+	return []string{
+		DurationDecimal,
+		DurationClock,
+	}
+}
+
+// CSVOptions configure the CSV export; the zero value writes the default
+// columns, comma separated, with decimal hours and a header row.
+type CSVOptions struct {
+	Columns        []string
+	Delimiter      string
+	DurationFormat string
+	NoHeader       bool
+}
+
+func parseCSVDelimiter(delimiter string) (rune, error) {
+	switch strings.ToLower(delimiter) {
+	case "":
+		return ',', nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter '%s', a single character is required", delimiter)
+	}
+	return r, nil
+}
+
+func fmtCSVDuration(duration time.Duration, format string) string {
+	if format == DurationClock {
+		minutes := int64(duration.Round(time.Minute) / time.Minute)
+		return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%.2f", duration.Hours())
+}
+
+func csvValue(entry Entry, column string, durationFormat string) string {
+	switch column {
+	case "id":
+		return entry.ID
+	case "date":
+		return entry.Begin.Format(DateFormat)
+	case "begin":
+		return entry.Begin.Format(time.RFC3339)
+	case "finish":
+		if entry.Finish.IsZero() {
+			return ""
+		}
+		return entry.Finish.Format(time.RFC3339)
+	case "duration":
+		return fmtCSVDuration(entry.End().Sub(entry.Begin), durationFormat)
+	case "project":
+		return entry.Project
+	case "task":
+		return entry.Task
+	case "notes":
+		return entry.Notes
+	case "tags":
+		return strings.Join(entry.Tags, ",")
+	case "attendees":
+		return strings.Join(entry.Attendees, ",")
+	case "references":
+		return strings.Join(entry.References, ",")
+	case "user":
+		return entry.User
+	}
+	return ""
+}
+
+// ExportCSV writes the entries as RFC 4180 CSV.
+func ExportCSV(entries []Entry, options CSVOptions) (string, error) {
+	columns := options.Columns
+	if len(columns) == 0 {
+		columns = defaultCSVColumns
+	}
+	for idx, column := range columns {
+		columns[idx] = strings.ToLower(strings.TrimSpace(column))
+		if !zeit.ContainsFold(CSVColumns(), columns[idx]) {
+			return "", fmt.Errorf("unknown column '%s', possible values: %s", column, strings.Join(CSVColumns(), ", "))
+		}
+	}
+
+	durationFormat := strings.ToLower(options.DurationFormat)
+	if durationFormat == "" {
+		durationFormat = DurationDecimal
+	}
+	if !zeit.ContainsFold(DurationFormats(), durationFormat) {
+		return "", fmt.Errorf("unknown duration format '%s', possible values: %s", options.DurationFormat, strings.Join(DurationFormats(), ", "))
+	}
+
+	delimiter, err := parseCSVDelimiter(options.Delimiter)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = delimiter
+	writer.UseCRLF = true
+
+	if !options.NoHeader {
+		writer.Write(columns)
+	}
+	for _, entry := range entries {
+		record := make([]string, len(columns))
+		for idx, column := range columns {
+			record[idx] = csvValue(entry, column, durationFormat)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	return buf.String(), writer.Error()
+}
+
+func CSVImportFields() []string {
+	return []string{
+		"date",
+		"begin",
+		"finish",
+		"duration",
+		"project",
+		"task",
+		"notes",
+		"tags",
+		"attendees",
+		"references",
+	}
+}
+
+var csvTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+var csvClockLayouts = []string{
+	"15:04:05",
+	"15:04",
+	"3:04PM",
+	"3:04 PM",
+	"3:04pm",
+	"3:04 pm",
+}
+
+// CSVImportOptions configure the CSV import. Mapping maps CSV columns to
+// fields as `Column=field`; columns named like a field are mapped without.
+// Timestamps without UTC offset are assumed to be in Location.
+type CSVImportOptions struct {
+	Mapping    []string
+	Delimiter  string
+	Location   *time.Location
+	TimeFormat string
+}
+
+type csvRowError struct {
+	Row int
+	Err error
+}
+
+func (rerr *csvRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", rerr.Row, rerr.Err)
+}
+
+func (options *CSVImportOptions) parseTime(value string, date string) (time.Time, error) {
+	layouts := csvTimeLayouts
+	if options.TimeFormat != "" {
+		layouts = []string{options.TimeFormat}
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, options.Location); err == nil {
+			return t, nil
+		}
+	}
+
+	if date != "" {
+		for _, layout := range csvClockLayouts {
+			if t, err := time.ParseInLocation(DateFormat+" "+layout, date+" "+value, options.Location); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("could not parse time '%s'", value)
+}
+
+// parseCSVDuration parses decimal hours like 1.5, clock durations like 1:30
+// and Go durations like 1h30m.
+func parseCSVDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.Replace(value, ",", ".", 1))
+
+	if hours, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)), nil
+	}
+
+	if parts := strings.Split(value, ":"); len(parts) == 2 || len(parts) == 3 {
+		var duration time.Duration
+		for idx, unit := range []time.Duration{time.Hour, time.Minute, time.Second}[:len(parts)] {
+			n, err := strconv.Atoi(parts[idx])
+			if err != nil {
+				return 0, fmt.Errorf("could not parse duration '%s'", value)
+			}
+			duration += time.Duration(n) * unit
+		}
+		return duration, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return duration, nil
+	}
+
+	return 0, fmt.Errorf("could not parse duration '%s'", value)
+}
+
+func (options *CSVImportOptions) columns(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+
+	find := func(name string) int {
+		for idx, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), strings.TrimSpace(name)) {
+				return idx
+			}
+		}
+		return -1
+	}
+
+	for _, mapping := range options.Mapping {
+		column, field, ok := strings.Cut(mapping, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid mapping '%s', expected Column=field", mapping)
+		}
+		if !zeit.ContainsFold(CSVImportFields(), field) {
+			return nil, fmt.Errorf("unknown field '%s', possible values: %s", field, strings.Join(CSVImportFields(), ", "))
+		}
+		idx := find(column)
+		if idx < 0 {
+			return nil, fmt.Errorf("column '%s' not found, available columns: %s", column, strings.Join(header, ", "))
+		}
+		columns[field] = idx
+	}
+
+	for _, field := range CSVImportFields() {
+		if _, ok := columns[field]; !ok {
+			if idx := find(field); idx >= 0 {
+				columns[field] = idx
+			}
+		}
+	}
+
+	if _, ok := columns["begin"]; !ok {
+		return nil, fmt.Errorf("no column mapped to begin, use --map Column=begin")
+	}
+	_, hasFinish := columns["finish"]
+	_, hasDuration := columns["duration"]
+	if !hasFinish && !hasDuration {
+		return nil, fmt.Errorf("no column mapped to finish or duration, use --map Column=finish")
+	}
+
+	return columns, nil
+}
+
+func (options *CSVImportOptions) entry(user string, columns map[string]int, record []string) (Entry, error) {
+	value := func(field string) string {
+		if idx, ok := columns[field]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	entry := Entry{
+		Project:    value("project"),
+		Task:       value("task"),
+		Notes:      value("notes"),
+		User:       user,
+		Attendees:  zeit.ParseAttendees(strings.Split(value("attendees"), ",")),
+		Tags:       zeit.ParseTags(strings.Split(value("tags"), ",")),
+		References: zeit.ParseReferences(strings.Split(value("references"), ",")),
+	}
+
+	var err error
+	date := value("date")
+	if entry.Begin, err = options.parseTime(value("begin"), date); err != nil {
+		return entry, fmt.Errorf("begin: %v", err)
+	}
+
+	if finish := value("finish"); finish != "" {
+		if entry.Finish, err = options.parseTime(finish, date); err != nil {
+			return entry, fmt.Errorf("finish: %v", err)
+		}
+		// Clock times past midnight finish on the next day
+		if entry.Finish.Before(entry.Begin) && date != "" && !strings.Contains(finish, "-") {
+			entry.Finish = entry.Finish.AddDate(0, 0, 1)
+		}
+	} else if duration := value("duration"); duration != "" {
+		d, err := parseCSVDuration(duration)
+		if err != nil {
+			return entry, err
+		}
+		entry.Finish = entry.Begin.Add(d)
+	} else {
+		return entry, fmt.Errorf("neither finish nor duration given")
+	}
+
+	if !entry.IsFinishedAfterBegan() {
+		return entry, NewFinishBeforeBeginError(entry)
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(record, "\x1f")))
+	entry.SHA1 = fmt.Sprintf("%x", sum)
+	return entry, nil
+}
+
+// ImportCSV reads the entries of a CSV file with a header row. Rows that
+// can't be read don't stop the import but are returned as errors.
+func ImportCSV(user string, file string, options CSVImportOptions) ([]Entry, []error, error) {
+	var entries []Entry
+	var rowErrors []error
+
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+
+	delimiter, err := parseCSVDelimiter(options.Delimiter)
+	if err != nil {
+		return entries, rowErrors, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return entries, rowErrors, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return entries, rowErrors, fmt.Errorf("could not read the header row: %v", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	columns, err := options.columns(header)
+	if err != nil {
+		return entries, rowErrors, err
+	}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: err})
+			continue
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		entry, err := options.entry(user, columns, record)
+		if err != nil {
+			rowErrors = append(rowErrors, &csvRowError{Row: row, Err: err})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rowErrors, nil
+}
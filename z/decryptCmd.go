@@ -0,0 +1,52 @@
+package z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt database",
+	Long:  "Permanently decrypt a previously encrypted database, storing it as a plain database file again.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := getLocalDatabase()
+		if err != nil {
+			return err
+		}
+
+		if db.Encryption == nil {
+			return errors.New("database is not encrypted")
+		}
+
+		if err := AutoBackup(GetCurrentUser(), "decrypt"); err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := db.DB.Save(&buf); err != nil {
+			return err
+		}
+		db.Close()
+
+		tmpfile := db.File + ".tmp"
+		if err := os.WriteFile(tmpfile, buf.Bytes(), 0600); err != nil {
+			return err
+		}
+
+		if err := os.Rename(tmpfile, db.File); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s database decrypted\n", CharInfo)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+}
@@ -0,0 +1,129 @@
+package z
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// GetConfigFile returns ~/.config/zeit/config.[toml|yaml|yml|json] if it
+// exists, which is used in addition to the zeit.[toml|yaml] lookup.
+func GetConfigFile() string {
+	configHome := GetConfigHome()
+	if configHome == "" {
+		return ""
+	}
+
+	for _, ext := range []string{"toml", "yaml", "yml", "json"} {
+		path := filepath.Join(configHome, "zeit", "config."+ext)
+		if fileExists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+func defaultsKey(cmd *cobra.Command) string {
+	path := strings.Fields(cmd.CommandPath())
+	return strings.Join(append([]string{"defaults"}, path[1:]...), ".")
+}
+
+// ApplyFlagDefaults sets every flag of the command that was not passed on the
+// command line to its value configured under `defaults.<command>.<flag>`,
+// e.g. `defaults.report.by-project`.
+func ApplyFlagDefaults(cmd *cobra.Command) error {
+	key := defaultsKey(cmd)
+	if !viper.IsSet(key) {
+		return nil
+	}
+
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed {
+			return
+		}
+
+		flagKey := key + "." + flag.Name
+		if !viper.IsSet(flagKey) {
+			return
+		}
+
+		var value string
+		if values, ok := viper.Get(flagKey).([]interface{}); ok {
+			var stringValues []string
+			for _, v := range values {
+				stringValues = append(stringValues, fmt.Sprint(v))
+			}
+			value = strings.Join(stringValues, ",")
+		} else {
+			value = viper.GetString(flagKey)
+		}
+
+		if serr := cmd.Flags().Set(flag.Name, value); serr != nil {
+			err = fmt.Errorf("invalid default for --%s in %s: %v", flag.Name, viper.ConfigFileUsed(), serr)
+		}
+	})
+
+	return err
+}
+
+// IsFirstWeekDayMonday reports whether weeks start on Monday, configured
+// either as `week.start: monday` or the older `firstWeekDayMonday: true`.
+func IsFirstWeekDayMonday() bool {
+	if viper.IsSet("week.start") {
+		return strings.ToLower(viper.GetString("week.start")) == "monday"
+	}
+
+	return viper.GetBool("firstWeekDayMonday")
+}
+
+// GetTimeLocation returns the timezone date ranges like today or thisWeek are
+// resolved in, configurable as `time.zone`, e.g. Europe/Berlin.
+func GetTimeLocation() (*time.Location, error) {
+	zone := viper.GetString("time.zone")
+	if zone == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Local, fmt.Errorf("unknown time.zone '%s'", zone)
+	}
+	return location, nil
+}
+
+// GetTimeDisplayFormat returns the layout used for printing timestamps,
+// configurable as `time.format`.
+func GetTimeDisplayFormat() string {
+	if viper.GetString("time.format") != "" {
+		return viper.GetString("time.format")
+	}
+
+	return "2006-01-02 15:04 -0700"
+}
+
+func OverlapPolicies() []string {
+	return []string{
+		OverlapAsk,
+		OverlapReject,
+		OverlapAllow,
+	}
+}
+
+// GetOverlapPolicy returns how overlapping entries are handled, configured
+// as `overlap.policy`.
+func GetOverlapPolicy() string {
+	policy := strings.ToLower(viper.GetString("overlap.policy"))
+	switch policy {
+	case OverlapReject, OverlapAllow:
+		return policy
+	default:
+		return OverlapAsk
+	}
+}
@@ -0,0 +1,165 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type DoctorIssue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	ID      string `json:"id,omitempty"`
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed"`
+
+	fix func() error
+}
+
+func newDoctorIssue(code string, id string, fix func() error, format string, a ...interface{}) DoctorIssue {
+	return DoctorIssue{
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+		ID:      id,
+		Fixable: fix != nil,
+		fix:     fix,
+	}
+}
+
+// Fix repairs the issue, if it is fixable.
+func (issue *DoctorIssue) Fix() error {
+	if issue.fix == nil {
+		return nil
+	}
+
+	if err := issue.fix(); err != nil {
+		return err
+	}
+
+	issue.Fixed = true
+	return nil
+}
+
+func parseRawEntry(id string, value string) (Entry, *DoctorIssue) {
+	var entry Entry
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		issue := newDoctorIssue(DoctorCorruptRecord, id, nil, "entry %s is not valid JSON: %v", id, err)
+		return entry, &issue
+	}
+
+	for _, field := range []string{"begin", "finish"} {
+		raw, ok := fields[field]
+		if !ok {
+			continue
+		}
+
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			issue := newDoctorIssue(DoctorInvalidTimestamp, id, nil, "entry %s has an unparsable %s timestamp %s", id, field, string(raw))
+			return entry, &issue
+		}
+	}
+
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		issue := newDoctorIssue(DoctorCorruptRecord, id, nil, "entry %s could not be read: %v", id, err)
+		return entry, &issue
+	}
+	entry.ID = id
+
+	if entry.Begin.IsZero() {
+		issue := newDoctorIssue(DoctorInvalidTimestamp, id, nil, "entry %s has no begin timestamp", id)
+		return entry, &issue
+	}
+
+	return entry, nil
+}
+
+// Diagnose scans the database of the user for corrupt or inconsistent
+// records. Nothing is changed until the returned issues are fixed.
+func Diagnose(user string) ([]DoctorIssue, error) {
+	var issues []DoctorIssue
+
+	rawEntries, err := database.ListRawEntries(user)
+	if err != nil {
+		return issues, err
+	}
+
+	ids := make([]string, 0, len(rawEntries))
+	for id := range rawEntries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	entries := make(map[string]Entry)
+	normalizedIDs := make(map[string]string)
+	contents := make(map[string]string)
+
+	for _, id := range ids {
+		if otherID, ok := normalizedIDs[strings.ToLower(strings.TrimSpace(id))]; ok {
+			issues = append(issues, newDoctorIssue(DoctorDuplicateID, id, nil, "entry ID %s is a duplicate of %s", id, otherID))
+		}
+		normalizedIDs[strings.ToLower(strings.TrimSpace(id))] = id
+
+		entry, issue := parseRawEntry(id, rawEntries[id])
+		if issue != nil {
+			issues = append(issues, *issue)
+			continue
+		}
+		entries[id] = entry
+
+		if !entry.Finish.IsZero() && !entry.IsFinishedAfterBegan() {
+			issues = append(issues, newDoctorIssue(DoctorFinishBeforeBegin, id, func() error {
+				entry.Begin, entry.Finish = entry.Finish, entry.Begin
+				_, err := database.UpdateEntry(user, entry)
+				return err
+			}, "entry %s finishes before it begins", id))
+		}
+
+		content := fmt.Sprintf("%s|%s|%s|%s|%s", entry.Begin.UTC(), entry.Finish.UTC(), entry.Project, entry.Task, entry.Notes)
+		if otherID, ok := contents[content]; ok {
+			issues = append(issues, newDoctorIssue(DoctorDuplicateEntry, id, func() error {
+				return database.EraseEntry(user, id)
+			}, "entry %s is identical to %s", id, otherID))
+			continue
+		}
+		contents[content] = id
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return issues, err
+	}
+	runningEntry, exists := entries[runningEntryId]
+	_, stored := rawEntries[runningEntryId]
+	if runningEntryId != "" && (!stored || exists && !runningEntry.Finish.IsZero()) {
+		issues = append(issues, newDoctorIssue(DoctorOrphanedReference, runningEntryId, func() error {
+			return database.SetRunningEntryId(user, "")
+		}, "running entry %s does not exist or is already finished", runningEntryId))
+	}
+
+	projects, err := database.ListProjects(user)
+	if err != nil {
+		return issues, err
+	}
+	for _, project := range projects {
+		for _, taskName := range project.Tasks {
+			task, err := database.GetTask(user, taskName)
+			if err != nil {
+				return issues, err
+			}
+			if task.Name != "" {
+				continue
+			}
+
+			issues = append(issues, newDoctorIssue(DoctorOrphanedReference, "", func() error {
+				return database.UpdateTask(user, taskName, Task{Name: taskName})
+			}, "project %s references task %s, which does not exist", project.Name, taskName))
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,89 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check database integrity",
+	Long:  "Scan the database for corrupt records, entries finishing before they begin, duplicates and orphaned references, and optionally fix repairable problems.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		issues, err := Diagnose(user)
+		if err != nil {
+			return err
+		}
+
+		if doctorFix && len(issues) > 0 {
+			if err := AutoBackup(user, "doctor"); err != nil {
+				return err
+			}
+
+			err = Batch(func() error {
+				for idx := range issues {
+					if err := issues[idx].Fix(); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if IsOutputJSON() {
+			if issues == nil {
+				issues = []DoctorIssue{}
+			}
+			if err := printJSON(issues); err != nil {
+				return err
+			}
+		} else {
+			fixable := 0
+			unrepairable := 0
+			for _, issue := range issues {
+				switch {
+				case issue.Fixed:
+					fmt.Printf("%s %s %s\n", CharFinish, issue.Message, color.FgLightGreen.Render("(fixed)"))
+				case issue.Fixable:
+					fmt.Printf("%s %s %s\n", CharError, issue.Message, color.FgGray.Render("(fixable)"))
+					fixable++
+				default:
+					fmt.Printf("%s %s\n", CharError, issue.Message)
+					if issue.ID != "" {
+						unrepairable++
+					}
+				}
+			}
+
+			if len(issues) == 0 {
+				fmt.Printf("%s no problems found\n", CharInfo)
+			} else if fixable > 0 {
+				fmt.Printf("%s run `zeit doctor --fix` to fix %d of %d problems\n", CharMore, fixable, len(issues))
+			}
+			if unrepairable > 0 {
+				fmt.Printf("%s entries that cannot be repaired can be removed using `zeit erase [id]`\n", CharMore)
+			}
+		}
+
+		for _, issue := range issues {
+			if !issue.Fixed {
+				return exitWithCode(ExitValidation)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Fix repairable problems; a backup is created beforehand")
+}
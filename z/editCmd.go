@@ -2,21 +2,27 @@ package z
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"time"
 
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 type EditableEntry struct {
-	Begin   string `json:"begin"`
-	Finish  string `json:"finish"`
-	Project string `json:"project"`
-	Task    string `json:"task"`
-	Notes   string `json:"notes"`
+	Begin      string   `json:"begin"`
+	Finish     string   `json:"finish"`
+	Project    string   `json:"project"`
+	Task       string   `json:"task"`
+	Notes      string   `json:"notes"`
+	Attendees  []string `json:"attendees"`
+	Tags       []string `json:"tags"`
+	References []string `json:"references"`
 }
 
 var (
@@ -28,35 +34,31 @@ var editCmd = &cobra.Command{
 	Short: "Edit an entry using $EDITOR",
 	Long:  "Edit an entry by opening a temporary file in your $EDITOR with the entry data. Use --last to edit the most recent entry.",
 	Args:  cobra.RangeArgs(0, 1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 		var id string
 
 		if editLast {
 			if len(args) > 0 {
-				fmt.Printf("%s Cannot specify both --last flag and entry ID\n", CharError)
-				os.Exit(1)
+				return errors.New("Cannot specify both --last flag and entry ID")
 			}
-			
+
 			// Get all entries and find the last one
 			entries, err := database.ListEntries(user)
 			if err != nil {
-				fmt.Printf("%s %+v\n", CharError, err)
-				os.Exit(1)
+				return err
 			}
-			
+
 			if len(entries) == 0 {
-				fmt.Printf("%s No entries found\n", CharError)
-				os.Exit(1)
+				return &NotFoundError{Message: "No entries found"}
 			}
-			
+
 			// Get the last entry (entries are sorted by begin time)
 			lastEntry := entries[len(entries)-1]
 			id = lastEntry.ID
 		} else {
 			if len(args) == 0 {
-				fmt.Printf("%s Entry ID is required when --last flag is not used\n", CharError)
-				os.Exit(1)
+				return errors.New("Entry ID is required when --last flag is not used")
 			}
 			id = args[0]
 		}
@@ -64,92 +66,109 @@ var editCmd = &cobra.Command{
 		// Get the existing entry
 		entry, err := database.GetEntry(user, id)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
-		// Create editable representation
-		editableEntry := EditableEntry{
-			Begin:   entry.Begin.Format("2006-01-02 15:04:05 -0700"),
-			Project: entry.Project,
-			Task:    entry.Task,
-			Notes:   entry.Notes,
+		modifiedEntry, err := editInEditor(NewEditableEntry(entry))
+		if err != nil {
+			return err
 		}
 
-		// Handle finish time (could be zero for running entries)
-		if !entry.Finish.IsZero() {
-			editableEntry.Finish = entry.Finish.Format("2006-01-02 15:04:05 -0700")
+		// Validate and update the entry
+		if err := validateAndUpdateEntry(user, id, modifiedEntry); err != nil {
+			return err
 		}
 
-		// Marshal to JSON
-		jsonData, err := json.MarshalIndent(editableEntry, "", "  ")
+		// Get updated entry and display
+		updatedEntry, err := database.GetEntry(user, id)
 		if err != nil {
-			fmt.Printf("%s Failed to serialize entry: %+v\n", CharError, err)
-			os.Exit(1)
+			return fmt.Errorf("Failed to retrieve updated entry: %w", err)
 		}
 
-		// Create temporary file
-		tmpFile, err := ioutil.TempFile("", "zeit-edit-*.json")
-		if err != nil {
-			fmt.Printf("%s Failed to create temporary file: %+v\n", CharError, err)
-			os.Exit(1)
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Updated: entryResources([]Entry{updatedEntry})})
 		}
-		defer os.Remove(tmpFile.Name())
 
-		// Write JSON to temp file
-		if _, err := tmpFile.Write(jsonData); err != nil {
-			fmt.Printf("%s Failed to write to temporary file: %+v\n", CharError, err)
-			os.Exit(1)
-		}
-		tmpFile.Close()
+		fmt.Printf("%s Entry updated successfully\n", CharInfo)
+		fmt.Printf("%s\n", GetEntryOutput(updatedEntry, true))
+		return nil
+	},
+}
 
-		// Get editor from environment
-		editor := os.Getenv("EDITOR")
-		if editor == "" {
-			editor = "vi" // Default fallback
-		}
+func NewEditableEntry(entry Entry) EditableEntry {
+	editableEntry := EditableEntry{
+		Begin:      entry.Begin.Format("2006-01-02 15:04:05 -0700"),
+		Project:    entry.Project,
+		Task:       entry.Task,
+		Notes:      entry.Notes,
+		Attendees:  entry.Attendees,
+		Tags:       entry.Tags,
+		References: entry.References,
+	}
 
-		// Open editor
-		editorCmd := exec.Command(editor, tmpFile.Name())
-		editorCmd.Stdin = os.Stdin
-		editorCmd.Stdout = os.Stdout
-		editorCmd.Stderr = os.Stderr
+	// Handle finish time (could be zero for running entries)
+	if !entry.Finish.IsZero() {
+		editableEntry.Finish = entry.Finish.Format("2006-01-02 15:04:05 -0700")
+	}
 
-		if err := editorCmd.Run(); err != nil {
-			fmt.Printf("%s Failed to run editor: %+v\n", CharError, err)
-			os.Exit(1)
-		}
+	return editableEntry
+}
 
-		// Read modified content
-		modifiedData, err := ioutil.ReadFile(tmpFile.Name())
-		if err != nil {
-			fmt.Printf("%s Failed to read modified file: %+v\n", CharError, err)
-			os.Exit(1)
-		}
+// editInEditor opens the entry in a temporary file in the configured editor
+// and returns it as saved.
+func editInEditor(editableEntry EditableEntry) (EditableEntry, error) {
+	var modifiedEntry EditableEntry
 
-		// Parse modified JSON
-		var modifiedEntry EditableEntry
-		if err := json.Unmarshal(modifiedData, &modifiedEntry); err != nil {
-			fmt.Printf("%s Invalid JSON format: %+v\n", CharError, err)
-			os.Exit(1)
-		}
+	// Marshal to JSON
+	jsonData, err := json.MarshalIndent(editableEntry, "", "  ")
+	if err != nil {
+		return modifiedEntry, fmt.Errorf("Failed to serialize entry: %+v", err)
+	}
 
-		// Validate and update the entry
-		if err := validateAndUpdateEntry(user, id, modifiedEntry); err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
-		}
+	// Create temporary file
+	tmpFile, err := ioutil.TempFile("", "zeit-edit-*.json")
+	if err != nil {
+		return modifiedEntry, fmt.Errorf("Failed to create temporary file: %+v", err)
+	}
+	defer os.Remove(tmpFile.Name())
 
-		// Get updated entry and display
-		updatedEntry, err := database.GetEntry(user, id)
-		if err != nil {
-			fmt.Printf("%s Failed to retrieve updated entry: %+v\n", CharError, err)
-			os.Exit(1)
-		}
+	// Write JSON to temp file
+	if _, err := tmpFile.Write(jsonData); err != nil {
+		return modifiedEntry, fmt.Errorf("Failed to write to temporary file: %+v", err)
+	}
+	tmpFile.Close()
 
-		fmt.Printf("%s Entry updated successfully\n", CharInfo)
-		fmt.Printf("%s\n", updatedEntry.GetOutput(true))
-	},
+	// Get editor from config or environment
+	editor := viper.GetString("editor")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi" // Default fallback
+	}
+
+	// Open editor
+	editorCmd := exec.Command(editor, tmpFile.Name())
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	if err := editorCmd.Run(); err != nil {
+		return modifiedEntry, fmt.Errorf("Failed to run editor: %+v", err)
+	}
+
+	// Read modified content
+	modifiedData, err := ioutil.ReadFile(tmpFile.Name())
+	if err != nil {
+		return modifiedEntry, fmt.Errorf("Failed to read modified file: %+v", err)
+	}
+
+	// Parse modified JSON
+	if err := json.Unmarshal(modifiedData, &modifiedEntry); err != nil {
+		return modifiedEntry, fmt.Errorf("Invalid JSON format: %+v", err)
+	}
+
+	return modifiedEntry, nil
 }
 
 func validateAndUpdateEntry(user string, id string, editableEntry EditableEntry) error {
@@ -158,18 +177,24 @@ func validateAndUpdateEntry(user string, id string, editableEntry EditableEntry)
 	if err != nil {
 		return err
 	}
+	if err = ValidateEditWindow(originalEntry); err != nil {
+		return err
+	}
 
 	// Create new entry with modified data
 	newEntry := originalEntry
 	newEntry.Project = editableEntry.Project
 	newEntry.Task = editableEntry.Task
 	newEntry.Notes = editableEntry.Notes
+	newEntry.Attendees = zeit.ParseAttendees(editableEntry.Attendees)
+	newEntry.Tags = zeit.ParseTags(editableEntry.Tags)
+	newEntry.References = zeit.ParseReferences(editableEntry.References)
 
 	// Parse begin time
 	if editableEntry.Begin != "" {
 		beginTime, err := ParseTime(editableEntry.Begin, time.Time{})
 		if err != nil {
-			return fmt.Errorf("invalid begin time format: %v", err)
+			return NewInvalidTimeError("begin", editableEntry.Begin)
 		}
 		newEntry.Begin = beginTime
 	}
@@ -178,7 +203,7 @@ func validateAndUpdateEntry(user string, id string, editableEntry EditableEntry)
 	if editableEntry.Finish != "" {
 		finishTime, err := ParseTime(editableEntry.Finish, time.Time{})
 		if err != nil {
-			return fmt.Errorf("invalid finish time format: %v", err)
+			return NewInvalidTimeError("finish", editableEntry.Finish)
 		}
 		newEntry.Finish = finishTime
 	} else {
@@ -187,55 +212,38 @@ func validateAndUpdateEntry(user string, id string, editableEntry EditableEntry)
 
 	// Validate time logic
 	if !newEntry.IsFinishedAfterBegan() {
-		return fmt.Errorf("finish time cannot be before begin time")
+		return NewFinishBeforeBeginError(newEntry)
 	}
 
-	// Check for overlaps with other entries
-	if err := checkForOverlaps(user, id, newEntry); err != nil {
+	if err := ValidateProjectRules(user, newEntry); err != nil {
 		return err
 	}
 
-	// Update in database
-	_, err = database.UpdateEntry(user, newEntry)
-	return err
-}
-
-func checkForOverlaps(user string, excludeID string, entry Entry) error {
-	// Get all entries for the user
-	entries, err := database.ListEntries(user)
+	// Check for overlaps with other entries and let the user resolve them
+	resolution, err := ResolveOverlaps(user, id, newEntry)
 	if err != nil {
-		return fmt.Errorf("failed to check for overlaps: %v", err)
+		return err
 	}
 
-	entryEnd := entry.Finish
-	if entryEnd.IsZero() {
-		entryEnd = time.Now() // Use current time for running entries
+	// Update in database
+	err = StoreResolution(user, resolution, func(entry Entry) (string, error) {
+		return database.UpdateEntry(user, entry)
+	})
+	if err != nil {
+		return err
 	}
 
-	for _, existingEntry := range entries {
-		// Skip the entry being edited
-		if existingEntry.ID == excludeID {
-			continue
-		}
-
-		existingEnd := existingEntry.Finish
-		if existingEnd.IsZero() {
-			existingEnd = time.Now() // Use current time for running entries
-		}
-
-		// Check for overlap
-		if (entry.Begin.Before(existingEnd) && entryEnd.After(existingEntry.Begin)) {
-			return fmt.Errorf("entry overlaps with existing entry %s (%s to %s)",
-				existingEntry.ID,
-				existingEntry.Begin.Format("2006-01-02 15:04:05"),
-				existingEnd.Format("2006-01-02 15:04:05"))
-		}
+	// Hooks get the entry as it was stored, after resolving overlaps
+	storedEntry, err := database.GetEntry(user, id)
+	if err != nil {
+		return err
 	}
-
+	RunPostHooks(HookPostEdit, storedEntry)
 	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(editCmd)
 	editCmd.Flags().BoolVarP(&editLast, "last", "l", false, "Edit the last entry")
-}
\ No newline at end of file
+	editCmd.ValidArgsFunction = completeEntryIDs
+}
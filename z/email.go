@@ -0,0 +1,297 @@
+package z
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// Reports are emailed using the SMTP server configured as `email.smtp`:
+//
+//	email:
+//	  smtp:
+//	    host: smtp.example.com
+//	    port: 587
+//	    username: me@example.com
+//	    password: ${SMTP_PASSWORD}
+//	    # starttls (default), tls or none
+//	    security: starttls
+//	  from: Me <me@example.com>
+//	  to: [manager@example.com]
+//	  subject: "Timesheet of {{.User}}, {{.Since}} to {{.Until}}"
+
+const defaultEmailSubject string = "Timesheet {{.Since}} to {{.Until}}"
+
+const emailTimeout time.Duration = 30 * time.Second
+
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Security string
+	From     *mail.Address
+}
+
+// EmailSubject is what the email.subject template is executed with.
+type EmailSubject struct {
+	User  string
+	Since string
+	Until string
+}
+
+func EmailSecurities() []string {
+	return []string{
+		EmailSecurityStartTLS,
+		EmailSecurityTLS,
+		EmailSecurityNone,
+	}
+}
+
+// NewMailer reads email.smtp and email.from. The port defaults to 465 with
+// TLS and to 587 otherwise.
+func NewMailer() (*Mailer, error) {
+	mailer := Mailer{
+		Host:     viper.GetString("email.smtp.host"),
+		Port:     viper.GetInt("email.smtp.port"),
+		Username: os.ExpandEnv(viper.GetString("email.smtp.username")),
+		Password: os.ExpandEnv(viper.GetString("email.smtp.password")),
+		Security: strings.ToLower(viper.GetString("email.smtp.security")),
+	}
+	if mailer.Host == "" {
+		return nil, errors.New("no SMTP server configured, please configure email.smtp.host")
+	}
+
+	if mailer.Security == "" {
+		mailer.Security = EmailSecurityStartTLS
+	}
+	if !zeit.ContainsFold(EmailSecurities(), mailer.Security) {
+		return nil, fmt.Errorf("unknown email.smtp.security '%s', possible values: %s", mailer.Security, strings.Join(EmailSecurities(), ", "))
+	}
+	if mailer.Port == 0 {
+		mailer.Port = 587
+		if mailer.Security == EmailSecurityTLS {
+			mailer.Port = 465
+		}
+	}
+
+	from := viper.GetString("email.from")
+	if from == "" {
+		from = mailer.Username
+	}
+	var err error
+	if mailer.From, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid email.from '%s', please configure the address to send from", from)
+	}
+
+	return &mailer, nil
+}
+
+// ParseEmailAddresses parses the recipients, which may be given separated by
+// commas as well.
+func ParseEmailAddresses(values []string) ([]*mail.Address, error) {
+	var addresses []*mail.Address
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddressList(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address '%s': %v", value, err)
+		}
+		addresses = append(addresses, parsed...)
+	}
+	return addresses, nil
+}
+
+// GetEmailSubject executes the email.subject template.
+func GetEmailSubject(subject EmailSubject) (string, error) {
+	text := viper.GetString("email.subject")
+	if text == "" {
+		text = defaultEmailSubject
+	}
+
+	tmpl, err := template.New("subject").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid email.subject: %v", err)
+	}
+	var output strings.Builder
+	if err = tmpl.Execute(&output, subject); err != nil {
+		return "", fmt.Errorf("invalid email.subject: %v", err)
+	}
+	return strings.TrimSpace(output.String()), nil
+}
+
+// emailBase64 encodes the body in lines of 76 characters.
+func emailBase64(body string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	var lines strings.Builder
+	for len(encoded) > 76 {
+		lines.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	lines.WriteString(encoded + "\r\n")
+	return lines.String()
+}
+
+// message returns the email with the text and the HTML version of the body
+// as alternatives.
+func (mailer *Mailer) message(to []*mail.Address, subject string, text string, htmlBody string) []byte {
+	var message bytes.Buffer
+
+	boundary := make([]byte, 12)
+	rand.Read(boundary)
+	boundaryString := fmt.Sprintf("zeit-%x", boundary)
+
+	recipients := make([]string, 0, len(to))
+	for _, address := range to {
+		recipients = append(recipients, address.String())
+	}
+
+	fmt.Fprintf(&message, "From: %s\r\n", mailer.From.String())
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%s@%s>\r\n", boundaryString, mailer.Host)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundaryString)
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", text},
+		{"text/html", htmlBody},
+	} {
+		fmt.Fprintf(&message, "--%s\r\n", boundaryString)
+		fmt.Fprintf(&message, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		fmt.Fprintf(&message, "Content-Transfer-Encoding: base64\r\n\r\n")
+		message.WriteString(emailBase64(part.body))
+	}
+	fmt.Fprintf(&message, "--%s--\r\n", boundaryString)
+
+	return message.Bytes()
+}
+
+// Send sends the email to the recipients.
+func (mailer *Mailer) Send(to []*mail.Address, subject string, text string, htmlBody string) error {
+	if len(to) == 0 {
+		return errors.New("no recipients, please configure email.to or use --to")
+	}
+
+	address := net.JoinHostPort(mailer.Host, strconv.Itoa(mailer.Port))
+	tlsConfig := &tls.Config{ServerName: mailer.Host}
+	dialer := net.Dialer{Timeout: emailTimeout}
+
+	var conn net.Conn
+	var err error
+	if mailer.Security == EmailSecurityTLS {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(emailTimeout))
+
+	client, err := smtp.NewClient(conn, mailer.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	defer client.Close()
+
+	if mailer.Security == EmailSecurityStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("SMTP: %v", err)
+		}
+	}
+	if mailer.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)); err != nil {
+			return fmt.Errorf("SMTP: %v", err)
+		}
+	}
+
+	if err = client.Mail(mailer.From.Address); err != nil {
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	for _, recipient := range to {
+		if err = client.Rcpt(recipient.Address); err != nil {
+			return fmt.Errorf("SMTP: %s: %v", recipient.Address, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	if _, err = writer.Write(mailer.message(to, subject, text, htmlBody)); err != nil {
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("SMTP: %v", err)
+	}
+	return client.Quit()
+}
+
+// ReportSchedules are the values of `report --schedule`.
+func ReportSchedules() []string {
+	return []string{
+		ReportScheduleDaily,
+		ReportScheduleWeekly,
+		ReportScheduleMonthly,
+	}
+}
+
+// GetReportCrontab returns the crontab line running zeit with the arguments
+// on the schedule: daily reports are sent on weekdays at 18:00 for the day,
+// weekly ones on Mondays at 8:00 for the last week and monthly ones on the
+// first of the month at 8:00 for the last month.
+func GetReportCrontab(schedule string, args []string) (string, error) {
+	var when, reportRange string
+	switch strings.ToLower(schedule) {
+	case ReportScheduleDaily:
+		when, reportRange = "0 18 * * 1-5", "today"
+	case ReportScheduleWeekly:
+		when, reportRange = "0 8 * * 1", "lastWeek"
+	case ReportScheduleMonthly:
+		when, reportRange = "0 8 1 * *", "lastMonth"
+	default:
+		return "", fmt.Errorf("unknown schedule '%s', possible values: %s", schedule, strings.Join(ReportSchedules(), ", "))
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	// cron doesn't run with the environment of the shell
+	var command []string
+	if db := viper.GetString("db"); db != "" {
+		command = append(command, "ZEIT_DB="+shellQuote(db))
+	}
+	command = append(command, shellQuote(executable))
+	if config := viper.ConfigFileUsed(); config != "" {
+		command = append(command, "--config", shellQuote(config))
+	}
+	command = append(command, "report", "--email", "--range", reportRange)
+	for _, arg := range args {
+		command = append(command, shellQuote(arg))
+	}
+
+	// A % ends the command in crontabs
+	return when + " " + strings.ReplaceAll(strings.Join(command, " "), "%", `\%`), nil
+}
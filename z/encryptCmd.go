@@ -0,0 +1,81 @@
+package z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	encryptMethod    string
+	encryptRecipient string
+)
+
+func getLocalDatabase() (*Database, error) {
+	db, ok := unwrapStorage(database).(*Database)
+	if !ok {
+		return nil, errors.New("only supported for local database files")
+	}
+
+	return db, nil
+}
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt database",
+	Long:  "Encrypt the database at rest using a passphrase, an age recipient or a GPG key. Afterwards the database is transparently decrypted whenever zeit runs.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := getLocalDatabase()
+		if err != nil {
+			return err
+		}
+
+		if db.Encryption != nil {
+			return errors.New("database is already encrypted")
+		}
+
+		enc, err := NewEncryption(encryptMethod, encryptRecipient)
+		if err != nil {
+			return err
+		}
+
+		// The snapshot is written with the new encryption, the database must
+		// not be left next to a plaintext copy of it
+		db.Encryption = enc
+		if err := AutoBackup(GetCurrentUser(), "encrypt"); err != nil {
+			db.Encryption = nil
+			return err
+		}
+		encrypted, err := EncryptAutoBackups(enc)
+		if err != nil {
+			db.Encryption = nil
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err = db.DB.Save(&buf); err != nil {
+			return err
+		}
+		db.Close()
+
+		if err = enc.WriteFile(db.File, buf.Bytes()); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s database encrypted using %s\n", CharInfo, color.FgLightWhite.Render(enc.Method))
+		if encrypted > 0 {
+			fmt.Printf("%s encrypted %d earlier automatic backups the same way\n", CharInfo, encrypted)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	encryptCmd.Flags().StringVar(&encryptMethod, "method", EncryptionPassphrase, "Encryption method, possible values: "+strings.Join(EncryptionMethods(), ", "))
+	encryptCmd.Flags().StringVar(&encryptRecipient, "recipient", "", "age recipient (age1...) or GPG key ID/email to encrypt to")
+}
@@ -0,0 +1,249 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const encryptionHeader string = "zeit-encrypted/v1"
+
+type Encryption struct {
+	Method     string
+	Recipient  string
+	passphrase string
+}
+
+func EncryptionMethods() []string {
+	return []string{
+		EncryptionPassphrase,
+		EncryptionAge,
+		EncryptionGPG,
+	}
+}
+
+func readPassphrase(prompt string) (string, error) {
+	if viper.GetString("passphrase") != "" {
+		return viper.GetString("passphrase"), nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("database is encrypted; please `export ZEIT_PASSPHRASE`")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s %s: ", CharMore, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	return string(passphrase), nil
+}
+
+func NewEncryption(method string, recipient string) (*Encryption, error) {
+	enc := Encryption{Method: method, Recipient: recipient}
+
+	switch method {
+	case EncryptionPassphrase:
+		passphrase, err := readPassphrase("new passphrase")
+		if err != nil {
+			return nil, err
+		}
+		if viper.GetString("passphrase") == "" {
+			confirmation, err := readPassphrase("repeat passphrase")
+			if err != nil {
+				return nil, err
+			}
+			if confirmation != passphrase {
+				return nil, errors.New("passphrases do not match")
+			}
+		}
+		if passphrase == "" {
+			return nil, errors.New("passphrase must not be empty")
+		}
+		enc.passphrase = passphrase
+	case EncryptionAge, EncryptionGPG:
+		if recipient == "" {
+			return nil, fmt.Errorf("%s encryption requires a --recipient", method)
+		}
+	default:
+		return nil, errors.New("unknown encryption method, possible values: " + strings.Join(EncryptionMethods(), ", "))
+	}
+
+	return &enc, nil
+}
+
+// ReadEncryptedFile checks whether the file at path was written by
+// WriteFile and, if so, returns the decrypted content alongside the
+// encryption settings required to write it back.
+func ReadEncryptedFile(path string) (*Encryption, []byte, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, encryptionHeader+" ") {
+		return nil, nil, nil
+	}
+
+	fields := strings.Fields(header)
+	enc := Encryption{Method: fields[1]}
+	if len(fields) > 2 {
+		enc.Recipient = fields[2]
+	}
+
+	ciphertext, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := enc.decrypt(ciphertext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decrypt database: %v", err)
+	}
+
+	return &enc, plaintext, nil
+}
+
+// IsEncryptedFile returns whether the file at path was written by WriteFile,
+// without decrypting it.
+func IsEncryptedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return strings.HasPrefix(header, encryptionHeader+" "), nil
+}
+
+func (enc *Encryption) WriteFile(path string, plaintext []byte) error {
+	ciphertext, err := enc.encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("%s %s", encryptionHeader, enc.Method)
+	if enc.Recipient != "" {
+		header = fmt.Sprintf("%s %s", header, enc.Recipient)
+	}
+
+	tmpfile := path + ".tmp"
+	err = os.WriteFile(tmpfile, append([]byte(header+"\n"), ciphertext...), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfile, path)
+}
+
+func (enc *Encryption) encrypt(plaintext []byte) ([]byte, error) {
+	var recipient age.Recipient
+	var err error
+
+	switch enc.Method {
+	case EncryptionPassphrase:
+		recipient, err = age.NewScryptRecipient(enc.passphrase)
+	case EncryptionAge:
+		recipient, err = age.ParseX25519Recipient(enc.Recipient)
+	case EncryptionGPG:
+		return runGPG(plaintext, "--encrypt", "--recipient", enc.Recipient)
+	default:
+		err = errors.New("unknown encryption method " + enc.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (enc *Encryption) decrypt(ciphertext []byte) ([]byte, error) {
+	var identities []age.Identity
+
+	switch enc.Method {
+	case EncryptionPassphrase:
+		passphrase, err := readPassphrase("passphrase")
+		if err != nil {
+			return nil, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		enc.passphrase = passphrase
+		identities = append(identities, identity)
+	case EncryptionAge:
+		identityFile := ExpandPath(viper.GetString("encryption.identity"))
+		if identityFile == "" {
+			return nil, errors.New("database is encrypted with age; please set `encryption.identity` in the config")
+		}
+		file, err := os.Open(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		identities, err = age.ParseIdentities(file)
+		if err != nil {
+			return nil, err
+		}
+	case EncryptionGPG:
+		return runGPG(ciphertext, "--decrypt")
+	default:
+		return nil, errors.New("unknown encryption method " + enc.Method)
+	}
+
+	reader, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+func runGPG(input []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("gpg", append([]string{"--batch", "--yes", "--quiet"}, args...)...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gpg: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -1,27 +1,16 @@
 package z
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/gookit/color"
-	"github.com/shopspring/decimal"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/viper"
 )
 
-type Entry struct {
-	ID      string    `json:"-"`
-	Begin   time.Time `json:"begin,omitempty"`
-	Finish  time.Time `json:"finish,omitempty"`
-	Project string    `json:"project,omitempty"`
-	Task    string    `json:"task,omitempty"`
-	Notes   string    `json:"notes,omitempty"`
-	User    string    `json:"user,omitempty"`
-
-	SHA1 string `json:"-"`
-}
+type Entry = zeit.Entry
 
 func NewEntry(
 	id string,
@@ -40,35 +29,24 @@ func NewEntry(
 	newEntry.Task = task
 	newEntry.User = user
 
-	_, err = newEntry.SetBeginFromString(begin, time.Time{})
+	_, err = SetEntryBegin(&newEntry, begin, time.Time{})
 	if err != nil {
 		return Entry{}, err
 	}
 
-	_, err = newEntry.SetFinishFromString(finish, time.Time{})
+	_, err = SetEntryFinish(&newEntry, finish, time.Time{})
 	if err != nil {
 		return Entry{}, err
 	}
 
 	if id == "" && newEntry.IsFinishedAfterBegan() == false {
-		return Entry{}, errors.New("beginning time of tracking cannot be after finish time")
+		return Entry{}, NewFinishBeforeBeginError(newEntry)
 	}
 
 	return newEntry, nil
 }
 
-func (entry *Entry) SetIDFromDatabaseKey(key string) error {
-	splitKey := strings.Split(key, ":")
-
-	if len(splitKey) < 3 || len(splitKey) > 3 {
-		return errors.New("not a valid database key")
-	}
-
-	entry.ID = splitKey[2]
-	return nil
-}
-
-func (entry *Entry) SetBeginFromString(begin string, contextTime time.Time) (time.Time, error) {
+func SetEntryBegin(entry *Entry, begin string, contextTime time.Time) (time.Time, error) {
 	var beginTime time.Time
 	var err error
 
@@ -77,36 +55,32 @@ func (entry *Entry) SetBeginFromString(begin string, contextTime time.Time) (tim
 	} else {
 		beginTime, err = ParseTime(begin, contextTime)
 		if err != nil {
-			return beginTime, err
+			return beginTime, NewInvalidTimeError("begin", begin)
 		}
 	}
 
 	entry.Begin = beginTime
-	entry.secondsBegin()
+	secondsBegin(entry)
 	return entry.Begin, nil
 }
 
-func (entry *Entry) SetFinishFromString(finish string, contextTime time.Time) (time.Time, error) {
+func SetEntryFinish(entry *Entry, finish string, contextTime time.Time) (time.Time, error) {
 	var finishTime time.Time
 	var err error
 
 	if finish != "" {
 		finishTime, err = ParseTime(finish, contextTime)
 		if err != nil {
-			return finishTime, err
+			return finishTime, NewInvalidTimeError("finish", finish)
 		}
 	}
 
 	entry.Finish = finishTime
-	entry.secondsFinish()
+	secondsFinish(entry)
 	return entry.Finish, nil
 }
 
-func (entry *Entry) IsFinishedAfterBegan() bool {
-	return (entry.Finish.IsZero() || entry.Begin.Before(entry.Finish) || entry.Begin.Equal(entry.Finish))
-}
-
-func (entry *Entry) GetOutputForTrack(isRunning bool, wasRunning bool) string {
+func GetEntryOutputForTrack(entry Entry, isRunning bool, wasRunning bool) string {
 	var outputPrefix string = ""
 	var outputSuffix string = ""
 
@@ -134,15 +108,7 @@ func (entry *Entry) GetOutputForTrack(isRunning bool, wasRunning bool) string {
 	return fmt.Sprintf("%s %s task%s\n", CharTrack, outputPrefix, outputSuffix)
 }
 
-func (entry *Entry) GetDuration() decimal.Decimal {
-	duration := entry.Finish.Sub(entry.Begin)
-	if duration < 0 {
-		duration = time.Now().Sub(entry.Begin)
-	}
-	return decimal.NewFromFloat(duration.Hours())
-}
-
-func (entry *Entry) GetOutputForFinish() string {
+func GetEntryOutputForFinish(entry Entry) string {
 	var outputSuffix string = ""
 
 	trackDiff := entry.Finish.Sub(entry.Begin)
@@ -161,7 +127,7 @@ func (entry *Entry) GetOutputForFinish() string {
 	return fmt.Sprintf("%s finished tracking task%s\n", CharFinish, outputSuffix)
 }
 
-func (entry *Entry) GetOutput(full bool) string {
+func GetEntryOutput(entry Entry, full bool) string {
 	var output string = ""
 	var entryFinish time.Time
 	var isRunning string = ""
@@ -180,64 +146,54 @@ func (entry *Entry) GetOutput(full bool) string {
 			color.FgGray.Render(entry.ID),
 			color.FgLightWhite.Render(entry.Task),
 			color.FgLightWhite.Render(entry.Project),
-			color.FgLightWhite.Render(entry.Begin.Format("2006-01-02 15:04 -0700")),
-			color.FgLightWhite.Render(entryFinish.Format("2006-01-02 15:04 -0700")),
+			color.FgLightWhite.Render(entry.Begin.Format(GetTimeDisplayFormat())),
+			color.FgLightWhite.Render(entryFinish.Format(GetTimeDisplayFormat())),
 			color.FgLightWhite.Render(taskDuration),
 			color.FgLightYellow.Render(isRunning),
 		)
 	} else {
-		output = fmt.Sprintf("%s\n   %s on %s\n   %sh from %s to %s %s\n\n   Notes:\n   %s\n",
+		output = fmt.Sprintf("%s\n   %s on %s\n   %sh from %s to %s %s\n",
 			color.FgGray.Render(entry.ID),
 			color.FgLightWhite.Render(entry.Task),
 			color.FgLightWhite.Render(entry.Project),
 			color.FgLightWhite.Render(taskDuration),
-			color.FgLightWhite.Render(entry.Begin.Format("2006-01-02 15:04 -0700")),
-			color.FgLightWhite.Render(entryFinish.Format("2006-01-02 15:04 -0700")),
+			color.FgLightWhite.Render(entry.Begin.Format(GetTimeDisplayFormat())),
+			color.FgLightWhite.Render(entryFinish.Format(GetTimeDisplayFormat())),
 			color.FgLightYellow.Render(isRunning),
-			color.FgLightWhite.Render(strings.Replace(entry.Notes, "\n", "\n   ", -1)),
 		)
-	}
 
-	return output
-}
-
-func GetFilteredEntries(entries []Entry, project string, task string, since time.Time, until time.Time) ([]Entry, error) {
-	var filteredEntries []Entry
-
-	for _, entry := range entries {
-		if project != "" && GetIdFromName(entry.Project) != GetIdFromName(project) {
-			continue
-		}
-
-		if task != "" && GetIdFromName(entry.Task) != GetIdFromName(task) {
-			continue
-		}
-
-		if since.IsZero() == false && since.Before(entry.Begin) == false && since.Equal(entry.Begin) == false {
-			continue
+		if len(entry.Attendees) > 0 {
+			output = fmt.Sprintf("%s   with %s\n", output, color.FgLightWhite.Render(strings.Join(entry.Attendees, ", ")))
 		}
 
-		if until.IsZero() == false && until.After(entry.Finish) == false && until.Equal(entry.Finish) == false {
-			continue
+		if len(entry.Tags) > 0 {
+			output = fmt.Sprintf("%s   tagged %s\n", output, color.FgLightWhite.Render("#"+strings.Join(entry.Tags, " #")))
 		}
 
-		if until.IsZero() == false && entry.Finish.IsZero() && !entry.Begin.Before(until) {
-			continue
+		if len(entry.References) > 0 {
+			output = fmt.Sprintf("%s   references %s\n", output, color.FgLightWhite.Render(strings.Join(entry.References, ", ")))
 		}
 
-		filteredEntries = append(filteredEntries, entry)
+		output = fmt.Sprintf("%s\n   Notes:\n   %s\n",
+			output,
+			color.FgLightWhite.Render(strings.Replace(entry.Notes, "\n", "\n   ", -1)),
+		)
 	}
 
-	return filteredEntries, nil
+	return output
+}
+
+func GetFilteredEntries(entries []Entry, project string, task string, since time.Time, until time.Time) ([]Entry, error) {
+	return zeit.FilterEntries(entries, project, task, since, until), nil
 }
 
-func (entry *Entry) secondsBegin() {
+func secondsBegin(entry *Entry) {
 	if viper.GetBool("time.no-seconds") {
 		entry.Begin = entry.Begin.Truncate(time.Duration(time.Minute))
 	}
 }
 
-func (entry *Entry) secondsFinish() {
+func secondsFinish(entry *Entry) {
 	if viper.GetBool("time.no-seconds") {
 		entry.Finish = entry.Finish.Truncate(time.Duration(time.Minute))
 	}
@@ -2,9 +2,9 @@ package z
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
 )
 
@@ -13,30 +13,32 @@ var entryCmd = &cobra.Command{
 	Short: "Display or update activity",
 	Long:  "Display or update tracked activity.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 		id := args[0]
 
 		entry, err := database.GetEntry(user, id)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
-		if begin != "" || finish != "" || project != "" || notes != "" || task != "" {
+		var updated bool = false
+		if begin != "" || finish != "" || project != "" || notes != "" || task != "" || len(attendees) > 0 || len(tags) > 0 || len(references) > 0 {
+			if err = ValidateEditWindow(entry); err != nil {
+				return err
+			}
+
 			if begin != "" {
-				entry.Begin, err = entry.SetBeginFromString(begin, entry.Begin)
+				entry.Begin, err = SetEntryBegin(&entry, begin, entry.Begin)
 				if err != nil {
-					fmt.Printf("%s %+v\n", CharError, err)
-					os.Exit(1)
+					return err
 				}
 			}
 
 			if finish != "" {
-				entry.Finish, err = entry.SetFinishFromString(finish, entry.Finish)
+				entry.Finish, err = SetEntryFinish(&entry, finish, entry.Finish)
 				if err != nil {
-					fmt.Printf("%s %+v\n", CharError, err)
-					os.Exit(1)
+					return err
 				}
 			}
 
@@ -52,15 +54,48 @@ var entryCmd = &cobra.Command{
 				entry.Notes = strings.Replace(notes, "\\n", "\n", -1)
 			}
 
+			if len(attendees) > 0 {
+				entry.Attendees = zeit.ParseAttendees(attendees)
+			}
+
+			if len(tags) > 0 {
+				entry.Tags = zeit.ParseTags(tags)
+			}
+
+			if len(references) > 0 {
+				entry.References = zeit.ParseReferences(references)
+			}
+
+			if !entry.IsFinishedAfterBegan() {
+				return NewFinishBeforeBeginError(entry)
+			}
+
+			if err = ValidateProjectRules(user, entry); err != nil {
+				return err
+			}
+
 			_, err = database.UpdateEntry(user, entry)
 			if err != nil {
-				fmt.Printf("%s %+v\n", CharError, err)
-				os.Exit(1)
+				return err
+			}
+			updated = true
+		}
+
+		if IsOutputJSON() {
+			if !updated {
+				return printJSON(EntryResource{ID: entry.ID, Entry: entry})
 			}
+			RunPostHooks(HookPostEdit, entry)
+			return printJSON(EntriesOutput{Updated: entryResources([]Entry{entry})})
 		}
 
-		fmt.Printf("%s %s\n", CharInfo, entry.GetOutput(true))
-		return
+		fmt.Printf("%s %s\n", CharInfo, GetEntryOutput(entry, true))
+		if updated {
+			WarnTagBudgets(user, entry)
+			WarnProjectBudget(user, entry)
+			RunPostHooks(HookPostEdit, entry)
+		}
+		return nil
 	},
 }
 
@@ -70,14 +105,13 @@ func init() {
 	entryCmd.Flags().StringVarP(&finish, "finish", "s", "", "Update date/time the activity finished at")
 	entryCmd.Flags().StringVarP(&project, "project", "p", "", "Update activity project")
 	entryCmd.Flags().StringVarP(&notes, "notes", "n", "", "Update activity notes")
+	entryCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Attendees of the activity, e.g. a meeting (comma separated)")
+	entryCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Update activity tags (comma separated)")
+	entryCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Update ticket/URL references of the activity (comma separated)")
 	entryCmd.Flags().StringVarP(&task, "task", "t", "", "Update activity task")
 	entryCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
 
-	flagName := "task"
-	entryCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	entryCmd.ValidArgsFunction = completeEntryIDs
+	entryCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	entryCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
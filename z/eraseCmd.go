@@ -2,7 +2,6 @@ package z
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/gookit/color"
 	"github.com/spf13/cobra"
@@ -13,21 +12,37 @@ var eraseCmd = &cobra.Command{
 	Short: "Erase activity",
 	Long:  "Erase tracked activity.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 		id := args[0]
 
-		err := database.EraseEntry(user, id)
+		entry, err := database.GetEntry(user, id)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
+		}
+		if err = ValidateEditWindow(entry); err != nil {
+			return err
+		}
+
+		if err := AutoBackup(user, "erase"); err != nil {
+			return err
+		}
+
+		err = database.EraseEntry(user, id)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Erased: entryResources([]Entry{entry})})
 		}
 
 		fmt.Printf("%s erased %s\n", CharInfo, color.FgLightWhite.Render(id))
-		return
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(eraseCmd)
+	eraseCmd.ValidArgsFunction = completeEntryIDs
 }
@@ -0,0 +1,71 @@
+package z
+
+import (
+	"errors"
+)
+
+// NotFoundError is returned for activities, projects and tasks that don't
+// exist. It matches ErrNotFound.
+type NotFoundError struct {
+	Message string
+}
+
+func (nerr *NotFoundError) Error() string {
+	return nerr.Message
+}
+
+func (nerr *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// StorageError is returned when the database can't be opened, read or
+// written to. Hint tells the user how to get around the problem.
+type StorageError struct {
+	Err  error
+	Hint string
+}
+
+func (serr *StorageError) Error() string {
+	return serr.Err.Error()
+}
+
+func (serr *StorageError) Unwrap() error {
+	return serr.Err
+}
+
+// exitError only sets the exit code, for commands that reported the problem
+// themselves already.
+type exitError struct {
+	Code int
+}
+
+func (eerr *exitError) Error() string {
+	return "exit status"
+}
+
+func exitWithCode(code int) error {
+	return &exitError{Code: code}
+}
+
+// ExitCode returns the exit code zeit exits with for the error.
+func ExitCode(err error) int {
+	var eerr *exitError
+	var verr *ValidationError
+	var serr *StorageError
+	var lerr *LockedError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &eerr):
+		return eerr.Code
+	case errors.Is(err, ErrNotFound):
+		return ExitNotFound
+	case errors.As(err, &verr) && verr.Code == ValidationOverlap:
+		return ExitOverlap
+	case errors.As(err, &verr):
+		return ExitValidation
+	case errors.As(err, &serr), errors.As(err, &lerr):
+		return ExitStorage
+	}
+	return ExitError
+}
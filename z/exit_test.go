@@ -0,0 +1,29 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("failed"), ExitError},
+		{exitWithCode(ExitOverlap), ExitOverlap},
+		{ErrNotFound, ExitNotFound},
+		{&NotFoundError{Message: "no activity to resume"}, ExitNotFound},
+		{fmt.Errorf("resume: %w", &NotFoundError{}), ExitNotFound},
+		{&ValidationError{Code: ValidationOverlap}, ExitOverlap},
+		{&ValidationError{}, ExitValidation},
+		{&StorageError{Err: errors.New("read-only")}, ExitStorage},
+		{&LockedError{Path: "zeit.db"}, ExitStorage},
+	} {
+		if got := ExitCode(test.err); got != test.want {
+			t.Errorf("ExitCode(%#v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
@@ -2,11 +2,23 @@ package z
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	exportColumns        []string
+	exportDelimiter      string
+	exportDurationFormat string
+	exportNoHeader       bool
+	exportSinceLast      bool
+	exportDestination    string
 )
 
 func exportZeitJson(user string, entries []Entry) (string, error) {
@@ -33,64 +45,128 @@ var exportCmd = &cobra.Command{
 	Short: "Export tracked activities",
 	Long:  "Export tracked activities to various formats.",
 	// Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Annotations: map[string]string{AnnotationReadOnly: "true", AnnotationWritesWith: "since-last"},
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var entries []Entry
-		var err error
 
 		user := GetCurrentUser()
 
-		entries, err = database.ListEntries(user)
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err = database.ListEntriesBetween(user, sinceTime, untilTime)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
-		sinceTime, untilTime := ParseSinceUntil(since, until, listRange)
 
 		var filteredEntries []Entry
 		filteredEntries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
+		}
+
+		if exportSinceLast {
+			destination := exportDestination
+			if destination == "" {
+				destination = format
+			}
+			watermark, werr := GetExportWatermark(user, destination)
+			if werr != nil {
+				return werr
+			}
+			filteredEntries = watermark.Changed(filteredEntries)
+
+			// Only mark the entries as exported once they were written
+			exported := filteredEntries
+			defer func() {
+				if err == nil {
+					err = watermark.Update(user, destination, exported)
+				}
+			}()
+		}
+
+		rules, err := GetRedactionRules(format, redact)
+		if err != nil {
+			return err
 		}
+		filteredEntries = RedactEntries(filteredEntries, rules)
+
+		rounding, err := GetRoundingFor(RoundInExport)
+		if err != nil {
+			return err
+		}
+		filteredEntries = rounding.RoundEntries(filteredEntries)
 
 		var output string = ""
 		switch format {
 		case "zeit":
 			output, err = exportZeitJson(user, filteredEntries)
 			if err != nil {
-				fmt.Printf("%s %+v\n", CharError, err)
-				os.Exit(1)
+				return err
 			}
 		case "tyme":
 			output, err = exportTymeJson(user, filteredEntries)
 			if err != nil {
-				fmt.Printf("%s %+v\n", CharError, err)
-				os.Exit(1)
+				return err
+			}
+		case "csv":
+			output, err = ExportCSV(filteredEntries, CSVOptions{
+				Columns:        exportColumns,
+				Delimiter:      exportDelimiter,
+				DurationFormat: exportDurationFormat,
+				NoHeader:       exportNoHeader,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Print(output)
+			return nil
+		case "ics":
+			fmt.Print(ExportICS(filteredEntries))
+			return nil
+		case "xlsx":
+			if term.IsTerminal(int(os.Stdout.Fd())) {
+				return errors.New("the xlsx export is binary, redirect it to a file")
+			}
+			layout, err := GetXLSXLayout()
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("duration-format") {
+				layout.DurationFormat = exportDurationFormat
+			}
+			workbook, err := ExportXLSX(filteredEntries, layout)
+			if err != nil {
+				return err
 			}
+			os.Stdout.Write(workbook)
+			return nil
 		default:
-			fmt.Printf("%s specify an export format; see `zeit export --help` for more info\n", CharError)
-			os.Exit(1)
+			return errors.New("specify an export format; see `zeit export --help` for more info")
 		}
 
 		fmt.Printf("%s\n", output)
-		return
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(exportCmd)
-	exportCmd.Flags().StringVar(&format, "format", "zeit", "Format to export, possible values: zeit, tyme")
+	exportCmd.Flags().StringVar(&format, "format", "zeit", "Format to export, possible values: zeit, tyme, csv, ics, xlsx")
 	exportCmd.Flags().StringVar(&since, "since", "", "Date/time to start the export from")
 	exportCmd.Flags().StringVar(&until, "until", "", "Date/time to export until")
-	exportCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until that accepts: "+strings.Join(Ranges(), ", "))
+	exportCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
 	exportCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be exported")
 	exportCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be exported")
+	exportCmd.Flags().StringSliceVar(&exportColumns, "columns", []string{}, "Columns of the csv export, possible values: "+strings.Join(CSVColumns(), ", ")+" (default "+strings.Join(defaultCSVColumns, ",")+")")
+	exportCmd.Flags().StringVar(&exportDelimiter, "delimiter", ",", "Delimiter of the csv export, a single character or tab")
+	exportCmd.Flags().StringVar(&exportDurationFormat, "duration-format", DurationDecimal, "Format of durations in the csv and xlsx export, possible values: "+strings.Join(DurationFormats(), ", "))
+	exportCmd.Flags().BoolVar(&exportNoHeader, "no-header", false, "Omit the header row of the csv export")
+	exportCmd.Flags().BoolVar(&exportSinceLast, "since-last", false, "Only export activities added or changed since the last export to the destination")
+	exportCmd.Flags().StringVar(&exportDestination, "destination", "", "Name of the destination for --since-last (default is the format)")
+	exportCmd.Flags().StringSliceVar(&redact, "redact", []string{}, "Redact notes instead of using redaction.<format>, possible values: "+strings.Join(RedactionRules(), ", "))
 
-	flagName := "task"
-	exportCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	exportCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	exportCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
@@ -0,0 +1,83 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var harvestDryRun bool
+
+var exportHarvestCmd = &cobra.Command{
+	Use:   "harvest ([flags])",
+	Short: "Push activities to Harvest",
+	Long:  "Push finished activities to Harvest as time entries, using the Harvest projects and tasks mapped in harvest.projects. Pushed activities are remembered and never exported twice.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		harvest, err := NewHarvest()
+		if err != nil {
+			return err
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		rules, err := GetRedactionRules("harvest", redact)
+		if err != nil {
+			return err
+		}
+		entries = RedactEntries(entries, rules)
+
+		stats, err := harvest.Push(user, entries, harvestDryRun, func(entry Entry, err error) {
+			if err != nil {
+				fmt.Printf("%s %s could not be pushed to Harvest: %+v\n", CharError, color.FgLightWhite.Render(entry.ID), color.FgRed.Render(err))
+				return
+			}
+			verb := "pushed"
+			if harvestDryRun {
+				verb = "would push"
+			}
+			fmt.Printf("%s %s %s\n", CharMore, verb, GetEntryOutput(entry, false))
+		})
+		if err != nil {
+			return err
+		}
+
+		if stats.Unmapped > 0 {
+			fmt.Printf("%s %d activities were skipped as their project or task is not mapped in harvest.projects\n", CharInfo, stats.Unmapped)
+		}
+		if harvestDryRun {
+			fmt.Printf("%s dry run: would push %d activities, %d were pushed before\n", CharInfo, stats.Pushed, stats.Skipped)
+			return nil
+		}
+		fmt.Printf("%s pushed %d activities to Harvest, %d were pushed before\n", CharInfo, stats.Pushed, stats.Skipped)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportHarvestCmd)
+	exportHarvestCmd.Flags().StringVar(&since, "since", "", "Date/time to start the export from")
+	exportHarvestCmd.Flags().StringVar(&until, "until", "", "Date/time to export until")
+	exportHarvestCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	exportHarvestCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be exported")
+	exportHarvestCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be exported")
+	exportHarvestCmd.Flags().StringSliceVar(&redact, "redact", []string{}, "Redact notes instead of using redaction.harvest, possible values: "+strings.Join(RedactionRules(), ", "))
+	exportHarvestCmd.Flags().BoolVar(&harvestDryRun, "dry-run", false, "Only show what would be pushed")
+}
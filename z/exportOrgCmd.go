@@ -0,0 +1,46 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var exportOrgCmd = &cobra.Command{
+	Use:         "org ([flags])",
+	Short:       "Export activities as org mode clocks",
+	Long:        "Export activities as CLOCK lines of org mode, grouped under a headline per project with a sub-headline per task.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(ExportOrg(entries))
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportOrgCmd)
+	exportOrgCmd.Flags().StringVar(&since, "since", "", "Date/time to start the export from")
+	exportOrgCmd.Flags().StringVar(&until, "until", "", "Date/time to export until")
+	exportOrgCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	exportOrgCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be exported")
+	exportOrgCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be exported")
+}
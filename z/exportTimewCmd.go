@@ -0,0 +1,71 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var exportTimewJSON bool
+
+var exportTimewCmd = &cobra.Command{
+	Use:         "timew ([flags])",
+	Short:       "Export activities for Timewarrior",
+	Long:        "Export activities as Timewarrior intervals tagged with their project, task and tags, either as lines of a Timewarrior data file or as JSON like `timew export`.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		rules, err := GetRedactionRules("timew", redact)
+		if err != nil {
+			return err
+		}
+		intervals := TimewIntervals(RedactEntries(entries, rules))
+
+		if exportTimewJSON {
+			for idx := range intervals {
+				intervals[idx].ID = idx + 1
+			}
+			output, err := json.Marshal(intervals)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", output)
+			return nil
+		}
+
+		for _, interval := range intervals {
+			fmt.Println(interval.Line())
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportTimewCmd)
+	exportTimewCmd.Flags().StringVar(&since, "since", "", "Date/time to start the export from")
+	exportTimewCmd.Flags().StringVar(&until, "until", "", "Date/time to export until")
+	exportTimewCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	exportTimewCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be exported")
+	exportTimewCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be exported")
+	exportTimewCmd.Flags().StringSliceVar(&redact, "redact", []string{}, "Redact notes instead of using redaction.timew, possible values: "+strings.Join(RedactionRules(), ", "))
+	exportTimewCmd.Flags().BoolVar(&exportTimewJSON, "json", false, "Export JSON like `timew export` instead of data file lines")
+}
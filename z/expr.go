@@ -0,0 +1,527 @@
+package z
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Expr is a small expression language for computed report columns, e.g.
+// `duration * rate * 1.19` or `billable ? round(duration * rate, 2) : 0`.
+// It knows numbers, strings and booleans, the operators + - * / % == != < <=
+// > >= && || ! and ?:, parentheses, the functions in exprFunctions and the
+// functions defined by scripts.
+type Expr struct {
+	Source string
+	root   exprNode
+}
+
+type exprNode interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type exprLiteral struct {
+	value interface{}
+}
+
+type exprVariable struct {
+	name string
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+type exprConditional struct {
+	condition, then, otherwise exprNode
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+var exprFunctions = map[string]func(args []interface{}) (interface{}, error){
+	"round": func(args []interface{}) (interface{}, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("round takes 1 or 2 arguments")
+		}
+		x, err := exprNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		places := 0.0
+		if len(args) == 2 {
+			if places, err = exprNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		factor := math.Pow(10, places)
+		return math.Round(x*factor) / factor, nil
+	},
+	"ceil":  exprMath(math.Ceil),
+	"floor": exprMath(math.Floor),
+	"abs":   exprMath(math.Abs),
+	"min": func(args []interface{}) (interface{}, error) {
+		return exprFold(args, math.Min)
+	},
+	"max": func(args []interface{}) (interface{}, error) {
+		return exprFold(args, math.Max)
+	},
+	"upper": exprString(strings.ToUpper),
+	"lower": exprString(strings.ToLower),
+}
+
+func exprMath(fn func(float64) float64) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes 1 argument")
+		}
+		x, err := exprNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(x), nil
+	}
+}
+
+func exprString(fn func(string) string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes 1 argument")
+		}
+		return fn(fmt.Sprint(args[0])), nil
+	}
+}
+
+func exprFold(args []interface{}, fn func(float64, float64) float64) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("takes at least 1 argument")
+	}
+	result, err := exprNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args[1:] {
+		x, err := exprNumber(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = fn(result, x)
+	}
+	return result, nil
+}
+
+func exprNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("'%v' is not a number", value)
+}
+
+func exprTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+// ParseExpr parses an expression, which can then be evaluated any number of
+// times.
+func ParseExpr(source string) (*Expr, error) {
+	tokens, err := exprLex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := exprParser{tokens: tokens}
+	root, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s'", p.tokens[p.pos])
+	}
+
+	return &Expr{Source: source, root: root}, nil
+}
+
+// Eval evaluates the expression with the given variables, which have to be
+// float64, string or bool.
+func (expr *Expr) Eval(env map[string]interface{}) (interface{}, error) {
+	return expr.root.eval(env)
+}
+
+func exprLex(source string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case (c >= '0' && c <= '9') || c == '.':
+			start := i
+			for i < len(source) && ((source[i] >= '0' && source[i] <= '9') || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(source) && (source[i] == '_' || (source[i] >= 'a' && source[i] <= 'z') || (source[i] >= 'A' && source[i] <= 'Z') || (source[i] >= '0' && source[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(source[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, source[i:i+end+2])
+			i += end + 2
+		default:
+			if i+1 < len(source) {
+				switch two := source[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					tokens = append(tokens, two)
+					i += 2
+					continue
+				}
+			}
+			if strings.IndexByte("+-*/%<>!?:(),", c) < 0 {
+				return nil, fmt.Errorf("unexpected character '%c'", c)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) accept(tokens ...string) (string, bool) {
+	token := p.peek()
+	for _, t := range tokens {
+		if token == t {
+			p.pos++
+			return token, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(token string) error {
+	if _, ok := p.accept(token); !ok {
+		if p.peek() == "" {
+			return fmt.Errorf("expected '%s' at end of expression", token)
+		}
+		return fmt.Errorf("expected '%s' instead of '%s'", token, p.peek())
+	}
+	return nil
+}
+
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) conditional() (exprNode, error) {
+	condition, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return condition, nil
+	}
+
+	then, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return &exprConditional{condition, then, otherwise}, nil
+}
+
+func (p *exprParser) binary(level int) (exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(exprPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op, left, right}
+	}
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op, operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+
+	switch {
+	case token == "(":
+		node, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case token[0] == '"' || token[0] == '\'':
+		return &exprLiteral{token[1 : len(token)-1]}, nil
+	case (token[0] >= '0' && token[0] <= '9') || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", token)
+		}
+		return &exprLiteral{value}, nil
+	case token == "true" || token == "false":
+		return &exprLiteral{token == "true"}, nil
+	case token[0] == '_' || (token[0] >= 'a' && token[0] <= 'z') || (token[0] >= 'A' && token[0] <= 'Z'):
+		if _, ok := p.accept("("); !ok {
+			return &exprVariable{token}, nil
+		}
+		if _, ok := exprFunctions[token]; !ok && !HasScriptFunction(token) {
+			return nil, fmt.Errorf("unknown function '%s'", token)
+		}
+
+		call := &exprCall{name: token}
+		if _, ok := p.accept(")"); ok {
+			return call, nil
+		}
+		for {
+			arg, err := p.conditional()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if _, ok := p.accept(","); !ok {
+				return call, p.expect(")")
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected '%s'", token)
+}
+
+func (node *exprLiteral) eval(env map[string]interface{}) (interface{}, error) {
+	return node.value, nil
+}
+
+func (node *exprVariable) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[node.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable '%s'", node.name)
+	}
+	return value, nil
+}
+
+func (node *exprUnary) eval(env map[string]interface{}) (interface{}, error) {
+	value, err := node.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.op == "!" {
+		return !exprTruthy(value), nil
+	}
+	x, err := exprNumber(value)
+	return -x, err
+}
+
+func (node *exprConditional) eval(env map[string]interface{}) (interface{}, error) {
+	condition, err := node.condition.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if exprTruthy(condition) {
+		return node.then.eval(env)
+	}
+	return node.otherwise.eval(env)
+}
+
+func (node *exprCall) eval(env map[string]interface{}) (interface{}, error) {
+	var args []interface{}
+	for _, arg := range node.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	var value interface{}
+	var err error
+	if function, ok := exprFunctions[node.name]; ok {
+		value, err = function(args)
+	} else {
+		value, err = CallScriptFunction(node.name, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", node.name, err)
+	}
+	return value, nil
+}
+
+func (node *exprBinary) eval(env map[string]interface{}) (interface{}, error) {
+	left, err := node.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.op {
+	case "&&":
+		if !exprTruthy(left) {
+			return false, nil
+		}
+		right, err := node.right.eval(env)
+		return exprTruthy(right), err
+	case "||":
+		if exprTruthy(left) {
+			return true, nil
+		}
+		right, err := node.right.eval(env)
+		return exprTruthy(right), err
+	}
+
+	right, err := node.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	ls, lIsString := left.(string)
+	rs, rIsString := right.(string)
+	switch node.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "+":
+		if lIsString || rIsString {
+			return fmt.Sprint(left) + fmt.Sprint(right), nil
+		}
+	case "<", "<=", ">", ">=":
+		if lIsString && rIsString {
+			return exprCompare(node.op, strings.Compare(ls, rs)), nil
+		}
+	}
+
+	l, err := exprNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := exprNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+
+	switch {
+	case l < r:
+		return exprCompare(node.op, -1), nil
+	case l > r:
+		return exprCompare(node.op, 1), nil
+	}
+	return exprCompare(node.op, 0), nil
+}
+
+func exprCompare(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// FormatExprValue formats a result for output, numbers with two decimals.
+func FormatExprValue(value interface{}) string {
+	if x, ok := value.(float64); ok {
+		return strconv.FormatFloat(x, 'f', 2, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,292 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+var errFederationReadOnly = errors.New("databases added using --also-db are read-only; use them with commands that only read, like list, report or stats")
+
+// Federation is a read-only view over several storages, e.g. a work and a
+// personal database, for combined reporting without merging their data.
+// Projects and tasks of earlier storages take precedence.
+type Federation struct {
+	Storages []Storage
+}
+
+// GetFederatedDatabases returns the databases configured using --also-db or
+// as `federation.databases`.
+func GetFederatedDatabases() []string {
+	var dbfiles []string
+
+	for _, dbfile := range viper.GetStringSlice("federation.databases") {
+		if dbfile != "" {
+			dbfiles = append(dbfiles, ExpandPath(dbfile))
+		}
+	}
+
+	return dbfiles
+}
+
+func openFederation(wait bool) (func(), error) {
+	var federation Federation
+	var closers []func()
+	closeAll := func() {
+		for _, closeStorage := range closers {
+			closeStorage()
+		}
+	}
+
+	open := func(dbfile string, local bool) error {
+		if local {
+			db, closeDB, err := openDatabaseFile(dbfile, false, wait)
+			if err != nil {
+				return err
+			}
+			federation.Storages = append(federation.Storages, db)
+			closers = append(closers, closeDB)
+			return nil
+		}
+
+		if IsGitStorage(dbfile) {
+			storage, closeStorage, err := openGitStorage(dbfile, false, wait)
+			if err != nil {
+				return err
+			}
+			federation.Storages = append(federation.Storages, storage)
+			closers = append(closers, closeStorage)
+			return nil
+		}
+
+		var storage Storage
+		var err error
+		if IsPostgresDSN(dbfile) {
+			storage, err = InitPostgres(dbfile)
+		} else {
+			storage, err = InitStorage()
+		}
+		if err != nil {
+			return err
+		}
+		federation.Storages = append(federation.Storages, storage)
+		if postgres, ok := storage.(*Postgres); ok {
+			closers = append(closers, func() { postgres.Close() })
+		}
+		return nil
+	}
+
+	dbfile := viper.GetString("db")
+	if err := open(dbfile, isLocalDatabase(dbfile)); err != nil {
+		return nil, err
+	}
+	for _, dbfile := range GetFederatedDatabases() {
+		if !IsPostgresDSN(dbfile) && !fileExists(dbfile) && !(IsGitStorage(dbfile) && fileExists(GitStoragePath(dbfile))) {
+			closeAll()
+			return nil, fmt.Errorf("database %s does not exist", dbfile)
+		}
+		if err := open(dbfile, !IsPostgresDSN(dbfile) && !IsGitStorage(dbfile)); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+
+	database = &federation
+	return func() {
+		closeAll()
+		database = nil
+	}, nil
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+}
+
+func (federation *Federation) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	return "", errFederationReadOnly
+}
+
+func (federation *Federation) GetEntry(user string, entryId string) (Entry, error) {
+	for _, storage := range federation.Storages {
+		entry, err := storage.GetEntry(user, entryId)
+		if err == nil {
+			return entry, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return entry, err
+		}
+	}
+
+	return Entry{}, ErrNotFound
+}
+
+func (federation *Federation) UpdateEntry(user string, entry Entry) (string, error) {
+	return entry.ID, errFederationReadOnly
+}
+
+func (federation *Federation) FinishEntry(user string, entry Entry) (string, error) {
+	return entry.ID, errFederationReadOnly
+}
+
+func (federation *Federation) EraseEntry(user string, id string) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) GetRunningEntryId(user string) (string, error) {
+	for _, storage := range federation.Storages {
+		id, err := storage.GetRunningEntryId(user)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+
+	return "", nil
+}
+
+func (federation *Federation) SetRunningEntryId(user string, id string) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) ListEntries(user string) ([]Entry, error) {
+	var entries []Entry
+
+	for _, storage := range federation.Storages {
+		storageEntries, err := storage.ListEntries(user)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, storageEntries...)
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (federation *Federation) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	var entries []Entry
+
+	for _, storage := range federation.Storages {
+		storageEntries, err := storage.ListEntriesBetween(user, from, to)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, storageEntries...)
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (federation *Federation) ListRawEntries(user string) (map[string]string, error) {
+	rawEntries := make(map[string]string)
+
+	for _, storage := range federation.Storages {
+		storageEntries, err := storage.ListRawEntries(user)
+		if err != nil {
+			return rawEntries, err
+		}
+		for id, value := range storageEntries {
+			rawEntries[id] = value
+		}
+	}
+
+	return rawEntries, nil
+}
+
+func (federation *Federation) GetImportsSHA1List(user string) (map[string]string, error) {
+	return federation.Storages[0].GetImportsSHA1List(user)
+}
+
+func (federation *Federation) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) GetMeta(user string, key string) (string, error) {
+	return federation.Storages[0].GetMeta(user, key)
+}
+
+func (federation *Federation) SetMeta(user string, key string, value string) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) UpdateProject(user string, projectName string, project Project) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) GetProject(user string, projectName string) (Project, error) {
+	for _, storage := range federation.Storages {
+		project, err := storage.GetProject(user, projectName)
+		if err != nil || project.Name != "" {
+			return project, err
+		}
+	}
+
+	return Project{}, nil
+}
+
+func (federation *Federation) ListProjects(user string) ([]Project, error) {
+	var projects []Project
+	seen := make(map[string]bool)
+
+	for _, storage := range federation.Storages {
+		storageProjects, err := storage.ListProjects(user)
+		if err != nil {
+			return projects, err
+		}
+		for _, project := range storageProjects {
+			if !seen[zeit.GetIdFromName(project.Name)] {
+				seen[zeit.GetIdFromName(project.Name)] = true
+				projects = append(projects, project)
+			}
+		}
+	}
+
+	return projects, nil
+}
+
+func (federation *Federation) EraseProject(user string, projectName string) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) UpdateTask(user string, taskName string, task Task) error {
+	return errFederationReadOnly
+}
+
+func (federation *Federation) GetTask(user string, taskName string) (Task, error) {
+	for _, storage := range federation.Storages {
+		task, err := storage.GetTask(user, taskName)
+		if err != nil || task.Name != "" {
+			return task, err
+		}
+	}
+
+	return Task{}, nil
+}
+
+func (federation *Federation) ListTasks(user string) ([]Task, error) {
+	var tasks []Task
+	seen := make(map[string]bool)
+
+	for _, storage := range federation.Storages {
+		storageTasks, err := storage.ListTasks(user)
+		if err != nil {
+			return tasks, err
+		}
+		for _, task := range storageTasks {
+			if !seen[zeit.GetIdFromName(task.Name)] {
+				seen[zeit.GetIdFromName(task.Name)] = true
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	return tasks, nil
+}
+
+func (federation *Federation) EraseTask(user string, taskName string) error {
+	return errFederationReadOnly
+}
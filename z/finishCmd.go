@@ -1,15 +1,36 @@
 package z
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
 )
 
+var finishIdle string
+
 var finishCmd = &cobra.Command{
 	Use:   "finish",
 	Short: "Finish currently running activity",
-	Long:  "Finishing tracking of currently running activity.",
-	Run: func(cmd *cobra.Command, args []string) {
-		finishTask(FinishWithMetadata)
+	Long:  "Finishing tracking of currently running activity. Idle periods within the activity, as recorded by `zeit notify`, can be subtracted from it or split off, see --idle.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if finishIdle != "" {
+			finishIdle = strings.ToLower(finishIdle)
+			if !zeit.ContainsFold(IdleActions(), finishIdle) {
+				return fmt.Errorf("unknown --idle action '%s', possible values: %s", finishIdle, strings.Join(IdleActions(), ", "))
+			}
+		}
+
+		finished, parts, err := finishTask(FinishWithMetadata)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Finished: entryResources([]Entry{finished}), Tracked: entryResources(parts)})
+		}
+		return nil
 	},
 }
 
@@ -17,15 +38,16 @@ func init() {
 	rootCmd.AddCommand(finishCmd)
 	finishCmd.Flags().StringVarP(&begin, "begin", "b", "", "Time the activity should begin at\n\nEither in the formats 16:00 / 4:00PM \nor relative to the current time, \ne.g. -0:15 (now minus 15 minutes), +1.50 (now plus 1:30h).")
 	finishCmd.Flags().StringVarP(&finish, "finish", "s", "", "Time the activity should finish at\n\nEither in the formats 16:00 / 4:00PM \nor relative to the current time, \ne.g. -0:15 (now minus 15 minutes), +1.50 (now plus 1:30h).\nMust be after --begin time.")
+	finishCmd.Flags().StringVar(&finish, "at", "", "Same as --finish, e.g. \"2021-10-01 17:00\" to finish an activity forgotten since")
 	finishCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be assigned")
 	finishCmd.Flags().StringVarP(&notes, "notes", "n", "", "Activity notes")
+	finishCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Attendees of the activity, e.g. a meeting (comma separated)")
+	finishCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Tags of the activity (comma separated)")
+	finishCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Ticket/URL references of the activity, e.g. GH-123 (comma separated)")
 	finishCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be assigned")
+	finishCmd.Flags().BoolVar(&create, "create", false, "Create the project or task even if its name is close to an existing one")
+	finishCmd.Flags().StringVar(&finishIdle, "idle", "", "What to do with idle periods within the activity, possible values: "+strings.Join(IdleActions(), ", ")+" (default idle.action or ask)")
 
-	flagName := "task"
-	finishCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	finishCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	finishCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
@@ -0,0 +1,154 @@
+package z
+
+import (
+	"sort"
+	"time"
+)
+
+type FocusDay struct {
+	Date              string  `json:"date"`
+	TrackedSeconds    int64   `json:"trackedSeconds"`
+	Blocks            int     `json:"blocks"`
+	Interruptions     int     `json:"interruptions"`
+	AverageSeconds    int64   `json:"averageSeconds"`
+	LongestSeconds    int64   `json:"longestSeconds"`
+	DeepSeconds       int64   `json:"deepSeconds"`
+	DeepShare         float64 `json:"deepShare"`
+	Score             int     `json:"score"`
+	blockSecondsTotal int64
+}
+
+func (day *FocusDay) addBlock(block time.Duration, deep time.Duration) {
+	seconds := int64(block.Seconds())
+	if day.Blocks > 0 {
+		day.Interruptions++
+	}
+	day.Blocks++
+	day.blockSecondsTotal += seconds
+	if seconds > day.LongestSeconds {
+		day.LongestSeconds = seconds
+	}
+	if block >= deep {
+		day.DeepSeconds += seconds
+	}
+}
+
+// finalize computes the averages and the focus score of the day, half of
+// which is how close the average block comes to the deep-work threshold and
+// half the share of time spent in deep-work blocks.
+func (day *FocusDay) finalize(deep time.Duration) {
+	if day.Blocks == 0 {
+		return
+	}
+
+	day.AverageSeconds = day.blockSecondsTotal / int64(day.Blocks)
+	if day.TrackedSeconds > 0 {
+		day.DeepShare = min(float64(day.DeepSeconds)/float64(day.TrackedSeconds), 1)
+	}
+
+	average := min(float64(day.AverageSeconds)/deep.Seconds(), 1)
+	day.Score = int(average*50 + day.DeepShare*50 + 0.5)
+}
+
+// Focus are the deep-work metrics per day and over the whole period.
+type Focus struct {
+	Since                time.Time  `json:"since"`
+	Until                time.Time  `json:"until"`
+	DeepThresholdSeconds int64      `json:"deepThresholdSeconds"`
+	PauseSeconds         int64      `json:"pauseSeconds"`
+	Days                 []FocusDay `json:"days"`
+	Total                FocusDay   `json:"total"`
+}
+
+// NewFocus splits the activities between since and until into uninterrupted
+// blocks: consecutive activities on the same project and task, separated by
+// at most maxPause, are one block. Every other switch within a day counts as
+// an interruption and blocks of at least deep count as deep work.
+func NewFocus(entries []Entry, since time.Time, until time.Time, deep time.Duration, maxPause time.Duration) Focus {
+	focus := Focus{
+		Since:                since,
+		Until:                until,
+		DeepThresholdSeconds: int64(deep.Seconds()),
+		PauseSeconds:         int64(maxPause.Seconds()),
+		Days:                 []FocusDay{},
+		Total:                FocusDay{Date: "total"},
+	}
+
+	sorted := append([]Entry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Begin.Before(sorted[j].Begin) })
+
+	days := make(map[string]*FocusDay)
+	var order []string
+	var current *FocusDay
+	var blockEnd time.Time
+	var block time.Duration
+	var blockProject, blockTask string
+
+	closeBlock := func() {
+		if current == nil || blockEnd.IsZero() {
+			return
+		}
+		current.addBlock(block, deep)
+		focus.Total.addBlock(block, deep)
+		blockEnd = time.Time{}
+	}
+
+	for _, entry := range sorted {
+		begin, end := entry.Begin, entry.End()
+		if begin.Before(since) {
+			begin = since
+		}
+		if end.After(until) {
+			end = until
+		}
+		if !end.After(begin) {
+			continue
+		}
+
+		date := begin.Format(DateFormat)
+		day, ok := days[date]
+		if !ok {
+			closeBlock()
+			day = &FocusDay{Date: date}
+			days[date] = day
+			order = append(order, date)
+		}
+		current = day
+
+		tracked := int64(end.Sub(begin).Seconds())
+		day.TrackedSeconds += tracked
+		focus.Total.TrackedSeconds += tracked
+
+		if !blockEnd.IsZero() &&
+			entry.Project == blockProject && entry.Task == blockTask &&
+			begin.Sub(blockEnd) <= maxPause {
+			if end.After(blockEnd) {
+				if begin.After(blockEnd) {
+					block += end.Sub(begin)
+				} else {
+					block += end.Sub(blockEnd)
+				}
+				blockEnd = end
+			}
+			continue
+		}
+
+		closeBlock()
+		blockEnd, block = end, end.Sub(begin)
+		blockProject, blockTask = entry.Project, entry.Task
+	}
+	closeBlock()
+
+	for _, date := range order {
+		days[date].finalize(deep)
+		focus.Days = append(focus.Days, *days[date])
+	}
+	// Switching days is no interruption
+	focus.Total.Interruptions = 0
+	for _, day := range focus.Days {
+		focus.Total.Interruptions += day.Interruptions
+	}
+	focus.Total.finalize(deep)
+
+	return focus
+}
@@ -0,0 +1,114 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	focusDeep  time.Duration
+	focusPause time.Duration
+)
+
+var focusCmd = &cobra.Command{
+	Use:         "focus",
+	Short:       "Focus score and deep-work metrics",
+	Long:        "Split the tracked activities into uninterrupted blocks and show per day how many blocks and interruptions there were, the average and longest block, the share of time spent in blocks of at least --deep and a focus score from 0 to 100. Shows the current week unless --since, --until or --range are given.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if focusDeep <= 0 {
+			return fmt.Errorf("--deep has to be positive")
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		if sinceTime.IsZero() {
+			location, err := GetTimeLocation()
+			if err != nil {
+				return err
+			}
+			config := &now.Config{WeekStartDay: time.Sunday, TimeLocation: location}
+			if IsFirstWeekDayMonday() {
+				config.WeekStartDay = time.Monday
+			}
+			sinceTime = config.With(time.Now().In(location)).BeginningOfWeek()
+		}
+		if untilTime.IsZero() || untilTime.After(time.Now()) {
+			untilTime = time.Now()
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		focus := NewFocus(entries, sinceTime, untilTime, focusDeep, focusPause)
+
+		if IsOutputJSON() {
+			return printJSON(focus)
+		}
+
+		if len(focus.Days) == 0 {
+			fmt.Printf("%s nothing tracked\n", CharInfo)
+			return nil
+		}
+
+		fmt.Printf("   %-10s %8s %6s %6s %8s %8s %6s %5s\n", "", "tracked", "blocks", "breaks", "average", "longest", "deep", "score")
+		for _, day := range focus.Days {
+			fmt.Printf("   %s\n", focusRowOutput(day))
+		}
+		fmt.Printf("%s %s\n", CharInfo, focusRowOutput(focus.Total))
+		return nil
+	},
+}
+
+func focusRowOutput(day FocusDay) string {
+	seconds := func(s int64) string {
+		return fmtDuration(time.Duration(s) * time.Second)
+	}
+
+	score := fmt.Sprintf("%5d", day.Score)
+	switch {
+	case day.Score >= 70:
+		score = color.FgLightGreen.Render(score)
+	case day.Score < 40:
+		score = color.FgLightYellow.Render(score)
+	}
+
+	return fmt.Sprintf("%-10s %7sh %6d %6d %7sh %7sh %5.0f%% %s",
+		day.Date,
+		seconds(day.TrackedSeconds),
+		day.Blocks,
+		day.Interruptions,
+		seconds(day.AverageSeconds),
+		seconds(day.LongestSeconds),
+		day.DeepShare*100,
+		score,
+	)
+}
+
+func init() {
+	rootCmd.AddCommand(focusCmd)
+	focusCmd.Flags().DurationVar(&focusDeep, "deep", 90*time.Minute, "Minimum length of a block to count as deep work")
+	focusCmd.Flags().DurationVar(&focusPause, "pause", 5*time.Minute, "Longest pause between activities on the same project and task that still continues a block")
+	focusCmd.Flags().StringVar(&since, "since", "", "Date/time to compute the metrics from (default is the beginning of the week)")
+	focusCmd.Flags().StringVar(&until, "until", "", "Date/time to compute the metrics until (default is now)")
+	focusCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	focusCmd.Flags().StringVarP(&project, "project", "p", "", "Only consider activities of this project")
+	focusCmd.Flags().StringVarP(&task, "task", "t", "", "Only consider activities of this task")
+	focusCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,45 @@
+package z
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const followInterval time.Duration = time.Second
+
+// Follow releases the database and re-renders the output every second,
+// reopening the database each time so that changes of other zeit processes
+// show up. On a terminal the output is updated in place, otherwise it is
+// printed again whenever it changed.
+func Follow(render func() (string, error)) error {
+	closeDatabase()
+
+	inPlace := !IsOutputJSON() && term.IsTerminal(int(os.Stdout.Fd()))
+
+	var previous string
+	for {
+		closeStorage, err := OpenStorage(false, true)
+		if err != nil {
+			return err
+		}
+
+		output, err := render()
+		closeStorage()
+		if err != nil {
+			return err
+		}
+
+		if output != previous {
+			if inPlace {
+				fmt.Print("\033[H\033[2J")
+			}
+			fmt.Print(output)
+			previous = output
+		}
+
+		time.Sleep(followInterval)
+	}
+}
@@ -0,0 +1,123 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// WorkingHours are the hours of the day within which untracked time counts
+// as a gap, configured as
+//
+//	workingHours:
+//	  begin: "09:00"
+//	  end: "17:00"
+//	  days: [monday, tuesday, wednesday, thursday, friday]
+type WorkingHours struct {
+	Begin time.Duration
+	End   time.Duration
+	Days  [7]bool
+}
+
+func parseClock(key string, value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s', use e.g. 09:00", key, value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// GetWorkingHours returns the configured working hours, by default 09:00 to
+// 17:00 from Monday to Friday.
+func GetWorkingHours() (WorkingHours, error) {
+	var hours WorkingHours
+	var err error
+
+	begin, end := "09:00", "17:00"
+	if viper.IsSet("workingHours.begin") {
+		begin = viper.GetString("workingHours.begin")
+	}
+	if viper.IsSet("workingHours.end") {
+		end = viper.GetString("workingHours.end")
+	}
+	if hours.Begin, err = parseClock("workingHours.begin", begin); err != nil {
+		return hours, err
+	}
+	if hours.End, err = parseClock("workingHours.end", end); err != nil {
+		return hours, err
+	}
+	if hours.End <= hours.Begin {
+		return hours, fmt.Errorf("workingHours.end has to be after workingHours.begin")
+	}
+
+	days := []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+	if viper.IsSet("workingHours.days") {
+		days = viper.GetStringSlice("workingHours.days")
+	}
+	for _, name := range days {
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			return hours, fmt.Errorf("unknown weekday '%s' in workingHours.days", name)
+		}
+		hours.Days[weekday] = true
+	}
+
+	return hours, nil
+}
+
+type Gap struct {
+	Begin time.Time `json:"begin"`
+	End   time.Time `json:"end"`
+}
+
+func (gap *Gap) Duration() time.Duration {
+	return gap.End.Sub(gap.Begin)
+}
+
+// FindGaps returns the untracked periods of at least minimum within the
+// working hours from since until until.
+func FindGaps(entries []Entry, hours WorkingHours, since time.Time, until time.Time, minimum time.Duration) []Gap {
+	var gaps []Gap
+
+	sorted := append([]Entry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Begin.Before(sorted[j].Begin) })
+
+	for day := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location()); day.Before(until); day = day.AddDate(0, 0, 1) {
+		if !hours.Days[day.Weekday()] {
+			continue
+		}
+
+		cursor, end := day.Add(hours.Begin), day.Add(hours.End)
+		if cursor.Before(since) {
+			cursor = since
+		}
+		if end.After(until) {
+			end = until
+		}
+
+		for _, entry := range sorted {
+			if !cursor.Before(end) {
+				break
+			}
+			if !entry.End().After(cursor) {
+				continue
+			}
+			if !entry.Begin.Before(end) {
+				break
+			}
+			if entry.Begin.After(cursor) && entry.Begin.Sub(cursor) >= minimum {
+				gaps = append(gaps, Gap{Begin: cursor, End: entry.Begin})
+			}
+			cursor = entry.End()
+		}
+
+		if cursor.Before(end) && end.Sub(cursor) >= minimum {
+			gaps = append(gaps, Gap{Begin: cursor, End: end})
+		}
+	}
+
+	return gaps
+}
@@ -0,0 +1,169 @@
+package z
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gapsMin  time.Duration
+	gapsFill bool
+)
+
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Find untracked time",
+	Long:  "List untracked periods within the working hours, today unless --since, --until or --range are given. Using --fill, an activity can be tracked for every gap one by one.",
+	Annotations: map[string]string{
+		AnnotationReadOnly:   "true",
+		AnnotationWritesWith: "fill",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		hours, err := GetWorkingHours()
+		if err != nil {
+			return err
+		}
+
+		var sinceTime, untilTime time.Time
+		if listRange != "" {
+			if sinceTime, untilTime, err = ParseTimeRange(since, until, listRange); err != nil {
+				return err
+			}
+		} else {
+			sinceTime, untilTime = now.BeginningOfDay(), time.Now()
+			if since != "" {
+				if sinceTime, err = ParseTime(since, time.Time{}); err != nil {
+					return NewInvalidTimeError("since", since)
+				}
+				// Days like monday begin at midnight, not the current time
+				if !strings.Contains(since, ":") {
+					sinceTime = now.With(sinceTime).BeginningOfDay()
+				}
+			}
+			if until != "" {
+				if untilTime, err = ParseTime(until, time.Time{}); err != nil {
+					return NewInvalidTimeError("until", until)
+				}
+			}
+		}
+		if untilTime.After(time.Now()) {
+			untilTime = time.Now()
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		gaps := FindGaps(entries, hours, sinceTime, untilTime, gapsMin)
+
+		if IsOutputJSON() {
+			if gaps == nil {
+				gaps = []Gap{}
+			}
+			return printJSON(gaps)
+		}
+
+		if len(gaps) == 0 {
+			fmt.Printf("%s no gaps of %s or more\n", CharInfo, gapsMin)
+			return nil
+		}
+
+		var total time.Duration
+		for _, gap := range gaps {
+			total += gap.Duration()
+			fmt.Printf("%s %s\n", CharMore, gapOutput(gap))
+		}
+		fmt.Printf("%s %sh untracked in %d gaps\n", CharInfo, color.FgLightWhite.Render(fmtDuration(total)), len(gaps))
+
+		if gapsFill {
+			if !IsInteractive() {
+				return fmt.Errorf("--fill requires an interactive terminal")
+			}
+			fillGaps(user, gaps)
+		}
+		return nil
+	},
+}
+
+func gapOutput(gap Gap) string {
+	return fmt.Sprintf("%s from %s to %s (%sh)",
+		gap.Begin.Format("Mon 2006-01-02"),
+		color.FgLightWhite.Render(gap.Begin.Format("15:04")),
+		color.FgLightWhite.Render(gap.End.Format("15:04")),
+		color.FgLightWhite.Render(fmtDuration(gap.Duration())),
+	)
+}
+
+// fillGaps asks for the project, task and notes of every gap and tracks an
+// activity for it; an empty project skips a gap.
+func fillGaps(user string, gaps []Gap) {
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(prompt string) (string, bool) {
+		fmt.Printf("  %s: ", prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(line), true
+	}
+
+	var filled int
+	for _, gap := range gaps {
+		fmt.Printf("\n%s %s\n", CharMore, gapOutput(gap))
+
+		project, ok := ask("project (empty to skip, q to quit)")
+		if !ok || project == "q" {
+			break
+		}
+		if project == "" {
+			continue
+		}
+		task, ok := ask("task")
+		if !ok {
+			break
+		}
+		notes, ok := ask("notes")
+		if !ok {
+			break
+		}
+
+		entry := Entry{Begin: gap.Begin, Finish: gap.End, Project: project, Task: task, Notes: notes, User: user}
+		if err := ValidateProjectRules(user, entry); err != nil {
+			fmt.Printf("%s %+v\n", CharError, err)
+			continue
+		}
+		if err := ValidateStrict(user, entry); err != nil {
+			fmt.Printf("%s %+v\n", CharError, err)
+			continue
+		}
+		if _, err := database.AddEntry(user, entry, false); err != nil {
+			fmt.Printf("%s %+v\n", CharError, err)
+			continue
+		}
+		fmt.Print(GetEntryOutputForTrack(entry, false, false))
+		filled++
+	}
+
+	fmt.Printf("\n%s filled %d of %d gaps\n", CharInfo, filled, len(gaps))
+}
+
+func init() {
+	rootCmd.AddCommand(gapsCmd)
+	gapsCmd.Flags().DurationVar(&gapsMin, "min", 15*time.Minute, "Only show gaps at least this long")
+	gapsCmd.Flags().StringVar(&since, "since", "", "Date/time to look for gaps from, e.g. monday (default is today)")
+	gapsCmd.Flags().StringVar(&until, "until", "", "Date/time to look for gaps until (default is now)")
+	gapsCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	gapsCmd.Flags().BoolVar(&gapsFill, "fill", false, "Track an activity for every gap, asking for its project, task and notes")
+	gapsCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,15 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "git integration",
+	Long:  "Track the branches of git repositories: `zeit track --from-git` tracks the branch checked out, and `zeit git hook post-checkout --install` installs a hook reminding of or switching tracking when switching branches, as configured in git.switch (remind or auto).",
+}
+
+func init() {
+	rootCmd.AddCommand(gitCmd)
+}
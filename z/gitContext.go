@@ -0,0 +1,285 @@
+package z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultGitTicketPattern matches ticket IDs like ABC-123 in branch names.
+const defaultGitTicketPattern string = `[A-Z][A-Z0-9]+-[0-9]+`
+
+// gitHookMarker identifies the git hooks installed by zeit, which may be
+// replaced when installing them again.
+const gitHookMarker string = "# Installed by zeit"
+
+const defaultGitTrailer string = "Time-spent"
+
+var ErrGitDetached = errors.New("not on a branch")
+
+// GitContext is what an activity is about while working in a git
+// repository: the repository name as project and the branch, or the ticket
+// ID found in it, as task.
+type GitContext struct {
+	Repository string
+	Branch     string
+	Ticket     string
+}
+
+func (context GitContext) Project() string {
+	return context.Repository
+}
+
+func (context GitContext) Task() string {
+	if context.Ticket != "" {
+		return context.Ticket
+	}
+	return context.Branch
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetGitTicketPattern returns git.ticket, the regular expression extracting
+// ticket IDs from branch names. If it has a group, the first one is the ID.
+func GetGitTicketPattern() (*regexp.Regexp, error) {
+	pattern := viper.GetString("git.ticket")
+	if pattern == "" {
+		pattern = defaultGitTicketPattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git.ticket: %v", err)
+	}
+	return re, nil
+}
+
+// GetGitContext returns the context of the repository the directory is in.
+func GetGitContext(dir string) (GitContext, error) {
+	var context GitContext
+
+	toplevel, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return context, err
+	}
+	context.Repository = filepath.Base(toplevel)
+
+	if context.Branch, err = runGit(dir, "symbolic-ref", "--short", "-q", "HEAD"); err != nil || context.Branch == "" {
+		return context, ErrGitDetached
+	}
+
+	re, err := GetGitTicketPattern()
+	if err != nil {
+		return context, err
+	}
+	if match := re.FindStringSubmatch(context.Branch); match != nil {
+		context.Ticket = match[0]
+		if len(match) > 1 && match[1] != "" {
+			context.Ticket = match[1]
+		}
+	}
+
+	return context, nil
+}
+
+// IsTrackingGitContext returns whether the entry is about the context.
+func IsTrackingGitContext(entry Entry, context GitContext) bool {
+	return strings.EqualFold(entry.Project, context.Project()) && strings.EqualFold(entry.Task, context.Task())
+}
+
+// GetGitHooksDir returns the directory git runs the hooks of the repository
+// the directory is in from, respecting core.hooksPath.
+func GetGitHooksDir(dir string) (string, error) {
+	hooksDir, err := runGit(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(hooksDir) {
+		return filepath.Abs(filepath.Join(dir, hooksDir))
+	}
+	return hooksDir, nil
+}
+
+// GitHookScript returns the git hook running the zeit command with the
+// arguments git passed to the hook.
+func GitHookScript(description string, command string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("#!/bin/sh\n%s\n# %s\nexec %s git %s \"$@\"\n", gitHookMarker, description, shellQuote(executable), command), nil
+}
+
+// InstallGitHook installs the hook into the repository the directory is in,
+// refusing to replace hooks not installed by zeit.
+func InstallGitHook(dir string, name string, script string) (string, error) {
+	hooksDir, err := GetGitHooksDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(hooksDir, name)
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), gitHookMarker) {
+		return path, fmt.Errorf("%s exists already, add the output of `zeit git hook %s` to it instead", path, name)
+	}
+
+	return path, os.WriteFile(path, []byte(script), 0755)
+}
+
+// GitPostCheckout handles switching branches as a post-checkout hook. Unless
+// the activity running is about the branch already, it reminds of tracking
+// the branch or, with git.switch set to auto and an activity of the
+// repository running, switches to tracking the branch.
+func GitPostCheckout(user string) (string, error) {
+	context, err := GetGitContext("")
+	if errors.Is(err, ErrGitDetached) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return "", err
+	}
+	if runningEntryId == "" {
+		return fmt.Sprintf("%s not tracking, run `zeit track --from-git` to track %s on %s\n", CharInfo, context.Task(), context.Project()), nil
+	}
+
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return "", err
+	}
+	if IsTrackingGitContext(runningEntry, context) {
+		return "", nil
+	}
+
+	if viper.GetString("git.switch") != GitSwitchAuto || !strings.EqualFold(runningEntry.Project, context.Project()) {
+		return fmt.Sprintf("%s still tracking %s on %s, run `zeit switch --from-git` to track %s on %s\n", CharInfo, runningEntry.Task, runningEntry.Project, context.Task(), context.Project()), nil
+	}
+
+	if runningEntry, err = finishRunningEntry(user, runningEntryId); err != nil {
+		return "", err
+	}
+
+	entry, err := NewEntry("", "", "", context.Project(), context.Task(), user)
+	if err != nil {
+		return "", err
+	}
+	if context.Ticket != "" {
+		entry.References = []string{context.Ticket}
+	}
+	if entry, err = trackRunningEntry(user, entry); err != nil {
+		return "", err
+	}
+
+	return GetEntryOutputForFinish(runningEntry) + GetEntryOutputForTrack(entry, true, false), nil
+}
+
+// getGitLastCommit returns when the last commit of the repository the
+// directory is in was made, or zero for repositories without commits.
+func getGitLastCommit(dir string) (time.Time, error) {
+	if _, err := runGit(dir, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return time.Time{}, nil
+	}
+
+	output, err := runGit(dir, "log", "-1", "--format=%ct", "HEAD")
+	if err != nil {
+		return time.Time{}, err
+	}
+	timestamp, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(timestamp, 0), nil
+}
+
+// GetGitTimeSpent returns the time tracked on the task of the activity
+// running, or of the latest one, since the last commit of the repository the
+// directory is in.
+func GetGitTimeSpent(user string, dir string) (time.Duration, error) {
+	latest, err := ListLatestEntries(user, time.Time{}, time.Time{}, func(Entry) bool { return true }, 0, 1)
+	if err != nil || len(latest) == 0 {
+		return 0, err
+	}
+
+	since, err := getGitLastCommit(dir)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	entries, err := database.ListEntriesBetween(user, since, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	var total time.Duration
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.Project, latest[0].Project) || !strings.EqualFold(entry.Task, latest[0].Task) {
+			continue
+		}
+		begin, end := entry.Begin, entry.End()
+		if begin.Before(since) {
+			begin = since
+		}
+		if end.After(now) {
+			end = now
+		}
+		if end.After(begin) {
+			total += end.Sub(begin)
+		}
+	}
+	return total, nil
+}
+
+// GitPrepareCommitMsg handles a commit as a prepare-commit-msg hook, adding a
+// trailer like `Time-spent: 1:25h` to the commit message in the file with
+// the time tracked on the current task since the last commit. Merges,
+// squashes and amended commits are left alone.
+func GitPrepareCommitMsg(user string, file string, source string) error {
+	if source == "merge" || source == "squash" || source == "commit" {
+		return nil
+	}
+
+	total, err := GetGitTimeSpent(user, "")
+	if err != nil || total < time.Minute {
+		return err
+	}
+
+	trailer := viper.GetString("git.trailer")
+	if trailer == "" {
+		trailer = defaultGitTrailer
+	}
+
+	_, err = runGit("", "interpret-trailers", "--in-place", "--if-exists", "replace", "--trailer", fmt.Sprintf("%s: %sh", trailer, fmtDuration(total)), file)
+	return err
+}
@@ -0,0 +1,52 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var gitHookInstall bool
+
+var gitHookCmd = &cobra.Command{
+	Use:         "hook [name]",
+	Short:       "Generate git hooks",
+	Long:        "Print the git hook, or install it into the repository in the current directory using --install. The post-checkout hook reminds of tracking the branch switched to, or switches tracking to it with git.switch set to auto. The prepare-commit-msg hook adds a Time-spent trailer with the time tracked on the current task since the last commit to commit messages.",
+	Args:        cobra.ExactArgs(1),
+	ValidArgs:   []string{GitHookPostCheckout, GitHookPrepareCommitMsg},
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var description string
+		switch args[0] {
+		case GitHookPostCheckout:
+			description = "Reminds of tracking the branch switched to in zeit"
+		case GitHookPrepareCommitMsg:
+			description = "Adds the time tracked in zeit since the last commit to the message"
+		default:
+			return fmt.Errorf("unknown hook '%s', possible values: %s, %s", args[0], GitHookPostCheckout, GitHookPrepareCommitMsg)
+		}
+
+		script, err := GitHookScript(description, args[0])
+		if err != nil {
+			return err
+		}
+
+		if !gitHookInstall {
+			fmt.Print(script)
+			return nil
+		}
+
+		path, err := InstallGitHook("", args[0], script)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s installed the hook as %s\n", CharInfo, color.FgLightWhite.Render(path))
+		return nil
+	},
+}
+
+func init() {
+	gitCmd.AddCommand(gitHookCmd)
+	gitHookCmd.Flags().BoolVar(&gitHookInstall, "install", false, "Install the hook into the repository in the current directory")
+}
@@ -0,0 +1,32 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var gitPostCheckoutCmd = &cobra.Command{
+	Use:    "post-checkout",
+	Short:  "Handle switching branches",
+	Long:   "Run by the post-checkout hook of git with the previous and the new HEAD and whether a branch was checked out, see `zeit git hook post-checkout`.",
+	Args:   cobra.MaximumNArgs(3),
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Checking out files does not switch branches
+		if len(args) == 3 && args[2] != "1" {
+			return nil
+		}
+
+		output, err := GitPostCheckout(GetCurrentUser())
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	gitCmd.AddCommand(gitPostCheckoutCmd)
+}
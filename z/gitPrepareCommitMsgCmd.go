@@ -0,0 +1,33 @@
+package z
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var gitPrepareCommitMsgCmd = &cobra.Command{
+	Use:         "prepare-commit-msg",
+	Short:       "Add the time spent to a commit message",
+	Long:        "Run by the prepare-commit-msg hook of git with the file containing the commit message and its source, see `zeit git hook prepare-commit-msg`.",
+	Args:        cobra.RangeArgs(1, 3),
+	Hidden:      true,
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var source string
+		if len(args) > 1 {
+			source = args[1]
+		}
+
+		// Failing would abort the commit
+		if err := GitPrepareCommitMsg(GetCurrentUser(), args[0], source); err != nil {
+			fmt.Fprintf(os.Stderr, "%s could not add the time spent: %+v\n", CharError, err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	gitCmd.AddCommand(gitPrepareCommitMsgCmd)
+}
@@ -0,0 +1,812 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// GitStorage keeps the data of every user as plain-text files in a git
+// repository, configured as `db: git:/path/to/repository`:
+//
+//	<user>/entries/2026/10/2026-10-14.txt  activities by the day they began
+//	<user>/projects/<id>.json
+//	<user>/tasks/<id>.json
+//	<user>/running                         ID of the running activity
+//	<user>/meta.json                       bookkeeping data of integrations
+//	<user>/imports.json
+//
+// Every change is committed right away, so that history, sync (using `git
+// pull` and `git push`) and resolving conflicts are left to git.
+type GitStorage struct {
+	Path string
+
+	users    map[string]*gitUser
+	batching bool
+	changes  []string
+}
+
+type gitUser struct {
+	entries  map[string]Entry
+	running  string
+	projects map[string]Project
+	tasks    map[string]Task
+	meta     map[string]string
+	imports  map[string]string
+}
+
+const gitStoragePrefix string = "git:"
+
+func IsGitStorage(dbfile string) bool {
+	return strings.HasPrefix(dbfile, gitStoragePrefix)
+}
+
+func GitStoragePath(dbfile string) string {
+	return ExpandPath(strings.TrimPrefix(dbfile, gitStoragePrefix))
+}
+
+func InitGitStorage(path string) (*GitStorage, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+
+	storage := GitStorage{Path: path, users: make(map[string]*gitUser)}
+	if _, err := os.Stat(filepath.Join(path, ".git")); errors.Is(err, fs.ErrNotExist) {
+		if _, err = storage.git("init", "--quiet"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &storage, nil
+}
+
+func openGitStorage(dbfile string, exclusive bool, wait bool) (*GitStorage, func(), error) {
+	storage, err := InitGitStorage(GitStoragePath(dbfile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The lock lives within .git so that it never gets committed
+	lock, err := AcquireLock(filepath.Join(storage.Path, ".git", "zeit.lock"), exclusive, wait)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return storage, func() { lock.Release() }, nil
+}
+
+func (storage *GitStorage) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = storage.Path
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(out), fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+		}
+		return string(out), fmt.Errorf("git %s: %v", args[0], err)
+	}
+
+	return string(out), nil
+}
+
+// commit commits all changes, unless changes are being batched.
+func (storage *GitStorage) commit(message string) error {
+	storage.changes = append(storage.changes, message)
+	if storage.batching {
+		return nil
+	}
+
+	return storage.flush()
+}
+
+func (storage *GitStorage) flush() error {
+	messages := storage.changes
+	storage.changes = nil
+
+	args := []string{"add", "--all", "--"}
+	for user := range storage.users {
+		if _, err := os.Stat(storage.path(user)); err == nil {
+			args = append(args, storage.userDir(user))
+		}
+	}
+	if len(args) == 3 {
+		return nil
+	}
+	if _, err := storage.git(args...); err != nil {
+		return err
+	}
+	if _, err := storage.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+
+	subject := "zeit: " + messages[0]
+	if len(messages) > 1 {
+		subject = fmt.Sprintf("zeit: %s and %d more changes", messages[0], len(messages)-1)
+	}
+
+	args = []string{"commit", "--quiet", "--no-verify", "--message", subject}
+	if email, _ := storage.git("config", "user.email"); strings.TrimSpace(email) == "" {
+		args = append([]string{"-c", "user.name=zeit", "-c", "user.email=zeit@localhost"}, args...)
+	}
+	_, err := storage.git(args...)
+	return err
+}
+
+// Batch runs fn while deferring the commit until fn returned, so that bulk
+// operations end up as a single commit.
+func (storage *GitStorage) Batch(fn func() error) error {
+	storage.batching = true
+	err := fn()
+	storage.batching = false
+
+	if len(storage.changes) > 0 {
+		if ferr := storage.flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
+
+func (storage *GitStorage) userDir(user string) string {
+	return url.PathEscape(user)
+}
+
+func (storage *GitStorage) path(user string, elem ...string) string {
+	return filepath.Join(append([]string{storage.Path, storage.userDir(user)}, elem...)...)
+}
+
+func (storage *GitStorage) dayPath(user string, day string) string {
+	return storage.path(user, "entries", day[0:4], day[5:7], day+".txt")
+}
+
+func entryDay(entry Entry) string {
+	return entry.Begin.Format(DateFormat)
+}
+
+// user returns the data of the user, reading it from the repository once.
+func (storage *GitStorage) user(user string) (*gitUser, error) {
+	if data, ok := storage.users[user]; ok {
+		return data, nil
+	}
+
+	data := &gitUser{
+		entries:  make(map[string]Entry),
+		projects: make(map[string]Project),
+		tasks:    make(map[string]Task),
+		meta:     make(map[string]string),
+		imports:  make(map[string]string),
+	}
+
+	err := filepath.WalkDir(storage.path(user, "entries"), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".txt" {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entries, err := parseGitDay(content)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, entry := range entries {
+			data.entries[entry.ID] = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for dir, read := range map[string]func(id string, content []byte) error{
+		"projects": func(id string, content []byte) error {
+			var project Project
+			err := json.Unmarshal(content, &project)
+			data.projects[id] = project
+			return err
+		},
+		"tasks": func(id string, content []byte) error {
+			var task Task
+			err := json.Unmarshal(content, &task)
+			data.tasks[id] = task
+			return err
+		},
+	} {
+		files, err := os.ReadDir(storage.path(user, dir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+			content, err := os.ReadFile(storage.path(user, dir, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if err = read(strings.TrimSuffix(file.Name(), ".json"), content); err != nil {
+				return nil, fmt.Errorf("%s: %v", storage.path(user, dir, file.Name()), err)
+			}
+		}
+	}
+
+	for file, v := range map[string]interface{}{"meta.json": &data.meta, "imports.json": &data.imports} {
+		content, err := os.ReadFile(storage.path(user, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = json.Unmarshal(content, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", storage.path(user, file), err)
+		}
+	}
+
+	running, err := os.ReadFile(storage.path(user, "running"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	data.running = strings.TrimSpace(string(running))
+
+	storage.users[user] = data
+	return data, nil
+}
+
+func writeGitFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+func removeGitFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func writeGitJSON(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeGitFile(path, append(content, '\n'))
+}
+
+// writeDays rewrites the files of the given days from the user's entries.
+func (storage *GitStorage) writeDays(user string, data *gitUser, days ...string) error {
+	for _, day := range days {
+		var entries []Entry
+		for _, entry := range data.entries {
+			if entryDay(entry) == day {
+				entries = append(entries, entry)
+			}
+		}
+
+		var err error
+		if len(entries) == 0 {
+			err = removeGitFile(storage.dayPath(user, day))
+		} else {
+			err = writeGitFile(storage.dayPath(user, day), formatGitDay(day, entries))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (storage *GitStorage) writeRunning(user string, data *gitUser) error {
+	if data.running == "" {
+		return removeGitFile(storage.path(user, "running"))
+	}
+	return writeGitFile(storage.path(user, "running"), []byte(data.running+"\n"))
+}
+
+func entrySummary(entry Entry) string {
+	summary := entryDay(entry) + " " + entry.Project
+	if entry.Task != "" {
+		summary += "/" + entry.Task
+	}
+	return summary
+}
+
+// formatGitDay formats the entries of a day, ordered by their begin:
+//
+//	[<id>]
+//	begin: 2026-10-14T09:00:00+02:00
+//	finish: 2026-10-14T10:30:00+02:00
+//	project: acme
+//	tags: admin, email
+//	notes:
+//	  indented notes, possibly
+//	  spanning several lines
+func formatGitDay(day string, entries []Entry) []byte {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Begin.Equal(entries[j].Begin) {
+			return entries[i].Begin.Before(entries[j].Begin)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", day)
+
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "\n[%s]\n", entry.ID)
+		fmt.Fprintf(&buf, "begin: %s\n", entry.Begin.Format(time.RFC3339Nano))
+		if !entry.Finish.IsZero() {
+			fmt.Fprintf(&buf, "finish: %s\n", entry.Finish.Format(time.RFC3339Nano))
+		}
+		for _, field := range [][2]string{
+			{"project", entry.Project},
+			{"task", entry.Task},
+			{"user", entry.User},
+			{"attendees", strings.Join(entry.Attendees, ", ")},
+			{"tags", strings.Join(entry.Tags, ", ")},
+			{"references", strings.Join(entry.References, ", ")},
+		} {
+			if field[1] != "" {
+				fmt.Fprintf(&buf, "%s: %s\n", field[0], field[1])
+			}
+		}
+		if entry.Notes != "" {
+			buf.WriteString("notes:\n")
+			for _, line := range strings.Split(entry.Notes, "\n") {
+				buf.WriteString(strings.TrimRight("  "+line, " ") + "\n")
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func splitGitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func parseGitDay(content []byte) ([]Entry, error) {
+	var entries []Entry
+	var entry *Entry
+	var notes []string
+	inNotes := false
+
+	finishEntry := func() {
+		if entry != nil {
+			entry.Notes = strings.TrimRight(strings.Join(notes, "\n"), "\n")
+			entries = append(entries, *entry)
+		}
+		notes = nil
+		inNotes = false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+
+		if inNotes && (line == "" || strings.HasPrefix(line, "  ")) {
+			notes = append(notes, strings.TrimPrefix(line, "  "))
+			continue
+		}
+		inNotes = false
+
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			finishEntry()
+			entry = &Entry{ID: strings.TrimSpace(line[1 : len(line)-1])}
+			continue
+		case entry == nil:
+			return entries, fmt.Errorf("line %d: expected [id]", number)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return entries, fmt.Errorf("line %d: expected key: value", number)
+		}
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch strings.TrimSpace(key) {
+		case "begin":
+			entry.Begin, err = time.Parse(time.RFC3339Nano, value)
+		case "finish":
+			entry.Finish, err = time.Parse(time.RFC3339Nano, value)
+		case "project":
+			entry.Project = value
+		case "task":
+			entry.Task = value
+		case "user":
+			entry.User = value
+		case "attendees":
+			entry.Attendees = splitGitList(value)
+		case "tags":
+			entry.Tags = splitGitList(value)
+		case "references":
+			entry.References = splitGitList(value)
+		case "notes":
+			inNotes = true
+			if value != "" {
+				notes = append(notes, value)
+			}
+		default:
+			err = fmt.Errorf("unknown key '%s'", key)
+		}
+		if err != nil {
+			return entries, fmt.Errorf("line %d: %v", number, err)
+		}
+	}
+	finishEntry()
+
+	return entries, scanner.Err()
+}
+
+func (storage *GitStorage) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return "", err
+	}
+
+	entry.ID = NewID()
+	data.entries[entry.ID] = entry
+	if err = storage.writeDays(user, data, entryDay(entry)); err != nil {
+		return entry.ID, err
+	}
+	if setRunning {
+		data.running = entry.ID
+		if err = storage.writeRunning(user, data); err != nil {
+			return entry.ID, err
+		}
+	}
+
+	return entry.ID, storage.commit("add " + entrySummary(entry))
+}
+
+func (storage *GitStorage) GetEntry(user string, entryId string) (Entry, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry, ok := data.entries[entryId]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+func (storage *GitStorage) UpdateEntry(user string, entry Entry) (string, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return entry.ID, err
+	}
+
+	days := []string{entryDay(entry)}
+	if previous, ok := data.entries[entry.ID]; ok && entryDay(previous) != days[0] {
+		days = append(days, entryDay(previous))
+	}
+
+	data.entries[entry.ID] = entry
+	if err = storage.writeDays(user, data, days...); err != nil {
+		return entry.ID, err
+	}
+
+	return entry.ID, storage.commit("update " + entrySummary(entry))
+}
+
+func (storage *GitStorage) FinishEntry(user string, entry Entry) (string, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return entry.ID, err
+	}
+
+	if data.running == "" {
+		return entry.ID, errors.New("no currently running entry found!")
+	}
+	if data.running != entry.ID {
+		return entry.ID, errors.New("specified entry is not currently running!")
+	}
+
+	days := []string{entryDay(entry)}
+	if previous, ok := data.entries[entry.ID]; ok && entryDay(previous) != days[0] {
+		days = append(days, entryDay(previous))
+	}
+
+	data.running = ""
+	data.entries[entry.ID] = entry
+	if err = storage.writeRunning(user, data); err != nil {
+		return entry.ID, err
+	}
+	if err = storage.writeDays(user, data, days...); err != nil {
+		return entry.ID, err
+	}
+
+	return entry.ID, storage.commit("finish " + entrySummary(entry))
+}
+
+func (storage *GitStorage) EraseEntry(user string, id string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	entry, ok := data.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(data.entries, id)
+	if data.running == id {
+		data.running = ""
+		if err = storage.writeRunning(user, data); err != nil {
+			return err
+		}
+	}
+	if err = storage.writeDays(user, data, entryDay(entry)); err != nil {
+		return err
+	}
+
+	return storage.commit("erase " + entrySummary(entry))
+}
+
+func (storage *GitStorage) GetRunningEntryId(user string) (string, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return "", err
+	}
+	return data.running, nil
+}
+
+func (storage *GitStorage) SetRunningEntryId(user string, id string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	data.running = id
+	if err = storage.writeRunning(user, data); err != nil {
+		return err
+	}
+
+	if entry, ok := data.entries[id]; ok {
+		return storage.commit("resume " + entrySummary(entry))
+	}
+	return storage.commit("stop tracking")
+}
+
+func (storage *GitStorage) ListEntries(user string) ([]Entry, error) {
+	return storage.ListEntriesBetween(user, time.Time{}, time.Time{})
+}
+
+func (storage *GitStorage) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	var entries []Entry
+
+	data, err := storage.user(user)
+	if err != nil {
+		return entries, err
+	}
+
+	for _, entry := range data.entries {
+		if entry.Overlaps(from, to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+	return entries, nil
+}
+
+func (storage *GitStorage) ListRawEntries(user string) (map[string]string, error) {
+	rawEntries := make(map[string]string)
+
+	data, err := storage.user(user)
+	if err != nil {
+		return rawEntries, err
+	}
+
+	for id, entry := range data.entries {
+		entryJson, err := json.Marshal(entry)
+		if err != nil {
+			return rawEntries, err
+		}
+		rawEntries[id] = string(entryJson)
+	}
+
+	return rawEntries, nil
+}
+
+func (storage *GitStorage) GetImportsSHA1List(user string) (map[string]string, error) {
+	sha1List := make(map[string]string)
+
+	data, err := storage.user(user)
+	if err != nil {
+		return sha1List, err
+	}
+
+	for sha1, id := range data.imports {
+		sha1List[sha1] = id
+	}
+	return sha1List, nil
+}
+
+func (storage *GitStorage) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	data.imports = sha1List
+	if err = writeGitJSON(storage.path(user, "imports.json"), data.imports); err != nil {
+		return err
+	}
+
+	return storage.commit("update imports")
+}
+
+func (storage *GitStorage) GetMeta(user string, key string) (string, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return "", err
+	}
+	return data.meta[key], nil
+}
+
+func (storage *GitStorage) SetMeta(user string, key string, value string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	data.meta[key] = value
+	if err = writeGitJSON(storage.path(user, "meta.json"), data.meta); err != nil {
+		return err
+	}
+
+	return storage.commit("update " + key)
+}
+
+func (storage *GitStorage) UpdateProject(user string, projectName string, project Project) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	projectId := zeit.GetIdFromName(projectName)
+	data.projects[projectId] = project
+	if err = writeGitJSON(storage.path(user, "projects", projectId+".json"), project); err != nil {
+		return err
+	}
+
+	return storage.commit("update project " + projectName)
+}
+
+func (storage *GitStorage) GetProject(user string, projectName string) (Project, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return Project{}, err
+	}
+	return data.projects[zeit.GetIdFromName(projectName)], nil
+}
+
+func (storage *GitStorage) ListProjects(user string) ([]Project, error) {
+	var projects []Project
+
+	data, err := storage.user(user)
+	if err != nil {
+		return projects, err
+	}
+
+	for _, project := range data.projects {
+		projects = append(projects, project)
+	}
+
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+func (storage *GitStorage) EraseProject(user string, projectName string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	projectId := zeit.GetIdFromName(projectName)
+	if _, ok := data.projects[projectId]; !ok {
+		return ErrNotFound
+	}
+
+	delete(data.projects, projectId)
+	if err = removeGitFile(storage.path(user, "projects", projectId+".json")); err != nil {
+		return err
+	}
+
+	return storage.commit("erase project " + projectName)
+}
+
+func (storage *GitStorage) UpdateTask(user string, taskName string, task Task) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	taskId := zeit.GetIdFromName(taskName)
+	data.tasks[taskId] = task
+	if err = writeGitJSON(storage.path(user, "tasks", taskId+".json"), task); err != nil {
+		return err
+	}
+
+	return storage.commit("update task " + taskName)
+}
+
+func (storage *GitStorage) GetTask(user string, taskName string) (Task, error) {
+	data, err := storage.user(user)
+	if err != nil {
+		return Task{}, err
+	}
+	return data.tasks[zeit.GetIdFromName(taskName)], nil
+}
+
+func (storage *GitStorage) ListTasks(user string) ([]Task, error) {
+	var tasks []Task
+
+	data, err := storage.user(user)
+	if err != nil {
+		return tasks, err
+	}
+
+	for _, task := range data.tasks {
+		tasks = append(tasks, task)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks, nil
+}
+
+func (storage *GitStorage) EraseTask(user string, taskName string) error {
+	data, err := storage.user(user)
+	if err != nil {
+		return err
+	}
+
+	taskId := zeit.GetIdFromName(taskName)
+	if _, ok := data.tasks[taskId]; !ok {
+		return ErrNotFound
+	}
+
+	delete(data.tasks, taskId)
+	if err = removeGitFile(storage.path(user, "tasks", taskId+".json")); err != nil {
+		return err
+	}
+
+	return storage.commit("erase task " + taskName)
+}
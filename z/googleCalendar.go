@@ -0,0 +1,638 @@
+package z
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+const defaultGoogleCalendarURL string = "https://www.googleapis.com/calendar/v3"
+
+const defaultGoogleAuthURL string = "https://accounts.google.com/o/oauth2/v2/auth"
+
+const defaultGoogleTokenURL string = "https://oauth2.googleapis.com/token"
+
+const googleCalendarScope string = "https://www.googleapis.com/auth/calendar.events"
+
+// googleLoginTimeout is how long `zeit calendar login` waits for the
+// browser to come back.
+const googleLoginTimeout time.Duration = 5 * time.Minute
+
+// GoogleCalendarPull is the calendar events are pulled from into
+// activities, e.g. `primary`.
+type GoogleCalendarPull struct {
+	Calendar string   `mapstructure:"calendar"`
+	Project  string   `mapstructure:"project"`
+	Keywords []string `mapstructure:"keywords"`
+}
+
+// GoogleCalendar syncs finished activities as events to the calendar
+// `google.calendar` and pulls events from `google.pull.calendar` into
+// activities, using the Google Calendar API instead of CalDAV.
+type GoogleCalendar struct {
+	URL          string
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Calendar     string
+	Projects     []string
+	Pull         GoogleCalendarPull
+
+	token *googleToken
+
+	// syncToken and upcoming are kept once the pulled events were imported
+	syncToken string
+	upcoming  map[string]Entry
+}
+
+type googleToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+type googleTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type googleEventTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type googleAttendee struct {
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+type googleExtendedProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+}
+
+type googleEvent struct {
+	ID                 string                    `json:"id,omitempty"`
+	ICalUID            string                    `json:"iCalUID,omitempty"`
+	Status             string                    `json:"status,omitempty"`
+	Summary            string                    `json:"summary,omitempty"`
+	Description        string                    `json:"description,omitempty"`
+	Start              googleEventTime           `json:"start"`
+	End                googleEventTime           `json:"end"`
+	Transparency       string                    `json:"transparency,omitempty"`
+	Attendees          []googleAttendee          `json:"attendees,omitempty"`
+	ExtendedProperties *googleExtendedProperties `json:"extendedProperties,omitempty"`
+}
+
+type googleEventList struct {
+	Summary       string        `json:"summary"`
+	Items         []googleEvent `json:"items"`
+	NextPageToken string        `json:"nextPageToken"`
+	NextSyncToken string        `json:"nextSyncToken"`
+}
+
+type googleError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewGoogleCalendar configures the Google Calendar client from `google.*`.
+// Without a google.clientId, Google Calendar is not used and nil is
+// returned.
+func NewGoogleCalendar() (*GoogleCalendar, error) {
+	google := GoogleCalendar{
+		URL:          strings.TrimSuffix(viper.GetString("google.url"), "/"),
+		AuthURL:      viper.GetString("google.authUrl"),
+		TokenURL:     viper.GetString("google.tokenUrl"),
+		ClientID:     os.ExpandEnv(viper.GetString("google.clientId")),
+		ClientSecret: os.ExpandEnv(viper.GetString("google.clientSecret")),
+		Calendar:     viper.GetString("google.calendar"),
+		Projects:     viper.GetStringSlice("google.projects"),
+	}
+	if google.ClientID == "" {
+		return nil, nil
+	}
+	if google.URL == "" {
+		google.URL = defaultGoogleCalendarURL
+	}
+	if google.AuthURL == "" {
+		google.AuthURL = defaultGoogleAuthURL
+	}
+	if google.TokenURL == "" {
+		google.TokenURL = defaultGoogleTokenURL
+	}
+
+	if err := viper.UnmarshalKey("google.pull", &google.Pull); err != nil {
+		return nil, fmt.Errorf("invalid google.pull: %v", err)
+	}
+	if google.Calendar == "" && google.Pull.Calendar == "" {
+		return nil, errors.New("please configure a Google calendar to push to as google.calendar and/or one to pull from as google.pull.calendar, e.g. primary")
+	}
+
+	return &google, nil
+}
+
+func (google *GoogleCalendar) Name() string {
+	return "Google Calendar"
+}
+
+func (google *GoogleCalendar) CanPush() bool {
+	return google.Calendar != ""
+}
+
+func getGoogleTokenPath() (string, error) {
+	if path := viper.GetString("google.tokenFile"); path != "" {
+		return path, nil
+	}
+
+	dataHome := GetDataHome()
+	if dataHome == "" {
+		return "", errors.New("could not find the data directory, please configure google.tokenFile")
+	}
+	return filepath.Join(dataHome, "zeit", "google-token.json"), nil
+}
+
+func (google *GoogleCalendar) saveToken(token googleToken) error {
+	path, err := getGoogleTokenPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	google.token = &token
+	return writeFileAtomically(path, content)
+}
+
+// requestToken posts the form to the token endpoint, keeping the refresh
+// token if a new one was not issued.
+func (google *GoogleCalendar) requestToken(form url.Values, refreshToken string) error {
+	form.Set("client_id", google.ClientID)
+	form.Set("client_secret", google.ClientSecret)
+
+	req, err := http.NewRequest(http.MethodPost, google.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var response googleTokenResponse
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("POST %s: %s", req.URL.Redacted(), res.Status)
+	}
+	if response.Error != "" || response.AccessToken == "" {
+		return fmt.Errorf("Google: %s %s", response.Error, response.ErrorDescription)
+	}
+
+	if response.RefreshToken != "" {
+		refreshToken = response.RefreshToken
+	}
+	return google.saveToken(googleToken{
+		AccessToken:  response.AccessToken,
+		RefreshToken: refreshToken,
+		Expiry:       time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+	})
+}
+
+// accessToken returns the access token stored by `zeit calendar login`,
+// refreshing it when it is about to expire.
+func (google *GoogleCalendar) accessToken() (string, error) {
+	if google.token == nil {
+		path, err := getGoogleTokenPath()
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.New("please log in to Google using `zeit calendar login`")
+		} else if err != nil {
+			return "", err
+		}
+
+		var token googleToken
+		if err = json.Unmarshal(content, &token); err != nil {
+			return "", fmt.Errorf("could not read the Google token %s: %v", path, err)
+		}
+		google.token = &token
+	}
+
+	if time.Until(google.token.Expiry) < time.Minute {
+		if google.token.RefreshToken == "" {
+			return "", errors.New("the Google token expired, please log in again using `zeit calendar login`")
+		}
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", google.token.RefreshToken)
+		if err := google.requestToken(form, google.token.RefreshToken); err != nil {
+			return "", err
+		}
+	}
+
+	return google.token.AccessToken, nil
+}
+
+func randomURLString() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// Login authorizes zeit to access the calendars of a Google account using
+// OAuth for installed apps: the user signs in using the browser, which
+// returns to a server listening on the loopback interface. The tokens are
+// stored in google.tokenFile.
+func (google *GoogleCalendar) Login(prompt func(authURL string)) error {
+	verifier, err := randomURLString()
+	if err != nil {
+		return err
+	}
+	state, err := randomURLString()
+	if err != nil {
+		return err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	redirectURI := fmt.Sprintf("http://%s", listener.Addr().String())
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("state") != state:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		case query.Get("error") != "":
+			fmt.Fprintln(w, "zeit was not authorized, you can close this window.")
+			select {
+			case errs <- fmt.Errorf("Google: %s", query.Get("error")):
+			default:
+			}
+		default:
+			fmt.Fprintln(w, "zeit was authorized, you can close this window.")
+			select {
+			case codes <- query.Get("code"):
+			default:
+			}
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	params := url.Values{}
+	params.Set("client_id", google.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", googleCalendarScope)
+	params.Set("access_type", "offline")
+	params.Set("prompt", "consent")
+	params.Set("state", state)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+	prompt(google.AuthURL + "?" + params.Encode())
+
+	var code string
+	select {
+	case code = <-codes:
+	case err = <-errs:
+		return err
+	case <-time.After(googleLoginTimeout):
+		return errors.New("timed out waiting for the authorization")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("code_verifier", verifier)
+	form.Set("redirect_uri", redirectURI)
+	return google.requestToken(form, "")
+}
+
+func (google *GoogleCalendar) request(method string, path string, query url.Values, body interface{}, v interface{}) (int, error) {
+	token, err := google.accessToken()
+	if err != nil {
+		return 0, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	target := google.URL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var message googleError
+		json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(&message)
+		return res.StatusCode, fmt.Errorf("%s %s: %s %s", method, req.URL.Redacted(), res.Status, message.Error.Message)
+	}
+
+	if v == nil || res.StatusCode == http.StatusNoContent {
+		return res.StatusCode, nil
+	}
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(v)
+}
+
+func (google *GoogleCalendar) stateKey(user string) string {
+	return user + " " + google.Calendar + " " + google.Pull.Calendar
+}
+
+func (google *GoogleCalendar) eventsPath(calendar string) string {
+	return "/calendars/" + url.PathEscape(calendar) + "/events"
+}
+
+// googleEventID returns the ID of the event of an activity: Google requires
+// base32hex characters, which the hex digits of the UUID are.
+func googleEventID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
+
+func (google *GoogleCalendar) event(entry Entry) ([]byte, string, error) {
+	event := googleEvent{
+		ID:           googleEventID(entry.ID),
+		Summary:      icsSummary(entry),
+		Description:  entry.Notes,
+		Start:        googleEventTime{DateTime: entry.Begin.Format(time.RFC3339)},
+		End:          googleEventTime{DateTime: entry.Finish.Format(time.RFC3339)},
+		Transparency: "transparent",
+		ExtendedProperties: &googleExtendedProperties{
+			Private: map[string]string{"zeit": entry.ID},
+		},
+	}
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, fmt.Sprintf("%x", sha1.Sum(content)), nil
+}
+
+// put creates the event with the ID derived from the activity, or updates
+// it if it exists already, e.g. when the sync state was lost.
+func (google *GoogleCalendar) put(entry Entry, pushed calendarPushed, exists bool, event []byte) (calendarPushed, error) {
+	pushed.Href = googleEventID(entry.ID)
+	body := json.RawMessage(event)
+
+	if !exists {
+		status, err := google.request(http.MethodPost, google.eventsPath(google.Calendar), nil, body, nil)
+		if status != http.StatusConflict {
+			return pushed, err
+		}
+	}
+
+	_, err := google.request(http.MethodPut, google.eventsPath(google.Calendar)+"/"+url.PathEscape(pushed.Href), nil, body, nil)
+	return pushed, err
+}
+
+func (google *GoogleCalendar) delete(pushed calendarPushed) error {
+	status, err := google.request(http.MethodDelete, google.eventsPath(google.Calendar)+"/"+url.PathEscape(pushed.Href), nil, nil, nil)
+	if status == http.StatusNotFound || status == http.StatusGone {
+		return nil
+	}
+	return err
+}
+
+// Push syncs the finished activities of the projects in google.projects, or
+// of all projects, to the calendar google.calendar.
+func (google *GoogleCalendar) Push(user string, entries []Entry, dryRun bool, report func(Entry, string, error)) (CalendarSyncStats, error) {
+	if google.Calendar == "" {
+		return CalendarSyncStats{}, nil
+	}
+
+	state, file, err := loadCalendarState("google", google.stateKey(user))
+	if err != nil {
+		return CalendarSyncStats{}, err
+	}
+
+	var pushing []Entry
+	for _, entry := range entries {
+		if len(google.Projects) == 0 || zeit.ContainsFold(google.Projects, entry.Project) {
+			pushing = append(pushing, entry)
+		}
+	}
+	return pushCalendar(user, google, state, file, pushing, dryRun, report)
+}
+
+// listEvents lists the events changed since the sync token, or all events
+// since the given time without one, following all pages. Google expires
+// sync tokens with 410 Gone, in which case all events are listed again.
+func (google *GoogleCalendar) listEvents(syncToken string, since time.Time) (googleEventList, error) {
+	var events googleEventList
+
+	query := url.Values{}
+	query.Set("singleEvents", "true")
+	query.Set("maxResults", "250")
+	if syncToken != "" {
+		query.Set("syncToken", syncToken)
+	} else {
+		query.Set("timeMin", since.Format(time.RFC3339))
+	}
+
+	for {
+		var page googleEventList
+		status, err := google.request(http.MethodGet, google.eventsPath(google.Pull.Calendar), query, nil, &page)
+		if status == http.StatusGone && syncToken != "" {
+			return google.listEvents("", since)
+		} else if err != nil {
+			return events, err
+		}
+
+		events.Summary = page.Summary
+		events.Items = append(events.Items, page.Items...)
+		if page.NextPageToken == "" {
+			events.NextSyncToken = page.NextSyncToken
+			return events, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// entry returns the timed event as activity of the project.
+func (google *GoogleCalendar) entry(user string, project string, event googleEvent) (Entry, bool) {
+	if event.Start.DateTime == "" || event.End.DateTime == "" {
+		return Entry{}, false
+	}
+	begin, err := time.Parse(time.RFC3339, event.Start.DateTime)
+	if err != nil {
+		return Entry{}, false
+	}
+	finish, err := time.Parse(time.RFC3339, event.End.DateTime)
+	if err != nil || !finish.After(begin) {
+		return Entry{}, false
+	}
+
+	var attendees []string
+	for _, attendee := range event.Attendees {
+		name := attendee.DisplayName
+		if name == "" {
+			name = attendee.Email
+		}
+		attendees = append(attendees, name)
+	}
+
+	uid := event.ICalUID
+	if uid == "" {
+		uid = event.ID
+	}
+
+	return Entry{
+		Begin:     begin,
+		Finish:    finish,
+		Project:   project,
+		Task:      event.Summary,
+		Notes:     event.Description,
+		User:      user,
+		Attendees: zeit.ParseAttendees(attendees),
+		SHA1:      eventSHA1(uid, begin),
+	}, true
+}
+
+// PullEntries returns the events of google.pull.calendar that finished
+// until then as activities. Only the events changed since the last sync are
+// listed, or the ones since the given time on the first sync; events that
+// are not finished yet are kept until they are. Their project is
+// google.pull.project, or else the name of the calendar as mapped in
+// ics.calendars.
+func (google *GoogleCalendar) PullEntries(user string, since time.Time, until time.Time) ([]Entry, []error, error) {
+	if google.Pull.Calendar == "" {
+		return nil, nil, nil
+	}
+
+	state, _, err := loadCalendarState("google", google.stateKey(user))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, err := google.listEvents(state.SyncToken, since)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	project := google.Pull.Project
+	if project == "" {
+		calendars, err := GetICSCalendarProjects(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		project = events.Summary
+		if mapped, ok := calendars[strings.ToLower(events.Summary)]; ok {
+			project = mapped
+		}
+	}
+
+	for _, event := range events.Items {
+		if event.Status == "cancelled" {
+			delete(state.Upcoming, event.ID)
+			continue
+		}
+		// Events pushed by zeit
+		if event.ExtendedProperties != nil && event.ExtendedProperties.Private["zeit"] != "" {
+			continue
+		}
+		if !containsKeyword(event.Summary+"\n"+event.Description, google.Pull.Keywords) {
+			delete(state.Upcoming, event.ID)
+			continue
+		}
+
+		if entry, ok := google.entry(user, project, event); ok {
+			state.Upcoming[event.ID] = entry
+		} else {
+			delete(state.Upcoming, event.ID)
+		}
+	}
+
+	var pulled []Entry
+	for id, entry := range state.Upcoming {
+		if entry.Finish.After(until) {
+			continue
+		}
+		delete(state.Upcoming, id)
+		if _, ok := state.Pulled[entry.SHA1]; !ok {
+			pulled = append(pulled, entry)
+		}
+	}
+	sort.Slice(pulled, func(i, j int) bool { return pulled[i].Begin.Before(pulled[j].Begin) })
+
+	google.syncToken = events.NextSyncToken
+	google.upcoming = state.Upcoming
+	return pulled, nil, nil
+}
+
+// RecordPulled remembers the pulled activities along with the sync token
+// and the events that are not finished yet.
+func (google *GoogleCalendar) RecordPulled(user string, entries []Entry, sha1List map[string]string) error {
+	if google.Pull.Calendar == "" {
+		return nil
+	}
+
+	state, file, err := loadCalendarState("google", google.stateKey(user))
+	if err != nil {
+		return err
+	}
+	recordPulled(&state, entries, sha1List)
+	state.SyncToken = google.syncToken
+	state.Upcoming = google.upcoming
+	return file.Save(state)
+}
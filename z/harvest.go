@@ -0,0 +1,211 @@
+package z
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const harvestMetaKey string = "harvest:pushed"
+
+const defaultHarvestURL string = "https://api.harvestapp.com"
+
+// HarvestProject maps a zeit project to a Harvest project, and its tasks to
+// Harvest tasks, falling back to the default task.
+type HarvestProject struct {
+	Project int64            `mapstructure:"project"`
+	Task    int64            `mapstructure:"task"`
+	Tasks   map[string]int64 `mapstructure:"tasks"`
+}
+
+type Harvest struct {
+	URL      string
+	Token    string
+	Account  string
+	Projects map[string]HarvestProject
+}
+
+type HarvestPushStats struct {
+	Pushed   int
+	Skipped  int
+	Unmapped int
+}
+
+type harvestTimeEntry struct {
+	ID        int64   `json:"id,omitempty"`
+	ProjectID int64   `json:"project_id"`
+	TaskID    int64   `json:"task_id"`
+	SpentDate string  `json:"spent_date"`
+	Hours     float64 `json:"hours"`
+	Notes     string  `json:"notes,omitempty"`
+}
+
+// NewHarvest configures the Harvest API client and the project mapping from
+// `harvest.*`.
+func NewHarvest() (*Harvest, error) {
+	harvest := Harvest{
+		URL:     strings.TrimSuffix(viper.GetString("harvest.url"), "/"),
+		Token:   os.ExpandEnv(viper.GetString("harvest.token")),
+		Account: os.ExpandEnv(viper.GetString("harvest.account")),
+	}
+	if harvest.URL == "" {
+		harvest.URL = defaultHarvestURL
+	}
+	if harvest.Token == "" || harvest.Account == "" {
+		return nil, errors.New("please configure the personal access token and account ID of Harvest as harvest.token and harvest.account")
+	}
+
+	if err := viper.UnmarshalKey("harvest.projects", &harvest.Projects); err != nil {
+		return nil, fmt.Errorf("invalid harvest.projects: %v", err)
+	}
+	if len(harvest.Projects) == 0 {
+		return nil, errors.New("please map zeit projects to Harvest projects in harvest.projects")
+	}
+
+	return &harvest, nil
+}
+
+func (harvest *Harvest) request(method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, harvest.URL+"/v2"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	req.Header.Set("Authorization", "Bearer "+harvest.Token)
+	req.Header.Set("Harvest-Account-Id", harvest.Account)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, req.URL.Redacted(), res.Status, strings.TrimSpace(string(message)))
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// timeEntry maps an activity to a Harvest time entry, if its project is
+// mapped. Viper lowercases the keys of the mapping, so lookups are
+// case-insensitive.
+func (harvest *Harvest) timeEntry(entry Entry) (harvestTimeEntry, bool) {
+	project, ok := harvest.Projects[strings.ToLower(entry.Project)]
+	if !ok || project.Project == 0 {
+		return harvestTimeEntry{}, false
+	}
+
+	task := project.Task
+	if id, ok := project.Tasks[strings.ToLower(entry.Task)]; ok {
+		task = id
+	}
+	if task == 0 {
+		return harvestTimeEntry{}, false
+	}
+
+	notes := entry.Task
+	if entry.Notes != "" {
+		notes = entry.Notes
+		if entry.Task != "" {
+			notes = entry.Task + ": " + entry.Notes
+		}
+	}
+
+	return harvestTimeEntry{
+		ProjectID: project.Project,
+		TaskID:    task,
+		SpentDate: entry.Begin.Format(DateFormat),
+		Hours:     math.Round(entry.Finish.Sub(entry.Begin).Hours()*100) / 100,
+		Notes:     notes,
+	}, true
+}
+
+func getHarvestPushed(user string) (map[string]int64, error) {
+	pushed := make(map[string]int64)
+
+	value, err := database.GetMeta(user, harvestMetaKey)
+	if err != nil || value == "" {
+		return pushed, err
+	}
+
+	if err = json.Unmarshal([]byte(value), &pushed); err != nil {
+		return pushed, errors.New("could not read the list of activities pushed to Harvest")
+	}
+	return pushed, nil
+}
+
+// Push creates Harvest time entries for the finished activities, except for
+// ones pushed before. Activities whose project or task isn't mapped are
+// skipped.
+func (harvest *Harvest) Push(user string, entries []Entry, dryRun bool, report func(Entry, error)) (HarvestPushStats, error) {
+	var stats HarvestPushStats
+
+	pushed, err := getHarvestPushed(user)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			continue
+		}
+		if _, ok := pushed[entry.ID]; ok {
+			stats.Skipped++
+			continue
+		}
+
+		timeEntry, ok := harvest.timeEntry(entry)
+		if !ok {
+			stats.Unmapped++
+			continue
+		}
+
+		if !dryRun {
+			var created harvestTimeEntry
+			if err = harvest.request(http.MethodPost, "/time_entries", timeEntry, &created); err != nil {
+				report(entry, err)
+				continue
+			}
+			pushed[entry.ID] = created.ID
+
+			// Save after every time entry, so that an interrupted push does not
+			// export time entries twice
+			value, err := json.Marshal(pushed)
+			if err != nil {
+				return stats, err
+			}
+			if err = database.SetMeta(user, harvestMetaKey, string(value)); err != nil {
+				return stats, err
+			}
+		}
+		stats.Pushed++
+		report(entry, nil)
+	}
+
+	return stats, nil
+}
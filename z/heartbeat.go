@@ -0,0 +1,110 @@
+package z
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Heartbeat is sent by editor plugins with the file being edited and,
+// optionally, the project they consider it part of.
+type Heartbeat struct {
+	File    string `json:"file,omitempty"`
+	Project string `json:"project,omitempty"`
+	Editor  string `json:"editor,omitempty"`
+}
+
+type HeartbeatResult struct {
+	Project        string `json:"project"`
+	Running        bool   `json:"running"`
+	RunningProject string `json:"runningProject,omitempty"`
+	Diverged       bool   `json:"diverged"`
+	Suggestion     string `json:"suggestion,omitempty"`
+	Command        string `json:"command,omitempty"`
+}
+
+// HeartbeatProject returns the project of a heartbeat, which is either the
+// one sent, the one configured for the longest matching path prefix in
+// `heartbeat.projects` or the name of the git repository containing the file.
+func HeartbeatProject(heartbeat Heartbeat) string {
+	if heartbeat.Project != "" {
+		return heartbeat.Project
+	}
+	if heartbeat.File == "" {
+		return ""
+	}
+
+	file := filepath.Clean(ExpandPath(heartbeat.File))
+
+	var project string
+	var longest int
+	for prefix, name := range viper.GetStringMapString("heartbeat.projects") {
+		prefix = filepath.Clean(ExpandPath(prefix))
+		if (file == prefix || strings.HasPrefix(file, prefix+string(filepath.Separator))) && len(prefix) > longest {
+			project, longest = name, len(prefix)
+		}
+	}
+	if project != "" {
+		return project
+	}
+
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return filepath.Base(dir)
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
+
+// CheckHeartbeat compares the project of a heartbeat with the running
+// activity and suggests switching if they differ.
+func CheckHeartbeat(user string, heartbeat Heartbeat) (HeartbeatResult, error) {
+	result := HeartbeatResult{Project: HeartbeatProject(heartbeat)}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return result, err
+	}
+	if runningEntryId == "" {
+		if result.Project != "" {
+			result.Suggestion = fmt.Sprintf("You are working on %s, but not tracking anything. Start tracking %s?", result.Project, result.Project)
+			result.Command = fmt.Sprintf("zeit track --project %q", result.Project)
+		}
+		return result, nil
+	}
+
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return result, err
+	}
+
+	result.Running = true
+	result.RunningProject = runningEntry.Project
+	if result.Project != "" && !strings.EqualFold(result.Project, runningEntry.Project) {
+		result.Diverged = true
+		result.Suggestion = fmt.Sprintf("You are working on %s, but tracking %s. Switch to %s?", result.Project, runningEntry.Project, result.Project)
+		result.Command = fmt.Sprintf("zeit switch --project %q", result.Project)
+	}
+
+	return result, nil
+}
+
+func (server *Server) heartbeat(user string, r *http.Request) (int, interface{}, error) {
+	var heartbeat Heartbeat
+	if err := decodeBody(r, &heartbeat); err != nil {
+		return 0, nil, err
+	}
+
+	if heartbeat.File == "" && heartbeat.Project == "" {
+		return 0, nil, newAPIError(http.StatusBadRequest, "file or project is required")
+	}
+
+	result, err := CheckHeartbeat(user, heartbeat)
+	return http.StatusOK, result, err
+}
@@ -0,0 +1,135 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// heatmapColors are the intensities of days with tracked time, from the
+// least to the most tracked.
+var heatmapColors = []string{"#9be9a8", "#40c463", "#30a14e", "#216e39"}
+
+// Heatmap is the time tracked per day between From and Until.
+type Heatmap struct {
+	From  time.Time
+	Until time.Time
+	Days  map[string]time.Duration
+}
+
+// NewHeatmap sums up the entries per day between from and until, splitting
+// activities spanning midnight across the days.
+func NewHeatmap(entries []Entry, from time.Time, until time.Time) Heatmap {
+	heatmap := Heatmap{From: from, Until: until, Days: make(map[string]time.Duration)}
+
+	for _, entry := range entries {
+		for day := now.With(entry.Begin).BeginningOfDay(); day.Before(entry.End()); day = day.AddDate(0, 0, 1) {
+			if day.Before(from) || !day.Before(until) {
+				continue
+			}
+			heatmap.Days[day.Format(DateFormat)] += zeit.ClippedDuration(entry, day, day.AddDate(0, 0, 1))
+		}
+	}
+
+	return heatmap
+}
+
+// GetDays returns the time tracked on every day with any tracked time, in
+// order.
+func (heatmap *Heatmap) GetDays() []StatsDay {
+	days := []StatsDay{}
+	for date, tracked := range heatmap.Days {
+		days = append(days, StatsDay{Date: date, Seconds: int64(tracked.Seconds())})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
+
+// level returns the intensity of a day relative to the most tracked day,
+// 0 being nothing tracked at all.
+func (heatmap *Heatmap) level(tracked time.Duration, most time.Duration) int {
+	if tracked <= 0 || most <= 0 {
+		return 0
+	}
+	return min(int(float64(tracked)/float64(most)*float64(len(heatmapColors)-1)+0.5), len(heatmapColors)-1) + 1
+}
+
+func heatmapCell(level int) string {
+	if level == 0 {
+		return "· "
+	}
+	return GetColorFnFromHex(heatmapColors[level-1])("■") + " "
+}
+
+// GetOutput renders the heatmap as a grid with a row per weekday and a column
+// per week.
+func (heatmap *Heatmap) GetOutput() string {
+	var output strings.Builder
+
+	weekStart := time.Sunday
+	if IsFirstWeekDayMonday() {
+		weekStart = time.Monday
+	}
+	first := heatmap.From.AddDate(0, 0, -((int(heatmap.From.Weekday()) - int(weekStart) + 7) % 7))
+
+	var most, total time.Duration
+	for _, tracked := range heatmap.Days {
+		most = max(most, tracked)
+		total += tracked
+	}
+
+	var weeks []time.Time
+	for week := first; week.Before(heatmap.Until); week = week.AddDate(0, 0, 7) {
+		weeks = append(weeks, week)
+	}
+
+	var months strings.Builder
+	for idx, week := range weeks {
+		name := ""
+		if idx == 0 {
+			name = heatmap.From.Format("Jan")
+		}
+		for weekday := 0; weekday < 7; weekday++ {
+			if day := week.AddDate(0, 0, weekday); day.Day() == 1 && !day.Before(heatmap.From) && day.Before(heatmap.Until) {
+				name = day.Format("Jan")
+			}
+		}
+		if name != "" && months.Len() <= idx*2 {
+			months.WriteString(strings.Repeat(" ", idx*2-months.Len()) + name)
+		}
+	}
+	fmt.Fprintf(&output, "\n      %s\n", months.String())
+
+	for weekday := 0; weekday < 7; weekday++ {
+		label := ""
+		if weekday%2 == 1 {
+			label = first.AddDate(0, 0, weekday).Format("Mon")
+		}
+		fmt.Fprintf(&output, "  %-3s ", label)
+		for _, week := range weeks {
+			day := week.AddDate(0, 0, weekday)
+			if day.Before(heatmap.From) || !day.Before(heatmap.Until) {
+				output.WriteString("  ")
+				continue
+			}
+			output.WriteString(heatmapCell(heatmap.level(heatmap.Days[day.Format(DateFormat)], most)))
+		}
+		output.WriteString("\n")
+	}
+
+	output.WriteString("\n      less ")
+	for level := 0; level <= len(heatmapColors); level++ {
+		output.WriteString(heatmapCell(level))
+	}
+	days := "days"
+	if len(heatmap.Days) == 1 {
+		days = "day"
+	}
+	fmt.Fprintf(&output, "more   %sh on %d %s, at most %sh a day\n\n", fmtDuration(total), len(heatmap.Days), days, fmtDuration(most))
+
+	return output.String()
+}
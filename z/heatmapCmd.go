@@ -0,0 +1,53 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/spf13/cobra"
+)
+
+var heatmapYear int
+
+var heatmapCmd = &cobra.Command{
+	Use:         "heatmap",
+	Short:       "Heatmap of tracked time",
+	Long:        "Render the hours tracked per day as a grid of weeks, the darker a day the more was tracked on it. Shows the last 52 weeks, or a whole year using --year.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		var from, until time.Time
+		if heatmapYear != 0 {
+			from = time.Date(heatmapYear, time.January, 1, 0, 0, 0, 0, time.Local)
+			until = from.AddDate(1, 0, 0)
+		} else {
+			until = now.BeginningOfDay().AddDate(0, 0, 1)
+			from = until.AddDate(0, 0, -52*7)
+		}
+
+		entries, err := database.ListEntriesBetween(user, from, until)
+		if err != nil {
+			return err
+		}
+		entries, err = GetFilteredEntries(entries, project, "", time.Time{}, time.Time{})
+		if err != nil {
+			return err
+		}
+
+		heatmap := NewHeatmap(entries, from, until)
+		if IsOutputJSON() {
+			return printJSON(map[string]interface{}{"from": from, "until": until, "days": heatmap.GetDays()})
+		}
+		fmt.Print(heatmap.GetOutput())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(heatmapCmd)
+	heatmapCmd.Flags().IntVar(&heatmapYear, "year", 0, "Year to show, e.g. 2024 (default is the last 52 weeks)")
+	heatmapCmd.Flags().StringVarP(&project, "project", "p", "", "Only show the time tracked on this project")
+	heatmapCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
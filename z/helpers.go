@@ -3,31 +3,23 @@ package z
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"math"
 	"os"
 	"os/exec"
 	"os/user"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jinzhu/now"
-	"github.com/markusmobius/go-dateparser"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/viper"
 )
 
-func TimeFormats() []string {
-	return []string{
-		`^\d{1,2}:\d{1,2}(am|pm)$`,     // Absolute twelve hour format
-		`^\d{1,2}:\d{1,2}$`,            // Absolute twenty four hour format
-		`^([+-])(\d{1,2}):(\d{1,2})$`,  // Relative hour:minute format
-		`^([+-])(\d{1,2})\.(\d{1,2})$`, // Relative hour.fraction format
+func GetCurrentUser() string {
+	if viper.GetString("user") != "" {
+		return viper.GetString("user")
 	}
-}
 
-func GetCurrentUser() string {
 	user, err := user.Current()
 	if err != nil {
 		return "unknown"
@@ -36,101 +28,25 @@ func GetCurrentUser() string {
 	return user.Username
 }
 
-func GetTimeFormat(timeStr string) int {
-	var matched bool
-	var regerr error
-
-	for timeFormatId, timeFormat := range TimeFormats() {
-		matched, regerr = regexp.MatchString(timeFormat, timeStr)
-		if regerr != nil {
-			return -1
-		}
-
-		if matched == true {
-			return timeFormatId
+func ExpandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			path = home + path[1:]
 		}
 	}
 
-	return -1
-}
-
-// TODO: Use https://golang.org/pkg/time/#ParseDuration
-func RelToTime(timeStr string, ftId int, contextTime time.Time) (time.Time, error) {
-	re := regexp.MustCompile(TimeFormats()[ftId])
-	gm := re.FindStringSubmatch(timeStr)
-
-	if len(gm) < 4 {
-		return time.Now(), errors.New("No match")
-	}
-
-	var hours int = 0
-	var minutes int = 0
-
-	if ftId == TFRelHourFraction {
-		f, _ := strconv.ParseFloat(gm[2]+"."+gm[3], 32)
-		minutes = int(f * 60.0)
-	} else {
-		hours, _ = strconv.Atoi(gm[2])
-		minutes, _ = strconv.Atoi(gm[3])
-	}
-
-	var t time.Time
-
-	if viper.IsSet("time.relative") && viper.GetString("time.relative") == "context" && !contextTime.IsZero() {
-		switch gm[1] {
-		case "+":
-			t = contextTime.Add(time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes))
-		case "-":
-			t = contextTime.Add((time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes)) * -1)
-		}
-
-		return t, nil
-	}
-
-	switch gm[1] {
-	case "+":
-		t = time.Now().Local().Add(time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes))
-	case "-":
-		t = time.Now().Local().Add((time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes)) * -1)
-	}
-
-	return t, nil
+	return os.ExpandEnv(path)
 }
 
+// ParseTime parses the time like zeit.ParseTime, relative to contextTime
+// only with time.relative set to context.
 func ParseTime(timeStr string, contextTime time.Time) (time.Time, error) {
-	loc, err := time.LoadLocation("Local")
-	if err != nil {
-		return time.Now(), errors.New("could not load location")
-	}
-
-	cfg := dateparser.Configuration{
-		DefaultTimezone: loc,
-	}
-
-	tfId := GetTimeFormat(timeStr)
-
-	switch tfId {
-	case TFRelHourMinute, TFRelHourFraction:
-		return RelToTime(timeStr, tfId, contextTime)
-	default:
-		tnew, err := dateparser.Parse(&cfg, timeStr)
-		if err != nil {
-			return time.Now(), errors.New("could not match passed time")
-		}
-
-		return tnew.Time, err
-	}
-}
-
-func GetIdFromName(name string) string {
-	reg, regerr := regexp.Compile("[^a-zA-Z0-9]+")
-	if regerr != nil {
-		return ""
+	if viper.GetString("time.relative") != "context" {
+		contextTime = time.Time{}
 	}
 
-	id := strings.ToLower(reg.ReplaceAllString(name, ""))
-
-	return id
+	return zeit.ParseTime(timeStr, contextTime)
 }
 
 func GetISOCalendarWeek(date time.Time) int {
@@ -179,18 +95,8 @@ func GetGitLog(repo string, since time.Time, until time.Time) (string, string, e
 	return stdoutStr, stderrStr, nil
 }
 
-func Ranges() []string {
-	return []string{
-		"today",
-		"yesterday",
-		"thisWeek",
-		"lastWeek",
-		"thisMonth",
-		"lastMonth",
-	}
-}
-
-func ParseSinceUntil(since string, until string, listRange string) (time.Time, time.Time) {
+// ParseTimeRange parses --since, --until and --range.
+func ParseTimeRange(since string, until string, listRange string) (time.Time, time.Time, error) {
 	var sinceTime time.Time
 	var untilTime time.Time
 	var err error
@@ -198,57 +104,38 @@ func ParseSinceUntil(since string, until string, listRange string) (time.Time, t
 	if since != "" {
 		sinceTime, err = now.Parse(since)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return sinceTime, untilTime, err
 		}
 	}
 
 	if until != "" {
 		untilTime, err = now.Parse(until)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return sinceTime, untilTime, err
 		}
 	}
 
 	if listRange != "" {
 		if since != "" || until != "" {
-			fmt.Println("Range and since/until can't be used together, select one of them")
-			os.Exit(1)
+			return sinceTime, untilTime, errors.New("range and since/until can't be used together, select one of them")
 		}
 
-		if viper.GetBool("firstWeekDayMonday") {
+		if IsFirstWeekDayMonday() {
 			now.WeekStartDay = time.Monday
 		}
 
 		loc, _ := time.LoadLocation("Local")
 		time.Local = loc
-		switch strings.ToLower(listRange) {
-		case "today":
-			sinceTime = now.BeginningOfDay()
-			untilTime = now.EndOfDay()
-		case "yesterday":
-			sinceTime = now.BeginningOfDay().AddDate(0, 0, -1)
-			untilTime = now.EndOfDay().AddDate(0, 0, -1)
-		case "thisweek":
-			sinceTime = now.BeginningOfWeek()
-			untilTime = now.EndOfWeek()
-		case "lastweek":
-			lastWeekDay := time.Now().AddDate(0, 0, -7)
-			sinceTime = now.With(lastWeekDay).BeginningOfWeek()
-			untilTime = now.With(lastWeekDay).EndOfWeek()
-		case "thismonth":
-			sinceTime = now.BeginningOfMonth()
-			untilTime = now.EndOfMonth()
-		case "lastmonth":
-			lastMonthDay := time.Now().AddDate(0, -1, 0)
-			sinceTime = now.With(lastMonthDay).BeginningOfMonth()
-			untilTime = now.With(lastMonthDay).EndOfMonth()
-		default:
-			fmt.Println("Unknown range selection, possible options: ", strings.Join(Ranges(), " "))
-			os.Exit(1)
+
+		location, err := GetTimeLocation()
+		if err != nil {
+			return sinceTime, untilTime, err
+		}
+		sinceTime, untilTime, err = zeit.ParseRange(listRange, time.Now().In(location), now.WeekStartDay)
+		if err != nil {
+			return sinceTime, untilTime, err
 		}
 	}
 
-	return sinceTime, untilTime
+	return sinceTime, untilTime, nil
 }
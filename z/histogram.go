@@ -0,0 +1,171 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// Histogram is the time tracked in each of a number of slots, like the hours
+// of the day or the days of the week, split by project.
+type Histogram struct {
+	Labels   []string
+	Slots    []map[string]time.Duration
+	Projects []string
+
+	colors map[string]func(...interface{}) string
+}
+
+// NewHistogram sums up the entries between since and until, hour by hour, in
+// the slot returned by slot for the hour's beginning.
+func NewHistogram(user string, entries []Entry, since time.Time, until time.Time, labels []string, slot func(time.Time) int) (Histogram, error) {
+	histogram := Histogram{Labels: labels, colors: make(map[string]func(...interface{}) string)}
+	for range labels {
+		histogram.Slots = append(histogram.Slots, make(map[string]time.Duration))
+	}
+
+	for _, entry := range entries {
+		key := zeit.GetIdFromName(entry.Project)
+		if _, ok := histogram.colors[key]; !ok {
+			project, err := database.GetProject(user, entry.Project)
+			if err != nil {
+				return histogram, err
+			}
+			histogram.colors[key] = GetColorFnFromHex(project.Color)
+			histogram.Projects = append(histogram.Projects, entry.Project)
+		}
+
+		begin, end := entry.Begin, entry.End()
+		if !since.IsZero() && begin.Before(since) {
+			begin = since
+		}
+		if !until.IsZero() && end.After(until) {
+			end = until
+		}
+		// Truncating would be off in time zones offset by half an hour
+		first := time.Date(begin.Year(), begin.Month(), begin.Day(), begin.Hour(), 0, 0, 0, begin.Location())
+		for hour := first; hour.Before(end); hour = hour.Add(time.Hour) {
+			from, to := hour, hour.Add(time.Hour)
+			if from.Before(begin) {
+				from = begin
+			}
+			if to.After(end) {
+				to = end
+			}
+			histogram.Slots[slot(hour)][key] += to.Sub(from)
+		}
+	}
+
+	sort.Slice(histogram.Projects, func(i, j int) bool {
+		return strings.ToLower(histogram.Projects[i]) < strings.ToLower(histogram.Projects[j])
+	})
+	return histogram, nil
+}
+
+// NewHourHistogram sums up the entries by hour of the day.
+func NewHourHistogram(user string, entries []Entry, since time.Time, until time.Time) (Histogram, error) {
+	var labels []string
+	for hour := 0; hour < 24; hour++ {
+		labels = append(labels, fmt.Sprintf("%02d:00", hour))
+	}
+	return NewHistogram(user, entries, since, until, labels, func(t time.Time) int { return t.Hour() })
+}
+
+// NewWeekdayHistogram sums up the entries by day of the week, beginning on
+// Monday if configured so.
+func NewWeekdayHistogram(user string, entries []Entry, since time.Time, until time.Time) (Histogram, error) {
+	first := time.Sunday
+	if IsFirstWeekDayMonday() {
+		first = time.Monday
+	}
+
+	var labels []string
+	for idx := 0; idx < 7; idx++ {
+		labels = append(labels, time.Weekday((int(first) + idx) % 7).String()[:3])
+	}
+	return NewHistogram(user, entries, since, until, labels, func(t time.Time) int { return (int(t.Weekday()) - int(first) + 7) % 7 })
+}
+
+type HistogramSlot struct {
+	Label    string           `json:"label"`
+	Seconds  int64            `json:"seconds"`
+	Projects map[string]int64 `json:"projects"`
+}
+
+// GetSlots returns the time tracked in each slot, in total and per project.
+func (histogram *Histogram) GetSlots() []HistogramSlot {
+	slots := []HistogramSlot{}
+	for idx, slot := range histogram.Slots {
+		histogramSlot := HistogramSlot{Label: histogram.Labels[idx], Projects: make(map[string]int64)}
+		for _, project := range histogram.Projects {
+			if tracked := slot[zeit.GetIdFromName(project)]; tracked > 0 {
+				histogramSlot.Projects[project] = int64(tracked.Seconds())
+				histogramSlot.Seconds += int64(tracked.Seconds())
+			}
+		}
+		slots = append(slots, histogramSlot)
+	}
+	return slots
+}
+
+// GetOutput renders a bar per slot, stacked in the colors of the projects and
+// width long for the slot with the most time tracked.
+func (histogram *Histogram) GetOutput(width int) string {
+	var output strings.Builder
+
+	totals := make([]time.Duration, len(histogram.Slots))
+	var most, total time.Duration
+	for idx, slot := range histogram.Slots {
+		for _, tracked := range slot {
+			totals[idx] += tracked
+		}
+		most = max(most, totals[idx])
+		total += totals[idx]
+	}
+
+	output.WriteString("\n")
+	for idx, slot := range histogram.Slots {
+		var bar strings.Builder
+		var cumulated time.Duration
+		drawn := 0
+		for _, project := range histogram.Projects {
+			key := zeit.GetIdFromName(project)
+			if slot[key] == 0 {
+				continue
+			}
+			// Widths add up from the cumulated time so that the stacked bar
+			// is as long as the total would be on its own
+			cumulated += slot[key]
+			cells := int(float64(cumulated)/float64(most)*float64(width)+0.5) - drawn
+			bar.WriteString(histogram.colors[key](strings.Repeat("█", cells)))
+			drawn += cells
+		}
+
+		share := 0.0
+		if total > 0 {
+			share = float64(totals[idx]) / float64(total) * 100
+		}
+		fmt.Fprintf(&output, "   %-5s │%s%s %8sh %5.1f%%\n",
+			histogram.Labels[idx],
+			bar.String(),
+			strings.Repeat(" ", width-drawn),
+			fmtDuration(totals[idx]),
+			share,
+		)
+	}
+
+	output.WriteString("\n")
+	for _, project := range histogram.Projects {
+		name := project
+		if name == "" {
+			name = "(no project)"
+		}
+		fmt.Fprintf(&output, "   %s %s\n", histogram.colors[zeit.GetIdFromName(project)]("█"), name)
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
@@ -0,0 +1,231 @@
+package z
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultHookTimeout is how long a hook may run unless hooks.timeout is set.
+const defaultHookTimeout time.Duration = 30 * time.Second
+
+// HookEntry is the entry passed to hooks on stdin.
+type HookEntry struct {
+	ID         string     `json:"id"`
+	Begin      time.Time  `json:"begin"`
+	Finish     *time.Time `json:"finish"`
+	Project    string     `json:"project"`
+	Task       string     `json:"task"`
+	Notes      string     `json:"notes"`
+	User       string     `json:"user"`
+	Attendees  []string   `json:"attendees"`
+	Tags       []string   `json:"tags"`
+	References []string   `json:"references"`
+}
+
+func NewHookEntry(entry Entry) HookEntry {
+	hookEntry := HookEntry{
+		ID:         entry.ID,
+		Begin:      entry.Begin,
+		Project:    entry.Project,
+		Task:       entry.Task,
+		Notes:      entry.Notes,
+		User:       entry.User,
+		Attendees:  entry.Attendees,
+		Tags:       entry.Tags,
+		References: entry.References,
+	}
+	if !entry.Finish.IsZero() {
+		hookEntry.Finish = &entry.Finish
+	}
+	for _, list := range []*[]string{&hookEntry.Attendees, &hookEntry.Tags, &hookEntry.References} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	return hookEntry
+}
+
+// GetHooksDir returns the directory hooks are run from, hooks.dir or
+// $XDG_CONFIG_HOME/zeit/hooks.
+func GetHooksDir() string {
+	if dir := viper.GetString("hooks.dir"); dir != "" {
+		return dir
+	}
+
+	configHome := GetConfigHome()
+	if configHome == "" {
+		return ""
+	}
+	return filepath.Join(configHome, "zeit", "hooks")
+}
+
+// findHooks returns the executables in the hooks directory named after the
+// event, e.g. post-track or post-track.sh, sorted by name.
+func findHooks(event string) []string {
+	dir := GetHooksDir()
+	if dir == "" {
+		return nil
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var hooks []string
+	for _, file := range files {
+		name := file.Name()
+		if name != event && !strings.HasPrefix(name, event+".") {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		hooks = append(hooks, filepath.Join(dir, name))
+	}
+	sort.Strings(hooks)
+
+	return hooks
+}
+
+// RunHooks runs the hooks of the event with payload as JSON on stdin, one
+// after the other, and returns the error of the first one failing. Their
+// output is passed on to stderr, keeping stdout for zeit's own output.
+func RunHooks(event string, payload interface{}) error {
+	hooks := findHooks(event)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultHookTimeout
+	if viper.IsSet("hooks.timeout") {
+		if timeout, err = time.ParseDuration(viper.GetString("hooks.timeout")); err != nil {
+			return fmt.Errorf("invalid hooks.timeout: %v", err)
+		}
+	}
+
+	for _, hook := range hooks {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		cmd := exec.CommandContext(ctx, hook)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"ZEIT_HOOK="+event,
+			"ZEIT_USER="+GetCurrentUser(),
+			"ZEIT_DB="+viper.GetString("db"),
+		)
+		err := cmd.Run()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("hook %s timed out after %s", filepath.Base(hook), timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("hook %s failed: %v", filepath.Base(hook), err)
+		}
+	}
+
+	return nil
+}
+
+// RunPreHooks runs the hooks of an event before it happens, which prevent it
+// by failing.
+func RunPreHooks(event string, entry Entry) error {
+	return RunHooks(event, NewHookEntry(entry))
+}
+
+// Post hooks are held back while the storage is open, so that hooks can run
+// zeit themselves, and run once it was released.
+var postHooksMutex sync.Mutex
+var postHooksHeld int
+var postHooks []func()
+
+// holdPostHooks makes RunPostHooks queue the hooks until releasePostHooks was
+// called as often as holdPostHooks.
+func holdPostHooks() {
+	postHooksMutex.Lock()
+	defer postHooksMutex.Unlock()
+	postHooksHeld++
+}
+
+// releasePostHooks runs the queued post hooks once nothing holds them back
+// anymore.
+func releasePostHooks() {
+	postHooksMutex.Lock()
+	postHooksHeld--
+	if postHooksHeld > 0 {
+		postHooksMutex.Unlock()
+		return
+	}
+	queued := postHooks
+	postHooks = nil
+	postHooksMutex.Unlock()
+
+	for _, run := range queued {
+		run()
+	}
+}
+
+// RunPostHooks runs the hooks of an event after it happened, posts it to the
+// webhooks subscribed to it, updates the Slack status and Taskwarrior and
+// publishes it to MQTT, which can only warn about failing. While the storage
+// is open, all of this happens once it was released.
+func RunPostHooks(event string, payload interface{}) {
+	if entry, ok := payload.(Entry); ok {
+		payload = NewHookEntry(entry)
+	}
+
+	// The Taskwarrior task's total is read while the storage is still open
+	updateTaskwarrior, err := TaskwarriorTimeUpdate(event, payload)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s could not update the Taskwarrior task: %+v\n", CharError, err)
+	}
+
+	run := func() {
+		if err := RunHooks(event, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		}
+		if err := DeliverWebhooks(event, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		}
+		if err := UpdateSlackStatus(event, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s could not update the Slack status: %+v\n", CharError, err)
+		}
+		if updateTaskwarrior != nil {
+			if err := updateTaskwarrior(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s could not update the Taskwarrior task: %+v\n", CharError, err)
+			}
+		}
+		if err := PublishMQTT(event, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s could not publish to MQTT: %+v\n", CharError, err)
+		}
+	}
+
+	postHooksMutex.Lock()
+	if postHooksHeld > 0 {
+		postHooks = append(postHooks, run)
+		postHooksMutex.Unlock()
+		return
+	}
+	postHooksMutex.Unlock()
+	run()
+}
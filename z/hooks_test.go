@@ -0,0 +1,120 @@
+package z
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// setTestConfig sets the config key for the duration of the test.
+func setTestConfig(t *testing.T, key string, value interface{}) {
+	t.Helper()
+
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, nil) })
+}
+
+// installTestHook installs a hook of the event that writes its payload to
+// the file returned.
+func installTestHook(t *testing.T, event string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "payload.json")
+	script := "#!/bin/sh\ncat > '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, event), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setTestConfig(t, "hooks.dir", dir)
+	return output
+}
+
+// readTestHookPayload returns the entry the hook was run with.
+func readTestHookPayload(t *testing.T, output string) HookEntry {
+	t.Helper()
+
+	var hookEntry HookEntry
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("the hook did not run: %v", err)
+	}
+	if err = json.Unmarshal(data, &hookEntry); err != nil {
+		t.Fatalf("the hook got an invalid payload %q: %v", data, err)
+	}
+	return hookEntry
+}
+
+func TestNewHookEntry(t *testing.T) {
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	running := NewHookEntry(Entry{ID: "1", Begin: begin, Project: "zeit", Tags: []string{"dev"}})
+	data, err := json.Marshal(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"finish":null`, `"attendees":[]`, `"tags":["dev"]`, `"references":[]`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("the payload of a running entry %s does not contain %s", data, field)
+		}
+	}
+
+	finished := NewHookEntry(Entry{ID: "1", Begin: begin, Finish: begin.Add(time.Hour)})
+	if finished.Finish == nil || !finished.Finish.Equal(begin.Add(time.Hour)) {
+		t.Errorf("NewHookEntry().Finish = %v, want the finish", finished.Finish)
+	}
+}
+
+func TestPostHooksWaitForRelease(t *testing.T) {
+	output := installTestHook(t, HookPostFinish)
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	holdPostHooks()
+	holdPostHooks()
+	RunPostHooks(HookPostFinish, Entry{ID: "1", Begin: begin, Finish: begin.Add(time.Hour)})
+
+	releasePostHooks()
+	if _, err := os.Stat(output); err == nil {
+		t.Fatal("the hook ran while post hooks were still held back")
+	}
+
+	releasePostHooks()
+	if hookEntry := readTestHookPayload(t, output); hookEntry.ID != "1" || hookEntry.Finish == nil {
+		t.Errorf("the hook got %+v, want the finished entry", hookEntry)
+	}
+}
+
+func TestEditPostHookGetsStoredEntry(t *testing.T) {
+	useTestDatabase(t)
+	output := installTestHook(t, HookPostEdit)
+	user := "test"
+	begin := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	id := addTestEntry(t, user, begin, begin.Add(time.Hour))
+	err := validateAndUpdateEntry(user, id, EditableEntry{
+		Begin:   begin.Format(time.RFC3339),
+		Finish:  begin.Add(2 * time.Hour).Format(time.RFC3339),
+		Project: "edited",
+		Tags:    []string{"dev"},
+	})
+	if err != nil {
+		t.Fatalf("validateAndUpdateEntry() failed: %v", err)
+	}
+
+	hookEntry := readTestHookPayload(t, output)
+	if hookEntry.ID != id || hookEntry.User != user || hookEntry.Project != "edited" {
+		t.Errorf("the hook got %+v, want the stored entry %s", hookEntry, id)
+	}
+	if hookEntry.Finish == nil || !hookEntry.Finish.Equal(begin.Add(2*time.Hour)) {
+		t.Errorf("the hook got the finish %v, want the edited one", hookEntry.Finish)
+	}
+}
@@ -0,0 +1,708 @@
+package z
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+const (
+	icsTimeFormat  string = "20060102T150405Z"
+	icsLineLength  int    = 75
+	icsProductId   string = "-//mrusme//zeit//EN"
+	icsUIDHostname string = "zeit"
+)
+
+var icsEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\;",
+	",", "\\,",
+	"\r\n", "\\n",
+	"\n", "\\n",
+)
+
+// icsLine writes a content line, folded after 75 octets as required by
+// RFC 5545 without splitting multi-byte characters.
+func icsLine(buf *strings.Builder, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines begin with a space, which counts as well
+		limit = icsLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func icsText(value string) string {
+	return icsEscaper.Replace(value)
+}
+
+func icsSummary(entry Entry) string {
+	summary := entry.Project
+	if entry.Task != "" {
+		if summary != "" {
+			summary += ": "
+		}
+		summary += entry.Task
+	}
+	if summary == "" {
+		summary = "zeit"
+	}
+	return summary
+}
+
+// ExportICS writes the entries as an iCalendar file with one VEVENT per
+// activity. Running activities end at the time of the export.
+func ExportICS(entries []Entry) string {
+	var buf strings.Builder
+
+	stamp := time.Now().UTC().Format(icsTimeFormat)
+
+	icsLine(&buf, "BEGIN:VCALENDAR")
+	icsLine(&buf, "VERSION:2.0")
+	icsLine(&buf, "PRODID:"+icsProductId)
+	icsLine(&buf, "CALSCALE:GREGORIAN")
+	for _, entry := range entries {
+		icsLine(&buf, "BEGIN:VEVENT")
+		icsLine(&buf, "UID:"+entry.ID+"@"+icsUIDHostname)
+		icsLine(&buf, "DTSTAMP:"+stamp)
+		icsLine(&buf, "DTSTART:"+entry.Begin.UTC().Format(icsTimeFormat))
+		icsLine(&buf, "DTEND:"+entry.End().UTC().Format(icsTimeFormat))
+		icsLine(&buf, "SUMMARY:"+icsText(icsSummary(entry)))
+		if entry.Notes != "" {
+			icsLine(&buf, "DESCRIPTION:"+icsText(entry.Notes))
+		}
+		if len(entry.Tags) > 0 {
+			var tags []string
+			for _, tag := range entry.Tags {
+				tags = append(tags, icsText(tag))
+			}
+			icsLine(&buf, "CATEGORIES:"+strings.Join(tags, ","))
+		}
+		icsLine(&buf, "TRANSP:TRANSPARENT")
+		icsLine(&buf, "END:VEVENT")
+	}
+	icsLine(&buf, "END:VCALENDAR")
+
+	return buf.String()
+}
+
+const icsMaxOccurrences int = 10000
+
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type icsEvent struct {
+	properties map[string][]icsProperty
+}
+
+func (event *icsEvent) get(name string) (icsProperty, bool) {
+	properties := event.properties[name]
+	if len(properties) == 0 {
+		return icsProperty{}, false
+	}
+	return properties[0], true
+}
+
+func (event *icsEvent) value(name string) string {
+	property, _ := event.get(name)
+	return property.Value
+}
+
+type icsCalendar struct {
+	Name   string
+	Events []*icsEvent
+}
+
+type ICSImportOptions struct {
+	// Calendars maps calendar names to projects; unmapped calendars use
+	// their name as project
+	Calendars map[string]string
+	Keywords  []string
+	Location  *time.Location
+	Since     time.Time
+	Until     time.Time
+}
+
+type icsEventError struct {
+	UID string
+	Err error
+}
+
+func (eerr *icsEventError) Error() string {
+	return fmt.Sprintf("event %s: %v", eerr.UID, eerr.Err)
+}
+
+// GetICSCalendarProjects returns the projects of calendars given as
+// Name=project, or else as configured in `ics.calendars`.
+func GetICSCalendarProjects(mappings []string) (map[string]string, error) {
+	calendars := make(map[string]string)
+	for name, project := range viper.GetStringMapString("ics.calendars") {
+		calendars[strings.ToLower(name)] = project
+	}
+
+	for _, mapping := range mappings {
+		name, project, ok := strings.Cut(mapping, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(project) == "" {
+			return nil, fmt.Errorf("invalid calendar mapping '%s', use Calendar=project", mapping)
+		}
+		calendars[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(project)
+	}
+
+	return calendars, nil
+}
+
+// readICSSource reads an iCalendar file, or fetches it from an http(s) or
+// webcal URL, e.g. the export URL of a CalDAV calendar. Credentials are
+// taken from the URL.
+func readICSSource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "webcal://") {
+		source = "https://" + strings.TrimPrefix(source, "webcal://")
+	}
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return os.ReadFile(source)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	client := http.Client{Timeout: 60 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Redacted(), res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func parseICSLine(line string) (icsProperty, error) {
+	property := icsProperty{Params: make(map[string]string)}
+
+	// The value begins after the first colon outside of quoted parameters
+	quoted := false
+	colon := -1
+	for idx, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = idx
+			break
+		}
+	}
+	if colon < 0 {
+		return property, fmt.Errorf("invalid line '%s'", line)
+	}
+	property.Value = line[colon+1:]
+
+	parts := strings.Split(line[:colon], ";")
+	property.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		property.Params[strings.ToUpper(key)] = strings.Trim(value, "\"")
+	}
+
+	return property, nil
+}
+
+func parseICS(content []byte) (icsCalendar, error) {
+	var calendar icsCalendar
+
+	// Unfold continuation lines first
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var event *icsEvent
+	var nested int
+	var found bool
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		property, err := parseICSLine(line)
+		if err != nil {
+			return calendar, err
+		}
+
+		switch {
+		case property.Name == "BEGIN" && strings.EqualFold(property.Value, "VCALENDAR"):
+			found = true
+		case property.Name == "BEGIN" && strings.EqualFold(property.Value, "VEVENT") && event == nil:
+			event = &icsEvent{properties: make(map[string][]icsProperty)}
+		case property.Name == "BEGIN" && event != nil:
+			// Alarms and other components within events
+			nested++
+		case property.Name == "END" && event != nil && nested > 0:
+			nested--
+		case property.Name == "END" && strings.EqualFold(property.Value, "VEVENT") && event != nil:
+			calendar.Events = append(calendar.Events, event)
+			event = nil
+		case event != nil && nested == 0:
+			event.properties[property.Name] = append(event.properties[property.Name], property)
+		case event == nil && property.Name == "X-WR-CALNAME":
+			calendar.Name = icsUnescape(property.Value)
+		}
+	}
+
+	if !found {
+		return calendar, errors.New("not an iCalendar file")
+	}
+	return calendar, nil
+}
+
+var icsUnescaper = strings.NewReplacer(
+	"\\\\", "\\",
+	"\\;", ";",
+	"\\,", ",",
+	"\\n", "\n",
+	"\\N", "\n",
+)
+
+func icsUnescape(value string) string {
+	return icsUnescaper.Replace(value)
+}
+
+// icsList splits a multi-value property at unescaped commas.
+func icsList(value string) []string {
+	var values []string
+	var current strings.Builder
+	for idx := 0; idx < len(value); idx++ {
+		if value[idx] == '\\' && idx+1 < len(value) {
+			current.WriteByte(value[idx])
+			current.WriteByte(value[idx+1])
+			idx++
+			continue
+		}
+		if value[idx] == ',' {
+			values = append(values, icsUnescape(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(value[idx])
+	}
+	return append(values, icsUnescape(current.String()))
+}
+
+// parseICSTime parses a DATE-TIME in UTC, with a TZID or floating in the
+// given location. All-day dates are reported as such.
+func parseICSTime(property icsProperty, location *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(property.Value)
+
+	if strings.EqualFold(property.Params["VALUE"], "DATE") || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, location)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsTimeFormat, value)
+		return t, false, err
+	}
+
+	if tzid := property.Params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			location = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	return t, false, err
+}
+
+// parseICSDuration parses durations like P1D or PT1H30M.
+func parseICSDuration(value string) (time.Duration, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+
+	var duration time.Duration
+	var number int
+	var digits bool
+	for _, r := range value[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			number = number*10 + int(r-'0')
+			digits = true
+			continue
+		case r == 'T':
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		switch r {
+		case 'W':
+			duration += time.Duration(number) * 7 * 24 * time.Hour
+		case 'D':
+			duration += time.Duration(number) * 24 * time.Hour
+		case 'H':
+			duration += time.Duration(number) * time.Hour
+		case 'M':
+			duration += time.Duration(number) * time.Minute
+		case 'S':
+			duration += time.Duration(number) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		number, digits = 0, false
+	}
+
+	if negative {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// icsRecurrences returns the begin of all occurrences of an event until the
+// given time. Only simple rules are supported: DAILY, WEEKLY (optionally by
+// weekday), MONTHLY and YEARLY with INTERVAL, COUNT and UNTIL.
+func icsRecurrences(begin time.Time, rrule string, until time.Time) ([]time.Time, error) {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(rrule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		rule[strings.ToUpper(key)] = strings.ToUpper(value)
+	}
+
+	for key := range rule {
+		switch key {
+		case "FREQ", "INTERVAL", "COUNT", "UNTIL", "BYDAY", "WKST":
+		default:
+			return nil, fmt.Errorf("recurrence rule %s is not supported", rrule)
+		}
+	}
+
+	interval := 1
+	if rule["INTERVAL"] != "" {
+		var err error
+		if interval, err = strconv.Atoi(rule["INTERVAL"]); err != nil || interval < 1 {
+			return nil, fmt.Errorf("invalid recurrence interval '%s'", rule["INTERVAL"])
+		}
+	}
+
+	count := icsMaxOccurrences
+	if rule["COUNT"] != "" {
+		var err error
+		if count, err = strconv.Atoi(rule["COUNT"]); err != nil {
+			return nil, fmt.Errorf("invalid recurrence count '%s'", rule["COUNT"])
+		}
+	}
+
+	if rule["UNTIL"] != "" {
+		ruleUntil, _, err := parseICSTime(icsProperty{Value: rule["UNTIL"]}, begin.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid recurrence end '%s'", rule["UNTIL"])
+		}
+		if len(rule["UNTIL"]) == len("20060102") {
+			ruleUntil = ruleUntil.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		if ruleUntil.Before(until) {
+			until = ruleUntil
+		}
+	}
+
+	var weekdays []time.Weekday
+	if rule["BYDAY"] != "" {
+		if rule["FREQ"] != "WEEKLY" {
+			return nil, fmt.Errorf("recurrence rule %s is not supported", rrule)
+		}
+		for _, day := range strings.Split(rule["BYDAY"], ",") {
+			weekday, ok := icsWeekdays[day]
+			if !ok {
+				return nil, fmt.Errorf("recurrence rule %s is not supported", rrule)
+			}
+			weekdays = append(weekdays, weekday)
+		}
+	}
+
+	var occurrences []time.Time
+	add := func(occurrence time.Time) bool {
+		if len(occurrences) >= count || occurrence.After(until) {
+			return false
+		}
+		occurrences = append(occurrences, occurrence)
+		return true
+	}
+
+	for step := 0; step < icsMaxOccurrences; step++ {
+		switch rule["FREQ"] {
+		case "DAILY":
+			if !add(begin.AddDate(0, 0, step*interval)) {
+				return occurrences, nil
+			}
+		case "WEEKLY":
+			week := begin.AddDate(0, 0, step*interval*7)
+			if len(weekdays) == 0 {
+				if !add(week) {
+					return occurrences, nil
+				}
+				continue
+			}
+			// Occurrences on the given weekdays of the week, which begins on
+			// Monday
+			monday := week.AddDate(0, 0, -((int(week.Weekday()) + 6) % 7))
+			var days []time.Time
+			for _, weekday := range weekdays {
+				day := monday.AddDate(0, 0, (int(weekday)+6)%7)
+				if !day.Before(begin) {
+					days = append(days, day)
+				}
+			}
+			sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+			for _, day := range days {
+				if !add(day) {
+					return occurrences, nil
+				}
+			}
+		case "MONTHLY", "YEARLY":
+			var occurrence time.Time
+			if rule["FREQ"] == "MONTHLY" {
+				occurrence = begin.AddDate(0, step*interval, 0)
+			} else {
+				occurrence = begin.AddDate(step*interval, 0, 0)
+			}
+			// Months without the day, e.g. the 31st, are skipped
+			if occurrence.Day() != begin.Day() {
+				if occurrence.After(until) {
+					return occurrences, nil
+				}
+				continue
+			}
+			if !add(occurrence) {
+				return occurrences, nil
+			}
+		default:
+			return nil, fmt.Errorf("recurrence rule %s is not supported", rrule)
+		}
+	}
+
+	return occurrences, nil
+}
+
+func (options *ICSImportOptions) matches(event *icsEvent) bool {
+	return containsKeyword(icsUnescape(event.value("SUMMARY")+"\n"+event.value("DESCRIPTION")+"\n"+event.value("CATEGORIES")), options.Keywords)
+}
+
+// containsKeyword returns whether the text mentions any of the keywords, or
+// true without keywords.
+func containsKeyword(text string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+
+	text = strings.ToLower(text)
+	for _, keyword := range keywords {
+		if strings.Contains(text, strings.ToLower(strings.TrimSpace(keyword))) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventSHA1 identifies an occurrence of a calendar event, as occurrences of
+// recurring events share the UID.
+func eventSHA1(uid string, begin time.Time) string {
+	sum := sha1.Sum([]byte(uid + "\x1f" + begin.UTC().Format(icsTimeFormat)))
+	return fmt.Sprintf("%x", sum)
+}
+
+func (options *ICSImportOptions) entry(user string, project string, event *icsEvent, begin time.Time, duration time.Duration) Entry {
+	var attendees []string
+	for _, attendee := range event.properties["ATTENDEE"] {
+		name := attendee.Params["CN"]
+		if name == "" {
+			name = strings.TrimPrefix(strings.TrimPrefix(attendee.Value, "mailto:"), "MAILTO:")
+		}
+		attendees = append(attendees, name)
+	}
+
+	var tags []string
+	for _, categories := range event.properties["CATEGORIES"] {
+		tags = append(tags, icsList(categories.Value)...)
+	}
+
+	entry := Entry{
+		Begin:      begin,
+		Finish:     begin.Add(duration),
+		Project:    project,
+		Task:       icsUnescape(event.value("SUMMARY")),
+		Notes:      icsUnescape(event.value("DESCRIPTION")),
+		User:       user,
+		Attendees:  zeit.ParseAttendees(attendees),
+		Tags:       zeit.ParseTags(tags),
+		References: zeit.ParseReferences([]string{event.value("URL")}),
+	}
+
+	entry.SHA1 = eventSHA1(event.value("UID"), begin)
+	return entry
+}
+
+// ImportICS converts the events of an iCalendar file or URL into entries.
+// All-day, cancelled and not yet finished events are skipped, as well as
+// events not matching any of the keywords. Events that can't be read don't
+// stop the import but are returned as errors.
+func ImportICS(user string, source string, options ICSImportOptions) ([]Entry, []error, error) {
+	content, err := readICSSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	calendar, err := parseICS(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if calendar.Name == "" {
+		calendar.Name = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	}
+
+	entries, eventErrors := importICSCalendar(user, calendar, options)
+	return entries, eventErrors, nil
+}
+
+// importICSCalendar converts the events of a parsed calendar into entries,
+// see ImportICS.
+func importICSCalendar(user string, calendar icsCalendar, options ICSImportOptions) ([]Entry, []error) {
+	var entries []Entry
+	var eventErrors []error
+
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.Until.IsZero() || options.Until.After(time.Now()) {
+		options.Until = time.Now()
+	}
+
+	project := calendar.Name
+	if mapped, ok := options.Calendars[strings.ToLower(calendar.Name)]; ok {
+		project = mapped
+	}
+
+	// Modified occurrences of recurring events replace the original ones
+	overrides := make(map[string]bool)
+	for _, event := range calendar.Events {
+		if recurrenceId, ok := event.get("RECURRENCE-ID"); ok {
+			if t, _, err := parseICSTime(recurrenceId, options.Location); err == nil {
+				overrides[event.value("UID")+"\x1f"+t.UTC().Format(icsTimeFormat)] = true
+			}
+		}
+	}
+
+	for _, event := range calendar.Events {
+		uid := event.value("UID")
+
+		if strings.EqualFold(event.value("STATUS"), "CANCELLED") || !options.matches(event) {
+			continue
+		}
+
+		dtstart, ok := event.get("DTSTART")
+		if !ok {
+			eventErrors = append(eventErrors, &icsEventError{UID: uid, Err: errors.New("no DTSTART")})
+			continue
+		}
+		begin, allDay, err := parseICSTime(dtstart, options.Location)
+		if err != nil {
+			eventErrors = append(eventErrors, &icsEventError{UID: uid, Err: err})
+			continue
+		}
+		if allDay {
+			continue
+		}
+
+		var duration time.Duration
+		if dtend, ok := event.get("DTEND"); ok {
+			finish, _, err := parseICSTime(dtend, options.Location)
+			if err != nil {
+				eventErrors = append(eventErrors, &icsEventError{UID: uid, Err: err})
+				continue
+			}
+			duration = finish.Sub(begin)
+		} else if value := event.value("DURATION"); value != "" {
+			if duration, err = parseICSDuration(value); err != nil {
+				eventErrors = append(eventErrors, &icsEventError{UID: uid, Err: err})
+				continue
+			}
+		}
+		if duration <= 0 {
+			continue
+		}
+
+		occurrences := []time.Time{begin}
+		if rrule := event.value("RRULE"); rrule != "" {
+			if _, ok := event.get("RECURRENCE-ID"); !ok {
+				if occurrences, err = icsRecurrences(begin, rrule, options.Until); err != nil {
+					eventErrors = append(eventErrors, &icsEventError{UID: uid, Err: err})
+					continue
+				}
+			}
+		}
+
+		exdates := make(map[string]bool)
+		for _, exdate := range event.properties["EXDATE"] {
+			for _, value := range strings.Split(exdate.Value, ",") {
+				exdate.Value = value
+				if t, _, err := parseICSTime(exdate, options.Location); err == nil {
+					exdates[t.UTC().Format(icsTimeFormat)] = true
+				}
+			}
+		}
+
+		_, isOverride := event.get("RECURRENCE-ID")
+		for _, occurrence := range occurrences {
+			key := occurrence.UTC().Format(icsTimeFormat)
+			if exdates[key] || (!isOverride && overrides[uid+"\x1f"+key]) {
+				continue
+			}
+			if occurrence.Before(options.Since) || occurrence.Add(duration).After(options.Until) {
+				continue
+			}
+			entries = append(entries, options.entry(user, project, event, occurrence, duration))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+	return entries, eventErrors
+}
@@ -0,0 +1,354 @@
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+)
+
+// Idle periods are recorded by `zeit notify`, which samples how long there
+// was no input every minute and notices the machine sleeping by the wall
+// clock jumping ahead. When finishing an activity, the idle periods within
+// it can be subtracted or split off.
+
+const defaultIdleThreshold time.Duration = 10 * time.Minute
+
+// idleKeep is how long recorded idle periods are kept.
+const idleKeep time.Duration = 7 * 24 * time.Hour
+
+type IdlePeriod struct {
+	Begin  time.Time `json:"begin"`
+	End    time.Time `json:"end"`
+	Asleep bool      `json:"asleep,omitempty"`
+}
+
+func (period IdlePeriod) Duration() time.Duration {
+	return period.End.Sub(period.Begin)
+}
+
+func IdleActions() []string {
+	return []string{
+		IdleAsk,
+		IdleSubtract,
+		IdleSplit,
+		IdleKeep,
+	}
+}
+
+// GetIdleAction returns what is done with idle periods when finishing,
+// configured as `idle.action`.
+func GetIdleAction() string {
+	action := strings.ToLower(viper.GetString("idle.action"))
+	switch action {
+	case IdleSubtract, IdleSplit, IdleKeep:
+		return action
+	default:
+		return IdleAsk
+	}
+}
+
+// GetIdleThreshold returns how long idle periods have to last to count,
+// `idle.threshold` or 10 minutes.
+func GetIdleThreshold() (time.Duration, error) {
+	if !viper.IsSet("idle.threshold") {
+		return defaultIdleThreshold, nil
+	}
+
+	threshold, err := parseTarget(viper.GetString("idle.threshold"))
+	if err != nil || threshold <= 0 {
+		return 0, fmt.Errorf("invalid idle.threshold '%s', use e.g. 10m", viper.GetString("idle.threshold"))
+	}
+	return threshold, nil
+}
+
+// GetIdleTime returns how long there was no keyboard or mouse input, as
+// printed in seconds by idle.command or else as reported by the platform.
+func GetIdleTime() (time.Duration, error) {
+	command := viper.GetString("idle.command")
+	if command == "" {
+		return platformIdleTime()
+	}
+
+	output, err := exec.Command(ExpandPath(command)).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", command, err)
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s printed '%s' instead of the idle seconds", command, strings.TrimSpace(string(output)))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// runIdleHelper runs a tool reporting the idle time and returns its output.
+func runIdleHelper(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("%s was not found, please install it or configure idle.command", name)
+	} else if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetIdlePeriodsPath returns the file idle periods are recorded in.
+func GetIdlePeriodsPath() string {
+	cacheHome := GetCacheHome()
+	if cacheHome == "" {
+		return ""
+	}
+
+	return filepath.Join(cacheHome, "zeit", "idle.json")
+}
+
+func ReadIdlePeriods() ([]IdlePeriod, error) {
+	var periods []IdlePeriod
+
+	path := GetIdlePeriodsPath()
+	if path == "" {
+		return periods, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return periods, nil
+	} else if err != nil {
+		return periods, err
+	}
+	if err = json.Unmarshal(content, &periods); err != nil {
+		return periods, fmt.Errorf("could not read the idle periods %s: %v", path, err)
+	}
+	return periods, nil
+}
+
+// mergeIdlePeriods sorts the periods and joins the overlapping ones, which
+// count as asleep if any of them does.
+func mergeIdlePeriods(periods []IdlePeriod) []IdlePeriod {
+	var merged []IdlePeriod
+
+	sorted := append([]IdlePeriod{}, periods...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Begin.Before(sorted[j].Begin) })
+
+	for _, period := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && !period.Begin.After(merged[last].End) {
+			if period.End.After(merged[last].End) {
+				merged[last].End = period.End
+			}
+			merged[last].Asleep = merged[last].Asleep || period.Asleep
+			continue
+		}
+		merged = append(merged, period)
+	}
+	return merged
+}
+
+// RecordIdlePeriod adds the period to the recorded ones, forgetting the ones
+// older than a week.
+func RecordIdlePeriod(period IdlePeriod) error {
+	path := GetIdlePeriodsPath()
+	if path == "" {
+		return errors.New("could not find the cache directory to record idle periods in")
+	}
+
+	periods, err := ReadIdlePeriods()
+	if err != nil {
+		return err
+	}
+
+	var kept []IdlePeriod
+	for _, recorded := range mergeIdlePeriods(append(periods, period)) {
+		if time.Since(recorded.End) < idleKeep {
+			kept = append(kept, recorded)
+		}
+	}
+
+	content, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomically(path, content)
+}
+
+// IdleSampler records idle periods when sampled regularly, every interval.
+type IdleSampler struct {
+	Interval  time.Duration
+	Threshold time.Duration
+	last      time.Time
+}
+
+// Sample records the time without input up to now if it exceeds the
+// threshold, as well as the time since the last sample if the machine was
+// asleep in between. The time without input can't be told on every platform,
+// in which case an error is returned after recording sleep.
+func (sampler *IdleSampler) Sample(now time.Time) error {
+	// Without the monotonic clock reading, which stops while asleep on some
+	// platforms, the difference is the wall clock time that passed
+	last := sampler.last
+	sampler.last = now
+	if !last.IsZero() && now.Round(0).Sub(last.Round(0)) >= sampler.Interval+sampler.Threshold {
+		if err := RecordIdlePeriod(IdlePeriod{Begin: last.Round(0), End: now.Round(0), Asleep: true}); err != nil {
+			return err
+		}
+	}
+
+	idle, err := GetIdleTime()
+	if err != nil {
+		return err
+	}
+	if idle >= sampler.Threshold {
+		return RecordIdlePeriod(IdlePeriod{Begin: now.Round(0).Add(-idle), End: now.Round(0)})
+	}
+	return nil
+}
+
+// FindIdlePeriods returns the idle periods of at least the threshold within
+// the entry, including the time without input up to now if the entry is
+// finished now.
+func FindIdlePeriods(entry Entry, threshold time.Duration) ([]IdlePeriod, error) {
+	periods, err := ReadIdlePeriods()
+	if err != nil {
+		return nil, err
+	}
+
+	if now := time.Now(); now.Sub(entry.Finish) < time.Minute {
+		if idle, err := GetIdleTime(); err == nil && idle >= threshold {
+			periods = append(periods, IdlePeriod{Begin: now.Add(-idle), End: now})
+		}
+	}
+
+	var found []IdlePeriod
+	for _, period := range mergeIdlePeriods(periods) {
+		if period.Begin.Before(entry.Begin) {
+			period.Begin = entry.Begin
+		}
+		if period.End.After(entry.Finish) {
+			period.End = entry.Finish
+		}
+		if period.Duration() >= threshold {
+			found = append(found, period)
+		}
+	}
+	return found, nil
+}
+
+// SubtractIdle finishes the entry earlier by the idle time within it.
+func SubtractIdle(entry Entry, periods []IdlePeriod) Entry {
+	for _, period := range periods {
+		entry.Finish = entry.Finish.Add(-period.Duration())
+	}
+	return entry
+}
+
+// SplitIdle finishes the entry when it became idle and returns it together
+// with a copy of it for every time it was resumed after being idle.
+func SplitIdle(entry Entry, periods []IdlePeriod) []Entry {
+	var entries []Entry
+
+	begin := entry.Begin
+	for _, period := range periods {
+		if period.Begin.After(begin) {
+			part := entry
+			part.Begin, part.Finish = begin, period.Begin
+			entries = append(entries, part)
+		}
+		begin = period.End
+	}
+	if begin.Before(entry.Finish) {
+		part := entry
+		part.Begin = begin
+		entries = append(entries, part)
+	}
+
+	// Only the first part is the entry itself, the others are added
+	for idx := 1; idx < len(entries); idx++ {
+		entries[idx].ID = ""
+	}
+	return entries
+}
+
+// ResolveIdle looks for idle periods within the entry being finished and,
+// depending on the action, subtracts them, splits the entry around them or
+// keeps them, asking which one when running interactively. The first entry
+// returned is the one to finish; splitting returns further entries to add.
+func ResolveIdle(entry Entry, action string) ([]Entry, error) {
+	if action == IdleKeep {
+		return []Entry{entry}, nil
+	}
+
+	threshold, err := GetIdleThreshold()
+	if err != nil {
+		return nil, err
+	}
+	periods, err := FindIdlePeriods(entry, threshold)
+	if err != nil || len(periods) == 0 {
+		return []Entry{entry}, err
+	}
+
+	var total time.Duration
+	for _, period := range periods {
+		total += period.Duration()
+	}
+
+	if action == IdleAsk {
+		for _, period := range periods {
+			state := "idle"
+			if period.Asleep {
+				state = "asleep"
+			}
+			fmt.Printf("%s %s from %s to %s (%sh)\n", CharInfo, state, period.Begin.Format("15:04"), period.End.Format("15:04"), fmtDuration(period.Duration()))
+		}
+		if !IsInteractive() {
+			fmt.Printf("%s keeping %sh of idle time, use --idle to subtract or split it\n", CharInfo, color.FgLightWhite.Render(fmtDuration(total)))
+			return []Entry{entry}, nil
+		}
+
+		for action == IdleAsk {
+			fmt.Printf("%s %sh of %sh were idle: [s]subtract  [p]split  [k]keep: ", CharMore, color.FgLightWhite.Render(fmtDuration(total)), color.FgLightWhite.Render(fmtDuration(entry.Finish.Sub(entry.Begin))))
+			key, err := readKey()
+			fmt.Printf("%c\n", key)
+			if err != nil {
+				return nil, err
+			}
+
+			switch strings.ToLower(string(key)) {
+			case "s":
+				action = IdleSubtract
+			case "p":
+				action = IdleSplit
+			case "k", "\r", "\n":
+				action = IdleKeep
+			case "q", "\x03", "\x1b":
+				return nil, errors.New("aborted")
+			default:
+				fmt.Printf("%s unknown action\n", CharError)
+			}
+		}
+	}
+
+	switch action {
+	case IdleSubtract:
+		return []Entry{SubtractIdle(entry, periods)}, nil
+	case IdleSplit:
+		entries := SplitIdle(entry, periods)
+		if len(entries) == 0 {
+			return nil, errors.New("the activity was idle all the time, erase it instead")
+		}
+		return entries, nil
+	default:
+		return []Entry{entry}, nil
+	}
+}
@@ -0,0 +1,31 @@
+//go:build darwin
+
+package z
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var hidIdleTimePattern = regexp.MustCompile(`"HIDIdleTime" = ([0-9]+)`)
+
+// platformIdleTime reads the time since the last input in nanoseconds from
+// the IOHIDSystem.
+func platformIdleTime() (time.Duration, error) {
+	output, err := runIdleHelper("ioreg", "-c", "IOHIDSystem", "-d", "4")
+	if err != nil {
+		return 0, err
+	}
+
+	match := hidIdleTimePattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, errors.New("ioreg did not report HIDIdleTime")
+	}
+	nanoseconds, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(nanoseconds), nil
+}
@@ -0,0 +1,45 @@
+//go:build !(darwin || windows)
+
+package z
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// platformIdleTime asks the idle monitor of GNOME on Wayland, which has no
+// common protocol for it, or xprintidle on X11 for the milliseconds since
+// the last input. Other Wayland compositors need an idle.command.
+func platformIdleTime() (time.Duration, error) {
+	var output string
+	var err error
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		output, err = runIdleHelper("dbus-send", "--print-reply", "--dest=org.gnome.Mutter.IdleMonitor",
+			"/org/gnome/Mutter/IdleMonitor/Core", "org.gnome.Mutter.IdleMonitor.GetIdletime")
+		if err != nil {
+			return 0, err
+		}
+		// The reply ends with `uint64 <milliseconds>`
+		fields := strings.Fields(output)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("the GNOME idle monitor replied '%s'", output)
+		}
+		output = fields[len(fields)-1]
+	} else if os.Getenv("DISPLAY") != "" {
+		if output, err = runIdleHelper("xprintidle"); err != nil {
+			return 0, err
+		}
+	} else {
+		return 0, fmt.Errorf("no graphical session to tell the idle time of, please configure idle.command")
+	}
+
+	milliseconds, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not read the idle time '%s'", output)
+	}
+	return time.Duration(milliseconds) * time.Millisecond, nil
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package z
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procGetLastInputInfo = windows.NewLazySystemDLL("user32.dll").NewProc("GetLastInputInfo")
+	procGetTickCount     = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetTickCount")
+)
+
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// platformIdleTime compares the tick count of the last input to the current
+// one, both in milliseconds since the system started.
+func platformIdleTime() (time.Duration, error) {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ok, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo: %v", err)
+	}
+
+	ticks, _, _ := procGetTickCount.Call()
+	return time.Duration(uint32(ticks)-info.dwTime) * time.Millisecond, nil
+}
@@ -0,0 +1,135 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	activityWatchServer string
+	activityWatchHost   string
+	activityWatchYes    bool
+)
+
+var importActivityWatchCmd = &cobra.Command{
+	Use:   "activitywatch ([flags])",
+	Short: "Import from ActivityWatch",
+	Long:  "Import the windows ActivityWatch recorded as activities, by default for today. The project and task of a window are those of the first rule in activitywatch.rules (default is watch.rules) matching it; windows of the same activity are joined, time away from keyboard is left out and activities shorter than activitywatch.minimum (default 5m) are skipped. Every activity is reviewed before importing it, unless --yes is given.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		if untilTime.IsZero() {
+			untilTime = time.Now()
+		}
+		if sinceTime.IsZero() {
+			sinceTime = time.Date(untilTime.Year(), untilTime.Month(), untilTime.Day(), 0, 0, 0, 0, untilTime.Location())
+		}
+
+		aw, err := NewActivityWatchImport(activityWatchServer, activityWatchHost)
+		if err != nil {
+			return err
+		}
+
+		candidates, err := aw.Candidates(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			fmt.Printf("%s no windows matched the rules between %s and %s\n", CharInfo, sinceTime.Format("2006-01-02 15:04"), untilTime.Format("2006-01-02 15:04"))
+			return nil
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		// Activities imported before aren't reviewed again, unless they are
+		// to be overwritten or merged
+		if strings.EqualFold(importOnDuplicate, ImportDuplicateSkip) {
+			var fresh []ActivityWatchCandidate
+			for _, candidate := range candidates {
+				if _, ok := sha1List[candidate.Entry.SHA1]; !ok {
+					fresh = append(fresh, candidate)
+				}
+			}
+			if imported := len(candidates) - len(fresh); imported > 0 {
+				fmt.Printf("%s %d activities were imported before\n", CharInfo, imported)
+			}
+			candidates = fresh
+			if len(candidates) == 0 {
+				return nil
+			}
+		}
+
+		var entries []Entry
+		switch {
+		case importDryRun:
+			for _, candidate := range candidates {
+				entries = append(entries, candidate.Entry)
+			}
+		case activityWatchYes:
+			for _, candidate := range candidates {
+				if !candidate.Certain {
+					fmt.Printf("%s skipping %s at %s, %s\n", CharInfo, color.FgLightWhite.Render(watchName(candidate.Entry.Project, candidate.Entry.Task)), candidate.Entry.Begin.Format("2006-01-02 15:04"), candidate.Reason)
+					continue
+				}
+				entries = append(entries, candidate.Entry)
+			}
+		case IsInteractive():
+			if entries, err = ReviewActivityWatchCandidates(candidates); err != nil {
+				return err
+			}
+			fmt.Println()
+		default:
+			return errors.New("reviewing the activities requires an interactive terminal, use --yes to import them without review")
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importActivityWatchCmd)
+	importActivityWatchCmd.Flags().StringVar(&activityWatchServer, "url", "", "URL of the ActivityWatch server (default is activitywatch.url, watch.activitywatch or http://localhost:5600)")
+	importActivityWatchCmd.Flags().StringVar(&activityWatchHost, "host", "", "Only import the windows of this host (default is all hosts)")
+	importActivityWatchCmd.Flags().StringVar(&since, "since", "", "Date/time to import from (default is today)")
+	importActivityWatchCmd.Flags().StringVar(&until, "until", "", "Date/time to import until (default is now)")
+	importActivityWatchCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	importActivityWatchCmd.Flags().BoolVarP(&activityWatchYes, "yes", "y", false, "Import the activities without reviewing them, skipping the ones of rules asking for confirmation")
+	importActivityWatchCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importActivityWatchCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported, without reviewing it")
+}
@@ -0,0 +1,111 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clockifyWorkspace string
+	clockifyClients   []string
+	clockifyProjects  []string
+	clockifyTags      []string
+)
+
+var importClockifyCmd = &cobra.Command{
+	Use:   "clockify ([flags]) [file]",
+	Short: "Import from Clockify",
+	Long:  "Import a detailed report exported from Clockify as CSV, or without a file the time entries of the last 30 days using the API key configured as clockify.token. Activities are deduplicated by start time and description, so reports and the API can be imported alike.",
+	Args:  cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		mapping, err := NewClockifyMapping(clockifyClients, clockifyProjects, clockifyTags)
+		if err != nil {
+			return err
+		}
+
+		var entries []Entry
+		if len(args) > 0 {
+			location, err := importLocation()
+			if err != nil {
+				return err
+			}
+
+			var rowErrors []error
+			entries, rowErrors, err = ImportClockifyCSV(user, args[0], mapping, location)
+			for _, rowErr := range rowErrors {
+				fmt.Printf("%s %+v\n", CharError, rowErr)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+			if err != nil {
+				return err
+			}
+			if untilTime.IsZero() {
+				untilTime = time.Now()
+			}
+			if sinceTime.IsZero() {
+				sinceTime = untilTime.AddDate(0, 0, -30)
+			}
+
+			clockify, err := NewClockify(clockifyWorkspace)
+			if err != nil {
+				return err
+			}
+			if entries, err = clockify.TimeEntries(user, sinceTime, untilTime, mapping); err != nil {
+				return err
+			}
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importClockifyCmd)
+	importClockifyCmd.Flags().StringVar(&clockifyWorkspace, "workspace", "", "Clockify workspace ID (default is clockify.workspace or the active workspace)")
+	importClockifyCmd.Flags().StringSliceVar(&clockifyClients, "client-map", []string{}, "Map Clockify clients to zeit projects as Client=project, in addition to clockify.clients (comma separated)")
+	importClockifyCmd.Flags().StringSliceVar(&clockifyProjects, "project-map", []string{}, "Map Clockify projects to zeit projects as Clockify=zeit, in addition to clockify.projects (comma separated)")
+	importClockifyCmd.Flags().StringSliceVar(&clockifyTags, "tag-map", []string{}, "Map Clockify tags to zeit tags as Clockify=zeit, in addition to clockify.tags (comma separated)")
+	importClockifyCmd.Flags().StringVar(&since, "since", "", "Date/time to import from using the API (default is 30 days ago)")
+	importClockifyCmd.Flags().StringVar(&until, "until", "", "Date/time to import until using the API (default is now)")
+	importClockifyCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	importClockifyCmd.Flags().StringVar(&importTimezone, "timezone", "", "Timezone of the report, e.g. Europe/Berlin (default is the local timezone)")
+	importClockifyCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importClockifyCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
+}
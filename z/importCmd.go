@@ -1,12 +1,13 @@
 package z
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/cnf/structhash"
-	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
 )
 
@@ -20,19 +21,19 @@ func importTymeJson(user string, file string) ([]Entry, error) {
 		tymeEntrySHA1 := structhash.Sha1(tymeEntry, 1)
 		tymeStart, err := time.Parse("2006-01-02T15:04:05-07:00", tymeEntry.Start)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
+			printWarning(err)
 			continue
 		}
 
 		tymeEnd, err := time.Parse("2006-01-02T15:04:05-07:00", tymeEntry.End)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
+			printWarning(err)
 			continue
 		}
 
 		entry, err := NewEntry("", "", "", tymeEntry.Project, tymeEntry.Task, user)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
+			printWarning(err)
 			continue
 		}
 
@@ -47,12 +48,84 @@ func importTymeJson(user string, file string) ([]Entry, error) {
 	return entries, nil
 }
 
+var (
+	importMap         []string
+	importDelimiter   string
+	importTimezone    string
+	importTimeFormat  string
+	importDryRun      bool
+	importOnDuplicate string
+	importCalendars   []string
+	importKeywords    []string
+)
+
+func importLocation() (*time.Location, error) {
+	if importTimezone == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(importTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone '%s'", importTimezone)
+	}
+	return location, nil
+}
+
+func importCsv(user string, file string) ([]Entry, error) {
+	location, err := importLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, rowErrors, err := ImportCSV(user, file, CSVImportOptions{
+		Mapping:    importMap,
+		Delimiter:  importDelimiter,
+		Location:   location,
+		TimeFormat: importTimeFormat,
+	})
+	for _, rowErr := range rowErrors {
+		printWarning(rowErr)
+	}
+
+	return entries, err
+}
+
+func importIcs(user string, source string) ([]Entry, error) {
+	location, err := importLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	calendars, err := GetICSCalendarProjects(importCalendars)
+	if err != nil {
+		return nil, err
+	}
+
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, eventErrors, err := ImportICS(user, source, ICSImportOptions{
+		Calendars: calendars,
+		Keywords:  importKeywords,
+		Location:  location,
+		Since:     sinceTime,
+		Until:     untilTime,
+	})
+	for _, eventErr := range eventErrors {
+		printWarning(eventErr)
+	}
+
+	return entries, err
+}
+
 var importCmd = &cobra.Command{
-	Use:   "import ([flags]) [file]",
+	Use:   "import ([flags]) [file|url]",
 	Short: "Import tracked activities",
 	Long:  "Import tracked activities from various formats.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var entries []Entry
 		var err error
 
@@ -61,52 +134,70 @@ var importCmd = &cobra.Command{
 		switch format {
 		case "zeit":
 			// TODO:
-			fmt.Printf("%s not yet implemented\n", CharError)
-			os.Exit(1)
+			return errors.New("not yet implemented")
 		case "tyme":
 			entries, err = importTymeJson(user, args[0])
 			if err != nil {
-				fmt.Printf("%s %+v\n", CharError, err)
-				os.Exit(1)
+				return err
+			}
+		case "csv":
+			entries, err = importCsv(user, args[0])
+			if err != nil {
+				return err
+			}
+		case "ics":
+			entries, err = importIcs(user, args[0])
+			if err != nil {
+				return err
 			}
 		default:
-			fmt.Printf("%s specify an import format; see `zeit import --help` for more info\n", CharError)
-			os.Exit(1)
+			return errors.New("specify an import format; see `zeit import --help` for more info")
 		}
 
 		sha1List, sha1Err := database.GetImportsSHA1List(user)
 		if sha1Err != nil {
-			fmt.Printf("%s %+v\n", CharError, sha1Err)
-			os.Exit(1)
+			return sha1Err
 		}
 
-		for _, entry := range entries {
-			if id, ok := sha1List[entry.SHA1]; ok {
-				fmt.Printf("%s %s was previously imported as %s; not importing again\n", CharInfo, color.FgLightWhite.Render(entry.SHA1), color.FgLightWhite.Render(id))
-				continue
-			}
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
 
-			importedId, err := database.AddEntry(user, entry, false)
-			if err != nil {
-				fmt.Printf("%s %s could not be imported: %+v\n", CharError, color.FgLightWhite.Render(entry.SHA1), color.FgRed.Render(err))
-				continue
-			}
+		if importDryRun {
+			return plan.Preview()
+		}
 
-			fmt.Printf("%s %s was imported as %s\n", CharInfo, color.FgLightWhite.Render(entry.SHA1), color.FgLightWhite.Render(importedId))
-			sha1List[entry.SHA1] = importedId
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
 		}
 
-		err = database.UpdateImportsSHA1List(user, sha1List)
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
-		return
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(importCmd)
-	importCmd.Flags().StringVar(&format, "format", "zeit", "Format to import, possible values: zeit, tyme")
+	importCmd.Flags().StringVar(&format, "format", "zeit", "Format to import, possible values: zeit, tyme, csv, ics")
+	importCmd.Flags().StringSliceVar(&importMap, "map", []string{}, "Map csv columns to fields as Column=field, possible fields: "+strings.Join(CSVImportFields(), ", ")+" (comma separated)")
+	importCmd.Flags().StringVar(&importDelimiter, "delimiter", ",", "Delimiter of the csv file, a single character or tab")
+	importCmd.Flags().StringVar(&importTimezone, "timezone", "", "Timezone of csv and ics timestamps without UTC offset, e.g. Europe/Berlin (default is the local timezone)")
+	importCmd.Flags().StringVar(&importTimeFormat, "time-format", "", "Go layout of csv timestamps, e.g. \"02.01.2006 15:04\" (default is RFC 3339 and similar)")
+	importCmd.Flags().StringSliceVar(&importCalendars, "calendar", []string{}, "Map ics calendar names to projects as Calendar=project (default is ics.calendars or the calendar name)")
+	importCmd.Flags().StringSliceVar(&importKeywords, "keyword", []string{}, "Only import ics events containing any of the keywords (comma separated)")
+	importCmd.Flags().StringVar(&since, "since", "", "Only import ics events beginning at or after this date/time")
+	importCmd.Flags().StringVar(&until, "until", "", "Only import ics events finished until this date/time (default is now)")
+	importCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	importCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
 }
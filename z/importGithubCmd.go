@@ -0,0 +1,51 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var importGithubCmd = &cobra.Command{
+	Use:     "github",
+	Aliases: []string{"gitlab"},
+	Short:   "Import assigned GitHub/GitLab issues as tasks",
+	Long:    "Import the open GitHub/GitLab issues assigned to you in the repositories configured in issues.repositories as tasks, e.g. `GH-123 Fix login`, which are offered when picking or completing the task to track, for the repository's project if it has one. Tasks of issues no longer open or assigned to you are removed.",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		repositories, err := GetIssueRepositories()
+		if err != nil {
+			return err
+		}
+		if len(repositories) == 0 {
+			fmt.Printf("%s no repositories configured in issues.repositories\n", CharInfo)
+			return nil
+		}
+
+		failed := false
+		tasks, err := ImportAssignedIssues(user, repositories, func(repository IssueRepository, err error) {
+			fmt.Printf("%s %s: %+v\n", CharError, repository.Repository, err)
+			failed = true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			fmt.Printf("%s %s\n", CharMore, color.FgLightWhite.Render(task.Name))
+		}
+		fmt.Printf("%s imported %d assigned issues as tasks\n", CharInfo, len(tasks))
+
+		if failed {
+			return fmt.Errorf("some repositories could not be read")
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importGithubCmd)
+}
@@ -0,0 +1,67 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var importOrgCmd = &cobra.Command{
+	Use:   "org ([flags]) file",
+	Short: "Import org mode clocks",
+	Long:  "Import the CLOCK lines of an org file. The top-level headline above a clock is the project, the innermost headline below it the task and the tags of the headlines are tags.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		location, err := importLocation()
+		if err != nil {
+			return err
+		}
+
+		entries, lineErrors, err := ImportOrg(user, args[0], location)
+		for _, lineErr := range lineErrors {
+			fmt.Printf("%s %+v\n", CharError, lineErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importOrgCmd)
+	importOrgCmd.Flags().StringVar(&importTimezone, "timezone", "", "Timezone of the clocks, e.g. Europe/Berlin (default is the local timezone)")
+	importOrgCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importOrgCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
+}
@@ -0,0 +1,336 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// ImportDuplicateStrategies are the values of `import --on-duplicate`.
+func ImportDuplicateStrategies() []string {
+	return []string{ImportDuplicateSkip, ImportDuplicateOverwrite, ImportDuplicateMerge, ImportDuplicateFail}
+}
+
+// importFingerprint identifies an activity independently of the source it
+// was imported from: its begin, finish and project.
+func importFingerprint(entry Entry) string {
+	return entry.Begin.UTC().Truncate(time.Second).Format(time.RFC3339) + "\x1f" +
+		entry.Finish.UTC().Truncate(time.Second).Format(time.RFC3339) + "\x1f" +
+		strings.ToLower(strings.TrimSpace(entry.Project))
+}
+
+// importStep is what importing a single activity does: create it, skip it or
+// overwrite or merge it into the activity it duplicates.
+type importStep struct {
+	Entry  Entry
+	Action string
+	Reason string
+
+	// target is the activity that is created, or the one that is overwritten
+	// or merged into; duplicates within an import share the same target.
+	target *Entry
+	// existing is set when target is an activity that is tracked already.
+	existing bool
+}
+
+// ImportPlan holds the steps of an import, which are worked out before
+// anything is written so that the fail strategy can refuse the whole import.
+type ImportPlan struct {
+	Strategy string
+	Steps    []*importStep
+}
+
+// PlanImport matches the entries against the activities of user: entries
+// imported before, as by their SHA1, or with the fingerprint of an activity
+// are duplicates and handled according to strategy.
+func PlanImport(user string, entries []Entry, sha1List map[string]string, strategy string) (ImportPlan, error) {
+	plan := ImportPlan{Strategy: strategy}
+
+	if !zeit.ContainsFold(ImportDuplicateStrategies(), strategy) {
+		return plan, fmt.Errorf("unknown duplicate strategy '%s', possible values: %s", strategy, strings.Join(ImportDuplicateStrategies(), ", "))
+	}
+	plan.Strategy = strings.ToLower(strategy)
+
+	tracked, err := database.ListEntries(user)
+	if err != nil {
+		return plan, err
+	}
+
+	byID := make(map[string]*Entry)
+	byFingerprint := make(map[string]*Entry)
+	for idx := range tracked {
+		entry := &tracked[idx]
+		byID[entry.ID] = entry
+		if !entry.Finish.IsZero() {
+			byFingerprint[importFingerprint(*entry)] = entry
+		}
+	}
+	existing := func(target *Entry) bool {
+		return target.ID != "" && byID[target.ID] == target
+	}
+
+	var duplicates int
+	for _, entry := range entries {
+		step := &importStep{Entry: entry, Action: ImportActionCreate}
+		plan.Steps = append(plan.Steps, step)
+
+		fingerprint := importFingerprint(entry)
+		if id, ok := sha1List[entry.SHA1]; ok {
+			target, ok := byID[id]
+			if !ok {
+				step.Action = ImportDuplicateSkip
+				step.Reason = "was previously imported as " + id
+				continue
+			}
+			step.target = target
+			step.Reason = "was previously imported as " + id
+		} else if target, ok := byFingerprint[fingerprint]; ok {
+			step.target = target
+			if existing(target) {
+				step.Reason = "duplicates " + target.ID
+			} else {
+				step.Reason = "duplicates an activity of this import"
+			}
+		} else {
+			created := entry
+			step.target = &created
+			byFingerprint[fingerprint] = &created
+			continue
+		}
+
+		step.existing = existing(step.target)
+		step.Action = plan.Strategy
+		duplicates++
+
+		switch plan.Strategy {
+		case ImportDuplicateOverwrite:
+			overwriteEntry(step.target, entry)
+		case ImportDuplicateMerge:
+			mergeEntry(step.target, entry)
+		}
+	}
+
+	if plan.Strategy == ImportDuplicateFail && duplicates > 0 {
+		for _, step := range plan.Steps {
+			if step.Action == ImportDuplicateFail && !IsOutputJSON() {
+				fmt.Printf("%s %s %s\n", CharError, color.FgLightWhite.Render(step.Entry.SHA1), step.Reason)
+			}
+		}
+		return plan, fmt.Errorf("%d of %d activities are duplicates; nothing was imported", duplicates, len(entries))
+	}
+
+	return plan, nil
+}
+
+// overwriteEntry replaces everything but the ID and user of target with entry.
+func overwriteEntry(target *Entry, entry Entry) {
+	entry.ID = target.ID
+	entry.User = target.User
+	entry.SHA1 = target.SHA1
+	*target = entry
+}
+
+// mergeEntry fills the task and notes of target if they are empty and adds
+// the tags, attendees and references of entry to it.
+func mergeEntry(target *Entry, entry Entry) {
+	if target.Task == "" {
+		target.Task = entry.Task
+	}
+	if target.Notes == "" {
+		target.Notes = entry.Notes
+	}
+	target.Tags = zeit.ParseTags(append(append([]string{}, target.Tags...), entry.Tags...))
+	target.Attendees = zeit.ParseAttendees(append(append([]string{}, target.Attendees...), entry.Attendees...))
+	target.References = zeit.ParseReferences(append(append([]string{}, target.References...), entry.References...))
+}
+
+func (plan *ImportPlan) summary(prefix string, counts map[string]int) string {
+	return fmt.Sprintf("%s%d created, %d skipped, %d overwritten, %d merged",
+		prefix, counts[ImportActionCreate], counts[ImportDuplicateSkip], counts[ImportDuplicateOverwrite], counts[ImportDuplicateMerge])
+}
+
+// ImportResult is what an import did, or would have done on a dry run, as
+// printed with --output json.
+type ImportResult struct {
+	DryRun      bool             `json:"dryRun"`
+	Created     int              `json:"created"`
+	Skipped     int              `json:"skipped"`
+	Overwritten int              `json:"overwritten"`
+	Merged      int              `json:"merged"`
+	Failed      int              `json:"failed"`
+	Activities  []ImportActivity `json:"activities"`
+}
+
+// ImportActivity is what was done with a single imported activity. ID is the
+// activity it was imported as or into.
+type ImportActivity struct {
+	SHA1   string `json:"sha1"`
+	ID     string `json:"id,omitempty"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newImportResult(dryRun bool, counts map[string]int, failed int, activities []ImportActivity) ImportResult {
+	return ImportResult{
+		DryRun:      dryRun,
+		Created:     counts[ImportActionCreate],
+		Skipped:     counts[ImportDuplicateSkip],
+		Overwritten: counts[ImportDuplicateOverwrite],
+		Merged:      counts[ImportDuplicateMerge],
+		Failed:      failed,
+		Activities:  activities,
+	}
+}
+
+// Preview prints what importing would do.
+func (plan *ImportPlan) Preview() error {
+	counts := make(map[string]int)
+	activities := []ImportActivity{}
+
+	for _, step := range plan.Steps {
+		counts[step.Action]++
+		activity := ImportActivity{SHA1: step.Entry.SHA1, Action: step.Action, Reason: step.Reason}
+		if step.existing {
+			activity.ID = step.target.ID
+		}
+		activities = append(activities, activity)
+		if IsOutputJSON() {
+			continue
+		}
+
+		switch step.Action {
+		case ImportActionCreate:
+			fmt.Printf("%s would import %s\n", CharMore, GetEntryOutput(step.Entry, false))
+		case ImportDuplicateSkip:
+			fmt.Printf("%s %s %s; would not import again\n", CharInfo, color.FgLightWhite.Render(step.Entry.SHA1), step.Reason)
+		case ImportDuplicateOverwrite:
+			fmt.Printf("%s %s %s; would overwrite it\n", CharMore, color.FgLightWhite.Render(step.Entry.SHA1), step.Reason)
+		case ImportDuplicateMerge:
+			fmt.Printf("%s %s %s; would merge into it\n", CharMore, color.FgLightWhite.Render(step.Entry.SHA1), step.Reason)
+		}
+	}
+
+	if IsOutputJSON() {
+		return printJSON(newImportResult(true, counts, 0, activities))
+	}
+	fmt.Printf("%s %s of %d activities\n", CharInfo, plan.summary("dry run: would have ", counts), len(plan.Steps))
+	return nil
+}
+
+// Import carries out the plan and records the imported SHA1s in sha1List.
+func (plan *ImportPlan) Import(user string, sha1List map[string]string) error {
+	counts := make(map[string]int)
+	var failed int
+	var imported []HookEntry
+	activities := []ImportActivity{}
+
+	// report prints what was done with an activity, or collects it for the
+	// JSON output
+	report := func(step *importStep, id string, err error, format string, a ...interface{}) {
+		activity := ImportActivity{SHA1: step.Entry.SHA1, ID: id, Action: step.Action, Reason: step.Reason}
+		if err != nil {
+			activity.Error = err.Error()
+		}
+		activities = append(activities, activity)
+		if !IsOutputJSON() {
+			fmt.Printf(format, a...)
+		}
+	}
+
+	for _, step := range plan.Steps {
+		sha1 := color.FgLightWhite.Render(step.Entry.SHA1)
+
+		switch step.Action {
+		case ImportDuplicateSkip:
+			var id string
+			if step.target != nil && step.target.ID != "" {
+				id = step.target.ID
+				sha1List[step.Entry.SHA1] = id
+			}
+			report(step, id, nil, "%s %s %s; not importing again\n", CharInfo, sha1, step.Reason)
+			counts[step.Action]++
+			continue
+		case ImportDuplicateOverwrite, ImportDuplicateMerge:
+			if step.existing {
+				// Entries locked in strict mode are neither overwritten nor
+				// merged into
+				existing, err := database.GetEntry(user, step.target.ID)
+				if err == nil {
+					err = ValidateEditWindow(existing)
+				}
+				if err == nil {
+					_, err = database.UpdateEntry(user, *step.target)
+				}
+				if err != nil {
+					report(step, step.target.ID, err, "%s %s could not be imported: %+v\n", CharError, sha1, color.FgRed.Render(err))
+					failed++
+					continue
+				}
+			}
+			if step.target.ID != "" {
+				done := "overwritten"
+				if step.Action == ImportDuplicateMerge {
+					done = "merged"
+				}
+				report(step, step.target.ID, nil, "%s %s %s; %s\n", CharInfo, sha1, step.Reason, done)
+				sha1List[step.Entry.SHA1] = step.target.ID
+				counts[step.Action]++
+				imported = append(imported, NewHookEntry(*step.target))
+			}
+			continue
+		}
+
+		// Imports merged into this activity have changed its target already.
+		entry := *step.target
+		entry.SHA1 = step.Entry.SHA1
+
+		var importedId string
+		resolution, err := ResolveOverlaps(user, "", entry)
+		if err != nil && (IsInteractive() || GetOverlapPolicy() == OverlapReject) {
+			report(step, "", err, "%s %s was not imported: %+v\n", CharError, sha1, color.FgRed.Render(err))
+			failed++
+			continue
+		} else if err != nil {
+			if !IsOutputJSON() {
+				fmt.Printf("%s %s %+v\n", CharInfo, sha1, err)
+			}
+			resolution = OverlapResolution{Entries: []Entry{entry}}
+		}
+
+		err = StoreResolution(user, resolution, func(entry Entry) (string, error) {
+			importedId, err = database.AddEntry(user, entry, false)
+			return importedId, err
+		})
+		if err != nil {
+			report(step, "", err, "%s %s could not be imported: %+v\n", CharError, sha1, color.FgRed.Render(err))
+			failed++
+			continue
+		}
+
+		report(step, importedId, nil, "%s %s was imported as %s\n", CharInfo, sha1, color.FgLightWhite.Render(importedId))
+		step.target.ID = importedId
+		sha1List[step.Entry.SHA1] = importedId
+		counts[step.Action]++
+		imported = append(imported, NewHookEntry(*step.target))
+	}
+
+	var err error
+	if IsOutputJSON() {
+		err = printJSON(newImportResult(false, counts, failed, activities))
+	} else {
+		summary := plan.summary("imported: ", counts)
+		if failed > 0 {
+			summary += fmt.Sprintf(", %d failed", failed)
+		}
+		fmt.Printf("%s %s\n", CharInfo, summary)
+	}
+
+	if len(imported) > 0 {
+		RunPostHooks(HookPostImport, imported)
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var importTimewCmd = &cobra.Command{
+	Use:   "timew ([flags]) [file|directory]",
+	Short: "Import from Timewarrior",
+	Long:  "Import the intervals of Timewarrior from its data directory (default is timew.data or where Timewarrior keeps it), a single data file or the output of `timew export`. Tags are translated to projects, tasks and tags by the rules configured as timew.rules.",
+	Args:  cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		rules, err := GetTimewRules()
+		if err != nil {
+			return err
+		}
+
+		source := GetTimewDataPath()
+		if len(args) > 0 {
+			source = args[0]
+		}
+
+		entries, intervalErrors, err := ImportTimew(user, source, rules)
+		for _, intervalErr := range intervalErrors {
+			fmt.Printf("%s %+v\n", CharError, intervalErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importTimewCmd)
+	importTimewCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importTimewCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
+}
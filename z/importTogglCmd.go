@@ -0,0 +1,156 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	togglWorkspace int64
+	togglProjects  []string
+	togglTags      []string
+	togglPush      bool
+)
+
+var importTogglCmd = &cobra.Command{
+	Use:   "toggl ([flags])",
+	Short: "Import from Toggl Track",
+	Long:  "Import the time entries of a Toggl Track workspace using the API token configured as toggl.token, by default for the last 30 days. With --push, new activities tracked in zeit are mirrored into Toggl, so that both stay in sync while migrating off Toggl gradually.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		if untilTime.IsZero() {
+			untilTime = time.Now()
+		}
+		if sinceTime.IsZero() {
+			sinceTime = untilTime.AddDate(0, 0, -30)
+		}
+
+		toggl, err := NewToggl(togglWorkspace, togglProjects, togglTags)
+		if err != nil {
+			return err
+		}
+
+		timeEntries, err := toggl.TimeEntries(sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		pushedIDs, err := TogglPushedIDs(user)
+		if err != nil {
+			return err
+		}
+
+		var entries []Entry
+		for _, timeEntry := range timeEntries {
+			if pushedIDs[timeEntry.ID] {
+				continue
+			}
+			entry, err := toggl.Entry(user, timeEntry)
+			if err != nil {
+				fmt.Printf("%s %+v\n", CharError, err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		reportPush := func(entry Entry, err error) {
+			if err != nil {
+				fmt.Printf("%s %s could not be pushed to Toggl: %+v\n", CharError, color.FgLightWhite.Render(entry.ID), color.FgRed.Render(err))
+				return
+			}
+			verb := "pushed"
+			if importDryRun {
+				verb = "would push"
+			}
+			fmt.Printf("%s %s %s to Toggl\n", CharMore, verb, GetEntryOutput(entry, false))
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			if err := plan.Preview(); err != nil {
+				return err
+			}
+			if togglPush {
+				stats, err := toggl.Push(user, sinceTime, untilTime, timeEntries, sha1List, true, reportPush)
+				if err != nil {
+					return err
+				}
+				if !IsOutputJSON() {
+					fmt.Printf("%s dry run: would push %d activities to Toggl\n", CharInfo, stats.Pushed)
+				}
+			}
+			return nil
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			for _, project := range toggl.ZeitProjects(timeEntries) {
+				existing, err := database.GetProject(user, project.Name)
+				if err != nil {
+					return err
+				}
+				if existing.Name != "" {
+					continue
+				}
+				if err = database.UpdateProject(user, project.Name, project); err != nil {
+					return err
+				}
+			}
+
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+
+		if togglPush {
+			stats, err := toggl.Push(user, sinceTime, untilTime, timeEntries, sha1List, false, reportPush)
+			if err != nil {
+				return err
+			}
+			if !IsOutputJSON() {
+				fmt.Printf("%s pushed %d activities to Toggl\n", CharInfo, stats.Pushed)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importTogglCmd)
+	importTogglCmd.Flags().Int64Var(&togglWorkspace, "workspace", 0, "Toggl workspace ID (default is toggl.workspace or the default workspace)")
+	importTogglCmd.Flags().StringSliceVar(&togglProjects, "project-map", []string{}, "Map Toggl projects to zeit projects as Toggl=zeit, in addition to toggl.projects (comma separated)")
+	importTogglCmd.Flags().StringSliceVar(&togglTags, "tag-map", []string{}, "Map Toggl tags to zeit tags as Toggl=zeit, in addition to toggl.tags (comma separated)")
+	importTogglCmd.Flags().StringVar(&since, "since", "", "Date/time to import from (default is 30 days ago)")
+	importTogglCmd.Flags().StringVar(&until, "until", "", "Date/time to import until (default is now)")
+	importTogglCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	importTogglCmd.Flags().BoolVar(&togglPush, "push", false, "Also push activities tracked in zeit within the range to Toggl")
+	importTogglCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importTogglCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported and pushed")
+}
@@ -0,0 +1,167 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var importUpstreamUser string
+
+var importUpstreamCmd = &cobra.Command{
+	Use:   "upstream-zeit ([flags]) [file]",
+	Short: "Import from upstream zeit",
+	Long:  "Import the activities, projects and tasks of an upstream zeit (github.com/mrusme/zeit) database or `zeit export`, by default the database found at the locations upstream zeit used. The upstream database is only read, and activities already imported are skipped, so the import can be repeated until switching over.",
+	Args:  cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		var file string
+		var err error
+		if len(args) > 0 {
+			file = ExpandPath(args[0])
+		} else if file, err = FindUpstreamZeit(viper.GetString("db")); err != nil {
+			return err
+		}
+		if sameFile(file, viper.GetString("db")) {
+			return fmt.Errorf("%s is the database in use", file)
+		}
+
+		sourceUser := user
+		if importUpstreamUser != "" {
+			sourceUser = importUpstreamUser
+		}
+
+		upstream, err := ReadUpstreamZeit(file, sourceUser)
+		if err != nil {
+			return err
+		}
+		running, hasRunning, entries, stale := upstream.SplitRunning()
+
+		fmt.Printf("%s found %d activities, %d projects and %d tasks of %s in %s\n",
+			CharInfo,
+			len(upstream.Entries),
+			len(upstream.Projects),
+			len(upstream.Tasks),
+			color.FgLightWhite.Render(sourceUser),
+			color.FgLightWhite.Render(file))
+		if len(upstream.Entries) == 0 && len(upstream.Users) > 0 {
+			fmt.Printf("%s the database contains the users %s; choose one using --user\n", CharMore, strings.Join(upstream.Users, ", "))
+			return nil
+		}
+		if stale > 0 {
+			fmt.Printf("%s %d unfinished activities besides the running one will not be imported\n", CharMore, stale)
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			if err := plan.Preview(); err != nil {
+				return err
+			}
+			if hasRunning && !IsOutputJSON() {
+				fmt.Printf("%s would continue tracking %s\n", CharMore, GetEntryOutput(running, false))
+			}
+			return nil
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			for _, project := range upstream.Projects {
+				existing, err := database.GetProject(user, project.Name)
+				if err != nil {
+					return err
+				}
+				if existing.Name != "" {
+					continue
+				}
+				if err = database.UpdateProject(user, project.Name, project); err != nil {
+					return err
+				}
+				fmt.Printf("%s project %s was imported\n", CharInfo, color.FgLightWhite.Render(project.Name))
+			}
+
+			for _, task := range upstream.Tasks {
+				existing, err := database.GetTask(user, task.Name)
+				if err != nil {
+					return err
+				}
+				if existing.Name != "" {
+					continue
+				}
+				if err = database.UpdateTask(user, task.Name, task); err != nil {
+					return err
+				}
+				fmt.Printf("%s task %s was imported\n", CharInfo, color.FgLightWhite.Render(task.Name))
+			}
+
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+
+			if hasRunning {
+				if err := importUpstreamRunning(user, running, sha1List); err != nil {
+					return err
+				}
+			}
+
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return nil
+		}
+		fmt.Printf("%s done; once everything is switched over, the upstream database at %s can be removed\n", CharInfo, color.FgLightWhite.Render(file))
+		return nil
+	},
+}
+
+// importUpstreamRunning continues tracking the activity running in upstream
+// zeit, unless an activity is running already.
+func importUpstreamRunning(user string, entry Entry, sha1List map[string]string) error {
+	if _, ok := sha1List[entry.SHA1]; ok {
+		return nil
+	}
+
+	runningId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return err
+	}
+	if runningId != "" {
+		fmt.Printf("%s %s is running upstream but not imported, as an activity is running already\n", CharError, GetEntryOutput(entry, false))
+		return nil
+	}
+
+	id, err := database.AddEntry(user, entry, true)
+	if err != nil {
+		return err
+	}
+	sha1List[entry.SHA1] = id
+
+	fmt.Printf("%s continuing to track %s\n", CharTrack, GetEntryOutput(entry, false))
+	return nil
+}
+
+func init() {
+	importCmd.AddCommand(importUpstreamCmd)
+	importUpstreamCmd.Flags().StringVar(&importUpstreamUser, "user", "", "User of the upstream database to import (default is the current user)")
+	importUpstreamCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importUpstreamCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
+}
@@ -0,0 +1,78 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watsonProjects []string
+	watsonTasks    []string
+)
+
+var importWatsonCmd = &cobra.Command{
+	Use:   "watson ([flags]) [file]",
+	Short: "Import from Watson",
+	Long:  "Import the frames of Watson from its frames file (default is in $WATSON_DIR or Watson's config directory). Projects are mapped by watson.projects and --project-map, and tags mapped to tasks by watson.tasks and --task-map become the task.",
+	Args:  cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		mapping, err := NewWatsonMapping(watsonProjects, watsonTasks)
+		if err != nil {
+			return err
+		}
+
+		file := GetWatsonFramesPath()
+		if len(args) > 0 {
+			file = args[0]
+		}
+
+		entries, frameErrors, err := ImportWatson(user, file, mapping)
+		for _, frameErr := range frameErrors {
+			fmt.Printf("%s %+v\n", CharError, frameErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		sha1List, err := database.GetImportsSHA1List(user)
+		if err != nil {
+			return err
+		}
+
+		plan, err := PlanImport(user, entries, sha1List, importOnDuplicate)
+		if err != nil {
+			return err
+		}
+
+		if importDryRun {
+			return plan.Preview()
+		}
+
+		if err := AutoBackup(user, "import"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			if err := plan.Import(user, sha1List); err != nil {
+				return err
+			}
+			return database.UpdateImportsSHA1List(user, sha1List)
+		})
+		if err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importWatsonCmd)
+	importWatsonCmd.Flags().StringSliceVar(&watsonProjects, "project-map", []string{}, "Map Watson projects to zeit projects as Watson=zeit, in addition to watson.projects (comma separated)")
+	importWatsonCmd.Flags().StringSliceVar(&watsonTasks, "task-map", []string{}, "Map Watson tags to zeit tasks as tag=Task, in addition to watson.tasks (comma separated)")
+	importWatsonCmd.Flags().StringVar(&importOnDuplicate, "on-duplicate", ImportDuplicateSkip, "What to do with activities that are tracked already, possible values: "+strings.Join(ImportDuplicateStrategies(), ", "))
+	importWatsonCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "Only show what would be imported")
+}
@@ -0,0 +1,529 @@
+package z
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+const invoiceMetaKey string = "invoice:sequence"
+
+var invoiceSeqRegexp = regexp.MustCompile(`\{seq(?::(\d+))?\}`)
+
+var invoiceCurrencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+}
+
+func InvoiceFormats() []string {
+	return []string{
+		InvoicePDF,
+		InvoiceHTML,
+	}
+}
+
+// InvoiceClient is a client configured as `invoice.clients.<name>`. Rate,
+// Currency and Tax override the rates of the projects and the defaults of
+// `invoice.*`.
+type InvoiceClient struct {
+	Key      string
+	Name     string   `mapstructure:"name"`
+	Address  string   `mapstructure:"address"`
+	TaxID    string   `mapstructure:"taxId"`
+	Projects []string `mapstructure:"projects"`
+	Rate     string   `mapstructure:"rate"`
+	Currency string   `mapstructure:"currency"`
+	Tax      string   `mapstructure:"tax"`
+}
+
+type InvoiceLine struct {
+	Project    string
+	Task       string
+	Activities int
+	Hours      decimal.Decimal
+	Rate       decimal.Decimal
+	Amount     decimal.Decimal
+
+	minutes int64
+}
+
+type Invoice struct {
+	Number   string
+	Date     time.Time
+	Due      time.Time
+	Since    time.Time
+	Until    time.Time
+	From     string
+	TaxID    string
+	Client   InvoiceClient
+	Currency string
+	Lines    []InvoiceLine
+	Hours    decimal.Decimal
+	Subtotal decimal.Decimal
+	TaxLabel string
+	TaxRate  decimal.Decimal
+	Tax      decimal.Decimal
+	Total    decimal.Decimal
+	Terms    string
+}
+
+// GetInvoiceClient returns the client configured as invoice.clients.<name>.
+func GetInvoiceClient(name string) (InvoiceClient, error) {
+	var client InvoiceClient
+
+	key := "invoice.clients." + strings.ToLower(name)
+	if !viper.IsSet(key) {
+		return client, fmt.Errorf("unknown client '%s', configure it as %s", name, key)
+	}
+	if err := viper.UnmarshalKey(key, &client); err != nil {
+		return client, fmt.Errorf("invalid %s: %v", key, err)
+	}
+
+	client.Key = strings.ToLower(name)
+	if client.Name == "" {
+		client.Name = name
+	}
+	if len(client.Projects) == 0 {
+		client.Projects = []string{name}
+	}
+	return client, nil
+}
+
+// GetInvoiceClients returns all clients configured as invoice.clients, sorted
+// by name.
+func GetInvoiceClients() ([]InvoiceClient, error) {
+	var clients []InvoiceClient
+
+	for name := range viper.GetStringMap("invoice.clients") {
+		client, err := GetInvoiceClient(name)
+		if err != nil {
+			return clients, err
+		}
+		clients = append(clients, client)
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+	return clients, nil
+}
+
+func parseInvoiceDecimal(key string, values ...string) (decimal.Decimal, error) {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		parsed, err := decimal.NewFromString(value)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid %s '%s'", key, value)
+		}
+		return parsed, nil
+	}
+	return decimal.Zero, nil
+}
+
+// getInvoiceRounding returns the rounding rules for invoices, invoice.rounding
+// minutes per activity as configured before the rounding rules existed and
+// otherwise the rounding rules if they apply to invoices.
+func getInvoiceRounding() (Rounding, error) {
+	minutes := viper.GetInt("invoice.rounding")
+	if minutes <= 0 {
+		return GetRoundingFor(RoundInInvoice)
+	}
+
+	rounding := Rounding{
+		Granularity: time.Duration(minutes) * time.Minute,
+		Direction:   strings.ToLower(viper.GetString("invoice.roundingMode")),
+		Per:         RoundPerEntry,
+	}
+	if rounding.Direction == "" {
+		rounding.Direction = RoundUp
+	}
+	if !zeit.ContainsFold(RoundingDirections(), rounding.Direction) {
+		return rounding, fmt.Errorf("unknown invoice.roundingMode '%s', possible values: %s", rounding.Direction, strings.Join(RoundingDirections(), ", "))
+	}
+	return rounding, nil
+}
+
+// NewInvoice aggregates the finished activities on the billable projects of
+// the client between since and until into a line per project and task.
+func NewInvoice(user string, client InvoiceClient, since time.Time, until time.Time) (Invoice, error) {
+	invoice := Invoice{
+		Date:     time.Now(),
+		Since:    since,
+		Until:    until,
+		From:     strings.TrimSpace(viper.GetString("invoice.from")),
+		TaxID:    viper.GetString("invoice.taxId"),
+		Client:   client,
+		TaxLabel: viper.GetString("invoice.taxLabel"),
+		Terms:    strings.TrimSpace(viper.GetString("invoice.terms")),
+	}
+	if invoice.TaxLabel == "" {
+		invoice.TaxLabel = "VAT"
+	}
+	dueDays := 14
+	if viper.IsSet("invoice.dueDays") {
+		dueDays = viper.GetInt("invoice.dueDays")
+	}
+	invoice.Due = invoice.Date.AddDate(0, 0, dueDays)
+
+	var err error
+	if invoice.TaxRate, err = parseInvoiceDecimal("tax", client.Tax, viper.GetString("invoice.tax")); err != nil {
+		return invoice, err
+	}
+	clientRate, err := parseInvoiceDecimal("rate", client.Rate)
+	if err != nil {
+		return invoice, err
+	}
+	defaultRate, err := parseInvoiceDecimal("rate", viper.GetString("invoice.rate"))
+	if err != nil {
+		return invoice, err
+	}
+
+	rounding, err := getInvoiceRounding()
+	if err != nil {
+		return invoice, err
+	}
+
+	entries, err := database.ListEntriesBetween(user, since, until)
+	if err != nil {
+		return invoice, err
+	}
+
+	var clientEntries []Entry
+	for _, entry := range entries {
+		if entry.Finish.IsZero() || !entry.Begin.Before(until) || !zeit.ContainsFold(client.Projects, entry.Project) {
+			continue
+		}
+		clientEntries = append(clientEntries, entry)
+	}
+
+	lines := make(map[string]*InvoiceLine)
+	projects := make(map[string]Project)
+	for _, entry := range rounding.RoundEntries(clientEntries) {
+
+		project, ok := projects[entry.Project]
+		if !ok {
+			project, _ = database.GetProject(user, entry.Project)
+			projects[entry.Project] = project
+		}
+		if !project.Billable {
+			continue
+		}
+
+		rate := project.Rate
+		if !clientRate.IsZero() {
+			rate = clientRate
+		} else if rate.IsZero() {
+			rate = defaultRate
+		}
+		if rate.IsZero() {
+			return invoice, fmt.Errorf("no rate for project '%s', set one using `zeit project --rate` or as invoice.clients.%s.rate", entry.Project, client.Key)
+		}
+
+		currency := client.Currency
+		if currency == "" {
+			currency = project.Currency
+		}
+		if currency == "" {
+			currency = viper.GetString("invoice.currency")
+		}
+		currency = strings.ToUpper(currency)
+		if invoice.Currency == "" {
+			invoice.Currency = currency
+		} else if invoice.Currency != currency {
+			return invoice, fmt.Errorf("activities in %s and %s can't be on the same invoice, set invoice.clients.%s.currency", invoice.Currency, currency, client.Key)
+		}
+
+		duration := entry.Finish.Sub(entry.Begin).Round(time.Minute)
+
+		key := entry.Project + "\x1f" + entry.Task + "\x1f" + rate.String()
+		line, ok := lines[key]
+		if !ok {
+			line = &InvoiceLine{Project: entry.Project, Task: entry.Task, Rate: rate}
+			lines[key] = line
+		}
+		line.Activities++
+		line.minutes += int64(duration / time.Minute)
+	}
+
+	if len(lines) == 0 {
+		return invoice, fmt.Errorf("no billable activities of %s between %s and %s", client.Name, since.Format(DateFormat), until.Format(DateFormat))
+	}
+
+	for _, line := range lines {
+		minutes := decimal.NewFromInt(line.minutes)
+		line.Hours = minutes.Div(decimal.NewFromInt(60))
+		line.Amount = minutes.Mul(line.Rate).Div(decimal.NewFromInt(60)).Round(2)
+		invoice.Lines = append(invoice.Lines, *line)
+		invoice.Hours = invoice.Hours.Add(line.Hours)
+		invoice.Subtotal = invoice.Subtotal.Add(line.Amount)
+	}
+	sort.Slice(invoice.Lines, func(i, j int) bool {
+		if invoice.Lines[i].Project != invoice.Lines[j].Project {
+			return invoice.Lines[i].Project < invoice.Lines[j].Project
+		}
+		return invoice.Lines[i].Task < invoice.Lines[j].Task
+	})
+
+	invoice.Tax = invoice.Subtotal.Mul(invoice.TaxRate).Div(decimal.NewFromInt(100)).Round(2)
+	invoice.Total = invoice.Subtotal.Add(invoice.Tax)
+	return invoice, nil
+}
+
+// invoiceNumber renders invoice.numberFormat, where {year}, {month} and
+// {client} are replaced and {seq} or {seq:digits} is the sequence number.
+func (invoice *Invoice) invoiceNumber(seq int) (string, string) {
+	format := viper.GetString("invoice.numberFormat")
+	if format == "" {
+		format = "{year}-{seq:3}"
+	}
+	format = strings.NewReplacer(
+		"{year}", invoice.Date.Format("2006"),
+		"{month}", invoice.Date.Format("01"),
+		"{client}", strings.ToUpper(invoice.Client.Key),
+	).Replace(format)
+
+	// Sequences count separately for everything around them, so that a number
+	// format with the year starts over every year
+	counter := invoiceSeqRegexp.ReplaceAllString(format, "{seq}")
+	number := invoiceSeqRegexp.ReplaceAllStringFunc(format, func(match string) string {
+		digits, _ := strconv.Atoi(invoiceSeqRegexp.FindStringSubmatch(match)[1])
+		return fmt.Sprintf("%0*d", digits, seq)
+	})
+	return number, counter
+}
+
+func getInvoiceSequences(user string) (map[string]int, error) {
+	sequences := make(map[string]int)
+
+	value, err := database.GetMeta(user, invoiceMetaKey)
+	if err != nil || value == "" {
+		return sequences, err
+	}
+	if err = json.Unmarshal([]byte(value), &sequences); err != nil {
+		return sequences, errors.New("could not read the invoice numbers")
+	}
+	return sequences, nil
+}
+
+// NextNumber sets the number of the invoice to the next one of its sequence,
+// without using it up.
+func (invoice *Invoice) NextNumber(user string) error {
+	sequences, err := getInvoiceSequences(user)
+	if err != nil {
+		return err
+	}
+
+	_, counter := invoice.invoiceNumber(0)
+	invoice.Number, _ = invoice.invoiceNumber(sequences[counter] + 1)
+	return nil
+}
+
+// UseNumber records the number of the invoice as used.
+func (invoice *Invoice) UseNumber(user string) error {
+	sequences, err := getInvoiceSequences(user)
+	if err != nil {
+		return err
+	}
+
+	_, counter := invoice.invoiceNumber(0)
+	sequences[counter]++
+
+	value, err := json.Marshal(sequences)
+	if err != nil {
+		return err
+	}
+	return database.SetMeta(user, invoiceMetaKey, string(value))
+}
+
+// FormatMoney formats the amount with invoice.money.decimal and
+// invoice.money.thousands as separators, and the currency placed according
+// to invoice.money.format.
+func FormatMoney(amount decimal.Decimal, currency string) string {
+	decimalSeparator := invoiceDecimalSeparator()
+	thousandsSeparator := viper.GetString("invoice.money.thousands")
+	if !viper.IsSet("invoice.money.thousands") {
+		thousandsSeparator = ","
+	}
+	format := viper.GetString("invoice.money.format")
+	if format == "" {
+		format = "{symbol}{amount}"
+	}
+
+	sign := ""
+	if amount.IsNegative() {
+		sign = "-"
+		amount = amount.Neg()
+	}
+	integer, fraction, _ := strings.Cut(amount.StringFixed(2), ".")
+
+	var grouped strings.Builder
+	for idx, digit := range integer {
+		if idx > 0 && (len(integer)-idx)%3 == 0 {
+			grouped.WriteString(thousandsSeparator)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	symbol, ok := invoiceCurrencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+	return strings.NewReplacer(
+		"{amount}", sign+grouped.String()+decimalSeparator+fraction,
+		"{symbol}", symbol,
+		"{currency}", currency,
+	).Replace(format)
+}
+
+func invoiceDecimalSeparator() string {
+	if separator := viper.GetString("invoice.money.decimal"); separator != "" {
+		return separator
+	}
+	return "."
+}
+
+func formatInvoiceHours(hours decimal.Decimal) string {
+	return strings.Replace(hours.StringFixed(2), ".", invoiceDecimalSeparator(), 1)
+}
+
+func (invoice *Invoice) Money(amount decimal.Decimal) string {
+	return FormatMoney(amount, invoice.Currency)
+}
+
+// Period describes the invoiced period, e.g. June 2024 for a whole month.
+func (invoice *Invoice) Period() string {
+	last := invoice.Until.Add(-time.Nanosecond)
+	if invoice.Since.Day() == 1 && last.AddDate(0, 0, 1).Day() == 1 && invoice.Since.Month() == last.Month() && invoice.Since.Year() == last.Year() {
+		return invoice.Since.Format("January 2006")
+	}
+	return invoice.Since.Format(DateFormat) + " – " + last.Format(DateFormat)
+}
+
+const defaultInvoiceTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{ .Number }}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 11pt; margin: 2cm; color: #222; }
+h1 { font-size: 20pt; margin-bottom: 0; }
+.parties { display: flex; justify-content: space-between; margin: 1.5em 0; white-space: pre-line; }
+table { width: 100%; border-collapse: collapse; margin-top: 1.5em; }
+th, td { padding: 0.3em 0.5em; text-align: left; }
+th { border-bottom: 1px solid #222; }
+.number { text-align: right; }
+tfoot td { border-top: 1px solid #ccc; }
+tfoot tr:last-child td { font-weight: bold; border-top: 1px solid #222; }
+.terms { margin-top: 2em; white-space: pre-line; }
+</style>
+</head>
+<body>
+<h1>Invoice {{ .Number }}</h1>
+<p>Date: {{ date .Date }}<br>Due: {{ date .Due }}<br>Period: {{ .Period }}</p>
+<div class="parties">
+<div>{{ .From }}{{ if .TaxID }}
+Tax ID: {{ .TaxID }}{{ end }}</div>
+<div>{{ .Client.Name }}
+{{ .Client.Address }}{{ if .Client.TaxID }}
+Tax ID: {{ .Client.TaxID }}{{ end }}</div>
+</div>
+<table>
+<thead><tr><th>Project</th><th>Task</th><th class="number">Hours</th><th class="number">Rate</th><th class="number">Amount</th></tr></thead>
+<tbody>
+{{- range .Lines }}
+<tr><td>{{ .Project }}</td><td>{{ .Task }}</td><td class="number">{{ hours .Hours }}</td><td class="number">{{ $.Money .Rate }}</td><td class="number">{{ $.Money .Amount }}</td></tr>
+{{- end }}
+</tbody>
+<tfoot>
+<tr><td colspan="2">Subtotal</td><td class="number">{{ hours .Hours }}</td><td></td><td class="number">{{ .Money .Subtotal }}</td></tr>
+<tr><td colspan="4">{{ .TaxLabel }} {{ .TaxRate }}%</td><td class="number">{{ .Money .Tax }}</td></tr>
+<tr><td colspan="4">Total</td><td class="number">{{ .Money .Total }}</td></tr>
+</tfoot>
+</table>
+{{ if .Terms }}<div class="terms">{{ .Terms }}</div>{{ end }}
+</body>
+</html>
+`
+
+// HTML renders the invoice using the template configured as
+// invoice.template, or the default one.
+func (invoice *Invoice) HTML() ([]byte, error) {
+	source := defaultInvoiceTemplate
+	if file := viper.GetString("invoice.template"); file != "" {
+		content, err := os.ReadFile(ExpandPath(file))
+		if err != nil {
+			return nil, err
+		}
+		source = string(content)
+	}
+
+	tmpl, err := template.New("invoice").Funcs(template.FuncMap{
+		"date":  func(t time.Time) string { return t.Format(DateFormat) },
+		"hours": formatInvoiceHours,
+		"money": FormatMoney,
+	}).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, invoice); err != nil {
+		return nil, fmt.Errorf("invalid invoice template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the invoice as PDF. With invoice.pdfCommand configured, e.g.
+// `weasyprint {input} {output}`, the HTML invoice is converted by it,
+// otherwise a plain PDF is laid out without the template.
+func (invoice *Invoice) PDF() ([]byte, error) {
+	command := strings.Fields(viper.GetString("invoice.pdfCommand"))
+	if len(command) == 0 {
+		return invoice.plainPDF(), nil
+	}
+
+	html, err := invoice.HTML()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "zeit-invoice")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "invoice.html")
+	output := filepath.Join(dir, "invoice.pdf")
+	if err = os.WriteFile(input, html, 0600); err != nil {
+		return nil, err
+	}
+	for idx, arg := range command {
+		command[idx] = strings.NewReplacer("{input}", input, "{output}", output).Replace(arg)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(output)
+}
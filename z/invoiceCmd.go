@@ -0,0 +1,117 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	invoiceClient string
+	invoiceMonth  string
+	invoiceFormat string
+	invoiceOutput string
+	invoiceNumber string
+	invoiceDryRun bool
+)
+
+var invoiceCmd = &cobra.Command{
+	Use:   "invoice ([flags])",
+	Short: "Create an invoice",
+	Long:  "Create an invoice for the billable activities of a client configured as invoice.clients.<client> in a month, using the rates of the projects and the next number of invoice.numberFormat.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if !zeit.ContainsFold(InvoiceFormats(), invoiceFormat) {
+			return fmt.Errorf("unknown format '%s', possible values: %s", invoiceFormat, strings.Join(InvoiceFormats(), ", "))
+		}
+		if invoiceClient == "" {
+			return errors.New("specify the client using --client")
+		}
+		client, err := GetInvoiceClient(invoiceClient)
+		if err != nil {
+			return err
+		}
+
+		var sinceTime time.Time
+		if invoiceMonth == "" {
+			now := time.Now()
+			sinceTime = time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+		} else if sinceTime, err = time.ParseInLocation("2006-01", invoiceMonth, time.Local); err != nil {
+			return fmt.Errorf("invalid month '%s', use YYYY-MM", invoiceMonth)
+		}
+		untilTime := sinceTime.AddDate(0, 1, 0)
+
+		invoice, err := NewInvoice(user, client, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		if invoiceNumber != "" {
+			invoice.Number = invoiceNumber
+		} else if err = invoice.NextNumber(user); err != nil {
+			return err
+		}
+
+		file := invoiceOutput
+		if file == "" {
+			file = "invoice-" + strings.NewReplacer("/", "-", " ", "-").Replace(invoice.Number) + "." + strings.ToLower(invoiceFormat)
+		}
+
+		summary := fmt.Sprintf("invoice %s for %s over %sh, total %s", color.FgLightWhite.Render(invoice.Number), color.FgLightWhite.Render(client.Name), color.FgLightWhite.Render(formatInvoiceHours(invoice.Hours)), color.FgLightWhite.Render(invoice.Money(invoice.Total)))
+		if invoiceDryRun {
+			for _, line := range invoice.Lines {
+				fmt.Printf("%s %s %s: %sh × %s = %s\n", CharMore, line.Project, line.Task, formatInvoiceHours(line.Hours), invoice.Money(line.Rate), invoice.Money(line.Amount))
+			}
+			fmt.Printf("%s dry run: would write %s to %s\n", CharInfo, summary, file)
+			return nil
+		}
+
+		var content []byte
+		if strings.ToLower(invoiceFormat) == InvoiceHTML {
+			content, err = invoice.HTML()
+		} else {
+			content, err = invoice.PDF()
+		}
+		if err != nil {
+			return err
+		}
+
+		if file == "-" {
+			os.Stdout.Write(content)
+		} else {
+			if fileExists(file) {
+				return fmt.Errorf("%s already exists, not overwriting it", file)
+			}
+			if err = os.WriteFile(file, content, 0644); err != nil {
+				return err
+			}
+		}
+
+		if invoiceNumber == "" {
+			if err = invoice.UseNumber(user); err != nil {
+				return err
+			}
+		}
+		if file != "-" {
+			fmt.Printf("%s wrote %s to %s\n", CharInfo, summary, file)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(invoiceCmd)
+	invoiceCmd.Flags().StringVar(&invoiceClient, "client", "", "Client to invoice, configured as invoice.clients.<client>")
+	invoiceCmd.Flags().StringVar(&invoiceMonth, "month", "", "Month to invoice as YYYY-MM (default is last month)")
+	invoiceCmd.Flags().StringVar(&invoiceFormat, "format", InvoicePDF, "Format of the invoice, possible values: "+strings.Join(InvoiceFormats(), ", "))
+	invoiceCmd.Flags().StringVar(&invoiceOutput, "file", "", "File to write the invoice to, or - for stdout (default is invoice-<number>.<format>)")
+	invoiceCmd.Flags().StringVar(&invoiceNumber, "number", "", "Use this invoice number instead of the next one")
+	invoiceCmd.Flags().BoolVar(&invoiceDryRun, "dry-run", false, "Only show the invoice lines and totals")
+}
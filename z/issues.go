@@ -0,0 +1,616 @@
+package z
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+const issuesMetaKey string = "issues:commented"
+
+const issuesPushedMetaKey string = "issues:pushed"
+
+const defaultIssueCommentTemplate string = "Total time tracked with zeit: {{.Duration}}h in {{.Activities}} activities"
+
+const defaultIssuePushTemplate string = "Time spent: {{.Duration}}h, {{.Total}}h in total (tracked with zeit)"
+
+type IssueRepository struct {
+	Repository string `mapstructure:"repository"`
+	Provider   string `mapstructure:"provider"`
+	URL        string `mapstructure:"url"`
+	Token      string `mapstructure:"token"`
+	Prefix     string `mapstructure:"prefix"`
+	Template   string `mapstructure:"template"`
+	// Project the repository belongs to, for bare #123 references and
+	// assigned issues imported as tasks
+	Project string `mapstructure:"project"`
+	// Push is how `zeit push github` reports time, as a comment or, on
+	// GitLab, as the issue's spent time
+	Push         string `mapstructure:"push"`
+	PushTemplate string `mapstructure:"pushTemplate"`
+}
+
+type Issue struct {
+	Repository *IssueRepository
+	Number     int
+}
+
+// AssignedIssue is an open issue assigned to the owner of the token of its
+// repository.
+type AssignedIssue struct {
+	Issue Issue
+	Title string
+}
+
+// IssuePush is the time tracked on an issue since it was last pushed.
+type IssuePush struct {
+	Issue    Issue
+	Duration string
+	Total    string
+
+	duration time.Duration
+	total    time.Duration
+}
+
+type IssueSummary struct {
+	Issue      Issue
+	Duration   string
+	Activities int
+	Projects   []string
+
+	duration time.Duration
+	running  bool
+}
+
+var (
+	githubIssueURL = regexp.MustCompile(`^https?://github\.com/([^/]+/[^/]+)/(?:issues|pull)/(\d+)`)
+	gitlabIssueURL = regexp.MustCompile(`^https?://([^/]+)/(.+?)/-/(?:issues|merge_requests)/(\d+)`)
+	shortIssueRef  = regexp.MustCompile(`^([^\s#]+/[^\s#]+)#(\d+)$`)
+	prefixIssueRef = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*)-(\d+)$`)
+
+	// References within the text of tasks and notes
+	issueRefInText = regexp.MustCompile(`https?://\S+/(?:issues|pull|merge_requests)/\d+|[\w.-]+/[\w./-]+#\d+|\b[A-Za-z][A-Za-z0-9_]*-\d+\b`)
+	bareIssueRef   = regexp.MustCompile(`(?:^|[^\w/#])#(\d+)\b`)
+)
+
+// GetIssueRepositories returns the repositories configured as
+// `issues.repositories`.
+func GetIssueRepositories() ([]IssueRepository, error) {
+	var repositories []IssueRepository
+
+	if err := viper.UnmarshalKey("issues.repositories", &repositories); err != nil {
+		return repositories, fmt.Errorf("invalid issues.repositories: %v", err)
+	}
+
+	for idx := range repositories {
+		repository := &repositories[idx]
+		repository.Provider = strings.ToLower(repository.Provider)
+		if repository.Provider == "" {
+			repository.Provider = IssueProviderGitHub
+		}
+		if repository.Provider != IssueProviderGitHub && repository.Provider != IssueProviderGitLab {
+			return repositories, fmt.Errorf("repository %s has unknown provider '%s', possible values: %s", repository.Repository, repository.Provider, strings.Join(IssueProviders(), ", "))
+		}
+		if repository.Template == "" {
+			repository.Template = defaultIssueCommentTemplate
+		}
+		if repository.PushTemplate == "" {
+			repository.PushTemplate = defaultIssuePushTemplate
+		}
+		repository.Push = strings.ToLower(repository.Push)
+		if repository.Push == "" {
+			repository.Push = IssuePushComment
+			if repository.Provider == IssueProviderGitLab {
+				repository.Push = IssuePushSpent
+			}
+		}
+		if repository.Push != IssuePushComment && repository.Push != IssuePushSpent {
+			return repositories, fmt.Errorf("repository %s has unknown push '%s', possible values: %s, %s", repository.Repository, repository.Push, IssuePushComment, IssuePushSpent)
+		}
+		if repository.Push == IssuePushSpent && repository.Provider != IssueProviderGitLab {
+			return repositories, fmt.Errorf("repository %s: only GitLab issues track spent time, use push: %s", repository.Repository, IssuePushComment)
+		}
+		repository.Token = os.ExpandEnv(repository.Token)
+	}
+
+	return repositories, nil
+}
+
+func IssueProviders() []string {
+	return []string{
+		IssueProviderGitHub,
+		IssueProviderGitLab,
+	}
+}
+
+func (repository *IssueRepository) apiURL() string {
+	if repository.URL != "" {
+		base := strings.TrimSuffix(repository.URL, "/")
+		if repository.Provider == IssueProviderGitLab && !strings.HasSuffix(base, "/api/v4") {
+			base += "/api/v4"
+		}
+		return base
+	}
+
+	if repository.Provider == IssueProviderGitLab {
+		return "https://gitlab.com/api/v4"
+	}
+	return "https://api.github.com"
+}
+
+func (repository *IssueRepository) host() string {
+	if repository.URL != "" {
+		if u, err := url.Parse(repository.URL); err == nil {
+			return u.Host
+		}
+	}
+
+	if repository.Provider == IssueProviderGitLab {
+		return "gitlab.com"
+	}
+	return "github.com"
+}
+
+// ParseIssueReference resolves a reference like a GitHub/GitLab issue URL,
+// `owner/repo#123` or `PREFIX-123` to an issue of one of the configured
+// repositories.
+func ParseIssueReference(reference string, repositories []IssueRepository) (Issue, bool) {
+	reference = strings.TrimSpace(reference)
+
+	var host, path, number string
+	if m := githubIssueURL.FindStringSubmatch(reference); m != nil {
+		host, path, number = "github.com", m[1], m[2]
+	} else if m := gitlabIssueURL.FindStringSubmatch(reference); m != nil {
+		host, path, number = m[1], m[2], m[3]
+	} else if m := shortIssueRef.FindStringSubmatch(reference); m != nil {
+		path, number = m[1], m[2]
+	} else if m := prefixIssueRef.FindStringSubmatch(reference); m != nil {
+		for idx := range repositories {
+			if repositories[idx].Prefix != "" && strings.EqualFold(repositories[idx].Prefix, m[1]) {
+				n, _ := strconv.Atoi(m[2])
+				return Issue{Repository: &repositories[idx], Number: n}, true
+			}
+		}
+		return Issue{}, false
+	} else {
+		return Issue{}, false
+	}
+
+	for idx := range repositories {
+		if !strings.EqualFold(repositories[idx].Repository, path) {
+			continue
+		}
+		if host != "" && !strings.EqualFold(repositories[idx].host(), host) {
+			continue
+		}
+
+		n, _ := strconv.Atoi(number)
+		return Issue{Repository: &repositories[idx], Number: n}, true
+	}
+
+	return Issue{}, false
+}
+
+func (issue *Issue) Key() string {
+	return fmt.Sprintf("%s:%s#%d", issue.Repository.host(), strings.ToLower(issue.Repository.Repository), issue.Number)
+}
+
+func (issue *Issue) String() string {
+	return fmt.Sprintf("%s#%d", issue.Repository.Repository, issue.Number)
+}
+
+// FindEntryIssues returns the issues an entry refers to, by its references
+// or within its task and notes. A bare #123 refers to the repository of the
+// entry's project or, if there is only one, the configured repository.
+func FindEntryIssues(entry Entry, repositories []IssueRepository) []Issue {
+	var issues []Issue
+	seen := make(map[string]bool)
+
+	add := func(issue Issue) {
+		if !seen[issue.Key()] {
+			seen[issue.Key()] = true
+			issues = append(issues, issue)
+		}
+	}
+
+	for _, reference := range entry.References {
+		if issue, ok := ParseIssueReference(reference, repositories); ok {
+			add(issue)
+		}
+	}
+
+	text := entry.Task + "\n" + entry.Notes
+	for _, reference := range issueRefInText.FindAllString(text, -1) {
+		if issue, ok := ParseIssueReference(reference, repositories); ok {
+			add(issue)
+		}
+	}
+
+	var projectRepository *IssueRepository
+	for idx := range repositories {
+		if repositories[idx].Project != "" && strings.EqualFold(repositories[idx].Project, entry.Project) {
+			projectRepository = &repositories[idx]
+			break
+		}
+	}
+	if projectRepository == nil && len(repositories) == 1 {
+		projectRepository = &repositories[0]
+	}
+	if projectRepository != nil {
+		for _, m := range bareIssueRef.FindAllStringSubmatch(text, -1) {
+			n, _ := strconv.Atoi(m[1])
+			add(Issue{Repository: projectRepository, Number: n})
+		}
+	}
+
+	return issues
+}
+
+func (issue *Issue) request(method string, path string, body interface{}, v interface{}) error {
+	return issue.Repository.request(method, path, body, v)
+}
+
+func (repository *IssueRepository) request(method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, repository.apiURL()+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	if repository.Token != "" {
+		if repository.Provider == IssueProviderGitLab {
+			req.Header.Set("PRIVATE-TOKEN", repository.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+repository.Token)
+		}
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", method, req.URL.Redacted(), res.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (issue *Issue) path() string {
+	if issue.Repository.Provider == IssueProviderGitLab {
+		return fmt.Sprintf("/projects/%s/issues/%d", url.PathEscape(issue.Repository.Repository), issue.Number)
+	}
+	return fmt.Sprintf("/repos/%s/issues/%d", issue.Repository.Repository, issue.Number)
+}
+
+// IsClosed fetches the state of the issue from its provider.
+func (issue *Issue) IsClosed() (bool, error) {
+	var state struct {
+		State string `json:"state"`
+	}
+
+	if err := issue.request(http.MethodGet, issue.path(), nil, &state); err != nil {
+		return false, err
+	}
+
+	return state.State == "closed", nil
+}
+
+func (issue *Issue) Comment(body string) error {
+	path := issue.path() + "/comments"
+	if issue.Repository.Provider == IssueProviderGitLab {
+		path = issue.path() + "/notes"
+	}
+
+	return issue.request(http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+// AddSpentTime adds to the time spent on a GitLab issue.
+func (issue *Issue) AddSpentTime(duration time.Duration) error {
+	spent := fmt.Sprintf("%dm", int(duration.Minutes()))
+	return issue.request(http.MethodPost, issue.path()+"/add_spent_time", map[string]string{"duration": spent}, nil)
+}
+
+// AssignedIssues fetches the open issues of the repository assigned to the
+// owner of its token, leaving out pull requests.
+func (repository *IssueRepository) AssignedIssues() ([]AssignedIssue, error) {
+	var assigned []AssignedIssue
+
+	var issues []struct {
+		Number      int             `json:"number"`
+		IID         int             `json:"iid"`
+		Title       string          `json:"title"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	}
+
+	if repository.Provider == IssueProviderGitLab {
+		path := fmt.Sprintf("/projects/%s/issues?scope=assigned_to_me&state=opened&per_page=100", url.PathEscape(repository.Repository))
+		if err := repository.request(http.MethodGet, path, nil, &issues); err != nil {
+			return assigned, err
+		}
+	} else {
+		var user struct {
+			Login string `json:"login"`
+		}
+		if err := repository.request(http.MethodGet, "/user", nil, &user); err != nil {
+			return assigned, err
+		}
+		path := fmt.Sprintf("/repos/%s/issues?assignee=%s&state=open&per_page=100", repository.Repository, url.QueryEscape(user.Login))
+		if err := repository.request(http.MethodGet, path, nil, &issues); err != nil {
+			return assigned, err
+		}
+	}
+
+	for _, issue := range issues {
+		if len(issue.PullRequest) > 0 && string(issue.PullRequest) != "null" {
+			continue
+		}
+		number := issue.Number
+		if repository.Provider == IssueProviderGitLab {
+			number = issue.IID
+		}
+		assigned = append(assigned, AssignedIssue{Issue: Issue{Repository: repository, Number: number}, Title: issue.Title})
+	}
+
+	return assigned, nil
+}
+
+// TaskName returns the name of the task an assigned issue is imported as,
+// e.g. `GH-123 Fix login` or `mrusme/zeit#123 Fix login`, which refers to
+// the issue by itself.
+func (assigned *AssignedIssue) TaskName() string {
+	reference := assigned.Issue.String()
+	if assigned.Issue.Repository.Prefix != "" {
+		reference = fmt.Sprintf("%s-%d", assigned.Issue.Repository.Prefix, assigned.Issue.Number)
+	}
+	return strings.TrimSpace(reference + " " + assigned.Title)
+}
+
+// GetIssueSummaries sums up the tracked time per referenced issue of the
+// configured repositories.
+func GetIssueSummaries(entries []Entry, repositories []IssueRepository) []IssueSummary {
+	summaries := make(map[string]*IssueSummary)
+
+	for _, entry := range entries {
+		for _, issue := range FindEntryIssues(entry, repositories) {
+			summary, ok := summaries[issue.Key()]
+			if !ok {
+				summary = &IssueSummary{Issue: issue}
+				summaries[issue.Key()] = summary
+			}
+			summary.duration += entry.End().Sub(entry.Begin)
+			summary.Activities++
+			summary.running = summary.running || entry.Finish.IsZero()
+			if entry.Project != "" && !zeit.ContainsFold(summary.Projects, entry.Project) {
+				summary.Projects = append(summary.Projects, entry.Project)
+			}
+		}
+	}
+
+	var list []IssueSummary
+	for _, summary := range summaries {
+		summary.Duration = fmtDuration(summary.duration)
+		sort.Strings(summary.Projects)
+		list = append(list, *summary)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Issue.Key() < list[j].Issue.Key() })
+
+	return list
+}
+
+func (summary *IssueSummary) Comment() (string, error) {
+	tmpl, err := template.New("comment").Parse(summary.Issue.Repository.Template)
+	if err != nil {
+		return "", fmt.Errorf("invalid comment template of repository %s: %v", summary.Issue.Repository.Repository, err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, summary); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func GetCommentedIssues(user string) (map[string]time.Time, error) {
+	commented := make(map[string]time.Time)
+
+	value, err := database.GetMeta(user, issuesMetaKey)
+	if err != nil || value == "" {
+		return commented, err
+	}
+
+	if err = json.Unmarshal([]byte(value), &commented); err != nil {
+		return commented, errors.New("could not read the list of commented issues")
+	}
+
+	return commented, nil
+}
+
+func UpdateCommentedIssues(user string, commented map[string]time.Time) error {
+	value, err := json.Marshal(commented)
+	if err != nil {
+		return err
+	}
+
+	return database.SetMeta(user, issuesMetaKey, string(value))
+}
+
+// GetIssuePushes returns the time tracked on every issue referenced by
+// finished entries since it was last pushed, as recorded in pushed.
+func GetIssuePushes(entries []Entry, repositories []IssueRepository, pushed map[string]int64) []IssuePush {
+	totals := make(map[string]*IssuePush)
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			continue
+		}
+		for _, issue := range FindEntryIssues(entry, repositories) {
+			push, ok := totals[issue.Key()]
+			if !ok {
+				push = &IssuePush{Issue: issue}
+				totals[issue.Key()] = push
+			}
+			push.total += entry.Finish.Sub(entry.Begin)
+		}
+	}
+
+	var list []IssuePush
+	for key, push := range totals {
+		push.duration = push.total - time.Duration(pushed[key])*time.Second
+		if push.duration < time.Minute {
+			continue
+		}
+		push.Duration = fmtDuration(push.duration)
+		push.Total = fmtDuration(push.total)
+		list = append(list, *push)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Issue.Key() < list[j].Issue.Key() })
+
+	return list
+}
+
+// Push reports the time spent to the issue, as configured for its
+// repository, and returns how it did.
+func (push *IssuePush) Push() (string, error) {
+	if push.Issue.Repository.Push == IssuePushSpent {
+		return "added spent time", push.Issue.AddSpentTime(push.duration)
+	}
+
+	tmpl, err := template.New("push").Parse(push.Issue.Repository.PushTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid push template of repository %s: %v", push.Issue.Repository.Repository, err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, push); err != nil {
+		return "", err
+	}
+
+	return "commented", push.Issue.Comment(buf.String())
+}
+
+func GetPushedIssues(user string) (map[string]int64, error) {
+	pushed := make(map[string]int64)
+
+	value, err := database.GetMeta(user, issuesPushedMetaKey)
+	if err != nil || value == "" {
+		return pushed, err
+	}
+
+	if err = json.Unmarshal([]byte(value), &pushed); err != nil {
+		return pushed, errors.New("could not read the list of pushed issues")
+	}
+
+	return pushed, nil
+}
+
+// UpdatePushedIssues records the total time pushed to the issue.
+func UpdatePushedIssues(user string, pushed map[string]int64, push IssuePush) error {
+	pushed[push.Issue.Key()] = int64(push.total.Seconds())
+
+	value, err := json.Marshal(pushed)
+	if err != nil {
+		return err
+	}
+
+	return database.SetMeta(user, issuesPushedMetaKey, string(value))
+}
+
+// ImportAssignedIssues stores the open issues assigned to the user in the
+// repositories as tasks and erases the tasks of issues that are no longer,
+// and returns the tasks imported. Repositories that could not be read are
+// reported to failed and keep their tasks.
+func ImportAssignedIssues(user string, repositories []IssueRepository, failed func(IssueRepository, error)) ([]Task, error) {
+	var imported []Task
+	current := make(map[string]bool)
+	checked := make(map[string]bool)
+
+	for _, repository := range repositories {
+		assignedIssues, err := repository.AssignedIssues()
+		if err != nil {
+			failed(repository, err)
+			continue
+		}
+		checked[repository.host()+":"+strings.ToLower(repository.Repository)] = true
+
+		for _, assigned := range assignedIssues {
+			task := Task{Name: assigned.TaskName(), Project: repository.Project, Issue: assigned.Issue.Key()}
+			if existing, err := database.GetTask(user, task.Name); err == nil {
+				task.GitRepository = existing.GitRepository
+			}
+			if err := database.UpdateTask(user, task.Name, task); err != nil {
+				return imported, err
+			}
+			current[task.Name] = true
+			imported = append(imported, task)
+		}
+	}
+
+	tasks, err := database.ListTasks(user)
+	if err != nil {
+		return imported, err
+	}
+	for _, task := range tasks {
+		repositoryKey, _, _ := strings.Cut(task.Issue, "#")
+		if task.Issue == "" || current[task.Name] || !checked[repositoryKey] {
+			continue
+		}
+		if err := database.EraseTask(user, task.Name); err != nil {
+			return imported, err
+		}
+	}
+
+	return imported, nil
+}
+
+// GetIssueTasks returns the names of the tasks imported from assigned issues
+// for the project, or all of them without a project.
+func GetIssueTasks(user string, project string) []string {
+	tasks, err := database.ListTasks(user)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, task := range tasks {
+		if task.Issue == "" {
+			continue
+		}
+		if project != "" && task.Project != "" && !strings.EqualFold(task.Project, project) {
+			continue
+		}
+		names = append(names, task.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,89 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var issuesDryRun bool
+
+var issuesCmd = &cobra.Command{
+	Use:   "issues",
+	Short: "Comment tracked time on closed issues",
+	Long:  "Check the GitHub/GitLab issues referenced by activities of the configured repositories and post a comment with the total time tracked on every issue that was closed, once per issue. Meant to be run periodically, e.g. from cron.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		repositories, err := GetIssueRepositories()
+		if err != nil {
+			return err
+		}
+		if len(repositories) == 0 {
+			fmt.Printf("%s no repositories configured in issues.repositories\n", CharInfo)
+			return nil
+		}
+
+		entries, err := database.ListEntries(user)
+		if err != nil {
+			return err
+		}
+
+		commented, err := GetCommentedIssues(user)
+		if err != nil {
+			return err
+		}
+
+		failed := false
+		for _, summary := range GetIssueSummaries(entries, repositories) {
+			issue := summary.Issue
+			if _, ok := commented[issue.Key()]; ok || summary.running {
+				continue
+			}
+
+			closed, err := issue.IsClosed()
+			if err != nil {
+				fmt.Printf("%s %s: %+v\n", CharError, issue.String(), err)
+				failed = true
+				continue
+			}
+			if !closed {
+				continue
+			}
+
+			comment, err := summary.Comment()
+			if err != nil {
+				return err
+			}
+
+			if issuesDryRun {
+				fmt.Printf("%s %s would be commented: %s\n", CharInfo, color.FgLightWhite.Render(issue.String()), comment)
+				continue
+			}
+
+			if err = issue.Comment(comment); err != nil {
+				fmt.Printf("%s %s: %+v\n", CharError, issue.String(), err)
+				failed = true
+				continue
+			}
+
+			commented[issue.Key()] = time.Now()
+			if err = UpdateCommentedIssues(user, commented); err != nil {
+				return err
+			}
+			fmt.Printf("%s commented %sh on %s\n", CharFinish, color.FgLightWhite.Render(summary.Duration), color.FgLightWhite.Render(issue.String()))
+		}
+
+		if failed {
+			return fmt.Errorf("some issues could not be checked or commented")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(issuesCmd)
+	issuesCmd.Flags().BoolVar(&issuesDryRun, "dry-run", false, "Only show which issues would be commented")
+}
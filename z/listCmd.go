@@ -1,9 +1,15 @@
 package z
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 )
@@ -13,46 +19,215 @@ var (
 	listOnlyProjectsAndTasks bool
 	listOnlyTasks            bool
 	appendProjectIDToTask    bool
+	listFollow               bool
+	listSearch               string
+	listRunning              bool
+	listBillable             bool
+	listFormat               string
+	listGroupBy              string
+	listLimit                int
+	listOffset               int
+	listReverse              bool
 )
 
 var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List activities",
-	Long:  "List all tracked activities.",
-	Run: func(cmd *cobra.Command, args []string) {
-		filteredEntries := listEntries()
+	Use:         "list",
+	Short:       "List activities",
+	Long:        "List all tracked activities, or only the ones matching all of the given filters.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listLimit < 0 || listOffset < 0 {
+			return fmt.Errorf("--limit and --offset can't be negative")
+		}
+
+		if listFollow {
+			if listOnlyProjectsAndTasks || listOnlyTasks {
+				return errors.New("--follow can't be used with --only-projects-and-tasks or --only-tasks")
+			}
+
+			return Follow(func() (string, error) {
+				entries, err := listLatestEntries()
+				if err != nil {
+					return "", err
+				}
+				return listOutput(entries)
+			})
+		}
+
+		if listOnlyProjectsAndTasks || listOnlyTasks {
+			// The projects and tasks are printed while listing
+			_, err := listEntries()
+			return err
+		}
+
+		entries, err := listLatestEntries()
+		if err != nil {
+			return err
+		}
+		output, err := listOutput(entries)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+// ListGroupOutput is a group of listed activities with --output json.
+type ListGroupOutput struct {
+	Label        string          `json:"label"`
+	TotalSeconds int64           `json:"totalSeconds"`
+	Entries      []EntryResource `json:"entries"`
+}
+
+func listOutput(filteredEntries []Entry) (string, error) {
+	if IsOutputJSON() {
+		return listJSONOutput(filteredEntries)
+	}
+
+	var output strings.Builder
+
+	var tmpl *template.Template
+	if listFormat != "" {
+		var err error
+		if tmpl, err = ParseListFormat(listFormat); err != nil {
+			return "", err
+		}
+	} else if header := listBudgetHeader(); header != "" {
+		fmt.Fprintf(&output, "%s\n\n", header)
+	}
+
+	if listGroupBy != "" {
+		groups, err := GroupListEntries(filteredEntries, listGroupBy)
+		if err != nil {
+			return "", err
+		}
 
+		totalHours := decimal.NewFromInt(0)
+		for _, group := range groups {
+			fmt.Fprintf(&output, "%s %s\n", CharInfo, color.FgLightWhite.Render(group.Label))
+			if err := listEntriesOutput(&output, group.Entries, tmpl); err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&output, "  SUBTOTAL: %s H\n\n", fmtHours(group.Hours))
+			totalHours = totalHours.Add(group.Hours)
+		}
+		fmt.Fprintf(&output, "TOTAL: %s H\n", fmtHours(totalHours))
+
+		return output.String(), nil
+	}
+
+	if err := listEntriesOutput(&output, filteredEntries, tmpl); err != nil {
+		return "", err
+	}
+
+	if listTotalTime == true {
 		totalHours := decimal.NewFromInt(0)
 		for _, entry := range filteredEntries {
 			totalHours = totalHours.Add(entry.GetDuration())
-			fmt.Printf("%s\n", entry.GetOutput(false))
+		}
+		fmt.Fprintf(&output, "\nTOTAL: %s H\n\n", fmtHours(totalHours))
+	}
+
+	return output.String(), nil
+}
+
+// listJSONOutput returns the entries, or their groups, as JSON. Followed
+// lists are printed as one array per line.
+func listJSONOutput(filteredEntries []Entry) (string, error) {
+	var list interface{} = entryResources(filteredEntries)
+	if listGroupBy != "" {
+		groups, err := GroupListEntries(filteredEntries, listGroupBy)
+		if err != nil {
+			return "", err
 		}
 
-		if listTotalTime == true {
-			fmt.Printf("\nTOTAL: %s H\n\n", fmtHours(totalHours))
+		groupOutputs := []ListGroupOutput{}
+		for _, group := range groups {
+			groupOutputs = append(groupOutputs, ListGroupOutput{
+				Label:        group.Label,
+				TotalSeconds: int64(zeit.Sum(group.Entries, time.Time{}, time.Time{}).Seconds()),
+				Entries:      entryResources(group.Entries),
+			})
 		}
-		return
-	},
+		list = groupOutputs
+	}
+
+	var stringified []byte
+	var err error
+	if listFollow {
+		stringified, err = json.Marshal(list)
+	} else {
+		stringified, err = json.MarshalIndent(list, "", "  ")
+	}
+	return string(stringified) + "\n", err
+}
+
+// listEntriesOutput writes one line per entry, formatted using tmpl if set.
+func listEntriesOutput(output *strings.Builder, entries []Entry, tmpl *template.Template) error {
+	for _, entry := range entries {
+		if tmpl == nil {
+			fmt.Fprintf(output, "%s\n", GetEntryOutput(entry, false))
+			continue
+		}
+
+		line, err := FormatListEntry(tmpl, entry)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "%s\n", line)
+	}
+	return nil
+}
+
+// listBudgetHeader returns the budget status of the listed project, if it has a
+// budget.
+func listBudgetHeader() string {
+	if project == "" {
+		return ""
+	}
+
+	user := GetCurrentUser()
+	budgetProject, err := database.GetProject(user, project)
+	if err != nil || !budgetProject.Budget.IsSet() {
+		return ""
+	}
+	if budgetProject.Name == "" {
+		budgetProject.Name = project
+	}
+
+	status, err := GetProjectBudgetStatus(user, budgetProject)
+	if err != nil {
+		return fmt.Sprintf("%s %+v", CharError, err)
+	}
+	return fmt.Sprintf("%s %s budget: %s", CharInfo, color.FgLightWhite.Render(status.Project), status.GetOutput())
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().StringVar(&since, "since", "", "Date/time to start the list from")
 	listCmd.Flags().StringVar(&until, "until", "", "Date/time to list until")
-	listCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until that accepts: "+strings.Join(Ranges(), ", "))
+	listCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
 	listCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be listed")
 	listCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be listed")
+	listCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Only list activities with any of the given attendees (comma separated)")
+	listCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Only list activities with any of the given tags (comma separated)")
+	listCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Only list activities with any of the given references (comma separated)")
+	listCmd.Flags().StringVar(&listSearch, "search", "", "Only list activities with notes containing the given text")
+	listCmd.Flags().BoolVar(&listRunning, "running", false, "Only list the running activity")
+	listCmd.Flags().BoolVar(&listBillable, "billable", false, "Only list activities on billable projects")
+	listCmd.Flags().StringVar(&listFormat, "format", "", "Go template to print each activity with, e.g. '{{.Begin}} {{.Duration}} {{.Project}}/{{.Task}}', or the name of a preset in list.formats")
+	listCmd.Flags().StringVar(&listGroupBy, "group-by", "", "Group the activities with subtotals and a total, possible values: "+strings.Join(ListGroupings(), ", "))
+	listCmd.Flags().IntVar(&listLimit, "limit", 0, "List at most this many activities (default is all)")
+	listCmd.Flags().IntVar(&listOffset, "offset", 0, "Skip this many activities before listing, e.g. to page with --limit")
+	listCmd.Flags().BoolVar(&listReverse, "reverse", false, "List the oldest activities first instead of the newest")
 	listCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
 	listCmd.Flags().BoolVar(&listTotalTime, "total", false, "Show total time of hours for listed activities")
 	listCmd.Flags().BoolVar(&listOnlyProjectsAndTasks, "only-projects-and-tasks", false, "Only list projects and their tasks, no entries")
 	listCmd.Flags().BoolVar(&listOnlyTasks, "only-tasks", false, "Only list tasks, no projects nor entries")
+	listCmd.Flags().BoolVar(&listFollow, "follow", false, "Keep the list updated as the running activity ticks and new activities are tracked")
 	listCmd.Flags().BoolVar(&appendProjectIDToTask, "append-project-id-to-task", false, "Append project ID to tasks in the list")
 
-	flagName := "task"
-	listCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	listCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	listCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
@@ -0,0 +1,88 @@
+package z
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ListFormatEntry is what a list format template is executed with, e.g.
+//
+//	{{.Begin}} {{.Duration}} {{.Project}}/{{.Task}}
+//
+// Begin and Finish are formatted like the default list output, BeginTime
+// and FinishTime allow other layouts, e.g. {{.BeginTime.Format "15:04"}}.
+type ListFormatEntry struct {
+	ID         string
+	Date       string
+	Begin      string
+	Finish     string
+	BeginTime  time.Time
+	FinishTime time.Time
+	Duration   string
+	Hours      string
+	Project    string
+	Task       string
+	Notes      string
+	User       string
+	Attendees  []string
+	Tags       []string
+	References []string
+	Running    bool
+}
+
+func NewListFormatEntry(entry Entry) ListFormatEntry {
+	finish := entry.End()
+
+	return ListFormatEntry{
+		ID:         entry.ID,
+		Date:       entry.Begin.Format(DateFormat),
+		Begin:      entry.Begin.Format(GetTimeDisplayFormat()),
+		Finish:     finish.Format(GetTimeDisplayFormat()),
+		BeginTime:  entry.Begin,
+		FinishTime: finish,
+		Duration:   fmtDuration(finish.Sub(entry.Begin)),
+		Hours:      fmtHours(entry.GetDuration()),
+		Project:    entry.Project,
+		Task:       entry.Task,
+		Notes:      entry.Notes,
+		User:       entry.User,
+		Attendees:  entry.Attendees,
+		Tags:       entry.Tags,
+		References: entry.References,
+		Running:    entry.Finish.IsZero(),
+	}
+}
+
+// ParseListFormat parses the format as a Go template, or, if it is the name
+// of a preset configured as list.formats.<name>, the preset. Besides the
+// builtin functions templates can use join, e.g. {{join .Tags ","}}, and
+// script to call functions of scripts, e.g. {{script "client" .Project}}.
+func ParseListFormat(format string) (*template.Template, error) {
+	name := "list"
+	if preset := viper.GetString("list.formats." + format); preset != "" {
+		name = format
+		format = preset
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join":   strings.Join,
+		"script": CallScriptFunction,
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list format: %v", err)
+	}
+	return tmpl, nil
+}
+
+func FormatListEntry(tmpl *template.Template, entry Entry) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, NewListFormatEntry(entry)); err != nil {
+		return "", fmt.Errorf("could not format activity %s: %v", entry.ID, err)
+	}
+	return buf.String(), nil
+}
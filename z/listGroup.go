@@ -0,0 +1,94 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/shopspring/decimal"
+)
+
+func ListGroupings() []string {
+	return []string{
+		ListGroupByDay,
+		ListGroupByWeek,
+		ListGroupByProject,
+		ListGroupByTask,
+		ListGroupByClient,
+	}
+}
+
+// ListGroup are the listed activities of a day, week, project, task or client.
+type ListGroup struct {
+	Label   string
+	Entries []Entry
+	Hours   decimal.Decimal
+}
+
+// GroupListEntries groups the entries by groupBy. Days and weeks keep the
+// order of the entries, projects, tasks and clients are sorted by name.
+func GroupListEntries(entries []Entry, groupBy string) ([]ListGroup, error) {
+	var labelOf func(entry Entry) string
+
+	switch strings.ToLower(groupBy) {
+	case ListGroupByDay:
+		labelOf = func(entry Entry) string { return entry.Begin.Format("Mon " + DateFormat) }
+	case ListGroupByWeek:
+		if IsFirstWeekDayMonday() {
+			now.WeekStartDay = time.Monday
+		}
+		labelOf = func(entry Entry) string {
+			return "Week of " + now.With(entry.Begin).BeginningOfWeek().Format(DateFormat)
+		}
+	case ListGroupByProject:
+		labelOf = func(entry Entry) string { return listGroupLabel(entry.Project, "(no project)") }
+	case ListGroupByTask:
+		labelOf = func(entry Entry) string { return listGroupLabel(entry.Task, "(no task)") }
+	case ListGroupByClient:
+		clients, err := GetInvoiceClients()
+		if err != nil {
+			return nil, err
+		}
+		clientOf := make(map[string]string)
+		for _, client := range clients {
+			for _, project := range client.Projects {
+				clientOf[strings.ToLower(project)] = client.Name
+			}
+		}
+		labelOf = func(entry Entry) string {
+			return listGroupLabel(clientOf[strings.ToLower(entry.Project)], "(no client)")
+		}
+	default:
+		return nil, fmt.Errorf("unknown grouping '%s', possible values: %s", groupBy, strings.Join(ListGroupings(), ", "))
+	}
+
+	var groups []ListGroup
+	index := make(map[string]int)
+	for _, entry := range entries {
+		label := labelOf(entry)
+		idx, ok := index[label]
+		if !ok {
+			idx = len(groups)
+			index[label] = idx
+			groups = append(groups, ListGroup{Label: label, Hours: decimal.Zero})
+		}
+		groups[idx].Entries = append(groups[idx].Entries, entry)
+		groups[idx].Hours = groups[idx].Hours.Add(entry.GetDuration())
+	}
+
+	switch strings.ToLower(groupBy) {
+	case ListGroupByProject, ListGroupByTask, ListGroupByClient:
+		sort.SliceStable(groups, func(i, j int) bool { return strings.ToLower(groups[i].Label) < strings.ToLower(groups[j].Label) })
+	}
+
+	return groups, nil
+}
+
+func listGroupLabel(name string, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
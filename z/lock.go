@@ -0,0 +1,59 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+type Lock = zeit.Lock
+
+type LockedError = zeit.LockedError
+
+// AcquireLock takes the lock on the database like zeit.AcquireLock, telling
+// the user what is going on while waiting for it.
+func AcquireLock(path string, exclusive bool, wait bool) (*Lock, error) {
+	lock, err := zeit.AcquireLock(path, exclusive, false)
+	var lerr *LockedError
+	if !errors.As(err, &lerr) {
+		return lock, err
+	}
+	if !wait {
+		return nil, fmt.Errorf("%w; use --wait to wait until it is released", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s waiting for the database to be unlocked ...\n", CharInfo)
+	return zeit.AcquireLock(path, exclusive, true)
+}
+
+// IsReadOnlyCommand reports whether the command only reads from the database
+// and can therefore share the lock with other readers.
+func IsReadOnlyCommand(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+
+	if name, ok := cmd.Annotations[AnnotationWritesWith]; ok {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			return false
+		}
+	}
+
+	return cmd.Annotations[AnnotationReadOnly] == "true"
+}
+
+// IsNoDatabaseCommand reports whether the database is not opened before
+// running the command.
+func IsNoDatabaseCommand(cmd *cobra.Command) bool {
+	if name, ok := cmd.Annotations[AnnotationNoDatabaseWith]; ok {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			return true
+		}
+	}
+
+	return cmd.Annotations[AnnotationNoDatabase] == "true"
+}
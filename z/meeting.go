@@ -0,0 +1,85 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+type MeetingCost struct {
+	Meetings int
+	Duration time.Duration
+	Cost     decimal.Decimal
+}
+
+// GetAttendeeRate returns the hourly rate of an attendee, configured as
+// `meetings.rates.<attendee>`, falling back to `meetings.rate`.
+func GetAttendeeRate(attendee string) decimal.Decimal {
+	rate := viper.GetString("meetings.rates." + attendee)
+	if rate == "" {
+		rate = viper.GetString("meetings.rate")
+	}
+
+	parsed, err := decimal.NewFromString(rate)
+	if err != nil {
+		return decimal.Zero
+	}
+
+	return parsed
+}
+
+// GetMeetingCost estimates the cost of a meeting by multiplying its duration
+// with the rates of all attendees, including the user themselves. The user's
+// own rate is the project rate if one is set.
+func GetMeetingCost(user string, entry Entry, project Project) decimal.Decimal {
+	ownRate := project.Rate
+	if ownRate.IsZero() {
+		ownRate = GetAttendeeRate(user)
+	}
+
+	rates := ownRate
+	for _, attendee := range entry.Attendees {
+		rates = rates.Add(GetAttendeeRate(attendee))
+	}
+
+	hours := decimal.NewFromFloat(entry.End().Sub(entry.Begin).Hours())
+	return rates.Mul(hours)
+}
+
+// GetMeetingCostsByWeek sums up the cost of all entries with attendees per
+// ISO week and project.
+func GetMeetingCostsByWeek(user string, entries []Entry) (map[string]map[string]MeetingCost, error) {
+	costs := make(map[string]map[string]MeetingCost)
+	projects := make(map[string]Project)
+
+	for _, entry := range entries {
+		if len(entry.Attendees) == 0 {
+			continue
+		}
+
+		project, ok := projects[entry.Project]
+		if !ok {
+			var err error
+			if project, err = database.GetProject(user, entry.Project); err != nil {
+				return costs, err
+			}
+			projects[entry.Project] = project
+		}
+
+		year, week := entry.Begin.ISOWeek()
+		weekKey := fmt.Sprintf("%04d-%02d", year, week)
+		if _, ok := costs[weekKey]; !ok {
+			costs[weekKey] = make(map[string]MeetingCost)
+		}
+
+		meetingCost := costs[weekKey][entry.Project]
+		meetingCost.Meetings++
+		meetingCost.Duration += entry.End().Sub(entry.Begin)
+		meetingCost.Cost = meetingCost.Cost.Add(GetMeetingCost(user, entry, project))
+		costs[weekKey][entry.Project] = meetingCost
+	}
+
+	return costs, nil
+}
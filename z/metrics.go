@@ -0,0 +1,137 @@
+package z
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// Metrics are what `zeit metrics` and `zeit serve` expose in the text format
+// of Prometheus on /metrics, e.g. to alert when nothing is tracked.
+type Metrics struct {
+	User     string
+	Tracking bool
+	Running  Entry
+	// Today is the time tracked today per project, including the running
+	// activity.
+	Today       map[string]time.Duration
+	Entries     int
+	LastTracked time.Time
+}
+
+// GetMetrics gathers the metrics of the user at now.
+func GetMetrics(user string, now time.Time) (Metrics, error) {
+	metrics := Metrics{User: user, Today: make(map[string]time.Duration)}
+
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return metrics, err
+	}
+	metrics.Entries = len(entries)
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			metrics.Tracking = true
+			metrics.Running = entry
+		}
+		if end := entry.End(); end.After(metrics.LastTracked) {
+			metrics.LastTracked = end
+		}
+		if duration := zeit.ClippedDuration(entry, day, now); duration > 0 {
+			metrics.Today[entry.Project] += duration
+		}
+	}
+	return metrics, nil
+}
+
+// metricsLabel escapes a label value as the text format requires.
+func metricsLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// WriteMetrics writes the metrics in the text format of Prometheus.
+func WriteMetrics(w io.Writer, metrics Metrics, now time.Time) {
+	user := `user="` + metricsLabel(metrics.User) + `"`
+	write := func(name string, kind string, help string, samples ...string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, sample := range samples {
+			fmt.Fprintf(w, "%s%s\n", name, sample)
+		}
+	}
+
+	tracking := 0
+	if metrics.Tracking {
+		tracking = 1
+	}
+	write("zeit_tracking", "gauge", "Whether an activity is running.",
+		fmt.Sprintf("{%s} %d", user, tracking))
+
+	var running []string
+	if metrics.Tracking {
+		running = append(running, fmt.Sprintf(`{%s,project="%s",task="%s"} %g`,
+			user, metricsLabel(metrics.Running.Project), metricsLabel(metrics.Running.Task), now.Sub(metrics.Running.Begin).Seconds()))
+	}
+	write("zeit_running_seconds", "gauge", "Seconds the running activity is running for.", running...)
+
+	projects := make([]string, 0, len(metrics.Today))
+	for project := range metrics.Today {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	var today []string
+	for _, project := range projects {
+		today = append(today, fmt.Sprintf(`{%s,project="%s"} %g`, user, metricsLabel(project), metrics.Today[project].Seconds()))
+	}
+	write("zeit_today_seconds", "gauge", "Seconds tracked today per project.", today...)
+
+	write("zeit_entries", "gauge", "Number of tracked activities.",
+		fmt.Sprintf("{%s} %d", user, metrics.Entries))
+
+	var lastTracked []string
+	if !metrics.LastTracked.IsZero() {
+		lastTracked = append(lastTracked, fmt.Sprintf("{%s} %d", user, metrics.LastTracked.Unix()))
+	}
+	write("zeit_last_tracked_timestamp_seconds", "gauge", "Unix time the latest activity finished, or now while one is running.", lastTracked...)
+}
+
+// serveMetrics handles /metrics for the user the request authenticates as.
+func (server *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var metrics Metrics
+	now := time.Now()
+
+	status, v := server.call(false, func() (int, interface{}, error) {
+		if err := checkOrigin(r); err != nil {
+			return 0, nil, err
+		}
+		user, authorized, err := server.authenticate(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !authorized {
+			return 0, nil, errUnauthorized
+		}
+		metrics, err = GetMetrics(user, now)
+		return http.StatusOK, nil, err
+	})
+
+	if status != http.StatusOK {
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		if output, ok := v.(ErrorOutput); ok {
+			fmt.Fprintln(w, output.Error.Message)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteMetrics(w, metrics, now)
+}
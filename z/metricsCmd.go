@@ -0,0 +1,62 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	metricsListen   string
+	metricsInsecure bool
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show Prometheus metrics",
+	Long:  "Print whether an activity is running, the time tracked today per project and the number of activities in the text format of Prometheus, e.g. for the textfile collector of the node exporter. Using --listen, they are served on /metrics instead, the same way `zeit serve` does.",
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		AnnotationReadOnly:       "true",
+		AnnotationNoDatabaseWith: "listen",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if metricsListen == "" {
+			now := time.Now()
+			metrics, err := GetMetrics(user, now)
+			if err != nil {
+				return err
+			}
+			WriteMetrics(os.Stdout, metrics, now)
+			return nil
+		}
+
+		if viper.GetString("remote.url") != "" {
+			return errors.New("zeit metrics --listen can't be used with remote.url configured")
+		}
+
+		server := NewServer(user, viper.GetString("server.token"))
+		server.Insecure = metricsInsecure
+		if err := printCreatedAPIToken(server, "metrics"); err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", server.serveMetrics)
+		fmt.Printf("%s serving metrics on %s\n", CharInfo, color.FgLightWhite.Render("http://"+metricsListen+"/metrics"))
+		return http.ListenAndServe(metricsListen, mux)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+	metricsCmd.Flags().StringVar(&metricsListen, "listen", "", "Serve the metrics on this address, e.g. 127.0.0.1:9350, instead of printing them")
+	metricsCmd.Flags().BoolVar(&metricsInsecure, "insecure", false, "Serve the metrics without a token unless server.token is set or API tokens exist, instead of creating an API token")
+}
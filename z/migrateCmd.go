@@ -0,0 +1,157 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	migrateFrom string
+	migrateTo   string
+)
+
+func isEmptyFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == 0
+}
+
+func moveFile(from string, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	// Renaming fails across file systems, fall back to copying
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(to)
+		return err
+	}
+
+	if err = target.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+
+	return os.Remove(from)
+}
+
+func findMigrationSource() (string, error) {
+	if migrateFrom != "" {
+		return ExpandPath(migrateFrom), nil
+	}
+
+	if db := viper.GetString("db"); db != GetDefaultDatabasePath() {
+		return db, nil
+	}
+
+	for _, path := range GetLegacyDatabasePaths() {
+		if fileExists(path) {
+			return path, nil
+		}
+	}
+
+	return "", errors.New("no database found to migrate, specify one using --from")
+}
+
+// backupMigrationSource opens the database to migrate just long enough to
+// create an automatic backup of it.
+func backupMigrationSource(from string) error {
+	db, err := InitDatabase(from)
+	if err != nil {
+		return &StorageError{Err: err}
+	}
+
+	database = db
+	defer func() {
+		db.Close()
+		database = nil
+	}()
+
+	return AutoBackup(GetCurrentUser(), "migrate")
+}
+
+var migrateCmd = &cobra.Command{
+	Use:         "migrate",
+	Short:       "Move database to the XDG data directory",
+	Long:        "Move an existing database, by default the one currently configured or found at a legacy location like ~/.config/zeit.db, to $XDG_DATA_HOME/zeit/zeit.db, after an automatic backup of it.",
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, err := findMigrationSource()
+		if err != nil {
+			return err
+		}
+
+		to := GetDefaultDatabasePath()
+		if migrateTo != "" {
+			to = ExpandPath(migrateTo)
+		}
+
+		switch {
+		case IsPostgresDSN(from):
+			return errors.New("PostgreSQL databases cannot be migrated")
+		case IsGitStorage(from):
+			return errors.New("git repositories cannot be migrated")
+		case !fileExists(from):
+			return fmt.Errorf("database %s does not exist", from)
+		case fileExists(to) && !isEmptyFile(to):
+			return fmt.Errorf("%s already exists, refusing to overwrite it", to)
+		}
+
+		if err = os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+			return err
+		}
+
+		// Make sure no other zeit process is using either database while moving
+		lock, err := AcquireLock(from+".lock", true, viper.GetBool(FlagWait))
+		if err != nil {
+			return err
+		}
+		if _, err = AcquireLock(to+".lock", true, viper.GetBool(FlagWait)); err != nil {
+			return err
+		}
+
+		if err = backupMigrationSource(from); err != nil {
+			return err
+		}
+
+		if err = moveFile(from, to); err != nil {
+			return err
+		}
+
+		lock.File.Close()
+		os.Remove(from + ".lock")
+
+		fmt.Printf("%s moved database from %s to %s\n", CharInfo, color.FgLightWhite.Render(from), color.FgLightWhite.Render(to))
+		if to == GetDefaultDatabasePath() {
+			fmt.Printf("%s zeit uses it by default; remove ZEIT_DB from your environment and `db` from your config, if set\n", CharMore)
+		} else {
+			fmt.Printf("%s `export ZEIT_DB=%s` or set `db` in your config to use it\n", CharMore, to)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Database to migrate (default is the current or a legacy database)")
+	migrateCmd.Flags().StringVar(&migrateTo, "to", "", "Location to move the database to (default is $XDG_DATA_HOME/zeit/zeit.db)")
+}
@@ -0,0 +1,211 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type MonthTotal struct {
+	Name    string `json:"name"`
+	Seconds int64  `json:"seconds"`
+}
+
+// MonthOverview sums up a month by project and by client, the client being
+// the one of invoice.clients a project is billed to.
+type MonthOverview struct {
+	Month               string       `json:"month"`
+	Projects            []MonthTotal `json:"projects"`
+	Clients             []MonthTotal `json:"clients"`
+	TotalSeconds        int64        `json:"totalSeconds"`
+	BillableSeconds     int64        `json:"billableSeconds"`
+	NonBillableSeconds  int64        `json:"nonBillableSeconds"`
+	TrackedDays         int          `json:"trackedDays"`
+	AverageDailySeconds int64        `json:"averageDailySeconds"`
+}
+
+// ParseMonth reads a month like 2024-03.
+func ParseMonth(value string) (time.Time, error) {
+	month, err := time.ParseInLocation("2006-01", strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return month, fmt.Errorf("invalid month '%s', use e.g. 2024-03", value)
+	}
+	return month, nil
+}
+
+func monthTotals(totals map[string]*MonthTotal) []MonthTotal {
+	list := []MonthTotal{}
+	for _, total := range totals {
+		list = append(list, *total)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Seconds != list[j].Seconds {
+			return list[i].Seconds > list[j].Seconds
+		}
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list
+}
+
+// NewMonthOverview sums up the activities of user in the month of t.
+// Activities spanning midnight count for the days they were tracked on.
+func NewMonthOverview(user string, t time.Time) (MonthOverview, error) {
+	ref := now.With(t)
+	monthBegin, monthEnd := ref.BeginningOfMonth(), ref.EndOfMonth()
+	overview := MonthOverview{Month: monthBegin.Format("2006-01")}
+
+	clients, err := GetInvoiceClients()
+	if err != nil {
+		return overview, err
+	}
+	clientOf := make(map[string]string)
+	for _, client := range clients {
+		for _, project := range client.Projects {
+			clientOf[strings.ToLower(project)] = client.Name
+		}
+	}
+
+	entries, err := database.ListEntriesBetween(user, monthBegin, monthEnd)
+	if err != nil {
+		return overview, err
+	}
+
+	projectTotals := make(map[string]*MonthTotal)
+	clientTotals := make(map[string]*MonthTotal)
+	projects := make(map[string]Project)
+	days := make(map[string]bool)
+
+	add := func(totals map[string]*MonthTotal, name string, seconds int64) {
+		key := strings.ToLower(name)
+		if totals[key] == nil {
+			totals[key] = &MonthTotal{Name: name}
+		}
+		totals[key].Seconds += seconds
+	}
+
+	for _, entry := range entries {
+		seconds := int64(zeit.ClippedDuration(entry, monthBegin, monthEnd).Seconds())
+		if seconds <= 0 {
+			continue
+		}
+
+		key := strings.ToLower(entry.Project)
+		project, ok := projects[key]
+		if !ok && entry.Project != "" {
+			if project, err = database.GetProject(user, entry.Project); err != nil {
+				return overview, err
+			}
+			projects[key] = project
+		}
+
+		name := entry.Project
+		if name == "" {
+			name = "(no project)"
+		}
+		add(projectTotals, name, seconds)
+		client, ok := clientOf[key]
+		if !ok {
+			client = "(no client)"
+		}
+		add(clientTotals, client, seconds)
+
+		overview.TotalSeconds += seconds
+		if project.Billable {
+			overview.BillableSeconds += seconds
+		} else {
+			overview.NonBillableSeconds += seconds
+		}
+
+		for day := now.With(entry.Begin).BeginningOfDay(); day.Before(entry.End()) && day.Before(monthEnd); day = day.AddDate(0, 0, 1) {
+			if zeit.ClippedDuration(entry, day, day.AddDate(0, 0, 1)) > 0 && !day.Before(monthBegin) {
+				days[day.Format(DateFormat)] = true
+			}
+		}
+	}
+
+	overview.Projects = monthTotals(projectTotals)
+	overview.Clients = monthTotals(clientTotals)
+	overview.TrackedDays = len(days)
+	if overview.TrackedDays > 0 {
+		overview.AverageDailySeconds = overview.TotalSeconds / int64(overview.TrackedDays)
+	}
+
+	return overview, nil
+}
+
+// GetOutput renders the overview, next to the one of previous if given.
+func (overview *MonthOverview) GetOutput(previous *MonthOverview) string {
+	var output strings.Builder
+
+	hours := func(seconds int64) string {
+		return fmtDuration(time.Duration(seconds)*time.Second) + "h"
+	}
+	hoursDelta := func(delta int64) string {
+		return fmtDelta(time.Duration(delta)*time.Second) + "h"
+	}
+	count := func(n int64) string {
+		return fmt.Sprint(n)
+	}
+	countDelta := func(delta int64) string {
+		return fmt.Sprintf("%+d", delta)
+	}
+	row := func(label string, current int64, before int64, format func(int64) string, formatDelta func(int64) string) {
+		fmt.Fprintf(&output, "   %-24s %10s", label, format(current))
+		if previous != nil {
+			fmt.Fprintf(&output, " %10s %10s", format(before), formatDelta(current-before))
+		}
+		output.WriteString("\n")
+	}
+	lookup := func(totals []MonthTotal, name string) int64 {
+		for _, total := range totals {
+			if strings.EqualFold(total.Name, name) {
+				return total.Seconds
+			}
+		}
+		return 0
+	}
+	section := func(title string, totals []MonthTotal, before []MonthTotal) {
+		fmt.Fprintf(&output, "\n%s\n\n", title)
+		names := make([]string, 0, len(totals))
+		for _, total := range totals {
+			names = append(names, total.Name)
+		}
+		if previous != nil {
+			for _, total := range before {
+				if lookup(totals, total.Name) == 0 {
+					names = append(names, total.Name)
+				}
+			}
+		}
+		for _, name := range names {
+			row(name, lookup(totals, name), lookup(before, name), hours, hoursDelta)
+		}
+	}
+
+	fmt.Fprintf(&output, "\nMONTH %s", overview.Month)
+	if previous != nil {
+		fmt.Fprintf(&output, " compared to %s", previous.Month)
+	}
+	output.WriteString("\n")
+
+	var before MonthOverview
+	if previous != nil {
+		before = *previous
+	}
+	section("PROJECTS", overview.Projects, before.Projects)
+	section("CLIENTS", overview.Clients, before.Clients)
+
+	output.WriteString("\n")
+	row("Total", overview.TotalSeconds, before.TotalSeconds, hours, hoursDelta)
+	row("Billable", overview.BillableSeconds, before.BillableSeconds, hours, hoursDelta)
+	row("Non-billable", overview.NonBillableSeconds, before.NonBillableSeconds, hours, hoursDelta)
+	row("Tracked days", int64(overview.TrackedDays), int64(before.TrackedDays), count, countDelta)
+	row("Average per tracked day", overview.AverageDailySeconds, before.AverageDailySeconds, hours, hoursDelta)
+	output.WriteString("\n")
+
+	return output.String()
+}
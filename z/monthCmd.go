@@ -0,0 +1,59 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/now"
+	"github.com/spf13/cobra"
+)
+
+var monthCompare bool
+
+var monthCmd = &cobra.Command{
+	Use:         "month ([flags]) [YYYY-MM]",
+	Short:       "Monthly overview",
+	Long:        "Sum up the current or the given month by project and by client, with the billable and non-billable time, the number of tracked days and the average hours per tracked day. Clients are the ones of invoice.clients.",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		month := now.BeginningOfMonth()
+		if len(args) > 0 {
+			var err error
+			if month, err = ParseMonth(args[0]); err != nil {
+				return err
+			}
+		}
+
+		overview, err := NewMonthOverview(user, month)
+		if err != nil {
+			return err
+		}
+
+		var previous *MonthOverview
+		if monthCompare {
+			before, err := NewMonthOverview(user, month.AddDate(0, -1, 0))
+			if err != nil {
+				return err
+			}
+			previous = &before
+		}
+
+		if IsOutputJSON() {
+			if previous != nil {
+				return printJSON([]MonthOverview{overview, *previous})
+			}
+			return printJSON(overview)
+		}
+
+		fmt.Print(overview.GetOutput(previous))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(monthCmd)
+	monthCmd.Flags().BoolVar(&monthCompare, "compare", false, "Show the previous month side by side")
+	monthCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,296 @@
+package z
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/spf13/viper"
+)
+
+// Tracking is published to an MQTT broker when `mqtt.url` is configured,
+// e.g. for home automation to change scenes while in a meeting:
+//
+//	mqtt:
+//	  url: mqtts://broker.local:8883
+//	  username: zeit
+//	  password: ${MQTT_PASSWORD}
+//	  topic: zeit
+//	  qos: 1
+//	  heartbeat: 1m
+//	  tls:
+//	    ca: ~/.config/zeit/ca.pem
+//
+// Starting and finishing an activity publish to <topic>/start and
+// <topic>/stop, and `zeit mqtt` publishes to <topic>/heartbeat every
+// mqtt.heartbeat. Every message is also retained on <topic>/state.
+
+const defaultMQTTTopic string = "zeit"
+
+const defaultMQTTHeartbeat time.Duration = time.Minute
+
+const mqttTimeout time.Duration = 10 * time.Second
+
+type MQTT struct {
+	URL       *url.URL
+	Username  string
+	Password  string
+	ClientID  string
+	Topic     string
+	QoS       byte
+	Heartbeat time.Duration
+	TLS       *tls.Config
+}
+
+// MQTTMessage is the JSON published for an event, with the running entry,
+// or the entry that was finished.
+type MQTTMessage struct {
+	Event          string     `json:"event"`
+	Timestamp      time.Time  `json:"timestamp"`
+	Tracking       bool       `json:"tracking"`
+	Entry          *HookEntry `json:"entry"`
+	ElapsedSeconds int64      `json:"elapsedSeconds,omitempty"`
+}
+
+// NewMQTT configures the broker from `mqtt.*`. Without an mqtt.url, nothing
+// is published and nil is returned.
+func NewMQTT() (*MQTT, error) {
+	rawURL := os.ExpandEnv(viper.GetString("mqtt.url"))
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	brokerURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt.url: %v", err)
+	}
+
+	mqtt := MQTT{
+		URL:      brokerURL,
+		Username: os.ExpandEnv(viper.GetString("mqtt.username")),
+		Password: os.ExpandEnv(viper.GetString("mqtt.password")),
+		ClientID: viper.GetString("mqtt.clientId"),
+		Topic:    strings.TrimSuffix(viper.GetString("mqtt.topic"), "/"),
+	}
+	if brokerURL.User != nil && mqtt.Username == "" {
+		mqtt.Username = brokerURL.User.Username()
+		mqtt.Password, _ = brokerURL.User.Password()
+	}
+	if mqtt.Topic == "" {
+		mqtt.Topic = defaultMQTTTopic
+	}
+	if mqtt.ClientID == "" {
+		// Unique, as brokers disconnect clients reusing the ID of another
+		hostname, _ := os.Hostname()
+		mqtt.ClientID = fmt.Sprintf("zeit-%s-%d", hostname, os.Getpid())
+	}
+
+	switch qos := viper.GetInt("mqtt.qos"); qos {
+	case 0, 1, 2:
+		mqtt.QoS = byte(qos)
+	default:
+		return nil, fmt.Errorf("invalid mqtt.qos '%d', possible values: 0, 1, 2", qos)
+	}
+
+	if mqtt.Heartbeat, err = getWatchDuration("mqtt.heartbeat", defaultMQTTHeartbeat); err != nil {
+		return nil, err
+	}
+
+	switch brokerURL.Scheme {
+	case "mqtt", "tcp":
+		if brokerURL.Port() == "" {
+			brokerURL.Host = net.JoinHostPort(brokerURL.Hostname(), "1883")
+		}
+	case "mqtts", "ssl", "tls":
+		if brokerURL.Port() == "" {
+			brokerURL.Host = net.JoinHostPort(brokerURL.Hostname(), "8883")
+		}
+		if mqtt.TLS, err = mqttTLSConfig(brokerURL.Hostname()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown scheme '%s' of mqtt.url, possible values: mqtt, mqtts", brokerURL.Scheme)
+	}
+
+	return &mqtt, nil
+}
+
+// mqttTLSConfig reads mqtt.tls.ca to verify the broker with, mqtt.tls.cert
+// and mqtt.tls.key to authenticate using a client certificate, and
+// mqtt.tls.insecure to not verify the broker at all.
+func mqttTLSConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: viper.GetBool("mqtt.tls.insecure"),
+	}
+
+	if ca := viper.GetString("mqtt.tls.ca"); ca != "" {
+		content, err := os.ReadFile(ExpandPath(ca))
+		if err != nil {
+			return nil, fmt.Errorf("could not read mqtt.tls.ca: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("mqtt.tls.ca %s contains no PEM certificates", ca)
+		}
+	}
+
+	cert, key := viper.GetString("mqtt.tls.cert"), viper.GetString("mqtt.tls.key")
+	if cert != "" || key != "" {
+		certificate, err := tls.LoadX509KeyPair(ExpandPath(cert), ExpandPath(key))
+		if err != nil {
+			return nil, fmt.Errorf("could not load mqtt.tls.cert and mqtt.tls.key: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// Publish connects to the broker, publishes the payload to the topics and
+// disconnects again. Topics are relative to mqtt.topic; retained ones end
+// with a "!".
+func (mqtt *MQTT) Publish(payload []byte, topics ...string) error {
+	// The credentials are passed on their own
+	broker := *mqtt.URL
+	broker.User = nil
+
+	options := paho.NewClientOptions().
+		AddBroker(broker.String()).
+		SetClientID(mqtt.ClientID).
+		SetUsername(mqtt.Username).
+		SetPassword(mqtt.Password).
+		SetCleanSession(true).
+		SetKeepAlive(time.Minute).
+		SetConnectTimeout(mqttTimeout).
+		SetWriteTimeout(mqttTimeout).
+		SetAutoReconnect(false)
+	if mqtt.TLS != nil {
+		options.SetTLSConfig(mqtt.TLS)
+	}
+
+	client := paho.NewClient(options)
+	if err := mqttWait(client.Connect(), "connecting to the broker"); err != nil {
+		return err
+	}
+	defer client.Disconnect(250)
+
+	for _, topic := range topics {
+		name, retained := strings.CutSuffix(topic, "!")
+		name = mqtt.Topic + "/" + name
+		if err := mqttWait(client.Publish(name, mqtt.QoS, retained, payload), "publishing to "+name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mqttWait waits up to mqttTimeout for the operation of the token to
+// complete.
+func mqttWait(token paho.Token, operation string) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("MQTT: timed out %s", operation)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("MQTT: %v", err)
+	}
+	return nil
+}
+
+// PublishMessage publishes the message to the topic of its event and
+// retains it as the state.
+func (mqtt *MQTT) PublishMessage(message MQTTMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return mqtt.Publish(payload, message.Event, "state!")
+}
+
+// NewMQTTHeartbeat returns the heartbeat message for the running entry, if
+// any.
+func NewMQTTHeartbeat(runningEntry *Entry, now time.Time) MQTTMessage {
+	message := MQTTMessage{Event: "heartbeat", Timestamp: now}
+	if runningEntry != nil {
+		entry := NewHookEntry(*runningEntry)
+		message.Tracking = true
+		message.Entry = &entry
+		message.ElapsedSeconds = int64(now.Sub(runningEntry.Begin).Seconds())
+	}
+	return message
+}
+
+// PublishMQTT publishes to <topic>/start when an activity began tracking
+// and to <topic>/stop when it was finished, if MQTT is configured.
+func PublishMQTT(event string, payload interface{}) error {
+	entry, ok := payload.(HookEntry)
+	if !ok || (event != HookPostTrack && event != HookPostFinish) {
+		return nil
+	}
+	// Activities tracked in retrospect are not going on
+	if event == HookPostTrack && entry.Finish != nil {
+		return nil
+	}
+
+	mqtt, err := NewMQTT()
+	if err != nil || mqtt == nil {
+		return err
+	}
+
+	message := MQTTMessage{Event: "start", Timestamp: time.Now(), Tracking: true, Entry: &entry}
+	if event == HookPostFinish {
+		message.Event = "stop"
+		message.Tracking = false
+	}
+	return mqtt.PublishMessage(message)
+}
+
+// PublishMQTTHeartbeats publishes a heartbeat with the running entry every
+// mqtt.heartbeat until it is interrupted. The database is reopened for every
+// heartbeat, so that it is not kept locked.
+func PublishMQTTHeartbeats(user string, mqtt *MQTT, once bool) error {
+	closeDatabase()
+
+	for {
+		closeStorage, err := OpenStorage(false, true)
+		if err != nil {
+			return err
+		}
+
+		var runningEntry *Entry
+		runningEntryId, err := database.GetRunningEntryId(user)
+		if err == nil && runningEntryId != "" {
+			var entry Entry
+			if entry, err = database.GetEntry(user, runningEntryId); err == nil {
+				runningEntry = &entry
+			}
+		}
+		closeStorage()
+		if err != nil {
+			return err
+		}
+
+		message := NewMQTTHeartbeat(runningEntry, time.Now())
+		if err = mqtt.PublishMessage(message); err != nil {
+			if once {
+				return err
+			}
+			// Brokers restarting must not stop the heartbeats
+			fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		}
+		if once {
+			fmt.Printf("%s published a heartbeat to %s\n", CharInfo, mqtt.Topic+"/heartbeat")
+			return nil
+		}
+
+		time.Sleep(mqtt.Heartbeat)
+	}
+}
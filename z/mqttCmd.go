@@ -0,0 +1,37 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var mqttOnce bool
+
+var mqttCmd = &cobra.Command{
+	Use:         "mqtt",
+	Short:       "Publish heartbeats to MQTT",
+	Long:        "Keep running and publish the running activity to <mqtt.topic>/heartbeat every mqtt.heartbeat (default 1m), retaining it on <mqtt.topic>/state, so that home automation notices when tracking stopped without zeit finishing it. Starting and finishing activities is published by every command once mqtt.url is configured. Using --once, a single heartbeat is published, e.g. from cron.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mqtt, err := NewMQTT()
+		if err != nil {
+			return err
+		}
+		if mqtt == nil {
+			return errors.New("no broker configured, please configure mqtt.url")
+		}
+
+		if !mqttOnce {
+			fmt.Printf("%s publishing heartbeats to %s every %s, press Ctrl+C to stop\n", CharInfo, mqtt.Topic+"/heartbeat", mqtt.Heartbeat)
+		}
+		return PublishMQTTHeartbeats(GetCurrentUser(), mqtt, mqttOnce)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mqttCmd)
+	mqttCmd.Flags().BoolVar(&mqttOnce, "once", false, "Publish a single heartbeat and exit")
+}
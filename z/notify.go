@@ -0,0 +1,218 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const notifyInterval time.Duration = time.Minute
+
+const defaultNotifyRepeat time.Duration = 30 * time.Minute
+
+// NotifySettings are the reminders `zeit notify` sends as desktop
+// notifications, none of which are sent unless configured:
+//
+//	notify:
+//	  idle: 15m      # nothing tracked for 15 minutes within working hours
+//	  running: 4h    # an activity running for more than 4 hours
+//	  pomodoro: 25m  # every 25 minutes of an activity
+//	  repeat: 30m    # how often idle and running reminders are repeated
+type NotifySettings struct {
+	Idle     time.Duration
+	Running  time.Duration
+	Pomodoro time.Duration
+	Repeat   time.Duration
+	// Command is run with the title and message instead of the notifier of
+	// the platform, configured as notify.command.
+	Command string
+}
+
+type Notification struct {
+	Title   string
+	Message string
+}
+
+// NotifyState is what was notified of before, so that reminders are only
+// repeated every NotifySettings.Repeat and every pomodoro is notified once.
+type NotifyState struct {
+	Idle      time.Time
+	Running   time.Time
+	EntryID   string
+	Pomodoros int64
+}
+
+// GetNotifySettings reads notify.*, where durations are given like 4h, 25m
+// or 1.5 (hours).
+func GetNotifySettings() (NotifySettings, error) {
+	settings := NotifySettings{
+		Repeat:  defaultNotifyRepeat,
+		Command: viper.GetString("notify.command"),
+	}
+
+	for key, duration := range map[string]*time.Duration{
+		"idle":     &settings.Idle,
+		"running":  &settings.Running,
+		"pomodoro": &settings.Pomodoro,
+		"repeat":   &settings.Repeat,
+	} {
+		if !viper.IsSet("notify." + key) {
+			continue
+		}
+		value, err := parseTarget(viper.GetString("notify." + key))
+		if err != nil || value < 0 {
+			return settings, fmt.Errorf("invalid notify.%s '%s', use e.g. 25m", key, viper.GetString("notify."+key))
+		}
+		*duration = value
+	}
+	return settings, nil
+}
+
+// HasReminders returns whether any reminder is configured.
+func (settings NotifySettings) HasReminders() bool {
+	return settings.Idle > 0 || settings.Running > 0 || settings.Pomodoro > 0
+}
+
+// isWorkingTime returns whether the time is within the working hours.
+func isWorkingTime(hours WorkingHours, t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return hours.Days[t.Weekday()] && !t.Before(day.Add(hours.Begin)) && t.Before(day.Add(hours.End))
+}
+
+// CheckNotifications returns the reminders due at now and updates the state
+// with them.
+func CheckNotifications(user string, settings NotifySettings, hours WorkingHours, state *NotifyState, now time.Time) ([]Notification, error) {
+	var notifications []Notification
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return notifications, err
+	}
+
+	if runningEntryId == "" {
+		state.Running, state.EntryID, state.Pomodoros = time.Time{}, "", 0
+		if settings.Idle == 0 || !isWorkingTime(hours, now) {
+			state.Idle = time.Time{}
+			return notifications, nil
+		}
+
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		idleSince := day.Add(hours.Begin)
+		latest, err := ListLatestEntries(user, idleSince, time.Time{}, func(Entry) bool { return true }, 0, 1)
+		if err != nil {
+			return notifications, err
+		}
+		if len(latest) > 0 && latest[0].Finish.After(idleSince) {
+			idleSince = latest[0].Finish
+		}
+
+		idle := now.Sub(idleSince)
+		if idle >= settings.Idle && (state.Idle.IsZero() || now.Sub(state.Idle) >= settings.Repeat) {
+			notifications = append(notifications, Notification{
+				Title:   "Not tracking",
+				Message: fmt.Sprintf("Nothing was tracked for %sh, run `zeit track` to track what you are working on.", fmtDuration(idle)),
+			})
+			state.Idle = now
+		}
+		return notifications, nil
+	}
+	state.Idle = time.Time{}
+
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return notifications, err
+	}
+	elapsed := now.Sub(runningEntry.Begin)
+
+	var pomodoros int64
+	if settings.Pomodoro > 0 {
+		pomodoros = int64(elapsed / settings.Pomodoro)
+	}
+	if runningEntry.ID != state.EntryID {
+		// Pomodoros that ended before the entry was seen are not notified
+		state.Running, state.EntryID, state.Pomodoros = time.Time{}, runningEntry.ID, pomodoros
+	}
+
+	name := runningEntry.Task + " on " + runningEntry.Project
+	if pomodoros > state.Pomodoros {
+		notifications = append(notifications, Notification{
+			Title:   "Pomodoro finished",
+			Message: fmt.Sprintf("%sh of %s, time for a break.", fmtDuration(time.Duration(pomodoros)*settings.Pomodoro), name),
+		})
+		state.Pomodoros = pomodoros
+	}
+
+	if settings.Running > 0 && elapsed >= settings.Running && (state.Running.IsZero() || now.Sub(state.Running) >= settings.Repeat) {
+		notifications = append(notifications, Notification{
+			Title:   "Still tracking",
+			Message: fmt.Sprintf("%s is running for %sh, run `zeit finish` if you forgot to finish it.", name, fmtDuration(elapsed)),
+		})
+		state.Running = now
+	}
+
+	return notifications, nil
+}
+
+// SendNotification shows the notification on the desktop, using notify-send
+// on Linux and BSD, osascript on macOS and a toast on Windows, or runs
+// notify.command with the title and message.
+func SendNotification(settings NotifySettings, notification Notification) error {
+	var cmd *exec.Cmd
+	if settings.Command != "" {
+		cmd = exec.Command(ExpandPath(settings.Command), notification.Title, notification.Message)
+	} else {
+		cmd = desktopNotificationCommand(notification)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%s was not found, please install it or configure notify.command", cmd.Path)
+		}
+		return fmt.Errorf("%s: %v %s", cmd.Path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Notify checks for reminders every minute and sends them, until it is
+// interrupted, recording idle periods along the way. The database is
+// reopened for every check, so that it is not kept locked.
+func Notify(user string, settings NotifySettings, hours WorkingHours, idleThreshold time.Duration) error {
+	closeDatabase()
+
+	var state NotifyState
+	sampler := IdleSampler{Interval: notifyInterval, Threshold: idleThreshold}
+	var idleErr error
+	for {
+		// The idle time may not be available, which is only told once
+		if err := sampler.Sample(time.Now()); err != nil && (idleErr == nil || err.Error() != idleErr.Error()) {
+			fmt.Fprintf(os.Stderr, "%s could not record idle periods: %+v\n", CharError, err)
+			idleErr = err
+		}
+
+		closeStorage, err := OpenStorage(false, true)
+		if err != nil {
+			return err
+		}
+
+		notifications, err := CheckNotifications(user, settings, hours, &state, time.Now())
+		closeStorage()
+		if err != nil {
+			return err
+		}
+
+		for _, notification := range notifications {
+			fmt.Printf("%s %s %s: %s\n", CharInfo, time.Now().Format("15:04"), notification.Title, notification.Message)
+			if err = SendNotification(settings, notification); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+			}
+		}
+
+		time.Sleep(notifyInterval)
+	}
+}
@@ -0,0 +1,55 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var notifyTest bool
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Send tracking reminders",
+	Long:  "Keep running and send desktop notifications when nothing is tracked within the working hours for notify.idle, when an activity is running for more than notify.running and whenever a pomodoro of notify.pomodoro ends. Meanwhile, idle periods are recorded for finishing activities without them. Using --test, a notification is sent right away to check that notifications work.",
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		AnnotationReadOnly:       "true",
+		AnnotationNoDatabaseWith: "test",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := GetNotifySettings()
+		if notifyTest {
+			err = SendNotification(settings, Notification{Title: "zeit", Message: "Notifications are working."})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s sent a notification\n", CharInfo)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		hours, err := GetWorkingHours()
+		if err != nil {
+			return err
+		}
+		idleThreshold, err := GetIdleThreshold()
+		if err != nil {
+			return err
+		}
+
+		if settings.HasReminders() {
+			fmt.Printf("%s sending reminders and recording idle periods, press Ctrl+C to stop\n", CharInfo)
+		} else {
+			fmt.Printf("%s no reminders configured in notify.*, only recording idle periods, press Ctrl+C to stop\n", CharInfo)
+		}
+		return Notify(GetCurrentUser(), settings, hours, idleThreshold)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+	notifyCmd.Flags().BoolVar(&notifyTest, "test", false, "Send a test notification and exit")
+}
@@ -0,0 +1,17 @@
+//go:build darwin
+
+package z
+
+import (
+	"os/exec"
+)
+
+// The title and message are passed as arguments, so that they need no
+// AppleScript quoting.
+func desktopNotificationCommand(notification Notification) *exec.Cmd {
+	return exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		notification.Title, notification.Message)
+}
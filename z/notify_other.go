@@ -0,0 +1,12 @@
+//go:build !(darwin || windows)
+
+package z
+
+import (
+	"os/exec"
+)
+
+// Linux and the BSDs show notifications through libnotify.
+func desktopNotificationCommand(notification Notification) *exec.Cmd {
+	return exec.Command("notify-send", "--app-name=zeit", notification.Title, notification.Message)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package z
+
+import (
+	"os"
+	"os/exec"
+)
+
+// windowsToastScript shows a toast as Windows PowerShell, reading the title
+// and message from the environment so that they need no quoting.
+const windowsToastScript string = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:ZEIT_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:ZEIT_NOTIFY_MESSAGE)) > $null
+$notifier = [Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe')
+$notifier.Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+func desktopNotificationCommand(notification Notification) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+	cmd.Env = append(os.Environ(), "ZEIT_NOTIFY_TITLE="+notification.Title, "ZEIT_NOTIFY_MESSAGE="+notification.Message)
+	return cmd
+}
@@ -0,0 +1,220 @@
+package z
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// Org mode clocks time in CLOCK lines in the LOGBOOK drawer of a headline:
+//
+//	* acme
+//	** Development
+//	   :LOGBOOK:
+//	   CLOCK: [2024-03-04 Mon 09:00]--[2024-03-04 Mon 10:30] =>  1:30
+//	   :END:
+//
+// Exports group activities under a headline per project with a sub-headline
+// per task, imports read the project from the top-level headline and the task
+// from the innermost headline below it.
+
+const orgTimeFormat string = "2006-01-02 Mon 15:04"
+
+// orgNoProject is the headline of activities without a project.
+const orgNoProject string = "(no project)"
+
+var (
+	orgHeadlineRegexp = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+	orgTagsRegexp     = regexp.MustCompile(`\s+(:[^\s:]+(?::[^\s:]+)*:)$`)
+	orgKeywordRegexp  = regexp.MustCompile(`^(?:TODO|NEXT|STARTED|WAITING|HOLD|DONE|CANCELED|CANCELLED)\s+`)
+	orgPriorityRegexp = regexp.MustCompile(`^\[#[A-Z0-9]\]\s*`)
+	orgCookieRegexp   = regexp.MustCompile(`\s*\[(?:\d+/\d+|\d+%)\]`)
+	orgClockRegexp    = regexp.MustCompile(`^\s*CLOCK:\s*\[([^\]]+)\](?:--\[([^\]]+)\])?`)
+	orgTimestampRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(?:\s+[^\s\d]+)?\s+(\d{1,2}:\d{2})$`)
+)
+
+func orgTimestamp(t time.Time) string {
+	return "[" + t.Format(orgTimeFormat) + "]"
+}
+
+func orgClock(entry Entry) string {
+	if entry.Finish.IsZero() {
+		return "CLOCK: " + orgTimestamp(entry.Begin)
+	}
+
+	minutes := int(entry.Finish.Sub(entry.Begin).Round(time.Minute).Minutes())
+	return fmt.Sprintf("CLOCK: %s--%s => %2d:%02d", orgTimestamp(entry.Begin), orgTimestamp(entry.Finish), minutes/60, minutes%60)
+}
+
+func orgHeadline(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ExportOrg writes the activities as org mode headlines with their clocks,
+// grouped by project and task and newest first, as org mode does.
+func ExportOrg(entries []Entry) string {
+	var output strings.Builder
+
+	grouped := make(map[string]map[string][]Entry)
+	for _, entry := range entries {
+		project := entry.Project
+		if project == "" {
+			project = orgNoProject
+		}
+		if grouped[project] == nil {
+			grouped[project] = make(map[string][]Entry)
+		}
+		grouped[project][entry.Task] = append(grouped[project][entry.Task], entry)
+	}
+
+	writeClocks := func(indent string, clocks []Entry) {
+		sort.SliceStable(clocks, func(i, j int) bool { return clocks[i].Begin.After(clocks[j].Begin) })
+		output.WriteString(indent + ":LOGBOOK:\n")
+		for _, entry := range clocks {
+			output.WriteString(indent + orgClock(entry) + "\n")
+		}
+		output.WriteString(indent + ":END:\n")
+	}
+
+	var projects []string
+	for project := range grouped {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		output.WriteString("* " + orgHeadline(project) + "\n")
+
+		tasks := grouped[project]
+		if clocks, ok := tasks[""]; ok {
+			writeClocks("  ", clocks)
+		}
+
+		var names []string
+		for task := range tasks {
+			if task != "" {
+				names = append(names, task)
+			}
+		}
+		sort.Strings(names)
+
+		for _, task := range names {
+			output.WriteString("** " + orgHeadline(task) + "\n")
+			writeClocks("   ", tasks[task])
+		}
+	}
+
+	return output.String()
+}
+
+type orgHeading struct {
+	Level int
+	Title string
+	Tags  []string
+}
+
+func parseOrgHeading(stars string, text string) orgHeading {
+	heading := orgHeading{Level: len(stars)}
+
+	if match := orgTagsRegexp.FindStringSubmatch(text); match != nil {
+		heading.Tags = strings.Split(strings.Trim(match[1], ":"), ":")
+		text = strings.TrimSuffix(text, match[0])
+	}
+	text = orgKeywordRegexp.ReplaceAllString(text, "")
+	text = orgPriorityRegexp.ReplaceAllString(text, "")
+	heading.Title = strings.TrimSpace(orgCookieRegexp.ReplaceAllString(text, ""))
+
+	return heading
+}
+
+func parseOrgTime(value string, location *time.Location) (time.Time, error) {
+	match := orgTimestampRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp '[%s]'", value)
+	}
+	return time.ParseInLocation("2006-01-02 15:04", match[1]+" "+match[2], location)
+}
+
+// ImportOrg reads the CLOCK lines of an org file. The top-level headline is
+// the project and the innermost headline below it the task, tags of all
+// headlines above a clock are its tags. Running clocks are skipped; lines
+// that can't be read don't stop the import but are returned as errors.
+func ImportOrg(user string, file string, location *time.Location) ([]Entry, []error, error) {
+	var entries []Entry
+	var lineErrors []error
+
+	f, err := os.Open(file)
+	if err != nil {
+		return entries, lineErrors, err
+	}
+	defer f.Close()
+
+	var path []orgHeading
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+
+		if match := orgHeadlineRegexp.FindStringSubmatch(line); match != nil {
+			heading := parseOrgHeading(match[1], match[2])
+			for len(path) > 0 && path[len(path)-1].Level >= heading.Level {
+				path = path[:len(path)-1]
+			}
+			path = append(path, heading)
+			continue
+		}
+
+		match := orgClockRegexp.FindStringSubmatch(line)
+		if match == nil || match[2] == "" {
+			continue
+		}
+		if len(path) == 0 {
+			lineErrors = append(lineErrors, fmt.Errorf("line %d: clock outside of a headline", number))
+			continue
+		}
+
+		begin, err := parseOrgTime(match[1], location)
+		if err != nil {
+			lineErrors = append(lineErrors, fmt.Errorf("line %d: %v", number, err))
+			continue
+		}
+		finish, err := parseOrgTime(match[2], location)
+		if err != nil {
+			lineErrors = append(lineErrors, fmt.Errorf("line %d: %v", number, err))
+			continue
+		}
+
+		entry := Entry{
+			Begin:   begin,
+			Finish:  finish,
+			Project: path[0].Title,
+			User:    user,
+		}
+		if entry.Project == orgNoProject {
+			entry.Project = ""
+		}
+		if len(path) > 1 {
+			entry.Task = path[len(path)-1].Title
+		}
+		var tags []string
+		for _, heading := range path {
+			tags = append(tags, heading.Tags...)
+		}
+		entry.Tags = zeit.ParseTags(tags)
+		entry.SHA1 = fmt.Sprintf("%x", sha1.Sum([]byte("org\x1f"+entry.Project+"\x1f"+entry.Task+"\x1f"+begin.UTC().Format(time.RFC3339))))
+
+		if !entry.IsFinishedAfterBegan() {
+			lineErrors = append(lineErrors, fmt.Errorf("line %d: %v", number, NewFinishBeforeBeginError(entry)))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, lineErrors, scanner.Err()
+}
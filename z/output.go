@@ -0,0 +1,123 @@
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+type ErrorOutput struct {
+	Error ErrorObject `json:"error"`
+}
+
+type ErrorObject struct {
+	Code        string       `json:"code"`
+	Message     string       `json:"message"`
+	Field       string       `json:"field,omitempty"`
+	Conflicts   []string     `json:"conflicts,omitempty"`
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+// EntriesOutput is printed with --output json by commands that track, finish,
+// change or erase activities.
+type EntriesOutput struct {
+	Tracked  []EntryResource `json:"tracked,omitempty"`
+	Finished []EntryResource `json:"finished,omitempty"`
+	Updated  []EntryResource `json:"updated,omitempty"`
+	Erased   []EntryResource `json:"erased,omitempty"`
+}
+
+func Outputs() []string {
+	return []string{
+		OutputText,
+		OutputJSON,
+	}
+}
+
+func IsOutputJSON() bool {
+	return viper.GetString(FlagOutput) == OutputJSON
+}
+
+func printJSON(v interface{}) error {
+	stringified, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", stringified)
+	return nil
+}
+
+func NewErrorObject(err error) ErrorObject {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return ErrorObject{
+			Code:        verr.Code,
+			Message:     verr.Message,
+			Field:       verr.Field,
+			Conflicts:   verr.Conflicts,
+			Suggestions: verr.Suggestions,
+		}
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		return ErrorObject{
+			Code:    "not-found",
+			Message: err.Error(),
+		}
+	}
+
+	var serr *StorageError
+	var lerr *LockedError
+	if errors.As(err, &serr) || errors.As(err, &lerr) {
+		return ErrorObject{
+			Code:    "storage",
+			Message: err.Error(),
+		}
+	}
+
+	return ErrorObject{
+		Code:    "error",
+		Message: err.Error(),
+	}
+}
+
+// printWarning reports a problem that doesn't stop the command. With
+// --output json it goes to stderr, so that stdout stays parseable.
+func printWarning(err error) {
+	if IsOutputJSON() {
+		fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		return
+	}
+	fmt.Printf("%s %+v\n", CharError, err)
+}
+
+// printError reports an error returned by a command, unless the command
+// reported it itself already.
+func printError(err error) {
+	var eerr *exitError
+	if errors.As(err, &eerr) {
+		return
+	}
+
+	if IsOutputJSON() {
+		if jerr := printJSON(ErrorOutput{Error: NewErrorObject(err)}); jerr == nil {
+			return
+		}
+	}
+
+	fmt.Printf("%s %+v\n", CharError, err)
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		for _, suggestion := range verr.Suggestions {
+			fmt.Printf("%s %s\n", CharMore, suggestion.Description)
+		}
+	}
+	var serr *StorageError
+	if errors.As(err, &serr) && serr.Hint != "" {
+		fmt.Printf("%s %s\n", CharMore, serr.Hint)
+	}
+}
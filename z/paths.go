@@ -0,0 +1,69 @@
+package z
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func getXDGDirectory(env string, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, fallback)
+}
+
+// GetConfigHome returns $XDG_CONFIG_HOME, defaulting to ~/.config.
+func GetConfigHome() string {
+	return getXDGDirectory("XDG_CONFIG_HOME", ".config")
+}
+
+// GetDataHome returns $XDG_DATA_HOME, defaulting to ~/.local/share.
+func GetDataHome() string {
+	return getXDGDirectory("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// GetCacheHome returns $XDG_CACHE_HOME, defaulting to ~/.cache.
+func GetCacheHome() string {
+	return getXDGDirectory("XDG_CACHE_HOME", ".cache")
+}
+
+// GetDefaultDatabasePath returns the location of the database used when
+// neither --db, ZEIT_DB nor `db` in the config are set.
+func GetDefaultDatabasePath() string {
+	dataHome := GetDataHome()
+	if dataHome == "" {
+		return ""
+	}
+
+	return filepath.Join(dataHome, "zeit", "zeit.db")
+}
+
+// GetLegacyDatabasePaths returns the locations earlier versions of the
+// documentation suggested to store the database at.
+func GetLegacyDatabasePaths() []string {
+	var paths []string
+
+	if configHome := GetConfigHome(); configHome != "" {
+		paths = append(paths,
+			filepath.Join(configHome, "zeit.db"),
+			filepath.Join(configHome, "zeit", "zeit.db"),
+		)
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".zeit.db"))
+	}
+
+	return paths
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
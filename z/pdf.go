@@ -0,0 +1,253 @@
+package z
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// pdfDocument lays out text and lines on A4 pages using the standard
+// Helvetica fonts, which PDF viewers provide, so that no fonts need to be
+// embedded. Text is limited to the Windows-1252 character set.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+}
+
+const (
+	pdfPageWidth  float64 = 595
+	pdfPageHeight float64 = 842
+)
+
+// pdfHelveticaWidths are the widths of the printable ASCII characters of
+// Helvetica in 1/1000 of the font size.
+var pdfHelveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var pdfWinAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+func pdfTextWidth(text string, size float64) float64 {
+	width := 0
+	for _, r := range text {
+		if r >= 32 && r <= 126 {
+			width += pdfHelveticaWidths[r-32]
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// pdfTruncate shortens text to fit into width, ending it with an ellipsis.
+func pdfTruncate(text string, size float64, width float64) string {
+	if pdfTextWidth(text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"…", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func pdfString(text string) string {
+	var encoded strings.Builder
+
+	encoded.WriteByte('(')
+	for _, r := range text {
+		var b byte
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			encoded.WriteByte('\\')
+			b = byte(r)
+		case r >= 32 && r <= 126, r >= 0xa0 && r <= 0xff:
+			b = byte(r)
+		default:
+			var ok bool
+			if b, ok = pdfWinAnsi[r]; !ok {
+				b = '?'
+			}
+		}
+		encoded.WriteByte(b)
+	}
+	encoded.WriteByte(')')
+
+	return encoded.String()
+}
+
+func (doc *pdfDocument) newPage() {
+	doc.pages = append(doc.pages, &bytes.Buffer{})
+}
+
+func (doc *pdfDocument) page() *bytes.Buffer {
+	if len(doc.pages) == 0 {
+		doc.newPage()
+	}
+	return doc.pages[len(doc.pages)-1]
+}
+
+func (doc *pdfDocument) text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(doc.page(), "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, pdfString(text))
+}
+
+func (doc *pdfDocument) textRight(x float64, y float64, size float64, bold bool, text string) {
+	doc.text(x-pdfTextWidth(text, size), y, size, bold, text)
+}
+
+func (doc *pdfDocument) line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(doc.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// Bytes writes the document as PDF 1.4.
+func (doc *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(content string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	if len(doc.pages) == 0 {
+		doc.newPage()
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	var kids []string
+	for idx := range doc.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+idx*2))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(doc.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for idx, page := range doc.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 6+idx*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// plainPDF lays out the invoice on A4 pages without a template.
+func (invoice *Invoice) plainPDF() []byte {
+	const (
+		left   float64 = 56
+		right  float64 = pdfPageWidth - 56
+		bottom float64 = 90
+		size   float64 = 10
+		lead   float64 = 14
+	)
+	columns := []float64{left, 200, 390, 465, right}
+
+	var doc pdfDocument
+	y := pdfPageHeight - 70
+
+	doc.text(left, y, 20, true, "Invoice "+invoice.Number)
+	y -= 2 * lead
+	for _, line := range []string{
+		"Date: " + invoice.Date.Format(DateFormat),
+		"Due: " + invoice.Due.Format(DateFormat),
+		"Period: " + invoice.Period(),
+	} {
+		doc.text(left, y, size, false, line)
+		y -= lead
+	}
+	y -= lead
+
+	from := strings.Split(invoice.From, "\n")
+	if invoice.TaxID != "" {
+		from = append(from, "Tax ID: "+invoice.TaxID)
+	}
+	to := append([]string{invoice.Client.Name}, strings.Split(strings.TrimSpace(invoice.Client.Address), "\n")...)
+	if invoice.Client.TaxID != "" {
+		to = append(to, "Tax ID: "+invoice.Client.TaxID)
+	}
+	for idx := 0; idx < len(from) || idx < len(to); idx++ {
+		if idx < len(from) {
+			doc.text(left, y, size, false, pdfTruncate(strings.TrimSpace(from[idx]), size, 250))
+		}
+		if idx < len(to) {
+			doc.text(320, y, size, idx == 0, pdfTruncate(strings.TrimSpace(to[idx]), size, right-320))
+		}
+		y -= lead
+	}
+	y -= lead
+
+	header := func() {
+		doc.text(columns[0], y, size, true, "Project")
+		doc.text(columns[1], y, size, true, "Task")
+		doc.textRight(columns[2], y, size, true, "Hours")
+		doc.textRight(columns[3], y, size, true, "Rate")
+		doc.textRight(columns[4], y, size, true, "Amount")
+		doc.line(left, y-4, right, y-4, 0.8)
+		y -= lead + 4
+	}
+	header()
+
+	for _, line := range invoice.Lines {
+		if y < bottom {
+			doc.newPage()
+			y = pdfPageHeight - 70
+			header()
+		}
+		doc.text(columns[0], y, size, false, pdfTruncate(line.Project, size, columns[1]-columns[0]-8))
+		doc.text(columns[1], y, size, false, pdfTruncate(line.Task, size, columns[2]-columns[1]-50))
+		doc.textRight(columns[2], y, size, false, formatInvoiceHours(line.Hours))
+		doc.textRight(columns[3], y, size, false, invoice.Money(line.Rate))
+		doc.textRight(columns[4], y, size, false, invoice.Money(line.Amount))
+		y -= lead
+	}
+
+	if y < bottom+4*lead {
+		doc.newPage()
+		y = pdfPageHeight - 70
+	}
+	doc.line(left, y+lead-4, right, y+lead-4, 0.4)
+	doc.text(left, y, size, false, "Subtotal")
+	doc.textRight(columns[2], y, size, false, formatInvoiceHours(invoice.Hours))
+	doc.textRight(columns[4], y, size, false, invoice.Money(invoice.Subtotal))
+	y -= lead
+	doc.text(left, y, size, false, fmt.Sprintf("%s %s%%", invoice.TaxLabel, invoice.TaxRate))
+	doc.textRight(columns[4], y, size, false, invoice.Money(invoice.Tax))
+	y -= lead
+	doc.line(left, y+lead-4, right, y+lead-4, 0.8)
+	doc.text(left, y, size, true, "Total")
+	doc.textRight(columns[4], y, size, true, invoice.Money(invoice.Total))
+	y -= 3 * lead
+
+	if invoice.Terms != "" {
+		for _, line := range strings.Split(invoice.Terms, "\n") {
+			if y < bottom {
+				doc.newPage()
+				y = pdfPageHeight - 70
+			}
+			doc.text(left, y, size, false, pdfTruncate(line, size, right-left))
+			y -= lead
+		}
+	}
+
+	return doc.Bytes()
+}
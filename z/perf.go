@@ -0,0 +1,443 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The timing layer records how long every command and each of its storage
+// operations took, if `perf.enabled` is set. One record per command run is
+// appended to the perf log, which `zeit perf report` summarizes.
+
+const (
+	defaultPerfKeep int           = 1000
+	defaultPerfSlow time.Duration = 100 * time.Millisecond
+)
+
+type PerfOperation struct {
+	Count int           `json:"count"`
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+type PerfSlowOperation struct {
+	Operation string        `json:"operation"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type PerfRecord struct {
+	Time       time.Time                `json:"time"`
+	Command    string                   `json:"command"`
+	Storage    string                   `json:"storage,omitempty"`
+	Duration   time.Duration            `json:"duration"`
+	Operations map[string]PerfOperation `json:"operations,omitempty"`
+	Slow       []PerfSlowOperation      `json:"slow,omitempty"`
+}
+
+var perf struct {
+	sync.Mutex
+	record *PerfRecord
+	begin  time.Time
+}
+
+func IsPerfEnabled() bool {
+	return viper.GetBool("perf.enabled")
+}
+
+func GetPerfLogPath() string {
+	if viper.GetString("perf.log") != "" {
+		return ExpandPath(viper.GetString("perf.log"))
+	}
+
+	dataHome := GetDataHome()
+	if dataHome == "" {
+		return ""
+	}
+	return filepath.Join(dataHome, "zeit", "perf.log")
+}
+
+// perfSlow returns the duration from which on storage operations are listed
+// individually, configured as `perf.slow`.
+func perfSlow() time.Duration {
+	if slow, err := time.ParseDuration(viper.GetString("perf.slow")); err == nil {
+		return slow
+	}
+	return defaultPerfSlow
+}
+
+func StartPerf(command string) {
+	perf.Lock()
+	defer perf.Unlock()
+
+	perf.begin = time.Now()
+	perf.record = &PerfRecord{
+		Time:       perf.begin,
+		Command:    command,
+		Operations: make(map[string]PerfOperation),
+	}
+}
+
+func recordPerfOperation(name string, duration time.Duration) {
+	perf.Lock()
+	defer perf.Unlock()
+
+	if perf.record == nil {
+		return
+	}
+
+	operation := perf.record.Operations[name]
+	operation.Count++
+	operation.Total += duration
+	if duration > operation.Max {
+		operation.Max = duration
+	}
+	perf.record.Operations[name] = operation
+
+	if duration >= perfSlow() {
+		perf.record.Slow = append(perf.record.Slow, PerfSlowOperation{Operation: name, Duration: duration})
+	}
+}
+
+// FinishPerf appends the record of the command to the perf log, keeping the
+// last `perf.keep` records.
+func FinishPerf() error {
+	perf.Lock()
+	record := perf.record
+	perf.record = nil
+	perf.Unlock()
+
+	if record == nil {
+		return nil
+	}
+	record.Duration = time.Since(perf.begin)
+
+	path := GetPerfLogPath()
+	if path == "" {
+		return errors.New("could not determine the location of the perf log")
+	}
+
+	records, err := ReadPerfLog()
+	if err != nil {
+		return err
+	}
+	records = append(records, *record)
+
+	keep := viper.GetInt("perf.keep")
+	if keep <= 0 {
+		keep = defaultPerfKeep
+	}
+	if len(records) > keep {
+		records = records[len(records)-keep:]
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err = encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func ReadPerfLog() ([]PerfRecord, error) {
+	var records []PerfRecord
+
+	file, err := os.Open(GetPerfLogPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return records, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record PerfRecord
+		// Skip records cut off by a crash instead of refusing the whole log
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+type PerfCommandSummary struct {
+	Command string        `json:"command"`
+	Count   int           `json:"count"`
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+}
+
+type PerfOperationSummary struct {
+	Operation string `json:"operation"`
+	PerfOperation
+	Average time.Duration `json:"average"`
+}
+
+type PerfSlowSummary struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	PerfSlowOperation
+}
+
+type PerfReport struct {
+	Records    int                    `json:"records"`
+	Since      time.Time              `json:"since"`
+	Commands   []PerfCommandSummary   `json:"commands"`
+	Operations []PerfOperationSummary `json:"operations"`
+	Slow       []PerfSlowSummary      `json:"slow"`
+}
+
+// NewPerfReport summarizes the records, slowest first.
+func NewPerfReport(records []PerfRecord, limit int) PerfReport {
+	report := PerfReport{Records: len(records)}
+	if len(records) > 0 {
+		report.Since = records[0].Time
+	}
+
+	commands := make(map[string]*PerfCommandSummary)
+	operations := make(map[string]*PerfOperationSummary)
+	for _, record := range records {
+		command, ok := commands[record.Command]
+		if !ok {
+			command = &PerfCommandSummary{Command: record.Command}
+			commands[record.Command] = command
+		}
+		command.Count++
+		command.Average += record.Duration
+		if record.Duration > command.Max {
+			command.Max = record.Duration
+		}
+
+		for name, op := range record.Operations {
+			operation, ok := operations[name]
+			if !ok {
+				operation = &PerfOperationSummary{Operation: name}
+				operations[name] = operation
+			}
+			operation.Count += op.Count
+			operation.Total += op.Total
+			if op.Max > operation.Max {
+				operation.Max = op.Max
+			}
+		}
+
+		for _, slow := range record.Slow {
+			report.Slow = append(report.Slow, PerfSlowSummary{Time: record.Time, Command: record.Command, PerfSlowOperation: slow})
+		}
+	}
+
+	for _, command := range commands {
+		command.Average /= time.Duration(command.Count)
+		report.Commands = append(report.Commands, *command)
+	}
+	for _, operation := range operations {
+		operation.Average = operation.Total / time.Duration(operation.Count)
+		report.Operations = append(report.Operations, *operation)
+	}
+
+	sort.Slice(report.Commands, func(i, j int) bool { return report.Commands[i].Max > report.Commands[j].Max })
+	sort.Slice(report.Operations, func(i, j int) bool { return report.Operations[i].Total > report.Operations[j].Total })
+	sort.Slice(report.Slow, func(i, j int) bool { return report.Slow[i].Duration > report.Slow[j].Duration })
+
+	if limit > 0 {
+		if len(report.Commands) > limit {
+			report.Commands = report.Commands[:limit]
+		}
+		if len(report.Operations) > limit {
+			report.Operations = report.Operations[:limit]
+		}
+		if len(report.Slow) > limit {
+			report.Slow = report.Slow[:limit]
+		}
+	}
+
+	return report
+}
+
+func unwrapStorage(storage Storage) Storage {
+	if timed, ok := storage.(*timedStorage); ok {
+		return timed.Storage
+	}
+	return storage
+}
+
+func storageName(storage Storage) string {
+	switch unwrapStorage(storage).(type) {
+	case *Database:
+		return "buntdb"
+	case *Postgres:
+		return "postgres"
+	case *Remote:
+		return "remote"
+	case *GitStorage:
+		return "git"
+	case *Federation:
+		return "federation"
+	}
+	return ""
+}
+
+// timedStorage records the duration of every operation of the storage.
+type timedStorage struct {
+	Storage
+}
+
+func NewTimedStorage(storage Storage) Storage {
+	if _, ok := storage.(*timedStorage); ok {
+		return storage
+	}
+
+	perf.Lock()
+	if perf.record != nil {
+		perf.record.Storage = storageName(storage)
+	}
+	perf.Unlock()
+
+	return &timedStorage{Storage: storage}
+}
+
+func timed(name string) func() {
+	begin := time.Now()
+	return func() { recordPerfOperation(name, time.Since(begin)) }
+}
+
+func (storage *timedStorage) Batch(fn func() error) error {
+	defer timed("Batch")()
+	if batcher, ok := storage.Storage.(Batcher); ok {
+		return batcher.Batch(fn)
+	}
+	return fn()
+}
+
+func (storage *timedStorage) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	defer timed("AddEntry")()
+	return storage.Storage.AddEntry(user, entry, setRunning)
+}
+
+func (storage *timedStorage) GetEntry(user string, entryId string) (Entry, error) {
+	defer timed("GetEntry")()
+	return storage.Storage.GetEntry(user, entryId)
+}
+
+func (storage *timedStorage) UpdateEntry(user string, entry Entry) (string, error) {
+	defer timed("UpdateEntry")()
+	return storage.Storage.UpdateEntry(user, entry)
+}
+
+func (storage *timedStorage) FinishEntry(user string, entry Entry) (string, error) {
+	defer timed("FinishEntry")()
+	return storage.Storage.FinishEntry(user, entry)
+}
+
+func (storage *timedStorage) EraseEntry(user string, id string) error {
+	defer timed("EraseEntry")()
+	return storage.Storage.EraseEntry(user, id)
+}
+
+func (storage *timedStorage) GetRunningEntryId(user string) (string, error) {
+	defer timed("GetRunningEntryId")()
+	return storage.Storage.GetRunningEntryId(user)
+}
+
+func (storage *timedStorage) SetRunningEntryId(user string, id string) error {
+	defer timed("SetRunningEntryId")()
+	return storage.Storage.SetRunningEntryId(user, id)
+}
+
+func (storage *timedStorage) ListEntries(user string) ([]Entry, error) {
+	defer timed("ListEntries")()
+	return storage.Storage.ListEntries(user)
+}
+
+func (storage *timedStorage) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	defer timed("ListEntriesBetween")()
+	return storage.Storage.ListEntriesBetween(user, from, to)
+}
+
+func (storage *timedStorage) ListRawEntries(user string) (map[string]string, error) {
+	defer timed("ListRawEntries")()
+	return storage.Storage.ListRawEntries(user)
+}
+
+func (storage *timedStorage) GetImportsSHA1List(user string) (map[string]string, error) {
+	defer timed("GetImportsSHA1List")()
+	return storage.Storage.GetImportsSHA1List(user)
+}
+
+func (storage *timedStorage) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	defer timed("UpdateImportsSHA1List")()
+	return storage.Storage.UpdateImportsSHA1List(user, sha1List)
+}
+
+func (storage *timedStorage) GetMeta(user string, key string) (string, error) {
+	defer timed("GetMeta")()
+	return storage.Storage.GetMeta(user, key)
+}
+
+func (storage *timedStorage) SetMeta(user string, key string, value string) error {
+	defer timed("SetMeta")()
+	return storage.Storage.SetMeta(user, key, value)
+}
+
+func (storage *timedStorage) UpdateProject(user string, projectName string, project Project) error {
+	defer timed("UpdateProject")()
+	return storage.Storage.UpdateProject(user, projectName, project)
+}
+
+func (storage *timedStorage) GetProject(user string, projectName string) (Project, error) {
+	defer timed("GetProject")()
+	return storage.Storage.GetProject(user, projectName)
+}
+
+func (storage *timedStorage) ListProjects(user string) ([]Project, error) {
+	defer timed("ListProjects")()
+	return storage.Storage.ListProjects(user)
+}
+
+func (storage *timedStorage) EraseProject(user string, projectName string) error {
+	defer timed("EraseProject")()
+	return storage.Storage.EraseProject(user, projectName)
+}
+
+func (storage *timedStorage) UpdateTask(user string, taskName string, task Task) error {
+	defer timed("UpdateTask")()
+	return storage.Storage.UpdateTask(user, taskName, task)
+}
+
+func (storage *timedStorage) GetTask(user string, taskName string) (Task, error) {
+	defer timed("GetTask")()
+	return storage.Storage.GetTask(user, taskName)
+}
+
+func (storage *timedStorage) ListTasks(user string) ([]Task, error) {
+	defer timed("ListTasks")()
+	return storage.Storage.ListTasks(user)
+}
+
+func (storage *timedStorage) EraseTask(user string, taskName string) error {
+	defer timed("EraseTask")()
+	return storage.Storage.EraseTask(user, taskName)
+}
@@ -0,0 +1,41 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var perfCmd = &cobra.Command{
+	Use:         "perf",
+	Short:       "Performance timing",
+	Long:        "Show whether the duration of commands and storage operations is recorded. Set `perf.enabled` in the configuration to record it and see `zeit perf report` for the slowest operations.",
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := ReadPerfLog()
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(map[string]interface{}{
+				"enabled": IsPerfEnabled(),
+				"log":     GetPerfLogPath(),
+				"records": len(records),
+			})
+		}
+
+		if IsPerfEnabled() {
+			fmt.Printf("%s timing is enabled, slow operations take %s or longer\n", CharInfo, perfSlow())
+		} else {
+			fmt.Printf("%s timing is disabled; set `perf.enabled` to record it\n", CharInfo)
+		}
+		fmt.Printf("%s %d records in %s\n", CharMore, len(records), color.FgLightWhite.Render(GetPerfLogPath()))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(perfCmd)
+}
@@ -0,0 +1,79 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var perfLimit int
+
+func fmtPerfDuration(duration time.Duration) string {
+	return duration.Round(time.Microsecond).String()
+}
+
+var perfReportCmd = &cobra.Command{
+	Use:         "report",
+	Short:       "Slowest operations",
+	Long:        "Summarize the recorded timings: the slowest commands, the storage operations taking the most time overall and the slowest individual storage operations.",
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := ReadPerfLog()
+		if err != nil {
+			return err
+		}
+
+		report := NewPerfReport(records, perfLimit)
+		if IsOutputJSON() {
+			return printJSON(report)
+		}
+
+		if report.Records == 0 {
+			fmt.Printf("%s no timings recorded; set `perf.enabled` to record them\n", CharInfo)
+			return nil
+		}
+
+		fmt.Printf("%s %d commands recorded since %s\n\n", CharInfo, report.Records, report.Since.Format(GetTimeDisplayFormat()))
+
+		fmt.Printf("%s %s\n", CharInfo, color.FgLightWhite.Render("Slowest commands"))
+		for _, command := range report.Commands {
+			fmt.Printf("%s %s: %dx, avg %s, max %s\n",
+				CharMore,
+				color.FgLightWhite.Render(command.Command),
+				command.Count,
+				fmtPerfDuration(command.Average),
+				color.FgCyan.Render(fmtPerfDuration(command.Max)))
+		}
+
+		fmt.Printf("\n%s %s\n", CharInfo, color.FgLightWhite.Render("Storage operations"))
+		for _, operation := range report.Operations {
+			fmt.Printf("%s %s: %dx, total %s, avg %s, max %s\n",
+				CharMore,
+				color.FgLightWhite.Render(operation.Operation),
+				operation.Count,
+				color.FgCyan.Render(fmtPerfDuration(operation.Total)),
+				fmtPerfDuration(operation.Average),
+				fmtPerfDuration(operation.Max))
+		}
+
+		if len(report.Slow) > 0 {
+			fmt.Printf("\n%s %s\n", CharInfo, color.FgLightWhite.Render("Slow operations"))
+			for _, slow := range report.Slow {
+				fmt.Printf("%s %s in %s: %s %s\n",
+					CharMore,
+					color.FgLightWhite.Render(slow.Operation),
+					slow.Command,
+					color.FgCyan.Render(fmtPerfDuration(slow.Duration)),
+					color.FgGray.Render(slow.Time.Format(GetTimeDisplayFormat())))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	perfCmd.AddCommand(perfReportCmd)
+	perfReportCmd.Flags().IntVar(&perfLimit, "limit", 10, "Number of entries to show per section, 0 for all")
+}
@@ -0,0 +1,228 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const pickerRows int = 8
+
+var errPickerCanceled = errors.New("canceled")
+
+// IsPickerEnabled reports whether track asks for a missing project and task
+// using the picker, which requires a terminal and can be turned off using
+// `picker.enabled: false`.
+func IsPickerEnabled() bool {
+	if viper.IsSet("picker.enabled") && !viper.GetBool("picker.enabled") {
+		return false
+	}
+	return IsInteractive() && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// fuzzyScore reports whether all characters of query appear in name in the
+// same order, ignoring case. The lower the score, the closer together and
+// the earlier in name they appear.
+func fuzzyScore(name string, query string) (int, bool) {
+	runes := []rune(strings.ToLower(name))
+	score, pos, last := 0, 0, -1
+
+	for _, char := range strings.ToLower(query) {
+		found := false
+		for ; pos < len(runes); pos++ {
+			if runes[pos] == char {
+				if last >= 0 {
+					score += pos - last - 1
+				} else {
+					score += pos
+				}
+				last = pos
+				pos++
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+// FuzzyFilter returns the options matching query, best matches first and
+// otherwise in their original order.
+func FuzzyFilter(options []string, query string) []string {
+	type scored struct {
+		option string
+		score  int
+	}
+
+	var matches []scored
+	for _, option := range options {
+		if score, ok := fuzzyScore(option, query); ok {
+			matches = append(matches, scored{option, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	var filtered []string
+	for _, match := range matches {
+		filtered = append(filtered, match.option)
+	}
+	return filtered
+}
+
+// GetRecentProjectsAndTasks returns the projects of the entries, most recently
+// used first, and per project its tasks in the same order.
+func GetRecentProjectsAndTasks(entries []Entry) ([]string, map[string][]string) {
+	var projects []string
+	tasks := make(map[string][]string)
+	seen := make(map[string]bool)
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Project != "" && !seen["p:"+entry.Project] {
+			seen["p:"+entry.Project] = true
+			projects = append(projects, entry.Project)
+		}
+		if entry.Task != "" && !seen["t:"+entry.Project+":"+entry.Task] {
+			seen["t:"+entry.Project+":"+entry.Task] = true
+			tasks[entry.Project] = append(tasks[entry.Project], entry.Task)
+		}
+	}
+
+	return projects, tasks
+}
+
+// PickProjectAndTask lets the user pick the project and then the task of an
+// activity, the ones used most recently first. Tasks used on the picked
+// project are offered before all others, followed by the tasks imported from
+// assigned issues. A configured project.default is used instead of picking
+// a project.
+func PickProjectAndTask(user string) (string, string, error) {
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return "", "", err
+	}
+	projects, projectTasks := GetRecentProjectsAndTasks(entries)
+
+	pickedProject := viper.GetString("project.default")
+	if pickedProject == "" {
+		if pickedProject, err = Pick("project", projects); err != nil {
+			return "", "", err
+		}
+	}
+
+	tasks := append([]string{}, projectTasks[pickedProject]...)
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		seen[task] = true
+	}
+	for _, task := range GetIssueTasks(user, pickedProject) {
+		if !seen[task] {
+			seen[task] = true
+			tasks = append(tasks, task)
+		}
+	}
+	for _, project := range projects {
+		for _, task := range projectTasks[project] {
+			if !seen[task] {
+				seen[task] = true
+				tasks = append(tasks, task)
+			}
+		}
+	}
+
+	pickedTask, err := Pick("task", tasks)
+	return pickedProject, pickedTask, err
+}
+
+// Pick shows the options below a prompt and filters them fuzzily while
+// typing. Up and down (or ctrl-p and ctrl-n) select an option and enter picks
+// it, or the typed text if no option matches; escape leaves the value empty.
+func Pick(label string, options []string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, state)
+
+	var query []rune
+	selected := 0
+	buf := make([]byte, 8)
+
+	for {
+		filtered := FuzzyFilter(options, string(query))
+		selected = min(selected, max(len(filtered)-1, 0))
+		renderPicker(label, string(query), filtered, selected)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		switch key := string(buf[:n]); key {
+		case "\r", "\n":
+			value := strings.TrimSpace(string(query))
+			if len(filtered) > 0 {
+				value = filtered[selected]
+			}
+			fmt.Printf("\r\033[J%s %s: %s\r\n", CharMore, label, color.FgLightWhite.Render(value))
+			return value, nil
+		case "\033":
+			fmt.Printf("\r\033[J%s %s: -\r\n", CharMore, label)
+			return "", nil
+		case "\x03", "\x04":
+			fmt.Print("\r\033[J")
+			return "", errPickerCanceled
+		case "\033[A", "\x10":
+			selected = max(selected-1, 0)
+		case "\033[B", "\x0e", "\t":
+			selected++
+		case "\x7f", "\x08":
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				selected = 0
+			}
+		default:
+			for _, char := range key {
+				if unicode.IsPrint(char) {
+					query = append(query, char)
+					selected = 0
+				}
+			}
+		}
+	}
+}
+
+func renderPicker(label string, query string, options []string, selected int) {
+	fmt.Print("\r\033[J")
+
+	first := max(selected-pickerRows+1, 0)
+	shown := options[first:min(first+pickerRows, len(options))]
+	for idx, option := range shown {
+		if first+idx == selected {
+			fmt.Printf("\r\n   %s", color.FgBlack.Render(color.BgLightWhite.Render(option)))
+		} else {
+			fmt.Printf("\r\n   %s", option)
+		}
+	}
+	if len(options) == 0 && query != "" {
+		fmt.Printf("\r\n   %s", color.FgGray.Render("new "+label+" '"+query+"'"))
+		shown = []string{""}
+	}
+	if len(shown) > 0 {
+		fmt.Printf("\033[%dA", len(shown))
+	}
+
+	fmt.Printf("\r%s %s: %s", CharMore, label, query)
+}
@@ -0,0 +1,546 @@
+package z
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type Postgres struct {
+	DB *sql.DB
+}
+
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS zeit_entries (
+		user_name TEXT NOT NULL,
+		id TEXT NOT NULL,
+		begin_at TIMESTAMPTZ NOT NULL,
+		finish_at TIMESTAMPTZ,
+		data JSONB NOT NULL,
+		PRIMARY KEY (user_name, id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS zeit_entries_begin ON zeit_entries (user_name, begin_at)`,
+	`CREATE TABLE IF NOT EXISTS zeit_status (
+		user_name TEXT PRIMARY KEY,
+		running TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS zeit_projects (
+		user_name TEXT NOT NULL,
+		id TEXT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (user_name, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS zeit_tasks (
+		user_name TEXT NOT NULL,
+		id TEXT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (user_name, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS zeit_imports (
+		user_name TEXT NOT NULL,
+		sha1 TEXT NOT NULL,
+		id TEXT NOT NULL,
+		PRIMARY KEY (user_name, sha1)
+	)`,
+	`CREATE TABLE IF NOT EXISTS zeit_meta (
+		user_name TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (user_name, key)
+	)`,
+}
+
+func InitPostgres(dsn string) (*Postgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+
+	for _, statement := range postgresSchema {
+		if _, err = db.Exec(statement); err != nil {
+			return nil, err
+		}
+	}
+
+	postgres := Postgres{db}
+	return &postgres, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (postgres *Postgres) setRunning(tx *sql.Tx, user string, id string) error {
+	_, err := tx.Exec(`INSERT INTO zeit_status (user_name, running) VALUES ($1, $2)
+		ON CONFLICT (user_name) DO UPDATE SET running = EXCLUDED.running`, user, id)
+	return err
+}
+
+// claimRunning sets the running entry unless another one is running, so that
+// of two machines starting at the same time only one succeeds.
+func (postgres *Postgres) claimRunning(tx *sql.Tx, user string, id string) error {
+	result, err := tx.Exec(`INSERT INTO zeit_status (user_name, running) VALUES ($1, $2)
+		ON CONFLICT (user_name) DO UPDATE SET running = EXCLUDED.running
+		WHERE zeit_status.running = ''`, user, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return &ValidationError{Code: ValidationOverlap, Message: "a task is already running"}
+	}
+
+	return nil
+}
+
+func (postgres *Postgres) Close() error {
+	return postgres.DB.Close()
+}
+
+func (postgres *Postgres) update(fn func(tx *sql.Tx) error) error {
+	tx, err := postgres.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (postgres *Postgres) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	id := NewID()
+
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return id, jsonerr
+	}
+
+	dberr := postgres.update(func(tx *sql.Tx) error {
+		if setRunning == true {
+			if err := postgres.claimRunning(tx, user, id); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`INSERT INTO zeit_entries (user_name, id, begin_at, finish_at, data)
+			VALUES ($1, $2, $3, $4, $5)`, user, id, entry.Begin, nullTime(entry.Finish), string(entryJson))
+		return err
+	})
+
+	return id, dberr
+}
+
+func (postgres *Postgres) GetEntry(user string, entryId string) (Entry, error) {
+	var entry Entry
+	var value string
+
+	err := postgres.DB.QueryRow(`SELECT data FROM zeit_entries WHERE user_name = $1 AND id = $2`,
+		user, entryId).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, ErrNotFound
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	json.Unmarshal([]byte(value), &entry)
+	entry.ID = entryId
+	return entry, nil
+}
+
+func (postgres *Postgres) UpdateEntry(user string, entry Entry) (string, error) {
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return entry.ID, jsonerr
+	}
+
+	_, dberr := postgres.DB.Exec(`INSERT INTO zeit_entries (user_name, id, begin_at, finish_at, data)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_name, id) DO UPDATE SET
+			begin_at = EXCLUDED.begin_at, finish_at = EXCLUDED.finish_at, data = EXCLUDED.data`,
+		user, entry.ID, entry.Begin, nullTime(entry.Finish), string(entryJson))
+
+	return entry.ID, dberr
+}
+
+func (postgres *Postgres) FinishEntry(user string, entry Entry) (string, error) {
+	entryJson, jsonerr := json.Marshal(entry)
+	if jsonerr != nil {
+		return entry.ID, jsonerr
+	}
+
+	dberr := postgres.update(func(tx *sql.Tx) error {
+		var runningEntryId string
+
+		err := tx.QueryRow(`SELECT running FROM zeit_status WHERE user_name = $1 FOR UPDATE`,
+			user).Scan(&runningEntryId)
+		if err != nil || runningEntryId == "" {
+			return errors.New("no currently running entry found!")
+		}
+
+		if runningEntryId != entry.ID {
+			return errors.New("specified entry is not currently running!")
+		}
+
+		if err = postgres.setRunning(tx, user, ""); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE zeit_entries SET begin_at = $3, finish_at = $4, data = $5
+			WHERE user_name = $1 AND id = $2`,
+			user, entry.ID, entry.Begin, nullTime(entry.Finish), string(entryJson))
+		return err
+	})
+
+	return entry.ID, dberr
+}
+
+func (postgres *Postgres) EraseEntry(user string, id string) error {
+	return postgres.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE zeit_status SET running = '' WHERE user_name = $1 AND running = $2`,
+			user, id); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`DELETE FROM zeit_entries WHERE user_name = $1 AND id = $2`, user, id)
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (postgres *Postgres) GetRunningEntryId(user string) (string, error) {
+	var runningId string = ""
+
+	err := postgres.DB.QueryRow(`SELECT running FROM zeit_status WHERE user_name = $1`,
+		user).Scan(&runningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return runningId, err
+}
+
+func (postgres *Postgres) SetRunningEntryId(user string, id string) error {
+	return postgres.update(func(tx *sql.Tx) error {
+		return postgres.setRunning(tx, user, id)
+	})
+}
+
+func (postgres *Postgres) ListEntries(user string) ([]Entry, error) {
+	var entries []Entry
+
+	rows, err := postgres.DB.Query(`SELECT id, data FROM zeit_entries WHERE user_name = $1
+		ORDER BY begin_at ASC`, user)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var value string
+		var entry Entry
+
+		if err = rows.Scan(&id, &value); err != nil {
+			return entries, err
+		}
+
+		json.Unmarshal([]byte(value), &entry)
+		entry.ID = id
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (postgres *Postgres) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	var entries []Entry
+
+	rows, err := postgres.DB.Query(`SELECT id, data FROM zeit_entries WHERE user_name = $1
+		AND ($2::timestamptz IS NULL OR finish_at IS NULL OR finish_at > $2)
+		AND ($3::timestamptz IS NULL OR begin_at < $3)
+		ORDER BY begin_at ASC`, user, nullTime(from), nullTime(to))
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var value string
+		var entry Entry
+
+		if err = rows.Scan(&id, &value); err != nil {
+			return entries, err
+		}
+
+		json.Unmarshal([]byte(value), &entry)
+		entry.ID = id
+		if entry.Overlaps(from, to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, rows.Err()
+}
+
+// ListLatestEntries reads the entries newest first and stops reading the rows
+// once the page is complete.
+func (postgres *Postgres) ListLatestEntries(user string, from time.Time, to time.Time, match func(Entry) bool, offset int, limit int) ([]Entry, error) {
+	var entries []Entry
+
+	rows, err := postgres.DB.Query(`SELECT id, data FROM zeit_entries WHERE user_name = $1
+		AND ($2::timestamptz IS NULL OR finish_at IS NULL OR finish_at > $2)
+		AND ($3::timestamptz IS NULL OR begin_at < $3)
+		ORDER BY begin_at DESC`, user, nullTime(from), nullTime(to))
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	var skipped int
+	for rows.Next() {
+		var id string
+		var value string
+		var entry Entry
+
+		if err = rows.Scan(&id, &value); err != nil {
+			return entries, err
+		}
+
+		json.Unmarshal([]byte(value), &entry)
+		entry.ID = id
+		if !entry.Overlaps(from, to) || !match(entry) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+
+	return entries, rows.Err()
+}
+
+func (postgres *Postgres) ListRawEntries(user string) (map[string]string, error) {
+	rawEntries := make(map[string]string)
+
+	rows, err := postgres.DB.Query(`SELECT id, data::text FROM zeit_entries WHERE user_name = $1`, user)
+	if err != nil {
+		return rawEntries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var value string
+
+		if err = rows.Scan(&id, &value); err != nil {
+			return rawEntries, err
+		}
+
+		rawEntries[id] = value
+	}
+
+	return rawEntries, rows.Err()
+}
+
+func (postgres *Postgres) GetImportsSHA1List(user string) (map[string]string, error) {
+	sha1List := make(map[string]string)
+
+	rows, err := postgres.DB.Query(`SELECT sha1, id FROM zeit_imports WHERE user_name = $1`, user)
+	if err != nil {
+		return sha1List, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sha1 string
+		var id string
+
+		if err = rows.Scan(&sha1, &id); err != nil {
+			return sha1List, err
+		}
+
+		sha1List[sha1] = id
+	}
+
+	return sha1List, rows.Err()
+}
+
+func (postgres *Postgres) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	// The list replaces the stored one, like the buntdb store does
+	return postgres.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM zeit_imports WHERE user_name = $1`, user); err != nil {
+			return err
+		}
+
+		for sha1, id := range sha1List {
+			_, err := tx.Exec(`INSERT INTO zeit_imports (user_name, sha1, id) VALUES ($1, $2, $3)`, user, sha1, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (postgres *Postgres) GetMeta(user string, key string) (string, error) {
+	var value string
+
+	err := postgres.DB.QueryRow(`SELECT value FROM zeit_meta WHERE user_name = $1 AND key = $2`,
+		user, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return value, err
+}
+
+func (postgres *Postgres) SetMeta(user string, key string, value string) error {
+	_, err := postgres.DB.Exec(`INSERT INTO zeit_meta (user_name, key, value) VALUES ($1, $2, $3)
+		ON CONFLICT (user_name, key) DO UPDATE SET value = EXCLUDED.value`, user, key, value)
+	return err
+}
+
+func (postgres *Postgres) setJSON(table string, user string, name string, v interface{}) error {
+	value, jsonerr := json.Marshal(v)
+	if jsonerr != nil {
+		return jsonerr
+	}
+
+	_, dberr := postgres.DB.Exec(`INSERT INTO `+table+` (user_name, id, data) VALUES ($1, $2, $3)
+		ON CONFLICT (user_name, id) DO UPDATE SET data = EXCLUDED.data`,
+		user, zeit.GetIdFromName(name), string(value))
+	return dberr
+}
+
+func (postgres *Postgres) getJSON(table string, user string, name string, v interface{}) error {
+	var value string
+
+	err := postgres.DB.QueryRow(`SELECT data FROM `+table+` WHERE user_name = $1 AND id = $2`,
+		user, zeit.GetIdFromName(name)).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewDecoder(strings.NewReader(value)).Decode(v)
+}
+
+func (postgres *Postgres) listJSON(table string, user string, fn func(value string) error) error {
+	// Sorted by name in byte order, like the buntdb store sorts projects and
+	// tasks
+	rows, err := postgres.DB.Query(`SELECT data FROM `+table+` WHERE user_name = $1 ORDER BY data->>'name' COLLATE "C"`, user)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return err
+		}
+		if err = fn(value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (postgres *Postgres) eraseJSON(table string, user string, name string) error {
+	result, err := postgres.DB.Exec(`DELETE FROM `+table+` WHERE user_name = $1 AND id = $2`,
+		user, zeit.GetIdFromName(name))
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (postgres *Postgres) UpdateProject(user string, projectName string, project Project) error {
+	return postgres.setJSON("zeit_projects", user, projectName, project)
+}
+
+func (postgres *Postgres) GetProject(user string, projectName string) (Project, error) {
+	var project Project
+	err := postgres.getJSON("zeit_projects", user, projectName, &project)
+	return project, err
+}
+
+func (postgres *Postgres) UpdateTask(user string, taskName string, task Task) error {
+	return postgres.setJSON("zeit_tasks", user, taskName, task)
+}
+
+func (postgres *Postgres) GetTask(user string, taskName string) (Task, error) {
+	var task Task
+	err := postgres.getJSON("zeit_tasks", user, taskName, &task)
+	return task, err
+}
+
+func (postgres *Postgres) ListProjects(user string) ([]Project, error) {
+	var projects []Project
+
+	err := postgres.listJSON("zeit_projects", user, func(value string) error {
+		var project Project
+		json.Unmarshal([]byte(value), &project)
+		projects = append(projects, project)
+		return nil
+	})
+
+	return projects, err
+}
+
+func (postgres *Postgres) EraseProject(user string, projectName string) error {
+	return postgres.eraseJSON("zeit_projects", user, projectName)
+}
+
+func (postgres *Postgres) ListTasks(user string) ([]Task, error) {
+	var tasks []Task
+
+	err := postgres.listJSON("zeit_tasks", user, func(value string) error {
+		var task Task
+		json.Unmarshal([]byte(value), &task)
+		tasks = append(tasks, task)
+		return nil
+	})
+
+	return tasks, err
+}
+
+func (postgres *Postgres) EraseTask(user string, taskName string) error {
+	return postgres.eraseJSON("zeit_tasks", user, taskName)
+}
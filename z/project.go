@@ -1,6 +1,60 @@
 package z
 
-type Project struct {
-	Name  string `json:"name,omitempty"`
-	Color string `json:"color,omitempty"`
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type ProjectRules = zeit.ProjectRules
+
+type ProjectBudget = zeit.ProjectBudget
+
+type Project = zeit.Project
+
+var projectTemplates = map[string]Project{
+	"consulting": {
+		Color:    "#5fafd7",
+		Billable: true,
+		Tasks:    []string{"Meeting", "Development", "Research", "Documentation", "Travel"},
+		Rules: ProjectRules{
+			NotesRequired: true,
+			TaskRequired:  true,
+		},
+	},
+	"employment": {
+		Color:    "#87af87",
+		Billable: false,
+		Tasks:    []string{"Meeting", "Development", "Review", "Support", "Admin"},
+		Rules: ProjectRules{
+			TaskRequired: true,
+		},
+	},
+	"oss": {
+		Color:    "#d7af5f",
+		Billable: false,
+		Tasks:    []string{"Issues", "Code Review", "Development", "Documentation", "Community"},
+	},
+}
+
+func ProjectTemplates() []string {
+	var names []string
+	for name := range projectTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func NewProjectFromTemplate(name string, template string) (Project, error) {
+	project, ok := projectTemplates[strings.ToLower(template)]
+	if !ok {
+		return Project{}, errors.New("unknown template, possible values: " + strings.Join(ProjectTemplates(), ", "))
+	}
+
+	project.Name = name
+	project.Tasks = append([]string{}, project.Tasks...)
+	return project, nil
 }
@@ -2,27 +2,35 @@ package z
 
 import (
 	"fmt"
-	"os"
+
 	// "time"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 	// "github.com/gookit/color"
 )
 
-var projectColor string
+var (
+	projectColor          string
+	projectBillable       bool
+	projectRate           string
+	projectCurrency       string
+	projectNotesRequired  bool
+	projectTaskRequired   bool
+	projectKnownTasksOnly bool
+)
 
 var projectCmd = &cobra.Command{
 	Use:   "project ([flags]) [project]",
 	Short: "Project settings",
 	Long:  "Configure project settings.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 		projectName := args[0]
 
 		project, err := database.GetProject(user, projectName)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
 		project.Name = projectName
@@ -31,18 +39,54 @@ var projectCmd = &cobra.Command{
 			project.Color = projectColor
 		}
 
+		if cmd.Flags().Changed("billable") {
+			project.Billable = projectBillable
+		}
+
+		if projectRate != "" {
+			project.Rate, err = decimal.NewFromString(projectRate)
+			if err != nil {
+				return fmt.Errorf("invalid rate: %w", err)
+			}
+		}
+
+		if projectCurrency != "" {
+			project.Currency = projectCurrency
+		}
+
+		if cmd.Flags().Changed("notes-required") {
+			project.Rules.NotesRequired = projectNotesRequired
+		}
+
+		if cmd.Flags().Changed("task-required") {
+			project.Rules.TaskRequired = projectTaskRequired
+		}
+
+		if cmd.Flags().Changed("known-tasks-only") {
+			project.Rules.KnownTasksOnly = projectKnownTasksOnly
+		}
+
 		err = database.UpdateProject(user, projectName, project)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(project)
 		}
 
 		fmt.Printf("%s project updated\n", CharInfo)
-		return
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(projectCmd)
 	projectCmd.Flags().StringVarP(&projectColor, "color", "c", "", "Set the color of the project (hex code, e.g. #121212)")
+	projectCmd.Flags().BoolVar(&projectBillable, "billable", false, "Set whether time tracked on the project is billable")
+	projectCmd.Flags().StringVar(&projectRate, "rate", "", "Set the hourly rate of the project")
+	projectCmd.Flags().StringVar(&projectCurrency, "currency", "", "Set the currency of the project's rate (e.g. EUR)")
+	projectCmd.Flags().BoolVar(&projectNotesRequired, "notes-required", false, "Require notes for finished activities on the project")
+	projectCmd.Flags().BoolVar(&projectTaskRequired, "task-required", false, "Require a task for activities on the project")
+	projectCmd.Flags().BoolVar(&projectKnownTasksOnly, "known-tasks-only", false, "Only allow the project's configured tasks")
 }
@@ -0,0 +1,91 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+)
+
+var projectTemplate string
+
+var projectInitCmd = &cobra.Command{
+	Use:   "init ([flags]) [project]",
+	Short: "Create project from template",
+	Long:  "Create a project with recommended tasks, billing settings and validation rules for a common type of engagement.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+		projectName := args[0]
+
+		existingProject, err := database.GetProject(user, projectName)
+		if err != nil {
+			return err
+		}
+
+		if existingProject.Name != "" {
+			return fmt.Errorf("project %s already exists", color.FgLightWhite.Render(existingProject.Name))
+		}
+
+		project, err := NewProjectFromTemplate(projectName, projectTemplate)
+		if err != nil {
+			return err
+		}
+
+		if projectColor != "" {
+			project.Color = projectColor
+		}
+
+		if projectRate != "" {
+			project.Rate, err = decimal.NewFromString(projectRate)
+			if err != nil {
+				return fmt.Errorf("invalid rate: %w", err)
+			}
+		}
+
+		if projectCurrency != "" {
+			project.Currency = projectCurrency
+		}
+
+		for _, taskName := range project.Tasks {
+			task, err := database.GetTask(user, taskName)
+			if err != nil {
+				return err
+			}
+
+			if task.Name != "" {
+				continue
+			}
+
+			task.Name = taskName
+			err = database.UpdateTask(user, taskName, task)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = database.UpdateProject(user, projectName, project)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s project %s created from template %s with tasks: %s\n",
+			CharInfo,
+			color.FgLightWhite.Render(projectName),
+			color.FgLightWhite.Render(projectTemplate),
+			strings.Join(project.Tasks, ", "),
+		)
+		return nil
+	},
+}
+
+func init() {
+	projectCmd.AddCommand(projectInitCmd)
+	projectInitCmd.Flags().StringVar(&projectTemplate, "template", "", "Template to create the project from, possible values: "+strings.Join(ProjectTemplates(), ", "))
+	projectInitCmd.Flags().StringVarP(&projectColor, "color", "c", "", "Set the color of the project (hex code, e.g. #121212)")
+	projectInitCmd.Flags().StringVar(&projectRate, "rate", "", "Set the hourly rate of the project")
+	projectInitCmd.Flags().StringVar(&projectCurrency, "currency", "", "Set the currency of the project's rate (e.g. EUR)")
+	projectInitCmd.MarkFlagRequired("template")
+}
@@ -0,0 +1,215 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// promptTTL is how long the prompt state of a database that is not a local
+// file, e.g. Postgres or a remote, is used before it is read again.
+const promptTTL time.Duration = 30 * time.Second
+
+// PromptState is the running entry as cached for shell prompts, together
+// with what it was read from, so that rendering a prompt usually only needs
+// to stat the database file.
+type PromptState struct {
+	Database  string       `json:"database"`
+	User      string       `json:"user"`
+	ModTime   time.Time    `json:"modTime"`
+	Timestamp time.Time    `json:"timestamp"`
+	Entry     *StatusEntry `json:"entry"`
+}
+
+// GetPromptStatePath returns the file the prompt state is cached in. Next to
+// it, the file without extension holds the begin of the running entry as
+// Unix time and the segment without the elapsed time, separated by a tab,
+// for shells to render the prompt from without running zeit.
+func GetPromptStatePath() string {
+	cacheHome := GetCacheHome()
+	if cacheHome == "" {
+		return ""
+	}
+
+	return filepath.Join(cacheHome, "zeit", "prompt.json")
+}
+
+// isFresh reports whether the cached state still reflects the database, i.e.
+// the database file was not modified since, or for other storages, whether
+// it is younger than promptTTL.
+func (state *PromptState) isFresh(dbfile string, user string) bool {
+	if state.Database != dbfile || state.User != user {
+		return false
+	}
+
+	if !isLocalDatabase(dbfile) {
+		return time.Since(state.Timestamp) < promptTTL
+	}
+
+	info, err := os.Stat(dbfile)
+	if err != nil {
+		return false
+	}
+	return info.ModTime().Equal(state.ModTime)
+}
+
+// Segment returns the prompt segment without the elapsed time, e.g.
+// `▶ acme/dev`, or nothing when not tracking.
+func (state *PromptState) Segment() string {
+	if state.Entry == nil {
+		return ""
+	}
+
+	icon, _ := getStatusbarIcons()
+	if viper.IsSet("prompt.icon") {
+		icon = viper.GetString("prompt.icon")
+	}
+
+	name := state.Entry.Project
+	if state.Entry.Task != "" {
+		name = strings.TrimPrefix(name+"/"+state.Entry.Task, "/")
+	}
+
+	return strings.TrimSpace(icon + " " + name)
+}
+
+func readPromptState(path string) (PromptState, error) {
+	var state PromptState
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// writeFileAtomically writes to a temporary file first, so that concurrent
+// prompts never read a partial file.
+func writeFileAtomically(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writePromptState(path string, state PromptState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(path, data); err != nil {
+		return err
+	}
+
+	var line string
+	if state.Entry != nil {
+		line = fmt.Sprintf("%d\t%s\n", state.Entry.Begin.Unix(), state.Segment())
+	}
+	return writeFileAtomically(strings.TrimSuffix(path, ".json"), []byte(line))
+}
+
+// loadPromptState reads the running entry of the user from the open
+// database into a new prompt state.
+func loadPromptState(user string, dbfile string) (PromptState, error) {
+	state := PromptState{Database: dbfile, User: user, Timestamp: time.Now()}
+
+	if isLocalDatabase(dbfile) {
+		info, err := os.Stat(dbfile)
+		if err != nil {
+			return state, err
+		}
+		state.ModTime = info.ModTime()
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil || runningEntryId == "" {
+		return state, err
+	}
+
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return state, err
+	}
+	state.Entry = &StatusEntry{
+		ID:      runningEntry.ID,
+		Begin:   runningEntry.Begin,
+		Project: runningEntry.Project,
+		Task:    runningEntry.Task,
+		Tags:    runningEntry.Tags,
+	}
+	return state, nil
+}
+
+// GetPromptState returns the running entry of the user from the cache, or
+// reads it from the database, without waiting for a lock, and caches it when
+// the cache is outdated.
+func GetPromptState(user string) (PromptState, error) {
+	dbfile := viper.GetString("db")
+	path := GetPromptStatePath()
+
+	cached, err := readPromptState(path)
+	if err == nil && cached.isFresh(dbfile, user) {
+		return cached, nil
+	}
+
+	// Without a database nothing is running
+	if isLocalDatabase(dbfile) && !fileExists(dbfile) {
+		return PromptState{Database: dbfile, User: user}, nil
+	}
+
+	closeStorage, err := OpenStorage(false, false)
+	if err != nil {
+		return cached, err
+	}
+	defer closeStorage()
+
+	state, err := loadPromptState(user, dbfile)
+	if err != nil {
+		return state, err
+	}
+
+	if path != "" {
+		writePromptState(path, state)
+	}
+	return state, nil
+}
+
+// updatePromptState refreshes the cached prompt state from the open database
+// after writing to it, if prompts are in use, so that shells reading the
+// state directly show the change right away.
+func updatePromptState(dbfile string) {
+	path := GetPromptStatePath()
+	if path == "" || !fileExists(path) {
+		return
+	}
+
+	state, err := loadPromptState(GetCurrentUser(), dbfile)
+	if err != nil {
+		return
+	}
+	writePromptState(path, state)
+}
+
+// GetPromptOutput renders the running entry as a short, colorless segment
+// for shell prompts like starship or powerlevel10k, e.g. `▶ acme/dev 1:05`.
+// When not tracking, or if anything goes wrong, it is empty.
+func GetPromptOutput(user string) string {
+	state, err := GetPromptState(user)
+	if err != nil || state.Entry == nil {
+		return ""
+	}
+
+	elapsed := time.Since(state.Entry.Begin)
+	return fmt.Sprintf("%s %d:%02d", state.Segment(), int(elapsed.Hours()), int(elapsed.Minutes())%60)
+}
@@ -0,0 +1,15 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Push tracked time",
+	Long:  "Push the time tracked on activities to other services, like the GitHub/GitLab issues they refer to.",
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+}
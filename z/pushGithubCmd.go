@@ -0,0 +1,92 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var pushGithubDryRun bool
+
+var pushGithubCmd = &cobra.Command{
+	Use:     "github ([flags])",
+	Aliases: []string{"gitlab"},
+	Short:   "Push time spent on GitHub/GitLab issues",
+	Long:    "Report the time tracked on the GitHub/GitLab issues of the repositories configured in issues.repositories since it was last pushed, as a comment or, on GitLab, as the issue's spent time. Issues are referred to by the references of activities, or within their task or notes, e.g. #123, GH-123 or an issue URL. Only finished activities are pushed.",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		repositories, err := GetIssueRepositories()
+		if err != nil {
+			return err
+		}
+		if len(repositories) == 0 {
+			fmt.Printf("%s no repositories configured in issues.repositories\n", CharInfo)
+			return nil
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		if entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime); err != nil {
+			return err
+		}
+
+		pushed, err := GetPushedIssues(user)
+		if err != nil {
+			return err
+		}
+
+		pushes := GetIssuePushes(entries, repositories, pushed)
+		if len(pushes) == 0 {
+			fmt.Printf("%s no time to push\n", CharInfo)
+			return nil
+		}
+
+		failed := false
+		for _, push := range pushes {
+			issue := push.Issue
+			if pushGithubDryRun {
+				fmt.Printf("%s would push %sh to %s\n", CharInfo, color.FgLightWhite.Render(push.Duration), color.FgLightWhite.Render(issue.String()))
+				continue
+			}
+
+			done, err := push.Push()
+			if err != nil {
+				fmt.Printf("%s %s: %+v\n", CharError, issue.String(), err)
+				failed = true
+				continue
+			}
+			if err = UpdatePushedIssues(user, pushed, push); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s %sh on %s\n", CharFinish, done, color.FgLightWhite.Render(push.Duration), color.FgLightWhite.Render(issue.String()))
+		}
+
+		if failed {
+			return fmt.Errorf("some issues could not be pushed to")
+		}
+		return nil
+	},
+}
+
+func init() {
+	pushCmd.AddCommand(pushGithubCmd)
+	pushGithubCmd.Flags().StringVar(&since, "since", "", "Date/time to push activities from")
+	pushGithubCmd.Flags().StringVar(&until, "until", "", "Date/time to push activities until")
+	pushGithubCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	pushGithubCmd.Flags().StringVarP(&project, "project", "p", "", "Project to push")
+	pushGithubCmd.Flags().StringVarP(&task, "task", "t", "", "Task to push")
+	pushGithubCmd.Flags().BoolVar(&pushGithubDryRun, "dry-run", false, "Only show what would be pushed")
+	pushGithubCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	pushGithubCmd.RegisterFlagCompletionFunc("task", completeTasks)
+}
@@ -0,0 +1,75 @@
+package z
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+var (
+	redactURL    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://\S+|\bwww\.\S+`)
+	redactSpaces = regexp.MustCompile(`(\S)  +`)
+)
+
+func RedactionRules() []string {
+	return []string{
+		RedactNotes,
+		RedactFirstLine,
+		RedactURLs,
+	}
+}
+
+// GetRedactionRules returns the rules configured for a destination as
+// `redaction.<destination>`, unless rules were given explicitly.
+func GetRedactionRules(destination string, rules []string) ([]string, error) {
+	if len(rules) == 0 {
+		rules = viper.GetStringSlice("redaction." + destination)
+	}
+
+	for idx, rule := range rules {
+		rules[idx] = strings.ToLower(strings.TrimSpace(rule))
+		if !zeit.ContainsFold(RedactionRules(), rules[idx]) {
+			return nil, fmt.Errorf("unknown redaction rule '%s', possible values: %s", rule, strings.Join(RedactionRules(), ", "))
+		}
+	}
+
+	return rules, nil
+}
+
+// RedactNotesText applies the redaction rules to the notes of an activity.
+func RedactNotesText(notes string, rules []string) string {
+	for _, rule := range rules {
+		switch rule {
+		case RedactNotes:
+			return ""
+		case RedactFirstLine:
+			notes, _, _ = strings.Cut(notes, "\n")
+		case RedactURLs:
+			lines := strings.Split(redactURL.ReplaceAllString(notes, ""), "\n")
+			for idx, line := range lines {
+				lines[idx] = strings.TrimRight(redactSpaces.ReplaceAllString(line, "$1 "), " ")
+			}
+			notes = strings.Join(lines, "\n")
+		}
+	}
+
+	return strings.TrimSpace(notes)
+}
+
+// RedactEntries returns copies of the entries with their notes redacted, so
+// that internal notes don't reach the destination.
+func RedactEntries(entries []Entry, rules []string) []Entry {
+	if len(rules) == 0 {
+		return entries
+	}
+
+	redacted := make([]Entry, len(entries))
+	for idx, entry := range entries {
+		entry.Notes = RedactNotesText(entry.Notes, rules)
+		redacted[idx] = entry
+	}
+	return redacted
+}
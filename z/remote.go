@@ -0,0 +1,236 @@
+package z
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Remote is a storage operating on the database of a zeit server via its
+// /api/storage endpoints, configured as `remote.url` and `remote.token`.
+type Remote struct {
+	URL   string
+	Token string
+
+	client http.Client
+}
+
+type remoteID struct {
+	ID string `json:"id"`
+}
+
+type remoteEntry struct {
+	EntryResource
+	SetRunning bool `json:"setRunning,omitempty"`
+}
+
+func InitRemote(remoteURL string, token string) (*Remote, error) {
+	if _, err := url.Parse(remoteURL); err != nil {
+		return nil, fmt.Errorf("invalid remote.url: %v", err)
+	}
+
+	remote := Remote{
+		URL:    strings.TrimSuffix(remoteURL, "/"),
+		Token:  token,
+		client: http.Client{Timeout: 30 * time.Second},
+	}
+	return &remote, nil
+}
+
+func (remote *Remote) request(method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, remote.URL+"/api/storage"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if remote.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+remote.Token)
+	}
+
+	res, err := remote.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var eo ErrorOutput
+		if json.NewDecoder(res.Body).Decode(&eo) == nil && eo.Error.Message != "" {
+			return fmt.Errorf("remote: %s", eo.Error.Message)
+		}
+		return fmt.Errorf("remote: %s", res.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func entryPath(id string) string {
+	return "/entries/" + url.PathEscape(id)
+}
+
+func (remote *Remote) AddEntry(user string, entry Entry, setRunning bool) (string, error) {
+	var created remoteID
+	err := remote.request(http.MethodPost, "/entries", remoteEntry{EntryResource{Entry: entry}, setRunning}, &created)
+	return created.ID, err
+}
+
+func (remote *Remote) GetEntry(user string, entryId string) (Entry, error) {
+	var resource EntryResource
+	if err := remote.request(http.MethodGet, entryPath(entryId), nil, &resource); err != nil {
+		return Entry{}, err
+	}
+
+	resource.Entry.ID = resource.ID
+	return resource.Entry, nil
+}
+
+func (remote *Remote) UpdateEntry(user string, entry Entry) (string, error) {
+	return entry.ID, remote.request(http.MethodPut, entryPath(entry.ID), EntryResource{ID: entry.ID, Entry: entry}, nil)
+}
+
+func (remote *Remote) FinishEntry(user string, entry Entry) (string, error) {
+	return entry.ID, remote.request(http.MethodPost, entryPath(entry.ID)+"/finish", EntryResource{ID: entry.ID, Entry: entry}, nil)
+}
+
+func (remote *Remote) EraseEntry(user string, id string) error {
+	return remote.request(http.MethodDelete, entryPath(id), nil, nil)
+}
+
+func (remote *Remote) GetRunningEntryId(user string) (string, error) {
+	var running remoteID
+	err := remote.request(http.MethodGet, "/running", nil, &running)
+	return running.ID, err
+}
+
+func (remote *Remote) SetRunningEntryId(user string, id string) error {
+	return remote.request(http.MethodPut, "/running", remoteID{ID: id}, nil)
+}
+
+func (remote *Remote) listEntries(query url.Values) ([]Entry, error) {
+	var resources []EntryResource
+	if err := remote.request(http.MethodGet, "/entries?"+query.Encode(), nil, &resources); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, resource := range resources {
+		resource.Entry.ID = resource.ID
+		entries = append(entries, resource.Entry)
+	}
+	return entries, nil
+}
+
+func (remote *Remote) ListEntries(user string) ([]Entry, error) {
+	return remote.listEntries(url.Values{})
+}
+
+func (remote *Remote) ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339Nano))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339Nano))
+	}
+	query.Set("between", "true")
+
+	return remote.listEntries(query)
+}
+
+func (remote *Remote) ListRawEntries(user string) (map[string]string, error) {
+	rawEntries := make(map[string]string)
+	err := remote.request(http.MethodGet, "/raw", nil, &rawEntries)
+	return rawEntries, err
+}
+
+func (remote *Remote) GetImportsSHA1List(user string) (map[string]string, error) {
+	sha1List := make(map[string]string)
+	err := remote.request(http.MethodGet, "/imports", nil, &sha1List)
+	return sha1List, err
+}
+
+func (remote *Remote) UpdateImportsSHA1List(user string, sha1List map[string]string) error {
+	return remote.request(http.MethodPut, "/imports", sha1List, nil)
+}
+
+func (remote *Remote) GetMeta(user string, key string) (string, error) {
+	var value string
+	err := remote.request(http.MethodGet, "/meta/"+url.PathEscape(key), nil, &value)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (remote *Remote) SetMeta(user string, key string, value string) error {
+	return remote.request(http.MethodPut, "/meta/"+url.PathEscape(key), value, nil)
+}
+
+// Projects and tasks that do not exist are returned empty rather than as an
+// error, as they are by the other storages.
+func (remote *Remote) UpdateProject(user string, projectName string, project Project) error {
+	return remote.request(http.MethodPut, "/projects/"+url.PathEscape(projectName), project, nil)
+}
+
+func (remote *Remote) GetProject(user string, projectName string) (Project, error) {
+	var project Project
+	err := remote.request(http.MethodGet, "/projects/"+url.PathEscape(projectName), nil, &project)
+	if errors.Is(err, ErrNotFound) {
+		return Project{}, nil
+	}
+	return project, err
+}
+
+func (remote *Remote) ListProjects(user string) ([]Project, error) {
+	var projects []Project
+	err := remote.request(http.MethodGet, "/projects", nil, &projects)
+	return projects, err
+}
+
+func (remote *Remote) EraseProject(user string, projectName string) error {
+	return remote.request(http.MethodDelete, "/projects/"+url.PathEscape(projectName), nil, nil)
+}
+
+func (remote *Remote) UpdateTask(user string, taskName string, task Task) error {
+	return remote.request(http.MethodPut, "/tasks/"+url.PathEscape(taskName), task, nil)
+}
+
+func (remote *Remote) GetTask(user string, taskName string) (Task, error) {
+	var task Task
+	err := remote.request(http.MethodGet, "/tasks/"+url.PathEscape(taskName), nil, &task)
+	if errors.Is(err, ErrNotFound) {
+		return Task{}, nil
+	}
+	return task, err
+}
+
+func (remote *Remote) ListTasks(user string) ([]Task, error) {
+	var tasks []Task
+	err := remote.request(http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+func (remote *Remote) EraseTask(user string, taskName string) error {
+	return remote.request(http.MethodDelete, "/tasks/"+url.PathEscape(taskName), nil, nil)
+}
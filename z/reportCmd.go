@@ -3,20 +3,26 @@ package z
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 type reportEntry struct {
-	Date     string
-	Project  string
-	Task     string
-	Duration float64
-	Notes    string
-	Running  bool
+	Date      string
+	Project   string
+	Task      string
+	Duration  float64
+	Notes     string
+	Running   bool
+	Attendees []string
+	Tags      []string
 }
 
 type reportLine struct {
@@ -26,25 +32,48 @@ type reportLine struct {
 }
 
 var (
-	weeklyFlag    bool
-	monthlyFlag   bool
-	notesFlag     bool
-	noTasksFlag   bool
-	byProjectFlag bool
+	weeklyFlag      bool
+	monthlyFlag     bool
+	notesFlag       bool
+	noTasksFlag     bool
+	byProjectFlag   bool
+	byAttendeeFlag  bool
+	byTagFlag       bool
+	meetingCostFlag bool
+	columnFlags     []string
+	reportFormat    string
+	reportGroupBy   string
+	reportEmail     bool
+	reportTo        []string
+	reportSchedule  string
 )
 var dailyReport map[string]map[string]map[string]reportLine
 var projectReport map[string]map[string]reportLine
+var groupReport map[string]map[string]reportLine
 
 var reportCmd = &cobra.Command{
-	Use:   "report",
-	Short: "report times an day / project / task level",
-	Long:  "Reporting summaries on daily, project, task level for a given range",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:         "report",
+	Short:       "report times an day / project / task level",
+	Long:        "Reporting summaries on daily, project, task level for a given range",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportSchedule != "" {
+			crontab, err := GetReportCrontab(reportSchedule, reportScheduleArgs(cmd))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s add this line to your crontab using `crontab -e`:\n%s\n", CharInfo, crontab)
+			return nil
+		}
+
 		if since == "" && until == "" && listRange == "" {
 			listRange = viper.GetString("report.default")
 		}
 
-		if byProjectFlag {
+		if byAttendeeFlag || byTagFlag {
+			groupReport = make(map[string]map[string]reportLine)
+		} else if byProjectFlag || strings.ToLower(reportGroupBy) == ReportGroupByProject {
+			byProjectFlag = true
 			projectReport = make(map[string]map[string]reportLine)
 		} else {
 			dailyReport = make(map[string]map[string]map[string]reportLine)
@@ -63,9 +92,47 @@ var reportCmd = &cobra.Command{
 			viper.Set("report.no-tasks", true)
 		}
 
-		filteredEntries := listEntries()
-		sinceTime, untilTime := ParseSinceUntil(since, until, listRange)
-		if listRange != "" {
+		var err error
+		if reportColumns, err = ParseReportColumns(columnFlags); err != nil {
+			return err
+		}
+		reportProjects = make(map[string]Project)
+
+		if !zeit.ContainsFold(ReportFormats(), reportFormat) {
+			return fmt.Errorf("unknown format '%s', possible values: %s", reportFormat, strings.Join(ReportFormats(), ", "))
+		}
+		if !zeit.ContainsFold(ReportGroupings(), reportGroupBy) {
+			return fmt.Errorf("unknown grouping '%s', possible values: %s", reportGroupBy, strings.Join(ReportGroupings(), ", "))
+		}
+
+		filteredEntries, err := listEntries()
+		if err != nil {
+			return err
+		}
+		groupBy := strings.ToLower(reportGroupBy)
+		if byProjectFlag {
+			groupBy = ReportGroupByProject
+		}
+		if reportEmail {
+			if err = emailReport(filteredEntries, groupBy); err != nil {
+				return err
+			}
+			return nil
+		}
+		switch strings.ToLower(reportFormat) {
+		case ReportFormatMarkdown:
+			fmt.Print(ReportMarkdown(filteredEntries, groupBy, viper.GetBool("report.notes")))
+			return nil
+		case ReportFormatHTML:
+			fmt.Print(ReportHTML(filteredEntries, groupBy, viper.GetBool("report.notes"), "Report"))
+			return nil
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		if listRange != "" && !IsOutputJSON() {
 			fmt.Println("Reporting for Timerange:", listRange, "/", sinceTime.Format(DateFormat), "-", untilTime.Format(DateFormat))
 		}
 		var reportEntries []reportEntry
@@ -79,19 +146,66 @@ var reportCmd = &cobra.Command{
 				entryDuration = time.Duration(fe.Finish.Sub(fe.Begin)).Seconds()
 			}
 			dateString := fe.Begin.Format(DateFormat)
-			reportEntries = append(reportEntries, reportEntry{dateString, fe.Project, fe.Task, entryDuration, fe.Notes, running})
+			reportEntries = append(reportEntries, reportEntry{dateString, fe.Project, fe.Task, entryDuration, fe.Notes, running, fe.Attendees, fe.Tags})
 		}
 
-		if byProjectFlag {
+		if meetingCostFlag {
+			if IsOutputJSON() {
+				summary, err := reportSummaryByMeetingCost(filteredEntries, sinceTime, untilTime)
+				if err != nil {
+					return err
+				}
+				return printJSON(summary)
+			}
+			return outputMeetingCost(filteredEntries)
+		} else if byAttendeeFlag {
+			for _, re := range reportEntries {
+				groupReporting(re.Attendees, zeit.ParseAttendees(attendees), re)
+			}
+			if IsOutputJSON() {
+				summary, err := reportSummaryByGroup("attendee", sinceTime, untilTime)
+				if err != nil {
+					return err
+				}
+				return printJSON(summary)
+			}
+			return outputByGroup("Attendee")
+		} else if byTagFlag {
+			for _, re := range reportEntries {
+				groupReporting(re.Tags, zeit.ParseTags(tags), re)
+			}
+			if IsOutputJSON() {
+				summary, err := reportSummaryByGroup("tag", sinceTime, untilTime)
+				if err != nil {
+					return err
+				}
+				return printJSON(summary)
+			}
+			return outputByGroup("Tag")
+		} else if byProjectFlag {
 			for _, re := range reportEntries {
 				projectReporting(re)
 			}
-			outputByProject()
+			if IsOutputJSON() {
+				summary, err := reportSummaryByProject(sinceTime, untilTime)
+				if err != nil {
+					return err
+				}
+				return printJSON(summary)
+			}
+			return outputByProject()
 		} else {
 			for _, re := range reportEntries {
 				dailyReporting(re)
 			}
-			output()
+			if IsOutputJSON() {
+				summary, err := reportSummaryByDay(sinceTime, untilTime)
+				if err != nil {
+					return err
+				}
+				return printJSON(summary)
+			}
+			return output()
 		}
 	},
 }
@@ -101,7 +215,7 @@ func init() {
 
 	reportCmd.Flags().StringVar(&since, "since", "", "Date/time to start the list from")
 	reportCmd.Flags().StringVar(&until, "until", "", "Date/time to list until")
-	reportCmd.Flags().StringVar(&listRange, "range", "", "shortcut to set since/until for a given range (today, yesterday, thisWeek, lastWeek, thisMonth, lastMonth)")
+	reportCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
 	reportCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be listed")
 	reportCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be listed")
 	reportCmd.PersistentFlags().BoolVar(&weeklyFlag, "weekly", false, "Print summary of weekly hours")
@@ -109,14 +223,99 @@ func init() {
 	reportCmd.PersistentFlags().BoolVar(&notesFlag, "notes", false, "Print notes for the task")
 	reportCmd.PersistentFlags().BoolVar(&noTasksFlag, "no-tasks", false, "Print only summary bot no task details")
 	reportCmd.PersistentFlags().BoolVar(&byProjectFlag, "by-project", false, "Group report by project instead of by day")
+	reportCmd.PersistentFlags().BoolVar(&byAttendeeFlag, "by-attendee", false, "Group report by attendee instead of by day")
+	reportCmd.PersistentFlags().BoolVar(&byTagFlag, "by-tag", false, "Group report by tag instead of by day")
+	reportCmd.PersistentFlags().BoolVar(&meetingCostFlag, "meeting-cost", false, "Estimate the cost of meetings per week and project")
+	reportCmd.Flags().StringVar(&reportFormat, "format", ReportFormatText, "Format of the report, possible values: "+strings.Join(ReportFormats(), ", "))
+	reportCmd.Flags().StringVar(&reportGroupBy, "group-by", ReportGroupByDay, "Group the report by, possible values: "+strings.Join(ReportGroupings(), ", "))
+	reportCmd.Flags().BoolVar(&reportEmail, "email", false, "Send the report as HTML email to email.to using the SMTP server email.smtp")
+	reportCmd.Flags().StringSliceVar(&reportTo, "to", []string{}, "Send the report to these addresses instead of email.to (comma separated)")
+	reportCmd.Flags().StringVar(&reportSchedule, "schedule", "", "Print the crontab line emailing the report regularly, possible values: "+strings.Join(ReportSchedules(), ", "))
+	reportCmd.Flags().StringArrayVar(&columnFlags, "column", []string{}, "Add a computed column as name=expression, e.g. \"gross=duration * rate * 1.19\" (repeatable)")
+	reportCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Only report activities with any of the given attendees (comma separated)")
+	reportCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Only report activities with any of the given tags (comma separated)")
+	reportCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Only report activities with any of the given references (comma separated)")
+
+	reportCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	reportCmd.RegisterFlagCompletionFunc("task", completeTasks)
+}
+
+// emailReport sends the entries as HTML email, with the Markdown report as
+// text alternative.
+func emailReport(entries []Entry, groupBy string) error {
+	mailer, err := NewMailer()
+	if err != nil {
+		return err
+	}
+
+	recipients := reportTo
+	if len(recipients) == 0 {
+		recipients = viper.GetStringSlice("email.to")
+	}
+	to, err := ParseEmailAddresses(recipients)
+	if err != nil {
+		return err
+	}
+
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if sinceTime.IsZero() || entry.Begin.Before(sinceTime) {
+			sinceTime = entry.Begin
+		}
+		if untilTime.IsZero() || entry.End().After(untilTime) {
+			untilTime = entry.End()
+		}
+	}
+	subject, err := GetEmailSubject(EmailSubject{
+		User:  GetCurrentUser(),
+		Since: sinceTime.Format(DateFormat),
+		Until: untilTime.Format(DateFormat),
+	})
+	if err != nil {
+		return err
+	}
+
+	notes := viper.GetBool("report.notes")
+	if err = mailer.Send(to, subject, ReportMarkdown(entries, groupBy, notes), ReportHTML(entries, groupBy, notes, subject)); err != nil {
+		return err
+	}
+
+	addresses := make([]string, 0, len(to))
+	for _, address := range to {
+		addresses = append(addresses, address.Address)
+	}
+	fmt.Printf("%s sent %s to %s\n", CharInfo, color.FgLightWhite.Render(subject), strings.Join(addresses, ", "))
+	return nil
+}
 
-	flagName := "task"
-	reportCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
+// reportScheduleArgs returns the flags the report was run with, for running
+// it the same way on a schedule. The range is chosen by the schedule.
+func reportScheduleArgs(cmd *cobra.Command) []string {
+	var args []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "schedule", "email", "since", "until", "range":
+			return
+		}
+		if cmd.InheritedFlags().Lookup(flag.Name) != nil {
+			return
+		}
+		if flag.Value.Type() == "stringArray" {
+			for _, value := range flag.Value.(pflag.SliceValue).GetSlice() {
+				args = append(args, "--"+flag.Name+"="+value)
+			}
+			return
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			args = append(args, "--"+flag.Name+"="+strings.Join(slice.GetSlice(), ","))
+			return
+		}
+		args = append(args, "--"+flag.Name+"="+flag.Value.String())
 	})
+	return args
 }
 
 func dailyReporting(re reportEntry) {
@@ -173,7 +372,28 @@ func projectReporting(re reportEntry) {
 	projectReport[re.Project][re.Task] = workEntry
 }
 
-func output() {
+func groupReporting(groups []string, filter []string, re reportEntry) {
+	for _, group := range groups {
+		if len(filter) > 0 && !zeit.ContainsFold(filter, group) {
+			continue
+		}
+
+		_, ok := groupReport[group]
+		if !ok {
+			groupReport[group] = make(map[string]reportLine)
+		}
+
+		workEntry := groupReport[group][re.Project]
+		if workEntry.Running || re.Running {
+			workEntry.Running = true
+		}
+		workEntry.Duration += re.Duration
+		workEntry.Notes = append(workEntry.Notes, re.Notes)
+		groupReport[group][re.Project] = workEntry
+	}
+}
+
+func output() error {
 	lastWeek := ""
 	weekSum := 0.0
 	lastMonth := ""
@@ -213,6 +433,9 @@ func output() {
 
 				if !viper.GetBool("report.no-tasks") {
 					color.FgLightWhite.Print("          ", fmtDuration(time.Duration(dailyReport[dateKey][projectKey][taskKey].Duration*float64(time.Second))), " ", taskKey)
+					if err := printReportColumns(projectKey, taskKey, dailyReport[dateKey][projectKey][taskKey]); err != nil {
+						return err
+					}
 					if dailyReport[dateKey][projectKey][taskKey].Running {
 						color.FgLightYellow.Println(" (running)")
 					} else {
@@ -231,7 +454,11 @@ func output() {
 				weekSum += dailyReport[dateKey][projectKey][taskKey].Duration
 				monthSum += dailyReport[dateKey][projectKey][taskKey].Duration
 			}
-			fmt.Println("       ", projectKey, ":", fmtDuration(time.Duration(projectSum*float64(time.Second))))
+			fmt.Print("        ", projectKey, " : ", fmtDuration(time.Duration(projectSum*float64(time.Second))))
+			if err := printReportColumns(projectKey, "", sumReportLines(dailyReport[dateKey][projectKey])); err != nil {
+				return err
+			}
+			fmt.Println()
 		}
 		fmt.Println("     ", dateKey, ":", fmtDuration(time.Duration(dailySum*float64(time.Second))))
 	}
@@ -241,9 +468,10 @@ func output() {
 	if viper.GetBool("report.monthlySUm") {
 		fmt.Println("\n Month: ", lastMonth, ":", fmtDuration(time.Duration(monthSum*float64(time.Second))))
 	}
+	return nil
 }
 
-func outputByProject() {
+func outputByProject() error {
 	grandTotal := 0.0
 
 	for _, projectKey := range projectKeysForProjectReport() {
@@ -253,6 +481,9 @@ func outputByProject() {
 		for _, taskKey := range taskKeysForProjectReport(projectKey) {
 			if !viper.GetBool("report.no-tasks") {
 				color.FgLightWhite.Print("        ", fmtDuration(time.Duration(projectReport[projectKey][taskKey].Duration*float64(time.Second))), " ", taskKey)
+				if err := printReportColumns(projectKey, taskKey, projectReport[projectKey][taskKey]); err != nil {
+					return err
+				}
 				if projectReport[projectKey][taskKey].Running {
 					color.FgLightYellow.Println(" (running)")
 				} else {
@@ -269,11 +500,92 @@ func outputByProject() {
 			projectSum += projectReport[projectKey][taskKey].Duration
 		}
 
-		fmt.Println("    Total:", fmtDuration(time.Duration(projectSum*float64(time.Second))))
+		fmt.Print("    Total: ", fmtDuration(time.Duration(projectSum*float64(time.Second))))
+		if err := printReportColumns(projectKey, "", sumReportLines(projectReport[projectKey])); err != nil {
+			return err
+		}
+		fmt.Println()
 		grandTotal += projectSum
 	}
 
 	fmt.Println("\nGrand Total:", fmtDuration(time.Duration(grandTotal*float64(time.Second))))
+	return nil
+}
+
+func outputByGroup(label string) error {
+	for _, groupKey := range groupKeysForGroupReport() {
+		groupSum := 0.0
+		activities := 0
+		fmt.Println("\n"+label+":", groupKey)
+
+		for _, projectKey := range projectKeysForGroupReport(groupKey) {
+			line := groupReport[groupKey][projectKey]
+			if !viper.GetBool("report.no-tasks") {
+				color.FgLightWhite.Print("        ", fmtDuration(time.Duration(line.Duration*float64(time.Second))), " ", projectKey)
+				if err := printReportColumns(projectKey, "", line); err != nil {
+					return err
+				}
+				if line.Running {
+					color.FgLightYellow.Println(" (running)")
+				} else {
+					fmt.Println()
+				}
+				if viper.GetBool("report.notes") {
+					for _, note := range line.Notes {
+						if len(note) > 0 {
+							color.FgLightBlue.Println("                    ", note)
+						}
+					}
+				}
+			}
+			groupSum += line.Duration
+			activities += len(line.Notes)
+		}
+
+		fmt.Println("    Total:", fmtDuration(time.Duration(groupSum*float64(time.Second))), "in", activities, "activities")
+	}
+	return nil
+}
+
+func outputMeetingCost(entries []Entry) error {
+	user := GetCurrentUser()
+
+	costs, err := GetMeetingCostsByWeek(user, entries)
+	if err != nil {
+		return err
+	}
+
+	currency := viper.GetString("meetings.currency")
+	grandTotal := decimal.Zero
+
+	weekKeys := make([]string, 0, len(costs))
+	for k := range costs {
+		weekKeys = append(weekKeys, k)
+	}
+	sort.Strings(weekKeys)
+
+	for _, weekKey := range weekKeys {
+		weekTotal := decimal.Zero
+		fmt.Println("\nWeek:", weekKey)
+
+		projectKeys := make([]string, 0, len(costs[weekKey]))
+		for k := range costs[weekKey] {
+			projectKeys = append(projectKeys, k)
+		}
+		sort.Strings(projectKeys)
+
+		for _, projectKey := range projectKeys {
+			meetingCost := costs[weekKey][projectKey]
+			color.FgLightWhite.Println("        ", fmtDuration(meetingCost.Duration), projectKey, "-", meetingCost.Meetings, "meetings,", currency, meetingCost.Cost.StringFixed(2))
+			weekTotal = weekTotal.Add(meetingCost.Cost)
+		}
+
+		fmt.Println("    Total:", currency, weekTotal.StringFixed(2))
+		grandTotal = grandTotal.Add(weekTotal)
+	}
+
+	fmt.Println("\nGrand Total:", currency, grandTotal.StringFixed(2))
+	return nil
 }
 
 func dialyKeys() []string {
@@ -335,3 +647,27 @@ func taskKeysForProjectReport(project string) []string {
 
 	return keys
 }
+
+func groupKeysForGroupReport() []string {
+	keys := make([]string, 0, len(groupReport))
+
+	for k := range groupReport {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func projectKeysForGroupReport(group string) []string {
+	keys := make([]string, 0, len(groupReport[group]))
+
+	for k := range groupReport[group] {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
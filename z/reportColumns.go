@@ -0,0 +1,112 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+)
+
+// reportColumn is a computed column of the report, configured either with
+// `--column name=expression` or as `report.columns` in the config.
+type reportColumn struct {
+	Name string
+	Expr *Expr
+}
+
+var reportColumns []reportColumn
+var reportProjects map[string]Project
+
+func ParseReportColumns(definitions []string) ([]reportColumn, error) {
+	var columns []reportColumn
+
+	if len(definitions) == 0 {
+		configured := viper.GetStringMapString("report.columns")
+		for name, source := range configured {
+			definitions = append(definitions, name+"="+source)
+		}
+		sort.Strings(definitions)
+	}
+
+	for _, definition := range definitions {
+		name, source, ok := strings.Cut(definition, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid column '%s', expected name=expression", definition)
+		}
+
+		expr, err := ParseExpr(source)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		columns = append(columns, reportColumn{Name: name, Expr: expr})
+	}
+
+	return columns, nil
+}
+
+// reportVariables returns the variables available to column expressions for
+// the given line of the report.
+func reportVariables(projectName string, taskName string, line reportLine) map[string]interface{} {
+	project, ok := reportProjects[projectName]
+	if !ok {
+		project, _ = database.GetProject(GetCurrentUser(), projectName)
+		reportProjects[projectName] = project
+	}
+
+	rate, _ := project.Rate.Float64()
+	return map[string]interface{}{
+		"duration":   line.Duration / 3600,
+		"minutes":    line.Duration / 60,
+		"seconds":    line.Duration,
+		"rate":       rate,
+		"currency":   project.Currency,
+		"billable":   project.Billable,
+		"project":    projectName,
+		"task":       taskName,
+		"activities": float64(len(line.Notes)),
+		"running":    line.Running,
+	}
+}
+
+// reportColumnValues evaluates the computed columns for the given line of the
+// report.
+func reportColumnValues(projectName string, taskName string, line reportLine) (map[string]interface{}, error) {
+	if len(reportColumns) == 0 {
+		return nil, nil
+	}
+
+	env := reportVariables(projectName, taskName, line)
+	values := make(map[string]interface{})
+	for _, column := range reportColumns {
+		value, err := column.Expr.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		values[column.Name] = value
+	}
+	return values, nil
+}
+
+func printReportColumns(projectName string, taskName string, line reportLine) error {
+	values, err := reportColumnValues(projectName, taskName, line)
+	if err != nil {
+		return err
+	}
+	for _, column := range reportColumns {
+		color.FgCyan.Print("  ", column.Name, ": ", FormatExprValue(values[column.Name]))
+	}
+	return nil
+}
+
+func sumReportLines(lines map[string]reportLine) reportLine {
+	var sum reportLine
+	for _, line := range lines {
+		sum.Duration += line.Duration
+		sum.Notes = append(sum.Notes, line.Notes...)
+		sum.Running = sum.Running || line.Running
+	}
+	return sum
+}
@@ -0,0 +1,128 @@
+package z
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Styles are inline, as most mail clients ignore style sheets.
+const (
+	htmlTableStyle    string = "border-collapse:collapse;font-family:sans-serif;font-size:14px"
+	htmlCellStyle     string = "padding:4px 12px;border-bottom:1px solid #e5e5e5;text-align:left;vertical-align:top"
+	htmlHeaderStyle   string = "padding:4px 12px;border-bottom:2px solid #333;text-align:left"
+	htmlSubtotalStyle string = "padding:4px 12px;border-bottom:1px solid #e5e5e5;color:#666;font-style:italic"
+	htmlTotalStyle    string = "padding:4px 12px;border-bottom:1px solid #333;font-weight:bold"
+)
+
+func htmlRow(style string, durationColumn int, cells ...string) string {
+	var row strings.Builder
+	row.WriteString("<tr>")
+	for idx, cell := range cells {
+		cellStyle := style
+		if idx == durationColumn {
+			cellStyle += ";text-align:right;white-space:nowrap"
+		}
+		fmt.Fprintf(&row, `<td style="%s">%s</td>`, cellStyle, cell)
+	}
+	row.WriteString("</tr>\n")
+	return row.String()
+}
+
+// ReportHTML writes the activities as an HTML document with a table grouped
+// like ReportMarkdown, e.g. for sending the report by email.
+func ReportHTML(entries []Entry, groupBy string, withNotes bool, title string) string {
+	var output strings.Builder
+
+	day := func(entry Entry) string { return entry.Begin.Format(DateFormat) }
+	project := func(entry Entry) string { return entry.Project }
+	outer, inner := day, project
+	if groupBy == ReportGroupByProject {
+		outer, inner = project, day
+	}
+
+	groups := make(map[string]map[string][]Entry)
+	for _, entry := range entries {
+		if groups[outer(entry)] == nil {
+			groups[outer(entry)] = make(map[string][]Entry)
+		}
+		groups[outer(entry)][inner(entry)] = append(groups[outer(entry)][inner(entry)], entry)
+	}
+
+	label := func(key string) string {
+		if key == "" {
+			return "(no project)"
+		}
+		return html.EscapeString(key)
+	}
+	withNotesCell := func(cells []string, notes string) []string {
+		if withNotes {
+			cells = append(cells, notes)
+		}
+		return cells
+	}
+
+	fmt.Fprintf(&output, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(title))
+	fmt.Fprintf(&output, "<h2 style=\"font-family:sans-serif\">%s</h2>\n", html.EscapeString(title))
+	fmt.Fprintf(&output, "<table style=\"%s\">\n<tr>", htmlTableStyle)
+	for idx, header := range withNotesCell([]string{"Date", "Project", "Task", "Duration"}, "Notes") {
+		style := htmlHeaderStyle
+		if idx == 3 {
+			style += ";text-align:right"
+		}
+		fmt.Fprintf(&output, `<th style="%s">%s</th>`, style, header)
+	}
+	output.WriteString("</tr>\n")
+
+	var outerKeys []string
+	for key := range groups {
+		outerKeys = append(outerKeys, key)
+	}
+	sort.Strings(outerKeys)
+
+	var total time.Duration
+	for _, outerKey := range outerKeys {
+		var outerTotal time.Duration
+
+		var innerKeys []string
+		for key := range groups[outerKey] {
+			innerKeys = append(innerKeys, key)
+		}
+		sort.Strings(innerKeys)
+
+		for _, innerKey := range innerKeys {
+			var innerTotal time.Duration
+
+			group := groups[outerKey][innerKey]
+			sort.SliceStable(group, func(i, j int) bool { return group[i].Begin.Before(group[j].Begin) })
+			for _, entry := range group {
+				duration := entry.End().Sub(entry.Begin)
+				innerTotal += duration
+
+				durationCell := fmtDuration(duration)
+				if entry.Finish.IsZero() {
+					durationCell += " (running)"
+				}
+				cells := []string{day(entry), html.EscapeString(entry.Project), html.EscapeString(entry.Task), durationCell}
+				output.WriteString(htmlRow(htmlCellStyle, 3, withNotesCell(cells, strings.ReplaceAll(html.EscapeString(entry.Notes), "\n", "<br>"))...))
+			}
+
+			cells := []string{"", label(innerKey), "", fmtDuration(innerTotal)}
+			if groupBy == ReportGroupByProject {
+				cells[0], cells[1] = cells[1], cells[0]
+			}
+			output.WriteString(htmlRow(htmlSubtotalStyle, 3, withNotesCell(cells, "")...))
+			outerTotal += innerTotal
+		}
+
+		output.WriteString(htmlRow(htmlTotalStyle, 3, withNotesCell([]string{label(outerKey), "", "", fmtDuration(outerTotal)}, "")...))
+		total += outerTotal
+	}
+
+	output.WriteString(htmlRow(htmlTotalStyle, 3, withNotesCell([]string{"Total", "", "", fmtDuration(total)}, "")...))
+	output.WriteString("</table>\n</body>\n</html>\n")
+
+	return output.String()
+}
@@ -0,0 +1,198 @@
+package z
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+// ReportItem is a line of the report as JSON, a day, project, task, tag,
+// attendee or week, with the lines it sums up as Items.
+type ReportItem struct {
+	Name       string                 `json:"name"`
+	Seconds    int64                  `json:"seconds"`
+	Activities int                    `json:"activities"`
+	Running    bool                   `json:"running,omitempty"`
+	Cost       *decimal.Decimal       `json:"cost,omitempty"`
+	Notes      []string               `json:"notes,omitempty"`
+	Columns    map[string]interface{} `json:"columns,omitempty"`
+	Items      []ReportItem           `json:"items,omitempty"`
+}
+
+type ReportSummary struct {
+	Since        *time.Time       `json:"since,omitempty"`
+	Until        *time.Time       `json:"until,omitempty"`
+	GroupBy      string           `json:"groupBy"`
+	Groups       []ReportItem     `json:"groups"`
+	TotalSeconds int64            `json:"totalSeconds"`
+	Currency     string           `json:"currency,omitempty"`
+	TotalCost    *decimal.Decimal `json:"totalCost,omitempty"`
+}
+
+func newReportSummary(groupBy string, sinceTime time.Time, untilTime time.Time) ReportSummary {
+	summary := ReportSummary{GroupBy: groupBy, Groups: []ReportItem{}}
+	if !sinceTime.IsZero() {
+		summary.Since = &sinceTime
+	}
+	if !untilTime.IsZero() {
+		summary.Until = &untilTime
+	}
+	return summary
+}
+
+// reportItem turns a line of the report into an item with its computed
+// columns and, if the report shows them, notes.
+func reportItem(name string, projectName string, taskName string, line reportLine) (ReportItem, error) {
+	columns, err := reportColumnValues(projectName, taskName, line)
+	if err != nil {
+		return ReportItem{}, err
+	}
+
+	item := ReportItem{
+		Name:       name,
+		Seconds:    int64(line.Duration),
+		Activities: len(line.Notes),
+		Running:    line.Running,
+		Columns:    columns,
+	}
+	if viper.GetBool("report.notes") {
+		for _, note := range line.Notes {
+			if note != "" {
+				item.Notes = append(item.Notes, note)
+			}
+		}
+	}
+	return item, nil
+}
+
+func (summary *ReportSummary) add(group ReportItem) {
+	summary.Groups = append(summary.Groups, group)
+	summary.TotalSeconds += group.Seconds
+}
+
+func reportSummaryByDay(sinceTime time.Time, untilTime time.Time) (ReportSummary, error) {
+	summary := newReportSummary(ReportGroupByDay, sinceTime, untilTime)
+
+	for _, dateKey := range dialyKeys() {
+		day := ReportItem{Name: dateKey}
+		for _, projectKey := range projectKeys(dateKey) {
+			project, err := reportItem(projectKey, projectKey, "", sumReportLines(dailyReport[dateKey][projectKey]))
+			if err != nil {
+				return summary, err
+			}
+			if !viper.GetBool("report.no-tasks") {
+				for _, taskKey := range taskKeys(dateKey, projectKey) {
+					item, err := reportItem(taskKey, projectKey, taskKey, dailyReport[dateKey][projectKey][taskKey])
+					if err != nil {
+						return summary, err
+					}
+					project.Items = append(project.Items, item)
+				}
+			}
+			day.Items = append(day.Items, project)
+			day.Seconds += project.Seconds
+			day.Activities += project.Activities
+			day.Running = day.Running || project.Running
+		}
+		summary.add(day)
+	}
+
+	return summary, nil
+}
+
+func reportSummaryByProject(sinceTime time.Time, untilTime time.Time) (ReportSummary, error) {
+	summary := newReportSummary(ReportGroupByProject, sinceTime, untilTime)
+
+	for _, projectKey := range projectKeysForProjectReport() {
+		project, err := reportItem(projectKey, projectKey, "", sumReportLines(projectReport[projectKey]))
+		if err != nil {
+			return summary, err
+		}
+		if !viper.GetBool("report.no-tasks") {
+			for _, taskKey := range taskKeysForProjectReport(projectKey) {
+				item, err := reportItem(taskKey, projectKey, taskKey, projectReport[projectKey][taskKey])
+				if err != nil {
+					return summary, err
+				}
+				project.Items = append(project.Items, item)
+			}
+		}
+		summary.add(project)
+	}
+
+	return summary, nil
+}
+
+func reportSummaryByGroup(groupBy string, sinceTime time.Time, untilTime time.Time) (ReportSummary, error) {
+	summary := newReportSummary(groupBy, sinceTime, untilTime)
+
+	for _, groupKey := range groupKeysForGroupReport() {
+		group := ReportItem{Name: groupKey}
+		for _, projectKey := range projectKeysForGroupReport(groupKey) {
+			project, err := reportItem(projectKey, projectKey, "", groupReport[groupKey][projectKey])
+			if err != nil {
+				return summary, err
+			}
+			group.Items = append(group.Items, project)
+			group.Seconds += project.Seconds
+			group.Activities += project.Activities
+			group.Running = group.Running || project.Running
+		}
+		// Time on an activity in several groups counts for each of them, in
+		// the total as well
+		summary.add(group)
+	}
+
+	return summary, nil
+}
+
+func reportSummaryByMeetingCost(entries []Entry, sinceTime time.Time, untilTime time.Time) (ReportSummary, error) {
+	summary := newReportSummary("week", sinceTime, untilTime)
+	summary.Currency = viper.GetString("meetings.currency")
+
+	costs, err := GetMeetingCostsByWeek(GetCurrentUser(), entries)
+	if err != nil {
+		return summary, err
+	}
+
+	weekKeys := make([]string, 0, len(costs))
+	for k := range costs {
+		weekKeys = append(weekKeys, k)
+	}
+	sort.Strings(weekKeys)
+
+	totalCost := decimal.Zero
+	for _, weekKey := range weekKeys {
+		weekCost := decimal.Zero
+		week := ReportItem{Name: weekKey}
+
+		projectKeys := make([]string, 0, len(costs[weekKey]))
+		for k := range costs[weekKey] {
+			projectKeys = append(projectKeys, k)
+		}
+		sort.Strings(projectKeys)
+
+		for _, projectKey := range projectKeys {
+			meetingCost := costs[weekKey][projectKey]
+			cost := meetingCost.Cost.Round(2)
+			week.Items = append(week.Items, ReportItem{
+				Name:       projectKey,
+				Seconds:    int64(meetingCost.Duration.Seconds()),
+				Activities: meetingCost.Meetings,
+				Cost:       &cost,
+			})
+			week.Seconds += int64(meetingCost.Duration.Seconds())
+			week.Activities += meetingCost.Meetings
+			weekCost = weekCost.Add(cost)
+		}
+
+		week.Cost = &weekCost
+		summary.add(week)
+		totalCost = totalCost.Add(weekCost)
+	}
+
+	summary.TotalCost = &totalCost
+	return summary, nil
+}
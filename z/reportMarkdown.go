@@ -0,0 +1,140 @@
+package z
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+func ReportFormats() []string {
+	return []string{
+		ReportFormatText,
+		ReportFormatMarkdown,
+		ReportFormatHTML,
+	}
+}
+
+func ReportGroupings() []string {
+	return []string{
+		ReportGroupByDay,
+		ReportGroupByProject,
+	}
+}
+
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// markdownLabel is the label of a group, which is empty for activities
+// without a project.
+func markdownLabel(key string) string {
+	if key == "" {
+		return "(no project)"
+	}
+	return markdownCell(key)
+}
+
+func markdownRow(cells ...string) string {
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+// ReportMarkdown writes the activities as a Markdown table, grouped by day
+// and then project, or by project and then day, with subtotals for both and
+// a grand total.
+func ReportMarkdown(entries []Entry, groupBy string, withNotes bool) string {
+	var output strings.Builder
+
+	day := func(entry Entry) string { return entry.Begin.Format(DateFormat) }
+	project := func(entry Entry) string { return entry.Project }
+	outer, inner := day, project
+	if groupBy == ReportGroupByProject {
+		outer, inner = project, day
+	}
+
+	groups := make(map[string]map[string][]Entry)
+	for _, entry := range entries {
+		if groups[outer(entry)] == nil {
+			groups[outer(entry)] = make(map[string][]Entry)
+		}
+		groups[outer(entry)][inner(entry)] = append(groups[outer(entry)][inner(entry)], entry)
+	}
+
+	header := []string{"Date", "Project", "Task", "Duration"}
+	align := []string{"---", "---", "---", "---:"}
+	if withNotes {
+		header = append(header, "Notes")
+		align = append(align, "---")
+	}
+	output.WriteString(markdownRow(header...))
+	output.WriteString(markdownRow(align...))
+
+	subtotal := func(label string, duration time.Duration) {
+		cells := []string{"", "", "", "**" + fmtDuration(duration) + "**"}
+		if groupBy == ReportGroupByProject {
+			cells[0] = label
+		} else {
+			cells[1] = label
+		}
+		if withNotes {
+			cells = append(cells, "")
+		}
+		output.WriteString(markdownRow(cells...))
+	}
+
+	var outerKeys []string
+	for key := range groups {
+		outerKeys = append(outerKeys, key)
+	}
+	sort.Strings(outerKeys)
+
+	var total time.Duration
+	for _, outerKey := range outerKeys {
+		var outerTotal time.Duration
+
+		var innerKeys []string
+		for key := range groups[outerKey] {
+			innerKeys = append(innerKeys, key)
+		}
+		sort.Strings(innerKeys)
+
+		for _, innerKey := range innerKeys {
+			var innerTotal time.Duration
+
+			group := groups[outerKey][innerKey]
+			sort.SliceStable(group, func(i, j int) bool { return group[i].Begin.Before(group[j].Begin) })
+			for _, entry := range group {
+				duration := entry.End().Sub(entry.Begin)
+				innerTotal += duration
+
+				durationCell := fmtDuration(duration)
+				if entry.Finish.IsZero() {
+					durationCell += " (running)"
+				}
+				cells := []string{day(entry), markdownCell(entry.Project), markdownCell(entry.Task), durationCell}
+				if withNotes {
+					cells = append(cells, markdownCell(entry.Notes))
+				}
+				output.WriteString(markdownRow(cells...))
+			}
+
+			subtotal("*"+markdownLabel(innerKey)+"*", innerTotal)
+			outerTotal += innerTotal
+		}
+
+		cells := []string{"**" + markdownLabel(outerKey) + "**", "", "", "**" + fmtDuration(outerTotal) + "**"}
+		if withNotes {
+			cells = append(cells, "")
+		}
+		output.WriteString(markdownRow(cells...))
+		total += outerTotal
+	}
+
+	cells := []string{"**Total**", "", "", "**" + fmtDuration(total) + "**"}
+	if withNotes {
+		cells = append(cells, "")
+	}
+	output.WriteString(markdownRow(cells...))
+
+	return output.String()
+}
@@ -0,0 +1,298 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"golang.org/x/term"
+)
+
+const timelineWidth int = 50
+
+type OverlapResolution struct {
+	Entries []Entry
+	Updated []Entry
+}
+
+func IsInteractive() bool {
+	return !IsOutputJSON() && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func readKey() (byte, error) {
+	fd := int(os.Stdin.Fd())
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, err
+	}
+	defer term.Restore(fd, state)
+
+	buf := make([]byte, 1)
+	if _, err = os.Stdin.Read(buf); err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+func findOverlap(entries []Entry, excludeID string, entry Entry) (Entry, bool) {
+	end := entry.End()
+
+	for _, existingEntry := range entries {
+		if existingEntry.ID == excludeID {
+			continue
+		}
+
+		if entry.Begin.Before(existingEntry.End()) && end.After(existingEntry.Begin) {
+			return existingEntry, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+func GetOutputForTimeline(mine Entry, theirs Entry) string {
+	start := mine.Begin
+	if theirs.Begin.Before(start) {
+		start = theirs.Begin
+	}
+
+	end := mine.End()
+	if theirs.End().After(end) {
+		end = theirs.End()
+	}
+
+	span := end.Sub(start)
+	pos := func(t time.Time) int {
+		if span <= 0 {
+			return 0
+		}
+		return int(float64(t.Sub(start)) / float64(span) * float64(timelineWidth))
+	}
+
+	overlapBegin, overlapEnd := pos(theirs.Begin), pos(theirs.End())
+	line := func(entry Entry, char string, clr color.Color) string {
+		var output string = ""
+		from, to := pos(entry.Begin), pos(entry.End())
+		for i := 0; i < timelineWidth; i++ {
+			switch {
+			case i < from || i >= to:
+				output += color.FgGray.Render("─")
+			case i >= overlapBegin && i < overlapEnd && clr != color.FgLightBlue:
+				output += color.FgLightRed.Render(char)
+			default:
+				output += clr.Render(char)
+			}
+		}
+		return output
+	}
+
+	return fmt.Sprintf("   mine   %s %s\n   theirs %s %s\n          %-*s%s\n",
+		line(mine, "█", color.FgLightYellow),
+		color.FgGray.Render(mine.ID),
+		line(theirs, "▒", color.FgLightBlue),
+		color.FgGray.Render(theirs.ID),
+		timelineWidth-5,
+		start.Format("15:04"),
+		end.Format("15:04"),
+	)
+}
+
+func trimEntry(entry Entry, conflict Entry) (Entry, error) {
+	if !entry.Begin.Before(conflict.Begin) && !entry.End().After(conflict.End()) {
+		return entry, errors.New("entry lies completely within the other one and cannot be trimmed; split instead")
+	}
+
+	if entry.Begin.Before(conflict.Begin) {
+		if entry.Finish.IsZero() {
+			return entry, errors.New("cannot trim the finish of a running entry")
+		}
+		entry.Finish = conflict.Begin
+	} else {
+		if conflict.Finish.IsZero() {
+			return entry, errors.New("cannot trim towards a running entry")
+		}
+		entry.Begin = conflict.Finish
+	}
+
+	return entry, nil
+}
+
+func splitEntries(mine Entry, theirs Entry) (Entry, Entry, *Entry, bool, error) {
+	var outer, inner Entry
+	var mineIsOuter bool
+
+	switch {
+	case !mine.Begin.After(theirs.Begin) && !mine.End().Before(theirs.End()):
+		outer, inner, mineIsOuter = mine, theirs, true
+	case !theirs.Begin.After(mine.Begin) && !theirs.End().Before(mine.End()):
+		outer, inner, mineIsOuter = theirs, mine, false
+	default:
+		// Partial overlap, split the overlapping period in the middle
+		overlapBegin, overlapEnd := theirs.Begin, mine.End()
+		if mine.Begin.After(theirs.Begin) {
+			overlapBegin, overlapEnd = mine.Begin, theirs.End()
+		}
+		middle := overlapBegin.Add(overlapEnd.Sub(overlapBegin) / 2)
+
+		if mine.Begin.Before(theirs.Begin) {
+			if mine.Finish.IsZero() {
+				return mine, theirs, nil, false, errors.New("cannot split a running entry")
+			}
+			mine.Finish, theirs.Begin = middle, middle
+		} else {
+			if theirs.Finish.IsZero() {
+				return mine, theirs, nil, false, errors.New("cannot split a running entry")
+			}
+			theirs.Finish, mine.Begin = middle, middle
+		}
+		return mine, theirs, nil, false, nil
+	}
+
+	if outer.Finish.IsZero() {
+		return mine, theirs, nil, false, errors.New("cannot split a running entry")
+	}
+
+	after := outer
+	after.ID = ""
+	after.Begin = inner.End()
+	outer.Finish = inner.Begin
+
+	if mineIsOuter {
+		return outer, inner, &after, true, nil
+	}
+	return inner, outer, &after, false, nil
+}
+
+// ResolveOverlaps checks the entry against all existing entries and, if
+// running interactively, lets the user resolve every overlap one by one.
+// Nothing is persisted; the caller stores the resolution.
+func ResolveOverlaps(user string, excludeID string, entry Entry) (OverlapResolution, error) {
+	var resolution OverlapResolution
+
+	if GetOverlapPolicy() == OverlapAllow {
+		resolution.Entries = []Entry{entry}
+		return resolution, nil
+	}
+
+	entries, err := database.ListEntriesBetween(user, entry.Begin, entry.End())
+	if err != nil {
+		return resolution, fmt.Errorf("failed to check for overlaps: %v", err)
+	}
+
+	queue := []Entry{entry}
+	updated := make(map[string]int)
+
+	for i := 0; i < len(queue); i++ {
+		for {
+			conflict, found := findOverlap(entries, excludeID, queue[i])
+			if !found {
+				break
+			}
+
+			verr := NewOverlapError(queue[i], queue[i].End(), conflict, conflict.End())
+			if !IsInteractive() || GetOverlapPolicy() == OverlapReject {
+				return resolution, verr
+			}
+
+			fmt.Printf("%s %s\n\n%s\n", CharError, verr.Message, GetOutputForTimeline(queue[i], conflict))
+			fmt.Printf("%s [m]trim mine  [t]trim theirs  [s]split  [a]abort: ", CharMore)
+			key, err := readKey()
+			fmt.Printf("%c\n", key)
+			if err != nil {
+				return resolution, err
+			}
+
+			var actionErr error = nil
+			var theirsChanged bool = false
+			theirs := conflict
+
+			switch strings.ToLower(string(key)) {
+			case "m":
+				queue[i], actionErr = trimEntry(queue[i], conflict)
+			case "t":
+				theirs, actionErr = trimEntry(conflict, queue[i])
+				theirsChanged = true
+			case "s":
+				var after *Entry
+				var afterIsMine bool
+				queue[i], theirs, after, afterIsMine, actionErr = splitEntries(queue[i], conflict)
+				theirsChanged = true
+				if actionErr == nil && after != nil {
+					if afterIsMine {
+						queue = append(queue, *after)
+					} else {
+						resolution.Entries = append(resolution.Entries, *after)
+						entries = append(entries, *after)
+					}
+				}
+			case "a", "q", "\x03", "\x1b":
+				return resolution, verr
+			default:
+				actionErr = errors.New("unknown action")
+			}
+
+			if actionErr != nil {
+				fmt.Printf("%s %+v\n", CharError, actionErr)
+				continue
+			}
+
+			if theirsChanged {
+				for idx := range entries {
+					if entries[idx].ID == theirs.ID {
+						entries[idx] = theirs
+					}
+				}
+				if idx, ok := updated[theirs.ID]; ok {
+					resolution.Updated[idx] = theirs
+				} else {
+					updated[theirs.ID] = len(resolution.Updated)
+					resolution.Updated = append(resolution.Updated, theirs)
+				}
+			}
+		}
+	}
+
+	resolution.Entries = append(queue, resolution.Entries...)
+	return resolution, nil
+}
+
+// StoreResolution persists the first resolved entry using store, every
+// additional entry created by splitting as a new entry and every modified
+// existing entry as an update. Nothing is stored if an existing entry can't
+// be changed anymore in strict mode.
+func StoreResolution(user string, resolution OverlapResolution, store func(Entry) (string, error)) error {
+	for _, entry := range resolution.Updated {
+		existing, err := database.GetEntry(user, entry.ID)
+		if err != nil {
+			return err
+		}
+		if err = ValidateEditWindow(existing); err != nil {
+			return err
+		}
+	}
+
+	for idx, entry := range resolution.Entries {
+		var err error
+		if idx == 0 {
+			_, err = store(entry)
+		} else {
+			_, err = database.AddEntry(user, entry, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range resolution.Updated {
+		if _, err := database.UpdateEntry(user, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
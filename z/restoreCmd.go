@@ -0,0 +1,80 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreMerge   bool
+	restoreReplace bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore ([flags]) [file]",
+	Short: "Restore database",
+	Long:  "Restore entries, projects, tasks and metadata from a backup archive, after verifying its integrity.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if restoreMerge == restoreReplace {
+			return errors.New("specify either --merge or --replace")
+		}
+
+		mode := RestoreMerge
+		if restoreReplace {
+			mode = RestoreReplace
+		}
+
+		backup, err := ReadBackupFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		if backup.Manifest.User != user {
+			fmt.Printf("%s backup was created by user %s, restoring for %s\n", CharInfo,
+				color.FgLightWhite.Render(backup.Manifest.User), color.FgLightWhite.Render(user))
+		}
+
+		if err := AutoBackup(user, "restore"); err != nil {
+			return err
+		}
+
+		stats, err := backup.Restore(user, mode)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s restored %d entries, %d projects and %d tasks from %s (%s)\n",
+			CharInfo,
+			stats.Entries,
+			stats.Projects,
+			stats.Tasks,
+			color.FgLightWhite.Render(args[0]),
+			backup.Manifest.Created.Format(GetTimeDisplayFormat()),
+		)
+		if stats.Skipped > 0 {
+			fmt.Printf("%s skipped %d already existing entries\n", CharInfo, stats.Skipped)
+		}
+		if backup.ConfigName != "" {
+			if encrypted, _ := IsEncryptedFile(args[0]); encrypted {
+				fmt.Printf("%s the backup contains the config file %s, which was not restored; the backup is encrypted, decrypt it before extracting the file\n",
+					CharInfo, backup.ConfigName)
+			} else {
+				fmt.Printf("%s the backup contains the config file %s, which was not restored; extract it using `tar -xzf %s config/%s`\n",
+					CharInfo, backup.ConfigName, args[0], backup.ConfigName)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().BoolVar(&restoreMerge, "merge", false, "Merge the backup into the database, keeping existing entries")
+	restoreCmd.Flags().BoolVar(&restoreReplace, "replace", false, "Replace all entries, projects and tasks with the backup")
+}
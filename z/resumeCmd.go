@@ -8,8 +8,16 @@ var resumeCmd = &cobra.Command{
 	Use:   "resume",
 	Short: "Resume last task",
 	Long:  "Track new activity with all parameters of the last task (based on begin time)",
-	Run: func(cmd *cobra.Command, args []string) {
-		resumeTask(1)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tracked, err := resumeTask(1)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Tracked: entryResources([]Entry{tracked})})
+		}
+		return nil
 	},
 }
 
@@ -1,15 +1,20 @@
 package z
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gookit/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-var database *Database
+var database Storage
+var closeDatabase func() = func() {}
 
 var (
 	begin        string
@@ -18,6 +23,9 @@ var (
 	project      string
 	task         string
 	notes        string
+	attendees    []string
+	tags         []string
+	references   []string
 )
 
 var (
@@ -29,12 +37,18 @@ var (
 var (
 	format string
 	force  bool
+	create bool
+	redact []string
 )
 
 var (
-	noColors bool
-	debug    bool
-	cfgFile  string
+	noColors     bool
+	debug        bool
+	cfgFile      string
+	outputFormat string
+	wait         bool
+	dbFile       string
+	alsoDBFiles  []string
 )
 
 const (
@@ -50,50 +64,129 @@ var rootCmd = &cobra.Command{
 	Use:   "zeit",
 	Short: "Command line Zeiterfassung",
 	Long:  `A command line time tracker.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The arguments were parsed, so errors from here on aren't usage errors
+		cmd.SilenceUsage = true
+
+		return preRun(cmd)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		postRun(cmd)
+	},
+}
+
+// preRun prepares running a command once its flags were parsed: it applies
+// the configured flag defaults, opens the database and checks for a stale
+// running entry.
+func preRun(cmd *cobra.Command) error {
+	if err := ApplyFlagDefaults(cmd); err != nil {
+		return err
+	}
+
+	if viper.GetBool(FlagNoColors) {
+		color.Disable()
+	}
+
+	if err := initStorage(cmd); err != nil {
+		return err
+	}
+	return CheckStaleEntry(cmd)
+}
+
+// postRun updates the prompt state after a command that may have changed the
+// entries.
+func postRun(cmd *cobra.Command) {
+	if database != nil && !IsNoDatabaseCommand(cmd) && !IsReadOnlyCommand(cmd) {
+		updatePromptState(viper.GetString("db"))
+	}
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(-1)
+	cmd, err := rootCmd.ExecuteC()
+
+	// Releasing the database runs the post hooks, which may use it too
+	closeDatabase()
+
+	if err != nil {
+		printError(err)
+		if !cmd.SilenceUsage {
+			os.Exit(ExitUsage)
+		}
+		os.Exit(ExitCode(err))
+	}
+
+	if err := FinishPerf(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s could not write the perf log: %+v\n", CharError, err)
 	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/zeit.[yaml|toml")
+	// Errors are printed by Execute, in the format selected by --output
+	rootCmd.SilenceErrors = true
+
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/zeit/config.toml or $XDG_CONFIG_HOME/zeit.[yaml|toml])")
+
+	rootCmd.PersistentFlags().StringVar(&dbFile, "db", "", "database file, PostgreSQL URL or git:<repository> (default is $XDG_DATA_HOME/zeit/zeit.db)")
+	viper.BindPFlag("db", rootCmd.PersistentFlags().Lookup("db"))
+
+	rootCmd.PersistentFlags().StringSliceVar(&alsoDBFiles, "also-db", []string{}, "additional database files or PostgreSQL URLs to include read-only, e.g. for combined reports")
+	viper.BindPFlag("federation.databases", rootCmd.PersistentFlags().Lookup("also-db"))
 
 	rootCmd.PersistentFlags().BoolVar(&noColors, FlagNoColors, false, "Do not use colors in output")
 	viper.BindPFlag(FlagNoColors, rootCmd.PersistentFlags().Lookup(FlagNoColors))
 
 	rootCmd.PersistentFlags().BoolVarP(&debug, FlagDebug, "d", false, "Display debugging output in the console. (default: false)")
 	viper.BindPFlag(FlagDebug, rootCmd.PersistentFlags().Lookup(FlagDebug))
+
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, FlagOutput, "o", OutputText, "Output format, possible values: "+strings.Join(Outputs(), ", "))
+	viper.BindPFlag(FlagOutput, rootCmd.PersistentFlags().Lookup(FlagOutput))
+
+	rootCmd.PersistentFlags().BoolVar(&wait, FlagWait, false, "Wait for the database to be unlocked if it is in use by another zeit process")
+	viper.BindPFlag(FlagWait, rootCmd.PersistentFlags().Lookup(FlagWait))
+
+	// --period is the same as --range on every command accepting one
+	rootCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "period" {
+			name = "range"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
 
 func initConfig() {
-	if noColors == true {
-		color.Disable()
-	}
-
 	viper.SetEnvPrefix("zeit")
 	viper.BindEnv("db")
+	viper.BindEnv("user")
+	viper.BindEnv("passphrase")
 
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		viper.AddConfigPath("$XDG_CONFIG_HOME")
-		viper.AddConfigPath("$XDG_CONFIG_HOME/zeit")
-		viper.AddConfigPath(home + "/.config")
-		viper.AddConfigPath(home + "/.config/zeit")
+		configHome := GetConfigHome()
+		if configHome == "" {
+			cobra.CheckErr(errors.New("could not determine $XDG_CONFIG_HOME"))
+		}
+
+		viper.AddConfigPath(configHome)
+		viper.AddConfigPath(filepath.Join(configHome, "zeit"))
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(filepath.Join(home, ".config"))
+			viper.AddConfigPath(filepath.Join(home, ".config", "zeit"))
+		}
 		viper.SetConfigName("zeit")
+
+		if configFile := GetConfigFile(); configFile != "" {
+			viper.SetConfigFile(configFile)
+		}
 	}
 
+	viper.SetDefault("db", GetDefaultDatabasePath())
+	viper.SetDefault("backup.auto", true)
+	viper.SetDefault("backup.keep", 10)
+
 	if err := viper.ReadInConfig(); err != nil {
 		// Set default values for parameters
 		viper.Set("debug", false)
@@ -103,11 +196,36 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 		fmt.Fprintln(os.Stderr, "Using Database file:", viper.GetString("db"))
 	}
+}
 
+func initStorage(cmd *cobra.Command) error {
 	var err error
-	database, err = InitDatabase()
+
+	if IsNoDatabaseCommand(cmd) {
+		return nil
+	}
+
+	dbfile := viper.GetString("db")
+	if dbfile == GetDefaultDatabasePath() && !fileExists(dbfile) && viper.GetString("remote.url") == "" {
+		for _, legacyPath := range GetLegacyDatabasePaths() {
+			if fileExists(legacyPath) {
+				fmt.Fprintf(os.Stderr, "%s found a database at %s; run `zeit migrate` to use it\n", CharInfo, legacyPath)
+				break
+			}
+		}
+	}
+
+	if IsPerfEnabled() {
+		StartPerf(cmd.CommandPath())
+	}
+
+	closeDatabase, err = OpenStorage(!IsReadOnlyCommand(cmd), viper.GetBool(FlagWait))
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return &StorageError{Err: err}
+	}
+
+	if IsPerfEnabled() {
+		database = NewTimedStorage(database)
 	}
+	return nil
 }
@@ -0,0 +1,150 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	roundApply       bool
+	roundGranularity string
+	roundDirection   string
+	roundPer         string
+)
+
+// RoundOutput lists the activities rounded, or the ones that would be rounded
+// unless Applied, with --output json.
+type RoundOutput struct {
+	Applied bool            `json:"applied"`
+	Entries []EntryResource `json:"entries"`
+}
+
+var roundCmd = &cobra.Command{
+	Use:   "round ([flags])",
+	Short: "Round tracked activities",
+	Long:  "Preview rounding the finished activities according to the rounding rules, or, using --apply, round the stored activities permanently.",
+	Annotations: map[string]string{
+		AnnotationReadOnly:   "true",
+		AnnotationWritesWith: "apply",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		rounding, err := GetRounding()
+		if err != nil {
+			return err
+		}
+		if roundGranularity != "" {
+			if rounding.Granularity, err = time.ParseDuration(roundGranularity); err != nil || rounding.Granularity <= 0 {
+				return fmt.Errorf("invalid granularity '%s', use e.g. 6m or 15m", roundGranularity)
+			}
+		}
+		if roundDirection != "" {
+			rounding.Direction = strings.ToLower(roundDirection)
+			if !zeit.ContainsFold(RoundingDirections(), rounding.Direction) {
+				return fmt.Errorf("unknown direction '%s', possible values: %s", roundDirection, strings.Join(RoundingDirections(), ", "))
+			}
+		}
+		if roundPer != "" {
+			rounding.Per = strings.ToLower(roundPer)
+			if !zeit.ContainsFold(RoundingAggregations(), rounding.Per) {
+				return fmt.Errorf("unknown aggregation '%s', possible values: %s", roundPer, strings.Join(RoundingAggregations(), ", "))
+			}
+		}
+		if !rounding.IsSet() {
+			return fmt.Errorf("no rounding configured, set rounding.granularity or use --granularity")
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		var changed []Entry
+		for idx, entry := range rounding.RoundEntries(entries) {
+			if entry.Finish.Equal(entries[idx].Finish) {
+				continue
+			}
+			if !IsOutputJSON() {
+				fmt.Printf("%s %s %s %s on %s: %sh → %sh\n",
+					CharMore,
+					color.FgGray.Render(entry.ID),
+					entry.Begin.Format("Mon 2006-01-02 15:04"),
+					color.FgLightWhite.Render(entry.Project),
+					color.FgLightWhite.Render(entry.Task),
+					fmtDuration(entries[idx].Finish.Sub(entries[idx].Begin)),
+					color.FgLightWhite.Render(fmtDuration(entry.Finish.Sub(entry.Begin))),
+				)
+			}
+			changed = append(changed, entry)
+		}
+
+		if IsOutputJSON() && (len(changed) == 0 || !roundApply) {
+			return printJSON(RoundOutput{Entries: entryResources(changed)})
+		}
+		if len(changed) == 0 {
+			fmt.Printf("%s nothing to round\n", CharInfo)
+			return nil
+		}
+		if !roundApply {
+			fmt.Printf("%s %d activities would be rounded, use --apply to round them\n", CharInfo, len(changed))
+			return nil
+		}
+
+		for _, entry := range changed {
+			if err = ValidateEditWindow(entry); err != nil {
+				return err
+			}
+		}
+
+		if err := AutoBackup(user, "round"); err != nil {
+			return err
+		}
+
+		err = Batch(func() error {
+			for _, entry := range changed {
+				if _, err := database.UpdateEntry(user, entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(RoundOutput{Applied: true, Entries: entryResources(changed)})
+		}
+
+		fmt.Printf("%s rounded %d activities\n", CharFinish, len(changed))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(roundCmd)
+	roundCmd.Flags().BoolVar(&roundApply, "apply", false, "Round the stored activities permanently")
+	roundCmd.Flags().StringVar(&roundGranularity, "granularity", "", "Round to multiples of this duration instead of rounding.granularity, e.g. 15m")
+	roundCmd.Flags().StringVar(&roundDirection, "direction", "", "Direction to round in instead of rounding.direction, possible values: "+strings.Join(RoundingDirections(), ", "))
+	roundCmd.Flags().StringVar(&roundPer, "per", "", "Round per activity or per day instead of rounding.per, possible values: "+strings.Join(RoundingAggregations(), ", "))
+	roundCmd.Flags().StringVar(&since, "since", "", "Date/time to start rounding from")
+	roundCmd.Flags().StringVar(&until, "until", "", "Date/time to round until")
+	roundCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	roundCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be rounded")
+	roundCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be rounded")
+	roundCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,169 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+func RoundingDirections() []string {
+	return []string{
+		RoundUp,
+		RoundNearest,
+		RoundDown,
+	}
+}
+
+func RoundingAggregations() []string {
+	return []string{
+		RoundPerEntry,
+		RoundPerDay,
+	}
+}
+
+// Rounding rounds tracked time to multiples of Granularity, either every
+// activity on its own or, Per day, the time tracked on a project and task
+// per day. Rules are configured as
+//
+//	rounding:
+//	  granularity: 15m
+//	  direction: up
+//	  per: entry
+//	  apply: [stats, export, invoice]
+type Rounding struct {
+	Granularity time.Duration
+	Direction   string
+	Per         string
+	Apply       []string
+}
+
+// GetRounding returns the rounding rules configured as rounding. Without a
+// granularity nothing is rounded.
+func GetRounding() (Rounding, error) {
+	rounding := Rounding{
+		Direction: RoundUp,
+		Per:       RoundPerEntry,
+		Apply:     []string{RoundInStats, RoundInExport, RoundInInvoice},
+	}
+
+	if value := viper.GetString("rounding.granularity"); value != "" {
+		granularity, err := time.ParseDuration(value)
+		if err != nil || granularity < 0 {
+			return rounding, fmt.Errorf("invalid rounding.granularity '%s', use e.g. 6m or 15m", value)
+		}
+		rounding.Granularity = granularity
+	}
+	if value := viper.GetString("rounding.direction"); value != "" {
+		rounding.Direction = strings.ToLower(value)
+	}
+	if !zeit.ContainsFold(RoundingDirections(), rounding.Direction) {
+		return rounding, fmt.Errorf("unknown rounding.direction '%s', possible values: %s", rounding.Direction, strings.Join(RoundingDirections(), ", "))
+	}
+	if value := viper.GetString("rounding.per"); value != "" {
+		rounding.Per = strings.ToLower(value)
+	}
+	if !zeit.ContainsFold(RoundingAggregations(), rounding.Per) {
+		return rounding, fmt.Errorf("unknown rounding.per '%s', possible values: %s", rounding.Per, strings.Join(RoundingAggregations(), ", "))
+	}
+	if viper.IsSet("rounding.apply") {
+		rounding.Apply = viper.GetStringSlice("rounding.apply")
+	}
+
+	return rounding, nil
+}
+
+// GetRoundingFor returns the rounding rules if they apply to where, e.g.
+// RoundInStats, and no rounding otherwise.
+func GetRoundingFor(where string) (Rounding, error) {
+	rounding, err := GetRounding()
+	if err != nil || !zeit.ContainsFold(rounding.Apply, where) {
+		return Rounding{}, err
+	}
+	return rounding, nil
+}
+
+func (rounding *Rounding) IsSet() bool {
+	return rounding.Granularity > 0
+}
+
+// Round rounds the duration to a multiple of the granularity.
+func (rounding *Rounding) Round(duration time.Duration) time.Duration {
+	if !rounding.IsSet() {
+		return duration
+	}
+
+	switch rounding.Direction {
+	case RoundNearest:
+		return duration.Round(rounding.Granularity)
+	case RoundDown:
+		return duration.Truncate(rounding.Granularity)
+	default:
+		rounded := duration.Truncate(rounding.Granularity)
+		if rounded < duration {
+			rounded += rounding.Granularity
+		}
+		return rounded
+	}
+}
+
+// RoundEntries returns the entries with their finish moved so that their
+// durations are rounded. Rounding per day rounds the time tracked on each
+// project and task per day and moves the finish of the day's last activities
+// by the difference. Running activities are left as they are.
+func (rounding *Rounding) RoundEntries(entries []Entry) []Entry {
+	rounded := make([]Entry, len(entries))
+	copy(rounded, entries)
+	if !rounding.IsSet() {
+		return rounded
+	}
+
+	if rounding.Per != RoundPerDay {
+		for idx := range rounded {
+			if entry := &rounded[idx]; !entry.Finish.IsZero() {
+				entry.Finish = entry.Begin.Add(rounding.Round(entry.Finish.Sub(entry.Begin)))
+			}
+		}
+		return rounded
+	}
+
+	groups := make(map[string][]int)
+	var keys []string
+	for idx, entry := range rounded {
+		if entry.Finish.IsZero() {
+			continue
+		}
+		key := entry.Begin.Format(DateFormat) + "\x1f" + zeit.GetIdFromName(entry.Project) + "\x1f" + zeit.GetIdFromName(entry.Task)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], idx)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool { return rounded[group[i]].Begin.Before(rounded[group[j]].Begin) })
+
+		var total time.Duration
+		for _, idx := range group {
+			total += rounded[idx].Finish.Sub(rounded[idx].Begin)
+		}
+		delta := rounding.Round(total) - total
+
+		for i := len(group) - 1; i >= 0 && delta != 0; i-- {
+			entry := &rounded[group[i]]
+			if delta > 0 {
+				entry.Finish = entry.Finish.Add(delta)
+				break
+			}
+			shorten := max(delta, -entry.Finish.Sub(entry.Begin))
+			entry.Finish = entry.Finish.Add(shorten)
+			delta -= shorten
+		}
+	}
+
+	return rounded
+}
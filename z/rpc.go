@@ -0,0 +1,190 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	rpcParseError     int = -32700
+	rpcInvalidRequest int = -32600
+	rpcMethodNotFound int = -32601
+	rpcInvalidParams  int = -32602
+	rpcServerError    int = -32000
+)
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Data    *ErrorObject `json:"data,omitempty"`
+}
+
+// rpcMethod maps a JSON-RPC method to a handler of the server. Params are
+// passed as query for reading methods and as body for the others, except for
+// `id`, which is passed as path value.
+type rpcMethod struct {
+	exclusive bool
+	method    string
+	fn        func(user string, r *http.Request) (int, interface{}, error)
+}
+
+// RPC answers JSON-RPC 2.0 requests, one per line, using the handlers of a
+// server acting as its user. Like with the server, the database is only
+// opened while a request is handled.
+type RPC struct {
+	Server  *Server
+	methods map[string]rpcMethod
+}
+
+func NewRPC(user string) *RPC {
+	server := NewServer(user, "")
+
+	return &RPC{
+		Server: server,
+		methods: map[string]rpcMethod{
+			"status":    {false, http.MethodGet, server.getStatus},
+			"stats":     {false, http.MethodGet, server.getStats},
+			"list":      {false, http.MethodGet, server.listEntries},
+			"get":       {false, http.MethodGet, server.getEntry},
+			"start":     {true, http.MethodPost, server.start},
+			"stop":      {true, http.MethodPost, server.stop},
+			"heartbeat": {false, http.MethodPost, server.heartbeat},
+			"create":    {true, http.MethodPost, server.createEntry},
+			"edit":      {true, http.MethodPut, server.updateEntry},
+			"erase":     {true, http.MethodDelete, server.eraseEntry},
+			"projects":  {false, http.MethodGet, server.listProjects},
+			"tasks":     {false, http.MethodGet, server.listTasks},
+		},
+	}
+}
+
+func (rpc *RPC) Serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			encoder.Encode(rpcResponse{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+
+		res := rpc.call(req)
+		if req.ID == nil {
+			continue
+		}
+		if err := encoder.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (rpc *RPC) call(req rpcRequest) rpcResponse {
+	res := rpcResponse{Version: "2.0", ID: req.ID}
+
+	if req.Version != "2.0" || req.Method == "" {
+		res.Error = &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}
+		return res
+	}
+
+	method, ok := rpc.methods[req.Method]
+	if !ok {
+		res.Error = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method '%s'", req.Method)}
+		return res
+	}
+
+	r, err := method.request(req.Params)
+	if err != nil {
+		res.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		return res
+	}
+
+	status, v := rpc.Server.call(method.exclusive, func() (int, interface{}, error) {
+		return method.fn(rpc.Server.User, r)
+	})
+
+	if eo, ok := v.(ErrorOutput); ok {
+		code := rpcServerError
+		if status == http.StatusBadRequest {
+			code = rpcInvalidParams
+		}
+		res.Error = &rpcError{Code: code, Message: eo.Error.Message, Data: &eo.Error}
+		return res
+	}
+
+	res.Result = v
+	if v == nil {
+		res.Result = true
+	}
+	return res
+}
+
+func (method *rpcMethod) request(rawParams json.RawMessage) (*http.Request, error) {
+	params := make(map[string]interface{})
+	if len(rawParams) > 0 && string(rawParams) != "null" {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			return nil, fmt.Errorf("params must be an object: %v", err)
+		}
+	}
+
+	id, _ := params["id"].(string)
+	delete(params, "id")
+
+	var r *http.Request
+	var err error
+	if method.method == http.MethodGet || method.method == http.MethodDelete {
+		query := url.Values{}
+		for key, value := range params {
+			if values, ok := value.([]interface{}); ok {
+				var list []string
+				for _, v := range values {
+					list = append(list, fmt.Sprint(v))
+				}
+				query.Set(key, strings.Join(list, ","))
+				continue
+			}
+			query.Set(key, fmt.Sprint(value))
+		}
+		r, err = http.NewRequest(method.method, "/?"+query.Encode(), nil)
+	} else {
+		var body []byte
+		if body, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+		r, err = http.NewRequest(method.method, "/", bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.SetPathValue("id", id)
+	return r, nil
+}
@@ -0,0 +1,25 @@
+package z
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rpcCmd = &cobra.Command{
+	Use:         "rpc",
+	Short:       "Speak JSON-RPC over stdio",
+	Long:        "Answer JSON-RPC 2.0 requests read from stdin, one per line, for editor plugins to integrate with zeit without spawning a process per call. Methods: status, stats, list, get, start, stop, heartbeat, create, edit, erase, projects and tasks, taking the same parameters as the corresponding endpoints of zeit serve.",
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Responses are written to stdout, which must not contain anything else
+		viper.Set(FlagOutput, OutputJSON)
+
+		return NewRPC(GetCurrentUser()).Serve(os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rpcCmd)
+}
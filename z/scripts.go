@@ -0,0 +1,254 @@
+package z
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// defaultScriptTimeout is how long a script function may run unless
+// scripts.timeout is set.
+const defaultScriptTimeout time.Duration = 5 * time.Second
+
+// script is a Lua script of the scripts directory, loaded into a state of
+// its own so that scripts can't overwrite each other's functions.
+type script struct {
+	name  string
+	state *lua.LState
+}
+
+var scripts []*script
+var scriptsErr error
+var scriptsOnce sync.Once
+
+// scriptsMutex serializes calls, a Lua state must not be used concurrently.
+var scriptsMutex sync.Mutex
+
+// GetScriptsDir returns the directory scripts are loaded from, scripts.dir or
+// $XDG_CONFIG_HOME/zeit/scripts.
+func GetScriptsDir() string {
+	if dir := viper.GetString("scripts.dir"); dir != "" {
+		return dir
+	}
+
+	configHome := GetConfigHome()
+	if configHome == "" {
+		return ""
+	}
+	return filepath.Join(configHome, "zeit", "scripts")
+}
+
+// loadScripts loads the *.lua files of the scripts directory sorted by name,
+// once per run.
+func loadScripts() ([]*script, error) {
+	scriptsOnce.Do(func() {
+		dir := GetScriptsDir()
+		if dir == "" {
+			return
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+		if err != nil {
+			scriptsErr = err
+			return
+		}
+
+		for _, file := range files {
+			state := lua.NewState()
+			if err := state.DoFile(file); err != nil {
+				state.Close()
+				scriptsErr = fmt.Errorf("script %s: %v", filepath.Base(file), err)
+				return
+			}
+			scripts = append(scripts, &script{name: filepath.Base(file), state: state})
+		}
+	})
+
+	return scripts, scriptsErr
+}
+
+func getScriptTimeout() (time.Duration, error) {
+	if !viper.IsSet("scripts.timeout") {
+		return defaultScriptTimeout, nil
+	}
+	timeout, err := time.ParseDuration(viper.GetString("scripts.timeout"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid scripts.timeout: %v", err)
+	}
+	return timeout, nil
+}
+
+func (s *script) function(name string) *lua.LFunction {
+	fn, _ := s.state.GetGlobal(name).(*lua.LFunction)
+	return fn
+}
+
+// call calls the function with the arguments converted to Lua and returns
+// nret results.
+func (s *script) call(fn *lua.LFunction, nret int, args ...interface{}) ([]lua.LValue, error) {
+	timeout, err := getScriptTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s.state.SetContext(ctx)
+	defer s.state.RemoveContext()
+
+	var values []lua.LValue
+	for _, arg := range args {
+		values = append(values, luaValue(s.state, arg))
+	}
+
+	err = s.state.CallByParam(lua.P{Fn: fn, NRet: nret, Protect: true}, values...)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("script %s timed out after %s", s.name, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %v", s.name, err)
+	}
+
+	results := make([]lua.LValue, nret)
+	for i := range results {
+		results[i] = s.state.Get(i - nret)
+	}
+	s.state.Pop(nret)
+	return results, nil
+}
+
+// HasScriptFunction returns whether one of the scripts defines a global
+// function of the name.
+func HasScriptFunction(name string) bool {
+	scripts, _ := loadScripts()
+	for _, s := range scripts {
+		if s.function(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CallScriptFunction calls the global function of the name defined by the
+// first script that defines it, e.g. in report columns or list formats.
+func CallScriptFunction(name string, args ...interface{}) (interface{}, error) {
+	scripts, err := loadScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	scriptsMutex.Lock()
+	defer scriptsMutex.Unlock()
+
+	for _, s := range scripts {
+		fn := s.function(name)
+		if fn == nil {
+			continue
+		}
+
+		results, err := s.call(fn, 1, args...)
+		if err != nil {
+			return nil, err
+		}
+		return goValue(results[0])
+	}
+
+	return nil, fmt.Errorf("unknown function '%s'", name)
+}
+
+// ValidateScripts passes the entry to the validate functions of the scripts.
+// A function returning a message, and optionally the field it is about,
+// rejects the entry.
+func ValidateScripts(entry Entry) error {
+	scripts, err := loadScripts()
+	if err != nil {
+		return err
+	}
+
+	scriptsMutex.Lock()
+	defer scriptsMutex.Unlock()
+
+	for _, s := range scripts {
+		fn := s.function("validate")
+		if fn == nil {
+			continue
+		}
+
+		results, err := s.call(fn, 2, NewListFormatEntry(entry))
+		if err != nil {
+			return err
+		}
+		if results[0] == lua.LNil || results[0] == lua.LFalse {
+			continue
+		}
+		return NewScriptViolationError(s.name, lua.LVAsString(results[1]), results[0].String())
+	}
+
+	return nil
+}
+
+// luaValue converts values of report columns and list formats to Lua.
+// Entries become tables with the times as RFC 3339 and the duration in
+// seconds, finish is nil while running.
+func luaValue(state *lua.LState, value interface{}) lua.LValue {
+	switch value := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(value)
+	case float64:
+		return lua.LNumber(value)
+	case int:
+		return lua.LNumber(value)
+	case string:
+		return lua.LString(value)
+	case time.Time:
+		return lua.LString(value.Format(time.RFC3339))
+	case []string:
+		table := state.NewTable()
+		for _, item := range value {
+			table.Append(lua.LString(item))
+		}
+		return table
+	case ListFormatEntry:
+		table := state.NewTable()
+		table.RawSetString("id", lua.LString(value.ID))
+		table.RawSetString("date", lua.LString(value.Date))
+		table.RawSetString("begin", luaValue(state, value.BeginTime))
+		if !value.Running {
+			table.RawSetString("finish", luaValue(state, value.FinishTime))
+		}
+		table.RawSetString("seconds", lua.LNumber(value.FinishTime.Sub(value.BeginTime).Seconds()))
+		table.RawSetString("project", lua.LString(value.Project))
+		table.RawSetString("task", lua.LString(value.Task))
+		table.RawSetString("notes", lua.LString(value.Notes))
+		table.RawSetString("user", lua.LString(value.User))
+		table.RawSetString("attendees", luaValue(state, value.Attendees))
+		table.RawSetString("tags", luaValue(state, value.Tags))
+		table.RawSetString("references", luaValue(state, value.References))
+		table.RawSetString("running", lua.LBool(value.Running))
+		return table
+	}
+	return lua.LString(fmt.Sprint(value))
+}
+
+// goValue converts the result of a script function to the values of the
+// expression language.
+func goValue(value lua.LValue) (interface{}, error) {
+	switch value := value.(type) {
+	case *lua.LNilType:
+		return "", nil
+	case lua.LBool:
+		return bool(value), nil
+	case lua.LNumber:
+		return float64(value), nil
+	case lua.LString:
+		return string(value), nil
+	}
+	return nil, fmt.Errorf("cannot use %s returned by script", value.Type())
+}
@@ -0,0 +1,124 @@
+package z
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+func SearchFields() []string {
+	return []string{
+		SearchFieldNotes,
+		SearchFieldTask,
+		SearchFieldProject,
+	}
+}
+
+type SearchMatch struct {
+	ID      string    `json:"id"`
+	Begin   time.Time `json:"begin"`
+	Finish  time.Time `json:"finish,omitempty"`
+	Project string    `json:"project"`
+	Task    string    `json:"task"`
+	Notes   string    `json:"notes"`
+	Fields  []string  `json:"fields"`
+}
+
+// NewSearchPattern compiles the query case-insensitively, as a regular
+// expression if regex is set and as plain text otherwise.
+func NewSearchPattern(query string, regex bool) (*regexp.Regexp, error) {
+	if !regex {
+		query = regexp.QuoteMeta(query)
+	}
+
+	pattern, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s'", query)
+	}
+	return pattern, nil
+}
+
+// SearchEntries returns the entries with any of the fields matching the
+// pattern, newest first.
+func SearchEntries(entries []Entry, pattern *regexp.Regexp, fields []string) ([]SearchMatch, error) {
+	matches := []SearchMatch{}
+
+	for _, field := range fields {
+		if !zeit.ContainsFold(SearchFields(), field) {
+			return matches, fmt.Errorf("unknown field '%s', possible values: %s", field, strings.Join(SearchFields(), ", "))
+		}
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		values := map[string]string{
+			SearchFieldNotes:   entry.Notes,
+			SearchFieldTask:    entry.Task,
+			SearchFieldProject: entry.Project,
+		}
+
+		var matched []string
+		for _, field := range fields {
+			field = strings.ToLower(field)
+			if pattern.MatchString(values[field]) {
+				matched = append(matched, field)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		matches = append(matches, SearchMatch{
+			ID:      entry.ID,
+			Begin:   entry.Begin,
+			Finish:  entry.Finish,
+			Project: entry.Project,
+			Task:    entry.Task,
+			Notes:   entry.Notes,
+			Fields:  matched,
+		})
+	}
+
+	return matches, nil
+}
+
+// highlightMatches renders every match of the pattern in text highlighted.
+func highlightMatches(text string, pattern *regexp.Regexp) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		return color.FgBlack.Render(color.BgLightYellow.Render(match))
+	})
+}
+
+// GetOutput returns the match as one line starting with the entry ID,
+// followed by the lines of the notes that matched.
+func (match *SearchMatch) GetOutput(pattern *regexp.Regexp) string {
+	var output strings.Builder
+
+	render := func(field string, value string) string {
+		if zeit.ContainsFold(match.Fields, field) {
+			value = highlightMatches(value, pattern)
+		}
+		return color.FgLightWhite.Render(value)
+	}
+
+	fmt.Fprintf(&output, "%s %s on %s %s\n",
+		color.FgGray.Render(match.ID),
+		render(SearchFieldTask, match.Task),
+		render(SearchFieldProject, match.Project),
+		match.Begin.Format(GetTimeDisplayFormat()),
+	)
+
+	if zeit.ContainsFold(match.Fields, SearchFieldNotes) {
+		for _, line := range strings.Split(match.Notes, "\n") {
+			if pattern.MatchString(line) {
+				fmt.Fprintf(&output, "   %s\n", highlightMatches(strings.TrimSpace(line), pattern))
+			}
+		}
+	}
+
+	return output.String()
+}
@@ -0,0 +1,69 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchRegex  bool
+	searchIDs    bool
+	searchFields []string
+)
+
+var searchCmd = &cobra.Command{
+	Use:         "search <query>",
+	Short:       "Search activities",
+	Long:        "Search the notes, tasks and projects of all activities, or the ones within --since, --until or --range, for a text or with --regex for a regular expression, ignoring case. Every match begins with the activity's ID; with --ids only the IDs are printed, e.g. for piping into edit or erase.",
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		pattern, err := NewSearchPattern(args[0], searchRegex)
+		if err != nil {
+			return err
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		matches, err := SearchEntries(entries, pattern, searchFields)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(matches)
+		}
+
+		for _, match := range matches {
+			if searchIDs {
+				fmt.Println(match.ID)
+				continue
+			}
+			fmt.Print(match.GetOutput(pattern))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolVar(&searchRegex, "regex", false, "Treat the query as a regular expression")
+	searchCmd.Flags().BoolVar(&searchIDs, "ids", false, "Only print the IDs of the matching activities")
+	searchCmd.Flags().StringSliceVar(&searchFields, "field", SearchFields(), "Fields to search (comma separated), possible values: "+strings.Join(SearchFields(), ", "))
+	searchCmd.Flags().StringVar(&since, "since", "", "Date/time to search from")
+	searchCmd.Flags().StringVar(&until, "until", "", "Date/time to search until")
+	searchCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+}
@@ -0,0 +1,73 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	serveListen   string
+	serveUI       bool
+	serveInsecure bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Serve the database as a JSON API",
+	Long:        "Run a local HTTP server exposing entries, projects, tasks, status and statistics as JSON endpoints, including starting and stopping activities. The database is only locked while a request is handled, so zeit can still be used alongside the server. Unless server.token is set or API tokens exist, an API token is created and shown on start, which requests have to authenticate with.",
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if viper.GetString("remote.url") != "" {
+			return errors.New("zeit serve can't be used with remote.url configured")
+		}
+
+		// Requests must never wait for input on the terminal the server runs in
+		viper.Set(FlagOutput, OutputJSON)
+
+		server := NewServer(user, viper.GetString("server.token"))
+		server.UI = serveUI
+		server.Insecure = serveInsecure
+		if err := printCreatedAPIToken(server, "serve"); err != nil {
+			return err
+		}
+		fmt.Printf("%s serving zeit on %s\n", CharInfo, color.FgLightWhite.Render("http://"+serveListen))
+		return http.ListenAndServe(serveListen, server.Handler())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:7350", "Address to listen on")
+	serveCmd.Flags().BoolVar(&serveUI, "ui", false, "Serve the web UI on /")
+	serveCmd.Flags().BoolVar(&serveInsecure, "insecure", false, "Accept requests without a token unless server.token is set or API tokens exist, instead of creating an API token")
+	serveCmd.Flags().String("token", "", "API token clients have to authenticate with (default is server.token from the config)")
+	viper.BindPFlag("server.token", serveCmd.Flags().Lookup("token"))
+}
+
+// printCreatedAPIToken creates an API token for a secure server without
+// server.token if no API tokens exist yet and prints it.
+func printCreatedAPIToken(server *Server, name string) error {
+	if server.Insecure || server.Token != "" {
+		return nil
+	}
+
+	token, err := EnsureAPIToken(name, server.User)
+	if err != nil || token == "" {
+		return err
+	}
+
+	fmt.Printf("%s created API token %s for user %s, clients have to authenticate with it, it will not be shown again:\n",
+		CharInfo,
+		color.FgLightWhite.Render(name),
+		color.FgLightWhite.Render(server.User),
+	)
+	fmt.Printf("%s\n", token)
+	return nil
+}
@@ -0,0 +1,693 @@
+package z
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+type EntryResource struct {
+	ID string `json:"id"`
+	Entry
+}
+
+type StatsTask struct {
+	Task         string `json:"task"`
+	TotalSeconds int64  `json:"totalSeconds"`
+}
+
+type StatsProject struct {
+	Project      string      `json:"project"`
+	TotalSeconds int64       `json:"totalSeconds"`
+	Tasks        []StatsTask `json:"tasks"`
+}
+
+type Stats struct {
+	Since        *time.Time     `json:"since"`
+	Until        *time.Time     `json:"until"`
+	TotalSeconds int64          `json:"totalSeconds"`
+	Projects     []StatsProject `json:"projects"`
+}
+
+type apiError struct {
+	Status int
+	Err    error
+}
+
+func (aerr *apiError) Error() string {
+	return aerr.Err.Error()
+}
+
+func newAPIError(status int, format string, a ...interface{}) error {
+	return &apiError{Status: status, Err: fmt.Errorf(format, a...)}
+}
+
+var errUnauthorized = errors.New("missing or invalid API token")
+
+// Server exposes the database of a user as a JSON API. Local databases are
+// opened and locked for every request only, so that the command line can be
+// used while the server is running. Requests have to authenticate using the
+// token or one of the API tokens as bearer token; only an Insecure server
+// without any tokens accepts every request.
+type Server struct {
+	User     string
+	Token    string
+	UI       bool
+	Insecure bool
+
+	mutex sync.Mutex
+}
+
+func NewServer(user string, token string) *Server {
+	return &Server{User: user, Token: token}
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/status", server.handle(false, server.getStatus))
+	mux.HandleFunc("GET /api/stats", server.handle(false, server.getStats))
+	mux.HandleFunc("POST /api/start", server.handle(true, server.start))
+	mux.HandleFunc("POST /api/stop", server.handle(true, server.stop))
+	mux.HandleFunc("POST /api/heartbeat", server.handle(false, server.heartbeat))
+
+	mux.HandleFunc("GET /api/entries", server.handle(false, server.listEntries))
+	mux.HandleFunc("POST /api/entries", server.handle(true, server.createEntry))
+	mux.HandleFunc("GET /api/entries/{id}", server.handle(false, server.getEntry))
+	mux.HandleFunc("PUT /api/entries/{id}", server.handle(true, server.updateEntry))
+	mux.HandleFunc("DELETE /api/entries/{id}", server.handle(true, server.eraseEntry))
+
+	mux.HandleFunc("GET /api/projects", server.handle(false, server.listProjects))
+	mux.HandleFunc("GET /api/projects/{name}", server.handle(false, server.getProject))
+	mux.HandleFunc("PUT /api/projects/{name}", server.handle(true, server.updateProject))
+	mux.HandleFunc("DELETE /api/projects/{name}", server.handle(true, server.eraseProject))
+
+	mux.HandleFunc("GET /api/tasks", server.handle(false, server.listTasks))
+	mux.HandleFunc("GET /api/tasks/{name}", server.handle(false, server.getTask))
+	mux.HandleFunc("PUT /api/tasks/{name}", server.handle(true, server.updateTask))
+	mux.HandleFunc("DELETE /api/tasks/{name}", server.handle(true, server.eraseTask))
+
+	mux.HandleFunc("GET /api/graphql", server.handle(false, server.graphql))
+	mux.HandleFunc("POST /api/graphql", server.handle(false, server.graphql))
+
+	mux.HandleFunc("GET /metrics", server.serveMetrics)
+
+	server.handleStorage(mux)
+	if server.UI {
+		server.handleUI(mux)
+	}
+
+	return mux
+}
+
+func (server *Server) handle(exclusive bool, fn func(user string, r *http.Request) (int, interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, v := server.call(exclusive, func() (int, interface{}, error) {
+			if err := checkOrigin(r); err != nil {
+				return 0, nil, err
+			}
+			if err := checkContentType(r); err != nil {
+				return 0, nil, err
+			}
+			user, authorized, err := server.authenticate(r)
+			if err != nil {
+				return 0, nil, err
+			}
+			if !authorized {
+				return 0, nil, errUnauthorized
+			}
+			return fn(user, r)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		w.WriteHeader(status)
+		if v != nil {
+			json.NewEncoder(w).Encode(v)
+		}
+	}
+}
+
+// call runs fn with the storage opened and returns the status and response,
+// which is an ErrorOutput if fn failed.
+func (server *Server) call(exclusive bool, fn func() (int, interface{}, error)) (int, interface{}) {
+	// Post hooks run once other requests may use the database again, as
+	// they may call the server or zeit themselves
+	holdPostHooks()
+	defer releasePostHooks()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var status int
+	var v interface{}
+
+	closeStorage, err := OpenStorage(exclusive, true)
+	if err == nil {
+		status, v, err = fn()
+		closeStorage()
+	}
+
+	if err == nil {
+		return status, v
+	}
+
+	status = http.StatusInternalServerError
+	errorObject := NewErrorObject(err)
+	var aerr *apiError
+	var verr *ValidationError
+	switch {
+	case errors.Is(err, errUnauthorized):
+		status = http.StatusUnauthorized
+		errorObject.Code = "unauthorized"
+	case errors.As(err, &aerr):
+		status = aerr.Status
+		errorObject = NewErrorObject(aerr.Err)
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.As(err, &verr) && verr.Code == ValidationOverlap:
+		status = http.StatusConflict
+	case errors.As(err, &verr):
+		status = http.StatusUnprocessableEntity
+	}
+
+	return status, ErrorOutput{Error: errorObject}
+}
+
+// authenticate returns the user a request acts as. The server's own token
+// acts as the user running the server, API tokens created using `zeit token`
+// as the user they were created for. Without any token configured, requests
+// to an insecure server act as the user running the server.
+func (server *Server) authenticate(r *http.Request) (string, bool, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if found && server.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(server.Token)) == 1 {
+		return server.User, true, nil
+	}
+
+	tokens, err := GetAPITokens()
+	if err != nil {
+		return "", false, err
+	}
+	if server.Token == "" && len(tokens) == 0 {
+		return server.User, server.Insecure, nil
+	}
+	if !found {
+		return "", false, nil
+	}
+
+	apiToken, ok, err := LookupAPIToken(token)
+	return apiToken.User, ok, err
+}
+
+// checkOrigin rejects requests for hosts other than IP addresses, localhost
+// and server.hosts, which DNS rebinding would let pages in the browser read,
+// and requests from pages of other origins.
+func checkOrigin(r *http.Request) error {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.Trim(host, "[]")
+
+	allowed := host == "localhost" || net.ParseIP(host) != nil
+	for _, name := range viper.GetStringSlice("server.hosts") {
+		allowed = allowed || strings.EqualFold(host, name)
+	}
+	if !allowed {
+		return newAPIError(http.StatusForbidden, "host %s is not allowed, add it to server.hosts", host)
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		originURL, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(originURL.Host, r.Host) {
+			return newAPIError(http.StatusForbidden, "requests from %s are not allowed", origin)
+		}
+	}
+
+	return nil
+}
+
+// checkContentType requires requests changing data to be sent as JSON, which
+// pages of other origins can't do without a preflight request, which the
+// server never allows.
+func checkContentType(r *http.Request) error {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return newAPIError(http.StatusUnsupportedMediaType, "Content-Type has to be application/json")
+	}
+
+	return nil
+}
+
+// EnsureAPIToken creates an API token named after the command for the user
+// unless one exists already, so that a server without server.token isn't
+// open to every page in the browser. It returns the token if one was
+// created.
+func EnsureAPIToken(name string, user string) (string, error) {
+	closeStorage, err := OpenStorage(true, true)
+	if err != nil {
+		return "", &StorageError{Err: err}
+	}
+	defer closeStorage()
+
+	tokens, err := GetAPITokens()
+	if err != nil || len(tokens) > 0 {
+		return "", err
+	}
+
+	return CreateAPIToken(name, user)
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+
+	return nil
+}
+
+func entryResources(entries []Entry) []EntryResource {
+	resources := []EntryResource{}
+	for _, entry := range entries {
+		resources = append(resources, EntryResource{ID: entry.ID, Entry: entry})
+	}
+	return resources
+}
+
+func normalizeEntry(entry *Entry) {
+	entry.Attendees = zeit.ParseAttendees(entry.Attendees)
+	entry.Tags = zeit.ParseTags(entry.Tags)
+	entry.References = zeit.ParseReferences(entry.References)
+}
+
+func parseQueryRange(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	sinceTime, untilTime, err := ParseTimeRange(query.Get("since"), query.Get("until"), query.Get("range"))
+	if err != nil {
+		return sinceTime, untilTime, newAPIError(http.StatusBadRequest, "%v", err)
+	}
+
+	return sinceTime, untilTime, nil
+}
+
+func (server *Server) getStatus(user string, r *http.Request) (int, interface{}, error) {
+	status, err := GetStatus(user)
+	return http.StatusOK, status, err
+}
+
+func (server *Server) listEntries(user string, r *http.Request) (int, interface{}, error) {
+	sinceTime, untilTime, err := parseQueryRange(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := r.URL.Query()
+	if entries, err = GetFilteredEntries(entries, query.Get("project"), query.Get("task"), sinceTime, untilTime); err != nil {
+		return 0, nil, err
+	}
+	if query.Has("tag") {
+		entries = filterEntriesByTags(entries, zeit.ParseTags(strings.Split(query.Get("tag"), ",")))
+	}
+	if query.Has("ref") {
+		entries = filterEntriesByReferences(entries, zeit.ParseReferences(strings.Split(query.Get("ref"), ",")))
+	}
+
+	return http.StatusOK, entryResources(entries), nil
+}
+
+func (server *Server) getEntry(user string, r *http.Request) (int, interface{}, error) {
+	entry, err := database.GetEntry(user, r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, EntryResource{ID: entry.ID, Entry: entry}, nil
+}
+
+func (server *Server) storeEntry(user string, id string, entry Entry, store func(Entry) (string, error)) error {
+	if !entry.IsFinishedAfterBegan() {
+		return NewFinishBeforeBeginError(entry)
+	}
+
+	if err := ValidateProjectRules(user, entry); err != nil {
+		return err
+	}
+
+	resolution, err := ResolveOverlaps(user, id, entry)
+	if err != nil {
+		return err
+	}
+
+	return StoreResolution(user, resolution, store)
+}
+
+func (server *Server) createEntry(user string, r *http.Request) (int, interface{}, error) {
+	var entry Entry
+	if err := decodeBody(r, &entry); err != nil {
+		return 0, nil, err
+	}
+
+	if entry.Begin.IsZero() || entry.Finish.IsZero() {
+		return 0, nil, newAPIError(http.StatusBadRequest, "begin and finish are required, use /api/start to begin tracking")
+	}
+	entry.User = user
+	normalizeEntry(&entry)
+
+	var id string
+	err := server.storeEntry(user, "", entry, func(entry Entry) (string, error) {
+		if err := runServerPreHooks(HookPreTrack, entry); err != nil {
+			return "", err
+		}
+		var err error
+		id, err = database.AddEntry(user, entry, false)
+		return id, err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if entry, err = database.GetEntry(user, id); err != nil {
+		return 0, nil, err
+	}
+	RunPostHooks(HookPostTrack, entry)
+	return http.StatusCreated, EntryResource{ID: entry.ID, Entry: entry}, nil
+}
+
+func (server *Server) updateEntry(user string, r *http.Request) (int, interface{}, error) {
+	original, err := database.GetEntry(user, r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = ValidateEditWindow(original); err != nil {
+		return 0, nil, err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entry := original
+	if err = decodeBody(r, &entry); err != nil {
+		return 0, nil, err
+	}
+	entry.ID = original.ID
+	entry.User = original.User
+	normalizeEntry(&entry)
+
+	if original.ID != runningEntryId && entry.Finish.IsZero() {
+		return 0, nil, newAPIError(http.StatusBadRequest, "finish is required, use /api/start to begin tracking")
+	}
+
+	err = server.storeEntry(user, entry.ID, entry, func(entry Entry) (string, error) {
+		if entry.ID == runningEntryId && !entry.Finish.IsZero() {
+			return database.FinishEntry(user, entry)
+		}
+		return database.UpdateEntry(user, entry)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if entry, err = database.GetEntry(user, entry.ID); err != nil {
+		return 0, nil, err
+	}
+	RunPostHooks(HookPostEdit, entry)
+	return http.StatusOK, EntryResource{ID: entry.ID, Entry: entry}, nil
+}
+
+func (server *Server) eraseEntry(user string, r *http.Request) (int, interface{}, error) {
+	id := r.PathValue("id")
+	entry, err := database.GetEntry(user, id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = ValidateEditWindow(entry); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.EraseEntry(user, id)
+}
+
+func (server *Server) start(user string, r *http.Request) (int, interface{}, error) {
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return 0, nil, err
+	}
+	if runningEntryId != "" {
+		return 0, nil, newAPIError(http.StatusConflict, "a task is already running")
+	}
+
+	var entry Entry
+	if err = decodeBody(r, &entry); err != nil {
+		return 0, nil, err
+	}
+
+	if entry.Begin.IsZero() {
+		entry.Begin = time.Now()
+	}
+	entry.Finish = time.Time{}
+	entry.User = user
+	if entry.Project == "" {
+		entry.Project = viper.GetString("project.default")
+	}
+	normalizeEntry(&entry)
+
+	if err = ValidateProjectRules(user, entry); err != nil {
+		return 0, nil, err
+	}
+	if err = runServerPreHooks(HookPreTrack, entry); err != nil {
+		return 0, nil, err
+	}
+
+	if entry.ID, err = database.AddEntry(user, entry, true); err != nil {
+		return 0, nil, err
+	}
+
+	RunPostHooks(HookPostTrack, entry)
+	return http.StatusCreated, EntryResource{ID: entry.ID, Entry: entry}, nil
+}
+
+func (server *Server) stop(user string, r *http.Request) (int, interface{}, error) {
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return 0, nil, err
+	}
+	if runningEntryId == "" {
+		return 0, nil, newAPIError(http.StatusConflict, "not running")
+	}
+
+	entry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var body struct {
+		Finish time.Time `json:"finish"`
+		Notes  string    `json:"notes"`
+	}
+	if err = decodeBody(r, &body); err != nil {
+		return 0, nil, err
+	}
+
+	entry.Finish = body.Finish
+	if entry.Finish.IsZero() {
+		entry.Finish = time.Now()
+	}
+	if body.Notes != "" {
+		entry.Notes = strings.TrimPrefix(entry.Notes+"\n"+body.Notes, "\n")
+	}
+
+	if !entry.IsFinishedAfterBegan() {
+		return 0, nil, NewFinishBeforeBeginError(entry)
+	}
+	if err = ValidateProjectRules(user, entry); err != nil {
+		return 0, nil, err
+	}
+	if err = runServerPreHooks(HookPreFinish, entry); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err = database.FinishEntry(user, entry); err != nil {
+		return 0, nil, err
+	}
+
+	RunPostHooks(HookPostFinish, entry)
+	return http.StatusOK, EntryResource{ID: entry.ID, Entry: entry}, nil
+}
+
+// runServerPreHooks runs the hooks before an event like the command line
+// does, a failing hook rejects the request.
+func runServerPreHooks(event string, entry Entry) error {
+	if err := RunPreHooks(event, entry); err != nil {
+		return newAPIError(http.StatusUnprocessableEntity, "%v", err)
+	}
+	return nil
+}
+
+func (server *Server) getStats(user string, r *http.Request) (int, interface{}, error) {
+	sinceTime, untilTime, err := parseQueryRange(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	stats := Stats{Projects: []StatsProject{}}
+	if !sinceTime.IsZero() {
+		stats.Since = &sinceTime
+	}
+	if !untilTime.IsZero() {
+		stats.Until = &untilTime
+	}
+
+	projects := make(map[string]map[string]time.Duration)
+	for _, entry := range entries {
+		to := untilTime
+		if to.IsZero() {
+			to = entry.End()
+		}
+		duration := zeit.ClippedDuration(entry, sinceTime, to)
+
+		if _, ok := projects[entry.Project]; !ok {
+			projects[entry.Project] = make(map[string]time.Duration)
+		}
+		projects[entry.Project][entry.Task] += duration
+		stats.TotalSeconds += int64(duration.Seconds())
+	}
+
+	for projectName, tasks := range projects {
+		statsProject := StatsProject{Project: projectName, Tasks: []StatsTask{}}
+		for taskName, duration := range tasks {
+			statsProject.Tasks = append(statsProject.Tasks, StatsTask{Task: taskName, TotalSeconds: int64(duration.Seconds())})
+			statsProject.TotalSeconds += int64(duration.Seconds())
+		}
+		sort.Slice(statsProject.Tasks, func(i, j int) bool { return statsProject.Tasks[i].Task < statsProject.Tasks[j].Task })
+		stats.Projects = append(stats.Projects, statsProject)
+	}
+	sort.Slice(stats.Projects, func(i, j int) bool { return stats.Projects[i].Project < stats.Projects[j].Project })
+
+	return http.StatusOK, stats, nil
+}
+
+func (server *Server) listProjects(user string, r *http.Request) (int, interface{}, error) {
+	projects, err := database.ListProjects(user)
+	if projects == nil {
+		projects = []Project{}
+	}
+	return http.StatusOK, projects, err
+}
+
+func (server *Server) getProject(user string, r *http.Request) (int, interface{}, error) {
+	project, err := database.GetProject(user, r.PathValue("name"))
+	if err == nil && project.Name == "" {
+		err = ErrNotFound
+	}
+	return http.StatusOK, project, err
+}
+
+func (server *Server) updateProject(user string, r *http.Request) (int, interface{}, error) {
+	name := r.PathValue("name")
+
+	project, err := database.GetProject(user, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = decodeBody(r, &project); err != nil {
+		return 0, nil, err
+	}
+	project.Name = name
+
+	return http.StatusOK, project, database.UpdateProject(user, name, project)
+}
+
+func (server *Server) eraseProject(user string, r *http.Request) (int, interface{}, error) {
+	name := r.PathValue("name")
+
+	project, err := database.GetProject(user, name)
+	if err == nil && project.Name == "" {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.EraseProject(user, name)
+}
+
+func (server *Server) listTasks(user string, r *http.Request) (int, interface{}, error) {
+	tasks, err := database.ListTasks(user)
+	if tasks == nil {
+		tasks = []Task{}
+	}
+	return http.StatusOK, tasks, err
+}
+
+func (server *Server) getTask(user string, r *http.Request) (int, interface{}, error) {
+	task, err := database.GetTask(user, r.PathValue("name"))
+	if err == nil && task.Name == "" {
+		err = ErrNotFound
+	}
+	return http.StatusOK, task, err
+}
+
+func (server *Server) updateTask(user string, r *http.Request) (int, interface{}, error) {
+	name := r.PathValue("name")
+
+	task, err := database.GetTask(user, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = decodeBody(r, &task); err != nil {
+		return 0, nil, err
+	}
+	task.Name = name
+
+	return http.StatusOK, task, database.UpdateTask(user, name, task)
+}
+
+func (server *Server) eraseTask(user string, r *http.Request) (int, interface{}, error) {
+	name := r.PathValue("name")
+
+	task, err := database.GetTask(user, name)
+	if err == nil && task.Name == "" {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.EraseTask(user, name)
+}
@@ -0,0 +1,319 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// gqlField is a field of the schema, with its type and the types of its
+// arguments in GraphQL notation like [String] or ID!. Fields of the query type
+// are resolved by the handler of the corresponding REST endpoint, with the
+// arguments passed as query and pathArg as path value. Fields of other types
+// are read from the JSON of the handler's response, unless they are computed.
+type gqlField struct {
+	Type    string
+	Args    map[string]string
+	pathArg string
+	resolve func(user string, r *http.Request) (int, interface{}, error)
+	compute func(object map[string]interface{}) interface{}
+}
+
+var rangeArgs = map[string]string{
+	"since": "String",
+	"until": "String",
+	"range": "String",
+}
+
+func (server *Server) graphqlSchema() map[string]map[string]gqlField {
+	entryArgs := map[string]string{
+		"project": "String",
+		"task":    "String",
+		"tag":     "[String]",
+		"ref":     "[String]",
+	}
+	for arg, typ := range rangeArgs {
+		entryArgs[arg] = typ
+	}
+
+	return map[string]map[string]gqlField{
+		"Query": {
+			"status":   {Type: "Status", resolve: server.getStatus},
+			"stats":    {Type: "Stats", Args: rangeArgs, resolve: server.getStats},
+			"entries":  {Type: "[Entry]", Args: entryArgs, resolve: server.listEntries},
+			"entry":    {Type: "Entry", Args: map[string]string{"id": "ID!"}, pathArg: "id", resolve: server.getEntry},
+			"projects": {Type: "[Project]", resolve: server.listProjects},
+			"project":  {Type: "Project", Args: map[string]string{"name": "String!"}, pathArg: "name", resolve: server.getProject},
+			"tasks":    {Type: "[Task]", resolve: server.listTasks},
+			"task":     {Type: "Task", Args: map[string]string{"name": "String!"}, pathArg: "name", resolve: server.getTask},
+		},
+		"Status": {
+			"schema":    {Type: "Int"},
+			"timestamp": {Type: "String"},
+			"running":   {Type: "Boolean"},
+			"entry":     {Type: "StatusEntry"},
+			"today":     {Type: "StatusToday"},
+		},
+		"StatusEntry": {
+			"id":             {Type: "ID"},
+			"begin":          {Type: "String"},
+			"elapsedSeconds": {Type: "Int"},
+			"project":        {Type: "String"},
+			"task":           {Type: "String"},
+			"tags":           {Type: "[String]"},
+		},
+		"StatusToday": {
+			"totalSeconds": {Type: "Int"},
+			"entries":      {Type: "Int"},
+		},
+		"Stats": {
+			"since":        {Type: "String"},
+			"until":        {Type: "String"},
+			"totalSeconds": {Type: "Int"},
+			"projects":     {Type: "[StatsProject]"},
+		},
+		"StatsProject": {
+			"project":      {Type: "String"},
+			"totalSeconds": {Type: "Int"},
+			"tasks":        {Type: "[StatsTask]"},
+		},
+		"StatsTask": {
+			"task":         {Type: "String"},
+			"totalSeconds": {Type: "Int"},
+		},
+		"Entry": {
+			"id":              {Type: "ID"},
+			"begin":           {Type: "String"},
+			"finish":          {Type: "String", compute: graphqlEntryFinish},
+			"project":         {Type: "String"},
+			"task":            {Type: "String"},
+			"notes":           {Type: "String"},
+			"user":            {Type: "String"},
+			"attendees":       {Type: "[String]"},
+			"tags":            {Type: "[String]"},
+			"references":      {Type: "[String]"},
+			"running":         {Type: "Boolean", compute: graphqlEntryRunning},
+			"durationSeconds": {Type: "Int", compute: graphqlEntryDuration},
+		},
+		"Project": {
+			"name":     {Type: "String"},
+			"color":    {Type: "String"},
+			"billable": {Type: "Boolean"},
+			"rate":     {Type: "String"},
+			"currency": {Type: "String"},
+			"tasks":    {Type: "[String]"},
+			"rules":    {Type: "ProjectRules"},
+		},
+		"ProjectRules": {
+			"notesRequired":  {Type: "Boolean"},
+			"taskRequired":   {Type: "Boolean"},
+			"knownTasksOnly": {Type: "Boolean"},
+		},
+		"Task": {
+			"name":          {Type: "String"},
+			"gitRepository": {Type: "String"},
+		},
+	}
+}
+
+func graphqlEntryFinish(object map[string]interface{}) interface{} {
+	if graphqlEntryRunning(object).(bool) {
+		return nil
+	}
+	return object["finish"]
+}
+
+func graphqlEntryRunning(object map[string]interface{}) interface{} {
+	finish, _ := object["finish"].(string)
+	t, err := time.Parse(time.RFC3339Nano, finish)
+	return err != nil || t.IsZero()
+}
+
+func graphqlEntryDuration(object map[string]interface{}) interface{} {
+	begin, _ := object["begin"].(string)
+	beginTime, err := time.Parse(time.RFC3339Nano, begin)
+	if err != nil {
+		return nil
+	}
+
+	finishTime := time.Now()
+	if !graphqlEntryRunning(object).(bool) {
+		finishTime, _ = time.Parse(time.RFC3339Nano, object["finish"].(string))
+	}
+	return int64(finishTime.Sub(beginTime).Seconds())
+}
+
+func (server *Server) graphql(user string, r *http.Request) (int, interface{}, error) {
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return 0, nil, newAPIError(http.StatusBadRequest, "invalid variables: %v", err)
+			}
+		}
+	} else if err := decodeBody(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	schema, err := newGraphQLSchema(user, server.graphqlSchema())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	}), nil
+}
+
+// newGraphQLSchema builds the schema of the user's requests from the types
+// of graphqlSchema. There are no mutations, data is modified through the
+// REST endpoints.
+func newGraphQLSchema(user string, types map[string]map[string]gqlField) (graphql.Schema, error) {
+	objects := make(map[string]*graphql.Object)
+
+	var typeOf func(typ string) graphql.Type
+	typeOf = func(typ string) graphql.Type {
+		switch {
+		case strings.HasSuffix(typ, "!"):
+			return graphql.NewNonNull(typeOf(strings.TrimSuffix(typ, "!")))
+		case strings.HasPrefix(typ, "["):
+			return graphql.NewList(typeOf(strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]")))
+		}
+
+		switch typ {
+		case "String":
+			return graphql.String
+		case "Int":
+			return graphql.Int
+		case "Float":
+			return graphql.Float
+		case "Boolean":
+			return graphql.Boolean
+		case "ID":
+			return graphql.ID
+		}
+
+		if object, ok := objects[typ]; ok {
+			return object
+		}
+		objects[typ] = graphql.NewObject(graphql.ObjectConfig{
+			Name: typ,
+			Fields: graphql.FieldsThunk(func() graphql.Fields {
+				fields := graphql.Fields{}
+				for name, field := range types[typ] {
+					args := graphql.FieldConfigArgument{}
+					for arg, argType := range field.Args {
+						args[arg] = &graphql.ArgumentConfig{Type: typeOf(argType)}
+					}
+					fields[name] = &graphql.Field{
+						Type:    typeOf(field.Type),
+						Args:    args,
+						Resolve: graphqlResolver(user, name, field),
+					}
+				}
+				return fields
+			}),
+		})
+		return objects[typ]
+	}
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: typeOf("Query").(*graphql.Object)})
+}
+
+func graphqlResolver(user string, name string, field gqlField) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if field.resolve != nil {
+			return resolveGraphQLQuery(user, field, p.Args)
+		}
+
+		source, _ := p.Source.(map[string]interface{})
+		if field.compute != nil {
+			return field.compute(source), nil
+		}
+
+		// Fields omitted from the JSON of the storage's types are their zero
+		// values
+		value := source[name]
+		if value == nil {
+			switch strings.TrimSuffix(field.Type, "!") {
+			case "Boolean":
+				return false, nil
+			case "Int":
+				return 0, nil
+			case "Float":
+				return 0.0, nil
+			}
+		}
+		return value, nil
+	}
+}
+
+// resolveGraphQLQuery calls the handler of a field of the query type with the
+// arguments and returns its response as decoded from JSON.
+func resolveGraphQLQuery(user string, field gqlField, args map[string]interface{}) (interface{}, error) {
+	query := url.Values{}
+	var pathValue string
+
+	for name, arg := range args {
+		if arg == nil {
+			continue
+		}
+
+		var value string
+		if list, ok := arg.([]interface{}); ok {
+			var values []string
+			for _, item := range list {
+				values = append(values, fmt.Sprint(item))
+			}
+			value = strings.Join(values, ",")
+		} else {
+			value = fmt.Sprint(arg)
+		}
+
+		if name == field.pathArg {
+			pathValue = value
+		} else {
+			query.Set(name, value)
+		}
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if field.pathArg != "" {
+		r.SetPathValue(field.pathArg, pathValue)
+	}
+
+	_, v, err := field.resolve(user, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handlers return typed values, which are resolved from their JSON
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(content, &value)
+	return value, err
+}
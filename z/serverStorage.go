@@ -0,0 +1,188 @@
+package z
+
+import (
+	"net/http"
+	"time"
+)
+
+// The /api/storage endpoints expose the storage operations of the server's
+// database to remote clients (see Remote). Unlike the other endpoints they do
+// not validate anything, as the client does so just like it would locally.
+// Entries are always stored as the user the request authenticated as.
+func (server *Server) handleStorage(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/storage/entries", server.handle(false, server.storageListEntries))
+	mux.HandleFunc("POST /api/storage/entries", server.handle(true, server.storageAddEntry))
+	mux.HandleFunc("GET /api/storage/entries/{id}", server.handle(false, server.getEntry))
+	mux.HandleFunc("PUT /api/storage/entries/{id}", server.handle(true, server.storageUpdateEntry))
+	mux.HandleFunc("POST /api/storage/entries/{id}/finish", server.handle(true, server.storageFinishEntry))
+	mux.HandleFunc("DELETE /api/storage/entries/{id}", server.handle(true, server.storageEraseEntry))
+	mux.HandleFunc("GET /api/storage/running", server.handle(false, server.storageGetRunning))
+	mux.HandleFunc("PUT /api/storage/running", server.handle(true, server.storageSetRunning))
+	mux.HandleFunc("GET /api/storage/raw", server.handle(false, server.storageListRaw))
+	mux.HandleFunc("GET /api/storage/imports", server.handle(false, server.storageGetImports))
+	mux.HandleFunc("PUT /api/storage/imports", server.handle(true, server.storageUpdateImports))
+	mux.HandleFunc("GET /api/storage/meta/{key}", server.handle(false, server.storageGetMeta))
+	mux.HandleFunc("PUT /api/storage/meta/{key}", server.handle(true, server.storageSetMeta))
+
+	mux.HandleFunc("GET /api/storage/projects", server.handle(false, server.listProjects))
+	mux.HandleFunc("GET /api/storage/projects/{name}", server.handle(false, server.getProject))
+	mux.HandleFunc("PUT /api/storage/projects/{name}", server.handle(true, server.storageUpdateProject))
+	mux.HandleFunc("DELETE /api/storage/projects/{name}", server.handle(true, server.storageEraseProject))
+	mux.HandleFunc("GET /api/storage/tasks", server.handle(false, server.listTasks))
+	mux.HandleFunc("GET /api/storage/tasks/{name}", server.handle(false, server.getTask))
+	mux.HandleFunc("PUT /api/storage/tasks/{name}", server.handle(true, server.storageUpdateTask))
+	mux.HandleFunc("DELETE /api/storage/tasks/{name}", server.handle(true, server.storageEraseTask))
+}
+
+func parseQueryTime(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return t, newAPIError(http.StatusBadRequest, "invalid %s: %v", key, err)
+	}
+	return t, nil
+}
+
+func (server *Server) storageListEntries(user string, r *http.Request) (int, interface{}, error) {
+	if !r.URL.Query().Has("between") {
+		entries, err := database.ListEntries(user)
+		return http.StatusOK, entryResources(entries), err
+	}
+
+	from, err := parseQueryTime(r, "from")
+	if err != nil {
+		return 0, nil, err
+	}
+	to, err := parseQueryTime(r, "to")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries, err := database.ListEntriesBetween(user, from, to)
+	return http.StatusOK, entryResources(entries), err
+}
+
+func (server *Server) storageAddEntry(user string, r *http.Request) (int, interface{}, error) {
+	var body remoteEntry
+	if err := decodeBody(r, &body); err != nil {
+		return 0, nil, err
+	}
+
+	body.Entry.User = user
+	id, err := database.AddEntry(user, body.Entry, body.SetRunning)
+	return http.StatusCreated, remoteID{ID: id}, err
+}
+
+func (server *Server) decodeEntry(user string, r *http.Request) (Entry, error) {
+	var resource EntryResource
+	if err := decodeBody(r, &resource); err != nil {
+		return Entry{}, err
+	}
+
+	resource.Entry.ID = r.PathValue("id")
+	resource.Entry.User = user
+	return resource.Entry, nil
+}
+
+func (server *Server) storageUpdateEntry(user string, r *http.Request) (int, interface{}, error) {
+	entry, err := server.decodeEntry(user, r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_, err = database.UpdateEntry(user, entry)
+	return http.StatusNoContent, nil, err
+}
+
+func (server *Server) storageFinishEntry(user string, r *http.Request) (int, interface{}, error) {
+	entry, err := server.decodeEntry(user, r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if _, err = database.FinishEntry(user, entry); err != nil {
+		return 0, nil, newAPIError(http.StatusConflict, "%v", err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+func (server *Server) storageEraseEntry(user string, r *http.Request) (int, interface{}, error) {
+	return http.StatusNoContent, nil, database.EraseEntry(user, r.PathValue("id"))
+}
+
+func (server *Server) storageGetRunning(user string, r *http.Request) (int, interface{}, error) {
+	id, err := database.GetRunningEntryId(user)
+	return http.StatusOK, remoteID{ID: id}, err
+}
+
+func (server *Server) storageSetRunning(user string, r *http.Request) (int, interface{}, error) {
+	var running remoteID
+	if err := decodeBody(r, &running); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.SetRunningEntryId(user, running.ID)
+}
+
+func (server *Server) storageListRaw(user string, r *http.Request) (int, interface{}, error) {
+	rawEntries, err := database.ListRawEntries(user)
+	return http.StatusOK, rawEntries, err
+}
+
+func (server *Server) storageGetImports(user string, r *http.Request) (int, interface{}, error) {
+	sha1List, err := database.GetImportsSHA1List(user)
+	return http.StatusOK, sha1List, err
+}
+
+func (server *Server) storageUpdateImports(user string, r *http.Request) (int, interface{}, error) {
+	sha1List := make(map[string]string)
+	if err := decodeBody(r, &sha1List); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.UpdateImportsSHA1List(user, sha1List)
+}
+
+func (server *Server) storageGetMeta(user string, r *http.Request) (int, interface{}, error) {
+	value, err := database.GetMeta(user, r.PathValue("key"))
+	return http.StatusOK, value, err
+}
+
+func (server *Server) storageSetMeta(user string, r *http.Request) (int, interface{}, error) {
+	var value string
+	if err := decodeBody(r, &value); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.SetMeta(user, r.PathValue("key"), value)
+}
+
+func (server *Server) storageUpdateProject(user string, r *http.Request) (int, interface{}, error) {
+	var project Project
+	if err := decodeBody(r, &project); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.UpdateProject(user, r.PathValue("name"), project)
+}
+
+func (server *Server) storageEraseProject(user string, r *http.Request) (int, interface{}, error) {
+	return http.StatusNoContent, nil, database.EraseProject(user, r.PathValue("name"))
+}
+
+func (server *Server) storageUpdateTask(user string, r *http.Request) (int, interface{}, error) {
+	var task Task
+	if err := decodeBody(r, &task); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusNoContent, nil, database.UpdateTask(user, r.PathValue("name"), task)
+}
+
+func (server *Server) storageEraseTask(user string, r *http.Request) (int, interface{}, error) {
+	return http.StatusNoContent, nil, database.EraseTask(user, r.PathValue("name"))
+}
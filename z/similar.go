@@ -0,0 +1,152 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// editDistance returns the Levenshtein distance of a and b, ignoring case.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// FindSimilarNames returns the known names that are at most two edits away
+// from name, closest first, or nothing if name is known itself.
+func FindSimilarNames(name string, known []string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	for _, existing := range known {
+		if zeit.GetIdFromName(existing) == zeit.GetIdFromName(name) {
+			return nil
+		}
+
+		distance := editDistance(name, existing)
+		if distance > 0 && distance <= 2 && distance*2 < len([]rune(name)) {
+			candidates = append(candidates, candidate{existing, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var similar []string
+	for _, candidate := range candidates {
+		similar = append(similar, candidate.name)
+	}
+	return similar
+}
+
+// knownProjectsAndTasks returns all project and task names used in entries
+// or stored on their own.
+func knownProjectsAndTasks(user string) ([]string, []string, error) {
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	projects, projectTasks := GetRecentProjectsAndTasks(entries)
+
+	storedProjects, err := database.ListProjects(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, project := range storedProjects {
+		if project.Name != "" {
+			projects = append(projects, project.Name)
+		}
+	}
+
+	var tasks []string
+	for _, names := range projectTasks {
+		tasks = append(tasks, names...)
+	}
+	storedTasks, err := database.ListTasks(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, task := range storedTasks {
+		if task.Name != "" {
+			tasks = append(tasks, task.Name)
+		}
+	}
+
+	return projects, tasks, nil
+}
+
+// CheckSimilarNames guards against creating a project or task by a typo:
+// if a name does not exist yet but is close to an existing one, the user is
+// asked whether they meant the existing one, and without a terminal an error
+// is returned. With create set, new names are taken as they are.
+func CheckSimilarNames(user string, project *string, task *string, create bool) error {
+	if create || (*project == "" && *task == "") {
+		return nil
+	}
+
+	projects, tasks, err := knownProjectsAndTasks(user)
+	if err != nil {
+		return err
+	}
+
+	check := func(field string, name *string, known []string) error {
+		if *name == "" {
+			return nil
+		}
+
+		similar := FindSimilarNames(*name, known)
+		if len(similar) == 0 {
+			return nil
+		}
+
+		verr := NewSimilarNameError(field, *name, similar)
+		if !IsInteractive() {
+			return verr
+		}
+
+		fmt.Printf("%s %s\n", CharError, verr.Message)
+		fmt.Printf("%s [y]use '%s'  [c]create '%s'  [a]abort: ", CharMore, similar[0], *name)
+		key, err := readKey()
+		fmt.Printf("%c\n", key)
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(string(key)) {
+		case "y", "\r":
+			*name = similar[0]
+			return nil
+		case "c":
+			return nil
+		default:
+			return verr
+		}
+	}
+
+	if err := check("project", project, projects); err != nil {
+		return err
+	}
+	return check("task", task, tasks)
+}
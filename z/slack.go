@@ -0,0 +1,177 @@
+package z
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultSlackURL string = "https://slack.com/api"
+
+const defaultSlackStatusText string = "{{.Project}}{{if .Task}}: {{.Task}}{{end}}"
+
+const defaultSlackStatusEmoji string = ":stopwatch:"
+
+// slackStatusTextLimit is the longest status text Slack accepts.
+const slackStatusTextLimit int = 100
+
+// SlackStatus is the status set while tracking, its text being a Go template
+// executed with the running entry.
+type SlackStatus struct {
+	Text  string `mapstructure:"text"`
+	Emoji string `mapstructure:"emoji"`
+}
+
+type Slack struct {
+	URL      string
+	Token    string
+	Status   SlackStatus
+	Projects map[string]SlackStatus
+}
+
+type slackProfile struct {
+	StatusText       string `json:"status_text"`
+	StatusEmoji      string `json:"status_emoji"`
+	StatusExpiration int64  `json:"status_expiration"`
+}
+
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// NewSlack configures the Slack client and the statuses per project from
+// `slack.*`. Without a slack.token, Slack is not used and nil is returned.
+func NewSlack() (*Slack, error) {
+	slack := Slack{
+		URL:   strings.TrimSuffix(viper.GetString("slack.url"), "/"),
+		Token: os.ExpandEnv(viper.GetString("slack.token")),
+		Status: SlackStatus{
+			Text:  viper.GetString("slack.text"),
+			Emoji: viper.GetString("slack.emoji"),
+		},
+	}
+	if slack.Token == "" {
+		return nil, nil
+	}
+	if slack.URL == "" {
+		slack.URL = defaultSlackURL
+	}
+	if slack.Status.Text == "" {
+		slack.Status.Text = defaultSlackStatusText
+	}
+	if slack.Status.Emoji == "" {
+		slack.Status.Emoji = defaultSlackStatusEmoji
+	}
+
+	if err := viper.UnmarshalKey("slack.projects", &slack.Projects); err != nil {
+		return nil, fmt.Errorf("invalid slack.projects: %v", err)
+	}
+
+	return &slack, nil
+}
+
+// StatusFor returns the status of the project of the entry, falling back to
+// slack.text and slack.emoji for whatever it does not configure.
+func (slack *Slack) StatusFor(entry HookEntry) (slackProfile, error) {
+	status := slack.Status
+	// Config keys are case-insensitive
+	if projectStatus, ok := slack.Projects[strings.ToLower(entry.Project)]; ok {
+		if projectStatus.Text != "" {
+			status.Text = projectStatus.Text
+		}
+		if projectStatus.Emoji != "" {
+			status.Emoji = projectStatus.Emoji
+		}
+	}
+
+	tmpl, err := template.New("slack").Parse(status.Text)
+	if err != nil {
+		return slackProfile{}, fmt.Errorf("invalid Slack status text: %v", err)
+	}
+	var text strings.Builder
+	if err = tmpl.Execute(&text, entry); err != nil {
+		return slackProfile{}, fmt.Errorf("invalid Slack status text: %v", err)
+	}
+
+	profile := slackProfile{StatusText: strings.TrimSpace(text.String()), StatusEmoji: status.Emoji}
+	if runes := []rune(profile.StatusText); len(runes) > slackStatusTextLimit {
+		profile.StatusText = string(runes[:slackStatusTextLimit-1]) + "…"
+	}
+	return profile, nil
+}
+
+// SetStatus sets the status of the user the token belongs to; an empty
+// profile clears it.
+func (slack *Slack) SetStatus(profile slackProfile) error {
+	content, err := json.Marshal(map[string]interface{}{"profile": profile})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, slack.URL+"/users.profile.set", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	req.Header.Set("Authorization", "Bearer "+slack.Token)
+
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("Slack: %s %s", res.Status, strings.TrimSpace(string(message)))
+	}
+
+	// Slack reports errors in the body of successful responses
+	var response slackResponse
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return err
+	}
+	if !response.OK {
+		return fmt.Errorf("Slack: %s", response.Error)
+	}
+	return nil
+}
+
+// UpdateSlackStatus sets the Slack status when an activity began tracking
+// and clears it when it was finished, if Slack is configured.
+func UpdateSlackStatus(event string, payload interface{}) error {
+	entry, ok := payload.(HookEntry)
+	if !ok || (event != HookPostTrack && event != HookPostFinish) {
+		return nil
+	}
+
+	slack, err := NewSlack()
+	if err != nil || slack == nil {
+		return err
+	}
+
+	if event == HookPostFinish {
+		return slack.SetStatus(slackProfile{})
+	}
+	// Activities tracked in retrospect are not going on
+	if entry.Finish != nil {
+		return nil
+	}
+
+	profile, err := slack.StatusFor(entry)
+	if err != nil {
+		return err
+	}
+	return slack.SetStatus(profile)
+}
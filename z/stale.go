@@ -0,0 +1,172 @@
+package z
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// A running entry is stale once it has been running for longer than
+// `running.max`, e.g. because it was forgotten over the weekend:
+//
+//	running:
+//	  max: 12h
+//	  autoFinish: true
+//
+// Every command warns about stale entries, suggesting when to finish them,
+// and with running.autoFinish, commands changing the database finish them
+// at the end of the working hours of the day they began.
+
+// StaleSuggestion is a time a stale entry could be finished at.
+type StaleSuggestion struct {
+	Finish time.Time
+	Reason string
+}
+
+// GetRunningMax returns running.max, or zero if stale entries are not
+// looked for.
+func GetRunningMax() (time.Duration, error) {
+	if !viper.IsSet("running.max") {
+		return 0, nil
+	}
+
+	max, err := parseTarget(viper.GetString("running.max"))
+	if err != nil || max < 0 {
+		return 0, fmt.Errorf("invalid running.max '%s', use e.g. 12h", viper.GetString("running.max"))
+	}
+	return max, nil
+}
+
+// GetStaleEntry returns the running entry if it is stale.
+func GetStaleEntry(user string, now time.Time) (Entry, bool, error) {
+	max, err := GetRunningMax()
+	if err != nil || max == 0 {
+		return Entry{}, false, err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil || runningEntryId == "" {
+		return Entry{}, false, err
+	}
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	return runningEntry, now.Sub(runningEntry.Begin) > max, nil
+}
+
+// endOfWorkday returns the end of the working hours of the day the entry
+// began, or the end of that day if it began after working hours.
+func endOfWorkday(entry Entry, hours WorkingHours) time.Time {
+	day := time.Date(entry.Begin.Year(), entry.Begin.Month(), entry.Begin.Day(), 0, 0, 0, 0, entry.Begin.Location())
+	if end := day.Add(hours.End); end.After(entry.Begin) {
+		return end
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Minute)
+}
+
+// SuggestStaleFinish returns when the stale entry was probably finished: at
+// the end of the working hours of the day it began and, if idle periods
+// were recorded within it, when it became idle for the longest time.
+func SuggestStaleFinish(entry Entry, now time.Time) ([]StaleSuggestion, error) {
+	var suggestions []StaleSuggestion
+
+	hours, err := GetWorkingHours()
+	if err != nil {
+		return suggestions, err
+	}
+	if end := endOfWorkday(entry, hours); end.Before(now) {
+		suggestions = append(suggestions, StaleSuggestion{Finish: end, Reason: "end of the working hours"})
+	}
+
+	threshold, err := GetIdleThreshold()
+	if err != nil {
+		return suggestions, err
+	}
+	entry.Finish = now
+	periods, err := FindIdlePeriods(entry, threshold)
+	if err != nil {
+		return suggestions, err
+	}
+	var longest IdlePeriod
+	for _, period := range periods {
+		if period.Duration() > longest.Duration() {
+			longest = period
+		}
+	}
+	if !longest.Begin.IsZero() {
+		suggestions = append(suggestions, StaleSuggestion{Finish: longest.Begin, Reason: "when it became idle"})
+	}
+
+	return suggestions, nil
+}
+
+// GetOutputForStale warns about the stale entry with the commands finishing
+// it at the suggested times.
+func GetOutputForStale(entry Entry, suggestions []StaleSuggestion, now time.Time) string {
+	name := entry.Project
+	if entry.Task != "" {
+		name = entry.Task + " on " + entry.Project
+	}
+
+	output := fmt.Sprintf("%s still tracking %s since %s (%sh), did you forget to finish it?\n",
+		CharError,
+		color.FgLightWhite.Render(name),
+		entry.Begin.Format("Mon "+GetTimeDisplayFormat()),
+		color.FgLightRed.Render(fmtDuration(now.Sub(entry.Begin))))
+	for _, suggestion := range suggestions {
+		output += fmt.Sprintf("%s zeit finish --at \"%s\"  %s\n", CharMore, suggestion.Finish.Format("2006-01-02 15:04"), color.FgGray.Render(suggestion.Reason))
+	}
+	return output
+}
+
+// CheckStaleEntry warns about a stale running entry before running the
+// command, or finishes it with running.autoFinish if the command changes the
+// database anyway. Commands completing shell input, finishing the entry
+// themselves or run by hooks are left alone.
+func CheckStaleEntry(cmd *cobra.Command) error {
+	switch cmd.Name() {
+	case "help", "completion", "finish", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return nil
+	}
+	if database == nil || cmd.Hidden || IsNoDatabaseCommand(cmd) {
+		return nil
+	}
+
+	user := GetCurrentUser()
+	now := time.Now()
+	entry, stale, err := GetStaleEntry(user, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		return nil
+	}
+	if !stale {
+		return nil
+	}
+
+	if viper.GetBool("running.autoFinish") && !IsReadOnlyCommand(cmd) {
+		hours, err := GetWorkingHours()
+		if err != nil {
+			return err
+		}
+		if end := endOfWorkday(entry, hours); end.Before(now) {
+			if entry, err = finishRunningEntryAt(user, entry.ID, end); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s%s it was running for longer than running.max, so it was finished at the end of the working hours\n", GetEntryOutputForFinish(entry), CharInfo)
+			return nil
+		}
+	}
+
+	suggestions, err := SuggestStaleFinish(entry, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+	}
+	fmt.Fprint(os.Stderr, GetOutputForStale(entry, suggestions, now))
+	return nil
+}
@@ -0,0 +1,96 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type StatsDay struct {
+	Date    string `json:"date"`
+	Seconds int64  `json:"seconds"`
+}
+
+type StatsWeek struct {
+	Week         string     `json:"week"`
+	Days         []StatsDay `json:"days"`
+	TotalSeconds int64      `json:"totalSeconds"`
+}
+
+type StatsShare struct {
+	Project      string  `json:"project"`
+	TotalSeconds int64   `json:"totalSeconds"`
+	Share        float64 `json:"share"`
+}
+
+// StatsSummary is what `zeit stats` shows as JSON: the days of the week
+// containing at and of the week before, and the distribution of the time
+// tracked across the projects.
+type StatsSummary struct {
+	Weeks        []StatsWeek           `json:"weeks"`
+	Projects     []StatsShare          `json:"projects"`
+	TotalSeconds int64                 `json:"totalSeconds"`
+	Budgets      []ProjectBudgetStatus `json:"budgets"`
+}
+
+func NewStatsSummary(user string, entries []Entry, at time.Time) (StatsSummary, error) {
+	summary := StatsSummary{Projects: []StatsShare{}}
+
+	if IsFirstWeekDayMonday() {
+		now.WeekStartDay = time.Monday
+	}
+	thisWeek := now.With(at).BeginningOfWeek()
+	for _, weekBegin := range []time.Time{thisWeek, thisWeek.AddDate(0, 0, -7)} {
+		year, number := weekBegin.AddDate(0, 0, 3).ISOWeek()
+		week := StatsWeek{Week: fmt.Sprintf("%d-W%02d", year, number)}
+		days := zeit.SumByDay(entries, weekBegin, weekBegin.AddDate(0, 0, 7), weekBegin.Location())
+		for day := weekBegin; day.Before(weekBegin.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+			tracked := StatsDay{Date: day.Format(DateFormat), Seconds: int64(days[day.Format(DateFormat)].Seconds())}
+			week.Days = append(week.Days, tracked)
+			week.TotalSeconds += tracked.Seconds
+		}
+		summary.Weeks = append(summary.Weeks, week)
+	}
+
+	totals := zeit.SumByProject(entries, time.Time{}, time.Time{})
+	for _, total := range totals {
+		summary.TotalSeconds += int64(total.Seconds())
+	}
+	for project, total := range totals {
+		share := StatsShare{Project: project, TotalSeconds: int64(total.Seconds())}
+		if summary.TotalSeconds > 0 {
+			share.Share = float64(share.TotalSeconds) / float64(summary.TotalSeconds)
+		}
+		summary.Projects = append(summary.Projects, share)
+	}
+	sort.Slice(summary.Projects, func(i, j int) bool {
+		if summary.Projects[i].TotalSeconds != summary.Projects[j].TotalSeconds {
+			return summary.Projects[i].TotalSeconds > summary.Projects[j].TotalSeconds
+		}
+		return strings.ToLower(summary.Projects[i].Project) < strings.ToLower(summary.Projects[j].Project)
+	})
+
+	var err error
+	summary.Budgets, err = GetProjectBudgetStatuses(user)
+	return summary, err
+}
+
+type TagStats struct {
+	Tag             string `json:"tag"`
+	ThisWeekSeconds int64  `json:"thisWeekSeconds"`
+	LastWeekSeconds int64  `json:"lastWeekSeconds"`
+	BudgetSeconds   int64  `json:"budgetSeconds,omitempty"`
+	BudgetPeriod    string `json:"budgetPeriod,omitempty"`
+	UsedSeconds     int64  `json:"usedSeconds,omitempty"`
+}
+
+type ReferenceStats struct {
+	Reference  string   `json:"reference"`
+	Seconds    int64    `json:"seconds"`
+	Activities int      `json:"activities"`
+	Projects   []string `json:"projects"`
+}
@@ -2,31 +2,88 @@ package z
 
 import (
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/cobra"
 	// "github.com/shopspring/decimal"
-	// "github.com/gookit/color"
 )
 
+var statsTags bool
+var statsGroupBy string
+var statsUtilization bool
+var statsCompare string
+
 var statsCmd = &cobra.Command{
-	Use:   "stats",
-	Short: "Display activity statistics",
-	Long:  "Display statistics on all tracked activities.",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:         "stats",
+	Short:       "Display activity statistics",
+	Long:        "Display statistics on all tracked activities, or those within --since, --until or --range.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 
-		entries, err := database.ListEntries(user)
+		if statsTags {
+			statsGroupBy = StatsGroupByTag
+		}
+
+		if statsCompare != "" {
+			return outputComparisonStats(user)
+		}
+
+		if statsUtilization {
+			return outputUtilizationStats(user)
+		}
+
+		switch statsGroupBy {
+		case "":
+		case StatsGroupByTag:
+			return outputTagStats(user)
+		case StatsGroupByReference:
+			return outputReferenceStats(user)
+		case StatsGroupByHour, StatsGroupByWeekday:
+			return outputHistogramStats(user, statsGroupBy)
+		default:
+			return fmt.Errorf("unknown group '%s', possible values: %s", statsGroupBy, strings.Join(StatsGroups(), ", "))
+		}
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		if entries, err = GetFilteredEntries(entries, "", "", sinceTime, untilTime); err != nil {
+			return err
+		}
+
+		// The weeks shown are those up to the end of the range
+		at := time.Now()
+		if !untilTime.IsZero() && untilTime.Before(at) {
+			at = untilTime
+		}
+
+		entries, err = roundStatsEntries(entries)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
+		}
+		if IsOutputJSON() {
+			summary, err := NewStatsSummary(user, entries, at)
+			if err != nil {
+				return err
+			}
+			return printJSON(summary)
 		}
 
 		cal, _ := NewCalendar(entries)
 
-		weekMinus0 := time.Now()
+		weekMinus0 := at
 		monthMinus0, weeknumberMinus0 := GetISOWeekInMonth(weekMinus0)
 		monthMinus00 := monthMinus0 - 1
 		weeknumberMinus00 := weeknumberMinus0 - 1
@@ -46,11 +103,388 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("%s\n\n\n", OutputAppendRight(thisWeek, previousWeek, 16))
 		fmt.Printf("%s\n", cal.GetOutputForDistribution())
 
-		return
+		statuses, err := GetProjectBudgetStatuses(user)
+		if err != nil {
+			return err
+		}
+		if len(statuses) > 0 {
+			fmt.Printf("BUDGETS\n\n")
+			for _, status := range statuses {
+				fmt.Printf("   %-16s %s\n", status.Project, status.GetOutput())
+			}
+			fmt.Println()
+		}
+
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(statsCmd)
 	statsCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+	statsCmd.Flags().BoolVar(&statsTags, "tags", false, "Show statistics and budgets per tag (same as --group-by tag)")
+	statsCmd.Flags().StringVar(&statsGroupBy, "group-by", "", "Show statistics per group, possible values: "+strings.Join(StatsGroups(), ", "))
+	statsCmd.Flags().BoolVar(&statsUtilization, "utilization", false, "Show the share of billable time per week, month and client")
+	statsCmd.Flags().StringVar(&statsCompare, "compare", "", "Compare the time per project with a previous range, e.g. lastMonth or 2024-01-01..2024-03-31")
+	statsCmd.Flags().StringVar(&since, "since", "", "Date/time to start the statistics from")
+	statsCmd.Flags().StringVar(&until, "until", "", "Date/time to end the statistics at")
+	statsCmd.Flags().StringVarP(&project, "project", "p", "", "Project to show the statistics for (only with --group-by hour or weekday)")
+	statsCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+}
+
+// roundStatsEntries applies the rounding rules to the entries if they apply to
+// statistics.
+func roundStatsEntries(entries []Entry) ([]Entry, error) {
+	rounding, err := GetRoundingFor(RoundInStats)
+	if err != nil {
+		return nil, err
+	}
+	return rounding.RoundEntries(entries), nil
+}
+
+func StatsGroups() []string {
+	return []string{
+		StatsGroupByTag,
+		StatsGroupByReference,
+		StatsGroupByHour,
+		StatsGroupByWeekday,
+	}
+}
+
+type referenceStats struct {
+	Reference string
+	Duration  time.Duration
+	Entries   int
+	Projects  []string
+}
+
+func outputReferenceStats(user string) error {
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return err
+	}
+
+	entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+	if err != nil {
+		return err
+	}
+
+	stats := make(map[string]referenceStats)
+	var referenceKeys []string
+	var unreferenced time.Duration
+	entries, err = roundStatsEntries(entries)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		to := untilTime
+		if to.IsZero() {
+			to = entry.End()
+		}
+		duration := zeit.ClippedDuration(entry, sinceTime, to)
+
+		if len(entry.References) == 0 {
+			unreferenced += duration
+			continue
+		}
+
+		// Time on an entry with several references is counted for each of them.
+		for _, reference := range entry.References {
+			key := strings.ToLower(reference)
+			s, ok := stats[key]
+			if !ok {
+				s.Reference = reference
+				referenceKeys = append(referenceKeys, key)
+			}
+			s.Duration += duration
+			s.Entries++
+			if entry.Project != "" && !zeit.ContainsFold(s.Projects, entry.Project) {
+				s.Projects = append(s.Projects, entry.Project)
+			}
+			stats[key] = s
+		}
+	}
+	sort.Strings(referenceKeys)
+
+	if IsOutputJSON() {
+		list := []ReferenceStats{}
+		for _, key := range referenceKeys {
+			sort.Strings(stats[key].Projects)
+			list = append(list, ReferenceStats{
+				Reference:  stats[key].Reference,
+				Seconds:    int64(stats[key].Duration.Seconds()),
+				Activities: stats[key].Entries,
+				Projects:   append([]string{}, stats[key].Projects...),
+			})
+		}
+		return printJSON(map[string]interface{}{"references": list, "unreferencedSeconds": int64(unreferenced.Seconds())})
+	}
+
+	fmt.Printf("\nREFERENCES\n\n")
+	for _, key := range referenceKeys {
+		s := stats[key]
+		sort.Strings(s.Projects)
+		fmt.Printf("   %8sh   %-32s %3d entries   %s\n",
+			color.FgLightWhite.Render(fmtDuration(s.Duration)),
+			s.Reference,
+			s.Entries,
+			color.FgGray.Render(strings.Join(s.Projects, ", ")),
+		)
+	}
+	if unreferenced > 0 {
+		fmt.Printf("\n   %8sh   %s\n", color.FgLightWhite.Render(fmtDuration(unreferenced)), color.FgGray.Render("without reference"))
+	}
+	fmt.Println()
+	return nil
+}
+
+// outputUtilizationStats shows the utilization of the given range, by default
+// of this and the last two months.
+func outputUtilizationStats(user string) error {
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return err
+	}
+	if sinceTime.IsZero() {
+		sinceTime = now.BeginningOfMonth().AddDate(0, -2, 0)
+	}
+	if untilTime.IsZero() || untilTime.After(time.Now()) {
+		untilTime = time.Now()
+	}
+
+	utilization, err := NewUtilization(user, sinceTime, untilTime)
+	if err != nil {
+		return err
+	}
+
+	if IsOutputJSON() {
+		return printJSON(utilization)
+	}
+
+	percent := func(period UtilizationPeriod) string {
+		rendered := fmt.Sprintf("%4.0f%%", period.Utilization*100)
+		switch {
+		case utilization.Target == 0 || period.TotalSeconds == 0:
+			return color.FgLightWhite.Render(rendered)
+		case period.Utilization >= utilization.Target:
+			return color.FgLightGreen.Render(rendered)
+		default:
+			return color.FgLightRed.Render(rendered)
+		}
+	}
+	row := func(period UtilizationPeriod) string {
+		return fmt.Sprintf("   %-16s %8sh of %8sh billable   %s\n",
+			period.Name,
+			fmtDuration(time.Duration(period.BillableSeconds)*time.Second),
+			fmtDuration(time.Duration(period.TotalSeconds)*time.Second),
+			percent(period),
+		)
+	}
+
+	fmt.Printf("\nUTILIZATION")
+	if utilization.Target > 0 {
+		fmt.Printf(" (target %.0f%%)", utilization.Target*100)
+	}
+	fmt.Printf("\n\nWEEKS\n\n")
+	for _, week := range utilization.Weeks {
+		fmt.Print(row(week))
+	}
+	fmt.Printf("\nMONTHS\n\n")
+	for _, month := range utilization.Months {
+		fmt.Print(row(month))
+	}
+	fmt.Printf("\nCLIENTS\n\n")
+	for _, client := range utilization.Clients {
+		fmt.Printf("   %-16s %8sh billable   %s %s\n",
+			client.Name,
+			fmtDuration(time.Duration(client.BillableSeconds)*time.Second),
+			color.FgLightWhite.Render(fmt.Sprintf("%4.0f%%", client.Utilization*100)),
+			color.FgGray.Render("of all time tracked"),
+		)
+	}
+	fmt.Printf("\n%s", row(utilization.Total))
+	fmt.Println()
+	return nil
+}
+
+// outputHistogramStats shows when the time in the given range was tracked,
+// by hour of the day or by weekday.
+func outputHistogramStats(user string, groupBy string) error {
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return err
+	}
+
+	entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+	if err != nil {
+		return err
+	}
+	if project != "" {
+		if entries, err = GetFilteredEntries(entries, project, "", time.Time{}, time.Time{}); err != nil {
+			return err
+		}
+	}
+
+	var histogram Histogram
+	title := "HOURS OF THE DAY"
+	if groupBy == StatsGroupByHour {
+		histogram, err = NewHourHistogram(user, entries, sinceTime, untilTime)
+	} else {
+		title = "DAYS OF THE WEEK"
+		histogram, err = NewWeekdayHistogram(user, entries, sinceTime, untilTime)
+	}
+	if err != nil {
+		return err
+	}
+
+	if IsOutputJSON() {
+		return printJSON(histogram.GetSlots())
+	}
+	fmt.Printf("\n%s\n%s", title, histogram.GetOutput(48))
+	return nil
+}
+
+// outputComparisonStats compares the given range, by default the one matching
+// --compare like thisMonth for lastMonth, with the one of --compare.
+func outputComparisonStats(user string) error {
+	var current, previous ComparisonPeriod
+	var err error
+
+	if previous.Since, previous.Until, err = ParseComparisonRange(statsCompare); err != nil {
+		return err
+	}
+
+	currentRange := listRange
+	if currentRange == "" && since == "" && until == "" {
+		var ok bool
+		if currentRange, ok = CurrentComparisonRange(statsCompare); !ok {
+			return fmt.Errorf("use --since, --until or --range to set the range to compare with %s", statsCompare)
+		}
+	}
+	if current.Since, current.Until, err = ParseTimeRange(since, until, currentRange); err != nil {
+		return err
+	}
+	if current.Until.IsZero() {
+		current.Until = time.Now()
+	}
+
+	comparison, err := NewComparison(user, current, previous)
+	if err != nil {
+		return err
+	}
+
+	if IsOutputJSON() {
+		return printJSON(comparison)
+	}
+
+	period := func(p ComparisonPeriod) string {
+		return p.Since.Format(DateFormat) + " – " + p.Until.Format(DateFormat)
+	}
+	row := func(name string, total ComparisonTotal) string {
+		percent := "new"
+		if total.DeltaPercent != nil {
+			percent = fmt.Sprintf("%+.1f%%", *total.DeltaPercent)
+		}
+		return fmt.Sprintf("   %-24s %10sh %10sh %sh   %s\n",
+			name,
+			fmtDuration(time.Duration(total.CurrentSeconds)*time.Second),
+			fmtDuration(time.Duration(total.PreviousSeconds)*time.Second),
+			color.FgLightWhite.Render(fmt.Sprintf("%10s", fmtDelta(time.Duration(total.DeltaSeconds)*time.Second))),
+			color.FgLightWhite.Render(fmt.Sprintf("%8s", percent)),
+		)
+	}
+
+	fmt.Printf("\nCOMPARISON\n\n   %-24s %s\n   %-24s %s\n\n", "current", period(comparison.Current), "previous", period(comparison.Previous))
+	for _, total := range comparison.Projects {
+		name := total.Project
+		if name == "" {
+			name = "(no project)"
+		}
+		fmt.Print(row(name, total))
+	}
+	fmt.Printf("\n%s\n", row("total", comparison.Total))
+	return nil
+}
+
+func outputTagStats(user string) error {
+	budgets, err := GetTagBudgets()
+	if err != nil {
+		return err
+	}
+
+	week := TagBudget{Period: BudgetWeek}
+	thisWeekBegin, thisWeekEnd := week.Range(time.Now())
+	lastWeekBegin, lastWeekEnd := week.Range(thisWeekBegin.AddDate(0, 0, -1))
+
+	entries, err := database.ListEntriesBetween(user, lastWeekBegin, thisWeekEnd)
+	if err != nil {
+		return err
+	}
+
+	thisWeek := make(map[string]time.Duration)
+	lastWeek := make(map[string]time.Duration)
+	var tagKeys []string
+	entries, err = roundStatsEntries(entries)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for _, tag := range entry.Tags {
+			tag = strings.ToLower(tag)
+			if _, ok := thisWeek[tag]; !ok {
+				tagKeys = append(tagKeys, tag)
+			}
+			thisWeek[tag] += zeit.ClippedDuration(entry, thisWeekBegin, thisWeekEnd)
+			lastWeek[tag] += zeit.ClippedDuration(entry, lastWeekBegin, lastWeekEnd)
+		}
+	}
+	for _, budget := range budgets {
+		if _, ok := thisWeek[budget.Tag]; !ok {
+			tagKeys = append(tagKeys, budget.Tag)
+			thisWeek[budget.Tag] = 0
+		}
+	}
+	sort.Strings(tagKeys)
+
+	if IsOutputJSON() {
+		list := []TagStats{}
+		for _, tag := range tagKeys {
+			stats := TagStats{Tag: tag, ThisWeekSeconds: int64(thisWeek[tag].Seconds()), LastWeekSeconds: int64(lastWeek[tag].Seconds())}
+			if budget, found, _ := GetTagBudget(tag); found {
+				used, err := budget.Used(user, time.Now())
+				if err != nil {
+					return err
+				}
+				stats.BudgetSeconds, stats.BudgetPeriod, stats.UsedSeconds = int64(budget.Limit.Seconds()), budget.Period, int64(used.Seconds())
+			}
+			list = append(list, stats)
+		}
+		return printJSON(list)
+	}
+
+	fmt.Printf("\nTAGS\n\n")
+	for _, tag := range tagKeys {
+		fmt.Printf("   %-16s this week %8sh   last week %8sh", "#"+tag, fmtDuration(thisWeek[tag]), fmtDuration(lastWeek[tag]))
+
+		budget, found, _ := GetTagBudget(tag)
+		if found {
+			used, err := budget.Used(user, time.Now())
+			if err != nil {
+				return err
+			}
+
+			clr := color.FgLightGreen
+			if used > budget.Limit {
+				clr = color.FgLightRed
+			}
+			fmt.Printf("   budget %s of %sh per %s (%d%%)",
+				clr.Render(fmtDuration(used)+"h"),
+				fmtDuration(budget.Limit),
+				budget.Period,
+				int(used*100/budget.Limit))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	return nil
 }
@@ -0,0 +1,128 @@
+package z
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// StatusSchemaVersion is the version of the `zeit status --output json`
+// schema. Any change to Status or StatusEntry, other than adding fields,
+// requires bumping it.
+const StatusSchemaVersion int = 1
+
+type StatusEntry struct {
+	ID             string    `json:"id"`
+	Begin          time.Time `json:"begin"`
+	ElapsedSeconds int64     `json:"elapsedSeconds"`
+	Project        string    `json:"project"`
+	Task           string    `json:"task"`
+	Tags           []string  `json:"tags"`
+}
+
+type StatusToday struct {
+	TotalSeconds     int64 `json:"totalSeconds"`
+	Entries          int   `json:"entries"`
+	TargetSeconds    int64 `json:"targetSeconds"`
+	RemainingSeconds int64 `json:"remainingSeconds"`
+}
+
+type Status struct {
+	Schema    int          `json:"schema"`
+	Timestamp time.Time    `json:"timestamp"`
+	Running   bool         `json:"running"`
+	Entry     *StatusEntry `json:"entry"`
+	Today     StatusToday  `json:"today"`
+}
+
+func GetStatus(user string) (Status, error) {
+	timestamp := time.Now()
+	status := Status{Schema: StatusSchemaVersion, Timestamp: timestamp}
+
+	targets, err := GetTargets()
+	if err != nil {
+		return status, err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return status, err
+	}
+
+	if runningEntryId != "" {
+		runningEntry, err := database.GetEntry(user, runningEntryId)
+		if err != nil {
+			return status, err
+		}
+
+		status.Running = true
+		status.Entry = &StatusEntry{
+			ID:             runningEntry.ID,
+			Begin:          runningEntry.Begin,
+			ElapsedSeconds: int64(timestamp.Sub(runningEntry.Begin).Seconds()),
+			Project:        runningEntry.Project,
+			Task:           runningEntry.Task,
+			Tags:           runningEntry.Tags,
+		}
+		if status.Entry.Tags == nil {
+			status.Entry.Tags = []string{}
+		}
+	}
+
+	today := now.With(timestamp)
+	todayBegin, todayEnd := today.BeginningOfDay(), today.EndOfDay()
+	entries, err := database.ListEntriesBetween(user, todayBegin, todayEnd)
+	if err != nil {
+		return status, err
+	}
+
+	var total time.Duration
+	for _, entry := range entries {
+		total += zeit.ClippedDuration(entry, todayBegin, todayEnd)
+	}
+	status.Today = StatusToday{
+		TotalSeconds:  int64(total.Seconds()),
+		Entries:       len(entries),
+		TargetSeconds: int64(targets.Day(timestamp).Seconds()),
+	}
+	status.Today.RemainingSeconds = max(status.Today.TargetSeconds-status.Today.TotalSeconds, 0)
+
+	return status, nil
+}
+
+// ParseStatusFormat parses the format as a Go template executed with the
+// Status, or, if it is the name of a preset configured as
+// status.formats.<name>, the preset. Besides the builtin functions templates
+// can use duration and clock to format seconds as hours, e.g.
+// {{duration .Today.TotalSeconds}}, or as a running clock, and join.
+func ParseStatusFormat(format string) (*template.Template, error) {
+	name := "status"
+	if preset := viper.GetString("status.formats." + format); preset != "" {
+		name = format
+		format = preset
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"duration": func(seconds int64) string { return fmtDuration(time.Duration(seconds) * time.Second) },
+		"clock":    func(seconds int64) string { return fmtClock(time.Duration(seconds) * time.Second) },
+		"join":     strings.Join,
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status format: %v", err)
+	}
+	return tmpl, nil
+}
+
+func FormatStatus(tmpl *template.Template, status Status) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, status); err != nil {
+		return "", fmt.Errorf("could not format status: %v", err)
+	}
+	return buf.String(), nil
+}
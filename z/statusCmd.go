@@ -0,0 +1,125 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusFollow    bool
+	statusFormat    string
+	statusStatusbar string
+	statusPrompt    bool
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Tracking status",
+	Long:  "Show the currently tracking activity with its elapsed time, the time tracked today and the progress towards the target of the day. Using --output json, the status is printed in a stable, versioned schema for status bars and widgets; --format prints it using a Go template.",
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		AnnotationReadOnly: "true",
+		// The prompt is read from a cache, opening the database only if needed
+		AnnotationNoDatabaseWith: "prompt",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if statusPrompt {
+			if output := GetPromptOutput(user); output != "" {
+				fmt.Println(output)
+			}
+			return nil
+		}
+
+		if statusFormat != "" {
+			if _, err := ParseStatusFormat(statusFormat); err != nil {
+				return err
+			}
+		}
+
+		if statusFollow {
+			return Follow(func() (string, error) {
+				return statusOutput(user)
+			})
+		}
+
+		output, err := statusOutput(user)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func statusOutput(user string) (string, error) {
+	status, err := GetStatus(user)
+	if err != nil {
+		return "", err
+	}
+
+	if statusStatusbar != "" {
+		output, err := GetStatusbarOutput(status, statusStatusbar)
+		return output + "\n", err
+	}
+
+	if IsOutputJSON() {
+		// Followed status is printed as one object per line
+		var stringified []byte
+		if statusFollow {
+			stringified, err = json.Marshal(status)
+		} else {
+			stringified, err = json.MarshalIndent(status, "", "  ")
+		}
+		return string(stringified) + "\n", err
+	}
+
+	if statusFormat != "" {
+		tmpl, err := ParseStatusFormat(statusFormat)
+		if err != nil {
+			return "", err
+		}
+		formatted, err := FormatStatus(tmpl, status)
+		return formatted + "\n", err
+	}
+
+	var output string
+	if status.Running {
+		entry := Entry{ID: status.Entry.ID, Begin: status.Entry.Begin, Project: status.Entry.Project, Task: status.Entry.Task}
+		output = GetEntryOutputForTrack(entry, true, true)
+	} else {
+		output = fmt.Sprintf("%s not running\n", CharFinish)
+	}
+
+	output += fmt.Sprintf("%s %sh tracked today", CharInfo, color.FgLightWhite.Render(fmtDuration(time.Duration(status.Today.TotalSeconds)*time.Second)))
+	if status.Today.TargetSeconds > 0 {
+		target := time.Duration(status.Today.TargetSeconds) * time.Second
+		if status.Today.RemainingSeconds > 0 {
+			output += fmt.Sprintf(", %d%% of %sh, %sh remaining",
+				status.Today.TotalSeconds*100/status.Today.TargetSeconds,
+				fmtDuration(target),
+				color.FgLightYellow.Render(fmtDuration(time.Duration(status.Today.RemainingSeconds)*time.Second)))
+		} else {
+			output += fmt.Sprintf(", %sh target reached", color.FgLightGreen.Render(fmtDuration(target)))
+		}
+	}
+	return output + "\n", nil
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusFollow, "follow", false, "Keep the status updated; with --output json, a status object is printed per line whenever it changes")
+	statusCmd.Flags().BoolVar(&statusFollow, "watch", false, "Same as --follow, refreshing the status every second")
+	statusCmd.Flags().StringVar(&statusFormat, "format", "", "Go template to print the status with, e.g. '{{if .Running}}{{.Entry.Project}} {{clock .Entry.ElapsedSeconds}}{{end}}', or the name of a preset in status.formats")
+	statusCmd.Flags().StringVar(&statusStatusbar, "statusbar", "", "Print a single line for status bars like tmux or polybar, or with waybar or i3blocks their JSON, possible values: "+strings.Join(StatusbarFormats(), ", "))
+	statusCmd.Flags().Lookup("statusbar").NoOptDefVal = StatusbarText
+	statusCmd.Flags().BoolVar(&statusPrompt, "prompt", false, "Print a minimal segment for shell prompts like starship or powerlevel10k, nothing when not tracking; always exits successfully")
+	statusCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,88 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func StatusbarFormats() []string {
+	return []string{
+		StatusbarText,
+		StatusbarWaybar,
+		StatusbarI3blocks,
+	}
+}
+
+type WaybarStatus struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+	Alt     string `json:"alt"`
+}
+
+type I3blocksStatus struct {
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text"`
+	Color     string `json:"color,omitempty"`
+}
+
+// getStatusbarIcons returns the icons shown while tracking and while idle,
+// configurable as statusbar.icon and statusbar.idleIcon.
+func getStatusbarIcons() (string, string) {
+	icon, idleIcon := "▶", "■"
+	if viper.IsSet("statusbar.icon") {
+		icon = viper.GetString("statusbar.icon")
+	}
+	if viper.IsSet("statusbar.idleIcon") {
+		idleIcon = viper.GetString("statusbar.idleIcon")
+	}
+	return icon, idleIcon
+}
+
+// GetStatusbarOutput renders the status as a single line for tmux, polybar
+// and the like, or as the JSON waybar and i3blocks read, without colors.
+func GetStatusbarOutput(status Status, format string) (string, error) {
+	icon, idleIcon := getStatusbarIcons()
+
+	hours := func(seconds int64) string {
+		return fmtDuration(time.Duration(seconds) * time.Second)
+	}
+
+	var full, short, tooltip, class string
+	if status.Running {
+		name := status.Entry.Project
+		if status.Entry.Task != "" {
+			name = strings.TrimPrefix(name+"/"+status.Entry.Task, "/")
+		}
+		full = strings.TrimSpace(fmt.Sprintf("%s %s %s", icon, name, hours(status.Entry.ElapsedSeconds)))
+		short = strings.TrimSpace(fmt.Sprintf("%s %s", icon, hours(status.Entry.ElapsedSeconds)))
+		tooltip = fmt.Sprintf("tracking %s since %s\n%sh tracked today", name, status.Entry.Begin.Format("15:04"), hours(status.Today.TotalSeconds))
+		class = "tracking"
+	} else {
+		full = strings.TrimSpace(fmt.Sprintf("%s %s", idleIcon, hours(status.Today.TotalSeconds)))
+		short = idleIcon
+		tooltip = fmt.Sprintf("not tracking\n%sh tracked today", hours(status.Today.TotalSeconds))
+		class = "idle"
+	}
+
+	switch strings.ToLower(format) {
+	case StatusbarText:
+		return full, nil
+	case StatusbarWaybar:
+		stringified, err := json.Marshal(WaybarStatus{Text: full, Tooltip: tooltip, Class: class, Alt: class})
+		return string(stringified), err
+	case StatusbarI3blocks:
+		i3blocks := I3blocksStatus{FullText: full, ShortText: short}
+		if !status.Running {
+			i3blocks.Color = viper.GetString("statusbar.idleColor")
+		}
+		stringified, err := json.Marshal(i3blocks)
+		return string(stringified), err
+	default:
+		return "", fmt.Errorf("unknown statusbar format '%s', possible values: %s", format, strings.Join(StatusbarFormats(), ", "))
+	}
+}
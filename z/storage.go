@@ -0,0 +1,227 @@
+package z
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+var ErrNotFound = zeit.ErrNotFound
+
+type Storage interface {
+	AddEntry(user string, entry Entry, setRunning bool) (string, error)
+	GetEntry(user string, entryId string) (Entry, error)
+	UpdateEntry(user string, entry Entry) (string, error)
+	FinishEntry(user string, entry Entry) (string, error)
+	EraseEntry(user string, id string) error
+	GetRunningEntryId(user string) (string, error)
+	SetRunningEntryId(user string, id string) error
+	ListEntries(user string) ([]Entry, error)
+	ListEntriesBetween(user string, from time.Time, to time.Time) ([]Entry, error)
+	ListRawEntries(user string) (map[string]string, error)
+
+	GetImportsSHA1List(user string) (map[string]string, error)
+	UpdateImportsSHA1List(user string, sha1List map[string]string) error
+
+	GetMeta(user string, key string) (string, error)
+	SetMeta(user string, key string, value string) error
+
+	UpdateProject(user string, projectName string, project Project) error
+	GetProject(user string, projectName string) (Project, error)
+	ListProjects(user string) ([]Project, error)
+	EraseProject(user string, projectName string) error
+	UpdateTask(user string, taskName string, task Task) error
+	GetTask(user string, taskName string) (Task, error)
+	ListTasks(user string) ([]Task, error)
+	EraseTask(user string, taskName string) error
+}
+
+type Batcher interface {
+	Batch(fn func() error) error
+}
+
+// Batch runs fn as a bulk operation on storages that support it.
+func Batch(fn func() error) error {
+	if batcher, ok := database.(Batcher); ok {
+		return batcher.Batch(fn)
+	}
+
+	return fn()
+}
+
+// LatestLister is implemented by storages that can list entries newest first
+// and stop reading as soon as a page is complete.
+type LatestLister interface {
+	ListLatestEntries(user string, from time.Time, to time.Time, match func(Entry) bool, offset int, limit int) ([]Entry, error)
+}
+
+// ListLatestEntries returns the entries overlapping the range from/to that
+// match, newest first, skipping the first offset of them and returning at
+// most limit, or all for a limit of 0.
+func ListLatestEntries(user string, from time.Time, to time.Time, match func(Entry) bool, offset int, limit int) ([]Entry, error) {
+	if lister, ok := database.(LatestLister); ok {
+		return lister.ListLatestEntries(user, from, to, match, offset, limit)
+	}
+
+	entries, err := database.ListEntriesBetween(user, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if match(entries[i]) {
+			latest = append(latest, entries[i])
+		}
+	}
+	return pageEntries(latest, offset, limit), nil
+}
+
+func pageEntries(entries []Entry, offset int, limit int) []Entry {
+	if offset >= len(entries) {
+		return nil
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func IsPostgresDSN(dbfile string) bool {
+	return strings.HasPrefix(dbfile, "postgres://") || strings.HasPrefix(dbfile, "postgresql://")
+}
+
+func InitStorage() (Storage, error) {
+	if remoteURL := viper.GetString("remote.url"); remoteURL != "" {
+		return InitRemote(remoteURL, viper.GetString("remote.token"))
+	}
+
+	dbfile := viper.GetString("db")
+	if dbfile == "" {
+		return nil, errors.New("please `export ZEIT_DB` to the location the zeit database should be stored at")
+	}
+
+	if IsPostgresDSN(dbfile) {
+		return InitPostgres(dbfile)
+	}
+
+	if IsGitStorage(dbfile) {
+		return InitGitStorage(GitStoragePath(dbfile))
+	}
+
+	return InitDatabase(dbfile)
+}
+
+// OpenStorage locks and opens the configured storage. The returned function
+// closes the database and releases the lock again; PostgreSQL connections
+// and remote servers require no lock and are kept open, git repositories are
+// read again every time. Post hooks run once the storage was closed.
+func OpenStorage(exclusive bool, wait bool) (func(), error) {
+	closeStorage, err := openStorage(exclusive, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	holdPostHooks()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			closeStorage()
+			releasePostHooks()
+		})
+	}, nil
+}
+
+func openStorage(exclusive bool, wait bool) (func(), error) {
+	var err error
+
+	// Commands writing to the database only use the database in use, unless
+	// databases were explicitly added for them
+	if len(GetFederatedDatabases()) > 0 {
+		if !exclusive {
+			return openFederation(wait)
+		}
+		if len(alsoDBFiles) > 0 {
+			return nil, errFederationReadOnly
+		}
+	}
+
+	dbfile := viper.GetString("db")
+	if IsGitStorage(dbfile) && viper.GetString("remote.url") == "" {
+		storage, closeStorage, err := openGitStorage(dbfile, exclusive, wait)
+		if err != nil {
+			return nil, err
+		}
+
+		database = storage
+		return func() {
+			closeStorage()
+			database = nil
+		}, nil
+	}
+
+	if !isLocalDatabase(dbfile) {
+		switch unwrapStorage(database).(type) {
+		case *Postgres, *Remote:
+		default:
+			database, err = InitStorage()
+		}
+		return func() {}, err
+	}
+
+	db, closeDB, err := openDatabaseFile(dbfile, exclusive, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	database = db
+	return func() {
+		if exclusive {
+			updatePromptState(dbfile)
+		}
+		closeDB()
+		database = nil
+	}, nil
+}
+
+func isLocalDatabase(dbfile string) bool {
+	return dbfile != "" && !IsPostgresDSN(dbfile) && !IsGitStorage(dbfile) && viper.GetString("remote.url") == ""
+}
+
+func openDatabaseFile(dbfile string, exclusive bool, wait bool) (*Database, func(), error) {
+	if err := os.MkdirAll(filepath.Dir(dbfile), 0700); err != nil {
+		return nil, nil, err
+	}
+
+	lock, err := AcquireLock(dbfile+".lock", exclusive, wait)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := InitDatabase(dbfile)
+	if err != nil {
+		lock.Release()
+		return nil, nil, err
+	}
+
+	return db, func() {
+		db.Close()
+		lock.Release()
+	}, nil
+}
+
+func NewID() string {
+	id, err := zeit.NewID()
+	if err != nil {
+		log.Fatalf("could not generate UUID: %+v", err)
+	}
+	return id
+}
@@ -0,0 +1,180 @@
+package z
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTestDatabase makes a new database file the storage of the commands for
+// the duration of the test.
+func useTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "zeit.db"))
+	if err != nil {
+		t.Fatalf("could not create the database: %v", err)
+	}
+
+	previous := database
+	database = db
+	t.Cleanup(func() {
+		database = previous
+		db.Close()
+	})
+	return db
+}
+
+// testStorages returns the storages both storage tests run against, the
+// database file and, if ZEIT_TEST_POSTGRES is set to a connection URL,
+// PostgreSQL.
+func testStorages(t *testing.T) map[string]Storage {
+	t.Helper()
+
+	storages := map[string]Storage{"buntdb": useTestDatabase(t)}
+
+	if dsn := os.Getenv("ZEIT_TEST_POSTGRES"); dsn != "" {
+		postgres, err := InitPostgres(dsn)
+		if err != nil {
+			t.Fatalf("could not connect to PostgreSQL: %v", err)
+		}
+		t.Cleanup(func() { postgres.Close() })
+		storages["postgres"] = postgres
+	}
+	return storages
+}
+
+// testUser returns a user no other test run has data of, as PostgreSQL
+// databases are shared between runs.
+func testUser(t *testing.T) string {
+	t.Helper()
+	return "test-" + NewID()
+}
+
+func TestStorageRunningEntry(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			user := testUser(t)
+
+			runningEntryId, err := storage.GetRunningEntryId(user)
+			if err != nil || runningEntryId != "" {
+				t.Fatalf("GetRunningEntryId() = %q, %v, want nothing running", runningEntryId, err)
+			}
+
+			id, err := storage.AddEntry(user, Entry{Begin: time.Now().Add(-time.Hour), Project: "zeit"}, true)
+			if err != nil {
+				t.Fatalf("AddEntry() failed: %v", err)
+			}
+			if runningEntryId, err = storage.GetRunningEntryId(user); err != nil || runningEntryId != id {
+				t.Fatalf("GetRunningEntryId() = %q, %v, want %q", runningEntryId, err, id)
+			}
+
+			entry, err := storage.GetEntry(user, id)
+			if err != nil {
+				t.Fatalf("GetEntry() failed: %v", err)
+			}
+			if entry.ID != id || entry.Project != "zeit" {
+				t.Errorf("GetEntry() = %+v, want the added entry", entry)
+			}
+
+			if _, err = storage.GetEntry(user, NewID()); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetEntry() of an unknown entry returned %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStorageListEntriesBetween(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			user := testUser(t)
+			day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+			var ids []string
+			for _, offset := range []int{2, 0, 1} {
+				begin := day.AddDate(0, 0, offset)
+				id, err := storage.AddEntry(user, Entry{Begin: begin, Finish: begin.Add(time.Hour)}, false)
+				if err != nil {
+					t.Fatalf("AddEntry() failed: %v", err)
+				}
+				ids = append(ids, id)
+			}
+
+			entries, err := storage.ListEntriesBetween(user, day, day.AddDate(0, 0, 2))
+			if err != nil {
+				t.Fatalf("ListEntriesBetween() failed: %v", err)
+			}
+			if len(entries) != 2 || entries[0].ID != ids[1] || entries[1].ID != ids[2] {
+				t.Errorf("ListEntriesBetween() = %+v, want the first two days in order", entries)
+			}
+		})
+	}
+}
+
+func TestStorageImportsSHA1ListIsReplaced(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			user := testUser(t)
+
+			if err := storage.UpdateImportsSHA1List(user, map[string]string{"a": "1", "b": "2"}); err != nil {
+				t.Fatalf("UpdateImportsSHA1List() failed: %v", err)
+			}
+			if err := storage.UpdateImportsSHA1List(user, map[string]string{"b": "3", "c": "4"}); err != nil {
+				t.Fatalf("UpdateImportsSHA1List() failed: %v", err)
+			}
+
+			sha1List, err := storage.GetImportsSHA1List(user)
+			if err != nil {
+				t.Fatalf("GetImportsSHA1List() failed: %v", err)
+			}
+			if len(sha1List) != 2 || sha1List["b"] != "3" || sha1List["c"] != "4" {
+				t.Errorf("GetImportsSHA1List() = %v, want map[b:3 c:4]", sha1List)
+			}
+		})
+	}
+}
+
+func TestStorageProjectsAndTasksSortedByName(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			user := testUser(t)
+
+			for _, name := range []string{"beta", "Gamma", "alpha"} {
+				if err := storage.UpdateProject(user, name, Project{Name: name}); err != nil {
+					t.Fatalf("UpdateProject() failed: %v", err)
+				}
+				if err := storage.UpdateTask(user, name, Task{Name: name}); err != nil {
+					t.Fatalf("UpdateTask() failed: %v", err)
+				}
+			}
+
+			projects, err := storage.ListProjects(user)
+			if err != nil {
+				t.Fatalf("ListProjects() failed: %v", err)
+			}
+			tasks, err := storage.ListTasks(user)
+			if err != nil {
+				t.Fatalf("ListTasks() failed: %v", err)
+			}
+
+			want := []string{"Gamma", "alpha", "beta"}
+			for idx, name := range want {
+				if len(projects) != len(want) || projects[idx].Name != name {
+					t.Fatalf("ListProjects() = %+v, want them in the order %v", projects, want)
+				}
+				if len(tasks) != len(want) || tasks[idx].Name != name {
+					t.Fatalf("ListTasks() = %+v, want them in the order %v", tasks, want)
+				}
+			}
+
+			if err = storage.EraseProject(user, "BETA"); err != nil {
+				t.Fatalf("EraseProject() failed: %v", err)
+			}
+			if project, err := storage.GetProject(user, "beta"); err != nil || project.Name != "" {
+				t.Errorf("GetProject() = %+v, %v after erasing it", project, err)
+			}
+		})
+	}
+}
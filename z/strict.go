@@ -0,0 +1,97 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Strict mode turns zeit into an auditable billing record: every activity
+// requires a task and notes, activities of billable projects require a
+// reference and activities can't be changed anymore once they are older than
+// `strict.editDays`. It's enabled by setting `strict.enabled`.
+
+const defaultStrictEditDays int = 7
+
+func IsStrict() bool {
+	return viper.GetBool("strict.enabled")
+}
+
+func strictEditDays() int {
+	if viper.IsSet("strict.editDays") {
+		return viper.GetInt("strict.editDays")
+	}
+	return defaultStrictEditDays
+}
+
+func NewStrictViolationError(field string, flag string, message string) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationStrictViolation,
+		Message: "strict mode: " + message,
+		Field:   field,
+		Suggestions: []Suggestion{
+			{
+				Description: fmt.Sprintf("pass %s using --%s", field, flag),
+				Field:       field,
+			},
+		},
+	}
+}
+
+// ValidateStrict checks the entry against the rules of strict mode. Like
+// notes required by project rules, notes and references are only required
+// once the entry is finished.
+func ValidateStrict(user string, entry Entry) error {
+	if !IsStrict() {
+		return nil
+	}
+
+	if entry.Task == "" {
+		return NewStrictViolationError("task", "task", "task is required")
+	}
+
+	if entry.Finish.IsZero() {
+		return nil
+	}
+
+	if strings.TrimSpace(entry.Notes) == "" {
+		return NewStrictViolationError("notes", "notes", "notes are required")
+	}
+
+	if entry.Project != "" && len(entry.References) == 0 {
+		project, err := database.GetProject(user, entry.Project)
+		if err != nil {
+			return err
+		}
+		if project.Billable {
+			return NewStrictViolationError("references", "ref", fmt.Sprintf("activities of billable project %s require a reference", entry.Project))
+		}
+	}
+
+	return nil
+}
+
+// ValidateEditWindow refuses changing or erasing an entry in strict mode
+// once it finished more than `strict.editDays` days ago.
+func ValidateEditWindow(entry Entry) error {
+	if !IsStrict() || entry.Finish.IsZero() {
+		return nil
+	}
+
+	days := strictEditDays()
+	if days <= 0 || time.Since(entry.Finish) <= time.Duration(days)*24*time.Hour {
+		return nil
+	}
+
+	return &ValidationError{
+		Code:    ValidationEditLocked,
+		Message: fmt.Sprintf("strict mode: entry %s finished more than %d days ago and can't be changed anymore", entry.ID, days),
+		Suggestions: []Suggestion{
+			{
+				Description: "track a correcting activity instead",
+			},
+		},
+	}
+}
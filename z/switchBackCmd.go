@@ -8,13 +8,24 @@ var switchBackCmd = &cobra.Command{
 	Use:   "switchback",
 	Short: "switchback to the task before the last one",
 	Long:  "End running activity and resume the task which was before, which can either be kept running until 'finish' is being called or parameterized to be a finished activity.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		finish = switchString
-		finishTask(FinishOnlyTime)
+		finished, parts, err := finishTask(FinishOnlyTime)
+		if err != nil {
+			return err
+		}
 
 		finish = ""
 		begin = switchString
-		resumeTask(2)
+		tracked, err := resumeTask(2)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Finished: entryResources([]Entry{finished}), Tracked: entryResources(append(parts, tracked))})
+		}
+		return nil
 	},
 }
 
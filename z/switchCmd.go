@@ -8,13 +8,24 @@ var switchCmd = &cobra.Command{
 	Use:   "switch",
 	Short: "switch to another task",
 	Long:  "End running activity and track new activity, which can either be kept running until 'finish' is being called or parameterized to be a finished activity.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		finish = switchString
-		finishTask(FinishOnlyTime)
+		finished, parts, err := finishTask(FinishOnlyTime)
+		if err != nil {
+			return err
+		}
 
 		finish = ""
 		begin = switchString
-		trackTask()
+		tracked, err := trackTask()
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Finished: entryResources([]Entry{finished}), Tracked: entryResources(append(parts, tracked))})
+		}
+		return nil
 	},
 }
 
@@ -25,12 +36,11 @@ func init() {
 	switchCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be assigned")
 	switchCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be assigned")
 	switchCmd.Flags().StringVarP(&notes, "notes", "n", "", "Activity notes")
+	switchCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Attendees of the activity, e.g. a meeting (comma separated)")
+	switchCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Tags of the activity (comma separated)")
+	switchCmd.Flags().BoolVar(&trackFromGit, "from-git", false, "Track the branch of the git repository in the current directory, see track --from-git")
+	switchCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Ticket/URL references of the activity, e.g. GH-123 (comma separated)")
 
-	flagName := "task"
-	switchCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	switchCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	switchCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
@@ -0,0 +1,365 @@
+package z
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Sync keeps the finished entries of several devices in one sync document on
+// a target (see SyncTarget). Every entry carries a revision vector counting
+// the changes per device, so that changes made on one device replace older
+// versions, while entries changed on several devices since their last sync
+// are merged deterministically: the last modified version wins, the other
+// one is kept as conflict to be resolved with `zeit sync resolve`.
+
+const SyncSchemaVersion int = 1
+
+const (
+	syncDeviceMetaKey    string = "sync:device"
+	syncStateMetaKey     string = "sync:state"
+	syncConflictsMetaKey string = "sync:conflicts"
+)
+
+type SyncVector map[string]int64
+
+type SyncRecord struct {
+	Entry    *EntryResource `json:"entry,omitempty"`
+	Vector   SyncVector     `json:"vector"`
+	Modified time.Time      `json:"modified"`
+	Deleted  bool           `json:"deleted,omitempty"`
+	Hash     string         `json:"hash,omitempty"`
+}
+
+type SyncDocument struct {
+	Schema  int                   `json:"schema"`
+	Records map[string]SyncRecord `json:"records"`
+}
+
+type SyncConflict struct {
+	ID       string         `json:"id"`
+	Detected time.Time      `json:"detected"`
+	Device   string         `json:"device"`
+	Entry    *EntryResource `json:"entry,omitempty"`
+	Deleted  bool           `json:"deleted,omitempty"`
+}
+
+type SyncStats struct {
+	Pushed    int `json:"pushed"`
+	Pulled    int `json:"pulled"`
+	Erased    int `json:"erased"`
+	Conflicts int `json:"conflicts"`
+}
+
+// syncHash identifies the content of an entry regardless of the user name,
+// which may differ between devices.
+func syncHash(entry Entry) string {
+	entry.ID = ""
+	entry.User = ""
+	entryJson, _ := json.Marshal(entry)
+	sum := sha1.Sum(entryJson)
+	return hex.EncodeToString(sum[:])
+}
+
+func (vector SyncVector) copy() SyncVector {
+	c := make(SyncVector)
+	for device, counter := range vector {
+		c[device] = counter
+	}
+	return c
+}
+
+// descends reports whether the vector includes all changes of the other.
+func (vector SyncVector) descends(other SyncVector) bool {
+	for device, counter := range other {
+		if vector[device] < counter {
+			return false
+		}
+	}
+	return true
+}
+
+func (vector SyncVector) merge(other SyncVector) SyncVector {
+	merged := vector.copy()
+	for device, counter := range other {
+		if merged[device] < counter {
+			merged[device] = counter
+		}
+	}
+	return merged
+}
+
+// device returns the vector's device with the most changes, which is only
+// used to tell where a conflicting version came from.
+func (vector SyncVector) device() string {
+	var device string
+	for d, counter := range vector {
+		if counter > vector[device] || (counter == vector[device] && d > device) {
+			device = d
+		}
+	}
+	return device
+}
+
+// wins reports whether the record wins over a concurrent other one.
+func (record SyncRecord) wins(other SyncRecord) bool {
+	if !record.Modified.Equal(other.Modified) {
+		return record.Modified.After(other.Modified)
+	}
+	return record.Hash > other.Hash
+}
+
+func GetSyncDevice(user string) (string, error) {
+	device, err := database.GetMeta(user, syncDeviceMetaKey)
+	if err != nil || device != "" {
+		return device, err
+	}
+
+	device = NewID()
+	return device, database.SetMeta(user, syncDeviceMetaKey, device)
+}
+
+func getSyncMeta(user string, key string, v interface{}) error {
+	value, err := database.GetMeta(user, key)
+	if err != nil || value == "" {
+		return err
+	}
+
+	if err = json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("could not read %s", key)
+	}
+	return nil
+}
+
+func setSyncMeta(user string, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return database.SetMeta(user, key, string(value))
+}
+
+func GetSyncConflicts(user string) (map[string]SyncConflict, error) {
+	conflicts := make(map[string]SyncConflict)
+	err := getSyncMeta(user, syncConflictsMetaKey, &conflicts)
+	return conflicts, err
+}
+
+func UpdateSyncConflicts(user string, conflicts map[string]SyncConflict) error {
+	return setSyncMeta(user, syncConflictsMetaKey, conflicts)
+}
+
+// localSyncRecords returns the records of the local entries, counting every
+// change since the last sync on the device's revision.
+func localSyncRecords(user string, device string, state map[string]SyncRecord) (map[string]SyncRecord, error) {
+	records := make(map[string]SyncRecord)
+
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return records, err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return records, err
+	}
+
+	now := time.Now().UTC()
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		present[entry.ID] = true
+		if entry.ID == runningEntryId || entry.Finish.IsZero() {
+			continue
+		}
+
+		record, ok := state[entry.ID]
+		hash := syncHash(entry)
+		if !ok || record.Deleted || record.Hash != hash {
+			record.Vector = record.Vector.copy()
+			record.Vector[device]++
+			record.Modified = now
+			record.Hash = hash
+			record.Deleted = false
+		}
+		record.Entry = &EntryResource{ID: entry.ID, Entry: entry}
+		records[entry.ID] = record
+	}
+
+	for id, record := range state {
+		if present[id] {
+			continue
+		}
+		if !record.Deleted {
+			record.Vector = record.Vector.copy()
+			record.Vector[device]++
+			record.Modified = now
+			record.Hash = ""
+			record.Deleted = true
+		}
+		record.Entry = nil
+		records[id] = record
+	}
+
+	return records, nil
+}
+
+func applySyncRecord(user string, id string, record SyncRecord) error {
+	if record.Deleted {
+		if _, err := database.GetEntry(user, id); err != nil {
+			return nil
+		}
+		return database.EraseEntry(user, id)
+	}
+
+	entry := record.Entry.Entry
+	entry.ID = id
+	entry.User = user
+	_, err := database.UpdateEntry(user, entry)
+	return err
+}
+
+// Sync merges the local entries with the sync document of the target and
+// writes the result to both.
+func Sync(user string, target SyncTarget) (SyncStats, error) {
+	var stats SyncStats
+
+	device, err := GetSyncDevice(user)
+	if err != nil {
+		return stats, err
+	}
+
+	state := make(map[string]SyncRecord)
+	if err = getSyncMeta(user, syncStateMetaKey, &state); err != nil {
+		return stats, err
+	}
+
+	conflicts, err := GetSyncConflicts(user)
+	if err != nil {
+		return stats, err
+	}
+
+	local, err := localSyncRecords(user, device, state)
+	if err != nil {
+		return stats, err
+	}
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return stats, err
+	}
+
+	remote, revision, err := target.Pull()
+	if err != nil {
+		return stats, err
+	}
+	if remote.Schema > SyncSchemaVersion {
+		return stats, fmt.Errorf("sync document has schema %d, update zeit to sync with it", remote.Schema)
+	}
+
+	merged := SyncDocument{Schema: SyncSchemaVersion, Records: make(map[string]SyncRecord)}
+	changed := false
+
+	ids := make(map[string]bool)
+	for id := range local {
+		ids[id] = true
+	}
+	for id := range remote.Records {
+		ids[id] = true
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	for _, id := range sorted {
+		l, lok := local[id]
+		r, rok := remote.Records[id]
+
+		if id == runningEntryId {
+			if rok {
+				merged.Records[id] = r
+			}
+			continue
+		}
+
+		var result SyncRecord
+		apply := false
+
+		switch {
+		case !rok:
+			result = l
+			changed = true
+			stats.Pushed++
+		case !lok:
+			result = r
+			apply = true
+		case l.Vector.descends(r.Vector) && r.Vector.descends(l.Vector):
+			result = l
+		case l.Vector.descends(r.Vector):
+			result = l
+			changed = true
+			stats.Pushed++
+		case r.Vector.descends(l.Vector):
+			result = r
+			apply = true
+		default:
+			winner, loser := l, r
+			if r.wins(l) {
+				winner, loser = r, l
+				apply = true
+			} else {
+				stats.Pushed++
+			}
+			result = winner
+			result.Vector = l.Vector.merge(r.Vector)
+			changed = true
+
+			if l.Hash != r.Hash {
+				conflicts[id] = SyncConflict{
+					ID:       id,
+					Detected: time.Now(),
+					Device:   loser.Vector.device(),
+					Entry:    loser.Entry,
+					Deleted:  loser.Deleted,
+				}
+				stats.Conflicts++
+			}
+		}
+
+		if apply {
+			if !lok && r.Deleted {
+				// Never known locally, nothing to erase
+			} else if err = applySyncRecord(user, id, result); err != nil {
+				return stats, err
+			} else if result.Deleted {
+				stats.Erased++
+			} else {
+				stats.Pulled++
+			}
+		}
+
+		merged.Records[id] = result
+	}
+
+	if changed {
+		if err = target.Push(merged, revision); err != nil {
+			return stats, err
+		}
+	}
+
+	for id, record := range merged.Records {
+		record.Entry = nil
+		merged.Records[id] = record
+	}
+	if err = setSyncMeta(user, syncStateMetaKey, merged.Records); err != nil {
+		return stats, err
+	}
+
+	return stats, UpdateSyncConflicts(user, conflicts)
+}
@@ -0,0 +1,54 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync entries with other devices",
+	Long:  "Push local changes of finished entries to the configured sync target (a zeit server, WebDAV or S3) and pull the changes of other devices. Entries changed on several devices since their last sync are merged deterministically, the version that didn't win is kept as conflict to be resolved with 'zeit sync resolve'.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		if viper.GetString("remote.url") != "" {
+			return fmt.Errorf("zeit sync can't be used with remote.url configured, all devices already share the server's database")
+		}
+
+		target, err := GetSyncTarget()
+		if err != nil {
+			return err
+		}
+
+		if err := AutoBackup(user, "sync"); err != nil {
+			return err
+		}
+
+		stats, err := Sync(user, target)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(stats)
+		}
+
+		fmt.Printf("%s synced: %d pushed, %d pulled, %d erased\n", CharInfo, stats.Pushed, stats.Pulled, stats.Erased)
+		if stats.Conflicts > 0 {
+			fmt.Printf("%s %s conflicting changes, see %s\n",
+				CharMore,
+				color.FgLightRed.Render(stats.Conflicts),
+				color.FgLightWhite.Render("zeit sync conflicts"),
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+}
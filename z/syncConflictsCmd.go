@@ -0,0 +1,66 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var syncConflictsCmd = &cobra.Command{
+	Use:         "conflicts",
+	Short:       "List sync conflicts",
+	Long:        "List the entries that were changed on several devices, showing the version kept and the conflicting one.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		conflicts, err := GetSyncConflicts(user)
+		if err != nil {
+			return err
+		}
+
+		var list []SyncConflict
+		for _, conflict := range conflicts {
+			list = append(list, conflict)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Detected.Before(list[j].Detected) })
+
+		if IsOutputJSON() {
+			if list == nil {
+				list = []SyncConflict{}
+			}
+			return printJSON(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Printf("%s no sync conflicts\n", CharInfo)
+			return nil
+		}
+
+		for _, conflict := range list {
+			fmt.Printf("%s %s\n", CharMore, color.FgLightWhite.Render(conflict.ID))
+
+			entry, err := database.GetEntry(user, conflict.ID)
+			if err != nil {
+				fmt.Printf("kept: %s\n", color.FgGray.Render("erased"))
+			} else {
+				fmt.Printf("kept:\n%s\n", GetEntryOutput(entry, true))
+			}
+
+			if conflict.Deleted {
+				fmt.Printf("conflicting: %s\n", color.FgGray.Render("erased on device "+conflict.Device))
+			} else {
+				conflictingEntry := conflict.Entry.Entry
+				conflictingEntry.ID = conflict.ID
+				fmt.Printf("conflicting, from device %s:\n%s\n", conflict.Device, GetEntryOutput(conflictingEntry, true))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncConflictsCmd)
+}
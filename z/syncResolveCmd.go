@@ -0,0 +1,89 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncResolveKeep bool
+	syncResolveTake bool
+)
+
+var syncResolveCmd = &cobra.Command{
+	Use:   "resolve ([flags]) [id]",
+	Short: "Resolve a sync conflict",
+	Long:  "Resolve a sync conflict by editing the conflicting version of the entry in your $EDITOR, which then replaces the kept one. Use --keep to dismiss the conflicting version or --take to use it as is. The resolution is pushed with the next sync.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+		id := args[0]
+
+		if syncResolveKeep && syncResolveTake {
+			return errors.New("Cannot specify both --keep and --take")
+		}
+
+		conflicts, err := GetSyncConflicts(user)
+		if err != nil {
+			return err
+		}
+
+		conflict, ok := conflicts[id]
+		if !ok {
+			return fmt.Errorf("no sync conflict for entry %s", color.FgLightWhite.Render(id))
+		}
+
+		_, getErr := database.GetEntry(user, id)
+		exists := getErr == nil
+
+		switch {
+		case syncResolveKeep:
+		case conflict.Deleted:
+			if syncResolveTake && exists {
+				if err = database.EraseEntry(user, id); err != nil {
+					return err
+				}
+			} else if !syncResolveTake {
+				return errors.New("the conflicting version was erased, use --keep or --take")
+			}
+		default:
+			conflictingEntry := conflict.Entry.Entry
+			conflictingEntry.ID = id
+			conflictingEntry.User = user
+
+			if syncResolveTake || !exists {
+				if _, err = database.UpdateEntry(user, conflictingEntry); err != nil {
+					return err
+				}
+			}
+
+			if !syncResolveTake {
+				modifiedEntry, err := editInEditor(NewEditableEntry(conflictingEntry))
+				if err != nil {
+					return err
+				}
+
+				if err := validateAndUpdateEntry(user, id, modifiedEntry); err != nil {
+					return err
+				}
+			}
+		}
+
+		delete(conflicts, id)
+		if err = UpdateSyncConflicts(user, conflicts); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s sync conflict of entry %s resolved\n", CharInfo, color.FgLightWhite.Render(id))
+		return nil
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncResolveCmd)
+	syncResolveCmd.Flags().BoolVar(&syncResolveKeep, "keep", false, "Keep the current version and dismiss the conflicting one")
+	syncResolveCmd.Flags().BoolVar(&syncResolveTake, "take", false, "Replace the current version with the conflicting one")
+}
@@ -0,0 +1,293 @@
+package z
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const syncMetaKey string = "sync"
+
+var ErrSyncConflict = errors.New("the sync document was changed by another device during the sync, run zeit sync again")
+
+// SyncTarget stores the sync document. Pull returns it along with a revision,
+// which Push uses to refuse overwriting changes of another device made in
+// between.
+type SyncTarget interface {
+	Pull() (SyncDocument, string, error)
+	Push(doc SyncDocument, revision string) error
+}
+
+func SyncTargetTypes() []string {
+	return []string{
+		SyncTargetZeit,
+		SyncTargetWebDAV,
+		SyncTargetS3,
+	}
+}
+
+// GetSyncTarget returns the target configured as `sync.type` and `sync.url`.
+func GetSyncTarget() (SyncTarget, error) {
+	targetURL := viper.GetString("sync.url")
+	if targetURL == "" {
+		return nil, errors.New("no sync.url configured")
+	}
+	if _, err := url.Parse(targetURL); err != nil {
+		return nil, fmt.Errorf("invalid sync.url: %v", err)
+	}
+
+	switch targetType := strings.ToLower(viper.GetString("sync.type")); targetType {
+	case "", SyncTargetZeit:
+		remote, err := InitRemote(targetURL, os.ExpandEnv(viper.GetString("sync.token")))
+		if err != nil {
+			return nil, err
+		}
+		return &zeitSyncTarget{remote: remote}, nil
+	case SyncTargetWebDAV:
+		return &httpSyncTarget{
+			URL: targetURL,
+			sign: func(req *http.Request, payload []byte) {
+				if username := viper.GetString("sync.username"); username != "" {
+					req.SetBasicAuth(username, os.ExpandEnv(viper.GetString("sync.password")))
+				}
+			},
+		}, nil
+	case SyncTargetS3:
+		credentials := s3Credentials{
+			Region:       viper.GetString("sync.region"),
+			AccessKey:    os.ExpandEnv(viper.GetString("sync.accessKey")),
+			SecretKey:    os.ExpandEnv(viper.GetString("sync.secretKey")),
+			SessionToken: os.ExpandEnv(viper.GetString("sync.sessionToken")),
+		}
+		if credentials.Region == "" {
+			credentials.Region = "us-east-1"
+		}
+		if credentials.AccessKey == "" || credentials.SecretKey == "" {
+			return nil, errors.New("sync.accessKey and sync.secretKey are required for s3")
+		}
+		return &httpSyncTarget{URL: targetURL, sign: credentials.sign}, nil
+	default:
+		return nil, fmt.Errorf("unknown sync.type '%s', possible values: %s", targetType, strings.Join(SyncTargetTypes(), ", "))
+	}
+}
+
+func parseSyncDocument(content []byte) (SyncDocument, error) {
+	doc := SyncDocument{Schema: SyncSchemaVersion, Records: make(map[string]SyncRecord)}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return doc, nil
+	}
+
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return doc, fmt.Errorf("could not read the sync document: %v", err)
+	}
+	if doc.Records == nil {
+		doc.Records = make(map[string]SyncRecord)
+	}
+	return doc, nil
+}
+
+func contentRevision(content []byte) string {
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// zeitSyncTarget keeps the sync document as meta value on a zeit server, for
+// the user its token belongs to. As the server has no conditional updates,
+// the document is compared right before it's replaced.
+type zeitSyncTarget struct {
+	remote *Remote
+}
+
+func (target *zeitSyncTarget) Pull() (SyncDocument, string, error) {
+	value, err := target.remote.GetMeta("", syncMetaKey)
+	if err != nil {
+		return SyncDocument{}, "", err
+	}
+
+	doc, err := parseSyncDocument([]byte(value))
+	return doc, contentRevision([]byte(value)), err
+}
+
+func (target *zeitSyncTarget) Push(doc SyncDocument, revision string) error {
+	value, err := target.remote.GetMeta("", syncMetaKey)
+	if err != nil {
+		return err
+	}
+	if contentRevision([]byte(value)) != revision {
+		return ErrSyncConflict
+	}
+
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return target.remote.SetMeta("", syncMetaKey, string(content))
+}
+
+// httpSyncTarget keeps the sync document as a file on a WebDAV server or in
+// an S3 bucket, using the ETag for conditional updates.
+type httpSyncTarget struct {
+	URL  string
+	sign func(req *http.Request, payload []byte)
+}
+
+func (target *httpSyncTarget) request(method string, payload []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, target.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	target.sign(req, payload)
+
+	client := http.Client{Timeout: 60 * time.Second}
+	return client.Do(req)
+}
+
+func (target *httpSyncTarget) Pull() (SyncDocument, string, error) {
+	res, err := target.request(http.MethodGet, nil, nil)
+	if err != nil {
+		return SyncDocument{}, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		doc, err := parseSyncDocument(nil)
+		return doc, "", err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return SyncDocument{}, "", fmt.Errorf("GET %s: %s", target.URL, res.Status)
+	}
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return SyncDocument{}, "", err
+	}
+
+	revision := res.Header.Get("ETag")
+	if revision == "" {
+		revision = contentRevision(content)
+	}
+
+	doc, err := parseSyncDocument(content)
+	return doc, revision, err
+}
+
+func (target *httpSyncTarget) Push(doc SyncDocument, revision string) error {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	if revision == "" {
+		header.Set("If-None-Match", "*")
+	} else if strings.HasPrefix(revision, `"`) || strings.HasPrefix(revision, `W/"`) {
+		header.Set("If-Match", revision)
+	}
+
+	res, err := target.request(http.MethodPut, content, header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPreconditionFailed || res.StatusCode == http.StatusConflict {
+		return ErrSyncConflict
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: %s", target.URL, res.Status)
+	}
+	return nil
+}
+
+type s3Credentials struct {
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func awsURIEncode(path string) string {
+	var sb strings.Builder
+	for _, b := range []byte(path) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || strings.IndexByte("-_.~/", b) >= 0 {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// sign signs the request using AWS Signature Version 4.
+func (credentials *s3Credentials) sign(req *http.Request, payload []byte) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	payloadSum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(payloadSum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + credentials.SessionToken + "\n"
+	}
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(path),
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := day + "/" + credentials.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretKey), day)
+	key = hmacSHA256(key, credentials.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKey, scope, signedHeaders, signature))
+}
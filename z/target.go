@@ -0,0 +1,93 @@
+package z
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Targets are the hours to work per weekday: `targets.day` on Monday to
+// Friday, unless `targets.days` sets a weekday differently:
+//
+//	targets:
+//	  day: 8h
+//	  days:
+//	    friday: 6h
+type Targets struct {
+	Days [7]time.Duration
+}
+
+// parseTarget reads a target like 8h, 7h30m or 7.5 (hours).
+func parseTarget(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if hours, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), strings.TrimSpace(name)) {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// GetTargets returns the configured targets.
+func GetTargets() (Targets, error) {
+	var targets Targets
+
+	if day := viper.GetString("targets.day"); day != "" {
+		target, err := parseTarget(day)
+		if err != nil || target < 0 {
+			return targets, fmt.Errorf("invalid targets.day '%s', use e.g. 8h or 7h30m", day)
+		}
+		for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+			targets.Days[weekday] = target
+		}
+	}
+
+	for name, value := range viper.GetStringMapString("targets.days") {
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			return targets, fmt.Errorf("unknown weekday '%s' in targets.days", name)
+		}
+		target, err := parseTarget(value)
+		if err != nil || target < 0 {
+			return targets, fmt.Errorf("invalid target '%s' for %s, use e.g. 8h or 7h30m", value, name)
+		}
+		targets.Days[weekday] = target
+	}
+
+	return targets, nil
+}
+
+// Day returns the target of the day t is on.
+func (targets *Targets) Day(t time.Time) time.Duration {
+	return targets.Days[t.Weekday()]
+}
+
+// IsSet reports whether any target is configured.
+func (targets *Targets) IsSet() bool {
+	for _, target := range targets.Days {
+		if target > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fmtDelta formats the difference to a target with its sign, rounded to the
+// minute.
+func fmtDelta(delta time.Duration) string {
+	delta = delta.Round(time.Minute)
+	if delta < 0 {
+		return "-" + fmtDuration(-delta)
+	}
+	return "+" + fmtDuration(delta)
+}
@@ -2,44 +2,197 @@ package z
 
 import (
 	"fmt"
-	"os"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/mrusme/zeit/pkg/zeit"
 	"github.com/spf13/viper"
 )
 
-type Task struct {
-	Name          string `json:"name,omitempty"`
-	GitRepository string `json:"gitRepository,omitempty"`
-}
+type Task = zeit.Task
 
-func listEntries() []Entry {
+func listEntries() ([]Entry, error) {
 	user := GetCurrentUser()
 
-	entries, err := database.ListEntries(user)
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	sinceTime, untilTime := ParseSinceUntil(since, until, listRange)
+	entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+	if err != nil {
+		return nil, err
+	}
 
-	var filteredEntries []Entry
-	filteredEntries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+	filteredEntries, err := filterListEntries(user, entries, sinceTime, untilTime)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	if listOnlyProjectsAndTasks || listOnlyTasks {
-		printProjects(filteredEntries)
-		return nil
+		return nil, printProjects(filteredEntries)
+	}
+	return filteredEntries, nil
+}
+
+// listLatestEntries returns the page of entries selected by --offset and
+// --limit, newest first unless --reverse is given.
+func listLatestEntries() ([]Entry, error) {
+	if listReverse || listOnlyProjectsAndTasks || listOnlyTasks {
+		entries, err := listEntries()
+		if err != nil {
+			return nil, err
+		}
+		return pageEntries(entries, listOffset, listLimit), nil
+	}
+
+	user := GetCurrentUser()
+
+	sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ListLatestEntries(user, sinceTime, untilTime, func(entry Entry) bool {
+		filteredEntries, err := filterListEntries(user, []Entry{entry}, sinceTime, untilTime)
+		return err == nil && len(filteredEntries) == 1
+	}, listOffset, listLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// filterListEntries returns the entries matching all filters given to list.
+func filterListEntries(user string, entries []Entry, sinceTime time.Time, untilTime time.Time) ([]Entry, error) {
+	filteredEntries, err := GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attendees) > 0 {
+		filteredEntries = filterEntriesByAttendees(filteredEntries, zeit.ParseAttendees(attendees))
+	}
+
+	if len(tags) > 0 {
+		filteredEntries = filterEntriesByTags(filteredEntries, zeit.ParseTags(tags))
+	}
+
+	if len(references) > 0 {
+		filteredEntries = filterEntriesByReferences(filteredEntries, zeit.ParseReferences(references))
+	}
+
+	if listSearch != "" {
+		filteredEntries = filterEntriesByNotes(filteredEntries, listSearch)
+	}
+
+	if listRunning {
+		filteredEntries = filterRunningEntries(filteredEntries)
+	}
+
+	if listBillable {
+		filteredEntries = filterBillableEntries(user, filteredEntries)
+	}
+
+	return filteredEntries, nil
+}
+
+func filterEntriesByAttendees(entries []Entry, attendees []string) []Entry {
+	var filteredEntries []Entry
+
+	for _, entry := range entries {
+		for _, attendee := range attendees {
+			if entry.HasAttendee(attendee) {
+				filteredEntries = append(filteredEntries, entry)
+				break
+			}
+		}
+	}
+
+	return filteredEntries
+}
+
+func filterEntriesByTags(entries []Entry, tags []string) []Entry {
+	var filteredEntries []Entry
+
+	for _, entry := range entries {
+		for _, tag := range tags {
+			if entry.HasTag(tag) {
+				filteredEntries = append(filteredEntries, entry)
+				break
+			}
+		}
 	}
+
 	return filteredEntries
 }
 
-func printProjects(entries []Entry) {
+func filterEntriesByReferences(entries []Entry, references []string) []Entry {
+	var filteredEntries []Entry
+
+	for _, entry := range entries {
+		for _, reference := range references {
+			if entry.HasReference(reference) {
+				filteredEntries = append(filteredEntries, entry)
+				break
+			}
+		}
+	}
+
+	return filteredEntries
+}
+
+func filterEntriesByNotes(entries []Entry, search string) []Entry {
+	var filteredEntries []Entry
+
+	search = strings.ToLower(search)
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Notes), search) {
+			filteredEntries = append(filteredEntries, entry)
+		}
+	}
+
+	return filteredEntries
+}
+
+func filterRunningEntries(entries []Entry) []Entry {
+	var filteredEntries []Entry
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			filteredEntries = append(filteredEntries, entry)
+		}
+	}
+
+	return filteredEntries
+}
+
+func filterBillableEntries(user string, entries []Entry) []Entry {
+	var filteredEntries []Entry
+
+	projects := make(map[string]Project)
+	for _, entry := range entries {
+		project, ok := projects[entry.Project]
+		if !ok {
+			project, _ = database.GetProject(user, entry.Project)
+			projects[entry.Project] = project
+		}
+		if project.Billable {
+			filteredEntries = append(filteredEntries, entry)
+		}
+	}
+
+	return filteredEntries
+}
+
+func printProjects(entries []Entry) error {
 	projectsAndTasks, _ := listProjectsAndTasks(entries)
+	if IsOutputJSON() {
+		return printProjectsJSON(projectsAndTasks)
+	}
+
 	for project := range projectsAndTasks {
 		if listOnlyProjectsAndTasks && !listOnlyTasks {
 			fmt.Printf("%s %s\n", CharMore, project)
@@ -57,6 +210,30 @@ func printProjects(entries []Entry) {
 			}
 		}
 	}
+	return nil
+}
+
+// printProjectsJSON prints the tasks by project, or only the tasks if
+// --only-tasks is given, sorted by name.
+func printProjectsJSON(projectsAndTasks map[string]map[string]bool) error {
+	projects := make(map[string][]string)
+	allTasks := []string{}
+	for project, tasks := range projectsAndTasks {
+		projects[project] = []string{}
+		for task := range tasks {
+			projects[project] = append(projects[project], task)
+			if !zeit.ContainsFold(allTasks, task) {
+				allTasks = append(allTasks, task)
+			}
+		}
+		sort.Strings(projects[project])
+	}
+
+	if listOnlyTasks {
+		sort.Strings(allTasks)
+		return printJSON(allTasks)
+	}
+	return printJSON(projects)
 }
 
 func listProjectsAndTasks(entries []Entry) (map[string]map[string]bool, []string) {
@@ -79,18 +256,71 @@ func listProjectsAndTasks(entries []Entry) (map[string]map[string]bool, []string
 	return projectsAndTasks, allTasks
 }
 
-func trackTask() {
+// errAlreadyRunning is returned when tracking while an activity is running.
+// The text output shows it as a notice rather than as an error.
+func errAlreadyRunning() error {
+	if IsOutputJSON() {
+		return &ValidationError{Code: ValidationOverlap, Message: "a task is already running"}
+	}
+	fmt.Printf("%s a task is already running\n", CharTrack)
+	return exitWithCode(ExitOverlap)
+}
+
+// errNotRunning is returned when finishing while no activity is running.
+func errNotRunning() error {
+	if IsOutputJSON() {
+		return &NotFoundError{Message: "not running"}
+	}
+	fmt.Printf("%s not running\n", CharFinish)
+	return exitWithCode(ExitNotFound)
+}
+
+func trackTask() (Entry, error) {
 	user := GetCurrentUser()
 
 	runningEntryId, err := database.GetRunningEntryId(user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
 	if runningEntryId != "" {
-		fmt.Printf("%s a task is already running\n", CharTrack)
-		os.Exit(1)
+		return Entry{}, errAlreadyRunning()
+	}
+
+	var taskwarriorReference string
+	if trackTaskwarrior != "" {
+		twTask, err := GetTaskwarriorTask(trackTaskwarrior)
+		if err != nil {
+			return Entry{}, err
+		}
+		if project == "" {
+			project = twTask.Project
+		}
+		if task == "" {
+			task = twTask.Description
+		}
+		taskwarriorReference = TaskwarriorReference(twTask.UUID)
+	}
+
+	var gitTicket string
+	if trackFromGit {
+		gitContext, err := GetGitContext("")
+		if err != nil {
+			return Entry{}, err
+		}
+		if project == "" {
+			project = gitContext.Project()
+		}
+		if task == "" {
+			task = gitContext.Task()
+		}
+		gitTicket = gitContext.Ticket
+	}
+
+	if project == "" && task == "" && IsPickerEnabled() {
+		if project, task, err = PickProjectAndTask(user); err != nil {
+			return Entry{}, err
+		}
 	}
 
 	if project == "" && viper.GetString("project.default") != "" {
@@ -98,60 +328,97 @@ func trackTask() {
 	}
 
 	if project == "" && viper.GetBool("project.mandatory") {
-		fmt.Println("project is mandatory but missing")
-		os.Exit(1)
+		return Entry{}, NewMandatoryError("project")
 	}
 
 	if task == "" && viper.GetBool("task.mandatory") {
-		fmt.Println("task is mandatory but missing")
-		os.Exit(1)
+		return Entry{}, NewMandatoryError("task")
+	}
+
+	if err = CheckSimilarNames(user, &project, &task, create); err != nil {
+		return Entry{}, err
 	}
 
 	newEntry, err := NewEntry("", begin, finish, project, task, user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
 	if notes != "" {
 		newEntry.Notes = notes
 	}
 
+	newEntry.Attendees = zeit.ParseAttendees(attendees)
+	newEntry.Tags = zeit.ParseTags(tags)
+	newEntry.References = zeit.ParseReferences(references)
+	if taskwarriorReference != "" && !zeit.ContainsFold(newEntry.References, taskwarriorReference) {
+		newEntry.References = append(newEntry.References, taskwarriorReference)
+	}
+	if gitTicket != "" && !zeit.ContainsFold(newEntry.References, gitTicket) {
+		newEntry.References = append(newEntry.References, gitTicket)
+	}
+
+	if err = ValidateProjectRules(user, newEntry); err != nil {
+		return Entry{}, err
+	}
+
 	isRunning := newEntry.Finish.IsZero()
 
-	_, err = database.AddEntry(user, newEntry, isRunning)
+	var warmStart WarmStart
+	var isWarmStart bool
+	if isRunning {
+		if warmStart, isWarmStart, err = GetWarmStart(user, newEntry.Begin); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	if err = RunPreHooks(HookPreTrack, newEntry); err != nil {
+		return Entry{}, err
+	}
+
+	newEntry.ID, err = database.AddEntry(user, newEntry, isRunning)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
-	fmt.Print(newEntry.GetOutputForTrack(isRunning, false))
+	if !IsOutputJSON() {
+		fmt.Print(GetEntryOutputForTrack(newEntry, isRunning, false))
+		WarnTagBudgets(user, newEntry)
+		WarnProjectBudget(user, newEntry)
+		if isWarmStart {
+			PrintWarmStart(warmStart)
+		}
+	}
+	RunPostHooks(HookPostTrack, newEntry)
+	return newEntry, nil
 }
 
-func finishTask(mode int) {
+// finishTask finishes the running entry and returns it, along with the idle
+// periods split off of it.
+func finishTask(mode int) (Entry, []Entry, error) {
 	user := GetCurrentUser()
 
 	runningEntryId, err := database.GetRunningEntryId(user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, nil, err
 	}
 
 	if runningEntryId == "" {
-		fmt.Printf("%s not running\n", CharFinish)
-		os.Exit(1)
+		return Entry{}, nil, errNotRunning()
 	}
 
 	runningEntry, err := database.GetEntry(user, runningEntryId)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, nil, err
+	}
+
+	if err = CheckSimilarNames(user, &project, &task, create); err != nil {
+		return Entry{}, nil, err
 	}
 
 	tmpEntry, err := NewEntry(runningEntry.ID, begin, finish, project, task, user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, nil, err
 	}
 
 	if begin != "" {
@@ -165,24 +432,112 @@ func finishTask(mode int) {
 	}
 
 	if mode == FinishWithMetadata {
-		finishTaskMetadata(user, &runningEntry, &tmpEntry)
+		if err := finishTaskMetadata(user, &runningEntry, &tmpEntry); err != nil {
+			return Entry{}, nil, err
+		}
+	}
+
+	// Idle time is only looked for when finishing now
+	var parts []Entry
+	if finish == "" {
+		action := finishIdle
+		if action == "" {
+			action = GetIdleAction()
+		}
+		entries, err := ResolveIdle(runningEntry, action)
+		if err != nil {
+			return Entry{}, nil, err
+		}
+		runningEntry, parts = entries[0], entries[1:]
 	}
 
 	if !runningEntry.IsFinishedAfterBegan() {
-		fmt.Printf("%s %+v\n", CharError, "beginning time of tracking cannot be after finish time")
-		os.Exit(1)
+		return Entry{}, nil, NewFinishBeforeBeginError(runningEntry)
+	}
+
+	if err = ValidateProjectRules(user, runningEntry); err != nil {
+		return Entry{}, nil, err
+	}
+
+	if err = RunPreHooks(HookPreFinish, runningEntry); err != nil {
+		return Entry{}, nil, err
 	}
 
 	_, err = database.FinishEntry(user, runningEntry)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, nil, err
+	}
+
+	for _, part := range parts {
+		if _, err = database.AddEntry(user, part, false); err != nil {
+			return Entry{}, nil, err
+		}
 	}
 
-	fmt.Print(runningEntry.GetOutputForFinish())
+	if !IsOutputJSON() {
+		fmt.Print(GetEntryOutputForFinish(runningEntry))
+		for _, part := range parts {
+			fmt.Print(GetEntryOutputForTrack(part, false, false))
+		}
+		WarnTagBudgets(user, runningEntry)
+		WarnProjectBudget(user, runningEntry)
+	}
+	RunPostHooks(HookPostFinish, runningEntry)
+	return runningEntry, parts, nil
+}
+
+// trackRunningEntry begins tracking the entry the way trackTask does, but
+// returning errors instead of exiting and without printing anything.
+func trackRunningEntry(user string, entry Entry) (Entry, error) {
+	var err error
+
+	if err = ValidateProjectRules(user, entry); err != nil {
+		return entry, err
+	}
+	if err = RunPreHooks(HookPreTrack, entry); err != nil {
+		return entry, err
+	}
+	if entry.ID, err = database.AddEntry(user, entry, true); err != nil {
+		return entry, err
+	}
+	RunPostHooks(HookPostTrack, entry)
+
+	return entry, nil
+}
+
+// finishRunningEntry finishes the entry now the way finishTask does, but
+// returning errors instead of exiting and without printing anything.
+func finishRunningEntry(user string, id string) (Entry, error) {
+	return finishRunningEntryAt(user, id, time.Now())
 }
 
-func finishTaskMetadata(user string, runningEntry *Entry, tmpEntry *Entry) {
+// finishRunningEntryAt is finishRunningEntry finishing the entry at a given
+// time.
+func finishRunningEntryAt(user string, id string, finish time.Time) (Entry, error) {
+	entry, err := database.GetEntry(user, id)
+	if err != nil {
+		return entry, err
+	}
+	entry.Finish = finish
+
+	if !entry.IsFinishedAfterBegan() {
+		return entry, NewFinishBeforeBeginError(entry)
+	}
+	if err = ValidateProjectRules(user, entry); err != nil {
+		return entry, err
+	}
+	if err = RunPreHooks(HookPreFinish, entry); err != nil {
+		return entry, err
+	}
+	if _, err = database.FinishEntry(user, entry); err != nil {
+		return entry, err
+	}
+	RunPostHooks(HookPostFinish, entry)
+
+	return entry, nil
+}
+
+func finishTaskMetadata(user string, runningEntry *Entry, tmpEntry *Entry) error {
 	if project != "" {
 		runningEntry.Project = tmpEntry.Project
 	}
@@ -195,23 +550,36 @@ func finishTaskMetadata(user string, runningEntry *Entry, tmpEntry *Entry) {
 		runningEntry.Notes = fmt.Sprintf("%s\n%s", runningEntry.Notes, notes)
 	}
 
+	if len(attendees) > 0 {
+		runningEntry.Attendees = zeit.ParseAttendees(attendees)
+	}
+
+	if len(tags) > 0 {
+		runningEntry.Tags = zeit.ParseTags(tags)
+	}
+
+	if len(references) > 0 {
+		runningEntry.References = zeit.ParseReferences(references)
+	}
+
 	if runningEntry.Task != "" {
 		task, err := database.GetTask(user, runningEntry.Task)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
-		taskGit(&task, runningEntry)
+		if err := taskGit(&task, runningEntry); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func taskGit(task *Task, runningEntry *Entry) {
+func taskGit(task *Task, runningEntry *Entry) error {
 	if task.GitRepository != "" && task.GitRepository != "-" {
 		stdout, stderr, err := GetGitLog(task.GitRepository, runningEntry.Begin, runningEntry.Finish)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
 		if stderr == "" {
@@ -220,27 +588,28 @@ func taskGit(task *Task, runningEntry *Entry) {
 			fmt.Printf("%s notes were not imported: %+v\n", CharError, stderr)
 		}
 	}
+	return nil
 }
 
-func resumeTask(index int) {
+func resumeTask(index int) (Entry, error) {
 	user := GetCurrentUser()
 
 	entries, err := database.ListEntries(user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
+	}
+	if len(entries) < index {
+		return Entry{}, &NotFoundError{Message: "no activity to resume"}
 	}
 	lastEntry := entries[len(entries)-index]
 
 	runningEntryId, err := database.GetRunningEntryId(user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
 	if runningEntryId != "" {
-		fmt.Printf("%s a task is already running\n", CharTrack)
-		os.Exit(1)
+		return Entry{}, errAlreadyRunning()
 	}
 
 	project = lastEntry.Project
@@ -248,21 +617,31 @@ func resumeTask(index int) {
 
 	newEntry, err := NewEntry("", begin, finish, project, task, user)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
 	if lastEntry.Notes != "" {
 		newEntry.Notes = lastEntry.Notes
 	}
 
+	newEntry.Attendees = lastEntry.Attendees
+	newEntry.Tags = lastEntry.Tags
+	newEntry.References = lastEntry.References
+
 	isRunning := newEntry.Finish.IsZero()
 
-	_, err = database.AddEntry(user, newEntry, isRunning)
+	if err = RunPreHooks(HookPreTrack, newEntry); err != nil {
+		return Entry{}, err
+	}
+
+	newEntry.ID, err = database.AddEntry(user, newEntry, isRunning)
 	if err != nil {
-		fmt.Printf("%s %+v\n", CharError, err)
-		os.Exit(1)
+		return Entry{}, err
 	}
 
-	fmt.Print(newEntry.GetOutputForTrack(isRunning, false))
+	if !IsOutputJSON() {
+		fmt.Print(GetEntryOutputForTrack(newEntry, isRunning, false))
+	}
+	RunPostHooks(HookPostTrack, newEntry)
+	return newEntry, nil
 }
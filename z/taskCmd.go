@@ -2,7 +2,6 @@ package z
 
 import (
 	"fmt"
-	"os"
 	// "time"
 	"github.com/spf13/cobra"
 	// "github.com/gookit/color"
@@ -15,14 +14,13 @@ var taskCmd = &cobra.Command{
 	Short: "Task settings",
 	Long:  "Configure task settings.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 		taskName := args[0]
 
 		task, err := database.GetTask(user, taskName)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
 		task.Name = taskName
@@ -33,12 +31,15 @@ var taskCmd = &cobra.Command{
 
 		err = database.UpdateTask(user, taskName, task)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(task)
 		}
 
 		fmt.Printf("%s task updated\n", CharInfo)
-		return
+		return nil
 	},
 }
 
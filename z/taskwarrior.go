@@ -0,0 +1,318 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// Taskwarrior tasks are linked to activities by a reference like
+// `taskwarrior:<uuid>`. `task start` and `task stop` are passed on to zeit by
+// an on-modify hook running `zeit taskwarrior on-modify`, and the total time
+// tracked on a task is written back to it as a duration UDA.
+
+const taskwarriorReferencePrefix string = "taskwarrior:"
+
+const defaultTaskwarriorUDA string = "zeit"
+
+const taskwarriorHookName string = "on-modify.zeit"
+
+// inTaskwarriorHook is set while handling a modification from within the
+// on-modify hook, during which the task itself must not be modified.
+var inTaskwarriorHook bool
+
+type TaskwarriorTask struct {
+	UUID        string `json:"uuid"`
+	Description string `json:"description"`
+	Project     string `json:"project"`
+	Status      string `json:"status"`
+	Start       string `json:"start"`
+}
+
+func getTaskwarriorCommand() string {
+	if command := viper.GetString("taskwarrior.command"); command != "" {
+		return command
+	}
+	return "task"
+}
+
+// GetTaskwarriorUDA returns the name of the duration UDA the tracked time is
+// written to, taskwarrior.uda or zeit.
+func GetTaskwarriorUDA() string {
+	if uda := viper.GetString("taskwarrior.uda"); uda != "" {
+		return uda
+	}
+	return defaultTaskwarriorUDA
+}
+
+// runTaskwarrior runs task with hooks and confirmations turned off.
+func runTaskwarrior(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(getTaskwarriorCommand(), append([]string{"rc.hooks=off", "rc.confirmation=off", "rc.verbose=nothing"}, args...)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("%s: %v %s", getTaskwarriorCommand(), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// GetTaskwarriorTask reads the task with the UUID, or a short UUID or ID,
+// from Taskwarrior.
+func GetTaskwarriorTask(uuid string) (TaskwarriorTask, error) {
+	var tasks []TaskwarriorTask
+
+	output, err := runTaskwarrior(uuid, "export")
+	if err != nil {
+		return TaskwarriorTask{}, err
+	}
+	if err = json.Unmarshal(output, &tasks); err != nil {
+		return TaskwarriorTask{}, fmt.Errorf("could not read the Taskwarrior task: %v", err)
+	}
+	if len(tasks) != 1 {
+		return TaskwarriorTask{}, fmt.Errorf("no single Taskwarrior task '%s'", uuid)
+	}
+
+	return tasks[0], nil
+}
+
+func TaskwarriorReference(uuid string) string {
+	return taskwarriorReferencePrefix + uuid
+}
+
+// GetTaskwarriorUUID returns the UUID of the Taskwarrior task the entry was
+// tracked on, if any.
+func GetTaskwarriorUUID(references []string) (string, bool) {
+	for _, reference := range references {
+		if uuid, ok := strings.CutPrefix(reference, taskwarriorReferencePrefix); ok {
+			return uuid, true
+		}
+	}
+	return "", false
+}
+
+// fmtTaskwarriorDuration formats the duration the ISO 8601 way Taskwarrior
+// stores durations.
+func fmtTaskwarriorDuration(duration time.Duration) string {
+	minutes := int(duration.Minutes())
+	if minutes == 0 {
+		return "PT0S"
+	}
+	return fmt.Sprintf("PT%dH%dM", minutes/60, minutes%60)
+}
+
+// GetTaskwarriorTime sums up the time tracked on the Taskwarrior task.
+func GetTaskwarriorTime(user string, uuid string) (time.Duration, error) {
+	entries, err := database.ListEntries(user)
+	if err != nil {
+		return 0, err
+	}
+
+	var total time.Duration
+	reference := TaskwarriorReference(uuid)
+	for _, entry := range entries {
+		if !entry.Finish.IsZero() && zeit.ContainsFold(entry.References, reference) {
+			total += entry.Finish.Sub(entry.Begin)
+		}
+	}
+	return total, nil
+}
+
+// TaskwarriorTimeUpdate returns the update writing the total time tracked on
+// the Taskwarrior task of a finished or edited activity back to the task, or
+// nil if there is none. The total is read from the database right away, the
+// update can run once the database was released.
+func TaskwarriorTimeUpdate(event string, payload interface{}) (func() error, error) {
+	entry, ok := payload.(HookEntry)
+	if !ok || inTaskwarriorHook {
+		return nil, nil
+	}
+	if event != HookPostFinish && event != HookPostEdit && (event != HookPostTrack || entry.Finish == nil) {
+		return nil, nil
+	}
+
+	uuid, ok := GetTaskwarriorUUID(entry.References)
+	if !ok {
+		return nil, nil
+	}
+
+	total, err := GetTaskwarriorTime(entry.User, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := runTaskwarrior(uuid, "modify", GetTaskwarriorUDA()+":"+fmtTaskwarriorDuration(total))
+		return err
+	}, nil
+}
+
+// GetTaskwarriorHooksDir returns the directory Taskwarrior runs hooks from,
+// as configured in hooks.location of the taskrc or ~/.task/hooks.
+func GetTaskwarriorHooksDir() (string, error) {
+	if output, err := runTaskwarrior("_get", "rc.hooks.location"); err == nil {
+		if location := strings.TrimSpace(string(output)); location != "" {
+			return location, nil
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".task", "hooks"), nil
+}
+
+// TaskwarriorHookScript returns the on-modify hook passing modifications on
+// to the zeit binary running.
+func TaskwarriorHookScript() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("#!/bin/sh\n# Tracks the time of started Taskwarrior tasks in zeit\nexec %s taskwarrior on-modify\n", shellQuote(executable)), nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// TaskwarriorOnModify handles a modification as an on-modify hook: it reads
+// the original and the modified task from stdin, tracks the task in zeit
+// when it was started and finishes it when it was stopped or completed. The
+// modified task is written to stdout, with the time tracked on it after
+// stopping, followed by feedback for Taskwarrior to show.
+func TaskwarriorOnModify(user string, stdin io.Reader, stdout io.Writer) error {
+	inTaskwarriorHook = true
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	var lines [][]byte
+	for len(lines) < 2 && scanner.Scan() {
+		lines = append(lines, append([]byte{}, scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(lines) != 2 {
+		return errors.New("expected the original and the modified task on stdin")
+	}
+
+	var original, modified TaskwarriorTask
+	var modifiedFields map[string]interface{}
+	if err := json.Unmarshal(lines[0], &original); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(lines[1], &modified); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(lines[1], &modifiedFields); err != nil {
+		return err
+	}
+
+	started := original.Start == "" && modified.Start != "" && modified.Status == "pending"
+	stopped := original.Start != "" && (modified.Start == "" || modified.Status != "pending")
+
+	var feedback string
+	if started || stopped {
+		closeStorage, err := OpenStorage(true, true)
+		if err != nil {
+			return err
+		}
+		defer closeStorage()
+
+		if started {
+			if feedback, err = taskwarriorStart(user, modified); err != nil {
+				return err
+			}
+		} else {
+			var total time.Duration
+			if feedback, total, err = taskwarriorStop(user, modified); err != nil {
+				return err
+			}
+			if total > 0 {
+				modifiedFields[GetTaskwarriorUDA()] = fmtTaskwarriorDuration(total)
+			}
+		}
+	}
+
+	line, err := json.Marshal(modifiedFields)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\n", line)
+	if feedback != "" {
+		fmt.Fprintln(stdout, feedback)
+	}
+	return nil
+}
+
+// taskwarriorStart tracks the task, finishing whatever is running.
+func taskwarriorStart(user string, twTask TaskwarriorTask) (string, error) {
+	var feedback string
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return "", err
+	}
+	if runningEntryId != "" {
+		finished, err := finishRunningEntry(user, runningEntryId)
+		if err != nil {
+			return "", err
+		}
+		feedback = fmt.Sprintf("zeit finished %s on %s. ", finished.Task, finished.Project)
+	}
+
+	entry, err := NewEntry("", "", "", twTask.Project, twTask.Description, user)
+	if err != nil {
+		return "", err
+	}
+	entry.References = []string{TaskwarriorReference(twTask.UUID)}
+
+	if entry, err = trackRunningEntry(user, entry); err != nil {
+		return "", err
+	}
+
+	return feedback + fmt.Sprintf("zeit began tracking %s on %s.", entry.Task, entry.Project), nil
+}
+
+// taskwarriorStop finishes the running entry if it tracks the task and
+// returns the total time tracked on the task.
+func taskwarriorStop(user string, twTask TaskwarriorTask) (string, time.Duration, error) {
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil || runningEntryId == "" {
+		return "", 0, err
+	}
+
+	runningEntry, err := database.GetEntry(user, runningEntryId)
+	if err != nil {
+		return "", 0, err
+	}
+	if uuid, ok := GetTaskwarriorUUID(runningEntry.References); !ok || uuid != twTask.UUID {
+		return "", 0, nil
+	}
+
+	finished, err := finishRunningEntry(user, runningEntryId)
+	if err != nil {
+		return "", 0, err
+	}
+
+	total, err := GetTaskwarriorTime(user, twTask.UUID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return fmt.Sprintf("zeit finished %s on %s, %sh tracked in total.", finished.Task, finished.Project, fmtDuration(total)), total, nil
+}
@@ -0,0 +1,15 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var taskwarriorCmd = &cobra.Command{
+	Use:   "taskwarrior",
+	Short: "Taskwarrior integration",
+	Long:  "Track Taskwarrior tasks in zeit: `zeit taskwarrior hook --install` installs an on-modify hook that tracks tasks started using `task start` and finishes them on `task stop` or `task done`. The total time tracked on a task is written back to it as the duration UDA taskwarrior.uda (default zeit).",
+}
+
+func init() {
+	rootCmd.AddCommand(taskwarriorCmd)
+}
@@ -0,0 +1,54 @@
+package z
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var taskwarriorHookInstall bool
+
+var taskwarriorHookCmd = &cobra.Command{
+	Use:         "hook",
+	Short:       "Generate the Taskwarrior on-modify hook",
+	Long:        "Print the on-modify hook script passing started and stopped Taskwarrior tasks on to zeit, or install it into Taskwarrior's hooks directory using --install.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, err := TaskwarriorHookScript()
+		if err != nil {
+			return err
+		}
+
+		if !taskwarriorHookInstall {
+			fmt.Print(script)
+			return nil
+		}
+
+		dir, err := GetTaskwarriorHooksDir()
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, taskwarriorHookName)
+		if err = os.WriteFile(path, []byte(script), 0700); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s installed the hook as %s\n", CharInfo, color.FgLightWhite.Render(path))
+		fmt.Printf("%s define the UDA for the tracked time in your .taskrc:\n", CharMore)
+		fmt.Printf("   uda.%s.type=duration\n   uda.%s.label=Tracked\n", GetTaskwarriorUDA(), GetTaskwarriorUDA())
+		return nil
+	},
+}
+
+func init() {
+	taskwarriorCmd.AddCommand(taskwarriorHookCmd)
+	taskwarriorHookCmd.Flags().BoolVar(&taskwarriorHookInstall, "install", false, "Install the hook into Taskwarrior's hooks directory")
+}
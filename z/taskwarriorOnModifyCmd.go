@@ -0,0 +1,35 @@
+package z
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var taskwarriorOnModifyCmd = &cobra.Command{
+	Use:   "on-modify",
+	Short: "Handle a Taskwarrior modification",
+	Long:  "Run by the on-modify hook of Taskwarrior with the original and the modified task on stdin, see `zeit taskwarrior hook`.",
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		// The database is only opened when a task was started or stopped
+		AnnotationNoDatabase: "true",
+	},
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Taskwarrior shows the output as feedback
+		color.Disable()
+
+		if err := TaskwarriorOnModify(GetCurrentUser(), os.Stdin, os.Stdout); err != nil {
+			fmt.Printf("zeit: %+v\n", err)
+			return exitWithCode(ExitCode(err))
+		}
+		return nil
+	},
+}
+
+func init() {
+	taskwarriorCmd.AddCommand(taskwarriorOnModifyCmd)
+}
@@ -0,0 +1,23 @@
+package z
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFmtTaskwarriorDuration(t *testing.T) {
+	for _, test := range []struct {
+		duration time.Duration
+		want     string
+	}{
+		{0, "PT0S"},
+		{59 * time.Second, "PT0S"},
+		{time.Minute, "PT0H1M"},
+		{90 * time.Minute, "PT1H30M"},
+		{26*time.Hour + 5*time.Minute, "PT26H5M"},
+	} {
+		if got := fmtTaskwarriorDuration(test.duration); got != test.want {
+			t.Errorf("fmtTaskwarriorDuration(%s) = %s, want %s", test.duration, got, test.want)
+		}
+	}
+}
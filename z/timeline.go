@@ -0,0 +1,230 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// Timeline lays out the activities of each day as blocks on a horizontal
+// axis of time, with hours not shown being the same for every day.
+type Timeline struct {
+	Days      []time.Time
+	Entries   []Entry
+	FromHour  int
+	UntilHour int
+	Width     int
+
+	colors map[string]func(...interface{}) string
+}
+
+type timelineSpan struct {
+	Begin time.Time `json:"begin"`
+	End   time.Time `json:"end"`
+}
+
+// NewTimeline spans the hours in which any of the entries was tracked on
+// the days from since until until.
+func NewTimeline(user string, entries []Entry, since time.Time, until time.Time, width int) (Timeline, error) {
+	timeline := Timeline{Entries: entries, Width: width, FromHour: 24, colors: make(map[string]func(...interface{}) string)}
+
+	for day := now.With(since).BeginningOfDay(); day.Before(until); day = day.AddDate(0, 0, 1) {
+		timeline.Days = append(timeline.Days, day)
+	}
+
+	for _, entry := range entries {
+		key := zeit.GetIdFromName(entry.Project)
+		if _, ok := timeline.colors[key]; !ok {
+			project, err := database.GetProject(user, entry.Project)
+			if err != nil {
+				return timeline, err
+			}
+			timeline.colors[key] = GetColorFnFromHex(project.Color)
+		}
+
+		for _, day := range timeline.Days {
+			begin, end := entry.Begin, entry.End()
+			if !begin.Before(day.AddDate(0, 0, 1)) || !end.After(day) {
+				continue
+			}
+			if begin.Before(day) {
+				timeline.FromHour = 0
+			} else {
+				timeline.FromHour = min(timeline.FromHour, begin.Hour())
+			}
+			if !end.Before(day.AddDate(0, 0, 1)) {
+				timeline.UntilHour = 24
+			} else {
+				hour := end.Hour()
+				if end.Minute() > 0 || end.Second() > 0 {
+					hour++
+				}
+				timeline.UntilHour = max(timeline.UntilHour, hour)
+			}
+		}
+	}
+
+	if timeline.FromHour >= timeline.UntilHour {
+		timeline.FromHour, timeline.UntilHour = 8, 18
+	}
+	return timeline, nil
+}
+
+// overlaps returns the spans in which more than one of the entries was
+// tracked.
+func timelineOverlaps(entries []Entry) []timelineSpan {
+	var spans []timelineSpan
+
+	sorted := append([]Entry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Begin.Before(sorted[j].Begin) })
+	for i := range sorted {
+		for j := i + 1; j < len(sorted) && sorted[j].Begin.Before(sorted[i].End()); j++ {
+			end := sorted[i].End()
+			if other := sorted[j].End(); other.Before(end) {
+				end = other
+			}
+			spans = append(spans, timelineSpan{Begin: sorted[j].Begin, End: end})
+		}
+	}
+
+	return spans
+}
+
+type TimelineDay struct {
+	Date        string           `json:"date"`
+	Seconds     int64            `json:"seconds"`
+	Projects    map[string]int64 `json:"projects"`
+	Overlapping []timelineSpan   `json:"overlapping"`
+}
+
+// GetDays returns the time tracked per day and project, and the spans in which
+// activities overlapped.
+func (timeline *Timeline) GetDays() []TimelineDay {
+	days := []TimelineDay{}
+	overlaps := timelineOverlaps(timeline.Entries)
+
+	for _, day := range timeline.Days {
+		timelineDay := TimelineDay{Date: day.Format(DateFormat), Projects: make(map[string]int64), Overlapping: []timelineSpan{}}
+		for _, entry := range timeline.Entries {
+			if tracked := zeit.ClippedDuration(entry, day, day.AddDate(0, 0, 1)); tracked > 0 {
+				timelineDay.Projects[entry.Project] += int64(tracked.Seconds())
+				timelineDay.Seconds += int64(tracked.Seconds())
+			}
+		}
+		for _, span := range overlaps {
+			if span.Begin.Before(day.AddDate(0, 0, 1)) && span.End.After(day) {
+				timelineDay.Overlapping = append(timelineDay.Overlapping, span)
+			}
+		}
+		days = append(days, timelineDay)
+	}
+
+	return days
+}
+
+// GetOutput renders a row per day, blocks in the colors of the projects and
+// overlapping activities highlighted.
+func (timeline *Timeline) GetOutput() string {
+	var output strings.Builder
+
+	hours := timeline.UntilHour - timeline.FromHour
+	cellsPerHour := max(timeline.Width/hours, 1)
+	cells := hours * cellsPerHour
+	slot := time.Hour / time.Duration(cellsPerHour)
+	overlaps := timelineOverlaps(timeline.Entries)
+
+	axis := make([]byte, cells+1)
+	for idx := range axis {
+		axis[idx] = ' '
+	}
+	step := 1
+	for step*cellsPerHour < 3 {
+		step++
+	}
+	for hour := timeline.FromHour; hour <= timeline.UntilHour; hour += step {
+		label := fmt.Sprintf("%02d", hour%24)
+		if pos := (hour - timeline.FromHour) * cellsPerHour; pos+len(label) <= len(axis) {
+			copy(axis[pos:], label)
+		}
+	}
+	fmt.Fprintf(&output, "\n%11s %s\n", "", strings.TrimRight(string(axis), " "))
+
+	totals := make(map[string]time.Duration)
+	var projects []string
+	var overlapped bool
+
+	for _, day := range timeline.Days {
+		start := day.Add(time.Duration(timeline.FromHour) * time.Hour)
+		var total time.Duration
+
+		var row strings.Builder
+		for cell := 0; cell < cells; cell++ {
+			from := start.Add(time.Duration(cell) * slot)
+			to := from.Add(slot)
+
+			var most time.Duration
+			var covering *Entry
+			for idx := range timeline.Entries {
+				if covered := zeit.ClippedDuration(timeline.Entries[idx], from, to); covered > most {
+					most = covered
+					covering = &timeline.Entries[idx]
+				}
+			}
+
+			overlapping := false
+			for _, span := range overlaps {
+				if span.Begin.Before(to) && span.End.After(from) {
+					overlapping = true
+					break
+				}
+			}
+
+			switch {
+			case covering == nil:
+				row.WriteString(" ")
+			case overlapping:
+				overlapped = true
+				row.WriteString(color.FgLightRed.Render("▓"))
+			default:
+				row.WriteString(timeline.colors[zeit.GetIdFromName(covering.Project)]("█"))
+			}
+		}
+
+		for _, entry := range timeline.Entries {
+			tracked := zeit.ClippedDuration(entry, day, day.AddDate(0, 0, 1))
+			if tracked <= 0 {
+				continue
+			}
+			total += tracked
+			key := zeit.GetIdFromName(entry.Project)
+			if _, ok := totals[key]; !ok {
+				projects = append(projects, entry.Project)
+			}
+			totals[key] += tracked
+		}
+
+		fmt.Fprintf(&output, "%s │%s│ %sh\n", day.Format("Mon 01-02"), row.String(), fmtDuration(total))
+	}
+
+	sort.Slice(projects, func(i, j int) bool { return strings.ToLower(projects[i]) < strings.ToLower(projects[j]) })
+	output.WriteString("\n")
+	for _, project := range projects {
+		name := project
+		if name == "" {
+			name = "(no project)"
+		}
+		key := zeit.GetIdFromName(project)
+		fmt.Fprintf(&output, "   %s %s %sh\n", timeline.colors[key]("█"), name, fmtDuration(totals[key]))
+	}
+	if overlapped {
+		fmt.Fprintf(&output, "   %s overlapping activities\n", color.FgLightRed.Render("▓"))
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
@@ -0,0 +1,77 @@
+package z
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var timelineColumns int
+
+var timelineCmd = &cobra.Command{
+	Use:         "timeline",
+	Short:       "Timeline of tracked activities",
+	Long:        "Render a timeline per day with the tracked activities as blocks in the colors of their projects, untracked time as empty space and overlapping activities highlighted. Shows today unless --since, --until or --range are given.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		sinceTime, untilTime, err := ParseTimeRange(since, until, listRange)
+		if err != nil {
+			return err
+		}
+		if sinceTime.IsZero() {
+			sinceTime = now.BeginningOfDay()
+		}
+		if untilTime.IsZero() {
+			untilTime = now.With(sinceTime).EndOfDay()
+			if since != "" {
+				untilTime = now.EndOfDay()
+			}
+		}
+
+		entries, err := database.ListEntriesBetween(user, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+		entries, err = GetFilteredEntries(entries, project, task, sinceTime, untilTime)
+		if err != nil {
+			return err
+		}
+
+		width := timelineColumns
+		if width <= 0 {
+			width = 72
+			if columns, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && columns > 40 {
+				width = columns - 24
+			}
+		}
+
+		timeline, err := NewTimeline(user, entries, sinceTime, untilTime, width)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(timeline.GetDays())
+		}
+		fmt.Print(timeline.GetOutput())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(timelineCmd)
+	timelineCmd.Flags().StringVar(&since, "since", "", "Date/time to start the timeline from")
+	timelineCmd.Flags().StringVar(&until, "until", "", "Date/time to show the timeline until")
+	timelineCmd.Flags().StringVar(&listRange, "range", "", "Shortcut for --since and --until, also as --period, that accepts: "+strings.Join(zeit.Ranges(), ", "))
+	timelineCmd.Flags().StringVarP(&project, "project", "p", "", "Only show activities of this project")
+	timelineCmd.Flags().StringVarP(&task, "task", "t", "", "Only show activities of this task")
+	timelineCmd.Flags().IntVar(&timelineColumns, "width", 0, "Width of the timeline in characters (default fits the terminal)")
+	timelineCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
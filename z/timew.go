@@ -0,0 +1,392 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// Timewarrior keeps intervals in monthly data files as lines like
+//
+//	inc 20240304T090000Z - 20240304T103000Z # acme dev "code review" # "notes"
+//
+// with UTC timestamps, and `timew export` writes the same intervals as a JSON
+// array. Tags carry everything, so rules translate them to zeit.
+
+const timewTimeFormat string = "20060102T150405Z"
+
+type TimewInterval struct {
+	ID         int      `json:"id,omitempty"`
+	Start      string   `json:"start"`
+	End        string   `json:"end,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Annotation string   `json:"annotation,omitempty"`
+}
+
+// TimewRule translates Timewarrior tags matching the regular expression Match
+// to the project, task or a tag of an activity. Project, Task and Tag may
+// refer to submatches as $1 or ${name}.
+type TimewRule struct {
+	Match   string `mapstructure:"match"`
+	Project string `mapstructure:"project"`
+	Task    string `mapstructure:"task"`
+	Tag     string `mapstructure:"tag"`
+
+	re *regexp.Regexp
+}
+
+// GetTimewRules returns the rules configured as timew.rules.
+func GetTimewRules() ([]TimewRule, error) {
+	var rules []TimewRule
+	if err := viper.UnmarshalKey("timew.rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid timew.rules: %v", err)
+	}
+
+	for idx := range rules {
+		rule := &rules[idx]
+		if rule.Match == "" || (rule.Project == "" && rule.Task == "" && rule.Tag == "") {
+			return nil, fmt.Errorf("timew rule #%d needs a match and a project, task or tag", idx+1)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("timew rule #%d: %v", idx+1, err)
+		}
+		rule.re = re
+	}
+	return rules, nil
+}
+
+// GetTimewDataPath returns the data directory of Timewarrior, configurable as
+// timew.data and otherwise located like Timewarrior does.
+func GetTimewDataPath() string {
+	if path := viper.GetString("timew.data"); path != "" {
+		return os.ExpandEnv(path)
+	}
+	if db := os.Getenv("TIMEWARRIORDB"); db != "" {
+		return filepath.Join(db, "data")
+	}
+
+	home, _ := os.UserHomeDir()
+	legacy := filepath.Join(home, ".timewarrior", "data")
+	if fileExists(legacy) {
+		return legacy
+	}
+	return filepath.Join(GetDataHome(), "timewarrior", "data")
+}
+
+type timewToken struct {
+	Value  string
+	Quoted bool
+}
+
+func timewTokens(line string) ([]timewToken, error) {
+	var tokens []timewToken
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		if line[i] != '"' {
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			tokens = append(tokens, timewToken{Value: line[i : i+end]})
+			i += end
+			continue
+		}
+
+		end := i + 1
+		for ; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(line) {
+			return nil, errors.New("unterminated quote")
+		}
+		var value string
+		if err := json.Unmarshal([]byte(line[i:end+1]), &value); err != nil {
+			value = line[i+1 : end]
+		}
+		tokens = append(tokens, timewToken{Value: value, Quoted: true})
+		i = end + 1
+	}
+
+	return tokens, nil
+}
+
+// ParseTimewLine reads an interval of a Timewarrior data file.
+func ParseTimewLine(line string) (TimewInterval, error) {
+	var interval TimewInterval
+
+	tokens, err := timewTokens(line)
+	if err != nil {
+		return interval, err
+	}
+	if len(tokens) < 2 || tokens[0].Value != "inc" {
+		return interval, errors.New("not a Timewarrior interval")
+	}
+	interval.Start = tokens[1].Value
+	tokens = tokens[2:]
+
+	if len(tokens) >= 2 && tokens[0].Value == "-" && !tokens[0].Quoted {
+		interval.End = tokens[1].Value
+		tokens = tokens[2:]
+	}
+	if len(tokens) == 0 {
+		return interval, nil
+	}
+	if tokens[0].Value != "#" || tokens[0].Quoted {
+		return interval, fmt.Errorf("unexpected '%s'", tokens[0].Value)
+	}
+
+	tokens = tokens[1:]
+	for idx, token := range tokens {
+		if token.Value == "#" && !token.Quoted {
+			var annotation []string
+			for _, t := range tokens[idx+1:] {
+				annotation = append(annotation, t.Value)
+			}
+			interval.Annotation = strings.Join(annotation, " ")
+			break
+		}
+		interval.Tags = append(interval.Tags, token.Value)
+	}
+
+	return interval, nil
+}
+
+func timewQuote(value string) string {
+	if value != "" && value != "#" && value != "-" && !strings.ContainsAny(value, "\"\\") &&
+		strings.IndexFunc(value, unicode.IsSpace) < 0 {
+		return value
+	}
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// Line formats the interval as in a Timewarrior data file.
+func (interval *TimewInterval) Line() string {
+	var line strings.Builder
+
+	line.WriteString("inc " + interval.Start)
+	if interval.End != "" {
+		line.WriteString(" - " + interval.End)
+	}
+	if len(interval.Tags) > 0 || interval.Annotation != "" {
+		line.WriteString(" #")
+		for _, tag := range interval.Tags {
+			line.WriteString(" " + timewQuote(tag))
+		}
+	}
+	if interval.Annotation != "" {
+		annotation, _ := json.Marshal(interval.Annotation)
+		line.WriteString(" # " + string(annotation))
+	}
+
+	return line.String()
+}
+
+// Entry translates the interval using the rules: every tag is translated by
+// the first rule matching it, the project and task by the first rules
+// setting them. Without rules the first tag is the project, the second the
+// task and the rest stay tags.
+func (interval *TimewInterval) Entry(user string, rules []TimewRule) (Entry, error) {
+	entry := Entry{
+		User:  user,
+		Notes: interval.Annotation,
+		SHA1:  fmt.Sprintf("%x", sha1.Sum([]byte("timew\x1f"+interval.Start))),
+	}
+
+	begin, err := time.Parse(timewTimeFormat, interval.Start)
+	if err != nil {
+		return entry, fmt.Errorf("invalid start '%s'", interval.Start)
+	}
+	entry.Begin = begin.Local()
+	if interval.End == "" {
+		return entry, fmt.Errorf("interval starting %s is still running", entry.Begin.Format(time.RFC3339))
+	}
+	finish, err := time.Parse(timewTimeFormat, interval.End)
+	if err != nil {
+		return entry, fmt.Errorf("invalid end '%s'", interval.End)
+	}
+	entry.Finish = finish.Local()
+
+	var tags []string
+	if len(rules) == 0 {
+		tags = interval.Tags
+		if len(tags) > 0 {
+			entry.Project, tags = tags[0], tags[1:]
+		}
+		if len(tags) > 0 {
+			entry.Task, tags = tags[0], tags[1:]
+		}
+	} else {
+		tags = timewRuleTags(&entry, interval.Tags, rules)
+	}
+	entry.Tags = zeit.ParseTags(tags)
+
+	if !entry.IsFinishedAfterBegan() {
+		return entry, NewFinishBeforeBeginError(entry)
+	}
+	return entry, nil
+}
+
+// timewRuleTags sets the project and task of entry from the first rules
+// matching the tags, and returns the translated and unmatched tags.
+func timewRuleTags(entry *Entry, intervalTags []string, rules []TimewRule) []string {
+	var tags []string
+
+	for _, tag := range intervalTags {
+		matched := false
+		for _, rule := range rules {
+			match := rule.re.FindStringSubmatchIndex(tag)
+			if match == nil {
+				continue
+			}
+			expand := func(template string) string {
+				return string(rule.re.ExpandString(nil, template, tag, match))
+			}
+			if rule.Project != "" && entry.Project == "" {
+				entry.Project = expand(rule.Project)
+			}
+			if rule.Task != "" && entry.Task == "" {
+				entry.Task = expand(rule.Task)
+			}
+			if rule.Tag != "" {
+				tags = append(tags, expand(rule.Tag))
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// readTimew reads the intervals of a `timew export`, a data file or all
+// monthly data files of a data directory.
+func readTimew(source string) ([]TimewInterval, []error, error) {
+	var intervals []TimewInterval
+	var lineErrors []error
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return intervals, lineErrors, err
+	}
+
+	files := []string{source}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(source, "[0-9][0-9][0-9][0-9]-[0-9][0-9].data"))
+		if err != nil {
+			return intervals, lineErrors, err
+		}
+		if len(files) == 0 {
+			return intervals, lineErrors, fmt.Errorf("no Timewarrior data files in %s", source)
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return intervals, lineErrors, err
+		}
+
+		if trimmed := bytes.TrimSpace(content); bytes.HasPrefix(trimmed, []byte("[")) {
+			var exported []TimewInterval
+			if err = json.Unmarshal(trimmed, &exported); err != nil {
+				return intervals, lineErrors, fmt.Errorf("invalid timew export %s: %v", file, err)
+			}
+			intervals = append(intervals, exported...)
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for number := 1; scanner.Scan(); number++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			interval, err := ParseTimewLine(line)
+			if err != nil {
+				lineErrors = append(lineErrors, fmt.Errorf("%s line %d: %v", filepath.Base(file), number, err))
+				continue
+			}
+			intervals = append(intervals, interval)
+		}
+		if err = scanner.Err(); err != nil {
+			return intervals, lineErrors, err
+		}
+	}
+
+	return intervals, lineErrors, nil
+}
+
+// ImportTimew reads the finished intervals of source and translates them to
+// activities. Intervals that can't be read don't stop the import but are
+// returned as errors.
+func ImportTimew(user string, source string, rules []TimewRule) ([]Entry, []error, error) {
+	var entries []Entry
+
+	intervals, intervalErrors, err := readTimew(source)
+	if err != nil {
+		return entries, intervalErrors, err
+	}
+
+	for _, interval := range intervals {
+		entry, err := interval.Entry(user, rules)
+		if err != nil {
+			intervalErrors = append(intervalErrors, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, intervalErrors, nil
+}
+
+// TimewIntervals converts activities to intervals tagged with their project,
+// task and tags, which the default translation reads back the same way.
+// Running activities become open intervals.
+func TimewIntervals(entries []Entry) []TimewInterval {
+	var intervals []TimewInterval
+
+	for _, entry := range entries {
+		interval := TimewInterval{
+			Start:      entry.Begin.UTC().Format(timewTimeFormat),
+			Annotation: entry.Notes,
+		}
+		if !entry.Finish.IsZero() {
+			interval.End = entry.Finish.UTC().Format(timewTimeFormat)
+		}
+		for _, tag := range append([]string{entry.Project, entry.Task}, entry.Tags...) {
+			if tag != "" {
+				interval.Tags = append(interval.Tags, tag)
+			}
+		}
+		intervals = append(intervals, interval)
+	}
+
+	sort.SliceStable(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
+	return intervals
+}
@@ -0,0 +1,72 @@
+package z
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+type TodayEntry struct {
+	ID      string     `json:"id"`
+	Begin   time.Time  `json:"begin"`
+	Finish  *time.Time `json:"finish"`
+	Project string     `json:"project"`
+	Task    string     `json:"task"`
+	Seconds int64      `json:"seconds"`
+	Running bool       `json:"running"`
+}
+
+// Today is what was tracked today so far and how much is left to the
+// target of the day.
+type Today struct {
+	Timestamp        time.Time    `json:"timestamp"`
+	Entries          []TodayEntry `json:"entries"`
+	Running          *TodayEntry  `json:"running"`
+	TotalSeconds     int64        `json:"totalSeconds"`
+	TargetSeconds    int64        `json:"targetSeconds"`
+	RemainingSeconds int64        `json:"remainingSeconds"`
+}
+
+func GetToday(user string, targets Targets) (Today, error) {
+	timestamp := time.Now()
+	today := Today{Timestamp: timestamp, Entries: []TodayEntry{}}
+
+	ref := now.With(timestamp)
+	todayBegin, todayEnd := ref.BeginningOfDay(), ref.EndOfDay()
+	entries, err := database.ListEntriesBetween(user, todayBegin, todayEnd)
+	if err != nil {
+		return today, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Begin.Before(entries[j].Begin) })
+
+	var total time.Duration
+	for _, entry := range entries {
+		duration := zeit.ClippedDuration(entry, todayBegin, timestamp)
+		total += duration
+
+		todayEntry := TodayEntry{
+			ID:      entry.ID,
+			Begin:   entry.Begin,
+			Project: entry.Project,
+			Task:    entry.Task,
+			Seconds: int64(duration.Seconds()),
+			Running: entry.Finish.IsZero(),
+		}
+		if !todayEntry.Running {
+			finish := entry.Finish
+			todayEntry.Finish = &finish
+		}
+		today.Entries = append(today.Entries, todayEntry)
+		if todayEntry.Running {
+			today.Running = &todayEntry
+		}
+	}
+
+	today.TotalSeconds = int64(total.Seconds())
+	today.TargetSeconds = int64(targets.Day(timestamp).Seconds())
+	today.RemainingSeconds = max(today.TargetSeconds-today.TotalSeconds, 0)
+
+	return today, nil
+}
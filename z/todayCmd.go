@@ -0,0 +1,113 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var todayFollow bool
+
+var todayCmd = &cobra.Command{
+	Use:         "today",
+	Short:       "Today's activities",
+	Long:        "Show the activities tracked today, the running one with its elapsed time, the total tracked so far and the time remaining to the target of the day.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		targets, err := GetTargets()
+		if err != nil {
+			return err
+		}
+
+		if todayFollow {
+			return Follow(func() (string, error) {
+				return todayOutput(user, targets)
+			})
+		}
+
+		output, err := todayOutput(user, targets)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func todayOutput(user string, targets Targets) (string, error) {
+	today, err := GetToday(user, targets)
+	if err != nil {
+		return "", err
+	}
+
+	if IsOutputJSON() {
+		var stringified []byte
+		if todayFollow {
+			stringified, err = json.Marshal(today)
+		} else {
+			stringified, err = json.MarshalIndent(today, "", "  ")
+		}
+		return string(stringified) + "\n", err
+	}
+
+	var output strings.Builder
+
+	timeFormat := "15:04"
+	for _, entry := range today.Entries {
+		finish := "now  "
+		if entry.Finish != nil {
+			finish = entry.Finish.Format(timeFormat)
+		}
+		name := entry.Task
+		if entry.Project != "" {
+			name = strings.TrimSpace(name + " on " + color.FgLightWhite.Render(entry.Project))
+		}
+		fmt.Fprintf(&output, "   %s - %s %8sh   %s\n",
+			entry.Begin.Format(timeFormat),
+			finish,
+			fmtDuration(time.Duration(entry.Seconds)*time.Second),
+			name,
+		)
+	}
+	if len(today.Entries) > 0 {
+		output.WriteString("\n")
+	}
+
+	if today.Running != nil {
+		entry := Entry{Begin: today.Running.Begin, Project: today.Running.Project, Task: today.Running.Task}
+		output.WriteString(GetEntryOutputForTrack(entry, true, true))
+	} else {
+		fmt.Fprintf(&output, "%s not running\n", CharFinish)
+	}
+
+	total := time.Duration(today.TotalSeconds) * time.Second
+	fmt.Fprintf(&output, "%s %sh tracked today", CharInfo, color.FgLightWhite.Render(fmtDuration(total)))
+	if today.TargetSeconds > 0 {
+		target := time.Duration(today.TargetSeconds) * time.Second
+		if today.RemainingSeconds > 0 {
+			fmt.Fprintf(&output, ", %sh remaining of %sh",
+				color.FgLightYellow.Render(fmtDuration(time.Duration(today.RemainingSeconds)*time.Second)),
+				fmtDuration(target))
+		} else {
+			fmt.Fprintf(&output, ", %sh target reached (%sh)",
+				color.FgLightGreen.Render(fmtDuration(target)),
+				fmtDelta(total-target))
+		}
+	}
+	output.WriteString("\n")
+
+	return output.String(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(todayCmd)
+	todayCmd.Flags().BoolVar(&todayFollow, "follow", false, "Keep the summary updated, e.g. in a terminal next to the editor")
+	todayCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
@@ -0,0 +1,377 @@
+package z
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+const togglMetaKey string = "toggl:pushed"
+
+const defaultTogglURL string = "https://api.track.toggl.com"
+
+type Toggl struct {
+	URL       string
+	Token     string
+	Workspace int64
+
+	// Projects maps Toggl project names to zeit projects and Tags Toggl tags
+	// to zeit tags
+	Projects map[string]string
+	Tags     map[string]string
+
+	projects map[int64]togglProject
+}
+
+type togglProject struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Billable bool   `json:"billable"`
+	Active   bool   `json:"active"`
+}
+
+type TogglTimeEntry struct {
+	ID          int64     `json:"id,omitempty"`
+	WorkspaceID int64     `json:"workspace_id"`
+	ProjectID   *int64    `json:"project_id,omitempty"`
+	Description string    `json:"description"`
+	Start       time.Time `json:"start"`
+	Stop        *string   `json:"stop,omitempty"`
+	Duration    int64     `json:"duration"`
+	Tags        []string  `json:"tags"`
+	CreatedWith string    `json:"created_with,omitempty"`
+}
+
+type TogglPushStats struct {
+	Pushed  int
+	Skipped int
+}
+
+// importMapping returns the names configured at key, extended by the given
+// mappings as From=to. Lookups are case-insensitive.
+func importMapping(key string, mappings []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for from, to := range viper.GetStringMapString(key) {
+		mapping[strings.ToLower(from)] = to
+	}
+
+	for _, m := range mappings {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return nil, fmt.Errorf("invalid mapping '%s', use Name=zeit", m)
+		}
+		mapping[strings.ToLower(strings.TrimSpace(from))] = strings.TrimSpace(to)
+	}
+
+	return mapping, nil
+}
+
+// NewToggl configures the Toggl Track API client from `toggl.*` and the
+// given Toggl=zeit project and tag mappings.
+func NewToggl(workspace int64, projectMappings []string, tagMappings []string) (*Toggl, error) {
+	var err error
+
+	toggl := Toggl{
+		URL:       strings.TrimSuffix(viper.GetString("toggl.url"), "/"),
+		Token:     os.ExpandEnv(viper.GetString("toggl.token")),
+		Workspace: workspace,
+	}
+	if toggl.URL == "" {
+		toggl.URL = defaultTogglURL
+	}
+	if toggl.Token == "" {
+		return nil, errors.New("please configure the API token of Toggl Track as toggl.token")
+	}
+	if toggl.Workspace == 0 {
+		toggl.Workspace = viper.GetInt64("toggl.workspace")
+	}
+
+	if toggl.Projects, err = importMapping("toggl.projects", projectMappings); err != nil {
+		return nil, err
+	}
+	if toggl.Tags, err = importMapping("toggl.tags", tagMappings); err != nil {
+		return nil, err
+	}
+
+	if toggl.Workspace == 0 {
+		var me struct {
+			DefaultWorkspaceID int64 `json:"default_workspace_id"`
+		}
+		if err = toggl.request(http.MethodGet, "/me", nil, &me); err != nil {
+			return nil, err
+		}
+		toggl.Workspace = me.DefaultWorkspaceID
+	}
+
+	var projects []togglProject
+	if err = toggl.request(http.MethodGet, fmt.Sprintf("/workspaces/%d/projects?active=both", toggl.Workspace), nil, &projects); err != nil {
+		return nil, err
+	}
+	toggl.projects = make(map[int64]togglProject)
+	for _, project := range projects {
+		toggl.projects[project.ID] = project
+	}
+
+	return &toggl, nil
+}
+
+func (toggl *Toggl) request(method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, toggl.URL+"/api/v9"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+	req.SetBasicAuth(toggl.Token, "api_token")
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, req.URL.Redacted(), res.Status, strings.TrimSpace(string(message)))
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (toggl *Toggl) project(entry TogglTimeEntry) togglProject {
+	if entry.ProjectID == nil {
+		return togglProject{}
+	}
+	return toggl.projects[*entry.ProjectID]
+}
+
+func (toggl *Toggl) zeitProject(entry TogglTimeEntry) string {
+	name := toggl.project(entry).Name
+	if mapped, ok := toggl.Projects[strings.ToLower(name)]; ok {
+		return mapped
+	}
+	return name
+}
+
+// togglProjectID returns the Toggl project of a zeit project, using the
+// project mapping in reverse.
+func (toggl *Toggl) togglProjectID(project string) *int64 {
+	if project == "" {
+		return nil
+	}
+
+	name := project
+	for from, to := range toggl.Projects {
+		if strings.EqualFold(to, project) {
+			name = from
+			break
+		}
+	}
+
+	for id, p := range toggl.projects {
+		if strings.EqualFold(p.Name, name) {
+			id := id
+			return &id
+		}
+	}
+	return nil
+}
+
+func togglSHA1(id int64) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte("toggl\x1f"+strconv.FormatInt(id, 10))))
+}
+
+// TimeEntries fetches the finished time entries of the workspace between
+// from and to.
+func (toggl *Toggl) TimeEntries(from time.Time, to time.Time) ([]TogglTimeEntry, error) {
+	var entries []TogglTimeEntry
+
+	query := url.Values{}
+	query.Set("start_date", from.Format(time.RFC3339))
+	query.Set("end_date", to.Format(time.RFC3339))
+
+	var all []TogglTimeEntry
+	if err := toggl.request(http.MethodGet, "/me/time_entries?"+query.Encode(), nil, &all); err != nil {
+		return entries, err
+	}
+
+	for _, entry := range all {
+		// Running time entries have a negative duration
+		if entry.WorkspaceID != toggl.Workspace || entry.Duration < 0 || entry.Stop == nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Entry converts a Toggl time entry, using the description as task.
+func (toggl *Toggl) Entry(user string, timeEntry TogglTimeEntry) (Entry, error) {
+	finish, err := time.Parse(time.RFC3339, *timeEntry.Stop)
+	if err != nil {
+		return Entry{}, fmt.Errorf("time entry %d: %v", timeEntry.ID, err)
+	}
+
+	var tags []string
+	for _, tag := range timeEntry.Tags {
+		if mapped, ok := toggl.Tags[strings.ToLower(tag)]; ok {
+			tag = mapped
+		}
+		tags = append(tags, tag)
+	}
+
+	entry := Entry{
+		Begin:   timeEntry.Start.Local(),
+		Finish:  finish.Local(),
+		Project: toggl.zeitProject(timeEntry),
+		Task:    timeEntry.Description,
+		User:    user,
+		Tags:    zeit.ParseTags(tags),
+		SHA1:    togglSHA1(timeEntry.ID),
+	}
+	return entry, nil
+}
+
+// ZeitProjects returns the zeit projects of the Toggl projects used by the
+// time entries.
+func (toggl *Toggl) ZeitProjects(timeEntries []TogglTimeEntry) []Project {
+	var projects []Project
+	seen := make(map[string]bool)
+	for _, timeEntry := range timeEntries {
+		p := toggl.project(timeEntry)
+		name := toggl.zeitProject(timeEntry)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		projects = append(projects, Project{Name: name, Color: p.Color, Billable: p.Billable})
+	}
+	return projects
+}
+
+func getTogglPushed(user string) (map[string]int64, error) {
+	pushed := make(map[string]int64)
+
+	value, err := database.GetMeta(user, togglMetaKey)
+	if err != nil || value == "" {
+		return pushed, err
+	}
+
+	if err = json.Unmarshal([]byte(value), &pushed); err != nil {
+		return pushed, errors.New("could not read the list of activities pushed to Toggl")
+	}
+	return pushed, nil
+}
+
+// Push mirrors the finished activities between from and to into Toggl,
+// except for ones pushed before and ones imported from the given time
+// entries.
+func (toggl *Toggl) Push(user string, from time.Time, to time.Time, timeEntries []TogglTimeEntry, sha1List map[string]string, dryRun bool, report func(Entry, error)) (TogglPushStats, error) {
+	var stats TogglPushStats
+
+	pushed, err := getTogglPushed(user)
+	if err != nil {
+		return stats, err
+	}
+
+	fromToggl := make(map[string]bool)
+	for _, timeEntry := range timeEntries {
+		if id, ok := sha1List[togglSHA1(timeEntry.ID)]; ok {
+			fromToggl[id] = true
+		}
+	}
+
+	entries, err := database.ListEntriesBetween(user, from, to)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			continue
+		}
+		if _, ok := pushed[entry.ID]; ok || fromToggl[entry.ID] {
+			stats.Skipped++
+			continue
+		}
+
+		stop := entry.Finish.UTC().Format(time.RFC3339)
+		timeEntry := TogglTimeEntry{
+			WorkspaceID: toggl.Workspace,
+			ProjectID:   toggl.togglProjectID(entry.Project),
+			Description: entry.Task,
+			Start:       entry.Begin.UTC(),
+			Stop:        &stop,
+			Duration:    int64(entry.Finish.Sub(entry.Begin).Seconds()),
+			Tags:        entry.Tags,
+			CreatedWith: "zeit",
+		}
+		if timeEntry.Description == "" {
+			timeEntry.Description = entry.Project
+		}
+		if timeEntry.Tags == nil {
+			timeEntry.Tags = []string{}
+		}
+
+		if !dryRun {
+			var created TogglTimeEntry
+			err = toggl.request(http.MethodPost, fmt.Sprintf("/workspaces/%d/time_entries", toggl.Workspace), timeEntry, &created)
+			if err != nil {
+				report(entry, err)
+				continue
+			}
+			pushed[entry.ID] = created.ID
+		}
+		stats.Pushed++
+		report(entry, nil)
+	}
+
+	if dryRun {
+		return stats, nil
+	}
+
+	value, err := json.Marshal(pushed)
+	if err != nil {
+		return stats, err
+	}
+	return stats, database.SetMeta(user, togglMetaKey, string(value))
+}
+
+// TogglPushedIDs returns the Toggl time entries pushed from zeit, which are
+// not imported again.
+func TogglPushedIDs(user string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+
+	pushed, err := getTogglPushed(user)
+	for _, id := range pushed {
+		ids[id] = true
+	}
+	return ids, err
+}
@@ -0,0 +1,140 @@
+package z
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// API tokens are stored as meta value of a reserved user, so that they are
+// shared by all users of a server's database. Only their hashes are kept.
+const (
+	serverMetaUser string = "_server"
+	tokensMetaKey  string = "tokens"
+	tokenPrefix    string = "zeit_"
+)
+
+type APIToken struct {
+	Name    string    `json:"name"`
+	User    string    `json:"user"`
+	Hash    string    `json:"hash,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+var errRemoteTokens = errors.New("API tokens can only be managed on the server")
+
+func GetAPITokens() ([]APIToken, error) {
+	var tokens []APIToken
+
+	if _, ok := unwrapStorage(database).(*Remote); ok {
+		return tokens, errRemoteTokens
+	}
+
+	value, err := database.GetMeta(serverMetaUser, tokensMetaKey)
+	if err != nil || value == "" {
+		return tokens, err
+	}
+
+	if err = json.Unmarshal([]byte(value), &tokens); err != nil {
+		return tokens, errors.New("could not read the list of API tokens")
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+func updateAPITokens(tokens []APIToken) error {
+	if _, ok := unwrapStorage(database).(*Remote); ok {
+		return errRemoteTokens
+	}
+
+	value, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	return database.SetMeta(serverMetaUser, tokensMetaKey, string(value))
+}
+
+// CreateAPIToken adds a token authenticating requests to the server as the
+// given user. The token itself is returned only once and cannot be retrieved
+// afterwards.
+func CreateAPIToken(name string, user string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("token name must not be empty")
+	}
+	if user == "" || strings.HasPrefix(user, "_") {
+		return "", fmt.Errorf("invalid user '%s'", user)
+	}
+
+	tokens, err := GetAPITokens()
+	if err != nil {
+		return "", err
+	}
+	for _, token := range tokens {
+		if strings.EqualFold(token.Name, name) {
+			return "", fmt.Errorf("token %s already exists", token.Name)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(secret)
+
+	tokens = append(tokens, APIToken{
+		Name:    name,
+		User:    user,
+		Hash:    hashAPIToken(token),
+		Created: time.Now(),
+	})
+
+	return token, updateAPITokens(tokens)
+}
+
+func RevokeAPIToken(name string) error {
+	tokens, err := GetAPITokens()
+	if err != nil {
+		return err
+	}
+
+	for idx, token := range tokens {
+		if strings.EqualFold(token.Name, name) {
+			return updateAPITokens(append(tokens[:idx], tokens[idx+1:]...))
+		}
+	}
+
+	return &NotFoundError{Message: fmt.Sprintf("token %s does not exist", name)}
+}
+
+// LookupAPIToken returns the API token matching the given bearer token, if
+// any.
+func LookupAPIToken(token string) (APIToken, bool, error) {
+	tokens, err := GetAPITokens()
+	if err != nil {
+		return APIToken{}, false, err
+	}
+
+	hash := []byte(hashAPIToken(token))
+	for _, apiToken := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(apiToken.Hash)) == 1 {
+			return apiToken, true, nil
+		}
+	}
+
+	return APIToken{}, false, nil
+}
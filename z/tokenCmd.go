@@ -0,0 +1,48 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:         "token",
+	Short:       "API tokens",
+	Long:        "List the API tokens authenticating requests to `zeit serve`. Every token acts as the user it was created for, so that several users can share one server.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokens, err := GetAPITokens()
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			for idx := range tokens {
+				tokens[idx].Hash = ""
+			}
+			return printJSON(tokens)
+		}
+
+		if len(tokens) == 0 {
+			fmt.Printf("%s no API tokens\n", CharInfo)
+			return nil
+		}
+
+		for _, token := range tokens {
+			fmt.Printf("%s %s %s %s %s\n",
+				CharMore,
+				color.FgLightWhite.Render(token.Name),
+				CharMore,
+				token.User,
+				color.FgGray.Render("created "+token.Created.Format(GetTimeDisplayFormat())),
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+}
@@ -0,0 +1,45 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var tokenUser string
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create ([flags]) [name]",
+	Short: "Create API token",
+	Long:  "Create an API token for `zeit serve`, acting as the current user or the one given by --user. The token is shown only once.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := tokenUser
+		if user == "" {
+			user = GetCurrentUser()
+		}
+
+		token, err := CreateAPIToken(args[0], user)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(map[string]string{"name": args[0], "user": user, "token": token})
+		}
+
+		fmt.Printf("%s token %s created for user %s, it will not be shown again:\n",
+			CharInfo,
+			color.FgLightWhite.Render(args[0]),
+			color.FgLightWhite.Render(user),
+		)
+		fmt.Printf("%s\n", token)
+		return nil
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCreateCmd.Flags().StringVarP(&tokenUser, "user", "u", "", "User the token acts as (default: current user)")
+}
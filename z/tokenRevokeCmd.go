@@ -0,0 +1,27 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke [name]",
+	Short: "Revoke API token",
+	Long:  "Revoke an API token, so that it no longer authenticates requests to `zeit serve`.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := RevokeAPIToken(args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s token %s revoked\n", CharInfo, color.FgLightWhite.Render(args[0]))
+		return nil
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenRevokeCmd)
+}
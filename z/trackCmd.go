@@ -1,65 +1,26 @@
 package z
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+var trackTaskwarrior string
+var trackFromGit bool
+
 var trackCmd = &cobra.Command{
 	Use:   "track",
 	Short: "Tracking time",
 	Long:  "Track new activity, which can either be kept running until 'finish' is being called or parameterized to be a finished activity.",
-	Run: func(cmd *cobra.Command, args []string) {
-		user := GetCurrentUser()
-
-		runningEntryId, err := database.GetRunningEntryId(user)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tracked, err := trackTask()
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
-		}
-
-		if runningEntryId != "" {
-			fmt.Printf("%s a task is already running\n", CharTrack)
-			os.Exit(1)
+			return err
 		}
 
-		if project == "" && viper.GetString("project.default") != "" {
-			project = viper.GetString("project.default")
+		if IsOutputJSON() {
+			return printJSON(EntriesOutput{Tracked: entryResources([]Entry{tracked})})
 		}
-
-		if project == "" && viper.GetBool("project.mandatory") {
-			fmt.Println("project is mandatory but missing")
-			os.Exit(1)
-		}
-
-		if task == "" && viper.GetBool("task.mandatory") {
-			fmt.Println("task is mandatory but missing")
-			os.Exit(1)
-		}
-
-		newEntry, err := NewEntry("", begin, finish, project, task, user)
-		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
-		}
-
-		if notes != "" {
-			newEntry.Notes = notes
-		}
-
-		isRunning := newEntry.Finish.IsZero()
-
-		_, err = database.AddEntry(user, newEntry, isRunning)
-		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
-		}
-
-		fmt.Printf(newEntry.GetOutputForTrack(isRunning, false))
-		return
+		return nil
 	},
 }
 
@@ -69,14 +30,15 @@ func init() {
 	trackCmd.Flags().StringVarP(&finish, "finish", "s", "", "Time the activity should finish at\n\nEither in the formats 16:00 / 4:00PM \nor relative to the current time, \ne.g. -0:15 (now minus 15 minutes), +1.50 (now plus 1:30h).\nMust be after --begin time.")
 	trackCmd.Flags().StringVarP(&project, "project", "p", "", "Project to be assigned")
 	trackCmd.Flags().StringVarP(&task, "task", "t", "", "Task to be assigned")
+	trackCmd.Flags().BoolVar(&create, "create", false, "Create the project or task even if its name is close to an existing one")
 	trackCmd.Flags().StringVarP(&notes, "notes", "n", "", "Activity notes")
+	trackCmd.Flags().StringSliceVarP(&attendees, "with", "w", []string{}, "Attendees of the activity, e.g. a meeting (comma separated)")
+	trackCmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Tags of the activity (comma separated)")
+	trackCmd.Flags().StringSliceVar(&references, "ref", []string{}, "Ticket/URL references of the activity, e.g. GH-123 (comma separated)")
+	trackCmd.Flags().StringVar(&trackTaskwarrior, "taskwarrior", "", "Track the Taskwarrior task with this UUID or ID, using its project and description unless --project and --task are given; the time tracked is written back to the task when finishing")
+	trackCmd.Flags().BoolVar(&trackFromGit, "from-git", false, "Track the branch of the git repository in the current directory, using the repository name as project and the ticket ID in the branch name, or the branch name, as task unless --project and --task are given")
 	trackCmd.Flags().BoolVarP(&force, "force", "f", false, "Force begin tracking of a new task \neven though another one is still running \n(ONLY IF YOU KNOW WHAT YOU'RE DOING!)")
 
-	flagName := "task"
-	trackCmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		user := GetCurrentUser()
-		entries, _ := database.ListEntries(user)
-		_, tasks := listProjectsAndTasks(entries)
-		return tasks, cobra.ShellCompDirectiveDefault
-	})
+	trackCmd.RegisterFlagCompletionFunc("project", completeProjects)
+	trackCmd.RegisterFlagCompletionFunc("task", completeTasks)
 }
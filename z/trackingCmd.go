@@ -2,37 +2,44 @@ package z
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 )
 
 var trackingCmd = &cobra.Command{
-	Use:   "tracking",
-	Short: "Currently tracking activity",
-	Long:  "Show currently tracking activity.",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:         "tracking",
+	Short:       "Currently tracking activity",
+	Long:        "Show currently tracking activity.",
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		user := GetCurrentUser()
 
 		runningEntryId, err := database.GetRunningEntryId(user)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
 		if runningEntryId == "" {
-			fmt.Printf("%s not running\n", CharFinish)
-			os.Exit(1)
+			// Scripts check whether something is tracked by the exit code
+			if IsOutputJSON() {
+				fmt.Println("null")
+			} else {
+				fmt.Printf("%s not running\n", CharFinish)
+			}
+			return exitWithCode(ExitError)
 		}
 
 		runningEntry, err := database.GetEntry(user, runningEntryId)
 		if err != nil {
-			fmt.Printf("%s %+v\n", CharError, err)
-			os.Exit(1)
+			return err
 		}
 
-		fmt.Printf(runningEntry.GetOutputForTrack(true, true))
-		return
+		if IsOutputJSON() {
+			return printJSON(EntryResource{ID: runningEntry.ID, Entry: runningEntry})
+		}
+
+		fmt.Print(GetEntryOutputForTrack(runningEntry, true, true))
+		return nil
 	},
 }
 
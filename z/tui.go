@@ -100,7 +100,7 @@ func OutputAppendRight(leftStr string, rightStr string, pad int) string {
 	for lpos := 0; lpos < leftLen; lpos++ {
 		if left[lpos] == '\n' || lpos == (leftLen-1) {
 			output = fmt.Sprintf("%s%*s", output, pad, "")
-			for rpos = rpos; rpos < rightLen; rpos++ {
+			for ; rpos < rightLen; rpos++ {
 				output = fmt.Sprintf("%s%c", output, right[rpos])
 				if right[rpos] == '\n' {
 					rpos++
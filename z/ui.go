@@ -0,0 +1,21 @@
+package z
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// handleUI serves the embedded web UI, which only uses the JSON API and asks
+// for an API token once the server rejects a request.
+func (server *Server) handleUI(mux *http.ServeMux) {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+
+	mux.Handle("GET /", http.FileServerFS(files))
+}
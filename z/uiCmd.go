@@ -0,0 +1,28 @@
+package z
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Interactive terminal UI",
+	Long:  "Show the running activity with a live timer, the most recent activities and a summary of the current week, and start, finish, resume, edit or erase activities using single keys.",
+	Annotations: map[string]string{
+		// The console opens the database itself whenever it needs it
+		AnnotationNoDatabase: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, err := GetTargets()
+		if err != nil {
+			return err
+		}
+
+		return NewConsole(GetCurrentUser(), targets).Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+	uiCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
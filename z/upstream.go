@@ -0,0 +1,178 @@
+package z
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/buntdb"
+)
+
+// Upstream zeit (github.com/mrusme/zeit) keeps activities, projects and tasks
+// in a buntdb database as `<user>:entry:<id>`, `<user>:project:<id>` and
+// `<user>:task:<id>` with the running activity in `<user>:status:running`,
+// and exports activities as a JSON array. Both are read without ever writing
+// to the upstream database.
+
+type UpstreamZeit struct {
+	File     string
+	Entries  []Entry
+	Projects []Project
+	Tasks    []Task
+	Running  string
+	// Users lists all users found in an upstream database
+	Users []string
+}
+
+// FindUpstreamZeit returns the first upstream database at the locations
+// upstream zeit used, other than the database in use.
+func FindUpstreamZeit(current string) (string, error) {
+	for _, path := range GetLegacyDatabasePaths() {
+		if fileExists(path) && !sameFile(path, current) {
+			return path, nil
+		}
+	}
+	return "", errors.New("no upstream zeit database found, specify its file or a `zeit export` of it")
+}
+
+func sameFile(a string, b string) bool {
+	aInfo, aErr := os.Stat(a)
+	bInfo, bErr := os.Stat(b)
+	return aErr == nil && bErr == nil && os.SameFile(aInfo, bInfo)
+}
+
+func upstreamSHA1(value string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte("upstream-zeit\x1f"+value)))
+}
+
+// ReadUpstreamZeit reads the activities, projects and tasks of user from an
+// upstream zeit database or JSON export.
+func ReadUpstreamZeit(file string, user string) (UpstreamZeit, error) {
+	upstream := UpstreamZeit{File: file}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return upstream, err
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return upstream, upstream.readExport(trimmed, user)
+	case bytes.HasPrefix(trimmed, []byte("*")):
+		return upstream, upstream.readDatabase(content, user)
+	case bytes.HasPrefix(content, []byte(encryptionHeader)):
+		return upstream, errors.New("this is an encrypted database of this version of zeit, not an upstream one")
+	}
+
+	return upstream, errors.New("neither an upstream zeit database nor a `zeit export`")
+}
+
+func (upstream *UpstreamZeit) readExport(content []byte, user string) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return fmt.Errorf("invalid zeit export: %v", err)
+	}
+
+	for idx, value := range raw {
+		var entry Entry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return fmt.Errorf("invalid activity #%d: %v", idx+1, err)
+		}
+		entry.User = user
+		entry.SHA1 = upstreamSHA1(string(value))
+		upstream.Entries = append(upstream.Entries, entry)
+	}
+
+	return nil
+}
+
+func (upstream *UpstreamZeit) readDatabase(content []byte, user string) error {
+	db, err := buntdb.Open(":memory:")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err = db.Load(bufio.NewReader(bytes.NewReader(content))); err != nil {
+		return fmt.Errorf("invalid upstream zeit database: %v", err)
+	}
+
+	users := make(map[string]bool)
+	return db.View(func(tx *buntdb.Tx) error {
+		tx.Ascend("", func(key, value string) bool {
+			keyUser, rest, ok := strings.Cut(key, ":")
+			if !ok {
+				return true
+			}
+			users[keyUser] = true
+			if keyUser != user {
+				return true
+			}
+
+			switch {
+			case strings.HasPrefix(rest, "entry:"):
+				var entry Entry
+				if err = json.Unmarshal([]byte(value), &entry); err != nil {
+					err = fmt.Errorf("invalid activity %s: %v", key, err)
+					return false
+				}
+				entry.ID = strings.TrimPrefix(rest, "entry:")
+				entry.User = user
+				entry.SHA1 = upstreamSHA1(key)
+				upstream.Entries = append(upstream.Entries, entry)
+			case strings.HasPrefix(rest, "project:"):
+				var project Project
+				if json.Unmarshal([]byte(value), &project) == nil && project.Name != "" {
+					upstream.Projects = append(upstream.Projects, project)
+				}
+			case strings.HasPrefix(rest, "task:"):
+				var task Task
+				if json.Unmarshal([]byte(value), &task) == nil && task.Name != "" {
+					upstream.Tasks = append(upstream.Tasks, task)
+				}
+			case rest == "status:running":
+				upstream.Running = value
+			}
+			return true
+		})
+
+		for u := range users {
+			upstream.Users = append(upstream.Users, u)
+		}
+		sort.Strings(upstream.Users)
+		sort.Slice(upstream.Entries, func(i, j int) bool { return upstream.Entries[i].Begin.Before(upstream.Entries[j].Begin) })
+		return err
+	})
+}
+
+// SplitRunning splits the activities into the one running in upstream zeit,
+// if any, and the finished ones. Other unfinished activities are counted as
+// stale, as their end is unknown.
+func (upstream *UpstreamZeit) SplitRunning() (Entry, bool, []Entry, int) {
+	var running Entry
+	var found bool
+	var finished []Entry
+	var stale int
+
+	for _, entry := range upstream.Entries {
+		if !entry.Finish.IsZero() {
+			finished = append(finished, entry)
+			continue
+		}
+		if !found && (upstream.Running == "" || entry.ID == upstream.Running) {
+			running = entry
+			found = true
+			continue
+		}
+		stale++
+	}
+
+	return running, found, finished, stale
+}
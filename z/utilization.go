@@ -0,0 +1,166 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+type UtilizationPeriod struct {
+	Name            string  `json:"name"`
+	BillableSeconds int64   `json:"billableSeconds"`
+	TotalSeconds    int64   `json:"totalSeconds"`
+	Utilization     float64 `json:"utilization"`
+}
+
+func (period *UtilizationPeriod) add(billable bool, tracked time.Duration) {
+	if billable {
+		period.BillableSeconds += int64(tracked.Seconds())
+	}
+	period.TotalSeconds += int64(tracked.Seconds())
+}
+
+func (period *UtilizationPeriod) setUtilization(totalSeconds int64) {
+	if totalSeconds > 0 {
+		period.Utilization = float64(period.BillableSeconds) / float64(totalSeconds)
+	}
+}
+
+// Utilization is the share of billable time in the time tracked per week, per
+// month and overall, and the share each client has in it.
+type Utilization struct {
+	Since   time.Time           `json:"since"`
+	Until   time.Time           `json:"until"`
+	Target  float64             `json:"target,omitempty"`
+	Weeks   []UtilizationPeriod `json:"weeks"`
+	Months  []UtilizationPeriod `json:"months"`
+	Clients []UtilizationPeriod `json:"clients"`
+	Total   UtilizationPeriod   `json:"total"`
+}
+
+// GetUtilizationTarget returns the utilization aimed at, configured as
+// utilization.target in percent, e.g. 70 or 70%.
+func GetUtilizationTarget() (float64, error) {
+	value := strings.TrimSpace(viper.GetString("utilization.target"))
+	if value == "" {
+		return 0, nil
+	}
+
+	target, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || target < 0 || target > 100 {
+		return 0, fmt.Errorf("invalid utilization.target '%s', use a percentage like 70", value)
+	}
+	return target / 100, nil
+}
+
+// NewUtilization sums up the activities between since and until per day, so
+// that activities spanning midnight count for the weeks and months they were
+// tracked in. The clients are the ones of invoice.clients.
+func NewUtilization(user string, since time.Time, until time.Time) (Utilization, error) {
+	utilization := Utilization{Since: since, Until: until, Total: UtilizationPeriod{Name: "total"}}
+
+	var err error
+	if utilization.Target, err = GetUtilizationTarget(); err != nil {
+		return utilization, err
+	}
+
+	clients, err := GetInvoiceClients()
+	if err != nil {
+		return utilization, err
+	}
+	clientOf := make(map[string]string)
+	for _, client := range clients {
+		for _, project := range client.Projects {
+			clientOf[strings.ToLower(project)] = client.Name
+		}
+	}
+
+	entries, err := database.ListEntriesBetween(user, since, until)
+	if err != nil {
+		return utilization, err
+	}
+
+	projects := make(map[string]Project)
+	clientTotals := make(map[string]*UtilizationPeriod)
+	for day := now.With(since).BeginningOfDay(); day.Before(until); day = day.AddDate(0, 0, 1) {
+		year, week := day.ISOWeek()
+		weekName := fmt.Sprintf("%d-W%02d", year, week)
+		if len(utilization.Weeks) == 0 || utilization.Weeks[len(utilization.Weeks)-1].Name != weekName {
+			utilization.Weeks = append(utilization.Weeks, UtilizationPeriod{Name: weekName})
+		}
+		if monthName := day.Format("2006-01"); len(utilization.Months) == 0 || utilization.Months[len(utilization.Months)-1].Name != monthName {
+			utilization.Months = append(utilization.Months, UtilizationPeriod{Name: monthName})
+		}
+		weekTotal := &utilization.Weeks[len(utilization.Weeks)-1]
+		monthTotal := &utilization.Months[len(utilization.Months)-1]
+
+		from, to := day, day.AddDate(0, 0, 1)
+		if from.Before(since) {
+			from = since
+		}
+		if to.After(until) {
+			to = until
+		}
+
+		for _, entry := range entries {
+			tracked := zeit.ClippedDuration(entry, from, to)
+			if tracked <= 0 {
+				continue
+			}
+
+			project, ok := projects[entry.Project]
+			if !ok {
+				if project, err = database.GetProject(user, entry.Project); err != nil {
+					return utilization, err
+				}
+				projects[entry.Project] = project
+			}
+
+			weekTotal.add(project.Billable, tracked)
+			monthTotal.add(project.Billable, tracked)
+			utilization.Total.add(project.Billable, tracked)
+
+			client, ok := clientOf[strings.ToLower(entry.Project)]
+			if !ok {
+				client = "(no client)"
+			}
+			if _, ok := clientTotals[client]; !ok {
+				clientTotals[client] = &UtilizationPeriod{Name: client}
+			}
+			clientTotals[client].add(project.Billable, tracked)
+		}
+	}
+
+	for _, client := range clientTotals {
+		utilization.Clients = append(utilization.Clients, *client)
+	}
+	sort.Slice(utilization.Clients, func(i, j int) bool {
+		if utilization.Clients[i].BillableSeconds != utilization.Clients[j].BillableSeconds {
+			return utilization.Clients[i].BillableSeconds > utilization.Clients[j].BillableSeconds
+		}
+		return strings.ToLower(utilization.Clients[i].Name) < strings.ToLower(utilization.Clients[j].Name)
+	})
+
+	// A client's utilization is its share of all the time tracked
+	for idx := range utilization.Clients {
+		utilization.Clients[idx].setUtilization(utilization.Total.TotalSeconds)
+	}
+	for idx := range utilization.Weeks {
+		utilization.Weeks[idx].setUtilization(utilization.Weeks[idx].TotalSeconds)
+	}
+	for idx := range utilization.Months {
+		utilization.Months[idx].setUtilization(utilization.Months[idx].TotalSeconds)
+	}
+	utilization.Total.setUtilization(utilization.Total.TotalSeconds)
+
+	if utilization.Clients == nil {
+		utilization.Clients = []UtilizationPeriod{}
+	}
+	return utilization, nil
+}
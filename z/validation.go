@@ -0,0 +1,200 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Suggestion struct {
+	Description string `json:"description"`
+	Field       string `json:"field,omitempty"`
+	Value       string `json:"value,omitempty"`
+}
+
+type ValidationError struct {
+	Code        string
+	Message     string
+	Field       string
+	Conflicts   []string
+	Suggestions []Suggestion
+}
+
+func (verr *ValidationError) Error() string {
+	return verr.Message
+}
+
+func NewInvalidTimeError(field string, value string) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationInvalidTime,
+		Message: fmt.Sprintf("could not parse %s time '%s'", field, value),
+		Field:   field,
+		Suggestions: []Suggestion{
+			{
+				Description: "use an absolute time like 16:00 / 4:00PM / 2006-01-02 15:04 or a relative one like -0:15 / +1.50",
+				Field:       field,
+			},
+		},
+	}
+}
+
+func NewFinishBeforeBeginError(entry Entry) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationFinishBeforeBegin,
+		Message: "beginning time of tracking cannot be after finish time",
+		Field:   "finish",
+		Suggestions: []Suggestion{
+			{
+				Description: fmt.Sprintf("set finish to a time after %s", entry.Begin.Format(time.RFC3339)),
+				Field:       "finish",
+				Value:       entry.Begin.Format(time.RFC3339),
+			},
+			{
+				Description: fmt.Sprintf("set begin to a time before %s", entry.Finish.Format(time.RFC3339)),
+				Field:       "begin",
+				Value:       entry.Finish.Format(time.RFC3339),
+			},
+		},
+	}
+}
+
+func NewMandatoryError(field string) *ValidationError {
+	var code string = ValidationProjectMandatory
+	if field == "task" {
+		code = ValidationTaskMandatory
+	}
+
+	return &ValidationError{
+		Code:    code,
+		Message: fmt.Sprintf("%s is mandatory but missing", field),
+		Field:   field,
+		Suggestions: []Suggestion{
+			{
+				Description: fmt.Sprintf("pass a %s using --%s", field, field),
+				Field:       field,
+			},
+		},
+	}
+}
+
+func NewOverlapError(entry Entry, entryEnd time.Time, existingEntry Entry, existingEnd time.Time) *ValidationError {
+	verr := &ValidationError{
+		Code: ValidationOverlap,
+		Message: fmt.Sprintf("entry overlaps with existing entry %s (%s to %s)",
+			existingEntry.ID,
+			existingEntry.Begin.Format("2006-01-02 15:04:05"),
+			existingEnd.Format("2006-01-02 15:04:05")),
+		Conflicts: []string{existingEntry.ID},
+	}
+
+	if entry.Begin.Before(existingEntry.Begin) {
+		verr.Suggestions = append(verr.Suggestions, Suggestion{
+			Description: fmt.Sprintf("set finish to %s to end before %s begins", existingEntry.Begin.Format(time.RFC3339), existingEntry.ID),
+			Field:       "finish",
+			Value:       existingEntry.Begin.Format(time.RFC3339),
+		})
+	}
+
+	if entryEnd.After(existingEnd) {
+		verr.Suggestions = append(verr.Suggestions, Suggestion{
+			Description: fmt.Sprintf("set begin to %s to start after %s finished", existingEnd.Format(time.RFC3339), existingEntry.ID),
+			Field:       "begin",
+			Value:       existingEnd.Format(time.RFC3339),
+		})
+	}
+
+	verr.Suggestions = append(verr.Suggestions, Suggestion{
+		Description: fmt.Sprintf("adjust the existing entry using `zeit edit %s`", existingEntry.ID),
+	})
+
+	return verr
+}
+
+func NewSimilarNameError(field string, name string, similar []string) *ValidationError {
+	verr := &ValidationError{
+		Code:    ValidationSimilarName,
+		Message: fmt.Sprintf("%s '%s' does not exist yet, did you mean '%s'?", field, name, similar[0]),
+		Field:   field,
+	}
+
+	for _, existing := range similar {
+		verr.Suggestions = append(verr.Suggestions, Suggestion{
+			Description: fmt.Sprintf("use --%s %s", field, existing),
+			Field:       field,
+			Value:       existing,
+		})
+	}
+	verr.Suggestions = append(verr.Suggestions, Suggestion{
+		Description: fmt.Sprintf("pass --create to create %s '%s'", field, name),
+	})
+
+	return verr
+}
+
+func NewRuleViolationError(project string, field string, message string) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationRuleViolation,
+		Message: fmt.Sprintf("project %s: %s", project, message),
+		Field:   field,
+		Suggestions: []Suggestion{
+			{
+				Description: "check the rules of the project using `zeit project --help`",
+				Field:       field,
+			},
+		},
+	}
+}
+
+func NewScriptViolationError(script string, field string, message string) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationScriptViolation,
+		Message: message,
+		Field:   field,
+		Suggestions: []Suggestion{
+			{
+				Description: fmt.Sprintf("check the validate function of %s in %s", script, GetScriptsDir()),
+				Field:       field,
+			},
+		},
+	}
+}
+
+// ValidateProjectRules checks the entry against the validation rules of its
+// project, of strict mode and of scripts. Notes are only required once the
+// entry is finished.
+func ValidateProjectRules(user string, entry Entry) error {
+	if err := ValidateStrict(user, entry); err != nil {
+		return err
+	}
+
+	if entry.Project == "" {
+		return ValidateScripts(entry)
+	}
+
+	project, err := database.GetProject(user, entry.Project)
+	if err != nil {
+		return err
+	}
+
+	if project.Rules.TaskRequired && entry.Task == "" {
+		return NewRuleViolationError(entry.Project, "task", "task is required")
+	}
+
+	if project.Rules.KnownTasksOnly && entry.Task != "" && !project.HasTask(entry.Task) {
+		verr := NewRuleViolationError(entry.Project, "task", fmt.Sprintf("unknown task %s", entry.Task))
+		for _, task := range project.Tasks {
+			verr.Suggestions = append(verr.Suggestions, Suggestion{
+				Description: fmt.Sprintf("use task %s", task),
+				Field:       "task",
+				Value:       task,
+			})
+		}
+		return verr
+	}
+
+	if project.Rules.NotesRequired && !entry.Finish.IsZero() && strings.TrimSpace(entry.Notes) == "" {
+		return NewRuleViolationError(entry.Project, "notes", "notes are required")
+	}
+
+	return ValidateScripts(entry)
+}
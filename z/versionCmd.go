@@ -13,10 +13,12 @@ func init() {
 }
 
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Display what Zeit it is",
-	Long:  `The version of Zeit.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:         "version",
+	Short:       "Display what Zeit it is",
+	Long:        `The version of Zeit.`,
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("zeit", VERSION)
+		return nil
 	},
 }
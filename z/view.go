@@ -0,0 +1,113 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// View is a named set of flags for a command, configured under
+// `views.<name>` either as a table of flags, e.g.
+//
+//	[views.acme-this-month]
+//	command = "stats"
+//	project = "acme"
+//	period = "this-month"
+//
+// or as a string of key:value filters, e.g.
+//
+//	[views]
+//	acme-this-month = "project:acme period:this-month group-by:day"
+//
+// The command defaults to list.
+type View struct {
+	Name    string
+	Command []string
+	Flags   []ViewFlag
+}
+
+type ViewFlag struct {
+	Name  string
+	Value string
+}
+
+// Args returns the command line the view stands for.
+func (view *View) Args() []string {
+	args := append([]string{}, view.Command...)
+	for _, flag := range view.Flags {
+		args = append(args, "--"+flag.Name+"="+flag.Value)
+	}
+	return args
+}
+
+func (view *View) String() string {
+	return "zeit " + strings.Join(view.Args(), " ")
+}
+
+// GetView returns the view configured as views.<name>.
+func GetView(name string) (View, error) {
+	view := View{Name: name, Command: []string{"list"}}
+
+	key := "views." + name
+	if !viper.IsSet(key) {
+		return view, fmt.Errorf("no view '%s', configure it as %s", name, key)
+	}
+
+	values := make(map[string]string)
+	switch raw := viper.Get(key).(type) {
+	case string:
+		for _, filter := range strings.Fields(raw) {
+			flag, value, ok := strings.Cut(filter, ":")
+			if !ok || flag == "" {
+				return view, fmt.Errorf("invalid filter '%s' in %s, use e.g. project:acme", filter, key)
+			}
+			values[flag] = value
+		}
+	case map[string]interface{}:
+		for flag, value := range raw {
+			if list, ok := value.([]interface{}); ok {
+				var stringValues []string
+				for _, v := range list {
+					stringValues = append(stringValues, fmt.Sprint(v))
+				}
+				values[flag] = strings.Join(stringValues, ",")
+				continue
+			}
+			values[flag] = fmt.Sprint(value)
+		}
+	default:
+		return view, fmt.Errorf("invalid %s, use a table of flags or a string like 'project:acme period:this-month'", key)
+	}
+
+	if command, ok := values["command"]; ok {
+		if view.Command = strings.Fields(command); len(view.Command) == 0 {
+			return view, fmt.Errorf("empty command in %s", key)
+		}
+		delete(values, "command")
+	}
+
+	for flag, value := range values {
+		view.Flags = append(view.Flags, ViewFlag{Name: strings.TrimLeft(flag, "-"), Value: value})
+	}
+	sort.Slice(view.Flags, func(i, j int) bool { return view.Flags[i].Name < view.Flags[j].Name })
+
+	return view, nil
+}
+
+// GetViews returns all views configured under views, sorted by name.
+func GetViews() ([]View, error) {
+	var views []View
+
+	for name := range viper.GetStringMap("views") {
+		view, err := GetView(name)
+		if err != nil {
+			return views, err
+		}
+		views = append(views, view)
+	}
+
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views, nil
+}
@@ -0,0 +1,164 @@
+package z
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var viewCmd = &cobra.Command{
+	Use:   "view [name] [flags for the command]",
+	Short: "Run a saved view",
+	Long:  "Run the command of a view configured under views.<name> with its flags, by default list. Flags given after the name are passed on to the command and replace the same flags of the view, also repeatable ones like --tag. Without a name all views are listed.",
+	Annotations: map[string]string{
+		// The viewed command opens the database itself
+		AnnotationNoDatabase: "true",
+	},
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, extra := splitViewArgs(args)
+		for _, arg := range extra {
+			if arg == "-h" || arg == "--help" {
+				cmd.Help()
+				return nil
+			}
+		}
+
+		if name == "" {
+			views, err := GetViews()
+			if err != nil {
+				return err
+			}
+			if len(views) == 0 {
+				fmt.Printf("%s no views configured\n", CharInfo)
+				return nil
+			}
+			for _, view := range views {
+				fmt.Printf("%s %s: %s\n", CharMore, color.FgLightWhite.Render(view.Name), view.String())
+			}
+			return nil
+		}
+
+		view, err := GetView(name)
+		if err != nil {
+			return err
+		}
+
+		return runView(view, extra)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		views, _ := GetViews()
+		var names []string
+		for _, view := range views {
+			names = append(names, view.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+// splitViewArgs returns the name of the view and the remaining arguments, in
+// which global flags like --db may precede the name, as flag parsing is left
+// to the viewed command.
+func splitViewArgs(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return arg, append(append([]string{}, args[:i]...), args[i+1:]...)
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = rootCmd.PersistentFlags().Lookup(arg[2:])
+		} else {
+			flag = rootCmd.PersistentFlags().ShorthandLookup(arg[len(arg)-1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			i++
+		}
+	}
+	return "", args
+}
+
+// runView runs the command of the view with the view's flags, followed by
+// extra. View flags also given in extra are dropped, so that extra replaces
+// them instead of adding to repeatable flags like --tag.
+func runView(view View, extra []string) error {
+	target, _, err := rootCmd.Find(append(view.Args(), extra...))
+	if err != nil {
+		return err
+	}
+	if target == rootCmd || target.RunE == nil {
+		return fmt.Errorf("unknown command '%s' in view %s", view.Command[0], view.Name)
+	}
+
+	overridden := viewFlagsIn(target, extra)
+	flags := view.Flags[:0:0]
+	for _, flag := range view.Flags {
+		if !overridden[flag.Name] {
+			flags = append(flags, flag)
+		}
+	}
+	view.Flags = flags
+
+	target, args, err := rootCmd.Find(append(view.Args(), extra...))
+	if err != nil {
+		return err
+	}
+
+	if err := target.ParseFlags(args); err != nil {
+		return fmt.Errorf("view %s: %v", view.Name, err)
+	}
+	if err := preRun(target); err != nil {
+		return err
+	}
+	if err := target.RunE(target, target.Flags().Args()); err != nil {
+		return err
+	}
+	postRun(target)
+	return nil
+}
+
+// viewFlagsIn returns the names of the flags of cmd that are given in args,
+// up to a terminating --.
+func viewFlagsIn(cmd *cobra.Command, args []string) map[string]bool {
+	names := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = cmd.Flags().Lookup(name)
+			if flag == nil {
+				flag = cmd.InheritedFlags().Lookup(name)
+			}
+		} else if name != "" {
+			flag = cmd.Flags().ShorthandLookup(name[:1])
+			if flag == nil {
+				flag = cmd.InheritedFlags().ShorthandLookup(name[:1])
+			}
+		}
+		if flag != nil {
+			names[flag.Name] = true
+		}
+	}
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(viewCmd)
+}
@@ -0,0 +1,221 @@
+package z
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// Warm start eases tracking again after a gap of several days, e.g. a
+// vacation, by showing the projects active before the gap as well as
+// recurring activities, like a weekly meeting, that were missed during it.
+
+const (
+	defaultWarmStartGapDays int = 3
+	warmStartProjectDays    int = 14
+	warmStartRecurringWeeks int = 4
+	warmStartMaxProjects    int = 5
+)
+
+type WarmStartProject struct {
+	Project  string
+	Duration time.Duration
+	Last     time.Time
+}
+
+// MissedRecurrence is an activity that was tracked on the same weekday in
+// most weeks before the gap, with its usual clock time and duration.
+type MissedRecurrence struct {
+	Project  string
+	Task     string
+	Weekday  time.Weekday
+	Begin    time.Duration
+	Duration time.Duration
+	Dates    []time.Time
+}
+
+type WarmStart struct {
+	GapBegin time.Time
+	GapEnd   time.Time
+	Projects []WarmStartProject
+	Missed   []MissedRecurrence
+}
+
+func warmStartGapDays() int {
+	if viper.IsSet("warmstart.gapDays") {
+		return viper.GetInt("warmstart.gapDays")
+	}
+	return defaultWarmStartGapDays
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2]
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// GetWarmStart reports whether nothing was tracked for at least
+// `warmstart.gapDays` days (3 by default, 0 disables it) before at, and what
+// was being worked on before.
+func GetWarmStart(user string, at time.Time) (WarmStart, bool, error) {
+	var warmStart WarmStart
+
+	gapDays := warmStartGapDays()
+	if gapDays <= 0 {
+		return warmStart, false, nil
+	}
+
+	entries, err := database.ListEntriesBetween(user, time.Time{}, at)
+	if err != nil {
+		return warmStart, false, err
+	}
+
+	for _, entry := range entries {
+		if !entry.Finish.IsZero() && entry.Finish.After(warmStart.GapBegin) {
+			warmStart.GapBegin = entry.Finish
+		}
+	}
+	warmStart.GapEnd = at
+	if warmStart.GapBegin.IsZero() || at.Sub(warmStart.GapBegin) < time.Duration(gapDays)*24*time.Hour {
+		return warmStart, false, nil
+	}
+
+	projectsSince := warmStart.GapBegin.AddDate(0, 0, -warmStartProjectDays)
+	recurringSince := warmStart.GapBegin.AddDate(0, 0, -7*warmStartRecurringWeeks)
+
+	projects := make(map[string]*WarmStartProject)
+	type recurrence struct {
+		project, task string
+		weekday       time.Weekday
+		weeks         map[string]bool
+		begins        []time.Duration
+		durations     []time.Duration
+	}
+	recurrences := make(map[string]*recurrence)
+
+	for _, entry := range entries {
+		if entry.Finish.IsZero() || entry.Project == "" {
+			continue
+		}
+
+		if entry.Begin.After(projectsSince) {
+			project, ok := projects[entry.Project]
+			if !ok {
+				project = &WarmStartProject{Project: entry.Project}
+				projects[entry.Project] = project
+			}
+			project.Duration += entry.Finish.Sub(entry.Begin)
+			if entry.Finish.After(project.Last) {
+				project.Last = entry.Finish
+			}
+		}
+
+		if entry.Begin.After(recurringSince) {
+			begin := entry.Begin.In(at.Location())
+			key := fmt.Sprintf("%s\x00%s\x00%d", zeit.GetIdFromName(entry.Project), zeit.GetIdFromName(entry.Task), begin.Weekday())
+			r, ok := recurrences[key]
+			if !ok {
+				r = &recurrence{project: entry.Project, task: entry.Task, weekday: begin.Weekday(), weeks: make(map[string]bool)}
+				recurrences[key] = r
+			}
+			year, week := begin.ISOWeek()
+			r.weeks[fmt.Sprintf("%d-%d", year, week)] = true
+			r.begins = append(r.begins, sinceMidnight(begin))
+			r.durations = append(r.durations, entry.Finish.Sub(entry.Begin))
+		}
+	}
+
+	for _, project := range projects {
+		warmStart.Projects = append(warmStart.Projects, *project)
+	}
+	sort.Slice(warmStart.Projects, func(i, j int) bool {
+		if warmStart.Projects[i].Duration != warmStart.Projects[j].Duration {
+			return warmStart.Projects[i].Duration > warmStart.Projects[j].Duration
+		}
+		return warmStart.Projects[i].Project < warmStart.Projects[j].Project
+	})
+	if len(warmStart.Projects) > warmStartMaxProjects {
+		warmStart.Projects = warmStart.Projects[:warmStartMaxProjects]
+	}
+
+	// Days skipped entirely, from the one after the last activity until the
+	// one before today
+	gapBegin := warmStart.GapBegin.In(at.Location())
+	firstDay := time.Date(gapBegin.Year(), gapBegin.Month(), gapBegin.Day()+1, 0, 0, 0, 0, at.Location())
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+
+	for _, r := range recurrences {
+		if len(r.weeks) < warmStartRecurringWeeks-1 {
+			continue
+		}
+		missed := MissedRecurrence{
+			Project:  r.project,
+			Task:     r.task,
+			Weekday:  r.weekday,
+			Begin:    medianDuration(r.begins),
+			Duration: medianDuration(r.durations),
+		}
+		for day := firstDay; day.Before(today); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == r.weekday {
+				missed.Dates = append(missed.Dates, day)
+			}
+		}
+		if len(missed.Dates) > 0 {
+			warmStart.Missed = append(warmStart.Missed, missed)
+		}
+	}
+	sort.Slice(warmStart.Missed, func(i, j int) bool {
+		return warmStart.Missed[i].Dates[0].Add(warmStart.Missed[i].Begin).Before(warmStart.Missed[j].Dates[0].Add(warmStart.Missed[j].Begin))
+	})
+
+	return warmStart, true, nil
+}
+
+func PrintWarmStart(warmStart WarmStart) {
+	days := int(warmStart.GapEnd.Sub(warmStart.GapBegin).Hours() / 24)
+	fmt.Printf("%s welcome back! Nothing was tracked for %d days, since %s\n", CharInfo, days, warmStart.GapBegin.Format(GetTimeDisplayFormat()))
+
+	if len(warmStart.Projects) > 0 {
+		fmt.Printf("%s projects active before:\n", CharMore)
+		for _, project := range warmStart.Projects {
+			fmt.Printf("     %s (%sh, last %s)\n",
+				color.FgLightWhite.Render(project.Project),
+				fmtDuration(project.Duration),
+				project.Last.Format(DateFormat))
+		}
+	}
+
+	for _, missed := range warmStart.Missed {
+		name := missed.Project
+		if missed.Task != "" {
+			name += "/" + missed.Task
+		}
+
+		var dates []string
+		for _, date := range missed.Dates {
+			dates = append(dates, date.Format(DateFormat))
+		}
+
+		fmt.Printf("%s recurring %s on %ss might be missing for %s\n",
+			CharMore,
+			color.FgLightWhite.Render(name),
+			missed.Weekday,
+			strings.Join(dates, ", "))
+
+		begin := missed.Dates[0].Add(missed.Begin)
+		command := fmt.Sprintf("zeit track --project %q", missed.Project)
+		if missed.Task != "" {
+			command += fmt.Sprintf(" --task %q", missed.Task)
+		}
+		command += fmt.Sprintf(" --begin %q --finish %q", begin.Format("2006-01-02 15:04"), begin.Add(missed.Duration).Format("2006-01-02 15:04"))
+		fmt.Printf("     %s\n", color.FgGray.Render(command))
+	}
+}
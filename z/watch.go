@@ -0,0 +1,539 @@
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/spf13/viper"
+)
+
+// `zeit watch` looks at the window in front every watch.interval and tracks
+// the project and task of the first rule matching it, once the window was in
+// front for watch.minimum:
+//
+//	watch:
+//	  rules:
+//	    - app: (?i)code
+//	      title: '(ACME-[0-9]+).*acme'
+//	      project: acme
+//	      task: $1
+//	    - title: (?i)meet\.google\.com
+//	      project: meetings
+//	      confirm: true
+//
+// Matches of rules with confirm, of several rules disagreeing or while an
+// activity tracked by hand is running are uncertain; instead of tracking
+// them they are queued for approval using `zeit watch approve`.
+
+const defaultWatchInterval time.Duration = 10 * time.Second
+
+const defaultWatchMinimum time.Duration = time.Minute
+
+type Window struct {
+	App   string `json:"app"`
+	Title string `json:"title"`
+}
+
+type WatchRule struct {
+	App     string `mapstructure:"app"`
+	Title   string `mapstructure:"title"`
+	Project string `mapstructure:"project"`
+	Task    string `mapstructure:"task"`
+	Confirm bool   `mapstructure:"confirm"`
+
+	app   *regexp.Regexp
+	title *regexp.Regexp
+}
+
+// WatchMatch is what a window is about according to the rules.
+type WatchMatch struct {
+	Project string
+	Task    string
+	Certain bool
+	Reason  string
+}
+
+// WatchSuggestion is an uncertain match waiting for approval.
+type WatchSuggestion struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	App     string    `json:"app"`
+	Title   string    `json:"title"`
+	Project string    `json:"project"`
+	Task    string    `json:"task"`
+	Reason  string    `json:"reason"`
+}
+
+type WatchQueue struct {
+	Next        int               `json:"next"`
+	Suggestions []WatchSuggestion `json:"suggestions"`
+}
+
+// watchName names the project and task the way suggestions show them.
+func watchName(project string, task string) string {
+	if task == "" {
+		return project
+	}
+	return task + " on " + project
+}
+
+// GetWatchRules reads and compiles watch.rules.
+func GetWatchRules() ([]WatchRule, error) {
+	return readWatchRules("watch.rules")
+}
+
+// readWatchRules reads and compiles the rules configured as key.
+func readWatchRules(key string) ([]WatchRule, error) {
+	var rules []WatchRule
+
+	if err := viper.UnmarshalKey(key, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", key, err)
+	}
+
+	for idx := range rules {
+		var err error
+		if rules[idx].App == "" && rules[idx].Title == "" {
+			return nil, fmt.Errorf("%s[%d] needs an app or a title to match", key, idx)
+		}
+		if rules[idx].Project == "" {
+			return nil, fmt.Errorf("%s[%d] needs a project", key, idx)
+		}
+		if rules[idx].App != "" {
+			if rules[idx].app, err = regexp.Compile(rules[idx].App); err != nil {
+				return nil, fmt.Errorf("invalid %s[%d].app: %v", key, idx, err)
+			}
+		}
+		if rules[idx].Title != "" {
+			if rules[idx].title, err = regexp.Compile(rules[idx].Title); err != nil {
+				return nil, fmt.Errorf("invalid %s[%d].title: %v", key, idx, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// match returns the project and task of the rule for the window, with the
+// groups of the title, e.g. $1, expanded.
+func (rule *WatchRule) match(window Window) (string, string, bool) {
+	if rule.app != nil && !rule.app.MatchString(window.App) {
+		return "", "", false
+	}
+	if rule.title == nil {
+		return rule.Project, rule.Task, true
+	}
+
+	submatches := rule.title.FindStringSubmatchIndex(window.Title)
+	if submatches == nil {
+		return "", "", false
+	}
+	project := string(rule.title.ExpandString(nil, rule.Project, window.Title, submatches))
+	task := string(rule.title.ExpandString(nil, rule.Task, window.Title, submatches))
+	return project, task, true
+}
+
+// MatchWatchRules returns what the window is about according to the first
+// matching rule, and whether any rule matched at all.
+func MatchWatchRules(rules []WatchRule, window Window) (WatchMatch, bool) {
+	var match WatchMatch
+	var matched bool
+
+	for idx := range rules {
+		project, task, ok := rules[idx].match(window)
+		if !ok {
+			continue
+		}
+		if !matched {
+			match = WatchMatch{Project: project, Task: task, Certain: !rules[idx].Confirm}
+			if rules[idx].Confirm {
+				match.Reason = "the rule asks for confirmation"
+			}
+			matched = true
+		} else if match.Certain && (!strings.EqualFold(project, match.Project) || !strings.EqualFold(task, match.Task)) {
+			match.Certain = false
+			match.Reason = "rules disagree, it could also be " + watchName(project, task)
+		}
+	}
+	return match, matched
+}
+
+// GetActiveWindow returns the window in front, as printed by watch.command
+// as the app and title separated by a tab, as last reported by the window
+// watcher of ActivityWatch at watch.activitywatch, or as told by the
+// platform.
+func GetActiveWindow() (Window, error) {
+	if command := viper.GetString("watch.command"); command != "" {
+		output, err := exec.Command(ExpandPath(command)).Output()
+		if err != nil {
+			return Window{}, fmt.Errorf("%s: %v", command, err)
+		}
+		app, title, _ := strings.Cut(strings.TrimRight(string(output), "\r\n"), "\t")
+		return Window{App: app, Title: title}, nil
+	}
+
+	if server := viper.GetString("watch.activitywatch"); server != "" {
+		return activityWatchWindow(strings.TrimSuffix(server, "/"))
+	}
+
+	return platformActiveWindow()
+}
+
+// runWindowHelper runs a tool telling the window in front and returns its
+// output.
+func runWindowHelper(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("%s was not found, please install it or configure watch.command", name)
+	} else if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func activityWatchGet(target string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "zeit/"+VERSION)
+
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", http.MethodGet, req.URL.Redacted(), res.Status)
+	}
+	return json.Unmarshal(content, v)
+}
+
+// activityWatchWindow returns the window of the latest event of the most
+// recently updated currentwindow bucket.
+func activityWatchWindow(server string) (Window, error) {
+	var buckets map[string]struct {
+		Type        string `json:"type"`
+		LastUpdated string `json:"last_updated"`
+	}
+	if err := activityWatchGet(server+"/api/0/buckets/", &buckets); err != nil {
+		return Window{}, err
+	}
+
+	var bucket, lastUpdated string
+	for id, info := range buckets {
+		if info.Type == "currentwindow" && info.LastUpdated >= lastUpdated {
+			bucket, lastUpdated = id, info.LastUpdated
+		}
+	}
+	if bucket == "" {
+		return Window{}, errors.New("ActivityWatch has no window watcher bucket, is aw-watcher-window running?")
+	}
+
+	var events []struct {
+		Data Window `json:"data"`
+	}
+	if err := activityWatchGet(server+"/api/0/buckets/"+url.PathEscape(bucket)+"/events?limit=1", &events); err != nil {
+		return Window{}, err
+	}
+	if len(events) == 0 {
+		return Window{}, nil
+	}
+	return events[0].Data, nil
+}
+
+func GetWatchQueuePath() string {
+	dataHome := GetDataHome()
+	if dataHome == "" {
+		return ""
+	}
+
+	return filepath.Join(dataHome, "zeit", "watch-queue.json")
+}
+
+func ReadWatchQueue() (WatchQueue, error) {
+	queue := WatchQueue{Next: 1, Suggestions: []WatchSuggestion{}}
+
+	path := GetWatchQueuePath()
+	if path == "" {
+		return queue, errors.New("could not find the data directory for the approval queue")
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return queue, nil
+	} else if err != nil {
+		return queue, err
+	}
+	if err = json.Unmarshal(content, &queue); err != nil {
+		return queue, fmt.Errorf("could not read the approval queue %s: %v", path, err)
+	}
+	return queue, nil
+}
+
+func WriteWatchQueue(queue WatchQueue) error {
+	path := GetWatchQueuePath()
+	if path == "" {
+		return errors.New("could not find the data directory for the approval queue")
+	}
+
+	content, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomically(path, content)
+}
+
+// QueueWatchSuggestion adds the suggestion to the approval queue, unless the
+// same project and task are waiting for approval already.
+func QueueWatchSuggestion(suggestion WatchSuggestion) (WatchSuggestion, bool, error) {
+	queue, err := ReadWatchQueue()
+	if err != nil {
+		return suggestion, false, err
+	}
+
+	for _, queued := range queue.Suggestions {
+		if strings.EqualFold(queued.Project, suggestion.Project) && strings.EqualFold(queued.Task, suggestion.Task) {
+			return queued, false, nil
+		}
+	}
+
+	suggestion.ID = queue.Next
+	queue.Next++
+	queue.Suggestions = append(queue.Suggestions, suggestion)
+	return suggestion, true, WriteWatchQueue(queue)
+}
+
+// TakeWatchSuggestion removes the suggestion from the approval queue.
+func TakeWatchSuggestion(id int) (WatchSuggestion, error) {
+	queue, err := ReadWatchQueue()
+	if err != nil {
+		return WatchSuggestion{}, err
+	}
+
+	for idx, suggestion := range queue.Suggestions {
+		if suggestion.ID == id {
+			queue.Suggestions = append(queue.Suggestions[:idx], queue.Suggestions[idx+1:]...)
+			return suggestion, WriteWatchQueue(queue)
+		}
+	}
+	return WatchSuggestion{}, fmt.Errorf("no suggestion %d waiting for approval", id)
+}
+
+func (suggestion WatchSuggestion) GetOutput() string {
+	return fmt.Sprintf("%s %s %s since %s, %s\n   %s\n",
+		CharMore,
+		color.FgLightWhite.Render(fmt.Sprintf("%d", suggestion.ID)),
+		color.FgLightWhite.Render(watchName(suggestion.Project, suggestion.Task)),
+		suggestion.Time.Format(GetTimeDisplayFormat()),
+		suggestion.Reason,
+		color.FgGray.Render(strings.TrimPrefix(suggestion.App+": "+suggestion.Title, ": ")))
+}
+
+// switchWatchEntry tracks the project and task from the time on, finishing
+// the running entry then. If the running entry began later, tracking begins
+// now instead.
+func switchWatchEntry(user string, project string, task string, since time.Time) (string, error) {
+	var output string
+
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return "", err
+	}
+	if runningEntryId != "" {
+		runningEntry, err := database.GetEntry(user, runningEntryId)
+		if err != nil {
+			return "", err
+		}
+		if !since.After(runningEntry.Begin) {
+			since = time.Now()
+		}
+		if runningEntry, err = finishRunningEntryAt(user, runningEntryId, since); err != nil {
+			return "", err
+		}
+		output = GetEntryOutputForFinish(runningEntry)
+	} else {
+		// Nor may it overlap an entry finished since
+		latest, err := ListLatestEntries(user, since, time.Time{}, func(Entry) bool { return true }, 0, 1)
+		if err != nil {
+			return "", err
+		}
+		if len(latest) > 0 && latest[0].Finish.After(since) {
+			since = latest[0].Finish
+		}
+	}
+
+	entry, err := NewEntry("", "", "", project, task, user)
+	if err != nil {
+		return "", err
+	}
+	entry.Begin = since
+	if entry, err = trackRunningEntry(user, entry); err != nil {
+		return "", err
+	}
+	return output + GetEntryOutputForTrack(entry, true, false), nil
+}
+
+// ApproveWatchSuggestion tracks the project and task of the suggestion from
+// the time it was suggested.
+func ApproveWatchSuggestion(user string, id int) (string, error) {
+	suggestion, err := TakeWatchSuggestion(id)
+	if err != nil {
+		return "", err
+	}
+	return switchWatchEntry(user, suggestion.Project, suggestion.Task, suggestion.Time)
+}
+
+// watchState is the match in front and since when, and the entry tracked by
+// watching, which is the only one switched automatically.
+type watchState struct {
+	match   WatchMatch
+	window  Window
+	since   time.Time
+	handled bool
+	tracked string
+}
+
+// handleWatchMatch tracks the match that was in front for long enough, or
+// queues it for approval if uncertain.
+func handleWatchMatch(user string, state *watchState, notify NotifySettings) (string, error) {
+	runningEntryId, err := database.GetRunningEntryId(user)
+	if err != nil {
+		return "", err
+	}
+
+	match := state.match
+	if runningEntryId != "" {
+		runningEntry, err := database.GetEntry(user, runningEntryId)
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(runningEntry.Project, match.Project) && strings.EqualFold(runningEntry.Task, match.Task) {
+			return "", nil
+		}
+		if match.Certain && runningEntryId != state.tracked {
+			match.Certain = false
+			match.Reason = watchName(runningEntry.Project, runningEntry.Task) + " was tracked by hand"
+		}
+	}
+
+	if !match.Certain {
+		suggestion, added, err := QueueWatchSuggestion(WatchSuggestion{
+			Time:    state.since,
+			App:     state.window.App,
+			Title:   state.window.Title,
+			Project: match.Project,
+			Task:    match.Task,
+			Reason:  match.Reason,
+		})
+		if err != nil || !added {
+			return "", err
+		}
+		notification := Notification{
+			Title:   "Track " + watchName(match.Project, match.Task) + "?",
+			Message: fmt.Sprintf("Run `zeit watch approve %d` to track it since %s.", suggestion.ID, suggestion.Time.Format("15:04")),
+		}
+		if err = SendNotification(notify, notification); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+		}
+		return fmt.Sprintf("%s waiting for approval:\n%s", CharInfo, suggestion.GetOutput()), nil
+	}
+
+	output, err := switchWatchEntry(user, match.Project, match.Task, state.since)
+	if err != nil {
+		return "", err
+	}
+	if state.tracked, err = database.GetRunningEntryId(user); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// getWatchDuration reads a duration like 10s or 1m from the config.
+func getWatchDuration(key string, fallback time.Duration) (time.Duration, error) {
+	if !viper.IsSet(key) {
+		return fallback, nil
+	}
+
+	value, err := time.ParseDuration(viper.GetString(key))
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s', use e.g. %s", key, viper.GetString(key), fallback)
+	}
+	return value, nil
+}
+
+// Watch tracks the windows in front according to the rules until it is
+// interrupted. The database is only opened while tracking.
+func Watch(user string, rules []WatchRule) error {
+	interval, err := getWatchDuration("watch.interval", defaultWatchInterval)
+	if err != nil {
+		return err
+	}
+	minimum, err := getWatchDuration("watch.minimum", defaultWatchMinimum)
+	if err != nil {
+		return err
+	}
+	notify, err := GetNotifySettings()
+	if err != nil {
+		return err
+	}
+
+	closeDatabase()
+
+	var state watchState
+	var windowErr error
+	for ; ; time.Sleep(interval) {
+		window, err := GetActiveWindow()
+		if err != nil {
+			// Told once, e.g. while ActivityWatch is not running
+			if windowErr == nil || err.Error() != windowErr.Error() {
+				fmt.Fprintf(os.Stderr, "%s %+v\n", CharError, err)
+			}
+			windowErr = err
+			continue
+		}
+		windowErr = nil
+
+		match, ok := MatchWatchRules(rules, window)
+		if !ok {
+			state.match, state.since = WatchMatch{}, time.Time{}
+			continue
+		}
+		if state.since.IsZero() || !strings.EqualFold(match.Project, state.match.Project) || !strings.EqualFold(match.Task, state.match.Task) {
+			state.match, state.window, state.since, state.handled = match, window, time.Now(), false
+		}
+		if state.handled || time.Since(state.since) < minimum {
+			continue
+		}
+
+		closeStorage, err := OpenStorage(true, true)
+		if err != nil {
+			return err
+		}
+		output, err := handleWatchMatch(user, &state, notify)
+		closeStorage()
+		if err != nil {
+			return err
+		}
+		state.handled = true
+		fmt.Print(output)
+	}
+}
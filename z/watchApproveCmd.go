@@ -0,0 +1,33 @@
+package z
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var watchApproveCmd = &cobra.Command{
+	Use:   "approve [id]",
+	Short: "Track an activity waiting for approval",
+	Long:  "Track the project and task suggested by `zeit watch` since the time it was suggested, finishing the running activity then.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("'%s' is not the ID of a suggestion, see `zeit watch queue`", args[0])
+		}
+
+		output, err := ApproveWatchSuggestion(GetCurrentUser(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	watchCmd.AddCommand(watchApproveCmd)
+}
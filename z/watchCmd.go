@@ -0,0 +1,34 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Track activities by the window in front",
+	Long:  "Keep running and track the project and task of the first rule in watch.rules matching the app and title of the window in front, once it was in front for watch.minimum (default 1m). The window is told by the platform, the window watcher of ActivityWatch at watch.activitywatch or watch.command. Uncertain matches are queued for approval instead, see `zeit watch queue`; activities tracked by hand are never switched automatically.",
+	Args:  cobra.NoArgs,
+	Annotations: map[string]string{
+		AnnotationReadOnly: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rules, err := GetWatchRules()
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return errors.New("no rules configured, please configure watch.rules")
+		}
+
+		fmt.Printf("%s watching the window in front with %d rules, press Ctrl+C to stop\n", CharInfo, len(rules))
+		return Watch(GetCurrentUser(), rules)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,38 @@
+package z
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var watchQueueCmd = &cobra.Command{
+	Use:         "queue",
+	Short:       "List activities waiting for approval",
+	Long:        "List the uncertain matches of `zeit watch` waiting to be approved using `zeit watch approve` or rejected using `zeit watch reject`.",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		queue, err := ReadWatchQueue()
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(queue.Suggestions)
+		}
+
+		if len(queue.Suggestions) == 0 {
+			fmt.Printf("%s nothing waiting for approval\n", CharInfo)
+			return nil
+		}
+		for _, suggestion := range queue.Suggestions {
+			fmt.Print(suggestion.GetOutput())
+		}
+		return nil
+	},
+}
+
+func init() {
+	watchCmd.AddCommand(watchQueueCmd)
+}
@@ -0,0 +1,53 @@
+package z
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var watchRejectAll bool
+
+var watchRejectCmd = &cobra.Command{
+	Use:         "reject ([flags]) [id]",
+	Short:       "Reject an activity waiting for approval",
+	Long:        "Remove a suggestion of `zeit watch` from the approval queue without tracking it, or all of them using --all.",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{AnnotationNoDatabase: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchRejectAll {
+			queue, err := ReadWatchQueue()
+			if err != nil {
+				return err
+			}
+			rejected := len(queue.Suggestions)
+			queue.Suggestions = []WatchSuggestion{}
+			if err = WriteWatchQueue(queue); err != nil {
+				return err
+			}
+			fmt.Printf("%s rejected %d suggestions\n", CharErase, rejected)
+			return nil
+		}
+
+		if len(args) == 0 {
+			return fmt.Errorf("please give the ID of a suggestion, see `zeit watch queue`, or use --all")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("'%s' is not the ID of a suggestion, see `zeit watch queue`", args[0])
+		}
+
+		suggestion, err := TakeWatchSuggestion(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s rejected %s\n", CharErase, watchName(suggestion.Project, suggestion.Task))
+		return nil
+	},
+}
+
+func init() {
+	watchCmd.AddCommand(watchRejectCmd)
+	watchRejectCmd.Flags().BoolVar(&watchRejectAll, "all", false, "Reject all suggestions")
+}
@@ -0,0 +1,65 @@
+package z
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Export watermarks remember, per destination, the content of every entry as
+// it was last exported there, so that incremental exports only emit entries
+// that were added or changed since. Entries carry no modification time, so
+// changes are told by their content hash.
+
+const exportWatermarkMetaKey string = "export:watermark:"
+
+type ExportWatermark struct {
+	Exported time.Time         `json:"exported"`
+	Hashes   map[string]string `json:"hashes"`
+}
+
+func GetExportWatermark(user string, destination string) (ExportWatermark, error) {
+	watermark := ExportWatermark{Hashes: make(map[string]string)}
+
+	value, err := database.GetMeta(user, exportWatermarkMetaKey+strings.ToLower(destination))
+	if err != nil || value == "" {
+		return watermark, err
+	}
+	if err = json.Unmarshal([]byte(value), &watermark); err != nil {
+		return watermark, fmt.Errorf("could not read the export watermark of %s", destination)
+	}
+	if watermark.Hashes == nil {
+		watermark.Hashes = make(map[string]string)
+	}
+	return watermark, nil
+}
+
+// Changed returns the finished entries added or changed since the last
+// export. Running entries are left for when they are finished.
+func (watermark *ExportWatermark) Changed(entries []Entry) []Entry {
+	var changed []Entry
+	for _, entry := range entries {
+		if entry.Finish.IsZero() {
+			continue
+		}
+		if hash, ok := watermark.Hashes[entry.ID]; !ok || hash != syncHash(entry) {
+			changed = append(changed, entry)
+		}
+	}
+	return changed
+}
+
+// Update marks the entries as exported to the destination.
+func (watermark *ExportWatermark) Update(user string, destination string, entries []Entry) error {
+	watermark.Exported = time.Now().UTC()
+	for _, entry := range entries {
+		watermark.Hashes[entry.ID] = syncHash(entry)
+	}
+
+	value, err := json.Marshal(watermark)
+	if err != nil {
+		return err
+	}
+	return database.SetMeta(user, exportWatermarkMetaKey+strings.ToLower(destination), string(value))
+}
@@ -0,0 +1,139 @@
+package z
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+// Watson keeps its frames as a JSON array of
+// [start, stop, project, id, tags, updated_at] with Unix timestamps in the
+// file `frames` of its directory.
+
+// WatsonMapping maps the projects of Watson to zeit projects and its tags to
+// zeit tasks. The first tag of a frame mapped to a task becomes the task of
+// the activity, other tags stay tags.
+type WatsonMapping struct {
+	Projects map[string]string
+	Tasks    map[string]string
+}
+
+func NewWatsonMapping(projectMappings []string, taskMappings []string) (WatsonMapping, error) {
+	var mapping WatsonMapping
+	var err error
+
+	if mapping.Projects, err = importMapping("watson.projects", projectMappings); err != nil {
+		return mapping, err
+	}
+	if mapping.Tasks, err = importMapping("watson.tasks", taskMappings); err != nil {
+		return mapping, err
+	}
+	return mapping, nil
+}
+
+// GetWatsonFramesPath returns the frames file of Watson, in $WATSON_DIR or
+// its default directory.
+func GetWatsonFramesPath() string {
+	if dir := os.Getenv("WATSON_DIR"); dir != "" {
+		return filepath.Join(dir, "frames")
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = GetConfigHome()
+	}
+	return filepath.Join(dir, "watson", "frames")
+}
+
+func watsonTime(value interface{}) (time.Time, error) {
+	timestamp, ok := value.(float64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%v'", value)
+	}
+	seconds, fraction := math.Modf(timestamp)
+	return time.Unix(int64(seconds), int64(fraction*1e9)).Local(), nil
+}
+
+func (mapping *WatsonMapping) entry(user string, frame []interface{}) (Entry, error) {
+	var entry Entry
+
+	if len(frame) < 5 {
+		return entry, fmt.Errorf("invalid frame %v", frame)
+	}
+	id, _ := frame[3].(string)
+	project, _ := frame[2].(string)
+
+	begin, err := watsonTime(frame[0])
+	if err != nil {
+		return entry, fmt.Errorf("frame %s: %v", id, err)
+	}
+	finish, err := watsonTime(frame[1])
+	if err != nil {
+		return entry, fmt.Errorf("frame %s: %v", id, err)
+	}
+
+	entry = Entry{
+		Begin:   begin,
+		Finish:  finish,
+		Project: project,
+		User:    user,
+		SHA1:    fmt.Sprintf("%x", sha1.Sum([]byte("watson\x1f"+id))),
+	}
+	if mapped, ok := mapping.Projects[strings.ToLower(project)]; ok {
+		entry.Project = mapped
+	}
+
+	frameTags, _ := frame[4].([]interface{})
+	var tags []string
+	for _, value := range frameTags {
+		tag, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if mapped, ok := mapping.Tasks[strings.ToLower(tag)]; ok && entry.Task == "" {
+			entry.Task = mapped
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	entry.Tags = zeit.ParseTags(tags)
+
+	if !entry.IsFinishedAfterBegan() {
+		return entry, fmt.Errorf("frame %s: %v", id, NewFinishBeforeBeginError(entry))
+	}
+	return entry, nil
+}
+
+// ImportWatson reads the frames of Watson. Frames that can't be read don't
+// stop the import but are returned as errors.
+func ImportWatson(user string, file string, mapping WatsonMapping) ([]Entry, []error, error) {
+	var entries []Entry
+	var frameErrors []error
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return entries, frameErrors, err
+	}
+
+	var frames [][]interface{}
+	if err = json.Unmarshal(content, &frames); err != nil {
+		return entries, frameErrors, fmt.Errorf("not a Watson frames file: %v", err)
+	}
+
+	for _, frame := range frames {
+		entry, err := mapping.entry(user, frame)
+		if err != nil {
+			frameErrors = append(frameErrors, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, frameErrors, nil
+}
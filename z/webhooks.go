@@ -0,0 +1,213 @@
+package z
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultWebhookRetries int = 3
+
+// webhookBackoff is the delay before the first retry, doubling with every
+// further one.
+const webhookBackoff time.Duration = time.Second
+
+// defaultWebhookTimeout is how long commands wait for all webhooks of an
+// event including retries unless hooks.webhookTimeout is set.
+const defaultWebhookTimeout time.Duration = 10 * time.Second
+
+// Webhook is an HTTP endpoint configured under `webhooks`, which the
+// activities of its events are posted to.
+type Webhook struct {
+	URL     string            `mapstructure:"url"`
+	Secret  string            `mapstructure:"secret"`
+	Events  []string          `mapstructure:"events"`
+	Headers map[string]string `mapstructure:"headers"`
+	Retries *int              `mapstructure:"retries"`
+}
+
+// WebhookPayload is the body posted to webhooks; post-import events carry
+// the imported entries instead of an entry.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Entry     *HookEntry  `json:"entry,omitempty"`
+	Entries   []HookEntry `json:"entries,omitempty"`
+}
+
+func WebhookEvents() []string {
+	return []string{
+		HookPostTrack,
+		HookPostFinish,
+		HookPostEdit,
+		HookPostImport,
+	}
+}
+
+// GetWebhooks returns the webhooks configured as `webhooks`. Events may be
+// given without their post- prefix and default to all events.
+func GetWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+
+	if err := viper.UnmarshalKey("webhooks", &webhooks); err != nil {
+		return webhooks, fmt.Errorf("invalid webhooks: %v", err)
+	}
+
+	for idx := range webhooks {
+		webhook := &webhooks[idx]
+		if webhook.URL == "" {
+			return webhooks, fmt.Errorf("webhook %d has no url", idx+1)
+		}
+		webhook.URL = os.ExpandEnv(webhook.URL)
+		webhook.Secret = os.ExpandEnv(webhook.Secret)
+		for name, value := range webhook.Headers {
+			webhook.Headers[name] = os.ExpandEnv(value)
+		}
+
+		if len(webhook.Events) == 0 {
+			webhook.Events = WebhookEvents()
+		}
+		for eventIdx, event := range webhook.Events {
+			event = strings.ToLower(event)
+			if !strings.HasPrefix(event, "post-") {
+				event = "post-" + event
+			}
+			if !slices.Contains(WebhookEvents(), event) {
+				return webhooks, fmt.Errorf("webhook %s has unknown event '%s', possible values: %s", webhook.URL, webhook.Events[eventIdx], strings.Join(WebhookEvents(), ", "))
+			}
+			webhook.Events[eventIdx] = event
+		}
+	}
+
+	return webhooks, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body using the secret of
+// the webhook, as sent in the X-Zeit-Signature header.
+func (webhook *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post posts the body to the webhook, retrying on network errors, rate
+// limits and server errors with an exponential backoff until ctx is done.
+func (webhook *Webhook) Post(ctx context.Context, event string, body []byte) error {
+	retries := defaultWebhookRetries
+	if webhook.Retries != nil {
+		retries = *webhook.Retries
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	backoff := webhookBackoff
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body)); err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "zeit/"+VERSION)
+		req.Header.Set("X-Zeit-Event", event)
+		if webhook.Secret != "" {
+			req.Header.Set("X-Zeit-Signature", webhook.Sign(body))
+		}
+		for name, value := range webhook.Headers {
+			req.Header.Set(name, value)
+		}
+
+		var res *http.Response
+		if res, err = client.Do(req); err != nil {
+			continue
+		}
+		res.Body.Close()
+
+		if res.StatusCode >= 200 && res.StatusCode <= 299 {
+			return nil
+		}
+		err = fmt.Errorf("webhook %s: %s", req.URL.Redacted(), res.Status)
+		if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+			return err
+		}
+	}
+
+	return err
+}
+
+// DeliverWebhooks posts the payload of a post- hook to all webhooks
+// subscribed to the event, in parallel, and waits for them to finish, but no
+// longer than hooks.webhookTimeout.
+func DeliverWebhooks(event string, payload interface{}) error {
+	webhooks, err := GetWebhooks()
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	timeout := defaultWebhookTimeout
+	if viper.IsSet("hooks.webhookTimeout") {
+		if timeout, err = time.ParseDuration(viper.GetString("hooks.webhookTimeout")); err != nil {
+			return fmt.Errorf("invalid hooks.webhookTimeout: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body := WebhookPayload{Event: event, Timestamp: time.Now()}
+	switch hookPayload := payload.(type) {
+	case HookEntry:
+		body.Entry = &hookPayload
+	case []HookEntry:
+		body.Entries = hookPayload
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(webhooks))
+	for idx := range webhooks {
+		if !slices.Contains(webhooks[idx].Events, event) {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = webhooks[idx].Post(ctx, event, data)
+		}(idx)
+	}
+	wg.Wait()
+
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package z
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWebhookSign(t *testing.T) {
+	webhook := Webhook{Secret: "secret"}
+
+	signature := webhook.Sign([]byte(`{"event":"post-track"}`))
+	if want := "sha256=61d58f65add288c03f26b5c06f1c729502046344f213dbaa9c170fcaae40e5d9"; signature != want {
+		t.Errorf("Sign() = %s, want %s", signature, want)
+	}
+}
+
+func TestGetWebhooks(t *testing.T) {
+	t.Setenv("ZEIT_TEST_TOKEN", "token")
+	setTestConfig(t, "webhooks", []map[string]interface{}{
+		{"url": "https://example.com/zeit", "events": []string{"track", "Post-Finish"}},
+		{"url": "https://example.com/${ZEIT_TEST_TOKEN}", "secret": "$ZEIT_TEST_TOKEN"},
+	})
+
+	webhooks, err := GetWebhooks()
+	if err != nil {
+		t.Fatalf("GetWebhooks() failed: %v", err)
+	}
+	if len(webhooks) != 2 {
+		t.Fatalf("GetWebhooks() = %+v, want 2 webhooks", webhooks)
+	}
+	if !slices.Equal(webhooks[0].Events, []string{HookPostTrack, HookPostFinish}) {
+		t.Errorf("GetWebhooks() events = %v, want them with their post- prefix", webhooks[0].Events)
+	}
+	if !slices.Equal(webhooks[1].Events, WebhookEvents()) {
+		t.Errorf("GetWebhooks() events = %v, want all events by default", webhooks[1].Events)
+	}
+	if webhooks[1].URL != "https://example.com/token" || webhooks[1].Secret != "token" {
+		t.Errorf("GetWebhooks() = %+v, want the environment variables expanded", webhooks[1])
+	}
+
+	for _, invalid := range []map[string]interface{}{
+		{"url": "https://example.com/zeit", "events": []string{"pre-track"}},
+		{"events": []string{"track"}},
+	} {
+		setTestConfig(t, "webhooks", []map[string]interface{}{invalid})
+		if _, err = GetWebhooks(); err == nil {
+			t.Errorf("GetWebhooks() accepted %v", invalid)
+		}
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	var mutex sync.Mutex
+	var received []WebhookPayload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		webhook := Webhook{Secret: "secret"}
+		if r.Header.Get("X-Zeit-Signature") != webhook.Sign(body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var payload WebhookPayload
+		json.Unmarshal(body, &payload)
+		mutex.Lock()
+		received = append(received, payload)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	setTestConfig(t, "webhooks", []map[string]interface{}{
+		{"url": server.URL, "secret": "secret", "events": []string{"finish"}},
+	})
+
+	entry := NewHookEntry(Entry{ID: "1", Project: "zeit"})
+	for _, event := range []string{HookPostTrack, HookPostFinish} {
+		if err := DeliverWebhooks(event, entry); err != nil {
+			t.Fatalf("DeliverWebhooks() failed: %v", err)
+		}
+	}
+
+	if len(received) != 1 || received[0].Event != HookPostFinish || received[0].Entry == nil || received[0].Entry.ID != "1" {
+		t.Errorf("the webhook received %+v, want only the post-finish event with the entry", received)
+	}
+}
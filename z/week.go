@@ -0,0 +1,194 @@
+package z
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+)
+
+var isoWeekRegexp = regexp.MustCompile(`^(?i)(?:(\d{4})-?)?W?(\d{1,2})$`)
+
+// ISOWeekMonday returns the Monday of the ISO week of year.
+func ISOWeekMonday(year int, week int, location *time.Location) (time.Time, error) {
+	// January 4th is always in the first ISO week.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, location)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+
+	if y, w := monday.ISOWeek(); week < 1 || y != year || w != week {
+		return monday, fmt.Errorf("%d has no week %d", year, week)
+	}
+	return monday, nil
+}
+
+// ParseISOWeek reads a week like 2024-W10, W10 or 10, the latter two in the
+// current year, and returns its Monday.
+func ParseISOWeek(value string) (time.Time, error) {
+	match := isoWeekRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid week '%s', use e.g. 2024-W10 or W10", value)
+	}
+
+	year, _ := time.Now().ISOWeek()
+	if match[1] != "" {
+		year, _ = strconv.Atoi(match[1])
+	}
+	week, _ := strconv.Atoi(match[2])
+
+	return ISOWeekMonday(year, week, time.Local)
+}
+
+type WeekSheetProject struct {
+	Project      string   `json:"project"`
+	Seconds      [7]int64 `json:"seconds"`
+	TotalSeconds int64    `json:"totalSeconds"`
+}
+
+type WeekSheetDay struct {
+	Date          string `json:"date"`
+	TotalSeconds  int64  `json:"totalSeconds"`
+	TargetSeconds int64  `json:"targetSeconds"`
+}
+
+// WeekSheet is the time tracked per project on each day from Monday to
+// Sunday of an ISO week.
+type WeekSheet struct {
+	Year          int                `json:"year"`
+	Week          int                `json:"week"`
+	Days          [7]WeekSheetDay    `json:"days"`
+	Projects      []WeekSheetProject `json:"projects"`
+	TotalSeconds  int64              `json:"totalSeconds"`
+	TargetSeconds int64              `json:"targetSeconds"`
+	DeltaSeconds  int64              `json:"deltaSeconds"`
+}
+
+// NewWeekSheet sums up the activities of user in the week beginning on
+// monday. Activities spanning midnight are split across the days.
+func NewWeekSheet(user string, monday time.Time, targets Targets) (WeekSheet, error) {
+	sheet := WeekSheet{}
+	sheet.Year, sheet.Week = monday.ISOWeek()
+
+	entries, err := database.ListEntriesBetween(user, monday, monday.AddDate(0, 0, 7))
+	if err != nil {
+		return sheet, err
+	}
+
+	var days [8]time.Time
+	for idx := range days {
+		days[idx] = monday.AddDate(0, 0, idx)
+	}
+	for idx := range sheet.Days {
+		sheet.Days[idx].Date = days[idx].Format(DateFormat)
+		sheet.Days[idx].TargetSeconds = int64(targets.Day(days[idx]).Seconds())
+		sheet.TargetSeconds += sheet.Days[idx].TargetSeconds
+	}
+
+	projects := make(map[string]*WeekSheetProject)
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Project)
+		project, ok := projects[key]
+		if !ok {
+			project = &WeekSheetProject{Project: entry.Project}
+			projects[key] = project
+		}
+
+		for idx := range sheet.Days {
+			seconds := int64(zeit.ClippedDuration(entry, days[idx], days[idx+1]).Seconds())
+			project.Seconds[idx] += seconds
+			project.TotalSeconds += seconds
+			sheet.Days[idx].TotalSeconds += seconds
+			sheet.TotalSeconds += seconds
+		}
+	}
+
+	for _, project := range projects {
+		if project.TotalSeconds > 0 {
+			sheet.Projects = append(sheet.Projects, *project)
+		}
+	}
+	sort.Slice(sheet.Projects, func(i, j int) bool {
+		return strings.ToLower(sheet.Projects[i].Project) < strings.ToLower(sheet.Projects[j].Project)
+	})
+	sheet.DeltaSeconds = sheet.TotalSeconds - sheet.TargetSeconds
+
+	return sheet, nil
+}
+
+// GetOutput renders the week as a grid of projects and days.
+func (sheet *WeekSheet) GetOutput(withTargets bool) string {
+	var output strings.Builder
+
+	hours := func(seconds int64) string {
+		if seconds == 0 {
+			return "-"
+		}
+		return fmtDuration(time.Duration(seconds) * time.Second)
+	}
+
+	name := func(project string) string {
+		if project == "" {
+			return "(no project)"
+		}
+		return project
+	}
+
+	label := 7
+	for _, project := range sheet.Projects {
+		label = max(label, len([]rune(name(project.Project))))
+	}
+	label = min(label, 32)
+	width := 7
+
+	row := func(title string, cells []string, total string) string {
+		if runes := []rune(title); len(runes) > label {
+			title = string(runes[:label-1]) + "…"
+		}
+		line := fmt.Sprintf("   %-*s", label, title)
+		for _, cell := range cells {
+			line += fmt.Sprintf(" %*s", width, cell)
+		}
+		return line + fmt.Sprintf("  %*s\n", width, total)
+	}
+
+	monday, _ := time.ParseInLocation(DateFormat, sheet.Days[0].Date, time.Local)
+	output.WriteString(fmt.Sprintf("\nWEEK %d-W%02d (%s - %s)\n\n", sheet.Year, sheet.Week, sheet.Days[0].Date, sheet.Days[6].Date))
+
+	var header []string
+	for idx := range sheet.Days {
+		header = append(header, monday.AddDate(0, 0, idx).Format("Mon 02"))
+	}
+	output.WriteString(row("", header, "Total"))
+
+	for _, project := range sheet.Projects {
+		var cells []string
+		for _, seconds := range project.Seconds {
+			cells = append(cells, hours(seconds))
+		}
+		output.WriteString(row(name(project.Project), cells, hours(project.TotalSeconds)))
+	}
+
+	output.WriteString("   " + strings.Repeat("─", label+7*(width+1)+2+width) + "\n")
+
+	var totals, targets, deltas []string
+	for _, day := range sheet.Days {
+		totals = append(totals, hours(day.TotalSeconds))
+		targets = append(targets, hours(day.TargetSeconds))
+		if day.TargetSeconds == 0 && day.TotalSeconds == 0 {
+			deltas = append(deltas, "-")
+		} else {
+			deltas = append(deltas, fmtDelta(time.Duration(day.TotalSeconds-day.TargetSeconds)*time.Second))
+		}
+	}
+	output.WriteString(row("Total", totals, hours(sheet.TotalSeconds)))
+	if withTargets {
+		output.WriteString(row("Target", targets, hours(sheet.TargetSeconds)))
+		output.WriteString(row("Delta", deltas, fmtDelta(time.Duration(sheet.DeltaSeconds)*time.Second)))
+	}
+	output.WriteString("\n")
+
+	return output.String()
+}
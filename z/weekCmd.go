@@ -0,0 +1,51 @@
+package z
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/now"
+	"github.com/spf13/cobra"
+)
+
+var weekCmd = &cobra.Command{
+	Use:         "week ([flags]) [week]",
+	Short:       "Weekly timesheet",
+	Long:        "Show the hours tracked per project on each day of the current or the given ISO week (e.g. 2024-W10 or W10), with daily and weekly totals and the difference to the configured targets.",
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{AnnotationReadOnly: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := GetCurrentUser()
+
+		targets, err := GetTargets()
+		if err != nil {
+			return err
+		}
+
+		var monday time.Time
+		if len(args) > 0 {
+			if monday, err = ParseISOWeek(args[0]); err != nil {
+				return err
+			}
+		} else {
+			monday = now.Monday()
+		}
+
+		sheet, err := NewWeekSheet(user, monday, targets)
+		if err != nil {
+			return err
+		}
+
+		if IsOutputJSON() {
+			return printJSON(sheet)
+		}
+
+		fmt.Print(sheet.GetOutput(targets.IsSet()))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(weekCmd)
+	weekCmd.Flags().BoolVar(&fractional, "decimal", false, "Show fractional hours in decimal format instead of minutes")
+}
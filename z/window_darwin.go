@@ -0,0 +1,28 @@
+//go:build darwin
+
+package z
+
+import (
+	"strings"
+)
+
+// The window title needs the accessibility permission, without it only the
+// app is told.
+const frontWindowScript string = `tell application "System Events"
+	set frontApp to first application process whose frontmost is true
+	set windowTitle to ""
+	try
+		set windowTitle to name of front window of frontApp
+	end try
+	return (name of frontApp) & tab & windowTitle
+end tell`
+
+func platformActiveWindow() (Window, error) {
+	output, err := runWindowHelper("osascript", "-e", frontWindowScript)
+	if err != nil {
+		return Window{}, err
+	}
+
+	app, title, _ := strings.Cut(output, "\t")
+	return Window{App: app, Title: title}, nil
+}
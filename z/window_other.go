@@ -0,0 +1,113 @@
+//go:build !(darwin || windows)
+
+package z
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var xpropValuePattern = regexp.MustCompile(`^[A-Z_]+\([A-Z_0-9]+\) = (.*)$`)
+
+type swayNode struct {
+	Focused          bool   `json:"focused"`
+	Name             string `json:"name"`
+	AppID            string `json:"app_id"`
+	WindowProperties struct {
+		Class string `json:"class"`
+	} `json:"window_properties"`
+	Nodes         []swayNode `json:"nodes"`
+	FloatingNodes []swayNode `json:"floating_nodes"`
+}
+
+func (node *swayNode) focused() (*swayNode, bool) {
+	if node.Focused {
+		return node, true
+	}
+	for _, children := range [][]swayNode{node.Nodes, node.FloatingNodes} {
+		for idx := range children {
+			if focused, ok := children[idx].focused(); ok {
+				return focused, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// platformActiveWindow asks sway or Hyprland on Wayland, or xprop on X11,
+// for the focused window, using its class or app ID as app. Other Wayland
+// compositors don't tell, and need a watch.command.
+func platformActiveWindow() (Window, error) {
+	switch {
+	case os.Getenv("SWAYSOCK") != "":
+		output, err := runWindowHelper("swaymsg", "-t", "get_tree", "-r")
+		if err != nil {
+			return Window{}, err
+		}
+		var tree swayNode
+		if err = json.Unmarshal([]byte(output), &tree); err != nil {
+			return Window{}, err
+		}
+		node, ok := tree.focused()
+		if !ok {
+			return Window{}, nil
+		}
+		app := node.AppID
+		if app == "" {
+			app = node.WindowProperties.Class
+		}
+		return Window{App: app, Title: node.Name}, nil
+
+	case os.Getenv("HYPRLAND_INSTANCE_SIGNATURE") != "":
+		output, err := runWindowHelper("hyprctl", "activewindow", "-j")
+		if err != nil {
+			return Window{}, err
+		}
+		var window struct {
+			Class string `json:"class"`
+			Title string `json:"title"`
+		}
+		if err = json.Unmarshal([]byte(output), &window); err != nil {
+			return Window{}, err
+		}
+		return Window{App: window.Class, Title: window.Title}, nil
+
+	case os.Getenv("DISPLAY") != "":
+		output, err := runWindowHelper("xprop", "-root", "_NET_ACTIVE_WINDOW")
+		if err != nil {
+			return Window{}, err
+		}
+		fields := strings.Fields(output)
+		if len(fields) == 0 || fields[len(fields)-1] == "0x0" {
+			return Window{}, nil
+		}
+		if output, err = runWindowHelper("xprop", "-id", fields[len(fields)-1], "WM_CLASS", "_NET_WM_NAME"); err != nil {
+			return Window{}, err
+		}
+
+		var window Window
+		for _, line := range strings.Split(output, "\n") {
+			match := xpropValuePattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			// WM_CLASS is the instance and the class, both quoted
+			values := strings.Split(match[1], ", ")
+			value := strings.Trim(values[len(values)-1], `"`)
+			if strings.HasPrefix(line, "WM_CLASS") {
+				window.App = value
+			} else if title, err := strconv.Unquote(match[1]); err == nil {
+				window.Title = title
+			} else {
+				window.Title = strings.Trim(match[1], `"`)
+			}
+		}
+		return window, nil
+	}
+
+	return Window{}, errors.New("could not tell the window in front without a graphical session, please configure watch.command or watch.activitywatch")
+}
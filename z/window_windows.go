@@ -0,0 +1,47 @@
+//go:build windows
+
+package z
+
+import (
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetWindowTextW = windows.NewLazySystemDLL("user32.dll").NewProc("GetWindowTextW")
+
+// platformActiveWindow returns the title of the foreground window and the
+// executable of its process, without .exe, as app.
+func platformActiveWindow() (Window, error) {
+	var window Window
+
+	hwnd := windows.GetForegroundWindow()
+	if hwnd == 0 {
+		return window, nil
+	}
+
+	title := make([]uint16, 512)
+	length, _, _ := procGetWindowTextW.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&title[0])), uintptr(len(title)))
+	window.Title = windows.UTF16ToString(title[:length])
+
+	var pid uint32
+	if _, err := windows.GetWindowThreadProcessId(hwnd, &pid); err != nil {
+		return window, err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		// Elevated processes can't be looked into
+		return window, nil
+	}
+	defer windows.CloseHandle(process)
+
+	exe := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(exe))
+	if err = windows.QueryFullProcessImageName(process, 0, &exe[0], &size); err != nil {
+		return window, err
+	}
+	window.App = strings.TrimSuffix(filepath.Base(windows.UTF16ToString(exe[:size])), ".exe")
+	return window, nil
+}
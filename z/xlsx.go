@@ -0,0 +1,321 @@
+package z
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrusme/zeit/pkg/zeit"
+	"github.com/spf13/viper"
+)
+
+// XLSXLayout configures the timesheet: Columns sums up per project or per
+// project and task, AllDays includes days without activities and
+// DurationFormat writes decimal hours or hh:mm.
+type XLSXLayout struct {
+	Columns        string `mapstructure:"columns"`
+	AllDays        bool   `mapstructure:"allDays"`
+	DurationFormat string `mapstructure:"durationFormat"`
+}
+
+func XLSXColumns() []string {
+	return []string{
+		XLSXColumnsProject,
+		XLSXColumnsTask,
+	}
+}
+
+// GetXLSXLayout returns the layout configured as xlsx.layout, by default
+// project columns on all days of the month in decimal hours.
+func GetXLSXLayout() (XLSXLayout, error) {
+	layout := XLSXLayout{Columns: XLSXColumnsProject, AllDays: true, DurationFormat: DurationDecimal}
+	if err := viper.UnmarshalKey("xlsx.layout", &layout); err != nil {
+		return layout, fmt.Errorf("invalid xlsx.layout: %v", err)
+	}
+	return layout, layout.validate()
+}
+
+func (layout *XLSXLayout) validate() error {
+	layout.Columns = strings.ToLower(layout.Columns)
+	if !zeit.ContainsFold(XLSXColumns(), layout.Columns) {
+		return fmt.Errorf("unknown xlsx columns '%s', possible values: %s", layout.Columns, strings.Join(XLSXColumns(), ", "))
+	}
+	layout.DurationFormat = strings.ToLower(layout.DurationFormat)
+	if !zeit.ContainsFold(DurationFormats(), layout.DurationFormat) {
+		return fmt.Errorf("unknown duration format '%s', possible values: %s", layout.DurationFormat, strings.Join(DurationFormats(), ", "))
+	}
+	return nil
+}
+
+// Cell styles defined in xlsxStyles
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleDate
+	xlsxStyleDecimal
+	xlsxStyleClock
+	xlsxStyleHeader
+	xlsxStyleTotalDecimal
+	xlsxStyleTotalClock
+)
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="3"><numFmt numFmtId="164" formatCode="yyyy-mm-dd ddd"/><numFmt numFmtId="165" formatCode="0.00"/><numFmt numFmtId="166" formatCode="[h]:mm"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="7">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="165" fontId="1" fillId="0" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1"/>
+<xf numFmtId="166" fontId="1" fillId="0" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1"/>
+</cellXfs>
+</styleSheet>`
+
+// xlsxColumn returns the column name of the zero based index, e.g. AA for 26.
+func xlsxColumn(idx int) string {
+	name := ""
+	for idx++; idx > 0; idx = (idx - 1) / 26 {
+		name = string(rune('A'+(idx-1)%26)) + name
+	}
+	return name
+}
+
+func xlsxEscape(value string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+// xlsxSerial returns the date as Excel serial day number.
+func xlsxSerial(date time.Time) int {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return int(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Sub(epoch).Hours() / 24)
+}
+
+type xlsxSheet struct {
+	Name    string
+	rows    strings.Builder
+	row     int
+	columns int
+}
+
+func (sheet *xlsxSheet) addRow(cells []string) {
+	sheet.row++
+	fmt.Fprintf(&sheet.rows, `<row r="%d">`, sheet.row)
+	for _, cell := range cells {
+		sheet.rows.WriteString(cell)
+	}
+	sheet.rows.WriteString("</row>")
+}
+
+func (sheet *xlsxSheet) stringCell(column int, value string, style int) string {
+	return fmt.Sprintf(`<c r="%s%d" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, xlsxColumn(column), sheet.row+1, style, xlsxEscape(value))
+}
+
+func (sheet *xlsxSheet) numberCell(column int, value float64, style int) string {
+	return fmt.Sprintf(`<c r="%s%d" s="%d"><v>%s</v></c>`, xlsxColumn(column), sheet.row+1, style, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (sheet *xlsxSheet) formulaCell(column int, formula string, value float64, style int) string {
+	return fmt.Sprintf(`<c r="%s%d" s="%d"><f>%s</f><v>%s</v></c>`, xlsxColumn(column), sheet.row+1, style, formula, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (sheet *xlsxSheet) xml() string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		fmt.Sprintf(`<cols><col min="1" max="1" width="16" customWidth="1"/><col min="2" max="%d" width="14" customWidth="1"/></cols>`, sheet.columns) +
+		`<sheetData>` + sheet.rows.String() + `</sheetData></worksheet>`
+}
+
+type xlsxDay map[string]time.Duration
+
+// ExportXLSX writes a timesheet workbook with one sheet per month, a row per
+// day and a column per project (or project and task), followed by a totals
+// row.
+func ExportXLSX(entries []Entry, layout XLSXLayout) ([]byte, error) {
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+
+	value := func(duration time.Duration) float64 {
+		if layout.DurationFormat == DurationClock {
+			return duration.Round(time.Minute).Hours() / 24
+		}
+		return duration.Hours()
+	}
+	style, totalStyle := xlsxStyleDecimal, xlsxStyleTotalDecimal
+	if layout.DurationFormat == DurationClock {
+		style, totalStyle = xlsxStyleClock, xlsxStyleTotalClock
+	}
+
+	months := make(map[string]map[string]xlsxDay)
+	for _, entry := range entries {
+		column := entry.Project
+		if layout.Columns == XLSXColumnsTask && entry.Task != "" {
+			column = entry.Project + ": " + entry.Task
+		}
+
+		month := entry.Begin.Format("2006-01")
+		day := entry.Begin.Format(DateFormat)
+		if months[month] == nil {
+			months[month] = make(map[string]xlsxDay)
+		}
+		if months[month][day] == nil {
+			months[month][day] = make(xlsxDay)
+		}
+		months[month][day][column] += entry.End().Sub(entry.Begin)
+	}
+
+	var monthNames []string
+	for month := range months {
+		monthNames = append(monthNames, month)
+	}
+	sort.Strings(monthNames)
+
+	var sheets []*xlsxSheet
+	for _, month := range monthNames {
+		days := months[month]
+
+		var columns []string
+		seen := make(map[string]bool)
+		for _, durations := range days {
+			for column := range durations {
+				if !seen[column] {
+					seen[column] = true
+					columns = append(columns, column)
+				}
+			}
+		}
+		sort.Strings(columns)
+
+		var dates []string
+		if layout.AllDays {
+			first, _ := time.ParseInLocation("2006-01", month, time.Local)
+			for date := first; date.Month() == first.Month(); date = date.AddDate(0, 0, 1) {
+				dates = append(dates, date.Format(DateFormat))
+			}
+		} else {
+			for date := range days {
+				dates = append(dates, date)
+			}
+			sort.Strings(dates)
+		}
+
+		sheet := &xlsxSheet{Name: month}
+		last := xlsxColumn(len(columns))
+
+		header := []string{sheet.stringCell(0, "Date", xlsxStyleHeader)}
+		for idx, column := range columns {
+			name := column
+			if name == "" {
+				name = "(no project)"
+			}
+			header = append(header, sheet.stringCell(idx+1, name, xlsxStyleHeader))
+		}
+		header = append(header, sheet.stringCell(len(columns)+1, "Total", xlsxStyleHeader))
+		sheet.addRow(header)
+
+		totals := make([]time.Duration, len(columns)+1)
+		for _, date := range dates {
+			t, _ := time.ParseInLocation(DateFormat, date, time.Local)
+			cells := []string{sheet.numberCell(0, float64(xlsxSerial(t)), xlsxStyleDate)}
+
+			var total time.Duration
+			for idx, column := range columns {
+				duration, ok := days[date][column]
+				total += duration
+				totals[idx] += duration
+				if ok {
+					cells = append(cells, sheet.numberCell(idx+1, value(duration), style))
+				}
+			}
+			totals[len(columns)] += total
+			cells = append(cells, sheet.formulaCell(len(columns)+1, fmt.Sprintf("SUM(B%d:%s%d)", sheet.row+1, last, sheet.row+1), value(total), totalStyle))
+			sheet.addRow(cells)
+		}
+
+		cells := []string{sheet.stringCell(0, "Total", xlsxStyleHeader)}
+		for idx := range totals {
+			column := xlsxColumn(idx + 1)
+			cells = append(cells, sheet.formulaCell(idx+1, fmt.Sprintf("SUM(%s2:%s%d)", column, column, sheet.row), value(totals[idx]), totalStyle))
+		}
+		sheet.addRow(cells)
+
+		sheet.columns = len(columns) + 2
+		sheets = append(sheets, sheet)
+	}
+
+	if len(sheets) == 0 {
+		sheet := &xlsxSheet{Name: "Timesheet", columns: 2}
+		sheet.addRow([]string{sheet.stringCell(0, "Date", xlsxStyleHeader), sheet.stringCell(1, "Total", xlsxStyleHeader)})
+		sheets = append(sheets, sheet)
+	}
+	return writeXLSX(sheets)
+}
+
+func writeXLSX(sheets []*xlsxSheet) ([]byte, error) {
+	var contentTypes, workbook, relationships strings.Builder
+
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	relationships.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+
+	files := make(map[string]string)
+	var names []string
+	for idx, sheet := range sheets {
+		file := fmt.Sprintf("worksheets/sheet%d.xml", idx+1)
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/%s" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, file)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet.Name), idx+1, idx+1)
+		fmt.Fprintf(&relationships, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="%s"/>`, idx+1, file)
+		files["xl/"+file] = sheet.xml()
+		names = append(names, "xl/"+file)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	relationships.WriteString(`</Relationships>`)
+
+	files["[Content_Types].xml"] = contentTypes.String()
+	files["_rels/.rels"] = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	files["xl/workbook.xml"] = workbook.String()
+	files["xl/_rels/workbook.xml.rels"] = relationships.String()
+	files["xl/styles.xml"] = xlsxStyles
+	names = append([]string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"}, names...)
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}